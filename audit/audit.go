@@ -0,0 +1,179 @@
+// Package audit records the audit events of the authentication flows of
+// gothic, e.g. for compliance: the start, completion and failure of
+// authentications, logouts and token refreshes, with the subject, the
+// provider, the IP address and the user agent. The events are written to
+// sinks: JSON lines to stdout or a file, or a webhook.
+//
+//	file, err := audit.NewFileSink("/var/log/app/audit.log")
+//	...
+//	logger := audit.New(audit.NewJSONSink(os.Stdout), file)
+//	gothic.Observers = append(gothic.Observers, logger.Observe)
+package audit
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	"golang.org/x/oauth2"
+)
+
+// EventType is the type of an audit event.
+type EventType string
+
+// The types of audit events.
+const (
+	EventAuthStarted    EventType = "auth_started"
+	EventAuthCompleted  EventType = "auth_completed"
+	EventAuthFailed     EventType = "auth_failed"
+	EventLogout         EventType = "logout"
+	EventTokenRefreshed EventType = "token_refreshed"
+)
+
+// Event is an audit event.
+type Event struct {
+	Time time.Time `json:"time"`
+	Type EventType `json:"type"`
+	// Subject is the user ID of the user, empty when the user isn't known,
+	// e.g. when the authentication failed.
+	Subject   string `json:"subject,omitempty"`
+	Provider  string `json:"provider,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	// Operation is the operation of gothic the event comes from.
+	Operation string `json:"operation,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Sink receives the audit events.
+type Sink interface {
+	Write(event Event) error
+}
+
+// Logger writes the audit events to its sinks.
+type Logger struct {
+	Sinks []Sink
+	// ClientIP returns the IP address of the client of a request. It defaults
+	// to the address of the connection, set it when the application is behind
+	// a proxy.
+	ClientIP func(req *http.Request) string
+	// ErrorHandler is called when a sink fails to write an event. It defaults
+	// to logging the error.
+	ErrorHandler func(sink Sink, event Event, err error)
+
+	mu sync.Mutex
+}
+
+// New creates a logger writing the events to the sinks.
+func New(sinks ...Sink) *Logger {
+	return &Logger{Sinks: sinks}
+}
+
+// Log writes the event to every sink, in order. The time of the event is set
+// when it is zero.
+func (l *Logger) Log(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	// the events are written in the same order to every sink
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, sink := range l.Sinks {
+		if err := sink.Write(event); err != nil {
+			l.handleError(sink, event, err)
+		}
+	}
+}
+
+func (l *Logger) handleError(sink Sink, event Event, err error) {
+	if l.ErrorHandler != nil {
+		l.ErrorHandler(sink, event, err)
+		return
+	}
+	log.Printf("audit: writing %s event: %v", event.Type, err)
+}
+
+// Observe logs the audit event of an operation of gothic. It is a
+// gothic.Observer. A completion requiring a second factor is logged once the
+// second factor is verified.
+func (l *Logger) Observe(op gothic.Operation) {
+	event := Event{
+		Time:      op.Start.UTC(),
+		Provider:  op.Provider,
+		Subject:   op.User.UserID,
+		Operation: op.Name,
+	}
+	if op.Request != nil {
+		event.IP = l.clientIP(op.Request)
+		event.UserAgent = op.Request.UserAgent()
+	}
+
+	switch {
+	case errors.Is(op.Err, gothic.ErrSecondFactorRequired):
+		return
+	case op.Name == gothic.OperationLogout:
+		event.Type = EventLogout
+	case op.Err != nil:
+		event.Type = EventAuthFailed
+	case op.Name == gothic.OperationBeginAuth:
+		event.Type = EventAuthStarted
+	case op.Name == gothic.OperationCompleteUserAuth, op.Name == gothic.OperationVerifySecondFactor:
+		event.Type = EventAuthCompleted
+	default:
+		return
+	}
+	if op.Err != nil {
+		event.Error = errorMessage(op.Err)
+	}
+	l.Log(event)
+}
+
+// RefreshToken refreshes the token of the user with the provider, and logs
+// the refresh. The request is the one of the user, if any, and may be nil.
+func (l *Logger) RefreshToken(req *http.Request, provider goth.Provider, user goth.User) (*oauth2.Token, error) {
+	event := Event{
+		Time:     time.Now().UTC(),
+		Type:     EventTokenRefreshed,
+		Subject:  user.UserID,
+		Provider: provider.Name(),
+	}
+	if req != nil {
+		event.IP = l.clientIP(req)
+		event.UserAgent = req.UserAgent()
+	}
+	token, err := provider.RefreshToken(user.RefreshToken)
+	if err != nil {
+		event.Error = errorMessage(err)
+	}
+	l.Log(event)
+	return token, err
+}
+
+func (l *Logger) clientIP(req *http.Request) string {
+	if l.ClientIP != nil {
+		return l.ClientIP(req)
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// errorMessage returns the message of the error, without the body of the
+// responses of token endpoints, which may hold anything.
+func errorMessage(err error) string {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		if retrieveErr.ErrorCode != "" {
+			return "oauth2: token exchange failed: " + retrieveErr.ErrorCode
+		}
+		return "oauth2: token exchange failed"
+	}
+	return err.Error()
+}
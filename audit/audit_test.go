@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	"github.com/andreimerlescu/goth/providers/faux"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+type recorder struct {
+	events []Event
+	err    error
+}
+
+func (r *recorder) Write(event Event) error {
+	r.events = append(r.events, event)
+	return r.err
+}
+
+func Test_Observe(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	r := &recorder{}
+	logger := New(r)
+
+	req := httptest.NewRequest("GET", "/auth/callback?provider=github", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("User-Agent", "browser")
+	start := time.Now()
+	user := goth.User{UserID: "42", Provider: "github"}
+
+	logger.Observe(gothic.Operation{Name: gothic.OperationBeginAuth, Provider: "github", Request: req, Start: start})
+	logger.Observe(gothic.Operation{Name: gothic.OperationCompleteUserAuth, Provider: "github", Request: req, Start: start, User: user, Err: gothic.ErrSecondFactorRequired})
+	logger.Observe(gothic.Operation{Name: gothic.OperationVerifySecondFactor, Provider: "github", Request: req, Start: start, User: user})
+	logger.Observe(gothic.Operation{Name: gothic.OperationCompleteUserAuth, Provider: "github", Request: req, Start: start, Err: &oauth2.RetrieveError{Body: []byte("secret"), ErrorCode: "invalid_grant"}})
+	logger.Observe(gothic.Operation{Name: gothic.OperationLogout, Request: req, Start: start})
+
+	a.Len(r.events, 4)
+	a.Equal(Event{Time: start.UTC(), Type: EventAuthStarted, Provider: "github", IP: "192.0.2.1", UserAgent: "browser", Operation: "begin_auth"}, r.events[0])
+	a.Equal(EventAuthCompleted, r.events[1].Type)
+	a.Equal("42", r.events[1].Subject)
+	a.Equal(EventAuthFailed, r.events[2].Type)
+	a.Equal("oauth2: token exchange failed: invalid_grant", r.events[2].Error)
+	a.Equal(EventLogout, r.events[3].Type)
+
+	logger.ClientIP = func(req *http.Request) string { return "198.51.100.1" }
+	logger.Observe(gothic.Operation{Name: gothic.OperationBeginAuth, Request: req, Start: start})
+	a.Equal("198.51.100.1", r.events[4].IP)
+}
+
+type refreshProvider struct {
+	*faux.Provider
+	err error
+}
+
+func (p refreshProvider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: "new"}, p.err
+}
+
+func Test_RefreshToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	r := &recorder{err: errors.New("unavailable")}
+	var failures int
+	logger := New(r)
+	logger.ErrorHandler = func(sink Sink, event Event, err error) { failures++ }
+
+	token, err := logger.RefreshToken(nil, refreshProvider{Provider: &faux.Provider{}}, goth.User{UserID: "42", RefreshToken: "refresh"})
+	a.NoError(err)
+	a.Equal("new", token.AccessToken)
+	_, err = logger.RefreshToken(nil, refreshProvider{Provider: &faux.Provider{}, err: errors.New("revoked")}, goth.User{UserID: "42"})
+	a.Error(err)
+
+	a.Len(r.events, 2)
+	a.Equal(EventTokenRefreshed, r.events[0].Type)
+	a.Equal("faux", r.events[0].Provider)
+	a.Equal("42", r.events[0].Subject)
+	a.Empty(r.events[0].Error)
+	a.Equal("revoked", r.events[1].Error)
+	a.Equal(2, failures)
+}
+
+func Test_FileSink(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(path)
+	a.NoError(err)
+	logger := New(sink)
+	logger.Log(Event{Type: EventLogout, Subject: "42"})
+	logger.Log(Event{Type: EventAuthStarted, Provider: "github"})
+	a.NoError(sink.Close())
+
+	file, err := os.Open(path)
+	a.NoError(err)
+	defer file.Close()
+	info, err := file.Stat()
+	a.NoError(err)
+	a.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		a.NoError(json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	a.Len(events, 2)
+	a.Equal(EventLogout, events[0].Type)
+	a.False(events[0].Time.IsZero())
+	a.Equal("github", events[1].Provider)
+}
+
+func Test_JSONSink(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var buf bytes.Buffer
+	a.NoError(NewJSONSink(&buf).Write(Event{Time: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Type: EventAuthFailed, Error: "failure"}))
+	a.Equal(`{"time":"2020-01-02T03:04:05Z","type":"auth_failed","error":"failure"}`+"\n", buf.String())
+}
+
+func Test_WebhookSink(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var received Event
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		a.Equal("Bearer token", req.Header.Get("Authorization"))
+		a.Equal("application/json", req.Header.Get("Content-Type"))
+		a.NoError(json.NewDecoder(req.Body).Decode(&received))
+		res.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL)
+	sink.Header.Set("Authorization", "Bearer token")
+	a.NoError(sink.Write(Event{Type: EventAuthCompleted, Subject: "42"}))
+	a.Equal("42", received.Subject)
+
+	status = http.StatusInternalServerError
+	a.Error(sink.Write(Event{Type: EventAuthCompleted}))
+}
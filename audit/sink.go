@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// JSONSink writes the events as JSON lines, e.g. to os.Stdout.
+type JSONSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONSink creates a sink writing the events as JSON lines to w.
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{w: w}
+}
+
+// Write writes the event as a line of JSON.
+func (s *JSONSink) Write(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// FileSink appends the events as JSON lines to a file.
+type FileSink struct {
+	*JSONSink
+	file *os.File
+}
+
+// NewFileSink opens the file, creating it readable only by its owner when it
+// doesn't exist, to append the events to it.
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{JSONSink: NewJSONSink(file), file: file}, nil
+}
+
+// Write appends the event to the file, and syncs it to the disk.
+func (s *FileSink) Write(event Event) error {
+	if err := s.JSONSink.Write(event); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// WebhookSink posts every event as JSON to a URL. It is called by the
+// goroutine handling the request, so the endpoint should be quick, or the
+// sink wrapped to post the events asynchronously.
+type WebhookSink struct {
+	URL string
+	// Header is added to the requests, e.g. for authentication.
+	Header     http.Header
+	Timeout    time.Duration
+	HTTPClient *http.Client
+}
+
+// NewWebhookSink creates a sink posting the events to the URL, with a timeout
+// of 5 seconds.
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		URL:     url,
+		Header:  http.Header{},
+		Timeout: 5 * time.Second,
+	}
+}
+
+// Write posts the event. Responses other than 2xx are errors.
+func (s *WebhookSink) Write(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range s.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := goth.HTTPClientWithFallBack(s.HTTPClient).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit: webhook responded with a %d status code", resp.StatusCode)
+	}
+	return nil
+}
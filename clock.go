@@ -0,0 +1,56 @@
+package goth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"time"
+)
+
+// Clock tells the time. goth and gothic read it from DefaultClock to expire
+// the states, the second factors, the sessions and the tokens, so the tests
+// can control the time.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is the Clock of a function, e.g. of a fixed time.
+type ClockFunc func() time.Time
+
+// Now returns the time of the function.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// DefaultClock is the clock of goth, the system clock by default.
+var DefaultClock Clock = ClockFunc(time.Now)
+
+// Now returns the time of DefaultClock.
+func Now() time.Time {
+	return DefaultClock.Now()
+}
+
+// Rand is the source of the states, the nonces and the identifiers generated
+// by goth and gothic, crypto/rand.Reader by default. The tests can replace it
+// with a deterministic reader, it must be cryptographically secure otherwise.
+// The keys and the nonces of the ciphers are always read from crypto/rand.
+var Rand io.Reader = rand.Reader
+
+// RandomBytes returns n bytes read from Rand.
+func RandomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(Rand, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// GenerateVerifier returns a PKCE code verifier of 32 bytes read from Rand,
+// like oauth2.GenerateVerifier.
+func GenerateVerifier() (string, error) {
+	b, err := RandomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
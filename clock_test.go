@@ -0,0 +1,36 @@
+package goth_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Clock(t *testing.T) {
+	a := assert.New(t)
+	clock, rand := goth.DefaultClock, goth.Rand
+	defer func() { goth.DefaultClock, goth.Rand = clock, rand }()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	goth.DefaultClock = goth.ClockFunc(func() time.Time { return now })
+	goth.Rand = bytes.NewReader([]byte("0123456789"))
+
+	a.Equal(now, goth.Now())
+	b, err := goth.RandomBytes(4)
+	a.NoError(err)
+	a.Equal([]byte("0123"), b)
+	b, _ = goth.RandomBytes(6)
+	a.Equal([]byte("456789"), b)
+	_, err = goth.RandomBytes(1)
+	a.Error(err)
+
+	goth.Rand = bytes.NewReader(bytes.Repeat([]byte{0xff}, 32))
+	verifier, err := goth.GenerateVerifier()
+	a.NoError(err)
+	a.Equal(strings.Repeat("_", 42)+"8", verifier)
+	_, err = goth.GenerateVerifier()
+	a.Error(err)
+}
@@ -0,0 +1,170 @@
+// Command goth-scaffold generates a minimal application signing in users
+// with goth: its main.go, wired to gothic with the chosen router, session
+// store and providers, its go.mod and a README listing the callbacks to
+// register and the environment variables of the clients.
+//
+//	goth-scaffold -module example.com/app -router chi -providers github,google
+//	cd app && go mod tidy && go run .
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// routers are the routers of the generated application, std being the
+// http.ServeMux of the standard library.
+var routers = []string{"std", "chi", "gin", "echo"}
+
+// stores are the session stores of the generated application.
+var stores = []string{"cookie", "filesystem"}
+
+// app is the application to generate.
+type app struct {
+	Module    string
+	Name      string
+	Router    string
+	Store     string
+	Addr      string
+	Providers []providerSpec
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run generates the application of the arguments, and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("goth-scaffold", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	module := fs.String("module", "", "the module `path` of the application, e.g. example.com/app (required)")
+	dir := fs.String("out", "", "the `directory` of the application, the last element of the module by default")
+	router := fs.String("router", "std", "the router, one of "+strings.Join(routers, ", "))
+	store := fs.String("store", "cookie", "the session store, one of "+strings.Join(stores, ", "))
+	providers := fs.String("providers", "github", "the comma separated providers, of "+strings.Join(providerNames(), ", "))
+	addr := fs.String("addr", "localhost:3000", "the address of the application")
+	force := fs.Bool("force", false, "overwrite the existing files")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	a, err := newApp(*module, *router, *store, *providers, *addr)
+	if err != nil {
+		fmt.Fprintf(stderr, "goth-scaffold: %v\n", err)
+		return 2
+	}
+	if *dir == "" {
+		*dir = a.Name
+	}
+	files, err := a.generate()
+	if err != nil {
+		fmt.Fprintf(stderr, "goth-scaffold: %v\n", err)
+		return 1
+	}
+	if err := write(*dir, files, *force); err != nil {
+		fmt.Fprintf(stderr, "goth-scaffold: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Generated %s, see %s for the callbacks to register. Then run:\n\n  cd %s\n  go mod tidy\n  go run .\n",
+		*dir, filepath.Join(*dir, "README.md"), *dir)
+	return 0
+}
+
+// newApp validates the options of the application.
+func newApp(module, router, store, providers, addr string) (*app, error) {
+	if module == "" {
+		return nil, errors.New("-module is required, e.g. -module example.com/app")
+	}
+	if !contains(routers, router) {
+		return nil, fmt.Errorf("unsupported router %q, use one of %s", router, strings.Join(routers, ", "))
+	}
+	if !contains(stores, store) {
+		return nil, fmt.Errorf("unsupported store %q, use one of %s", store, strings.Join(stores, ", "))
+	}
+	a := &app{Module: module, Name: path.Base(module), Router: router, Store: store, Addr: addr}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(providers, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		spec, ok := providerSpecs[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported provider %q, use some of %s", name, strings.Join(providerNames(), ", "))
+		}
+		seen[name] = true
+		a.Providers = append(a.Providers, spec)
+	}
+	if len(a.Providers) == 0 {
+		return nil, errors.New("-providers is required, e.g. -providers github,google")
+	}
+	return a, nil
+}
+
+// generate returns the files of the application, by name.
+func (a *app) generate() (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for name, text := range map[string]string{
+		"main.go":   mainTemplate,
+		"go.mod":    goModTemplate,
+		"README.md": readmeTemplate,
+	} {
+		t, err := template.New(name).Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		var b bytes.Buffer
+		if err := t.Execute(&b, a); err != nil {
+			return nil, err
+		}
+		files[name] = b.Bytes()
+	}
+	src, err := format.Source(files["main.go"])
+	if err != nil {
+		return nil, fmt.Errorf("formatting main.go: %w", err)
+	}
+	files["main.go"] = src
+	return files, nil
+}
+
+// write writes the files in the directory, without overwriting the existing
+// files unless force is set.
+func write(dir string, files map[string][]byte, force bool) error {
+	if !force {
+		for name := range files {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return fmt.Errorf("%s exists, use -force to overwrite it", filepath.Join(dir, name))
+			}
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
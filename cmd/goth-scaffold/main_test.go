@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Generate(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	for _, router := range routers {
+		for _, store := range stores {
+			app, err := newApp("example.com/login", router, store, "github, openid-connect,okta,github", "localhost:3000")
+			a.NoError(err)
+			a.Len(app.Providers, 3)
+
+			files, err := app.generate()
+			a.NoError(err, router)
+			main := string(files["main.go"])
+			a.Contains(main, `"github.com/andreimerlescu/goth/providers/github"`)
+			a.Contains(main, `callbackURL("openid-connect")`)
+			a.Contains(main, `os.Getenv("OKTA_ORG_URL"), callbackURL("okta")`)
+			a.Contains(main, "/auth/{{.Name}}")
+			if store == "filesystem" {
+				a.Contains(main, "gothic.UseFilesystem")
+			} else {
+				a.Contains(main, "gothic.UseCookies")
+			}
+			if router != "std" {
+				a.Contains(main, `"github.com/`+map[string]string{"chi": "go-chi/chi/v5", "gin": "gin-gonic/gin", "echo": "labstack/echo/v4"}[router]+`"`)
+			}
+			a.Equal("module example.com/login\n\ngo 1.22\n", string(files["go.mod"]))
+			a.Contains(string(files["README.md"]), "| OpenID Connect | http://localhost:3000/auth/openid-connect/callback | OPENID_CONNECT_KEY, OPENID_CONNECT_SECRET, OPENID_CONNECT_DISCOVERY_URL |")
+		}
+	}
+}
+
+// Test_Generate_Build builds and vets the applications of every router, with
+// goth replaced by this tree.
+func Test_Generate_Build(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the generated applications")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go command")
+	}
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, router := range routers {
+		router := router
+		t.Run(router, func(t *testing.T) {
+			t.Parallel()
+			a := assert.New(t)
+			store := "cookie"
+			if router == "std" {
+				store = "filesystem"
+			}
+			app, err := newApp("example.com/login", router, store, "github,google,openid-connect,okta", "localhost:3000")
+			a.NoError(err)
+			files, err := app.generate()
+			a.NoError(err)
+			files["go.mod"] = append(files["go.mod"], []byte("\nrequire github.com/andreimerlescu/goth v0.0.0\n\nreplace github.com/andreimerlescu/goth => "+root+"\n")...)
+			dir := t.TempDir()
+			a.NoError(write(dir, files, false))
+
+			for _, args := range [][]string{{"mod", "tidy"}, {"build", "./..."}, {"vet", "./..."}} {
+				cmd := exec.Command(goBin, args...)
+				cmd.Dir = dir
+				out, err := cmd.CombinedOutput()
+				if !a.NoError(err, "go %s: %s", strings.Join(args, " "), out) {
+					return
+				}
+			}
+		})
+	}
+}
+
+func Test_NewApp_Errors(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	_, err := newApp("", "std", "cookie", "github", "localhost:3000")
+	a.ErrorContains(err, "-module")
+	_, err = newApp("example.com/app", "gorilla", "cookie", "github", "localhost:3000")
+	a.ErrorContains(err, "router")
+	_, err = newApp("example.com/app", "std", "redis", "github", "localhost:3000")
+	a.ErrorContains(err, "store")
+	_, err = newApp("example.com/app", "std", "cookie", "myspace", "localhost:3000")
+	a.ErrorContains(err, "myspace")
+	_, err = newApp("example.com/app", "std", "cookie", " , ", "localhost:3000")
+	a.ErrorContains(err, "-providers")
+}
+
+func Test_Run(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	dir := filepath.Join(t.TempDir(), "app")
+
+	var stdout, stderr bytes.Buffer
+	a.Equal(0, run([]string{"-module", "example.com/app", "-out", dir, "-router", "chi", "-providers", "google"}, &stdout, &stderr), stderr.String())
+	a.Contains(stdout.String(), "go mod tidy")
+	for _, name := range []string{"main.go", "go.mod", "README.md"} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		a.NoError(err, name)
+	}
+
+	stderr.Reset()
+	a.Equal(1, run([]string{"-module", "example.com/app", "-out", dir}, &stdout, &stderr))
+	a.True(strings.Contains(stderr.String(), "-force"), stderr.String())
+	a.Equal(0, run([]string{"-module", "example.com/app", "-out", dir, "-force"}, &stdout, &stderr))
+
+	a.Equal(2, run([]string{"-router", "chi"}, &stdout, &stderr))
+	a.Equal(2, run([]string{"-unknown"}, &stdout, &stderr))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// providerSpec is a provider the scaffolder can wire into the application.
+type providerSpec struct {
+	// Name is the name of the provider, in its routes.
+	Name string
+	// Title is the name displayed on the login page.
+	Title string
+	// Package is the name of the package of the provider.
+	Package string
+	// Args are the arguments of New after the client and the callback, e.g.
+	// the scopes, with ENV replaced by the prefix of its environment variables.
+	Args string
+	// Env are the environment variables of the provider after the client, e.g.
+	// the domain of auth0, without the prefix.
+	Env []string
+	// Err is set when New returns an error.
+	Err bool
+	// Org is set when the organization is the third argument of New, before
+	// the callback.
+	Org string
+}
+
+// EnvPrefix returns the prefix of the environment variables of the provider,
+// e.g. OPENID_CONNECT for openid-connect.
+func (p providerSpec) EnvPrefix() string {
+	return strings.ToUpper(strings.ReplaceAll(p.Name, "-", "_"))
+}
+
+// Import returns the import path of the package of the provider.
+func (p providerSpec) Import() string {
+	return "github.com/andreimerlescu/goth/providers/" + p.Package
+}
+
+// Extra returns the arguments of New after the callback.
+func (p providerSpec) Extra() string {
+	return strings.ReplaceAll(p.Args, "ENV", p.EnvPrefix())
+}
+
+// OrgArg returns the organization argument of New, before the callback.
+func (p providerSpec) OrgArg() string {
+	return strings.ReplaceAll(p.Org, "ENV", p.EnvPrefix())
+}
+
+var providerSpecs = map[string]providerSpec{}
+
+func init() {
+	for _, p := range []providerSpec{
+		{Name: "amazon", Title: "Amazon"},
+		{Name: "auth0", Title: "Auth0", Args: `, os.Getenv("ENV_DOMAIN"), "openid", "profile", "email"`, Env: []string{"DOMAIN"}},
+		{Name: "bitbucket", Title: "Bitbucket"},
+		{Name: "box", Title: "Box"},
+		{Name: "digitalocean", Title: "DigitalOcean", Args: `, "read"`},
+		{Name: "discord", Title: "Discord", Args: `, discord.ScopeIdentify, discord.ScopeEmail`},
+		{Name: "dropbox", Title: "Dropbox"},
+		{Name: "facebook", Title: "Facebook"},
+		{Name: "figma", Title: "Figma"},
+		{Name: "gitea", Title: "Gitea"},
+		{Name: "github", Title: "GitHub", Args: `, "read:user", "user:email"`},
+		{Name: "gitlab", Title: "GitLab"},
+		{Name: "google", Title: "Google", Args: `, "openid", "profile", "email"`},
+		{Name: "heroku", Title: "Heroku"},
+		{Name: "linkedin", Title: "LinkedIn"},
+		{Name: "microsoftonline", Title: "Microsoft"},
+		{Name: "okta", Title: "Okta", Org: `os.Getenv("ENV_ORG_URL")`, Args: `, "openid", "profile", "email"`, Env: []string{"ORG_URL"}},
+		{Name: "openid-connect", Title: "OpenID Connect", Package: "openidConnect", Args: `, os.Getenv("ENV_DISCOVERY_URL"), "openid", "profile", "email"`, Env: []string{"DISCOVERY_URL"}, Err: true},
+		{Name: "patreon", Title: "Patreon"},
+		{Name: "roblox", Title: "Roblox"},
+		{Name: "salesforce", Title: "Salesforce"},
+		{Name: "slack", Title: "Slack"},
+		{Name: "spotify", Title: "Spotify"},
+		{Name: "strava", Title: "Strava"},
+		{Name: "twitch", Title: "Twitch"},
+		{Name: "yahoo", Title: "Yahoo"},
+		{Name: "zoom", Title: "Zoom", Args: `, "read:user"`},
+	} {
+		if p.Package == "" {
+			p.Package = p.Name
+		}
+		providerSpecs[p.Name] = p
+	}
+}
+
+// providerNames returns the names of the providers of the scaffolder, sorted.
+func providerNames() []string {
+	names := make([]string, 0, len(providerSpecs))
+	for name := range providerSpecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,235 @@
+package main
+
+// mainTemplate is the main.go of the application.
+const mainTemplate = `// Command {{.Name}} signs in users with goth. It was generated by
+// goth-scaffold, see the README.
+package main
+
+import (
+	"crypto/rand"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	{{- if eq .Store "filesystem"}}
+	"path/filepath"
+	{{- end}}
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	{{- range .Providers}}
+	"{{.Import}}"
+	{{- end}}
+	{{- if eq .Router "chi"}}
+	"github.com/go-chi/chi/v5"
+	{{- else if eq .Router "gin"}}
+	"github.com/gin-gonic/gin"
+	{{- else if eq .Router "echo"}}
+	"github.com/labstack/echo/v4"
+	{{- end}}
+	"github.com/gorilla/sessions"
+)
+
+// baseURL is the URL of the application, of the callbacks registered with
+// the providers.
+var baseURL = getenv("BASE_URL", "http://{{.Addr}}")
+
+// appSession is the name of the session of the signed in user.
+const appSession = "{{.Name}}_session"
+
+func main() {
+	key := []byte(os.Getenv("SESSION_SECRET"))
+	if len(key) == 0 {
+		log.Println("SESSION_SECRET is not set, the sessions are lost on restart")
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatal(err)
+		}
+	}
+	opts := &sessions.Options{Path: "/", MaxAge: 86400, HttpOnly: true, Secure: strings.HasPrefix(baseURL, "https://")}
+	{{- if eq .Store "filesystem"}}
+	dir := getenv("SESSION_DIR", filepath.Join(os.TempDir(), "{{.Name}}-sessions"))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		log.Fatal(err)
+	}
+	if err := gothic.UseFilesystem(dir, key, nil, 0, opts); err != nil {
+		log.Fatal(err)
+	}
+	{{- else}}
+	if err := gothic.UseCookies(key, opts); err != nil {
+		log.Fatal(err)
+	}
+	{{- end}}
+
+	{{range .Providers}}
+	{{- if .Err}}
+	{{.Package}}Provider, err := {{.Package}}.New(os.Getenv("{{.EnvPrefix}}_KEY"), os.Getenv("{{.EnvPrefix}}_SECRET"), callbackURL("{{.Name}}"){{.Extra}})
+	if err != nil {
+		log.Fatalf("{{.Name}}: %v", err)
+	}
+	goth.UseProviders({{.Package}}Provider)
+	{{- else if .Org}}
+	goth.UseProviders({{.Package}}.New(os.Getenv("{{.EnvPrefix}}_KEY"), os.Getenv("{{.EnvPrefix}}_SECRET"), {{.OrgArg}}, callbackURL("{{.Name}}"){{.Extra}}))
+	{{- else}}
+	goth.UseProviders({{.Package}}.New(os.Getenv("{{.EnvPrefix}}_KEY"), os.Getenv("{{.EnvPrefix}}_SECRET"), callbackURL("{{.Name}}"){{.Extra}}))
+	{{- end}}
+	{{end}}
+	addr := getenv("ADDR", "{{.Addr}}")
+	log.Printf("listening on %s", baseURL)
+	{{- if eq .Router "chi"}}
+	r := chi.NewRouter()
+	r.Get("/", index)
+	// gothic reads the provider of the routes of chi.
+	r.Get("/auth/{provider}", begin)
+	r.Get("/auth/{provider}/callback", callback)
+	r.Post("/logout", logout)
+	log.Fatal(http.ListenAndServe(addr, r))
+	{{- else if eq .Router "gin"}}
+	r := gin.Default()
+	r.GET("/", gin.WrapF(index))
+	r.GET("/auth/:provider", func(c *gin.Context) {
+		begin(c.Writer, gothic.GetContextWithProvider(c.Request, c.Param("provider")))
+	})
+	r.GET("/auth/:provider/callback", func(c *gin.Context) {
+		callback(c.Writer, gothic.GetContextWithProvider(c.Request, c.Param("provider")))
+	})
+	r.POST("/logout", gin.WrapF(logout))
+	log.Fatal(r.Run(addr))
+	{{- else if eq .Router "echo"}}
+	e := echo.New()
+	e.GET("/", echo.WrapHandler(http.HandlerFunc(index)))
+	e.GET("/auth/:provider", func(c echo.Context) error {
+		begin(c.Response(), gothic.GetContextWithProvider(c.Request(), c.Param("provider")))
+		return nil
+	})
+	e.GET("/auth/:provider/callback", func(c echo.Context) error {
+		callback(c.Response(), gothic.GetContextWithProvider(c.Request(), c.Param("provider")))
+		return nil
+	})
+	e.POST("/logout", echo.WrapHandler(http.HandlerFunc(logout)))
+	log.Fatal(e.Start(addr))
+	{{- else}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", index)
+	mux.HandleFunc("GET /auth/{provider}", func(res http.ResponseWriter, req *http.Request) {
+		begin(res, gothic.GetContextWithProvider(req, req.PathValue("provider")))
+	})
+	mux.HandleFunc("GET /auth/{provider}/callback", func(res http.ResponseWriter, req *http.Request) {
+		callback(res, gothic.GetContextWithProvider(req, req.PathValue("provider")))
+	})
+	mux.HandleFunc("POST /logout", logout)
+	log.Fatal(http.ListenAndServe(addr, mux))
+	{{- end}}
+}
+
+// callbackURL returns the callback of the provider, to register with it.
+func callbackURL(provider string) string {
+	return baseURL + "/auth/" + provider + "/callback"
+}
+
+func getenv(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// providers are the providers of the login page.
+var providers = []struct{ Name, Title string }{
+	{{- range .Providers}}
+	{"{{.Name}}", "{{.Title}}"},
+	{{- end}}
+}
+
+// index shows the signed in user, or the providers to sign in with.
+func index(res http.ResponseWriter, req *http.Request) {
+	session, _ := gothic.Store.Get(req, appSession)
+	page.Execute(res, map[string]interface{}{
+		"User":      session.Values["name"],
+		"Provider":  session.Values["provider"],
+		"Providers": providers,
+	})
+}
+
+// begin redirects the user to the provider.
+func begin(res http.ResponseWriter, req *http.Request) {
+	gothic.BeginAuthHandler(res, req)
+}
+
+// callback completes the authentication, and signs in the user.
+func callback(res http.ResponseWriter, req *http.Request) {
+	user, err := gothic.CompleteUserAuth(res, req)
+	if err != nil {
+		gothic.WriteError(res, req, http.StatusUnauthorized, err)
+		return
+	}
+	session, _ := gothic.Store.Get(req, appSession)
+	name := user.Name
+	if name == "" {
+		name = user.Email
+	}
+	if name == "" {
+		name = user.NickName
+	}
+	session.Values["name"] = name
+	session.Values["provider"] = user.Provider
+	session.Values["user_id"] = user.UserID
+	if err := session.Save(req, res); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(res, req, "/", http.StatusFound)
+}
+
+// logout signs out the user.
+func logout(res http.ResponseWriter, req *http.Request) {
+	session, _ := gothic.Store.Get(req, appSession)
+	session.Options.MaxAge = -1
+	_ = session.Save(req, res)
+	http.Redirect(res, req, "/", http.StatusFound)
+}
+
+var page = template.Must(template.New("page").Parse(` + "`" + `<!doctype html>
+<title>{{.Name}}</title>
+{{"{{"}}if .User{{"}}"}}
+<p>Signed in as {{"{{"}}.User{{"}}"}} with {{"{{"}}.Provider{{"}}"}}.</p>
+<form method="post" action="/logout"><button>Sign out</button></form>
+{{"{{"}}else{{"}}"}}
+{{"{{"}}range .Providers{{"}}"}}<p><a href="/auth/{{"{{"}}.Name{{"}}"}}">Sign in with {{"{{"}}.Title{{"}}"}}</a></p>
+{{"{{"}}end{{"}}"}}
+{{"{{"}}end{{"}}"}}
+` + "`" + `))
+`
+
+// goModTemplate is the go.mod of the application, completed by go mod tidy.
+const goModTemplate = `module {{.Module}}
+
+go 1.22
+`
+
+// readmeTemplate is the README.md of the application.
+const readmeTemplate = `# {{.Name}}
+
+Signs in users with [goth](https://github.com/andreimerlescu/goth), generated by goth-scaffold
+with the {{.Router}} router and the {{.Store}} session store.
+
+Register the callbacks with the providers, and set their clients:
+
+| Provider | Callback | Environment |
+| --- | --- | --- |
+{{- range $p := .Providers}}
+| {{$p.Title}} | http://{{$.Addr}}/auth/{{$p.Name}}/callback | {{$p.EnvPrefix}}_KEY, {{$p.EnvPrefix}}_SECRET{{range $p.Env}}, {{$p.EnvPrefix}}_{{.}}{{end}} |
+{{- end}}
+
+Then run the application:
+
+` + "```" + `text
+$ go mod tidy
+$ export SESSION_SECRET=$(openssl rand -hex 32)
+$ go run .
+` + "```" + `
+
+and open http://{{.Addr}}. Set BASE_URL to the public URL of the application, e.g.
+https://app.example.com, and ADDR to the address it listens on.
+`
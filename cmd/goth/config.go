@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// config is the configuration of a provider, read from a JSON file and
+// overridden by the flags, with the client from the GOTH_CLIENT_ID and
+// GOTH_CLIENT_SECRET environment variables by default.
+type config struct {
+	Provider     string   `json:"provider"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes,omitempty"`
+	// DiscoveryURL is the discovery document of openid-connect.
+	DiscoveryURL string `json:"discovery_url,omitempty"`
+	// Domain is the domain of auth0, or the organization URL of okta.
+	Domain string `json:"domain,omitempty"`
+	// CallbackURL is the callback of the loopback login, registered with the
+	// provider, e.g. http://127.0.0.1:8085/callback.
+	CallbackURL string `json:"callback_url,omitempty"`
+	// DeviceAuthURL and TokenURL are the endpoints of the device flow, known
+	// for some providers.
+	DeviceAuthURL string `json:"device_auth_url,omitempty"`
+	TokenURL      string `json:"token_url,omitempty"`
+}
+
+// register registers the flags of the configuration.
+func (c *config) register(fs *flag.FlagSet, file *string) {
+	fs.StringVar(file, "config", "", "the JSON `file` of the configuration")
+	fs.StringVar(&c.Provider, "provider", "", "the `name` of the provider, see goth providers")
+	fs.StringVar(&c.ClientID, "client-id", "", "the client ID, $GOTH_CLIENT_ID by default")
+	fs.StringVar(&c.ClientSecret, "client-secret", "", "the client secret, $GOTH_CLIENT_SECRET by default")
+	fs.Func("scopes", "the comma separated `scopes` to request", func(s string) error {
+		c.Scopes = strings.Split(s, ",")
+		return nil
+	})
+	fs.StringVar(&c.DiscoveryURL, "discovery-url", "", "the discovery `URL` of openid-connect")
+	fs.StringVar(&c.Domain, "domain", "", "the domain of auth0, or the organization URL of okta")
+	fs.StringVar(&c.CallbackURL, "callback", "", "the loopback callback `URL` registered with the provider (default http://127.0.0.1:8085/callback)")
+	fs.StringVar(&c.DeviceAuthURL, "device-auth-url", "", "the device authorization endpoint of the device flow")
+	fs.StringVar(&c.TokenURL, "token-url", "", "the token endpoint of the device flow")
+}
+
+// load reads the file of the configuration, when set, and overrides it with
+// the flags which were set.
+func (c *config) load(fs *flag.FlagSet, file string) error {
+	flags := *c
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		*c = config{}
+		if err := json.Unmarshal(b, c); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "provider":
+			c.Provider = flags.Provider
+		case "client-id":
+			c.ClientID = flags.ClientID
+		case "client-secret":
+			c.ClientSecret = flags.ClientSecret
+		case "scopes":
+			c.Scopes = flags.Scopes
+		case "discovery-url":
+			c.DiscoveryURL = flags.DiscoveryURL
+		case "domain":
+			c.Domain = flags.Domain
+		case "callback":
+			c.CallbackURL = flags.CallbackURL
+		case "device-auth-url":
+			c.DeviceAuthURL = flags.DeviceAuthURL
+		case "token-url":
+			c.TokenURL = flags.TokenURL
+		}
+	})
+	if c.ClientID == "" {
+		c.ClientID = os.Getenv("GOTH_CLIENT_ID")
+	}
+	if c.ClientSecret == "" {
+		c.ClientSecret = os.Getenv("GOTH_CLIENT_SECRET")
+	}
+	switch {
+	case c.Provider == "":
+		return fmt.Errorf("the provider is required, see goth providers")
+	case c.ClientID == "":
+		return fmt.Errorf("the client ID is required, set -client-id or $GOTH_CLIENT_ID")
+	}
+	return nil
+}
+
+// newProviders are the providers created with a client, a callback URL and
+// scopes, registered by the provider_*.go files of the providers compiled in.
+var newProviders = map[string]func(clientKey, secret, callbackURL string, scopes ...string) goth.Provider{}
+
+// configuredProviders are the providers requiring more of the configuration,
+// e.g. a domain, registered like newProviders.
+var configuredProviders = map[string]func(c *config, callbackURL string) (goth.Provider, error){}
+
+// deviceEndpoints are the endpoints of the device flow of the providers
+// supporting it.
+var deviceEndpoints = map[string][2]string{
+	"github":          {"https://github.com/login/device/code", "https://github.com/login/oauth/access_token"},
+	"google":          {"https://oauth2.googleapis.com/device/code", "https://oauth2.googleapis.com/token"},
+	"microsoftonline": {"https://login.microsoftonline.com/common/oauth2/v2.0/devicecode", "https://login.microsoftonline.com/common/oauth2/v2.0/token"},
+}
+
+// providerNames returns the names of the supported providers, sorted.
+func providerNames() []string {
+	var names []string
+	for name := range configuredProviders {
+		names = append(names, name)
+	}
+	for name := range newProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// provider creates the provider of the configuration, with the callback URL.
+func (c *config) provider(callbackURL string) (goth.Provider, error) {
+	if newProvider, ok := configuredProviders[c.Provider]; ok {
+		return newProvider(c, callbackURL)
+	}
+	newProvider, ok := newProviders[c.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unsupported provider %q, see goth providers", c.Provider)
+	}
+	return newProvider(c.ClientID, c.ClientSecret, callbackURL, c.Scopes...), nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// deviceLogin authenticates the user with the device flow of RFC 8628: it
+// prints the code the user enters at the verification URL, polls the token
+// endpoint until the user approves it, and fetches the user with the token.
+func deviceLogin(ctx context.Context, c *config, prompt io.Writer) (goth.User, error) {
+	client := goth.HTTPClientWithFallBack(nil)
+	authURL, tokenURL, err := c.deviceEndpoints(ctx, client)
+	if err != nil {
+		return goth.User{}, err
+	}
+	p, err := c.provider("")
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, client)
+	conf := &oauth2.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		Endpoint:     oauth2.Endpoint{DeviceAuthURL: authURL, TokenURL: tokenURL},
+		Scopes:       c.Scopes,
+	}
+	da, err := conf.DeviceAuth(ctx)
+	if err != nil {
+		return goth.User{}, fmt.Errorf("requesting the device code: %w", err)
+	}
+	fmt.Fprintf(prompt, "Enter the code %s at %s to authenticate.\n", da.UserCode, da.VerificationURI)
+	if da.VerificationURIComplete != "" {
+		fmt.Fprintf(prompt, "Or open %s\n", da.VerificationURIComplete)
+	}
+	token, err := conf.DeviceAccessToken(ctx, da)
+	if err != nil {
+		return goth.User{}, fmt.Errorf("waiting for the approval: %w", err)
+	}
+
+	// The session of the provider is restored from the token, with the field
+	// names common to the sessions of the providers.
+	idToken, _ := token.Extra("id_token").(string)
+	data, err := json.Marshal(struct {
+		AccessToken  string
+		RefreshToken string
+		ExpiresAt    time.Time
+		IDToken      string `json:",omitempty"`
+	}{token.AccessToken, token.RefreshToken, token.Expiry, idToken})
+	if err != nil {
+		return goth.User{}, err
+	}
+	sess, err := p.UnmarshalSession(string(data))
+	if err != nil {
+		return goth.User{}, err
+	}
+	user, err := p.FetchUser(sess)
+	if err != nil {
+		return goth.User{}, fmt.Errorf("fetching the user: %w", err)
+	}
+	return user, nil
+}
+
+// deviceEndpoints returns the device authorization and token endpoints of the
+// configuration, of the provider, or of the discovery document of
+// openid-connect.
+func (c *config) deviceEndpoints(ctx context.Context, client *http.Client) (authURL, tokenURL string, err error) {
+	authURL, tokenURL = c.DeviceAuthURL, c.TokenURL
+	if endpoints, ok := deviceEndpoints[c.Provider]; ok {
+		if authURL == "" {
+			authURL = endpoints[0]
+		}
+		if tokenURL == "" {
+			tokenURL = endpoints[1]
+		}
+	}
+	if (authURL == "" || tokenURL == "") && c.DiscoveryURL != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.DiscoveryURL, nil)
+		if err != nil {
+			return "", "", err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", "", fmt.Errorf("%s: unexpected status %s", c.DiscoveryURL, resp.Status)
+		}
+		var discovery struct {
+			DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+			TokenEndpoint               string `json:"token_endpoint"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+			return "", "", fmt.Errorf("%s: %w", c.DiscoveryURL, err)
+		}
+		if authURL == "" {
+			authURL = discovery.DeviceAuthorizationEndpoint
+		}
+		if tokenURL == "" {
+			tokenURL = discovery.TokenEndpoint
+		}
+	}
+	if authURL == "" || tokenURL == "" {
+		return "", "", fmt.Errorf("the device flow of %s requires -device-auth-url and -token-url", c.Provider)
+	}
+	return authURL, tokenURL, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// defaultCallbackURL is the callback of the loopback login, which must be
+// registered with the provider.
+const defaultCallbackURL = "http://127.0.0.1:8085/callback"
+
+// login authenticates the user through a loopback redirect: it serves the
+// callback on the loopback interface, begins the authentication, passes its
+// URL to open, and completes the authentication when the provider redirects
+// the browser to the callback.
+func login(ctx context.Context, c *config, open func(authURL string) error) (goth.User, error) {
+	callbackURL := c.CallbackURL
+	if callbackURL == "" {
+		callbackURL = defaultCallbackURL
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return goth.User{}, err
+	}
+	if u.Scheme != "http" || !isLoopback(u.Hostname()) {
+		return goth.User{}, fmt.Errorf("the callback %s of the login must be an http URL of the loopback interface, e.g. %s", callbackURL, defaultCallbackURL)
+	}
+	ln, err := net.Listen("tcp", u.Host)
+	if err != nil {
+		return goth.User{}, err
+	}
+	defer ln.Close()
+	// The port is chosen by the system when it is 0.
+	u.Host = net.JoinHostPort(u.Hostname(), fmt.Sprint(ln.Addr().(*net.TCPAddr).Port))
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	p, err := c.provider(u.String())
+	if err != nil {
+		return goth.User{}, err
+	}
+	b, err := goth.RandomBytes(32)
+	if err != nil {
+		return goth.User{}, err
+	}
+	state := base64.RawURLEncoding.EncodeToString(b)
+	sess, err := p.BeginAuth(state)
+	if err != nil {
+		return goth.User{}, err
+	}
+	authURL, err := sess.GetAuthURL()
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	type outcome struct {
+		user goth.User
+		err  error
+	}
+	done := make(chan outcome, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc(u.Path, func(res http.ResponseWriter, req *http.Request) {
+		user, err := complete(p, sess, state, req.URL.Query())
+		if err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(res, "<p>The authentication failed: %s</p>", html.EscapeString(err.Error()))
+		} else {
+			fmt.Fprint(res, "<p>You are authenticated, you may close this window.</p>")
+		}
+		select {
+		case done <- outcome{user, err}:
+		default:
+		}
+	})
+	srv := &http.Server{Handler: mux}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Close()
+
+	if err := open(authURL); err != nil {
+		return goth.User{}, err
+	}
+	select {
+	case o := <-done:
+		return o.user, o.err
+	case <-ctx.Done():
+		return goth.User{}, fmt.Errorf("waiting for the callback: %w", ctx.Err())
+	}
+}
+
+// complete completes the authentication with the parameters of the callback.
+func complete(p goth.Provider, sess goth.Session, state string, params url.Values) (goth.User, error) {
+	if e := params.Get("error"); e != "" {
+		if description := params.Get("error_description"); description != "" {
+			e += ": " + description
+		}
+		return goth.User{}, fmt.Errorf("the provider returned an error: %s", e)
+	}
+	if params.Get("state") != state {
+		return goth.User{}, errors.New("the state of the callback does not match")
+	}
+	if _, err := sess.Authorize(p, params); err != nil {
+		return goth.User{}, fmt.Errorf("authorizing: %w", err)
+	}
+	user, err := p.FetchUser(sess)
+	if err != nil {
+		return goth.User{}, fmt.Errorf("fetching the user: %w", err)
+	}
+	return user, nil
+}
+
+// isLoopback reports whether the host is the loopback interface.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// openBrowser opens the URL in the browser of the user.
+func openBrowser(u string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", u).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", u).Start()
+	default:
+		return exec.Command("xdg-open", u).Start()
+	}
+}
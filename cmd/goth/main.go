@@ -0,0 +1,187 @@
+// Command goth tests the configuration of a provider, without an application:
+// it authenticates with the provider through a loopback redirect or the
+// device flow, and prints the normalized user and the metadata of its token.
+// It also validates the callback URLs registered with the providers.
+//
+//	goth login -provider github -client-id ID -client-secret SECRET
+//	goth device -provider google -config google.json
+//	goth validate-callback https://example.com/auth/github/callback
+//	goth providers
+//
+// The client ID and secret default to the GOTH_CLIENT_ID and
+// GOTH_CLIENT_SECRET environment variables, which keeps the secret out of the
+// history of the shell.
+//
+// Every provider is compiled in by default. Build with the goth_providers tag
+// and the tags of the providers, e.g. goth_github and goth_openidconnect, to
+// only compile these:
+//
+//	go build -tags goth_providers,goth_github,goth_google ./cmd/goth
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+const usage = `Usage: goth <command> [flags]
+
+Commands:
+  login              authenticate through a loopback redirect, and print the user
+  device             authenticate with the device flow, and print the user
+  validate-callback  validate callback URLs
+  providers          list the supported providers
+
+Run goth <command> -h for the flags of a command.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run runs the command of the arguments, and returns the exit code.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	var err error
+	switch args[0] {
+	case "login", "device":
+		err = authenticate(ctx, args[0], args[1:], stdout, stderr)
+	case "validate-callback":
+		err = validateCallbacks(args[1:], stdout, stderr)
+	case "providers":
+		fmt.Fprintln(stdout, strings.Join(providerNames(), "\n"))
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+	default:
+		fmt.Fprintf(stderr, "goth: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	case err != nil:
+		fmt.Fprintf(stderr, "goth: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// errUsage is returned for the invalid flags, already reported by the flag
+// set.
+var errUsage = errors.New("usage")
+
+// authenticate runs the login or device command.
+func authenticate(ctx context.Context, command string, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("goth "+command, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var c config
+	var file string
+	c.register(fs, &file)
+	showTokens := fs.Bool("show-tokens", false, "print the tokens, which are redacted by default")
+	timeout := fs.Duration("timeout", 5*time.Minute, "the time to wait for the authentication")
+	noBrowser := fs.Bool("no-browser", false, "do not open the browser, only print the URL (login)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "goth %s: unexpected arguments %q\n", command, fs.Args())
+		return errUsage
+	}
+	if err := c.load(fs, file); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	var user goth.User
+	var err error
+	if command == "device" {
+		user, err = deviceLogin(ctx, &c, stderr)
+	} else {
+		open := func(authURL string) error {
+			fmt.Fprintf(stderr, "Open the following URL in your browser to authenticate:\n\n  %s\n\n", authURL)
+			if !*noBrowser {
+				_ = openBrowser(authURL)
+			}
+			return nil
+		}
+		user, err = login(ctx, &c, open)
+	}
+	if err != nil {
+		return err
+	}
+	return printResult(stdout, user, *showTokens)
+}
+
+// result is the output of the login and device commands.
+type result struct {
+	User  json.RawMessage `json:"user"`
+	Token tokenInfo       `json:"token"`
+}
+
+// tokenInfo is the metadata of the token of the user.
+type tokenInfo struct {
+	AccessToken     bool       `json:"access_token"`
+	RefreshToken    bool       `json:"refresh_token"`
+	IDToken         bool       `json:"id_token"`
+	AccessTokenType string     `json:"access_token_type,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	ExpiresIn       string     `json:"expires_in,omitempty"`
+}
+
+// printResult prints the normalized user, with its tokens redacted unless
+// showTokens is set, and the metadata of its token.
+func printResult(w io.Writer, user goth.User, showTokens bool) error {
+	b, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	data := string(b)
+	if !showTokens {
+		data = goth.RedactJSON(data)
+	}
+	out := result{
+		User: json.RawMessage(data),
+		Token: tokenInfo{
+			AccessToken:  user.AccessToken != "",
+			RefreshToken: user.RefreshToken != "",
+			IDToken:      user.IDToken != "",
+		},
+	}
+	if strings.Count(user.AccessToken, ".") == 2 {
+		out.Token.AccessTokenType = "jwt"
+	} else if user.AccessToken != "" {
+		out.Token.AccessTokenType = "opaque"
+	}
+	if !user.ExpiresAt.IsZero() {
+		out.Token.ExpiresAt = &user.ExpiresAt
+		out.Token.ExpiresIn = user.ExpiresAt.Sub(goth.Now()).Round(time.Second).String()
+	}
+	b, err = json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_amazon
+// +build !goth_providers goth_amazon
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/amazon"
+)
+
+func init() {
+	newProviders["amazon"] = func(k, s, c string, sc ...string) goth.Provider { return amazon.New(k, s, c, sc...) }
+}
@@ -0,0 +1,20 @@
+//go:build !goth_providers || goth_auth0
+// +build !goth_providers goth_auth0
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/auth0"
+)
+
+func init() {
+	configuredProviders["auth0"] = func(c *config, callbackURL string) (goth.Provider, error) {
+		if c.Domain == "" {
+			return nil, fmt.Errorf("auth0 requires -domain")
+		}
+		return auth0.New(c.ClientID, c.ClientSecret, callbackURL, c.Domain, c.Scopes...), nil
+	}
+}
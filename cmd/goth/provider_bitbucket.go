@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_bitbucket
+// +build !goth_providers goth_bitbucket
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/bitbucket"
+)
+
+func init() {
+	newProviders["bitbucket"] = func(k, s, c string, sc ...string) goth.Provider { return bitbucket.New(k, s, c, sc...) }
+}
@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_box
+// +build !goth_providers goth_box
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/box"
+)
+
+func init() {
+	newProviders["box"] = func(k, s, c string, sc ...string) goth.Provider { return box.New(k, s, c, sc...) }
+}
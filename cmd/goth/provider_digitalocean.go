@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_digitalocean
+// +build !goth_providers goth_digitalocean
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/digitalocean"
+)
+
+func init() {
+	newProviders["digitalocean"] = func(k, s, c string, sc ...string) goth.Provider { return digitalocean.New(k, s, c, sc...) }
+}
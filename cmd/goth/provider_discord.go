@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_discord
+// +build !goth_providers goth_discord
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/discord"
+)
+
+func init() {
+	newProviders["discord"] = func(k, s, c string, sc ...string) goth.Provider { return discord.New(k, s, c, sc...) }
+}
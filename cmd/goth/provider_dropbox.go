@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_dropbox
+// +build !goth_providers goth_dropbox
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/dropbox"
+)
+
+func init() {
+	newProviders["dropbox"] = func(k, s, c string, sc ...string) goth.Provider { return dropbox.New(k, s, c, sc...) }
+}
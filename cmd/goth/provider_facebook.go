@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_facebook
+// +build !goth_providers goth_facebook
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/facebook"
+)
+
+func init() {
+	newProviders["facebook"] = func(k, s, c string, sc ...string) goth.Provider { return facebook.New(k, s, c, sc...) }
+}
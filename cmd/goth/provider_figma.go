@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_figma
+// +build !goth_providers goth_figma
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/figma"
+)
+
+func init() {
+	newProviders["figma"] = func(k, s, c string, sc ...string) goth.Provider { return figma.New(k, s, c, sc...) }
+}
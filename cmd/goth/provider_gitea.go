@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_gitea
+// +build !goth_providers goth_gitea
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/gitea"
+)
+
+func init() {
+	newProviders["gitea"] = func(k, s, c string, sc ...string) goth.Provider { return gitea.New(k, s, c, sc...) }
+}
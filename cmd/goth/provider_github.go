@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_github
+// +build !goth_providers goth_github
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/github"
+)
+
+func init() {
+	newProviders["github"] = func(k, s, c string, sc ...string) goth.Provider { return github.New(k, s, c, sc...) }
+}
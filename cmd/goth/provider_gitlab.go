@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_gitlab
+// +build !goth_providers goth_gitlab
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/gitlab"
+)
+
+func init() {
+	newProviders["gitlab"] = func(k, s, c string, sc ...string) goth.Provider { return gitlab.New(k, s, c, sc...) }
+}
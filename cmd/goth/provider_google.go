@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_google
+// +build !goth_providers goth_google
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/google"
+)
+
+func init() {
+	newProviders["google"] = func(k, s, c string, sc ...string) goth.Provider { return google.New(k, s, c, sc...) }
+}
@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_heroku
+// +build !goth_providers goth_heroku
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/heroku"
+)
+
+func init() {
+	newProviders["heroku"] = func(k, s, c string, sc ...string) goth.Provider { return heroku.New(k, s, c, sc...) }
+}
@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_linkedin
+// +build !goth_providers goth_linkedin
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/linkedin"
+)
+
+func init() {
+	newProviders["linkedin"] = func(k, s, c string, sc ...string) goth.Provider { return linkedin.New(k, s, c, sc...) }
+}
@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_microsoftonline
+// +build !goth_providers goth_microsoftonline
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/microsoftonline"
+)
+
+func init() {
+	newProviders["microsoftonline"] = func(k, s, c string, sc ...string) goth.Provider { return microsoftonline.New(k, s, c, sc...) }
+}
@@ -0,0 +1,20 @@
+//go:build !goth_providers || goth_okta
+// +build !goth_providers goth_okta
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/okta"
+)
+
+func init() {
+	configuredProviders["okta"] = func(c *config, callbackURL string) (goth.Provider, error) {
+		if c.Domain == "" {
+			return nil, fmt.Errorf("okta requires -domain, the URL of the organization")
+		}
+		return okta.New(c.ClientID, c.ClientSecret, c.Domain, callbackURL, c.Scopes...), nil
+	}
+}
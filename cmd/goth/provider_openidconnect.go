@@ -0,0 +1,20 @@
+//go:build !goth_providers || goth_openidconnect
+// +build !goth_providers goth_openidconnect
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/openidConnect"
+)
+
+func init() {
+	configuredProviders["openid-connect"] = func(c *config, callbackURL string) (goth.Provider, error) {
+		if c.DiscoveryURL == "" {
+			return nil, fmt.Errorf("openid-connect requires -discovery-url")
+		}
+		return openidConnect.New(c.ClientID, c.ClientSecret, callbackURL, c.DiscoveryURL, c.Scopes...)
+	}
+}
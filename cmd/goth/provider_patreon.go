@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_patreon
+// +build !goth_providers goth_patreon
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/patreon"
+)
+
+func init() {
+	newProviders["patreon"] = func(k, s, c string, sc ...string) goth.Provider { return patreon.New(k, s, c, sc...) }
+}
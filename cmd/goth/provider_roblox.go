@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_roblox
+// +build !goth_providers goth_roblox
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/roblox"
+)
+
+func init() {
+	newProviders["roblox"] = func(k, s, c string, sc ...string) goth.Provider { return roblox.New(k, s, c, sc...) }
+}
@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_salesforce
+// +build !goth_providers goth_salesforce
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/salesforce"
+)
+
+func init() {
+	newProviders["salesforce"] = func(k, s, c string, sc ...string) goth.Provider { return salesforce.New(k, s, c, sc...) }
+}
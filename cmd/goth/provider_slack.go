@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_slack
+// +build !goth_providers goth_slack
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/slack"
+)
+
+func init() {
+	newProviders["slack"] = func(k, s, c string, sc ...string) goth.Provider { return slack.New(k, s, c, sc...) }
+}
@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_spotify
+// +build !goth_providers goth_spotify
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/spotify"
+)
+
+func init() {
+	newProviders["spotify"] = func(k, s, c string, sc ...string) goth.Provider { return spotify.New(k, s, c, sc...) }
+}
@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_strava
+// +build !goth_providers goth_strava
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/strava"
+)
+
+func init() {
+	newProviders["strava"] = func(k, s, c string, sc ...string) goth.Provider { return strava.New(k, s, c, sc...) }
+}
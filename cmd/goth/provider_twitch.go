@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_twitch
+// +build !goth_providers goth_twitch
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/twitch"
+)
+
+func init() {
+	newProviders["twitch"] = func(k, s, c string, sc ...string) goth.Provider { return twitch.New(k, s, c, sc...) }
+}
@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_yahoo
+// +build !goth_providers goth_yahoo
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/yahoo"
+)
+
+func init() {
+	newProviders["yahoo"] = func(k, s, c string, sc ...string) goth.Provider { return yahoo.New(k, s, c, sc...) }
+}
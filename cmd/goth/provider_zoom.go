@@ -0,0 +1,13 @@
+//go:build !goth_providers || goth_zoom
+// +build !goth_providers goth_zoom
+
+package main
+
+import (
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/zoom"
+)
+
+func init() {
+	newProviders["zoom"] = func(k, s, c string, sc ...string) goth.Provider { return zoom.New(k, s, c, sc...) }
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// problem is a problem of a callback URL, an error or a warning.
+type problem struct {
+	warning bool
+	message string
+}
+
+func (p problem) String() string {
+	if p.warning {
+		return "warning: " + p.message
+	}
+	return "error: " + p.message
+}
+
+// validateCallback returns the problems of the callback URL registered with a
+// provider.
+func validateCallback(raw string) []problem {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return []problem{{message: err.Error()}}
+	}
+	if !u.IsAbs() || u.Host == "" {
+		if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+			return []problem{{warning: true, message: fmt.Sprintf("the custom scheme %s is only valid for native applications", u.Scheme)}}
+		}
+		return []problem{{message: "the callback must be an absolute URL, with a scheme and a host"}}
+	}
+
+	var problems []problem
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if !isLoopback(u.Hostname()) {
+			problems = append(problems, problem{message: "the callback must use https, except on the loopback interface"})
+		}
+	default:
+		problems = append(problems, problem{warning: true, message: fmt.Sprintf("the custom scheme %s is only valid for native applications", u.Scheme)})
+	}
+	if u.User != nil {
+		problems = append(problems, problem{message: "the callback must not have credentials"})
+	}
+	if strings.Contains(u.Host, "*") {
+		problems = append(problems, problem{message: "the callback must not have a wildcard"})
+	}
+	if u.Fragment != "" || strings.Contains(raw, "#") {
+		problems = append(problems, problem{message: "the callback must not have a fragment"})
+	}
+	query := u.Query()
+	for _, name := range []string{"state", "code", "error", "id_token", "access_token"} {
+		if query.Has(name) {
+			problems = append(problems, problem{message: fmt.Sprintf("the query of the callback must not have the %s parameter, which is set by the provider", name)})
+		}
+	}
+	if u.Hostname() == "localhost" {
+		problems = append(problems, problem{warning: true, message: "prefer 127.0.0.1 to localhost, which may not resolve to the loopback interface"})
+	}
+	if u.Path == "" || u.Path == "/" {
+		problems = append(problems, problem{warning: true, message: "the callback should have a path of its own, e.g. /auth/github/callback"})
+	}
+	return problems
+}
+
+// errInvalidCallback is returned when a callback URL has errors.
+var errInvalidCallback = errors.New("invalid callback URL")
+
+// validateCallbacks runs the validate-callback command, which prints the
+// problems of the callback URLs, and fails when one of them has an error.
+func validateCallbacks(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("goth validate-callback", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: goth validate-callback URL...")
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	var failed bool
+	for _, raw := range fs.Args() {
+		problems := validateCallback(raw)
+		if len(problems) == 0 {
+			fmt.Fprintf(stdout, "%s: ok\n", raw)
+			continue
+		}
+		for _, p := range problems {
+			fmt.Fprintf(stdout, "%s: %s\n", raw, p)
+			failed = failed || !p.warning
+		}
+	}
+	if failed {
+		return errInvalidCallback
+	}
+	return nil
+}
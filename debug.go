@@ -0,0 +1,304 @@
+package goth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exchange is a request of a provider and its response, recorded by a
+// DebugCapture with the secrets and the tokens redacted.
+type Exchange struct {
+	Time           time.Time     `json:"time"`
+	Duration       time.Duration `json:"duration"`
+	Method         string        `json:"method"`
+	URL            string        `json:"url"`
+	RequestHeader  http.Header   `json:"request_header"`
+	RequestBody    string        `json:"request_body,omitempty"`
+	Status         int           `json:"status,omitempty"`
+	ResponseHeader http.Header   `json:"response_header,omitempty"`
+	ResponseBody   string        `json:"response_body,omitempty"`
+	Error          string        `json:"error,omitempty"`
+}
+
+// DebugCapture is a transport recording the requests of the providers and
+// their responses, e.g. the token exchanges and the userinfo requests, to
+// diagnose the failures of the providers. The secrets and tokens of the
+// headers, URLs and form or JSON bodies are redacted.
+type DebugCapture struct {
+	// Transport sends the requests, DefaultTransport when it is nil.
+	Transport http.RoundTripper
+	// Writer receives the exchanges as they complete, when set.
+	Writer io.Writer
+	// Max is the number of exchanges kept, 100 when it is zero.
+	Max int
+
+	mu        sync.Mutex
+	exchanges []Exchange
+}
+
+// maxCapturedBody is the size of the bodies kept in the exchanges.
+const maxCapturedBody = 64 << 10
+
+// EnableDebugCapture records the requests of the providers without an HTTP
+// client of their own, by replacing DefaultClient with a client sending its
+// requests through the returned capture. The exchanges are written to w,
+// when set. Only enable it while diagnosing a provider.
+func EnableDebugCapture(w io.Writer) *DebugCapture {
+	fmt.Fprintln(os.Stderr, "goth: debug capture is enabled, the requests of the providers are recorded. Never enable it in production.")
+	client := http.Client{}
+	if DefaultClient != nil {
+		client = *DefaultClient
+	}
+	capture := &DebugCapture{Transport: client.Transport, Writer: w}
+	client.Transport = capture
+	DefaultClient = &client
+	return capture
+}
+
+// RoundTrip sends the request with the Transport, and records it with its
+// response.
+func (c *DebugCapture) RoundTrip(req *http.Request) (*http.Response, error) {
+	e := Exchange{
+		Time:          time.Now(),
+		Method:        req.Method,
+		URL:           redactURL(req.URL),
+		RequestHeader: redactHeader(req.Header),
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		e.RequestBody = redactBody(req.Header.Get("Content-Type"), body)
+	}
+
+	transport := c.Transport
+	if transport == nil {
+		transport = DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	e.Duration = time.Since(e.Time)
+	if err != nil {
+		e.Error = err.Error()
+		c.record(e)
+		return nil, err
+	}
+
+	e.Status = resp.StatusCode
+	e.ResponseHeader = redactHeader(resp.Header)
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		e.Error = err.Error()
+	}
+	e.ResponseBody = redactBody(resp.Header.Get("Content-Type"), body)
+	c.record(e)
+	return resp, err
+}
+
+func (c *DebugCapture) record(e Exchange) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	max := c.Max
+	if max <= 0 {
+		max = 100
+	}
+	c.exchanges = append(c.exchanges, e)
+	if len(c.exchanges) > max {
+		c.exchanges = append([]Exchange(nil), c.exchanges[len(c.exchanges)-max:]...)
+	}
+	if c.Writer != nil {
+		_, _ = io.WriteString(c.Writer, e.String())
+	}
+}
+
+// Exchanges returns the exchanges recorded, the oldest first.
+func (c *DebugCapture) Exchanges() []Exchange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Exchange(nil), c.exchanges...)
+}
+
+// Reset forgets the exchanges recorded.
+func (c *DebugCapture) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.exchanges = nil
+}
+
+// ServeHTTP serves the exchanges recorded as JSON. Only serve it to the
+// administrators of the application.
+func (c *DebugCapture) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(res).Encode(c.Exchanges())
+}
+
+// String returns the exchange in the format of an HTTP dump.
+func (e Exchange) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--> %s %s\n", e.Method, e.URL)
+	writeHeader(&b, e.RequestHeader)
+	if e.RequestBody != "" {
+		fmt.Fprintf(&b, "\n%s\n", e.RequestBody)
+	}
+	if e.Error != "" && e.Status == 0 {
+		fmt.Fprintf(&b, "<-- error: %s (%s)\n\n", e.Error, e.Duration)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "<-- %d %s (%s)\n", e.Status, http.StatusText(e.Status), e.Duration)
+	writeHeader(&b, e.ResponseHeader)
+	if e.ResponseBody != "" {
+		fmt.Fprintf(&b, "\n%s\n", e.ResponseBody)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func writeHeader(w io.Writer, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			fmt.Fprintf(w, "%s: %s\n", name, value)
+		}
+	}
+}
+
+const redacted = "REDACTED"
+
+// Sensitive reports whether the name of a header, a parameter or a JSON field
+// holds a secret or a token, e.g. access_token or Authorization, which is
+// redacted by the debug capture, RedactJSON and RedactBody.
+func Sensitive(name string) bool {
+	name = snakeCase(name)
+	for _, suffix := range []string{"_type", "_uri", "_url", "_endpoint", "_supported", "_in", "_at", "_expires", "_expiry"} {
+		if strings.HasSuffix(name, suffix) {
+			return false
+		}
+	}
+	switch name {
+	case "authorization", "proxy_authorization", "cookie", "set_cookie",
+		"code", "code_verifier", "device_code", "user_code", "key", "samlresponse":
+		return true
+	}
+	for _, word := range []string{"token", "secret", "password", "assertion", "signature", "api_key", "apikey"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// snakeCase returns the lower snake case of a header, parameter or field
+// name, e.g. refresh_token_expires_at for RefreshTokenExpiresAt.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '-':
+			r = '_'
+		case r >= 'A' && r <= 'Z':
+			if i > 0 && name[i-1] >= 'a' && name[i-1] <= 'z' {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func redactHeader(header http.Header) http.Header {
+	out := make(http.Header, len(header))
+	for name, values := range header {
+		if Sensitive(name) {
+			out[name] = []string{redacted}
+		} else {
+			out[name] = append([]string(nil), values...)
+		}
+	}
+	return out
+}
+
+func redactURL(u *url.URL) string {
+	r := *u
+	r.User = nil
+	if r.RawQuery != "" {
+		r.RawQuery = redactValues(r.Query()).Encode()
+	}
+	return r.String()
+}
+
+func redactValues(values url.Values) url.Values {
+	for name := range values {
+		if Sensitive(name) {
+			values[name] = []string{redacted}
+		}
+	}
+	return values
+}
+
+// redactBody redacts the JSON and form bodies, and truncates the others.
+func redactBody(contentType string, body []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	trimmed := bytes.TrimSpace(body)
+	if strings.HasSuffix(mediaType, "json") || bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("[")) {
+		var v interface{}
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+		if d.Decode(&v) == nil {
+			b, err := json.Marshal(redactJSON(v))
+			if err == nil {
+				return truncate(string(b))
+			}
+		}
+	}
+	if mediaType == "application/x-www-form-urlencoded" || mediaType == "text/plain" || mediaType == "" {
+		if values, err := url.ParseQuery(string(trimmed)); err == nil && len(values) > 0 && bytes.Contains(trimmed, []byte("=")) {
+			return truncate(redactValues(values).Encode())
+		}
+	}
+	return truncate(string(body))
+}
+
+func redactJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if Sensitive(key) && value != nil && value != "" {
+				v[key] = redacted
+			} else {
+				v[key] = redactJSON(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redactJSON(value)
+		}
+	}
+	return v
+}
+
+func truncate(s string) string {
+	if len(s) > maxCapturedBody {
+		return s[:maxCapturedBody] + "... (truncated)"
+	}
+	return s
+}
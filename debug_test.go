@@ -0,0 +1,86 @@
+package goth_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func Test_EnableDebugCapture(t *testing.T) {
+	a := assert.New(t)
+	defer func(client *http.Client) { goth.DefaultClient = client }(goth.DefaultClient)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/token":
+			res.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(res, `{"access_token":"at-secret","token_type":"Bearer","expires_in":3600,"id_token":"eyJ.secret","scope":"openid"}`)
+		default:
+			res.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			res.WriteHeader(http.StatusUnauthorized)
+			_, _ = io.WriteString(res, `{"error":"invalid_token","nested":[{"refresh_token":"rt-secret"}]}`)
+		}
+	}))
+	defer ts.Close()
+
+	var dump bytes.Buffer
+	capture := goth.EnableDebugCapture(&dump)
+
+	config := &oauth2.Config{
+		ClientID:     "client",
+		ClientSecret: "client-secret",
+		Endpoint:     oauth2.Endpoint{TokenURL: ts.URL + "/token", AuthStyle: oauth2.AuthStyleInParams},
+	}
+	token, err := config.Exchange(goth.ContextForClient(goth.HTTPClientWithFallBack(nil)), "code-secret")
+	a.NoError(err)
+	// the responses are still read by the providers
+	a.Equal("at-secret", token.AccessToken)
+
+	req, _ := http.NewRequest("GET", ts.URL+"/userinfo?access_token=at-secret&fields=email", nil)
+	req.Header.Set("Authorization", "Bearer at-secret")
+	resp, err := goth.HTTPClientWithFallBack(nil).Do(req)
+	a.NoError(err)
+	b, _ := io.ReadAll(resp.Body)
+	a.Contains(string(b), "rt-secret")
+
+	exchanges := capture.Exchanges()
+	a.Len(exchanges, 2)
+	a.Equal("POST", exchanges[0].Method)
+	form, _ := url.ParseQuery(exchanges[0].RequestBody)
+	a.Equal("client", form.Get("client_id"))
+	a.Equal("REDACTED", form.Get("client_secret"))
+	a.Equal("REDACTED", form.Get("code"))
+	a.Equal("authorization_code", form.Get("grant_type"))
+	a.Equal(200, exchanges[0].Status)
+	a.JSONEq(`{"access_token":"REDACTED","token_type":"Bearer","expires_in":3600,"id_token":"REDACTED","scope":"openid"}`, exchanges[0].ResponseBody)
+
+	a.Equal(ts.URL+"/userinfo?access_token=REDACTED&fields=email", exchanges[1].URL)
+	a.Equal("REDACTED", exchanges[1].RequestHeader.Get("Authorization"))
+	a.Equal(401, exchanges[1].Status)
+	a.JSONEq(`{"error":"invalid_token","nested":[{"refresh_token":"REDACTED"}]}`, exchanges[1].ResponseBody)
+
+	for _, secret := range []string{"at-secret", "rt-secret", "eyJ.secret", "code-secret", "client-secret"} {
+		a.NotContains(dump.String(), secret)
+	}
+	a.Contains(dump.String(), "--> GET "+ts.URL+"/userinfo")
+	a.Contains(dump.String(), "<-- 401 Unauthorized")
+
+	res := httptest.NewRecorder()
+	capture.ServeHTTP(res, httptest.NewRequest("GET", "/debug/goth", nil))
+	var served []goth.Exchange
+	a.NoError(json.NewDecoder(res.Body).Decode(&served))
+	a.Len(served, 2)
+	a.False(strings.Contains(res.Body.String(), "at-secret"))
+
+	capture.Reset()
+	a.Empty(capture.Exchanges())
+}
@@ -12,18 +12,24 @@ import (
 	"github.com/andreimerlescu/goth/gothic"
 	"github.com/andreimerlescu/goth/providers/amazon"
 	"github.com/andreimerlescu/goth/providers/apple"
+	"github.com/andreimerlescu/goth/providers/atlassian"
 	"github.com/andreimerlescu/goth/providers/auth0"
+	"github.com/andreimerlescu/goth/providers/authentik"
 	"github.com/andreimerlescu/goth/providers/azuread"
 	"github.com/andreimerlescu/goth/providers/battlenet"
 	"github.com/andreimerlescu/goth/providers/bitbucket"
 	"github.com/andreimerlescu/goth/providers/box"
 	"github.com/andreimerlescu/goth/providers/dailymotion"
 	"github.com/andreimerlescu/goth/providers/deezer"
+	"github.com/andreimerlescu/goth/providers/dex"
 	"github.com/andreimerlescu/goth/providers/digitalocean"
 	"github.com/andreimerlescu/goth/providers/discord"
 	"github.com/andreimerlescu/goth/providers/dropbox"
+	"github.com/andreimerlescu/goth/providers/ebay"
+	"github.com/andreimerlescu/goth/providers/etsy"
 	"github.com/andreimerlescu/goth/providers/eveonline"
 	"github.com/andreimerlescu/goth/providers/facebook"
+	"github.com/andreimerlescu/goth/providers/figma"
 	"github.com/andreimerlescu/goth/providers/fitbit"
 	"github.com/andreimerlescu/goth/providers/gitea"
 	"github.com/andreimerlescu/goth/providers/github"
@@ -33,6 +39,7 @@ import (
 	"github.com/andreimerlescu/goth/providers/heroku"
 	"github.com/andreimerlescu/goth/providers/instagram"
 	"github.com/andreimerlescu/goth/providers/intercom"
+	"github.com/andreimerlescu/goth/providers/intuit"
 	"github.com/andreimerlescu/goth/providers/kakao"
 	"github.com/andreimerlescu/goth/providers/lastfm"
 	"github.com/andreimerlescu/goth/providers/line"
@@ -42,11 +49,14 @@ import (
 	"github.com/andreimerlescu/goth/providers/microsoftonline"
 	"github.com/andreimerlescu/goth/providers/naver"
 	"github.com/andreimerlescu/goth/providers/nextcloud"
+	"github.com/andreimerlescu/goth/providers/notion"
 	"github.com/andreimerlescu/goth/providers/okta"
 	"github.com/andreimerlescu/goth/providers/onedrive"
 	"github.com/andreimerlescu/goth/providers/openidConnect"
 	"github.com/andreimerlescu/goth/providers/patreon"
 	"github.com/andreimerlescu/goth/providers/paypal"
+	"github.com/andreimerlescu/goth/providers/plex"
+	"github.com/andreimerlescu/goth/providers/roblox"
 	"github.com/andreimerlescu/goth/providers/salesforce"
 	"github.com/andreimerlescu/goth/providers/seatalk"
 	"github.com/andreimerlescu/goth/providers/shopify"
@@ -65,7 +75,9 @@ import (
 	"github.com/andreimerlescu/goth/providers/vk"
 	"github.com/andreimerlescu/goth/providers/wecom"
 	"github.com/andreimerlescu/goth/providers/wepay"
+	"github.com/andreimerlescu/goth/providers/wordpress"
 	"github.com/andreimerlescu/goth/providers/xero"
+	"github.com/andreimerlescu/goth/providers/xerov2"
 	"github.com/andreimerlescu/goth/providers/yahoo"
 	"github.com/andreimerlescu/goth/providers/yammer"
 	"github.com/andreimerlescu/goth/providers/yandex"
@@ -75,11 +87,8 @@ import (
 
 func main() {
 	goth.UseProviders(
-		// Use twitterv2 instead of twitter if you only have access to the Essential API Level
-		// the twitter provider uses a v1.1 API that is not available to the Essential Level
-		twitterv2.New(os.Getenv("TWITTER_KEY"), os.Getenv("TWITTER_SECRET"), "http://localhost:3000/auth/twitterv2/callback"),
-		// If you'd like to use authenticate instead of authorize in TwitterV2 provider, use this instead.
-		// twitterv2.NewAuthenticate(os.Getenv("TWITTER_KEY"), os.Getenv("TWITTER_SECRET"), "http://localhost:3000/auth/twitterv2/callback"),
+		// twitterv2 uses the OAuth 2.0 user-context flow with PKCE, the twitter provider the legacy OAuth 1.0a flow
+		twitterv2.New(os.Getenv("TWITTER_CLIENT_ID"), os.Getenv("TWITTER_CLIENT_SECRET"), "http://localhost:3000/auth/twitterv2/callback"),
 
 		twitter.New(os.Getenv("TWITTER_KEY"), os.Getenv("TWITTER_SECRET"), "http://localhost:3000/auth/twitter/callback"),
 		// If you'd like to use authenticate instead of authorize in Twitter provider, use this instead.
@@ -87,6 +96,7 @@ func main() {
 
 		tiktok.New(os.Getenv("TIKTOK_KEY"), os.Getenv("TIKTOK_SECRET"), "http://localhost:3000/auth/tiktok/callback"),
 		facebook.New(os.Getenv("FACEBOOK_KEY"), os.Getenv("FACEBOOK_SECRET"), "http://localhost:3000/auth/facebook/callback"),
+		figma.New(os.Getenv("FIGMA_KEY"), os.Getenv("FIGMA_SECRET"), "http://localhost:3000/auth/figma/callback"),
 		fitbit.New(os.Getenv("FITBIT_KEY"), os.Getenv("FITBIT_SECRET"), "http://localhost:3000/auth/fitbit/callback"),
 		google.New(os.Getenv("GOOGLE_KEY"), os.Getenv("GOOGLE_SECRET"), "http://localhost:3000/auth/google/callback"),
 		gplus.New(os.Getenv("GPLUS_KEY"), os.Getenv("GPLUS_SECRET"), "http://localhost:3000/auth/gplus/callback"),
@@ -97,10 +107,14 @@ func main() {
 		lastfm.New(os.Getenv("LASTFM_KEY"), os.Getenv("LASTFM_SECRET"), "http://localhost:3000/auth/lastfm/callback"),
 		twitch.New(os.Getenv("TWITCH_KEY"), os.Getenv("TWITCH_SECRET"), "http://localhost:3000/auth/twitch/callback"),
 		dropbox.New(os.Getenv("DROPBOX_KEY"), os.Getenv("DROPBOX_SECRET"), "http://localhost:3000/auth/dropbox/callback"),
+		// eBay redirects to the accept URL of the RuName, set it to http://localhost:3000/auth/ebay/callback
+		ebay.New(os.Getenv("EBAY_KEY"), os.Getenv("EBAY_SECRET"), os.Getenv("EBAY_RUNAME")),
+		etsy.New(os.Getenv("ETSY_KEY"), os.Getenv("ETSY_SECRET"), "http://localhost:3000/auth/etsy/callback"),
 		digitalocean.New(os.Getenv("DIGITALOCEAN_KEY"), os.Getenv("DIGITALOCEAN_SECRET"), "http://localhost:3000/auth/digitalocean/callback", "read"),
 		bitbucket.New(os.Getenv("BITBUCKET_KEY"), os.Getenv("BITBUCKET_SECRET"), "http://localhost:3000/auth/bitbucket/callback"),
 		instagram.New(os.Getenv("INSTAGRAM_KEY"), os.Getenv("INSTAGRAM_SECRET"), "http://localhost:3000/auth/instagram/callback"),
 		intercom.New(os.Getenv("INTERCOM_KEY"), os.Getenv("INTERCOM_SECRET"), "http://localhost:3000/auth/intercom/callback"),
+		intuit.New(os.Getenv("INTUIT_KEY"), os.Getenv("INTUIT_SECRET"), "http://localhost:3000/auth/intuit/callback"),
 		box.New(os.Getenv("BOX_KEY"), os.Getenv("BOX_SECRET"), "http://localhost:3000/auth/box/callback"),
 		salesforce.New(os.Getenv("SALESFORCE_KEY"), os.Getenv("SALESFORCE_SECRET"), "http://localhost:3000/auth/salesforce/callback"),
 		seatalk.New(os.Getenv("SEATALK_KEY"), os.Getenv("SEATALK_SECRET"), "http://localhost:3000/auth/seatalk/callback"),
@@ -117,9 +131,10 @@ func main() {
 		// Yahoo only accepts urls that starts with https
 		yahoo.New(os.Getenv("YAHOO_KEY"), os.Getenv("YAHOO_SECRET"), "https://localhost.com"),
 		typetalk.New(os.Getenv("TYPETALK_KEY"), os.Getenv("TYPETALK_SECRET"), "http://localhost:3000/auth/typetalk/callback", "my"),
-		slack.New(os.Getenv("SLACK_KEY"), os.Getenv("SLACK_SECRET"), "http://localhost:3000/auth/slack/callback"),
+		slack.NewOpenID(os.Getenv("SLACK_KEY"), os.Getenv("SLACK_SECRET"), "http://localhost:3000/auth/slack/callback"),
 		stripe.New(os.Getenv("STRIPE_KEY"), os.Getenv("STRIPE_SECRET"), "http://localhost:3000/auth/stripe/callback"),
 		wepay.New(os.Getenv("WEPAY_KEY"), os.Getenv("WEPAY_SECRET"), "http://localhost:3000/auth/wepay/callback", "view_user"),
+		wordpress.New(os.Getenv("WORDPRESS_KEY"), os.Getenv("WORDPRESS_SECRET"), "http://localhost:3000/auth/wordpress/callback"),
 		// By default paypal production auth urls will be used, please set PAYPAL_ENV=sandbox as environment variable for testing
 		// in sandbox environment
 		paypal.New(os.Getenv("PAYPAL_KEY"), os.Getenv("PAYPAL_SECRET"), "http://localhost:3000/auth/paypal/callback"),
@@ -132,14 +147,17 @@ func main() {
 		deezer.New(os.Getenv("DEEZER_KEY"), os.Getenv("DEEZER_SECRET"), "http://localhost:3000/auth/deezer/callback", "email"),
 		discord.New(os.Getenv("DISCORD_KEY"), os.Getenv("DISCORD_SECRET"), "http://localhost:3000/auth/discord/callback", discord.ScopeIdentify, discord.ScopeEmail),
 		meetup.New(os.Getenv("MEETUP_KEY"), os.Getenv("MEETUP_SECRET"), "http://localhost:3000/auth/meetup/callback"),
+		atlassian.New(os.Getenv("ATLASSIAN_KEY"), os.Getenv("ATLASSIAN_SECRET"), "http://localhost:3000/auth/atlassian/callback"),
 
 		// Auth0 allocates domain per customer, a domain must be provided for auth0 to work
 		auth0.New(os.Getenv("AUTH0_KEY"), os.Getenv("AUTH0_SECRET"), "http://localhost:3000/auth/auth0/callback", os.Getenv("AUTH0_DOMAIN")),
 		xero.New(os.Getenv("XERO_KEY"), os.Getenv("XERO_SECRET"), "http://localhost:3000/auth/xero/callback"),
+		xerov2.New(os.Getenv("XERO_KEY"), os.Getenv("XERO_SECRET"), "http://localhost:3000/auth/xerov2/callback"),
 		vk.New(os.Getenv("VK_KEY"), os.Getenv("VK_SECRET"), "http://localhost:3000/auth/vk/callback"),
 		naver.New(os.Getenv("NAVER_KEY"), os.Getenv("NAVER_SECRET"), "http://localhost:3000/auth/naver/callback"),
 		yandex.New(os.Getenv("YANDEX_KEY"), os.Getenv("YANDEX_SECRET"), "http://localhost:3000/auth/yandex/callback"),
 		nextcloud.NewCustomisedDNS(os.Getenv("NEXTCLOUD_KEY"), os.Getenv("NEXTCLOUD_SECRET"), "http://localhost:3000/auth/nextcloud/callback", os.Getenv("NEXTCLOUD_URL")),
+		notion.New(os.Getenv("NOTION_KEY"), os.Getenv("NOTION_SECRET"), "http://localhost:3000/auth/notion/callback"),
 		gitea.New(os.Getenv("GITEA_KEY"), os.Getenv("GITEA_SECRET"), "http://localhost:3000/auth/gitea/callback"),
 		shopify.New(os.Getenv("SHOPIFY_KEY"), os.Getenv("SHOPIFY_SECRET"), "http://localhost:3000/auth/shopify/callback", shopify.ScopeReadCustomers, shopify.ScopeReadOrders),
 		apple.New(os.Getenv("APPLE_KEY"), os.Getenv("APPLE_SECRET"), "http://localhost:3000/auth/apple/callback", nil, apple.ScopeName, apple.ScopeEmail),
@@ -149,6 +167,8 @@ func main() {
 		wecom.New(os.Getenv("WECOM_CORP_ID"), os.Getenv("WECOM_SECRET"), os.Getenv("WECOM_AGENT_ID"), "http://localhost:3000/auth/wecom/callback"),
 		zoom.New(os.Getenv("ZOOM_KEY"), os.Getenv("ZOOM_SECRET"), "http://localhost:3000/auth/zoom/callback", "read:user"),
 		patreon.New(os.Getenv("PATREON_KEY"), os.Getenv("PATREON_SECRET"), "http://localhost:3000/auth/patreon/callback"),
+		plex.New(os.Getenv("PLEX_CLIENT_IDENTIFIER"), "Goth Example", "http://localhost:3000/auth/plex/callback"),
+		roblox.New(os.Getenv("ROBLOX_KEY"), os.Getenv("ROBLOX_SECRET"), "http://localhost:3000/auth/roblox/callback"),
 	)
 
 	// OpenID Connect is based on OpenID Connect Auto Discovery URL (https://openid.net/specs/openid-connect-discovery-1_0-17.html)
@@ -159,21 +179,40 @@ func main() {
 		goth.UseProviders(openidConnect)
 	}
 
+	// authentik is self-hosted, the provider discovers its endpoints from the instance URL and application slug
+	authentik, _ := authentik.New(os.Getenv("AUTHENTIK_KEY"), os.Getenv("AUTHENTIK_SECRET"), "http://localhost:3000/auth/authentik/callback", os.Getenv("AUTHENTIK_URL"), os.Getenv("AUTHENTIK_SLUG"))
+	if authentik != nil {
+		goth.UseProviders(authentik)
+	}
+
+	// Dex discovers its endpoints from the issuer URL, set DEX_CONNECTOR_ID to skip the connector selection page
+	dex, _ := dex.New(os.Getenv("DEX_KEY"), os.Getenv("DEX_SECRET"), "http://localhost:3000/auth/dex/callback", os.Getenv("DEX_ISSUER_URL"))
+	if dex != nil {
+		dex.SetConnectorID(os.Getenv("DEX_CONNECTOR_ID"))
+		goth.UseProviders(dex)
+	}
+
 	m := map[string]string{
 		"amazon":          "Amazon",
 		"apple":           "Apple",
+		"atlassian":       "Atlassian",
 		"auth0":           "Auth0",
+		"authentik":       "authentik",
 		"azuread":         "Azure AD",
 		"battlenet":       "Battle.net",
 		"bitbucket":       "Bitbucket",
 		"box":             "Box",
 		"dailymotion":     "Dailymotion",
 		"deezer":          "Deezer",
+		"dex":             "Dex",
 		"digitalocean":    "Digital Ocean",
 		"discord":         "Discord",
 		"dropbox":         "Dropbox",
+		"ebay":            "eBay",
+		"etsy":            "Etsy",
 		"eveonline":       "Eve Online",
 		"facebook":        "Facebook",
+		"figma":           "Figma",
 		"fitbit":          "Fitbit",
 		"gitea":           "Gitea",
 		"github":          "Github",
@@ -183,6 +222,7 @@ func main() {
 		"heroku":          "Heroku",
 		"instagram":       "Instagram",
 		"intercom":        "Intercom",
+		"intuit":          "Intuit",
 		"kakao":           "Kakao",
 		"lastfm":          "Last FM",
 		"line":            "LINE",
@@ -192,11 +232,14 @@ func main() {
 		"microsoftonline": "Microsoft Online",
 		"naver":           "Naver",
 		"nextcloud":       "NextCloud",
+		"notion":          "Notion",
 		"okta":            "Okta",
 		"onedrive":        "Onedrive",
 		"openid-connect":  "OpenID Connect",
 		"patreon":         "Patreon",
 		"paypal":          "Paypal",
+		"plex":            "Plex",
+		"roblox":          "Roblox",
 		"salesforce":      "Salesforce",
 		"seatalk":         "SeaTalk",
 		"shopify":         "Shopify",
@@ -215,7 +258,9 @@ func main() {
 		"vk":              "VK",
 		"wecom":           "WeCom",
 		"wepay":           "Wepay",
+		"wordpress":       "WordPress.com",
 		"xero":            "Xero",
+		"xerov2":          "Xero (OpenID Connect)",
 		"yahoo":           "Yahoo",
 		"yammer":          "Yammer",
 		"yandex":          "Yandex",
@@ -0,0 +1,118 @@
+package goth
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// fipsMode is set by EnableFIPS, the goth_fips build tag, or the GOTH_FIPS
+// environment variable set to 1.
+var (
+	fipsMu   sync.RWMutex
+	fipsMode = os.Getenv("GOTH_FIPS") == "1"
+)
+
+// ErrNotFIPSApproved is matched by the errors of the primitives and
+// configurations refused in FIPS mode.
+var ErrNotFIPSApproved = errors.New("goth: not FIPS approved")
+
+// FIPSError is returned when a primitive or a configuration is refused in
+// FIPS mode. It matches ErrNotFIPSApproved with errors.Is.
+type FIPSError struct {
+	// What is the primitive or configuration refused, e.g. "JWT algorithm
+	// EdDSA".
+	What string
+}
+
+func (e *FIPSError) Error() string {
+	return "goth: " + e.What + " is not FIPS approved"
+}
+
+// Is reports whether target is ErrNotFIPSApproved.
+func (e *FIPSError) Is(target error) bool {
+	return target == ErrNotFIPSApproved
+}
+
+// EnableFIPS restricts the cryptography of goth and gothic to the primitives
+// approved by FIPS 140: the session keys, the algorithms of the JWTs
+// validated, and the encryption types of Kerberos. The configurations using
+// other primitives fail with a *FIPSError. It should be called before
+// configuring gothic and the providers; build with the goth_fips tag or set
+// GOTH_FIPS=1 to enable it from the start, including the SESSION_SECRET read
+// by gothic.
+//
+// It only restricts the choices of goth: build with a FIPS validated
+// cryptographic module of Go for a compliant deployment.
+func EnableFIPS() {
+	fipsMu.Lock()
+	defer fipsMu.Unlock()
+	fipsMode = true
+}
+
+// FIPS reports whether the FIPS mode is enabled.
+func FIPS() bool {
+	fipsMu.RLock()
+	defer fipsMu.RUnlock()
+	return fipsMode
+}
+
+// fipsJWTAlgorithms are the JWS algorithms with FIPS approved primitives.
+// EdDSA is left out, as FIPS 140-2 modules don't approve it.
+var fipsJWTAlgorithms = map[string]bool{
+	"RS256": true, "RS384": true, "RS512": true,
+	"PS256": true, "PS384": true, "PS512": true,
+	"ES256": true, "ES384": true, "ES512": true,
+	"HS256": true, "HS384": true, "HS512": true,
+}
+
+// JWTAlgorithms returns the algorithms allowed to validate JWTs: all of them,
+// or the FIPS approved ones in FIPS mode.
+func JWTAlgorithms(algorithms ...string) []string {
+	if !FIPS() {
+		return algorithms
+	}
+	var allowed []string
+	for _, alg := range algorithms {
+		if fipsJWTAlgorithms[alg] {
+			allowed = append(allowed, alg)
+		}
+	}
+	return allowed
+}
+
+// CheckJWTAlgorithms returns a *FIPSError in FIPS mode when an algorithm isn't
+// approved.
+func CheckJWTAlgorithms(algorithms ...string) error {
+	if !FIPS() {
+		return nil
+	}
+	for _, alg := range algorithms {
+		if !fipsJWTAlgorithms[alg] {
+			return &FIPSError{What: "JWT algorithm " + alg}
+		}
+	}
+	return nil
+}
+
+// minFIPSHMACKey is the minimum length of HMAC keys, 112 bits, see NIST SP
+// 800-131A.
+const minFIPSHMACKey = 14
+
+// CheckSessionKeys returns a *FIPSError in FIPS mode when the keys of
+// sessions are too short to authenticate them with HMAC-SHA256, or when the
+// encryption key, if any, isn't an AES key.
+func CheckSessionKeys(authKey, encryptionKey []byte) error {
+	if !FIPS() {
+		return nil
+	}
+	if len(authKey) < minFIPSHMACKey {
+		return &FIPSError{What: "session authentication key shorter than 112 bits"}
+	}
+	switch len(encryptionKey) {
+	case 0, 16, 24, 32:
+		return nil
+	default:
+		return &FIPSError{What: "session encryption key which isn't an AES key"}
+	}
+}
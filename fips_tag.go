@@ -0,0 +1,8 @@
+//go:build goth_fips
+// +build goth_fips
+
+package goth
+
+func init() {
+	fipsMode = true
+}
@@ -0,0 +1,33 @@
+package goth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FIPS(t *testing.T) {
+	a := assert.New(t)
+	enabled := fipsMode
+	fipsMode = false
+	defer func() { fipsMode = enabled }()
+
+	a.Equal([]string{"EdDSA", "RS256"}, JWTAlgorithms("EdDSA", "RS256"))
+	a.NoError(CheckJWTAlgorithms("none"))
+	a.NoError(CheckSessionKeys([]byte("short"), nil))
+
+	EnableFIPS()
+	a.True(FIPS())
+
+	a.Equal([]string{"RS256", "ES384"}, JWTAlgorithms("EdDSA", "RS256", "none", "ES384"))
+	a.NoError(CheckJWTAlgorithms("RS256", "PS512"))
+	err := CheckJWTAlgorithms("RS256", "EdDSA")
+	a.True(errors.Is(err, ErrNotFIPSApproved))
+	a.Equal("goth: JWT algorithm EdDSA is not FIPS approved", err.Error())
+
+	a.True(errors.Is(CheckSessionKeys([]byte("short"), nil), ErrNotFIPSApproved))
+	a.NoError(CheckSessionKeys([]byte("a-key-of-32-bytes-for-hmac-sha25"), nil))
+	a.NoError(CheckSessionKeys([]byte("a-key-of-32-bytes-for-hmac-sha25"), make([]byte, 32)))
+	a.True(errors.Is(CheckSessionKeys([]byte("a-key-of-32-bytes-for-hmac-sha25"), make([]byte, 20)), ErrNotFIPSApproved))
+}
@@ -0,0 +1,214 @@
+package gothic
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Login is the metadata of a login of a user, passed to Anomalies with the
+// previous login kept by Users.
+type Login struct {
+	Provider string
+	UserID   string
+	Time     time.Time
+	// IP is the address of the client, see ClientIP.
+	IP        string
+	UserAgent string
+	// Device is a hash of the user agent and the client hints of the
+	// client.
+	Device string
+}
+
+// UserStore keeps the last login of the users.
+type UserStore interface {
+	// LastLogin returns the last login of the user, or nil when the user
+	// never logged in.
+	LastLogin(provider, userID string) (*Login, error)
+	SaveLogin(login Login) error
+}
+
+// AnomalyDetector flags suspicious logins, e.g. from a new device, which
+// then require the verification of StepUp.
+type AnomalyDetector interface {
+	// Detect reports whether the login is anomalous. The previous login is
+	// nil when the user never logged in, or when Users isn't configured.
+	Detect(req *http.Request, login, previous *Login) (bool, error)
+}
+
+// AnomalyDetectorFunc is an AnomalyDetector function.
+type AnomalyDetectorFunc func(req *http.Request, login, previous *Login) (bool, error)
+
+// Detect calls f.
+func (f AnomalyDetectorFunc) Detect(req *http.Request, login, previous *Login) (bool, error) {
+	return f(req, login, previous)
+}
+
+var (
+	// Users keeps the last login of the users, for Anomalies, when set.
+	Users UserStore
+	// Anomalies is called by CompleteUserAuth once the user is
+	// authenticated by the provider. Anomalous logins require the
+	// verification of StepUp, even when StepUp doesn't require it from the
+	// user, and fail with ErrAnomalousLogin without StepUp.
+	Anomalies AnomalyDetector
+
+	ErrAnomalousLogin = errors.New("anomalous login")
+
+	// NewDevice flags the logins from another device than the previous
+	// login of the user.
+	NewDevice AnomalyDetector = AnomalyDetectorFunc(func(req *http.Request, login, previous *Login) (bool, error) {
+		return previous != nil && previous.Device != login.Device, nil
+	})
+)
+
+// AnyAnomaly flags the logins flagged by any of the detectors.
+func AnyAnomaly(detectors ...AnomalyDetector) AnomalyDetector {
+	return AnomalyDetectorFunc(func(req *http.Request, login, previous *Login) (bool, error) {
+		for _, detector := range detectors {
+			anomalous, err := detector.Detect(req, login, previous)
+			if anomalous || err != nil {
+				return anomalous, err
+			}
+		}
+		return false, nil
+	})
+}
+
+// ImpossibleTravel flags the logins too far from the previous login of the
+// user to have travelled between them.
+type ImpossibleTravel struct {
+	// Locate returns the coordinates of an IP address, e.g. with a GeoIP
+	// database. The logins from addresses it can't locate aren't flagged.
+	Locate func(ip string) (latitude, longitude float64, err error)
+	// MaxSpeed is the speed of the users in km/h, 1000 by default. 100 km
+	// are allowed for the inaccuracy of the locations.
+	MaxSpeed float64
+}
+
+// Detect flags the login when it is too far from the previous one.
+func (t ImpossibleTravel) Detect(req *http.Request, login, previous *Login) (bool, error) {
+	if previous == nil || previous.IP == login.IP {
+		return false, nil
+	}
+	lat1, lon1, err := t.Locate(previous.IP)
+	if err != nil {
+		return false, nil
+	}
+	lat2, lon2, err := t.Locate(login.IP)
+	if err != nil {
+		return false, nil
+	}
+	speed := t.MaxSpeed
+	if speed == 0 {
+		speed = 1000
+	}
+	hours := login.Time.Sub(previous.Time).Hours()
+	if hours < 0 {
+		hours = 0
+	}
+	return distance(lat1, lon1, lat2, lon2) > speed*hours+100, nil
+}
+
+// distance returns the great-circle distance in km between two coordinates.
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+// MemoryUserStore is a UserStore in memory, for a single instance of the
+// application.
+type MemoryUserStore struct {
+	mu     sync.Mutex
+	logins map[[2]string]Login
+}
+
+// NewMemoryUserStore returns an empty MemoryUserStore.
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{logins: map[[2]string]Login{}}
+}
+
+// LastLogin returns the last login of the user.
+func (s *MemoryUserStore) LastLogin(provider, userID string) (*Login, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	login, ok := s.logins[[2]string{provider, userID}]
+	if !ok {
+		return nil, nil
+	}
+	return &login, nil
+}
+
+// SaveLogin saves the login as the last login of the user.
+func (s *MemoryUserStore) SaveLogin(login Login) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logins[[2]string{login.Provider, login.UserID}] = login
+	return nil
+}
+
+func newLogin(req *http.Request, user goth.User) *Login {
+	return &Login{
+		Provider:  user.Provider,
+		UserID:    user.UserID,
+		Time:      goth.Now(),
+		IP:        ClientIP(req),
+		UserAgent: req.UserAgent(),
+		Device:    deviceFingerprint(req, true),
+	}
+}
+
+// authenticated checks the login of the user authenticated by the provider,
+// requires the second factor when needed, and saves the login once the user
+// is authenticated, with the changes of the session in update.
+func authenticated(c *Config, req *http.Request, user goth.User, update *sessionUpdate) (goth.User, error) {
+	login := newLogin(req, user)
+	anomalous := false
+	if c.Anomalies != nil {
+		var previous *Login
+		if c.Users != nil {
+			var err error
+			previous, err = c.Users.LastLogin(login.Provider, login.UserID)
+			if err != nil {
+				return goth.User{}, err
+			}
+		}
+		var err error
+		anomalous, err = c.Anomalies.Detect(req, login, previous)
+		if err != nil {
+			return goth.User{}, err
+		}
+		if anomalous && c.StepUp == nil {
+			return goth.User{}, ErrAnomalousLogin
+		}
+	}
+	if c.StepUp != nil {
+		var err error
+		user, err = requireStepUp(c, req, user, anomalous, update)
+		if err != nil {
+			return user, err
+		}
+	}
+	return user, loggedIn(c, req, user, update)
+}
+
+// loggedIn saves the login of the authenticated user in Users, and its
+// session in the Inventory, when they are set. The time of the login is kept
+// in the session for the Revocations.
+func loggedIn(c *Config, req *http.Request, user goth.User, update *sessionUpdate) error {
+	issued(c, update)
+	if c.Users != nil {
+		if err := c.Users.SaveLogin(*newLogin(req, user)); err != nil {
+			return err
+		}
+	}
+	return createSession(c, req, user, update)
+}
@@ -0,0 +1,184 @@
+package gothic
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// IPBinding binds the sessions of gothic to the IP address of the client who
+// started the authentication.
+type IPBinding int
+
+const (
+	// IPBindingNone doesn't bind the sessions.
+	IPBindingNone IPBinding = iota
+	// IPBindingExact binds the sessions to the IP address.
+	IPBindingExact
+	// IPBindingPrefix binds the sessions to the /24 prefix of IPv4 addresses
+	// and the /64 prefix of IPv6 addresses, to allow for clients roaming in
+	// their network.
+	IPBindingPrefix
+)
+
+var (
+	// BindIP binds the sessions to the IP address of the client, so they are
+	// rejected with an *IPMismatchError when they are used from another
+	// address. Clients changing addresses during the authentication, e.g.
+	// mobile users roaming, can't complete it.
+	BindIP = IPBindingNone
+
+	// ClientIP returns the IP address of the client of a request. It defaults
+	// to the address of the connection; set it when the application is behind
+	// a proxy.
+	ClientIP = clientIP
+
+	// BindUserAgent binds the sessions to a hash of the User-Agent of the
+	// client, so they are rejected with ErrSessionUserAgentMismatch when
+	// they are replayed by another client, e.g. with a stolen cookie.
+	BindUserAgent = false
+	// BindClientHints adds the Sec-CH-UA, Sec-CH-UA-Mobile and
+	// Sec-CH-UA-Platform client hints to the hash of BindUserAgent.
+	BindClientHints = false
+
+	ErrSessionIPMismatch        = errors.New("session used from another IP address")
+	ErrSessionUserAgentMismatch = errors.New("session used from another user agent")
+)
+
+// IPMismatchError is returned when a session bound to an IP address is used
+// from another one. It matches ErrSessionIPMismatch with errors.Is.
+type IPMismatchError struct {
+	// Bound is the address or prefix the session is bound to.
+	Bound string
+	// IP is the address the session was used from.
+	IP string
+}
+
+func (e *IPMismatchError) Error() string {
+	return "gothic: session bound to " + e.Bound + " used from " + e.IP
+}
+
+// Is reports whether target is ErrSessionIPMismatch.
+func (e *IPMismatchError) Is(target error) bool {
+	return target == ErrSessionIPMismatch
+}
+
+// The session keys of the bindings.
+const (
+	ipKey        = "_gothic_ip"
+	userAgentKey = "_gothic_ua"
+)
+
+// bindSession returns the session values binding the session to the client
+// of the request.
+func bindSession(req *http.Request) map[string]string {
+	values := map[string]string{}
+	if bound := boundIP(req); bound != "" {
+		values[ipKey] = bound
+	}
+	if fingerprint := userAgentFingerprint(req); fingerprint != "" {
+		values[userAgentKey] = fingerprint
+	}
+	return values
+}
+
+// checkSession checks that the request comes from the client the session is
+// bound to.
+func checkSession(c *Config, req *http.Request) error {
+	if BindIP != IPBindingNone {
+		bound, _ := getFromSession(c, ipKey, req)
+		if err := checkIP(req, bound); err != nil {
+			return err
+		}
+	}
+	if BindUserAgent {
+		fingerprint, _ := getFromSession(c, userAgentKey, req)
+		if err := checkUserAgent(req, fingerprint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// boundIP returns the address or prefix of the client the sessions are bound
+// to, or an empty string when they aren't bound.
+func boundIP(req *http.Request) string {
+	if BindIP == IPBindingNone {
+		return ""
+	}
+	ip := ClientIP(req)
+	if BindIP != IPBindingPrefix {
+		return ip
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
+
+// checkIP checks that the request comes from the address the session is
+// bound to, if any.
+func checkIP(req *http.Request, bound string) error {
+	if bound == "" {
+		return nil
+	}
+	ip := ClientIP(req)
+	if _, network, err := net.ParseCIDR(bound); err == nil {
+		if parsed := net.ParseIP(ip); parsed != nil && network.Contains(parsed) {
+			return nil
+		}
+	} else if ip == bound {
+		return nil
+	}
+	return &IPMismatchError{Bound: bound, IP: ip}
+}
+
+// userAgentFingerprint returns the hash of the user agent of the client, or
+// an empty string when the sessions aren't bound to it.
+func userAgentFingerprint(req *http.Request) string {
+	if !BindUserAgent {
+		return ""
+	}
+	return deviceFingerprint(req, BindClientHints)
+}
+
+// deviceFingerprint returns the hash of the user agent of the client, and of
+// its client hints when requested.
+func deviceFingerprint(req *http.Request, clientHints bool) string {
+	h := sha256.New()
+	h.Write([]byte(req.UserAgent()))
+	if clientHints {
+		for _, name := range []string{"Sec-CH-UA", "Sec-CH-UA-Mobile", "Sec-CH-UA-Platform"} {
+			h.Write([]byte{0})
+			h.Write([]byte(req.Header.Get(name)))
+		}
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// checkUserAgent checks that the request comes from the user agent the
+// session is bound to, if any.
+func checkUserAgent(req *http.Request, fingerprint string) error {
+	if fingerprint == "" {
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(fingerprint), []byte(userAgentFingerprint(req))) != 1 {
+		return ErrSessionUserAgentMismatch
+	}
+	return nil
+}
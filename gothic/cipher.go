@@ -0,0 +1,92 @@
+package gothic
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// StoreCipher encrypts the values of the sessions before the Store persists
+// them, e.g. in files, Redis or SQL, and decrypts them when they are read, in
+// addition to the encryption of the cookies by the store.
+type StoreCipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// Cipher encrypts the values of the sessions, when set. The sessions stored
+// before it is set, or with keys it no longer has, can't be read, and their
+// users have to authenticate again.
+var Cipher StoreCipher
+
+// ErrSessionDecryption is returned when a session value can't be decrypted by
+// the Cipher.
+var ErrSessionDecryption = errors.New("session value can't be decrypted")
+
+// aesCipher encrypts with AES-GCM, each value prefixed by the ID of its key.
+type aesCipher struct {
+	aeads map[[4]byte]cipher.AEAD
+	ids   [][4]byte
+}
+
+// NewAESCipher returns a StoreCipher encrypting with AES-GCM and the first of
+// the 16, 24 or 32 bytes long keys, and decrypting with any of them, to
+// rotate the keys.
+func NewAESCipher(keys ...[]byte) (StoreCipher, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("gothic: no keys for the session cipher")
+	}
+	c := &aesCipher{aeads: map[[4]byte]cipher.AEAD{}}
+	for i, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("gothic: session cipher key %d: %w", i, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		var id [4]byte
+		sum := sha256.Sum256(key)
+		copy(id[:], sum[:])
+		c.aeads[id] = aead
+		c.ids = append(c.ids, id)
+	}
+	return c, nil
+}
+
+// Encrypt encrypts the plaintext with the first key.
+func (c *aesCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	id := c.ids[0]
+	aead := c.aeads[id]
+	out := make([]byte, len(id)+aead.NonceSize(), len(id)+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	copy(out, id[:])
+	nonce := out[len(id):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	// the ID of the key is authenticated
+	return aead.Seal(out, nonce, plaintext, id[:]), nil
+}
+
+// Decrypt decrypts the ciphertext with the key it was encrypted with.
+func (c *aesCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	var id [4]byte
+	if len(ciphertext) < len(id) {
+		return nil, ErrSessionDecryption
+	}
+	copy(id[:], ciphertext)
+	aead, ok := c.aeads[id]
+	if !ok || len(ciphertext) < len(id)+aead.NonceSize() {
+		return nil, ErrSessionDecryption
+	}
+	nonce := ciphertext[len(id) : len(id)+aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, ciphertext[len(id)+aead.NonceSize():], id[:])
+	if err != nil {
+		return nil, ErrSessionDecryption
+	}
+	return plaintext, nil
+}
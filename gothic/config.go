@@ -0,0 +1,166 @@
+package gothic
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gorilla/sessions"
+)
+
+// Config is the configuration of the sessions of gothic, of the functions
+// reading the requests, and of the components of the logins, replaced as a
+// whole by Configure. Start from CurrentConfig to keep the configuration
+// which isn't replaced.
+type Config struct {
+	Store sessions.Store
+	// SessionName is the name of the session, "_gothic_session" when it is
+	// empty.
+	SessionName string
+	// The functions of the requests, see SetState, GetState and
+	// GetProviderName. The functions of gothic are used when they are nil.
+	SetState        func(req *http.Request) string
+	GetState        func(req *http.Request) string
+	GetProviderName func(req *http.Request) (string, error)
+
+	// The components of the logins, see the package variables. The
+	// components left nil aren't used.
+	Cipher           StoreCipher
+	Inventory        SessionInventory
+	Revocations      RevocationList
+	Tokens           TokenStore
+	Users            UserStore
+	Anomalies        AnomalyDetector
+	StepUp           SecondFactor
+	Queue            *LoginQueue
+	AllowedRedirects *RedirectAllowlist
+}
+
+// config is the *Config of Configure, or nil.
+var config atomic.Value
+
+func init() {
+	config.Store((*Config)(nil))
+}
+
+/*
+Configure replaces the configuration of gothic with a copy of c, atomically:
+the applications reconfiguring gothic while it serves requests, e.g. rotating
+the keys of the sessions with UseCookies, don't race with the requests in
+flight, which read the previous configuration or the new one.
+
+The configuration is c, as it is: its components left nil aren't used, even
+when the package variable of the same name is set. Start from CurrentConfig
+to keep the configuration gothic reads:
+
+	c := gothic.CurrentConfig()
+	c.Store = store
+	err := gothic.Configure(&c)
+
+Until Configure is called, gothic reads the package variables of the fields
+of Config, e.g. Store, GetProviderName or Inventory, which must then only be
+set before the requests are served. Once it is called, gothic only reads its
+configuration, and UseCookies and UseFilesystem replace the Store of the
+configuration. Configure(nil) goes back to the package variables. Each request
+reads a single configuration, from its start to its end.
+
+The other package variables, e.g. UserCacheTTL, the timeouts of the
+operations, MaxCookieSize, BindIP and BindUserAgent, and the function
+variables, e.g. CompleteUserAuth, replacing the handlers of gothic in the
+tests, aren't part of the configuration: they must still only be set before
+the requests are served.
+*/
+func Configure(c *Config) error {
+	if c == nil {
+		config.Store((*Config)(nil))
+		return nil
+	}
+	if c.Store == nil {
+		return errors.New("gothic: the configuration has no Store")
+	}
+	snapshot := *c
+	if snapshot.SessionName == "" {
+		snapshot.SessionName = defaultSessionName
+	}
+	if snapshot.SetState == nil {
+		snapshot.SetState = setState
+	}
+	if snapshot.GetState == nil {
+		snapshot.GetState = getState
+	}
+	if snapshot.GetProviderName == nil {
+		snapshot.GetProviderName = getProviderName
+	}
+	config.Store(&snapshot)
+	return nil
+}
+
+// CurrentConfig returns the configuration gothic reads, the one of Configure
+// or the package variables.
+func CurrentConfig() Config {
+	return *current()
+}
+
+// configured returns the configuration of Configure, or nil.
+func configured() *Config {
+	return config.Load().(*Config)
+}
+
+// current returns the configuration of Configure, or a snapshot of the
+// package variables. It is read once by the functions of gothic serving a
+// request, which pass it down.
+func current() *Config {
+	if c := configured(); c != nil {
+		return c
+	}
+	return variables()
+}
+
+// variables returns the configuration of the package variables.
+func variables() *Config {
+	return &Config{
+		Store:            Store,
+		SessionName:      SessionName,
+		SetState:         SetState,
+		GetState:         GetState,
+		GetProviderName:  GetProviderName,
+		Cipher:           Cipher,
+		Inventory:        Inventory,
+		Revocations:      Revocations,
+		Tokens:           Tokens,
+		Users:            Users,
+		Anomalies:        Anomalies,
+		StepUp:           StepUp,
+		Queue:            Queue,
+		AllowedRedirects: AllowedRedirects,
+	}
+}
+
+// currentSession returns the store and the name of the session, of the
+// configuration of Configure or the package variables, for getProviderName,
+// which current refers to.
+func currentSession() (sessions.Store, string) {
+	if c := configured(); c != nil {
+		return c.Store, c.SessionName
+	}
+	return Store, SessionName
+}
+
+// useStore replaces the store of the configuration of Configure, when there
+// is one, or else the Store variable.
+func useStore(store sessions.Store) {
+	for {
+		c := configured()
+		if c == nil {
+			Store = store
+			defaultStore = store
+			keySet = true
+			return
+		}
+		next := *c
+		next.Store = store
+		if config.CompareAndSwap(c, &next) {
+			return
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package gothic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// JSONErrors makes the handlers of gothic write their errors as JSON, e.g.
+// {"error":"provider_required","status":400}, for single page applications
+// and mobile clients. The clients preferring text or HTML in their Accept
+// header still get text.
+var JSONErrors = false
+
+// errorCodes are the codes of the JSON errors.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrProviderRequired, "provider_required"},
+	{goth.ErrProviderNotFound, "provider_not_found"},
+	{goth.ErrProviderUnavailable, "provider_unavailable"},
+	{ErrSessionNotFound, "session_not_found"},
+	{ErrStateTokenMismatch, "state_mismatch"},
+	{ErrSessionIPMismatch, "session_mismatch"},
+	{ErrSessionUserAgentMismatch, "session_mismatch"},
+	{ErrSessionTooLarge, "session_too_large"},
+	{ErrRedirectNotAllowed, "redirect_not_allowed"},
+	{ErrProviderBusy, "provider_busy"},
+	{ErrAnomalousLogin, "anomalous_login"},
+	{ErrSecondFactorRequired, "second_factor_required"},
+	{ErrSecondFactorFailed, "second_factor_failed"},
+	{ErrSecondFactorExpired, "second_factor_expired"},
+	{ErrPanic, "internal_error"},
+}
+
+// ErrorCode returns the code of the error in the JSON errors, e.g.
+// provider_required for ErrProviderRequired, or a code of the status for the
+// other errors, e.g. bad_request.
+func ErrorCode(err error, status int) string {
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// ErrorResponse is the body of the JSON errors.
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Status  int    `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// WriteError writes the error with the status, as JSON when JSONErrors is set
+// and the client accepts it, or as text, e.g. in the callback handler of the
+// application when CompleteUserAuth fails.
+func WriteError(res http.ResponseWriter, req *http.Request, status int, err error) {
+	if JSONErrors && acceptsJSON(req) {
+		res.Header().Set("Content-Type", "application/json")
+		res.Header().Set("X-Content-Type-Options", "nosniff")
+		res.WriteHeader(status)
+		_ = json.NewEncoder(res).Encode(ErrorResponse{
+			Error:   ErrorCode(err, status),
+			Status:  status,
+			Message: err.Error(),
+		})
+		return
+	}
+	res.WriteHeader(status)
+	_, _ = fmt.Fprintln(res, err)
+}
+
+// acceptsJSON reports whether the Accept header of the request prefers JSON
+// to text and HTML, or accepts anything.
+func acceptsJSON(req *http.Request) bool {
+	accept := req.Header.Get("Accept")
+	if accept == "" {
+		return true
+	}
+	jsonQ, textQ := -1.0, -1.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		switch mediaType {
+		case "application/json", "application/problem+json":
+			jsonQ = maxQ(jsonQ, q)
+		case "application/*", "*/*":
+			// wildcards rank below the explicit types
+			jsonQ = maxQ(jsonQ, q-0.001)
+		case "text/plain", "text/html", "text/*":
+			textQ = maxQ(textQ, q)
+		}
+	}
+	return jsonQ > 0 && jsonQ >= textQ
+}
+
+func maxQ(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,136 @@
+package gothic
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// EventType is the type of an event of the authentication lifecycle.
+type EventType string
+
+// The events of the authentication lifecycle.
+const (
+	// EventAuthStarted is published when the user is sent to the provider.
+	EventAuthStarted EventType = "auth_started"
+	// EventAuthCompleted is published when the user is authenticated, after
+	// the second factor when one is required.
+	EventAuthCompleted EventType = "auth_completed"
+	// EventAuthFailed is published when an authentication or the verification
+	// of a second factor fails.
+	EventAuthFailed EventType = "auth_failed"
+	// EventSecondFactorRequired is published when the user signed in with the
+	// provider, and has to verify a second factor.
+	EventSecondFactorRequired EventType = "second_factor_required"
+	// EventLogout is published when the session is invalidated by Logout.
+	EventLogout EventType = "logout"
+	// EventPanic is published when Recover recovers a panic, with its
+	// *PanicError.
+	EventPanic EventType = "panic"
+)
+
+// Event is the payload of an event of the authentication lifecycle.
+type Event struct {
+	Type EventType
+	// Provider is the name of the provider, empty when it couldn't be
+	// determined from the request.
+	Provider string
+	Request  *http.Request
+	// User is the user authenticated, for EventAuthCompleted and
+	// EventSecondFactorRequired.
+	User goth.User
+	// Err is the error of EventAuthFailed and EventPanic.
+	Err  error
+	Time time.Time
+}
+
+// EventHandler handles the events it is subscribed to. It is called by the
+// goroutine handling the request, so it should be quick.
+type EventHandler func(event Event)
+
+type subscription struct {
+	handler EventHandler
+}
+
+var (
+	subscriptionsMu sync.RWMutex
+	subscriptions   = map[EventType][]*subscription{}
+)
+
+// Subscribe calls the handler for every event of the type, after the handlers
+// subscribed before it, e.g. to provision the users once they are
+// authenticated. It returns the function unsubscribing the handler.
+func Subscribe(event EventType, handler EventHandler) (unsubscribe func()) {
+	s := &subscription{handler: handler}
+	subscriptionsMu.Lock()
+	subscriptions[event] = append(subscriptions[event], s)
+	subscriptionsMu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			subscriptionsMu.Lock()
+			defer subscriptionsMu.Unlock()
+			current := subscriptions[event]
+			remaining := make([]*subscription, 0, len(current))
+			for _, other := range current {
+				if other != s {
+					remaining = append(remaining, other)
+				}
+			}
+			subscriptions[event] = remaining
+		})
+	}
+}
+
+func subscribed() bool {
+	subscriptionsMu.RLock()
+	defer subscriptionsMu.RUnlock()
+	for _, handlers := range subscriptions {
+		if len(handlers) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// publish publishes the event of an operation to its subscribers.
+func publish(op Operation) {
+	event := Event{
+		Provider: op.Provider,
+		Request:  op.Request,
+		User:     op.User,
+		Err:      op.Err,
+		Time:     op.Start,
+	}
+	switch {
+	case op.Name == OperationLogout:
+		if op.Err != nil {
+			return
+		}
+		event.Type = EventLogout
+	case errors.Is(op.Err, ErrPanic):
+		event.Type = EventPanic
+	case errors.Is(op.Err, ErrSecondFactorRequired):
+		event.Type = EventSecondFactorRequired
+		event.Err = nil
+	case op.Err != nil:
+		event.Type = EventAuthFailed
+	case op.Name == OperationBeginAuth:
+		event.Type = EventAuthStarted
+	case op.Name == OperationCompleteUserAuth, op.Name == OperationVerifySecondFactor:
+		event.Type = EventAuthCompleted
+	default:
+		return
+	}
+
+	subscriptionsMu.RLock()
+	handlers := subscriptions[event.Type]
+	subscriptionsMu.RUnlock()
+	for _, s := range handlers {
+		s.handler(event)
+	}
+}
@@ -11,7 +11,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
-	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -21,6 +21,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/andreimerlescu/goth"
@@ -33,7 +34,7 @@ type contextKey string
 
 var (
 	keySet       = false
-	SessionName  = "_gothic_session"
+	SessionName  = defaultSessionName
 	defaultStore sessions.Store
 	Store        sessions.Store
 
@@ -45,44 +46,60 @@ var (
 
 	timeoutKey     contextKey = "timeout"
 	providerKey    contextKey = "provider"
+	inflightKey    contextKey = "inflight"
 	defaultTimeout            = 30 * time.Second
 )
 
 // ProviderParamKey can be used as a key in context when passing in a provider
 const ProviderParamKey int = iota
 
+// defaultSessionName is the name of the session, unless SessionName or the
+// configuration replaces it.
+const defaultSessionName = "_gothic_session"
+
+// sessionSecretErr is the error of the cookie store of the SESSION_SECRET
+// environment variable, e.g. a key refused in FIPS mode, returned by the
+// functions using the sessions until another store is set.
+var sessionSecretErr error
+
 func init() {
-	if len(os.Getenv("SESSION_SECRET")) > 0 {
-		err := UseCookies([]byte(os.Getenv("SESSION_SECRET")), &sessions.Options{HttpOnly: true})
-		if err != nil {
-			keySet = true
+	if secret := os.Getenv("SESSION_SECRET"); len(secret) > 0 {
+		if err := UseCookies([]byte(secret), &sessions.Options{HttpOnly: true}); err != nil {
+			sessionSecretErr = fmt.Errorf("gothic: SESSION_SECRET: %w", err)
 		}
 	}
 }
 
 // UseCookies assigns the sessions.Store to sessions.NewCookieStore using your provided key.
-// You supply a pointer to the session.Options into gothic.
+// You supply a pointer to the session.Options into gothic. Once Configure is
+// called, it replaces the Store of the configuration.
+// In FIPS mode, see goth.EnableFIPS, the key must be at least 14 bytes long.
 func UseCookies(key []byte, opts *sessions.Options) error {
+	if err := goth.CheckSessionKeys(key, nil); err != nil {
+		return err
+	}
 	cookieStore := sessions.NewCookieStore(key)
 	cookieStore.Options = opts
-	Store = cookieStore
-	defaultStore = Store
-	keySet = true
+	useStore(cookieStore)
 	return nil
 }
 
 // UseFilesystem assigns the sessions.Store to sessions.NewFilesystemStore using your path and
-// provided key. You supply a pointer to your sessions.Options into gothic.
+// provided key. You supply a pointer to your sessions.Options into gothic. Once
+// Configure is called, it replaces the Store of the configuration.
+// In FIPS mode, see goth.EnableFIPS, the authentication key must be at least
+// 14 bytes long, and the encryption key an AES key.
 func UseFilesystem(path string, authKey, encryptionKey []byte, maxLength int, opts *sessions.Options) error {
+	if err := goth.CheckSessionKeys(authKey, encryptionKey); err != nil {
+		return err
+	}
 	codec := securecookie.New(authKey, encryptionKey)
 	fsStore := sessions.NewFilesystemStore(path, authKey, encryptionKey)
 	fsStore.Options = opts
 	fsStore.Codecs = []securecookie.Codec{codec}
 	fsStore.MaxLength(maxLength)
 	fsStore.MaxAge(opts.MaxAge)
-	Store = fsStore
-	defaultStore = Store
-	keySet = true
+	useStore(fsStore)
 	return nil
 }
 
@@ -97,21 +114,58 @@ for the requested provider.
 See https://github.com/markbates/goth/blob/master/examples/main.go to see this in action.
 */
 func BeginAuthHandler(res http.ResponseWriter, req *http.Request) {
+	SetSecurityHeaders(res)
 	authURL, err := GetAuthURL(res, req)
 	if err != nil {
-		res.WriteHeader(http.StatusBadRequest)
-		_, _ = fmt.Fprintln(res, err)
+		ErrorHandler(res, req, http.StatusBadRequest, err)
 		return
 	}
 
 	http.Redirect(res, req, authURL, http.StatusTemporaryRedirect)
 }
 
+/*
+MetadataHandler serves the metadata of providers implementing
+goth.MetadataProvider, e.g. the service provider metadata of SAML which is
+registered with the identity provider. It expects to be able to get the name of
+the provider from the query parameters as either "provider" or ":provider".
+*/
+func MetadataHandler(res http.ResponseWriter, req *http.Request) {
+	providerName, err := current().GetProviderName(req)
+	if err != nil {
+		ErrorHandler(res, req, http.StatusBadRequest, err)
+		return
+	}
+
+	provider, err := goth.GetProvider(providerName)
+	if err != nil {
+		ErrorHandler(res, req, http.StatusNotFound, err)
+		return
+	}
+
+	mp, ok := provider.(goth.MetadataProvider)
+	if !ok {
+		ErrorHandler(res, req, http.StatusNotFound, fmt.Errorf("provider %s has no metadata", providerName))
+		return
+	}
+
+	contentType, metadata, err := mp.Metadata()
+	if err != nil {
+		ErrorHandler(res, req, http.StatusInternalServerError, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", contentType)
+	_, _ = res.Write(metadata)
+}
+
 // SetState sets the state string associated with the given request.
 // If no state string is associated with the request, one will be generated.
 // This state is sent to the provider and can be retrieved during the
 // callback.
-var SetState = func(req *http.Request) string {
+var SetState = setState
+
+func setState(req *http.Request) string {
 	state := req.URL.Query().Get("state")
 	if len(state) > 0 {
 		return state
@@ -122,8 +176,7 @@ var SetState = func(req *http.Request) string {
 	// is unguessable, preventing CSRF attacks, as described in
 	//
 	// https://auth0.com/docs/protocols/oauth2/oauth-state#keep-reading
-	nonceBytes := make([]byte, 64)
-	_, err := io.ReadFull(rand.Reader, nonceBytes)
+	nonceBytes, err := goth.RandomBytes(64)
 	if err != nil {
 		panic("gothic: source of randomness unavailable: " + err.Error())
 	}
@@ -133,12 +186,13 @@ var SetState = func(req *http.Request) string {
 // GetState gets the state returned by the provider during the callback.
 // This is used to prevent CSRF attacks, see
 // http://tools.ietf.org/html/rfc6749#section-10.12
-var GetState = func(req *http.Request) string {
-	params := req.URL.Query()
-	if params.Encode() == "" && req.Method == http.MethodPost {
+var GetState = getState
+
+func getState(req *http.Request) string {
+	if req.Method == http.MethodPost {
 		return req.FormValue("state")
 	}
-	return params.Get("state")
+	return req.URL.Query().Get("state")
 }
 
 /*
@@ -152,36 +206,120 @@ I would recommend using the BeginAuthHandler instead of doing all of these steps
 yourself, but that's entirely up to you.
 */
 func GetAuthURL(res http.ResponseWriter, req *http.Request) (string, error) {
-	if !keySet && defaultStore == Store {
-		fmt.Println("goth/gothic: no SESSION_SECRET environment variable is set. The default cookie store is not available and any calls will fail. Ignore this warning if you are using a different store.")
+	sess, err := beginAuth(res, req)
+	if err != nil {
+		return "", err
 	}
+	return sess.GetAuthURL()
+}
 
-	providerName, err := GetProviderName(req)
+/*
+BeginChallengeHandler starts the authentication process with providers whose
+sessions implement goth.ChallengeSession, e.g. WebAuthn, and writes the
+challenge the browser needs to authenticate the user, instead of redirecting.
+It expects to be able to get the name of the provider from the query parameters
+as either "provider" or ":provider".
+*/
+func BeginChallengeHandler(res http.ResponseWriter, req *http.Request) {
+	SetSecurityHeaders(res)
+	sess, err := beginAuth(res, req)
 	if err != nil {
-		return "", err
+		ErrorHandler(res, req, http.StatusBadRequest, err)
+		return
+	}
+
+	cs, ok := sess.(goth.ChallengeSession)
+	if !ok {
+		ErrorHandler(res, req, http.StatusBadRequest, errors.New("provider has no challenge, use BeginAuthHandler"))
+		return
+	}
+
+	contentType, challenge, err := cs.Challenge()
+	if err != nil {
+		ErrorHandler(res, req, http.StatusInternalServerError, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", contentType)
+	res.Header().Set("Cache-Control", "no-store")
+	_, _ = res.Write(challenge)
+}
+
+// beginAuth starts the authentication process with the requested provider,
+// and stores its session.
+func beginAuth(res http.ResponseWriter, req *http.Request) (sess goth.Session, err error) {
+	c := current()
+	start := time.Now()
+	providerName, err := c.GetProviderName(req)
+	_, end := startSpan(req.Context(), OperationBeginAuth, providerName)
+	defer func() {
+		end(err)
+		observe(c, Operation{Name: OperationBeginAuth, Provider: providerName, Request: req, Start: start, Err: err})
+	}()
+	if err != nil {
+		return nil, err
+	}
+	if err = storeErr(); err != nil {
+		return nil, err
 	}
+	warnNoKey()
 
 	provider, err := goth.GetProvider(providerName)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	sess, err := provider.BeginAuth(SetState(req))
+	destination, err := returnTo(c, req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	pp, withParams := provider.(goth.BeginAuthWithParamsProvider)
+	params := req.URL.Query()
+	if withParams && req.Method == http.MethodPost {
+		// forms starting the authentication, e.g. with an email address,
+		// may be posted
+		err = req.ParseForm()
+		if err != nil {
+			return nil, err
+		}
+		params = req.Form
+	}
+	state := c.SetState(req)
+	var started goth.Session
+	err = withTimeout(req.Context(), OperationBeginAuth, false, func(context.Context) error {
+		var s goth.Session
+		var err error
+		if withParams {
+			s, err = pp.BeginAuthWithParams(state, params)
+		} else {
+			s, err = provider.BeginAuth(state)
+		}
+		if err != nil {
+			return err
+		}
+		started = s
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sess = started
 
+	// make sure the session is usable before storing it
 	authURL, err := sess.GetAuthURL()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	err = StoreInSession(providerName, sess.Marshal(), req, res)
-
+	values := bindSession(req)
+	values[providerName] = marshalSession(sess, authURL)
+	if destination != "" {
+		values[returnToKey] = destination
+	}
+	err = storeInSession(c, req, res, values)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-
-	return authURL, err
+	return sess, nil
 }
 
 /*
@@ -191,14 +329,50 @@ process and fetches all the basic information about the user from the provider.
 It expects to be able to get the name of the provider from the query parameters
 as either "provider" or ":provider".
 
+When StepUp requires a second factor from the user, or Anomalies flags the
+login, the user is returned with ErrSecondFactorRequired, and is authenticated
+by VerifySecondFactor.
+
+CompleteUserAuth sets the SecurityHeaders on the response of the callback, and
+waits for the Queue of the provider, when set. The session is saved once,
+before CompleteUserAuth returns: the session of the provider is cleared, and
+replaced by the ID of the session in the Inventory or the user waiting for the
+second factor, when there are. The tokens of the user are given to Tokens,
+when set, see MinimalSession.
+
 See https://github.com/markbates/goth/blob/master/examples/main.go to see this in action.
 */
 var CompleteUserAuth = func(res http.ResponseWriter, req *http.Request) (goth.User, error) {
-	if !keySet && defaultStore == Store {
-		fmt.Println("goth/gothic: no SESSION_SECRET environment variable is set. The default cookie store is not available and any calls will fail. Ignore this warning if you are using a different store.")
+	SetSecurityHeaders(res)
+	start := time.Now()
+	c := current()
+	providerName, _ := c.GetProviderName(req)
+	ctx, end := startSpan(req.Context(), OperationCompleteUserAuth, providerName)
+	update := &sessionUpdate{}
+	user, err := completeQueued(ctx, c, req, providerName, update)
+	if err == nil {
+		if err = saveTokens(ctx, c, user); err != nil {
+			user = goth.User{}
+		}
+	}
+	if err == nil {
+		user, err = authenticated(c, req, user, update)
+	}
+	if saveErr := update.save(c, req, res); saveErr != nil && (err == nil || errors.Is(err, ErrSecondFactorRequired)) {
+		user, err = goth.User{}, saveErr
+	}
+	end(err)
+	observe(c, Operation{Name: OperationCompleteUserAuth, Provider: providerName, Request: req, Start: start, User: user, Err: err})
+	return user, err
+}
+
+func completeUserAuth(ctx context.Context, c *Config, req *http.Request, update *sessionUpdate) (goth.User, error) {
+	if err := storeErr(); err != nil {
+		return goth.User{}, err
 	}
+	warnNoKey()
 
-	providerName, err := GetProviderName(req)
+	providerName, err := c.GetProviderName(req)
 	if err != nil {
 		return goth.User{}, err
 	}
@@ -208,29 +382,43 @@ var CompleteUserAuth = func(res http.ResponseWriter, req *http.Request) (goth.Us
 		return goth.User{}, err
 	}
 
-	value, err := GetFromSession(providerName, req)
+	value, err := getFromSession(c, providerName, req)
+	if err != nil {
+		return goth.User{}, err
+	}
+	// the session of the provider is cleared once the authentication is
+	// complete, or failed
+	update.clear = true
+	err = checkSession(c, req)
 	if err != nil {
 		return goth.User{}, err
 	}
-	defer Logout(res, req)
 	sess, err := provider.UnmarshalSession(value)
 	if err != nil {
 		return goth.User{}, err
 	}
 
-	err = validateState(req, sess)
+	err = validateState(c, req, sess)
 	if err != nil {
 		return goth.User{}, err
 	}
 
-	user, err := provider.FetchUser(sess)
+	// not traced, the user is usually fetched after the token exchange
+	user, err := FetchUser(ctx, provider, sess)
 	if err == nil {
 		// user can be found with existing session data
 		return user, err
 	}
+	if errors.Is(err, ErrContextTimeout) || ctx.Err() != nil {
+		// the provider may still be reading the session, which the token
+		// exchange would write
+		return goth.User{}, err
+	}
 
+	// providers may post the callback (e.g. form_post or a SAML response),
+	// while routers add the provider name to the query
 	params := req.URL.Query()
-	if params.Encode() == "" && req.Method == "POST" {
+	if req.Method == http.MethodPost {
 		err := req.ParseForm()
 		if err != nil {
 			return goth.User{}, err
@@ -239,46 +427,101 @@ var CompleteUserAuth = func(res http.ResponseWriter, req *http.Request) (goth.Us
 	}
 
 	// get new token and retry fetch
-	_, err = sess.Authorize(provider, params)
+	authorizeCtx, end := startSpan(ctx, OperationAuthorize, providerName)
+	cs, honorsContext := sess.(goth.ContextSession)
+	err = withTimeout(authorizeCtx, OperationAuthorize, honorsContext, func(ctx context.Context) error {
+		var err error
+		if honorsContext {
+			_, err = cs.AuthorizeContext(ctx, provider, params)
+		} else {
+			_, err = sess.Authorize(provider, params)
+		}
+		return err
+	})
+	end(err)
 	if err != nil {
 		return goth.User{}, err
 	}
 
-	err = StoreInSession(providerName, sess.Marshal(), req, res)
+	fetchCtx, end := startSpan(ctx, OperationFetchUser, providerName)
+	gu, err := FetchUser(fetchCtx, provider, sess)
+	end(err)
+	return gu, err
+}
 
+func fetchUser(ctx context.Context, provider goth.Provider, sess goth.Session) (goth.User, error) {
+	var user goth.User
+	cp, honorsContext := provider.(goth.ContextProvider)
+	err := withTimeout(ctx, OperationFetchUser, honorsContext, func(ctx context.Context) error {
+		var u goth.User
+		var err error
+		if honorsContext {
+			u, err = cp.FetchUserContext(ctx, sess)
+		} else {
+			u, err = provider.FetchUser(sess)
+		}
+		if err != nil {
+			return err
+		}
+		user = u
+		return nil
+	})
 	if err != nil {
 		return goth.User{}, err
 	}
-
-	gu, err := provider.FetchUser(sess)
-	return gu, err
+	return user, nil
 }
 
 // validateState ensures that the state token param from the original
 // AuthURL matches the one included in the current (callback) request.
-func validateState(req *http.Request, sess goth.Session) error {
+func validateState(c *Config, req *http.Request, sess goth.Session) error {
 	rawAuthURL, err := sess.GetAuthURL()
 	if err != nil {
 		return err
 	}
+	return CheckState(rawAuthURL, c.GetState(req))
+}
 
+// CheckState checks the state of a callback against the state of the auth
+// URL of its session, and returns ErrStateTokenMismatch when they differ.
+// Auth URLs without a state, e.g. of the providers without one, accept any
+// state.
+func CheckState(rawAuthURL, state string) error {
 	authURL, err := url.Parse(rawAuthURL)
 	if err != nil {
 		return err
 	}
 
-	reqState := GetState(req)
-
 	originalState := authURL.Query().Get("state")
-	if originalState != "" && (originalState != reqState) {
-		return errors.New("state token mismatch")
+	if originalState != "" && subtle.ConstantTimeCompare([]byte(originalState), []byte(state)) != 1 {
+		return ErrStateTokenMismatch
 	}
 	return nil
 }
 
-// Logout invalidates a user session.
-func Logout(res http.ResponseWriter, req *http.Request) error {
-	session, err := Store.Get(req, SessionName)
+// Logout invalidates a user session, and removes it from the Inventory.
+func Logout(res http.ResponseWriter, req *http.Request) (err error) {
+	c := current()
+	start := time.Now()
+	defer func() {
+		observe(c, Operation{Name: OperationLogout, Request: req, Start: start, Err: err})
+	}()
+	if c.Inventory != nil {
+		if id, err := getFromSession(c, sessionIDKey, req); err == nil {
+			if err := c.Inventory.Delete(id); err != nil {
+				return err
+			}
+		}
+	}
+	return logout(c, res, req)
+}
+
+// logout clears the session, e.g. once the authentication is complete.
+func logout(c *Config, res http.ResponseWriter, req *http.Request) error {
+	if err := storeErr(); err != nil {
+		return err
+	}
+	session, err := c.Store.Get(req, c.SessionName)
 	if err != nil {
 		return err
 	}
@@ -291,6 +534,22 @@ func Logout(res http.ResponseWriter, req *http.Request) error {
 	return nil
 }
 
+// storeErr returns the error of the SESSION_SECRET store while the sessions
+// would use it.
+func storeErr() error {
+	if configured() != nil || keySet || defaultStore != Store {
+		return nil
+	}
+	return sessionSecretErr
+}
+
+// warnNoKey warns when the default cookie store has no key.
+func warnNoKey() {
+	if configured() == nil && !keySet && defaultStore == Store {
+		fmt.Println("goth/gothic: no SESSION_SECRET environment variable is set. The default cookie store is not available and any calls will fail. Ignore this warning if you are using a different store.")
+	}
+}
+
 // GetProviderName is a function used to get the name of a provider
 // for a given request. By default, this provider is fetched from
 // the URL query string. If you provide it in a different way,
@@ -331,10 +590,9 @@ func getProviderName(req *http.Request) (string, error) {
 	}
 
 	// As a fallback, loop over the used providers, if we already have a valid session for any provider (ie. user has already begun authentication with a provider), then return that provider name
-	providers := goth.GetProviders()
-	session, _ := Store.Get(req, SessionName)
-	for _, provider := range providers {
-		p := provider.Name()
+	store, name := currentSession()
+	session, _ := store.Get(req, name)
+	for _, p := range goth.ProviderNames() {
 		if session.Values == nil {
 			session.Values = make(map[interface{}]interface{})
 		}
@@ -345,7 +603,7 @@ func getProviderName(req *http.Request) (string, error) {
 	}
 
 	// if not found then return an empty string with the corresponding error
-	return "", errors.New("you must select a provider")
+	return "", ErrProviderRequired
 }
 
 // GetContextWithProvider returns a new request context containing the provider
@@ -355,68 +613,215 @@ func GetContextWithProvider(req *http.Request, provider string) *http.Request {
 
 // StoreInSession stores a specified key/value pair in the session.
 func StoreInSession(key string, value string, req *http.Request, res http.ResponseWriter) error {
-	session, _ := Store.New(req, SessionName)
+	return storeInSession(current(), req, res, map[string]string{key: value})
+}
+
+// storeInSession stores the values in the session with a single save, as the
+// stores keeping the session on the client only keep the last one.
+func storeInSession(c *Config, req *http.Request, res http.ResponseWriter, values map[string]string) error {
+	if err := storeErr(); err != nil {
+		return err
+	}
+	session, _ := c.Store.New(req, c.SessionName)
 	if session.Values == nil {
 		session.Values = make(map[interface{}]interface{})
 	}
 
-	if err := updateSessionValue(session, key, value); err != nil {
-		return err
+	for key, value := range values {
+		if err := updateSessionValue(c, session, key, value); err != nil {
+			return err
+		}
 	}
 
-	return session.Save(req, res)
+	return saveSession(req, res, session)
+}
+
+// sessionUpdate is the changes of the session saved once a handler
+// completes, with a single save: the stores keeping the session on the client
+// only keep the last cookie of a response.
+type sessionUpdate struct {
+	// clear removes the values of the session before the values are set, and
+	// deletes the session when none are.
+	clear   bool
+	values  map[string]string
+	deleted []string
+}
+
+// set stores the value in the session.
+func (u *sessionUpdate) set(key, value string) {
+	if u.values == nil {
+		u.values = map[string]string{}
+	}
+	u.values[key] = value
+}
+
+// remove deletes the key from the session.
+func (u *sessionUpdate) remove(key string) {
+	delete(u.values, key)
+	u.deleted = append(u.deleted, key)
+}
+
+// save saves the session once, when it changed.
+func (u *sessionUpdate) save(c *Config, req *http.Request, res http.ResponseWriter) error {
+	if !u.clear && len(u.values) == 0 && len(u.deleted) == 0 {
+		return nil
+	}
+	if u.clear && len(u.values) == 0 {
+		return logout(c, res, req)
+	}
+	session, _ := c.Store.New(req, c.SessionName)
+	if u.clear || session.Values == nil {
+		session.Values = make(map[interface{}]interface{})
+	}
+	for _, key := range u.deleted {
+		delete(session.Values, key)
+	}
+	for key, value := range u.values {
+		if err := updateSessionValue(c, session, key, value); err != nil {
+			return err
+		}
+	}
+	return saveSession(req, res, session)
 }
 
 // GetFromSession retrieves a previously-stored value from the session.
 // If no value has previously been stored at the specified key, it will return an error.
 func GetFromSession(key string, req *http.Request) (string, error) {
-	session, _ := Store.Get(req, SessionName)
-	value, err := getSessionValue(session, key)
+	return getFromSession(current(), key, req)
+}
+
+func getFromSession(c *Config, key string, req *http.Request) (string, error) {
+	if err := storeErr(); err != nil {
+		return "", err
+	}
+	session, _ := c.Store.Get(req, c.SessionName)
+	value, err := getSessionValue(c, session, key)
 	if err != nil {
-		return "", errors.New("could not find a matching session for this request")
+		return "", ErrSessionNotFound
 	}
 
 	return value, nil
 }
 
-func getSessionValue(session *sessions.Session, key string) (string, error) {
+func getSessionValue(c *Config, session *sessions.Session, key string) (string, error) {
 	value := session.Values[key]
 	if value == nil {
 		return "", fmt.Errorf("no session value found for key %s", key)
 	}
+	data, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("the session value for key %s is a %T, not a string", key, value)
+	}
+	return decodeSessionValue(c.Cipher, data)
+}
 
-	rdata := strings.NewReader(value.(string))
-	r, err := gzip.NewReader(rdata)
+func updateSessionValue(c *Config, session *sessions.Session, key, value string) error {
+	data, err := encodeSessionValue(c.Cipher, value)
 	if err != nil {
-		return "", fmt.Errorf("failed to create gzip reader: %w", err)
+		return err
 	}
-	defer func(r *gzip.Reader) {
-		err := r.Close()
-		if err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "getSessionValue threw gzip.Reader .Close() err: %v", err)
-		}
-	}(r)
+	if session.Values == nil {
+		session.Values = make(map[interface{}]interface{})
+	}
+	session.Values[key] = data
+	return nil
+}
 
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, r); err != nil {
-		return "", fmt.Errorf("failed to read gzipped data: %w", err)
+// The gzip writers and readers, and the buffers of the session values, are
+// pooled: a gzip.Writer allocates about a megabyte, and the values are
+// encoded and decoded on every login.
+var (
+	gzipWriters = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+	gzipReaders sync.Pool
+	buffers     = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+)
+
+// maxPooledBuffer is the capacity of the largest buffers returned to the
+// pool, so a large value doesn't keep its buffer.
+const maxPooledBuffer = 64 << 10
+
+func getBuffer() *bytes.Buffer {
+	b := buffers.Get().(*bytes.Buffer)
+	b.Reset()
+	return b
+}
+
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() <= maxPooledBuffer {
+		buffers.Put(b)
 	}
+}
 
-	return buf.String(), nil
+// EncodeSessionValue encodes a value of the session of gothic, e.g. a
+// marshaled provider session: it is gzipped, then encrypted with the Cipher
+// when it is set.
+func EncodeSessionValue(value string) (string, error) {
+	return encodeSessionValue(current().Cipher, value)
 }
 
-func updateSessionValue(session *sessions.Session, key, value string) error {
-	var b bytes.Buffer
-	gz := gzip.NewWriter(&b)
-	if _, err := gz.Write([]byte(value)); err != nil {
-		return fmt.Errorf("failed to write gzipped data: %w", err)
+func encodeSessionValue(cipher StoreCipher, value string) (string, error) {
+	b := getBuffer()
+	defer putBuffer(b)
+	gz := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(gz)
+	gz.Reset(b)
+	if _, err := io.WriteString(gz, value); err != nil {
+		return "", fmt.Errorf("failed to write gzipped data: %w", err)
 	}
 	if err := gz.Close(); err != nil {
-		return fmt.Errorf("failed to close gzip writer: %w", err)
+		return "", fmt.Errorf("failed to close gzip writer: %w", err)
 	}
-	if session.Values == nil {
-		session.Values = make(map[interface{}]interface{})
+	if cipher != nil {
+		ciphertext, err := cipher.Encrypt(b.Bytes())
+		if err != nil {
+			return "", err
+		}
+		return string(ciphertext), nil
 	}
-	session.Values[key] = b.String()
-	return nil
+	return b.String(), nil
+}
+
+// DecodeSessionValue decodes a value of the session of gothic encoded by
+// EncodeSessionValue. The values of tampered or truncated sessions return an
+// error.
+func DecodeSessionValue(data string) (string, error) {
+	return decodeSessionValue(current().Cipher, data)
+}
+
+func decodeSessionValue(cipher StoreCipher, data string) (string, error) {
+	if cipher != nil {
+		plaintext, err := cipher.Decrypt([]byte(data))
+		if err != nil {
+			return "", err
+		}
+		data = string(plaintext)
+	}
+
+	rdata := strings.NewReader(data)
+	r, ok := gzipReaders.Get().(*gzip.Reader)
+	var err error
+	if ok {
+		err = r.Reset(rdata)
+	} else {
+		r, err = gzip.NewReader(rdata)
+	}
+	if err != nil {
+		if r != nil {
+			gzipReaders.Put(r)
+		}
+		return "", fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	// the errors of malformed values are returned by io.Copy, so the reader
+	// isn't closed
+	defer gzipReaders.Put(r)
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	// the JSON of the sessions compresses about three times
+	buf.Grow(3 * len(data))
+	if _, err := io.Copy(buf, r); err != nil {
+		return "", fmt.Errorf("failed to read gzipped data: %w", err)
+	}
+
+	return buf.String(), nil
 }
@@ -3,20 +3,36 @@ package gothic_test
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/pprof"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/andreimerlescu/goth"
 	. "github.com/andreimerlescu/goth/gothic"
 	"github.com/andreimerlescu/goth/providers/faux"
+	"github.com/andreimerlescu/goth/providers/mock"
+	"github.com/andreimerlescu/goth/providers/reddit"
+	"github.com/andreimerlescu/goth/providers/shopify"
+	"github.com/andreimerlescu/goth/saml"
 	"github.com/gorilla/sessions"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
 )
 
 type mapKey struct {
@@ -94,6 +110,82 @@ func Test_BeginAuthHandler(t *testing.T) {
 		fmt.Sprintf(`<a href="%s">Temporary Redirect</a>`, html.EscapeString(au)))
 }
 
+func Test_SecurityHeaders(t *testing.T) {
+	a := assert.New(t)
+
+	res := httptest.NewRecorder()
+	BeginAuthHandler(res, httptest.NewRequest("GET", "/auth?provider=faux", nil))
+	a.Equal(http.StatusTemporaryRedirect, res.Code)
+	a.Equal("no-store", res.Header().Get("Cache-Control"))
+	a.Equal("no-referrer", res.Header().Get("Referrer-Policy"))
+	a.Equal("nosniff", res.Header().Get("X-Content-Type-Options"))
+
+	res = httptest.NewRecorder()
+	BeginAuthHandler(res, httptest.NewRequest("GET", "/auth?provider=unknown", nil))
+	a.Equal(http.StatusBadRequest, res.Code)
+	a.Equal("no-store", res.Header().Get("Cache-Control"))
+
+	// the callback errors too
+	res = httptest.NewRecorder()
+	_, err := CompleteUserAuth(res, httptest.NewRequest("GET", "/auth/callback?provider=faux&code=secret", nil))
+	a.Error(err)
+	a.Equal("no-referrer", res.Header().Get("Referrer-Policy"))
+
+	headers := SecurityHeaders
+	defer func() { SecurityHeaders = headers }()
+	SecurityHeaders = http.Header{"Referrer-Policy": {"same-origin"}}
+	res = httptest.NewRecorder()
+	BeginAuthHandler(res, httptest.NewRequest("GET", "/auth?provider=faux", nil))
+	a.Equal("same-origin", res.Header().Get("Referrer-Policy"))
+	a.Empty(res.Header().Get("Cache-Control"))
+}
+
+func Test_JSONErrors(t *testing.T) {
+	a := assert.New(t)
+
+	// text by default
+	res := httptest.NewRecorder()
+	BeginAuthHandler(res, httptest.NewRequest("GET", "/auth", nil))
+	a.Equal(http.StatusBadRequest, res.Code)
+	a.Equal("you must select a provider\n", res.Body.String())
+
+	JSONErrors = true
+	defer func() { JSONErrors = false }()
+	for accept, wantJSON := range map[string]bool{
+		"":                                   true,
+		"*/*":                                true,
+		"application/json":                   true,
+		"application/json, text/plain;q=0.9": true,
+		"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8": false,
+		"text/plain":           false,
+		"application/json;q=0": false,
+	} {
+		req := httptest.NewRequest("GET", "/auth?provider=unknown", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		res := httptest.NewRecorder()
+		BeginAuthHandler(res, req)
+		a.Equal(http.StatusBadRequest, res.Code)
+		if !wantJSON {
+			a.Equal("no provider for unknown exists\n", res.Body.String(), accept)
+			continue
+		}
+		a.Equal("application/json", res.Header().Get("Content-Type"), accept)
+		a.JSONEq(`{"error":"provider_not_found","status":400,"message":"no provider for unknown exists"}`, res.Body.String(), accept)
+	}
+
+	res = httptest.NewRecorder()
+	MetadataHandler(res, httptest.NewRequest("GET", "/metadata", nil))
+	a.JSONEq(`{"error":"provider_required","status":400,"message":"you must select a provider"}`, res.Body.String())
+
+	res = httptest.NewRecorder()
+	WriteError(res, httptest.NewRequest("GET", "/auth/callback", nil), http.StatusForbidden, &IPMismatchError{Bound: "192.0.2.1", IP: "198.51.100.1"})
+	a.Equal(http.StatusForbidden, res.Code)
+	a.JSONEq(`{"error":"session_mismatch","status":403,"message":"gothic: session bound to 192.0.2.1 used from 198.51.100.1"}`, res.Body.String())
+	a.Equal("internal_server_error", ErrorCode(errors.New("boom"), http.StatusInternalServerError))
+}
+
 func Test_GetAuthURL(t *testing.T) {
 	a := assert.New(t)
 
@@ -125,6 +217,30 @@ func Test_GetAuthURL(t *testing.T) {
 	a.NotEqual(parsed.Query().Get("state"), parsed2.Query().Get("state"))
 }
 
+func Test_GetAuthURLWithParams(t *testing.T) {
+	a := assert.New(t)
+
+	goth.UseProviders(shopify.New("key", "secret", "/foo"))
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=shopify&shop=some-shop.myshopify.com", nil)
+	a.NoError(err)
+
+	u, err := GetAuthURL(res, req)
+	a.NoError(err)
+	a.Contains(u, "https://some-shop.myshopify.com/admin/oauth/authorize")
+
+	// the parameters of forms posted to start the authentication are used too
+	form := url.Values{"shop": {"other-shop.myshopify.com"}}
+	req, err = http.NewRequest("POST", "/auth?provider=shopify", strings.NewReader(form.Encode()))
+	a.NoError(err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	u, err = GetAuthURL(httptest.NewRecorder(), req)
+	a.NoError(err)
+	a.Contains(u, "https://other-shop.myshopify.com/admin/oauth/authorize")
+}
+
 func Test_CompleteUserAuth(t *testing.T) {
 	a := assert.New(t)
 
@@ -244,11 +360,28 @@ func Test_StateValidation(t *testing.T) {
 	_, err = CompleteUserAuth(res, req)
 	a.NoError(err)
 
-	// Assert that mismatched states will return an error
+	// Assert that mismatched states will return an error, with a new
+	// session as the completed one was cleared
+	req, _ = http.NewRequest("GET", "/auth?provider=faux&state=state_REAL", nil)
+	BeginAuthHandler(res, req)
+	session, _ = Store.Get(req, SessionName)
 	req, _ = http.NewRequest("GET", "/auth/callback?provider=faux&state=state_FAKE", nil)
 	session.Save(req, res)
 	_, err = CompleteUserAuth(res, req)
-	a.Error(err)
+	a.True(err == ErrStateTokenMismatch)
+}
+
+func Test_SentinelErrors(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := GetProviderName(httptest.NewRequest("GET", "/auth", nil))
+	a.True(err == ErrProviderRequired)
+
+	_, err = GetFromSession("faux", httptest.NewRequest("GET", "/auth/callback?provider=faux", nil))
+	a.True(err == ErrSessionNotFound)
+
+	_, err = CompleteUserAuth(httptest.NewRecorder(), httptest.NewRequest("GET", "/auth/callback?provider=faux", nil))
+	a.True(err == ErrSessionNotFound)
 }
 
 func Test_AppleStateValidation(t *testing.T) {
@@ -261,6 +394,1783 @@ func Test_AppleStateValidation(t *testing.T) {
 	a.Equal(appleStateValue, GetState(req))
 }
 
+func Test_PostStateWithProviderQuery(t *testing.T) {
+	a := assert.New(t)
+	form := url.Values{}
+	form.Add("state", "state_REAL")
+	req, _ := http.NewRequest(http.MethodPost, "/auth/callback?provider=faux", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	a.Equal("state_REAL", GetState(req))
+}
+
+func Test_MetadataHandler(t *testing.T) {
+	a := assert.New(t)
+
+	goth.UseProviders(saml.New("https://sp.example.com", "https://sp.example.com/auth/saml/callback", &saml.IdPMetadata{}))
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/metadata?provider=saml", nil)
+	a.NoError(err)
+
+	MetadataHandler(res, req)
+	a.Equal(http.StatusOK, res.Code)
+	a.Equal(saml.MetadataContentType, res.Header().Get("Content-Type"))
+	a.Contains(res.Body.String(), `entityID="https://sp.example.com"`)
+
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/auth/metadata?provider=faux", nil)
+	a.NoError(err)
+
+	MetadataHandler(res, req)
+	a.Equal(http.StatusNotFound, res.Code)
+}
+
+func Test_BeginChallengeHandler(t *testing.T) {
+	a := assert.New(t)
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/challenge?provider=faux", nil)
+	a.NoError(err)
+
+	BeginChallengeHandler(res, req)
+	a.Equal(http.StatusBadRequest, res.Code)
+}
+
+type codeFactor struct {
+	required bool
+	code     string
+}
+
+func (f codeFactor) Required(user goth.User) (bool, error) {
+	return f.required, nil
+}
+
+func (f codeFactor) Verify(user goth.User, code string) error {
+	if code != f.code {
+		return errors.New("invalid code")
+	}
+	return nil
+}
+
+// copySession carries the gothic session to the next request.
+func copySession(from, to *http.Request) {
+	session, _ := Store.Get(from, SessionName)
+	next, _ := Store.New(to, SessionName)
+	next.Values = session.Values
+}
+
+func Test_StoreInSession_TooLarge(t *testing.T) {
+	a := assert.New(t)
+	store := Store
+	Store = sessions.NewCookieStore([]byte("test-session-key"))
+	defer func() { Store, MaxCookieSize = store, 4096 }()
+
+	// random values don't compress
+	value := func(n int) string {
+		b := make([]byte, n)
+		_, _ = rand.Read(b)
+		return hex.EncodeToString(b)
+	}
+
+	res := httptest.NewRecorder()
+	a.NoError(StoreInSession("small", value(100), httptest.NewRequest("GET", "/", nil), res))
+	a.Len(res.Result().Cookies(), 1)
+
+	// refused by securecookie
+	res = httptest.NewRecorder()
+	err := StoreInSession("large", value(3000), httptest.NewRequest("GET", "/", nil), res)
+	a.ErrorIs(err, ErrSessionTooLarge)
+	var tooLarge *SessionTooLargeError
+	a.True(errors.As(err, &tooLarge))
+	a.Equal("large", tooLarge.Key)
+	a.Greater(tooLarge.Size, 3000)
+	a.Equal(4096, tooLarge.Max)
+	a.Empty(res.Result().Cookies())
+	a.Equal("session_too_large", ErrorCode(err, http.StatusInternalServerError))
+
+	// above MaxCookieSize
+	MaxCookieSize = 1000
+	res = httptest.NewRecorder()
+	err = StoreInSession("large", value(1000), httptest.NewRequest("GET", "/", nil), res)
+	a.True(errors.As(err, &tooLarge))
+	a.Equal("large", tooLarge.Key)
+	a.Greater(tooLarge.Size, 1000)
+	a.Empty(res.Result().Cookies())
+
+	// the cookies of the other stores are checked too
+	Store = wrappedStore{sessions.NewCookieStore([]byte("test-session-key"))}
+	res = httptest.NewRecorder()
+	err = StoreInSession("large", value(1000), httptest.NewRequest("GET", "/", nil), res)
+	a.True(errors.As(err, &tooLarge))
+	a.Empty(res.Result().Cookies())
+	MaxCookieSize = 4096
+	err = StoreInSession("large", value(3000), httptest.NewRequest("GET", "/", nil), res)
+	a.True(errors.As(err, &tooLarge))
+	a.Greater(tooLarge.Size, 4096)
+
+	// the server-side stores only write the ID of the session in the cookie
+	fsStore := sessions.NewFilesystemStore(t.TempDir(), []byte("test-session-key"))
+	fsStore.MaxLength(0)
+	Store = fsStore
+	a.NoError(StoreInSession("large", value(3000), httptest.NewRequest("GET", "/", nil), httptest.NewRecorder()))
+
+	MaxCookieSize = 0
+	Store = sessions.NewCookieStore([]byte("test-session-key"))
+	a.NoError(StoreInSession("large", value(1000), httptest.NewRequest("GET", "/", nil), httptest.NewRecorder()))
+}
+
+// wrappedStore is a store saving its sessions with a cookie store, e.g. to
+// instrument it.
+type wrappedStore struct {
+	*sessions.CookieStore
+}
+
+func (s wrappedStore) Get(req *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(req).Get(s, name)
+}
+
+func (s wrappedStore) New(req *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+	return session, nil
+}
+
+// cookieLogin begins the authentication with a cookie store, and returns the
+// callback request carrying the cookie of the session.
+func cookieLogin(t *testing.T) *http.Request {
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/auth?provider=faux", nil)
+	authURL, err := GetAuthURL(res, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	callback := httptest.NewRequest("GET", "/auth/callback?provider=faux&state="+u.Query().Get("state"), nil)
+	for _, c := range res.Result().Cookies() {
+		callback.AddCookie(c)
+	}
+	return callback
+}
+
+func Test_CompleteUserAuth_SingleSessionWrite(t *testing.T) {
+	a := assert.New(t)
+	store := Store
+	Store = sessions.NewCookieStore([]byte("test-session-key"))
+	defer func() { Store, Inventory, StepUp = store, nil, nil }()
+
+	// the session of the provider is deleted
+	res := httptest.NewRecorder()
+	_, err := CompleteUserAuth(res, cookieLogin(t))
+	a.NoError(err)
+	cookies := res.Result().Cookies()
+	a.Len(cookies, 1)
+	a.True(cookies[0].MaxAge < 0)
+
+	// the failed authentications delete it too
+	callback := cookieLogin(t)
+	callback.URL.RawQuery = "provider=faux&state=other"
+	res = httptest.NewRecorder()
+	_, err = CompleteUserAuth(res, callback)
+	a.Equal(ErrStateTokenMismatch, err)
+	cookies = res.Result().Cookies()
+	a.Len(cookies, 1)
+	a.True(cookies[0].MaxAge < 0)
+
+	// it is replaced by the session of the Inventory
+	Inventory = NewMemoryInventory()
+	callback = cookieLogin(t)
+	res = httptest.NewRecorder()
+	_, err = CompleteUserAuth(res, callback)
+	a.NoError(err)
+	cookies = res.Result().Cookies()
+	a.Len(cookies, 1)
+	a.True(cookies[0].MaxAge > 0)
+	next := httptest.NewRequest("GET", "/", nil)
+	next.AddCookie(cookies[0])
+	_, err = CurrentSession(next)
+	a.NoError(err)
+	_, err = GetFromSession("faux", next)
+	a.Equal(ErrSessionNotFound, err)
+
+	// or by the user waiting for the second factor, whose verification
+	// writes the session once too
+	StepUp = codeFactor{required: true, code: "123456"}
+	res = httptest.NewRecorder()
+	_, err = CompleteUserAuth(res, cookieLogin(t))
+	a.Equal(ErrSecondFactorRequired, err)
+	cookies = res.Result().Cookies()
+	a.Len(cookies, 1)
+	verify := httptest.NewRequest("GET", "/auth/verify?code=123456", nil)
+	verify.AddCookie(cookies[0])
+	res = httptest.NewRecorder()
+	_, err = VerifySecondFactor(res, verify)
+	a.NoError(err)
+	cookies = res.Result().Cookies()
+	a.Len(cookies, 1)
+	next = httptest.NewRequest("GET", "/", nil)
+	next.AddCookie(cookies[0])
+	_, err = CurrentSession(next)
+	a.NoError(err)
+}
+
+func Test_CompleteUserAuthWithStepUp(t *testing.T) {
+	a := assert.New(t)
+	StepUp = codeFactor{required: true, code: "123456"}
+	defer func() { StepUp = nil }()
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+
+	sess := faux.Session{Name: "Homer Simpson", Email: "homer@example.com", AccessToken: "1234567890"}
+	session, _ := Store.Get(req, SessionName)
+	session.Values["faux"] = gzipString(sess.Marshal())
+	err = session.Save(req, res)
+	a.NoError(err)
+
+	user, err := CompleteUserAuth(res, req)
+	a.Equal(ErrSecondFactorRequired, err)
+	a.Equal("Homer Simpson", user.Name)
+
+	// the provider session is gone, the user waits for the second factor
+	session, _ = Store.Get(req, SessionName)
+	a.Nil(session.Values["faux"])
+
+	waiting, err := SecondFactorUser(req)
+	a.NoError(err)
+	a.Equal("homer@example.com", waiting.Email)
+
+	verify, err := http.NewRequest("GET", "/auth/verify?code=000000", nil)
+	a.NoError(err)
+	copySession(req, verify)
+	_, err = VerifySecondFactor(res, verify)
+	a.True(errors.Is(err, ErrSecondFactorFailed))
+
+	next, err := http.NewRequest("GET", "/auth/verify?code=123456", nil)
+	a.NoError(err)
+	copySession(verify, next)
+	user, err = VerifySecondFactor(res, next)
+	a.NoError(err)
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("1234567890", user.AccessToken)
+
+	// the user can only be verified once
+	_, err = VerifySecondFactor(res, next)
+	a.Error(err)
+}
+
+func Test_CompleteUserAuthWithStepUpNotRequired(t *testing.T) {
+	a := assert.New(t)
+	StepUp = codeFactor{required: false}
+	defer func() { StepUp = nil }()
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+
+	sess := faux.Session{Name: "Homer Simpson", Email: "homer@example.com", AccessToken: "1234567890"}
+	session, _ := Store.Get(req, SessionName)
+	session.Values["faux"] = gzipString(sess.Marshal())
+	err = session.Save(req, res)
+	a.NoError(err)
+
+	user, err := CompleteUserAuth(res, req)
+	a.NoError(err)
+	a.Equal("Homer Simpson", user.Name)
+
+	_, err = SecondFactorUser(req)
+	a.Error(err)
+}
+
+func Test_VerifySecondFactorAttempts(t *testing.T) {
+	a := assert.New(t)
+	StepUp = codeFactor{required: true, code: "123456"}
+	defer func() { StepUp = nil }()
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+
+	sess := faux.Session{Name: "Homer Simpson", AccessToken: "1234567890"}
+	session, _ := Store.Get(req, SessionName)
+	session.Values["faux"] = gzipString(sess.Marshal())
+	err = session.Save(req, res)
+	a.NoError(err)
+
+	_, err = CompleteUserAuth(res, req)
+	a.Equal(ErrSecondFactorRequired, err)
+
+	for i := 0; i < StepUpAttempts; i++ {
+		next, err := http.NewRequest("GET", "/auth/verify?code=000000", nil)
+		a.NoError(err)
+		copySession(req, next)
+		req = next
+		_, err = VerifySecondFactor(res, req)
+		a.True(errors.Is(err, ErrSecondFactorFailed))
+	}
+
+	next, err := http.NewRequest("GET", "/auth/verify?code=123456", nil)
+	a.NoError(err)
+	copySession(req, next)
+	_, err = VerifySecondFactor(res, next)
+	a.Error(err)
+	a.False(errors.Is(err, ErrSecondFactorFailed))
+}
+
+func Test_Clock(t *testing.T) {
+	a := assert.New(t)
+	clock, rand := goth.DefaultClock, goth.Rand
+	defer func() { goth.DefaultClock, goth.Rand = clock, rand }()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	goth.DefaultClock = goth.ClockFunc(func() time.Time { return now })
+	goth.Rand = bytes.NewReader(bytes.Repeat([]byte{1}, 64))
+
+	// the state is read from goth.Rand
+	a.Equal(strings.Repeat("AQEB", 21)+"AQ==", SetState(httptest.NewRequest("GET", "/auth?provider=faux", nil)))
+	goth.Rand = bytes.NewReader(nil)
+	a.Panics(func() { SetState(httptest.NewRequest("GET", "/auth?provider=faux", nil)) })
+
+	// the second factor expires with goth.DefaultClock
+	StepUp = codeFactor{required: true, code: "123456"}
+	defer func() { StepUp = nil }()
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	session, _ := Store.Get(req, SessionName)
+	session.Values["faux"] = gzipString((&faux.Session{Name: "Homer Simpson", AccessToken: "1234567890"}).Marshal())
+	a.NoError(session.Save(req, res))
+	_, err := CompleteUserAuth(res, req)
+	a.Equal(ErrSecondFactorRequired, err)
+
+	now = now.Add(StepUpTimeout + time.Second)
+	verify := httptest.NewRequest("GET", "/auth/verify?code=123456", nil)
+	copySession(req, verify)
+	_, err = VerifySecondFactor(res, verify)
+	a.True(errors.Is(err, ErrSecondFactorExpired))
+}
+
+func Test_Observers(t *testing.T) {
+	a := assert.New(t)
+	var operations []Operation
+	Observers = []Observer{func(op Operation) { operations = append(operations, op) }}
+	defer func() { Observers = nil }()
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=faux", nil)
+	a.NoError(err)
+	_, err = GetAuthURL(res, req)
+	a.NoError(err)
+
+	callback, err := http.NewRequest("GET", "/auth/callback?provider=faux&state=other", nil)
+	a.NoError(err)
+	copySession(req, callback)
+	_, err = CompleteUserAuth(res, callback)
+	a.Equal(ErrStateTokenMismatch, err)
+
+	_, err = GetAuthURL(res, httptest.NewRequest("GET", "/auth", nil))
+	a.Equal(ErrProviderRequired, err)
+
+	a.Len(operations, 3)
+	a.Equal(OperationBeginAuth, operations[0].Name)
+	a.Equal("faux", operations[0].Provider)
+	a.NoError(operations[0].Err)
+	a.Equal(req, operations[0].Request)
+	a.Equal(OperationCompleteUserAuth, operations[1].Name)
+	a.Equal("faux", operations[1].Provider)
+	a.Equal(ErrStateTokenMismatch, operations[1].Err)
+	a.Equal(OperationBeginAuth, operations[2].Name)
+	a.Empty(operations[2].Provider)
+	a.Equal(ErrProviderRequired, operations[2].Err)
+
+	a.NoError(Logout(res, req))
+	a.Len(operations, 4)
+	a.Equal(OperationLogout, operations[3].Name)
+	a.Equal("faux", operations[3].Provider)
+}
+
+func Test_Subscribe(t *testing.T) {
+	a := assert.New(t)
+	var provisioned, notified []string
+	var failures []error
+	unsubscribe := Subscribe(EventAuthCompleted, func(event Event) { provisioned = append(provisioned, event.User.Email) })
+	defer unsubscribe()
+	unsubscribeNotifications := Subscribe(EventAuthCompleted, func(event Event) { notified = append(notified, event.User.Email) })
+	defer Subscribe(EventAuthFailed, func(event Event) { failures = append(failures, event.Err) })()
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+	sess := faux.Session{Name: "Homer Simpson", Email: "homer@example.com"}
+	session, _ := Store.Get(req, SessionName)
+	session.Values["faux"] = gzipString(sess.Marshal())
+	a.NoError(session.Save(req, res))
+	_, err = CompleteUserAuth(res, req)
+	a.NoError(err)
+
+	unsubscribeNotifications()
+	unsubscribeNotifications()
+	other, err := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+	session, _ = Store.Get(other, SessionName)
+	session.Values["faux"] = gzipString((&faux.Session{Email: "marge@example.com"}).Marshal())
+	a.NoError(session.Save(other, res))
+	_, err = CompleteUserAuth(res, other)
+	a.NoError(err)
+
+	_, err = CompleteUserAuth(res, httptest.NewRequest("GET", "/auth/callback", nil))
+	a.Equal(ErrProviderRequired, err)
+
+	a.Equal([]string{"homer@example.com", "marge@example.com"}, provisioned)
+	a.Equal([]string{"homer@example.com"}, notified)
+	a.Equal([]error{ErrProviderRequired}, failures)
+}
+
+type panicProvider struct {
+	faux.Provider
+}
+
+func (p *panicProvider) Name() string {
+	return "panicky"
+}
+
+func (p *panicProvider) BeginAuth(state string) (goth.Session, error) {
+	var values map[string]string
+	values[state] = state
+	return nil, nil
+}
+
+func Test_Recover(t *testing.T) {
+	a := assert.New(t)
+	goth.UseProviders(&panicProvider{})
+	defer goth.UnregisterProviders("panicky")
+
+	var panics []Event
+	defer Subscribe(EventPanic, func(event Event) { panics = append(panics, event) })()
+
+	handler := RecoverFunc(BeginAuthHandler)
+	res := httptest.NewRecorder()
+	handler(res, httptest.NewRequest("GET", "/auth?provider=panicky", nil))
+	a.Equal(http.StatusInternalServerError, res.Code)
+	a.Contains(res.Body.String(), "gothic: panic recovered: assignment to entry in nil map")
+
+	a.Len(panics, 1)
+	a.Equal("panicky", panics[0].Provider)
+	a.True(errors.Is(panics[0].Err, ErrPanic))
+	var panicErr *PanicError
+	a.True(errors.As(panics[0].Err, &panicErr))
+	a.Contains(string(panicErr.Stack), "BeginAuth")
+	var runtimeErr runtime.Error
+	a.True(errors.As(panics[0].Err, &runtimeErr))
+
+	var handled error
+	ErrorHandler = func(res http.ResponseWriter, req *http.Request, status int, err error) {
+		handled = err
+		res.WriteHeader(status)
+	}
+	defer func() { ErrorHandler = WriteError }()
+	res = httptest.NewRecorder()
+	Recover(http.HandlerFunc(BeginAuthHandler)).ServeHTTP(res, httptest.NewRequest("GET", "/auth?provider=panicky", nil))
+	a.Equal(http.StatusInternalServerError, res.Code)
+	a.True(errors.Is(handled, ErrPanic))
+
+	// the handlers of gothic write their errors with the ErrorHandler too
+	res = httptest.NewRecorder()
+	BeginAuthHandler(res, httptest.NewRequest("GET", "/auth", nil))
+	a.Equal(ErrProviderRequired, handled)
+
+	a.Panics(func() {
+		Recover(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic(http.ErrAbortHandler)
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	})
+}
+
+func Test_BindIP(t *testing.T) {
+	a := assert.New(t)
+	BindIP = IPBindingPrefix
+	defer func() { BindIP = IPBindingNone }()
+
+	begin := func(remoteAddr string) (*http.Request, string) {
+		req := httptest.NewRequest("GET", "/auth?provider=faux", nil)
+		req.RemoteAddr = remoteAddr
+		authURL, err := GetAuthURL(httptest.NewRecorder(), req)
+		a.NoError(err)
+		u, _ := url.Parse(authURL)
+		return req, u.Query().Get("state")
+	}
+	callback := func(req *http.Request, state, remoteAddr string) *http.Request {
+		next := httptest.NewRequest("GET", "/auth/callback?provider=faux&state="+state, nil)
+		next.RemoteAddr = remoteAddr
+		copySession(req, next)
+		return next
+	}
+
+	// clients may roam within the prefix
+	req, state := begin("192.0.2.1:1234")
+	_, err := CompleteUserAuth(httptest.NewRecorder(), callback(req, state, "192.0.2.77:4321"))
+	a.NoError(err)
+
+	req, state = begin("192.0.2.1:1234")
+	_, err = CompleteUserAuth(httptest.NewRecorder(), callback(req, state, "198.51.100.1:1234"))
+	a.True(errors.Is(err, ErrSessionIPMismatch))
+	var mismatch *IPMismatchError
+	a.True(errors.As(err, &mismatch))
+	a.Equal("192.0.2.0/24", mismatch.Bound)
+	a.Equal("198.51.100.1", mismatch.IP)
+
+	BindIP = IPBindingExact
+	req, state = begin("[2001:db8::1]:1234")
+	_, err = CompleteUserAuth(httptest.NewRecorder(), callback(req, state, "[2001:db8::2]:1234"))
+	a.True(errors.Is(err, ErrSessionIPMismatch))
+
+	// the user waiting for the second factor is bound too
+	StepUp = codeFactor{required: true, code: "123456"}
+	defer func() { StepUp = nil }()
+	req, state = begin("192.0.2.1:1234")
+	next := callback(req, state, "192.0.2.1:1234")
+	_, err = CompleteUserAuth(httptest.NewRecorder(), next)
+	a.Equal(ErrSecondFactorRequired, err)
+	verify := httptest.NewRequest("GET", "/auth/verify?code=123456", nil)
+	verify.RemoteAddr = "192.0.2.2:1234"
+	copySession(next, verify)
+	_, err = SecondFactorUser(verify)
+	a.True(errors.Is(err, ErrSessionIPMismatch))
+	_, err = VerifySecondFactor(httptest.NewRecorder(), verify)
+	a.True(errors.Is(err, ErrSessionIPMismatch))
+	verify.RemoteAddr = "192.0.2.1:1234"
+	_, err = VerifySecondFactor(httptest.NewRecorder(), verify)
+	a.NoError(err)
+}
+
+func Test_Anomalies(t *testing.T) {
+	a := assert.New(t)
+	Users = NewMemoryUserStore()
+	Anomalies = NewDevice
+	defer func() { Users, Anomalies = nil, nil }()
+
+	complete := func(userAgent string) (*http.Request, error) {
+		req := httptest.NewRequest("GET", "/auth?provider=faux", nil)
+		authURL, err := GetAuthURL(httptest.NewRecorder(), req)
+		a.NoError(err)
+		u, _ := url.Parse(authURL)
+		callback := httptest.NewRequest("GET", "/auth/callback?provider=faux&state="+u.Query().Get("state"), nil)
+		callback.Header.Set("User-Agent", userAgent)
+		copySession(req, callback)
+		_, err = CompleteUserAuth(httptest.NewRecorder(), callback)
+		return callback, err
+	}
+
+	// the first login is saved
+	_, err := complete("Mozilla/5.0")
+	a.NoError(err)
+	login, err := Users.LastLogin("faux", "id")
+	a.NoError(err)
+	a.Equal("Mozilla/5.0", login.UserAgent)
+	a.Equal("192.0.2.1", login.IP)
+	_, err = complete("Mozilla/5.0")
+	a.NoError(err)
+
+	// logins from new devices fail without a second factor
+	_, err = complete("curl/8.0")
+	a.Equal(ErrAnomalousLogin, err)
+
+	// and require it otherwise, even when it isn't required from the user
+	StepUp = codeFactor{required: false, code: "123456"}
+	defer func() { StepUp = nil }()
+	req, err := complete("curl/8.0")
+	a.Equal(ErrSecondFactorRequired, err)
+	verify := httptest.NewRequest("GET", "/auth/verify?code=123456", nil)
+	verify.Header.Set("User-Agent", "curl/8.0")
+	copySession(req, verify)
+	_, err = VerifySecondFactor(httptest.NewRecorder(), verify)
+	a.NoError(err)
+	login, _ = Users.LastLogin("faux", "id")
+	a.Equal("curl/8.0", login.UserAgent)
+	_, err = complete("curl/8.0")
+	a.NoError(err)
+}
+
+func Test_ImpossibleTravel(t *testing.T) {
+	a := assert.New(t)
+	locations := map[string][2]float64{
+		"192.0.2.1":    {48.8566, 2.3522},   // Paris
+		"198.51.100.1": {51.5074, -0.1278},  // London
+		"203.0.113.1":  {35.6762, 139.6503}, // Tokyo
+	}
+	travel := ImpossibleTravel{Locate: func(ip string) (float64, float64, error) {
+		l, ok := locations[ip]
+		if !ok {
+			return 0, 0, errors.New("unknown location")
+		}
+		return l[0], l[1], nil
+	}}
+
+	start := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	previous := &Login{IP: "192.0.2.1", Time: start}
+	for ip, want := range map[string]bool{
+		"192.0.2.1":    false,
+		"198.51.100.1": false,
+		"203.0.113.1":  true,
+		"192.0.2.99":   false,
+	} {
+		anomalous, err := travel.Detect(nil, &Login{IP: ip, Time: start.Add(2 * time.Hour)}, previous)
+		a.NoError(err)
+		a.Equal(want, anomalous, ip)
+	}
+	anomalous, _ := travel.Detect(nil, &Login{IP: "203.0.113.1", Time: start.Add(12 * time.Hour)}, previous)
+	a.False(anomalous)
+	anomalous, _ = travel.Detect(nil, &Login{IP: "203.0.113.1", Time: start}, nil)
+	a.False(anomalous)
+
+	anomalous, _ = AnyAnomaly(NewDevice, travel).Detect(nil, &Login{IP: "203.0.113.1", Time: start}, previous)
+	a.True(anomalous)
+}
+
+func Test_Queue(t *testing.T) {
+	a := assert.New(t)
+	q := NewLoginQueue(2, 1, 50*time.Millisecond)
+	ctx := context.Background()
+	shopify := mock.New()
+	shopify.SetName("shopify")
+	goth.UseProviders(shopify)
+	defer goth.UnregisterProviders("shopify")
+
+	// the names of the providers which aren't used get no queue
+	_, err := q.Acquire(ctx, "random-name")
+	a.ErrorIs(err, goth.ErrProviderNotFound)
+
+	first, err := q.Acquire(ctx, "faux")
+	a.NoError(err)
+	second, err := q.Acquire(ctx, "faux")
+	a.NoError(err)
+	// the other providers have their own limit
+	other, err := q.Acquire(ctx, "shopify")
+	a.NoError(err)
+	other()
+
+	// the third login waits for a login to complete
+	acquired := make(chan error)
+	go func() {
+		release, err := q.Acquire(ctx, "faux")
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	// while the queue is full
+	_, err = q.Acquire(ctx, "faux")
+	a.Equal(ErrProviderBusy, err)
+	first()
+	a.NoError(<-acquired)
+
+	// and times out
+	_, err = q.Acquire(ctx, "faux")
+	a.NoError(err)
+	_, err = q.Acquire(ctx, "faux")
+	a.Equal(ErrProviderBusy, err)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = q.Acquire(canceled, "faux")
+	a.Equal(context.Canceled, err)
+	second()
+
+	Queue = NewLoginQueue(1, 0, 0)
+	defer func() { Queue = nil }()
+	release, _ := Queue.Acquire(ctx, "faux")
+	_, err = CompleteUserAuth(httptest.NewRecorder(), httptest.NewRequest("GET", "/auth/callback?provider=faux", nil))
+	a.Equal(ErrProviderBusy, err)
+	release()
+}
+
+func Test_Cipher(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := NewAESCipher()
+	a.Error(err)
+	_, err = NewAESCipher([]byte("short"))
+	a.Error(err)
+
+	old, err := NewAESCipher([]byte("an-old-key-of-32-bytes-for-aes!!"))
+	a.NoError(err)
+	Cipher = old
+	defer func() { Cipher = nil }()
+
+	req := httptest.NewRequest("GET", "/auth?provider=faux", nil)
+	authURL, err := GetAuthURL(httptest.NewRecorder(), req)
+	a.NoError(err)
+	u, _ := url.Parse(authURL)
+
+	// the values are encrypted before the store persists them
+	session, _ := Store.Get(req, SessionName)
+	value := session.Values["faux"].(string)
+	a.False(strings.HasPrefix(value, "\x1f\x8b"))
+	ciphertext := []byte(value)
+	ciphertext[len(ciphertext)-1] ^= 1
+	_, err = old.Decrypt(ciphertext)
+	a.Equal(ErrSessionDecryption, err)
+
+	// and read with the rotated keys
+	Cipher, err = NewAESCipher([]byte("a-new-key-of-32-bytes-for-aes!!!"), []byte("an-old-key-of-32-bytes-for-aes!!"))
+	a.NoError(err)
+	callback := httptest.NewRequest("GET", "/auth/callback?provider=faux&state="+u.Query().Get("state"), nil)
+	copySession(req, callback)
+	_, err = CompleteUserAuth(httptest.NewRecorder(), callback)
+	a.NoError(err)
+
+	// without the key, the session can't be read
+	req = httptest.NewRequest("GET", "/auth?provider=faux", nil)
+	_, err = GetAuthURL(httptest.NewRecorder(), req)
+	a.NoError(err)
+	Cipher = old
+	_, err = GetFromSession("faux", req)
+	a.Equal(ErrSessionNotFound, err)
+}
+
+// healthProvider is a provider with an endpoint, or an authentication URL
+// without it.
+type healthProvider struct {
+	faux.Provider
+	name, endpoint, authURL string
+}
+
+func (p *healthProvider) Name() string {
+	return p.name
+}
+
+func (p *healthProvider) BeginAuth(state string) (goth.Session, error) {
+	return &faux.Session{AuthURL: p.authURL}, nil
+}
+
+type endpointProvider struct {
+	healthProvider
+}
+
+func (p *endpointProvider) HealthEndpoint() string {
+	return p.endpoint
+}
+
+func Test_ProvidersHealthHandler(t *testing.T) {
+	a := assert.New(t)
+	defer func() {
+		goth.ClearProviders()
+		goth.UseProviders(fauxProvider)
+	}()
+
+	var mu sync.Mutex
+	requests := 0
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		switch req.URL.Path {
+		case "/token":
+			res.WriteHeader(http.StatusMethodNotAllowed)
+		case "/":
+			http.Redirect(res, req, "/login", http.StatusFound)
+		default:
+			res.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer ts.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	goth.ClearProviders()
+	goth.UseProviders(
+		&endpointProvider{healthProvider{name: "oidc", endpoint: ts.URL + "/token"}},
+		&endpointProvider{healthProvider{name: "failing", endpoint: ts.URL + "/failing"}},
+		&endpointProvider{healthProvider{name: "unreachable", endpoint: closed.URL}},
+		&healthProvider{name: "oauth2", authURL: ts.URL + "/authorize?client_id=id"},
+		&healthProvider{name: "ldap", authURL: "/ldap/login"},
+	)
+	goth.UseProviderFactory("lazy", func() (goth.Provider, error) {
+		return nil, errors.New("discovery unreachable")
+	})
+
+	res := httptest.NewRecorder()
+	ProvidersHealthHandler(res, httptest.NewRequest("GET", "/health/providers", nil))
+	a.Equal(http.StatusServiceUnavailable, res.Code)
+	var report HealthReport
+	a.NoError(json.NewDecoder(res.Body).Decode(&report))
+	a.Equal("degraded", report.Status)
+	a.Equal(HealthUp, report.Providers["oidc"].Status)
+	a.Equal(ts.URL+"/token", report.Providers["oidc"].Endpoint)
+	a.Equal(HealthDown, report.Providers["failing"].Status)
+	a.Equal("responded with a 502", report.Providers["failing"].Error)
+	a.Equal(HealthDown, report.Providers["unreachable"].Status)
+	a.Equal(HealthUp, report.Providers["oauth2"].Status)
+	a.Equal(ts.URL+"/", report.Providers["oauth2"].Endpoint)
+	a.Equal(HealthUnknown, report.Providers["ldap"].Status)
+	// the providers of the factories are checked too
+	a.Equal(HealthDown, report.Providers["lazy"].Status)
+	a.Contains(report.Providers["lazy"].Error, "discovery unreachable")
+	a.Equal(3, count())
+
+	// the health is cached
+	CheckProviders(context.Background())
+	a.Equal(3, count())
+
+	interval := HealthCheckInterval
+	HealthCheckInterval = 0
+	defer func() { HealthCheckInterval = interval }()
+	goth.ClearProviders()
+	goth.UseProviders(&endpointProvider{healthProvider{name: "oidc", endpoint: ts.URL + "/token"}})
+	res = httptest.NewRecorder()
+	ProvidersHealthHandler(res, httptest.NewRequest("GET", "/health/providers", nil))
+	a.Equal(http.StatusOK, res.Code)
+	a.Equal(4, count())
+}
+
+func Test_Inventory(t *testing.T) {
+	a := assert.New(t)
+	_, err := ActiveSessions("faux", "id")
+	a.Error(err)
+
+	Inventory = NewMemoryInventory()
+	defer func() { Inventory = nil }()
+
+	login := func(userAgent string) *http.Request {
+		req := httptest.NewRequest("GET", "/auth?provider=faux", nil)
+		authURL, err := GetAuthURL(httptest.NewRecorder(), req)
+		a.NoError(err)
+		u, _ := url.Parse(authURL)
+		callback := httptest.NewRequest("GET", "/auth/callback?provider=faux&state="+u.Query().Get("state"), nil)
+		callback.Header.Set("User-Agent", userAgent)
+		copySession(req, callback)
+		_, err = CompleteUserAuth(httptest.NewRecorder(), callback)
+		a.NoError(err)
+		return callback
+	}
+
+	laptop := login("Mozilla/5.0")
+	phone := login("Mobile Safari")
+
+	current, err := CurrentSession(phone)
+	a.NoError(err)
+	a.Equal("faux", current.Provider)
+	a.Equal("id", current.UserID)
+	a.Equal("Mobile Safari", current.UserAgent)
+	a.Equal("192.0.2.1", current.IP)
+
+	sessions, err := ActiveSessions("faux", "id")
+	a.NoError(err)
+	a.Len(sessions, 2)
+	// the most recently seen first
+	a.Equal(current.ID, sessions[0].ID)
+	a.Equal("Mozilla/5.0", sessions[1].UserAgent)
+
+	details, err := SessionDetails(sessions[1].ID)
+	a.NoError(err)
+	a.Equal("Mozilla/5.0", details.UserAgent)
+
+	next := httptest.NewRequest("GET", "/logout", nil)
+	copySession(laptop, next)
+	a.NoError(Logout(httptest.NewRecorder(), next))
+	sessions, _ = ActiveSessions("faux", "id")
+	a.Len(sessions, 1)
+	_, err = SessionDetails(details.ID)
+	a.Equal(ErrSessionNotFound, err)
+
+	_, err = CurrentSession(httptest.NewRequest("GET", "/", nil))
+	a.Equal(ErrSessionNotFound, err)
+}
+
+func Test_InvalidateAllSessions(t *testing.T) {
+	a := assert.New(t)
+	subject := Subject{Provider: "faux", UserID: "id"}
+	a.Error(InvalidateAllSessions(context.Background(), subject))
+
+	Inventory = NewMemoryInventory()
+	Revocations = NewMemoryRevocationList()
+	defer func() { Inventory, Revocations = nil, nil }()
+
+	req := httptest.NewRequest("GET", "/auth?provider=faux", nil)
+	authURL, err := GetAuthURL(httptest.NewRecorder(), req)
+	a.NoError(err)
+	u, _ := url.Parse(authURL)
+	callback := httptest.NewRequest("GET", "/auth/callback?provider=faux&state="+u.Query().Get("state"), nil)
+	copySession(req, callback)
+	user, err := CompleteUserAuth(httptest.NewRecorder(), callback)
+	a.NoError(err)
+	a.Equal(subject, SubjectOf(user))
+	_, err = CurrentSession(callback)
+	a.NoError(err)
+
+	issuedAt := time.Now()
+	revoked, err := IsRevoked(context.Background(), subject, issuedAt)
+	a.NoError(err)
+	a.False(revoked)
+
+	a.NoError(InvalidateAllSessions(context.Background(), subject))
+	_, err = CurrentSession(callback)
+	a.Equal(ErrSessionNotFound, err)
+	sessions, err := ActiveSessions("faux", "id")
+	a.NoError(err)
+	a.Len(sessions, 0)
+
+	// the cookies and JWTs issued before are revoked, not the ones issued after
+	revoked, _ = IsRevoked(context.Background(), subject, issuedAt)
+	a.True(revoked)
+	revoked, _ = IsRevoked(context.Background(), subject, time.Now().Add(time.Second))
+	a.False(revoked)
+	revoked, _ = IsRevoked(context.Background(), Subject{Provider: "faux", UserID: "other"}, issuedAt)
+	a.False(revoked)
+}
+
+func Test_InvalidateAllSessions_Cookies(t *testing.T) {
+	a := assert.New(t)
+	store := Store
+	Store = sessions.NewCookieStore([]byte("test-session-key"))
+	Revocations = NewMemoryRevocationList()
+	p := mock.New(goth.User{UserID: "7"})
+	p.SetName("revoked-mock")
+	goth.UseProviders(p)
+	defer func() {
+		Store, Revocations = store, nil
+		goth.UnregisterProviders("revoked-mock")
+	}()
+
+	// login returns a request with the cookie of a login linking the mock
+	// provider
+	login := func() *http.Request {
+		res := httptest.NewRecorder()
+		_, err := CompleteUserAuth(res, cookieLogin(t))
+		a.NoError(err)
+		linked := httptest.NewRequest("GET", "/", nil)
+		for _, c := range res.Result().Cookies() {
+			linked.AddCookie(c)
+		}
+		res = httptest.NewRecorder()
+		sess := &mock.Session{UserID: "7", AccessToken: mock.AccessToken("7")}
+		a.NoError(StoreInSession("revoked-mock", sess.Marshal(), linked, res))
+		req := httptest.NewRequest("GET", "/", nil)
+		for _, c := range res.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		return req
+	}
+
+	req := login()
+	user, err := SessionUser(context.Background(), req)
+	a.NoError(err)
+	a.Equal("7", user.UserID)
+
+	// the cookies issued before are revoked, not the ones issued after
+	a.NoError(InvalidateAllSessions(context.Background(), SubjectOf(user)))
+	_, err = SessionUser(context.Background(), req)
+	a.ErrorIs(err, ErrSessionNotFound)
+	users, err := FetchAllUsers(context.Background(), req)
+	a.NoError(err)
+	a.Len(users, 1)
+	a.ErrorIs(users[0].Err, ErrSessionNotFound)
+	user, err = SessionUser(context.Background(), login())
+	a.NoError(err)
+	a.Equal("7", user.UserID)
+}
+
+// countingProvider counts the users fetched.
+type countingProvider struct {
+	*faux.Provider
+	fetched int
+}
+
+func (p *countingProvider) FetchUser(session goth.Session) (goth.User, error) {
+	p.fetched++
+	return p.Provider.FetchUser(session)
+}
+
+func Test_FetchUser_Cache(t *testing.T) {
+	a := assert.New(t)
+	p := &countingProvider{Provider: &faux.Provider{}}
+	ctx := context.Background()
+	sess := &faux.Session{ID: "id", Name: "Homer", AccessToken: "access"}
+
+	// the users aren't cached by default
+	_, err := FetchUser(ctx, p, sess)
+	a.NoError(err)
+	_, err = FetchUser(ctx, p, sess)
+	a.NoError(err)
+	a.Equal(2, p.fetched)
+
+	UserCacheTTL = time.Minute
+	clock := goth.DefaultClock
+	now := time.Now()
+	goth.DefaultClock = goth.ClockFunc(func() time.Time { return now })
+	defer func() { UserCacheTTL, goth.DefaultClock = 0, clock }()
+
+	_, err = FetchUser(ctx, p, sess)
+	a.NoError(err)
+	cached, err := FetchUser(ctx, p, sess)
+	a.NoError(err)
+	a.Equal("Homer", cached.Name)
+	a.Equal(3, p.fetched)
+
+	// the other tokens, the errors and the sessions without token aren't cached
+	_, err = FetchUser(ctx, p, &faux.Session{ID: "id", AccessToken: "other"})
+	a.NoError(err)
+	a.Equal(4, p.fetched)
+	_, err = FetchUser(ctx, p, &faux.Session{ID: "id"})
+	a.Error(err)
+	_, err = FetchUser(ctx, p, &faux.Session{ID: "id"})
+	a.Error(err)
+	a.Equal(6, p.fetched)
+
+	// the users expire, and are forgotten once revoked
+	now = now.Add(time.Minute)
+	_, err = FetchUser(ctx, p, sess)
+	a.NoError(err)
+	a.Equal(7, p.fetched)
+	Revocations = NewMemoryRevocationList()
+	defer func() { Revocations = nil }()
+	a.NoError(InvalidateAllSessions(ctx, Subject{Provider: "faux", UserID: "id"}))
+	_, err = FetchUser(ctx, p, sess)
+	a.NoError(err)
+	a.Equal(8, p.fetched)
+
+	// the RawData of the cached users can't be modified by the callers
+	raw := &rawDataProvider{Provider: &faux.Provider{}}
+	sess = &faux.Session{ID: "raw", AccessToken: "raw-" + now.String()}
+	user, err := FetchUser(ctx, raw, sess)
+	a.NoError(err)
+	user.RawData["groups"].([]interface{})[0] = "users"
+	user.RawData["org"].(map[string]interface{})["name"] = "shelbyville"
+	user.RawData["teams"].([]string)[0] = "bar"
+	user, err = FetchUser(ctx, raw, sess)
+	a.NoError(err)
+	a.Equal(1, raw.fetched)
+	a.Equal("admins", user.RawData["groups"].([]interface{})[0])
+	a.Equal("springfield", user.RawData["org"].(map[string]interface{})["name"])
+	a.Equal("plant", user.RawData["teams"].([]string)[0])
+
+	// the sessions marshaling their token as access_token are cached
+	_, err = FetchUser(ctx, raw, &reddit.Session{AccessToken: "reddit-" + now.String()})
+	a.NoError(err)
+	_, err = FetchUser(ctx, raw, &reddit.Session{AccessToken: "reddit-" + now.String()})
+	a.NoError(err)
+	a.Equal(2, raw.fetched)
+}
+
+// rawDataProvider fetches a user with nested RawData, whatever the session.
+type rawDataProvider struct {
+	*faux.Provider
+	fetched int
+}
+
+func (p *rawDataProvider) FetchUser(goth.Session) (goth.User, error) {
+	p.fetched++
+	return goth.User{Provider: "faux", UserID: "raw", RawData: map[string]interface{}{
+		"groups": []interface{}{"admins"},
+		"org":    map[string]interface{}{"name": "springfield"},
+		"teams":  []string{"plant"},
+	}}, nil
+}
+
+func Test_BindUserAgent(t *testing.T) {
+	a := assert.New(t)
+	BindUserAgent = true
+	BindClientHints = true
+	defer func() { BindUserAgent, BindClientHints = false, false }()
+
+	complete := func(userAgent, platform string) error {
+		req := httptest.NewRequest("GET", "/auth?provider=faux", nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0")
+		req.Header.Set("Sec-CH-UA-Platform", `"macOS"`)
+		authURL, err := GetAuthURL(httptest.NewRecorder(), req)
+		a.NoError(err)
+		u, _ := url.Parse(authURL)
+
+		callback := httptest.NewRequest("GET", "/auth/callback?provider=faux&state="+u.Query().Get("state"), nil)
+		callback.Header.Set("User-Agent", userAgent)
+		callback.Header.Set("Sec-CH-UA-Platform", platform)
+		copySession(req, callback)
+		_, err = CompleteUserAuth(httptest.NewRecorder(), callback)
+		return err
+	}
+
+	a.NoError(complete("Mozilla/5.0", `"macOS"`))
+	a.Equal(ErrSessionUserAgentMismatch, complete("curl/8.0", `"macOS"`))
+	a.Equal(ErrSessionUserAgentMismatch, complete("Mozilla/5.0", `"Windows"`))
+
+	StepUp = codeFactor{required: true, code: "123456"}
+	defer func() { StepUp = nil }()
+	req := httptest.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	session, _ := Store.Get(req, SessionName)
+	session.Values["faux"] = gzipString((&faux.Session{Email: "homer@example.com"}).Marshal())
+	_, err := CompleteUserAuth(httptest.NewRecorder(), req)
+	a.Equal(ErrSecondFactorRequired, err)
+	verify := httptest.NewRequest("GET", "/auth/verify?code=123456", nil)
+	verify.Header.Set("User-Agent", "curl/8.0")
+	copySession(req, verify)
+	_, err = VerifySecondFactor(httptest.NewRecorder(), verify)
+	a.Equal(ErrSessionUserAgentMismatch, err)
+}
+
+func Test_ReturnTo(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := NewRedirectAllowlist("https://*.example.com/*", "/account/*")
+	a.NoError(err)
+	_, err = NewRedirectAllowlist("https://app.*.com/")
+	a.Error(err)
+	_, err = NewRedirectAllowlist("//evil.example")
+	a.Error(err)
+	_, err = NewRedirectAllowlist("javascript:alert(1)")
+	a.Error(err)
+
+	// only the paths of the application are allowed by default
+	a.NoError(ValidateRedirect("/dashboard?tab=1"))
+	for _, destination := range []string{"https://evil.example/", "//evil.example/", "/\\evil.example/", "https:evil.example", "javascript:alert(1)"} {
+		err = ValidateRedirect(destination)
+		a.True(errors.Is(err, ErrRedirectNotAllowed), destination)
+	}
+
+	AllowedRedirects, err = NewRedirectAllowlist("https://app.example.com/", "https://*.example.org/docs/*", "/account/*")
+	a.NoError(err)
+	defer func() { AllowedRedirects = nil }()
+	for destination, allowed := range map[string]bool{
+		"https://app.example.com":               true,
+		"https://APP.example.com/?next=1":       true,
+		"https://app.example.com/admin":         false,
+		"http://app.example.com/":               false,
+		"https://app.example.com.evil.example/": false,
+		"https://user@app.example.com/":         false,
+		"https://eu.example.org/docs/auth":      true,
+		"https://example.org/docs/auth":         false,
+		"https://eu.example.org/docs/../admin":  false,
+		"/account/settings":                     true,
+		"/account/../admin":                     false,
+		"/dashboard":                            false,
+	} {
+		a.Equal(allowed, AllowedRedirects.Allowed(destination), destination)
+	}
+
+	req := httptest.NewRequest("GET", "/auth?provider=faux&returnTo="+url.QueryEscape("https://evil.example/"), nil)
+	_, err = GetAuthURL(httptest.NewRecorder(), req)
+	var redirectErr *RedirectError
+	a.True(errors.As(err, &redirectErr))
+	a.Equal("gothic: redirect to https://evil.example/ not allowed", err.Error())
+
+	req = httptest.NewRequest("GET", "/auth?provider=faux&returnTo="+url.QueryEscape("/account/settings"), nil)
+	authURL, err := GetAuthURL(httptest.NewRecorder(), req)
+	a.NoError(err)
+	u, _ := url.Parse(authURL)
+	callback := httptest.NewRequest("GET", "/auth/callback?provider=faux&state="+u.Query().Get("state"), nil)
+	copySession(req, callback)
+	destination, err := ReturnTo(callback)
+	a.NoError(err)
+	a.Equal("/account/settings", destination)
+
+	// the destination is validated again
+	AllowedRedirects, _ = NewRedirectAllowlist("https://app.example.com/")
+	_, err = ReturnTo(callback)
+	a.True(errors.Is(err, ErrRedirectNotAllowed))
+
+	_, err = CompleteUserAuth(httptest.NewRecorder(), callback)
+	a.NoError(err)
+	destination, err = ReturnTo(httptest.NewRequest("GET", "/", nil))
+	a.NoError(err)
+	a.Equal("", destination)
+}
+
+func Test_GetFromSession_Malformed(t *testing.T) {
+	a := assert.New(t)
+
+	Store = NewProviderStore()
+	req, _ := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	session, _ := Store.Get(req, SessionName)
+	for _, value := range []interface{}{42, []byte("gzip"), "not gzipped", gzipString("truncated")[:12]} {
+		session.Values["faux"] = value
+		_, err := GetFromSession("faux", req)
+		a.ErrorIs(err, ErrSessionNotFound, "%v", value)
+		_, err = CompleteUserAuth(httptest.NewRecorder(), req)
+		a.ErrorIs(err, ErrSessionNotFound, "%v", value)
+	}
+}
+
+func Test_ProviderFactory(t *testing.T) {
+	a := assert.New(t)
+
+	JSONErrors = true
+	defer func() { JSONErrors = false }()
+	calls := 0
+	goth.UseProviderFactory("lazy", func() (goth.Provider, error) {
+		calls++
+		return nil, errors.New("discovery unreachable")
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth?provider=lazy", nil)
+	req.Header.Set("Accept", "application/json")
+	BeginAuthHandler(res, req)
+	a.Equal(http.StatusBadRequest, res.Code)
+	a.Contains(res.Body.String(), `"error":"provider_unavailable"`)
+	a.Equal(1, calls)
+}
+
+func Test_FetchAllUsers(t *testing.T) {
+	a := assert.New(t)
+
+	github := mock.New(goth.User{UserID: "octocat"})
+	github.SetName("linked-github")
+	google := mock.New(goth.User{UserID: "homer"})
+	google.SetName("linked-google")
+	broken := mock.New()
+	broken.SetName("linked-broken")
+	broken.FetchUserErr = errors.New("provider unavailable")
+	// the providers reject the expired access tokens
+	expired := func(sess *mock.Session) (goth.User, error) {
+		if !strings.HasPrefix(sess.AccessToken, "mock-refreshed-") {
+			return goth.User{}, errors.New("access token expired")
+		}
+		return goth.User{UserID: sess.UserID, AccessToken: sess.AccessToken, RefreshToken: sess.RefreshToken, ExpiresAt: sess.ExpiresAt}, nil
+	}
+	github.FetchUserFunc = expired
+	zitadel := mock.New()
+	zitadel.SetName("linked-zitadel")
+	zitadel.FetchUserFunc = expired
+	goth.UseProviders(github, google, broken, zitadel)
+	defer goth.UnregisterProviders("linked-github", "linked-google", "linked-broken", "linked-zitadel")
+
+	req := httptest.NewRequest("GET", "/", nil)
+	session, _ := Store.New(req, SessionName)
+	store := func(key string, sess goth.Session) {
+		session.Values[key], _ = EncodeSessionValue(sess.Marshal())
+	}
+	store("linked-github", &mock.Session{UserID: "octocat", AccessToken: "a1", RefreshToken: "r1", ExpiresAt: time.Now().Add(-time.Minute)})
+	store("linked-google", &mock.Session{UserID: "homer", AccessToken: "a2", ExpiresAt: time.Now().Add(time.Hour)})
+	store("linked-broken", &mock.Session{AccessToken: "a3"})
+	// the session doesn't know its access token expired
+	store("linked-zitadel", &mock.Session{UserID: "marge", AccessToken: "a4", RefreshToken: "r4"})
+	session.Values["theme"] = "dark"
+
+	users, err := FetchAllUsers(context.Background(), req)
+	a.NoError(err)
+	a.Len(users, 4)
+	a.Equal("linked-broken", users[0].Provider)
+	a.EqualError(users[0].Err, "provider unavailable")
+
+	// the expired tokens are refreshed
+	a.Equal("linked-github", users[1].Provider)
+	a.NoError(users[1].Err)
+	a.Equal("octocat", users[1].User.UserID)
+	a.True(users[1].Refreshed)
+	a.Equal("mock-refreshed-r1", users[1].User.AccessToken)
+
+	a.Equal("linked-google", users[2].Provider)
+	a.NoError(users[2].Err)
+	a.Equal("homer", users[2].User.UserID)
+	a.False(users[2].Refreshed)
+	a.Equal("a2", users[2].User.AccessToken)
+
+	// the expired tokens are refreshed once the fetch failed
+	a.Equal("linked-zitadel", users[3].Provider)
+	a.NoError(users[3].Err)
+	a.Equal("marge", users[3].User.UserID)
+	a.True(users[3].Refreshed)
+	a.Equal("mock-refreshed-r4", users[3].User.AccessToken)
+
+	// the user of the session is the first without an error
+	user, err := SessionUser(context.Background(), req)
+	a.NoError(err)
+	a.Equal("octocat", user.UserID)
+	_, err = SessionUser(context.Background(), httptest.NewRequest("GET", "/", nil))
+	a.ErrorIs(err, ErrSessionNotFound)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	users, err = FetchAllUsers(ctx, req)
+	a.NoError(err)
+	for _, u := range users {
+		a.ErrorIs(u.Err, context.Canceled)
+	}
+}
+
+func Test_SessionSecret_FIPS(t *testing.T) {
+	a := assert.New(t)
+	if os.Getenv("GOTHIC_TEST_SESSION_SECRET") == "1" {
+		// the store of the refused SESSION_SECRET is the default store
+		Store = nil
+		_, err := GetAuthURL(httptest.NewRecorder(), httptest.NewRequest("GET", "/auth?provider=faux", nil))
+		a.ErrorIs(err, goth.ErrNotFIPSApproved)
+		a.Contains(err.Error(), "SESSION_SECRET")
+		_, err = CompleteUserAuth(httptest.NewRecorder(), httptest.NewRequest("GET", "/auth/callback?provider=faux", nil))
+		a.ErrorIs(err, goth.ErrNotFIPSApproved)
+		a.ErrorIs(StoreInSession("faux", "value", httptest.NewRequest("GET", "/", nil), httptest.NewRecorder()), goth.ErrNotFIPSApproved)
+
+		// until another store is set
+		a.NoError(UseCookies([]byte("a-session-key-long-enough"), &sessions.Options{}))
+		_, err = GetAuthURL(httptest.NewRecorder(), httptest.NewRequest("GET", "/auth?provider=faux", nil))
+		a.NoError(err)
+		return
+	}
+
+	// gothic reads SESSION_SECRET when the package is initialized, without
+	// panicking when it is refused
+	cmd := exec.Command(os.Args[0], "-test.run=^Test_SessionSecret_FIPS$")
+	cmd.Env = append(os.Environ(), "GOTHIC_TEST_SESSION_SECRET=1", "GOTH_FIPS=1", "SESSION_SECRET=short")
+	out, err := cmd.CombinedOutput()
+	a.NoError(err, string(out))
+}
+
+func Test_Configure(t *testing.T) {
+	a := assert.New(t)
+	defer Configure(nil)
+
+	a.Error(Configure(&Config{}))
+
+	a.NoError(Configure(&Config{Store: sessions.NewCookieStore([]byte("test-session-key"))}))
+	c := CurrentConfig()
+	a.Equal("_gothic_session", c.SessionName)
+	a.NotNil(c.SetState)
+	a.NotNil(c.GetState)
+	a.NotNil(c.GetProviderName)
+
+	// the configuration is read, not the package variables
+	res := httptest.NewRecorder()
+	_, err := GetAuthURL(res, httptest.NewRequest("GET", "/auth?provider=faux", nil))
+	a.NoError(err)
+	a.Len(res.Result().Cookies(), 1)
+	a.Equal("_gothic_session", res.Result().Cookies()[0].Name)
+	_, err = CompleteUserAuth(httptest.NewRecorder(), cookieLogin(t))
+	a.NoError(err)
+
+	// UseCookies replaces the store of the configuration, not Store
+	store := Store
+	a.NoError(UseCookies([]byte("another-session-key"), &sessions.Options{Path: "/"}))
+	a.Equal(store, Store)
+	a.NotEqual(c.Store, CurrentConfig().Store)
+
+	a.NoError(Configure(nil))
+	a.Equal(Store, CurrentConfig().Store)
+}
+
+func Test_Configure_Variables(t *testing.T) {
+	a := assert.New(t)
+	getProviderName := GetProviderName
+	defer func() {
+		Configure(nil)
+		GetProviderName, Inventory = getProviderName, nil
+	}()
+
+	GetProviderName = func(req *http.Request) (string, error) { return "faux", nil }
+	Inventory = NewMemoryInventory()
+
+	// the configuration is taken as it is, the variables aren't copied
+	a.NoError(Configure(&Config{Store: sessions.NewCookieStore([]byte("test-session-key"))}))
+	c := CurrentConfig()
+	a.Nil(c.Inventory)
+	name, err := c.GetProviderName(httptest.NewRequest("GET", "/?provider=other", nil))
+	a.NoError(err)
+	a.Equal("other", name)
+	Configure(nil)
+
+	// the configuration starts from the package variables with CurrentConfig
+	c = CurrentConfig()
+	c.Store = sessions.NewCookieStore([]byte("test-session-key"))
+	c.Revocations = NewMemoryRevocationList()
+	a.NoError(Configure(&c))
+	GetProviderName, Inventory = getProviderName, nil
+	c = CurrentConfig()
+	name, err = c.GetProviderName(httptest.NewRequest("GET", "/", nil))
+	a.NoError(err)
+	a.Equal("faux", name)
+	a.NotNil(c.Inventory)
+	a.NotNil(c.Revocations)
+	a.Nil(Revocations)
+
+	// the components of the configuration are used, not the variables
+	res := httptest.NewRecorder()
+	user, err := CompleteUserAuth(res, cookieLogin(t))
+	a.NoError(err)
+	req := httptest.NewRequest("GET", "/", nil)
+	for _, cookie := range res.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	_, err = CurrentSession(req)
+	a.NoError(err)
+	a.NoError(InvalidateAllSessions(context.Background(), SubjectOf(user)))
+	revoked, err := IsRevoked(context.Background(), SubjectOf(user), time.Now().Add(-time.Minute))
+	a.NoError(err)
+	a.True(revoked)
+	_, err = CurrentSession(req)
+	a.Equal(ErrSessionNotFound, err)
+}
+
+func Test_Configure_Concurrent(t *testing.T) {
+	a := assert.New(t)
+	defer Configure(nil)
+	a.NoError(Configure(&Config{Store: sessions.NewCookieStore([]byte("test-session-key"))}))
+
+	// the store is replaced while the requests are served
+	done := make(chan struct{})
+	reconfigured := make(chan struct{})
+	go func() {
+		defer close(reconfigured)
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				_ = UseCookies([]byte("test-session-key"), &sessions.Options{Path: "/"})
+			} else {
+				_ = Configure(&Config{Store: sessions.NewCookieStore([]byte("test-session-key"))})
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				_, err := CompleteUserAuth(httptest.NewRecorder(), cookieLogin(t))
+				a.NoError(err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	<-reconfigured
+}
+
+// slowProvider fetches the users slowly, ignoring the context, and with it
+// when it is a goth.ContextProvider.
+type slowProvider struct {
+	*faux.Provider
+	delay time.Duration
+}
+
+func (p *slowProvider) FetchUser(session goth.Session) (goth.User, error) {
+	time.Sleep(p.delay)
+	return p.Provider.FetchUser(session)
+}
+
+func (p *slowProvider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	time.Sleep(p.delay)
+	return &oauth2.Token{AccessToken: "refreshed"}, nil
+}
+
+type slowContextProvider struct {
+	*slowProvider
+}
+
+func (p *slowContextProvider) FetchUserContext(ctx context.Context, session goth.Session) (goth.User, error) {
+	select {
+	case <-time.After(p.delay):
+		return p.Provider.FetchUser(session)
+	case <-ctx.Done():
+		return goth.User{}, ctx.Err()
+	}
+}
+
+func Test_Timeouts(t *testing.T) {
+	a := assert.New(t)
+	timeout := FetchUserTimeout
+	defer func() { FetchUserTimeout = timeout }()
+	FetchUserTimeout = 20 * time.Millisecond
+	sess := &faux.Session{Name: "Homer", AccessToken: "access"}
+
+	for _, p := range []goth.Provider{
+		&slowProvider{Provider: &faux.Provider{}, delay: time.Second},
+		&slowContextProvider{&slowProvider{Provider: &faux.Provider{}, delay: time.Second}},
+	} {
+		_, err := FetchUser(context.Background(), p, sess)
+		var timeoutErr *TimeoutError
+		a.True(errors.As(err, &timeoutErr))
+		a.Equal(OperationFetchUser, timeoutErr.Operation)
+		a.ErrorIs(err, ErrContextTimeout)
+		a.ErrorIs(err, context.DeadlineExceeded)
+	}
+
+	// the operations have their own timeouts
+	p := &slowProvider{Provider: &faux.Provider{}, delay: 50 * time.Millisecond}
+	token, err := RefreshToken(context.Background(), p, "refresh")
+	a.NoError(err)
+	a.Equal("refreshed", token.AccessToken)
+
+	// overridden for a request
+	ctx := WithTimeout(context.Background(), OperationFetchUser, time.Second)
+	user, err := FetchUser(ctx, p, sess)
+	a.NoError(err)
+	a.Equal("Homer", user.Name)
+	ctx = WithTimeout(ctx, OperationRefreshToken, 10*time.Millisecond)
+	_, err = RefreshToken(ctx, p, "refresh")
+	a.ErrorIs(err, ErrContextTimeout)
+
+	// or not limited
+	FetchUserTimeout = 0
+	_, err = FetchUser(context.Background(), p, sess)
+	a.NoError(err)
+
+	// the requests canceled aren't timeouts
+	FetchUserTimeout = time.Second
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = FetchUser(canceled, &slowContextProvider{p}, sess)
+	a.ErrorIs(err, context.Canceled)
+	a.ErrorIs(err, ErrContextCanceled)
+	a.NotErrorIs(err, ErrContextTimeout)
+}
+
+func Test_Timeouts_Queue(t *testing.T) {
+	a := assert.New(t)
+	store, timeout := Store, FetchUserTimeout
+	Store = sessions.NewCookieStore([]byte("test-session-key"))
+	FetchUserTimeout = 20 * time.Millisecond
+	Queue = NewLoginQueue(1, 0, 0)
+	defer func() {
+		Store, FetchUserTimeout, Queue = store, timeout, nil
+		goth.UseProviders(fauxProvider)
+	}()
+	goth.UseProviders(&slowProvider{Provider: &faux.Provider{}, delay: 200 * time.Millisecond})
+
+	_, err := CompleteUserAuth(httptest.NewRecorder(), cookieLogin(t))
+	a.ErrorIs(err, ErrContextTimeout)
+	// the login holds its slot while the provider ignoring the context is
+	// still fetching the user
+	_, err = Queue.Acquire(context.Background(), "faux")
+	a.Equal(ErrProviderBusy, err)
+	a.Eventually(func() bool {
+		release, err := Queue.Acquire(context.Background(), "faux")
+		if err != nil {
+			return false
+		}
+		release()
+		return true
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+// tokenRecorder records the users whose tokens are saved.
+type tokenRecorder struct {
+	users []goth.User
+}
+
+func (r *tokenRecorder) SaveTokens(ctx context.Context, user goth.User) error {
+	r.users = append(r.users, user)
+	return nil
+}
+
+func Test_MinimalSession(t *testing.T) {
+	a := assert.New(t)
+	store := Store
+	Store = sessions.NewCookieStore([]byte("test-session-key"))
+	tokens := &tokenRecorder{}
+	defer func() { Store, MinimalSession, Tokens, StepUp = store, false, nil, nil }()
+
+	cookieSize := func() int {
+		res := httptest.NewRecorder()
+		_, err := GetAuthURL(res, httptest.NewRequest("GET", "/auth?provider=faux", nil))
+		a.NoError(err)
+		return len(res.Header().Get("Set-Cookie"))
+	}
+	full := cookieSize()
+	MinimalSession = true
+	a.Less(cookieSize(), full)
+
+	// the callback completes with the state of the minimal session
+	Tokens = tokens
+	user, err := CompleteUserAuth(httptest.NewRecorder(), cookieLogin(t))
+	a.NoError(err)
+	a.NotEmpty(user.AccessToken)
+	a.Len(tokens.users, 1)
+	a.Equal(user.AccessToken, tokens.users[0].AccessToken)
+
+	callback := cookieLogin(t)
+	callback.URL.RawQuery = "provider=faux&state=other"
+	_, err = CompleteUserAuth(httptest.NewRecorder(), callback)
+	a.Equal(ErrStateTokenMismatch, err)
+	a.Len(tokens.users, 1)
+
+	// the users waiting for the second factor are kept without their tokens
+	StepUp = codeFactor{required: true, code: "123456"}
+	res := httptest.NewRecorder()
+	user, err = CompleteUserAuth(res, cookieLogin(t))
+	a.Equal(ErrSecondFactorRequired, err)
+	a.NotEmpty(user.AccessToken)
+	a.Len(tokens.users, 2)
+	verify := httptest.NewRequest("GET", "/auth/verify?code=123456", nil)
+	for _, c := range res.Result().Cookies() {
+		verify.AddCookie(c)
+	}
+	user, err = VerifySecondFactor(httptest.NewRecorder(), verify)
+	a.NoError(err)
+	a.Equal("faux", user.Provider)
+	a.Empty(user.AccessToken)
+}
+
+// labelProvider records the pprof labels of the fetch of the user.
+type labelProvider struct {
+	*faux.Provider
+	operation string
+}
+
+func (p *labelProvider) FetchUserContext(ctx context.Context, session goth.Session) (goth.User, error) {
+	p.operation, _ = pprof.Label(ctx, "gothic_operation")
+	return p.Provider.FetchUser(session)
+}
+
+func Test_Instrument(t *testing.T) {
+	a := assert.New(t)
+	p := &labelProvider{Provider: &faux.Provider{}}
+	goth.UseProviders(p)
+	defer func() {
+		Instrument(false)
+		goth.UseProviders(fauxProvider)
+	}()
+	ResetPhases()
+	login := func(sess faux.Session) error {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/auth/callback?provider=faux", nil)
+		session, _ := Store.Get(req, SessionName)
+		session.Values["faux"] = gzipString(sess.Marshal())
+		_ = session.Save(req, res)
+		_, err := CompleteUserAuth(res, req)
+		return err
+	}
+
+	// the phases aren't timed by default
+	a.NoError(login(faux.Session{Name: "Homer"}))
+	a.Zero(Phases()[OperationCompleteUserAuth].Count)
+	a.Empty(p.operation)
+
+	// the user is fetched after the token exchange, labeled for pprof
+	Instrument(true)
+	a.NoError(login(faux.Session{Name: "Homer"}))
+	a.Equal("fetch_user", p.operation)
+	a.Error(login(faux.Session{AuthURL: "http://example.com/auth?state=state"}))
+	completed := Phases()[OperationCompleteUserAuth]
+	a.EqualValues(2, completed.Count)
+	a.EqualValues(1, completed.Errors)
+	a.True(completed.Max > 0 && completed.Max <= completed.Total)
+	a.Equal(completed.Total/2, completed.Mean)
+	a.EqualValues(1, Phases()[OperationAuthorize].Count)
+	a.EqualValues(1, Phases()[OperationFetchUser].Count)
+
+	_, err := GetAuthURL(httptest.NewRecorder(), httptest.NewRequest("GET", "/auth?provider=faux", nil))
+	a.NoError(err)
+	a.EqualValues(1, Phases()[OperationBeginAuth].Count)
+
+	b, err := json.Marshal(Phases())
+	a.NoError(err)
+	a.Contains(string(b), `"complete_user_auth":{"count":2,"errors":1,`)
+
+	ResetPhases()
+	a.Zero(Phases()[OperationCompleteUserAuth].Count)
+}
+
+func Fuzz_DecodeSessionValue(f *testing.F) {
+	for _, seed := range []string{"", "{}", `{"AuthURL":"http://example.com/auth?state=state"}`} {
+		encoded, err := EncodeSessionValue(seed)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(encoded)
+		f.Add(encoded[:len(encoded)/2])
+	}
+	f.Add("\x1f\x8b")
+	f.Fuzz(func(t *testing.T, data string) {
+		value, err := DecodeSessionValue(data)
+		if err != nil {
+			return
+		}
+		encoded, err := EncodeSessionValue(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again, err := DecodeSessionValue(encoded); err != nil || again != value {
+			t.Fatalf("the value %q is decoded as %q, %v", value, again, err)
+		}
+	})
+}
+
+func Fuzz_CheckState(f *testing.F) {
+	f.Add("http://example.com/auth?state=state", "state")
+	f.Add("http://example.com/auth?state=state", "forged")
+	f.Add("http://example.com/auth", "state")
+	f.Add("%zz", "")
+	f.Fuzz(func(t *testing.T, authURL, state string) {
+		err := CheckState(authURL, state)
+		if err != nil {
+			return
+		}
+		u, _ := url.Parse(authURL)
+		if original := u.Query().Get("state"); original != "" && original != state {
+			t.Fatalf("the state %q was accepted for %s", state, authURL)
+		}
+	})
+}
+
+func Fuzz_CompleteUserAuth(f *testing.F) {
+	f.Add(`{"ID":"id","Name":"Homer","Email":"homer@example.com","AuthURL":"http://example.com/auth?state=state"}`)
+	f.Add(`{"AuthURL":"%zz"}`)
+	f.Add(`{"AuthURL":"http://example.com/auth?state=other"}`)
+	f.Add("null")
+	f.Add("")
+	f.Fuzz(func(t *testing.T, data string) {
+		Store = NewProviderStore()
+		req, _ := http.NewRequest("GET", "/auth/callback?provider=faux&state=state", nil)
+		session, _ := Store.Get(req, SessionName)
+		value, err := EncodeSessionValue(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		session.Values["faux"] = value
+		_, _ = CompleteUserAuth(httptest.NewRecorder(), req)
+	})
+}
+
+// sessionValue is a marshaled provider session, of the size of the sessions
+// of the OAuth2 providers.
+var sessionValue = `{"AuthURL":"https://github.com/login/oauth/authorize?client_id=0123456789abcdef&redirect_uri=https%3A%2F%2Fexample.com%2Fauth%2Fgithub%2Fcallback&response_type=code&scope=user%3Aemail&state=` + strings.Repeat("s", 88) + `","AccessToken":"gho_` + strings.Repeat("t", 36) + `","RefreshToken":"","ExpiresAt":"0001-01-01T00:00:00Z"}`
+
+func Benchmark_EncodeSessionValue(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := EncodeSessionValue(sessionValue); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_DecodeSessionValue(b *testing.B) {
+	encoded, err := EncodeSessionValue(sessionValue)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecodeSessionValue(encoded); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_StoreInSession(b *testing.B) {
+	Store = NewProviderStore()
+	req, _ := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	res := httptest.NewRecorder()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := StoreInSession("faux", sessionValue, req, res); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := GetFromSession("faux", req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func gzipString(value string) string {
 	var b bytes.Buffer
 	gz := gzip.NewWriter(&b)
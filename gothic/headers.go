@@ -0,0 +1,23 @@
+package gothic
+
+import "net/http"
+
+// SecurityHeaders are set on the responses of BeginAuthHandler,
+// BeginChallengeHandler and of the callbacks calling CompleteUserAuth, whose
+// URLs carry the state and the codes of the authentication: they must never
+// be cached, nor leaked to other sites with the Referer header. Set them to
+// nil to manage the headers in the application.
+var SecurityHeaders = http.Header{
+	"Cache-Control":          {"no-store"},
+	"Pragma":                 {"no-cache"},
+	"Referrer-Policy":        {"no-referrer"},
+	"X-Content-Type-Options": {"nosniff"},
+}
+
+// SetSecurityHeaders sets the SecurityHeaders on the response, e.g. of a
+// handler of the application.
+func SetSecurityHeaders(res http.ResponseWriter) {
+	for name, values := range SecurityHeaders {
+		res.Header()[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+}
@@ -0,0 +1,213 @@
+package gothic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// The statuses of the providers in the health reports.
+const (
+	HealthUp = "up"
+	// HealthDown is the status of the providers which can't be reached, or
+	// respond with a 5xx status code.
+	HealthDown = "down"
+	// HealthUnknown is the status of the providers without an endpoint to
+	// check, e.g. LDAP.
+	HealthUnknown = "unknown"
+)
+
+var (
+	// HealthCheckInterval is the time the health of a provider is cached, so
+	// the dashboards and readiness probes polling ProvidersHealthHandler
+	// don't flood the providers.
+	HealthCheckInterval = 30 * time.Second
+	// HealthCheckTimeout is the time a provider has to respond.
+	HealthCheckTimeout = 5 * time.Second
+)
+
+// ProviderHealth is the health of a provider.
+type ProviderHealth struct {
+	Status    string        `json:"status"`
+	Endpoint  string        `json:"endpoint,omitempty"`
+	Latency   time.Duration `json:"latency_ns,omitempty"`
+	CheckedAt time.Time     `json:"checked_at"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// HealthReport is the health of the providers.
+type HealthReport struct {
+	// Status is ok, or degraded when a provider is down.
+	Status    string                    `json:"status"`
+	Providers map[string]ProviderHealth `json:"providers"`
+}
+
+// ProvidersHealthHandler serves the health of the providers as JSON, with a
+// 503 status code when a provider is down, for dashboards and readiness
+// gates. See CheckProviders.
+func ProvidersHealthHandler(res http.ResponseWriter, req *http.Request) {
+	report := CheckProviders(req.Context())
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-store")
+	if report.Status != "ok" {
+		res.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(res).Encode(report)
+}
+
+// CheckProviders checks whether the providers are reachable, by requesting
+// the endpoint of the providers implementing goth.HealthProvider, or the
+// origin of the authentication URL of the others. Any response but a 5xx
+// means the provider is up. The health of each provider is cached for
+// HealthCheckInterval, and checked once at a time.
+func CheckProviders(ctx context.Context) HealthReport {
+	names := goth.ProviderNames()
+	report := HealthReport{Status: "ok", Providers: make(map[string]ProviderHealth, len(names))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			var health ProviderHealth
+			// the providers of goth.UseProviderFactory are constructed,
+			// and down while they can't be
+			provider, err := goth.GetProvider(name)
+			if err != nil {
+				health = ProviderHealth{Status: HealthDown, CheckedAt: goth.Now(), Error: err.Error()}
+			} else {
+				health = healthChecks.check(ctx, name, provider)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			report.Providers[name] = health
+			if health.Status == HealthDown {
+				report.Status = "degraded"
+			}
+		}(name)
+	}
+	wg.Wait()
+	return report
+}
+
+// healthChecks caches the health of the providers.
+var healthChecks = &healthCache{entries: map[string]*healthEntry{}}
+
+type healthCache struct {
+	mu      sync.Mutex
+	entries map[string]*healthEntry
+}
+
+type healthEntry struct {
+	// mu is held during the checks, so they are done once at a time.
+	mu       sync.Mutex
+	provider goth.Provider
+	health   ProviderHealth
+}
+
+func (c *healthCache) check(ctx context.Context, name string, provider goth.Provider) ProviderHealth {
+	c.mu.Lock()
+	e, ok := c.entries[name]
+	if !ok {
+		e = &healthEntry{}
+		c.entries[name] = e
+	}
+	c.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if sameProvider(e.provider, provider) && goth.Now().Sub(e.health.CheckedAt) < HealthCheckInterval {
+		return e.health
+	}
+	e.provider = provider
+	e.health = checkProvider(ctx, provider)
+	return e.health
+}
+
+// sameProvider reports whether the provider checked is still the provider
+// of its name.
+func sameProvider(checked, provider goth.Provider) bool {
+	if checked == nil || reflect.TypeOf(checked) != reflect.TypeOf(provider) || !reflect.TypeOf(provider).Comparable() {
+		return false
+	}
+	return checked == provider
+}
+
+func checkProvider(ctx context.Context, provider goth.Provider) ProviderHealth {
+	health := ProviderHealth{Status: HealthUnknown, CheckedAt: goth.Now()}
+	endpoint, err := healthEndpoint(provider)
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	if endpoint == "" {
+		return health
+	}
+	health.Endpoint = endpoint
+
+	ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	var client *http.Client
+	if p, ok := provider.(interface{ Client() *http.Client }); ok {
+		client = p.Client()
+	}
+	// the endpoints redirect to login pages, which don't tell more
+	noRedirect := *goth.HTTPClientWithFallBack(client)
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	start := time.Now()
+	resp, err := noRedirect.Do(req)
+	health.Latency = time.Since(start)
+	if err != nil {
+		health.Status = HealthDown
+		health.Error = err.Error()
+		return health
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		health.Status = HealthDown
+		health.Error = fmt.Sprintf("responded with a %d", resp.StatusCode)
+		return health
+	}
+	health.Status = HealthUp
+	return health
+}
+
+// healthEndpoint returns the endpoint of the provider to check, or an empty
+// string when it has none.
+func healthEndpoint(provider goth.Provider) (string, error) {
+	if hp, ok := provider.(goth.HealthProvider); ok {
+		return hp.HealthEndpoint(), nil
+	}
+	sess, err := provider.BeginAuth("health")
+	if err != nil {
+		return "", err
+	}
+	authURL, err := sess.GetAuthURL()
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", errors.New("no endpoint to check")
+	}
+	return u.Scheme + "://" + u.Host + "/", nil
+}
@@ -0,0 +1,116 @@
+package gothic
+
+import (
+	"context"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+)
+
+// instrumented is 1 when the phases are instrumented, see Instrument.
+var instrumented int32
+
+/*
+Instrument enables or disables the instrumentation of the phases of the
+logins: the start of the authentication, the token exchange, the fetch of the
+user and the refresh of the tokens are timed, for Phases, and labeled for
+pprof with the gothic_operation and gothic_provider labels, so the CPU
+profiles of production show where the logins spend their time without a
+tracing stack. It can be toggled at any time, and costs nothing when disabled.
+
+The phases can be published with expvar:
+
+	gothic.Instrument(true)
+	expvar.Publish("gothic", expvar.Func(func() interface{} { return gothic.Phases() }))
+*/
+func Instrument(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&instrumented, 1)
+	} else {
+		atomic.StoreInt32(&instrumented, 0)
+	}
+}
+
+// PhaseStats are the timings of a phase of the logins, see Instrument.
+type PhaseStats struct {
+	Count  int64         `json:"count"`
+	Errors int64         `json:"errors"`
+	Total  time.Duration `json:"total_ns"`
+	Mean   time.Duration `json:"mean_ns"`
+	Max    time.Duration `json:"max_ns"`
+}
+
+// phaseCounters are the counters of a phase.
+type phaseCounters struct {
+	count  int64
+	errors int64
+	total  int64
+	max    int64
+}
+
+// phases are the counters of the phases, by operation.
+var phases = map[string]*phaseCounters{
+	OperationBeginAuth:        {},
+	OperationCompleteUserAuth: {},
+	OperationAuthorize:        {},
+	OperationFetchUser:        {},
+	OperationRefreshToken:     {},
+}
+
+// Phases returns the timings of the phases instrumented since they were
+// reset, by operation, e.g. OperationFetchUser. The time of
+// OperationCompleteUserAuth includes the ones of OperationAuthorize and
+// OperationFetchUser.
+func Phases() map[string]PhaseStats {
+	stats := make(map[string]PhaseStats, len(phases))
+	for op, c := range phases {
+		s := PhaseStats{
+			Count:  atomic.LoadInt64(&c.count),
+			Errors: atomic.LoadInt64(&c.errors),
+			Total:  time.Duration(atomic.LoadInt64(&c.total)),
+			Max:    time.Duration(atomic.LoadInt64(&c.max)),
+		}
+		if s.Count > 0 {
+			s.Mean = s.Total / time.Duration(s.Count)
+		}
+		stats[op] = s
+	}
+	return stats
+}
+
+// ResetPhases resets the timings of the phases.
+func ResetPhases() {
+	for _, c := range phases {
+		atomic.StoreInt64(&c.count, 0)
+		atomic.StoreInt64(&c.errors, 0)
+		atomic.StoreInt64(&c.total, 0)
+		atomic.StoreInt64(&c.max, 0)
+	}
+}
+
+// startPhase labels the goroutine with the phase, and returns the function
+// timing it and restoring the labels of ctx.
+func startPhase(ctx context.Context, operation, provider string) (context.Context, func(err error)) {
+	c, ok := phases[operation]
+	if !ok || atomic.LoadInt32(&instrumented) == 0 {
+		return ctx, func(error) {}
+	}
+	labeled := pprof.WithLabels(ctx, pprof.Labels("gothic_operation", operation, "gothic_provider", provider))
+	pprof.SetGoroutineLabels(labeled)
+	start := time.Now()
+	return labeled, func(err error) {
+		d := int64(time.Since(start))
+		pprof.SetGoroutineLabels(ctx)
+		atomic.AddInt64(&c.count, 1)
+		atomic.AddInt64(&c.total, d)
+		if err != nil {
+			atomic.AddInt64(&c.errors, 1)
+		}
+		for {
+			max := atomic.LoadInt64(&c.max)
+			if d <= max || atomic.CompareAndSwapInt64(&c.max, max, d) {
+				return
+			}
+		}
+	}
+}
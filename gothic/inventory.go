@@ -0,0 +1,203 @@
+package gothic
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// SessionInfo is the metadata of a session of an authenticated user, without
+// its tokens, e.g. for the "active sessions" page of the user or for the
+// support.
+type SessionInfo struct {
+	ID        string    `json:"id"`
+	Provider  string    `json:"provider"`
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// SessionInventory keeps the sessions of the authenticated users, e.g. next
+// to the sessions of a server-side store.
+type SessionInventory interface {
+	Create(info SessionInfo) error
+	// Get returns the session, or ErrSessionNotFound.
+	Get(id string) (*SessionInfo, error)
+	// Touch updates the time the session was last seen.
+	Touch(id string, lastSeen time.Time) error
+	// List returns the sessions of the user.
+	List(provider, userID string) ([]SessionInfo, error)
+	Delete(id string) error
+}
+
+// Inventory keeps the sessions of the users authenticated by
+// CompleteUserAuth and VerifySecondFactor, when set. The ID of the session
+// is kept in the gothic session of the user.
+var Inventory SessionInventory
+
+// sessionIDKey is the session key of the ID of the session in the Inventory.
+const sessionIDKey = "_gothic_sid"
+
+// createSession adds the session of the authenticated user to the Inventory,
+// when set, and its ID to the session, replacing the values of the session.
+func createSession(c *Config, req *http.Request, user goth.User, update *sessionUpdate) error {
+	if c.Inventory == nil {
+		return nil
+	}
+	b, err := goth.RandomBytes(32)
+	if err != nil {
+		return err
+	}
+	now := goth.Now()
+	info := SessionInfo{
+		ID:        base64.RawURLEncoding.EncodeToString(b),
+		Provider:  user.Provider,
+		UserID:    user.UserID,
+		Email:     user.Email,
+		IP:        ClientIP(req),
+		UserAgent: req.UserAgent(),
+		CreatedAt: now,
+		LastSeen:  now,
+	}
+	if err := c.Inventory.Create(info); err != nil {
+		return err
+	}
+
+	update.clear = true
+	update.set(sessionIDKey, info.ID)
+	return nil
+}
+
+// CurrentSession returns the session of the user in the Inventory, and
+// updates the time it was last seen, e.g. in a middleware of the
+// application. It returns ErrSessionNotFound when the session was deleted or
+// revoked, see InvalidateAllSessions.
+func CurrentSession(req *http.Request) (*SessionInfo, error) {
+	return sessionInfo(current(), req)
+}
+
+func sessionInfo(c *Config, req *http.Request) (*SessionInfo, error) {
+	inventory := c.Inventory
+	if inventory == nil {
+		return nil, errors.New("gothic: no session inventory is configured")
+	}
+	id, err := getFromSession(c, sessionIDKey, req)
+	if err != nil {
+		return nil, err
+	}
+	info, err := inventory.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := isRevoked(req.Context(), c, Subject{Provider: info.Provider, UserID: info.UserID}, info.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrSessionNotFound
+	}
+	info.LastSeen = goth.Now()
+	if err := inventory.Touch(id, info.LastSeen); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ActiveSessions returns the sessions of the user in the Inventory, the most
+// recently seen first.
+func ActiveSessions(provider, userID string) ([]SessionInfo, error) {
+	inventory := current().Inventory
+	if inventory == nil {
+		return nil, errors.New("gothic: no session inventory is configured")
+	}
+	sessions, err := inventory.List(provider, userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+	return sessions, nil
+}
+
+// SessionDetails returns the session of the Inventory, e.g. for the support.
+func SessionDetails(id string) (*SessionInfo, error) {
+	inventory := current().Inventory
+	if inventory == nil {
+		return nil, errors.New("gothic: no session inventory is configured")
+	}
+	return inventory.Get(id)
+}
+
+// MemoryInventory is a SessionInventory in memory, for a single instance of
+// the application.
+type MemoryInventory struct {
+	mu       sync.Mutex
+	sessions map[string]SessionInfo
+}
+
+// NewMemoryInventory returns an empty MemoryInventory.
+func NewMemoryInventory() *MemoryInventory {
+	return &MemoryInventory{sessions: map[string]SessionInfo{}}
+}
+
+// Create adds the session.
+func (m *MemoryInventory) Create(info SessionInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[info.ID] = info
+	return nil
+}
+
+// Get returns the session.
+func (m *MemoryInventory) Get(id string) (*SessionInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return &info, nil
+}
+
+// Touch updates the time the session was last seen.
+func (m *MemoryInventory) Touch(id string, lastSeen time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, ok := m.sessions[id]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	info.LastSeen = lastSeen
+	m.sessions[id] = info
+	return nil
+}
+
+// List returns the sessions of the user.
+func (m *MemoryInventory) List(provider, userID string) ([]SessionInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sessions []SessionInfo
+	for _, info := range m.sessions {
+		if info.Provider == provider && info.UserID == userID {
+			sessions = append(sessions, info)
+		}
+	}
+	return sessions, nil
+}
+
+// Delete removes the session.
+func (m *MemoryInventory) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
@@ -0,0 +1,239 @@
+package gothic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// FetchAllUsersConcurrency is the number of users FetchAllUsers fetches at
+// the same time.
+var FetchAllUsersConcurrency = 4
+
+// LinkedUser is the user of a provider linked to the session, or the error
+// fetching it.
+type LinkedUser struct {
+	Provider string
+	User     goth.User
+	// Refreshed is set when the access token of the user expired and was
+	// refreshed: the application should store the new tokens of the User.
+	Refreshed bool
+	Err       error
+}
+
+/*
+FetchAllUsers fetches the users of all the providers whose sessions the
+application stored in the session of the request, with StoreInSession and
+the name of the provider, e.g. the accounts a user linked. The users are
+fetched concurrently, at most FetchAllUsersConcurrency at a time, with
+FetchUser, and their expired access tokens are refreshed when the providers
+can. The users are sorted by provider, each with its error: the users of the
+sessions issued before InvalidateAllSessions revoked them have
+ErrSessionNotFound, see Revocations.
+*/
+func FetchAllUsers(ctx context.Context, req *http.Request) ([]LinkedUser, error) {
+	return fetchAllUsers(ctx, current(), req)
+}
+
+func fetchAllUsers(ctx context.Context, c *Config, req *http.Request) ([]LinkedUser, error) {
+	if err := storeErr(); err != nil {
+		return nil, err
+	}
+	session, err := c.Store.Get(req, c.SessionName)
+	if err != nil {
+		return nil, err
+	}
+	var linked []LinkedUser
+	values := map[string]interface{}{}
+	for key, value := range session.Values {
+		name, ok := key.(string)
+		if !ok || strings.HasPrefix(name, "_gothic") {
+			continue
+		}
+		if _, err := goth.GetProvider(name); errors.Is(err, goth.ErrProviderNotFound) {
+			// a value of the application
+			continue
+		}
+		linked = append(linked, LinkedUser{Provider: name})
+		values[name] = value
+	}
+	sort.Slice(linked, func(i, j int) bool { return linked[i].Provider < linked[j].Provider })
+
+	concurrency := FetchAllUsersConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range linked {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			// the users left aren't fetched
+			for j := i; j < len(linked); j++ {
+				linked[j].Err = ctx.Err()
+			}
+			wg.Wait()
+			return linked, revokeLinkedUsers(ctx, c, req, linked)
+		}
+		wg.Add(1)
+		go func(l *LinkedUser, value interface{}) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			l.User, l.Refreshed, l.Err = fetchLinkedUser(ctx, c, l.Provider, value)
+		}(&linked[i], values[linked[i].Provider])
+	}
+	wg.Wait()
+	return linked, revokeLinkedUsers(ctx, c, req, linked)
+}
+
+// revokeLinkedUsers replaces the users whose sessions were revoked by
+// InvalidateAllSessions since the user logged in with ErrSessionNotFound.
+func revokeLinkedUsers(ctx context.Context, c *Config, req *http.Request, linked []LinkedUser) error {
+	if c.Revocations == nil {
+		return nil
+	}
+	issuedAt := sessionIssuedAt(c, req)
+	for i := range linked {
+		if linked[i].Err != nil {
+			continue
+		}
+		revoked, err := isRevoked(ctx, c, SubjectOf(linked[i].User), issuedAt)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			linked[i].User, linked[i].Refreshed, linked[i].Err = goth.User{}, false, ErrSessionNotFound
+		}
+	}
+	return nil
+}
+
+// fetchLinkedUser fetches the user of the session value of the provider. Its
+// access token is refreshed first when the session knows it expired, or
+// after the fetch failed otherwise, when the provider can.
+func fetchLinkedUser(ctx context.Context, c *Config, providerName string, value interface{}) (goth.User, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return goth.User{}, false, err
+	}
+	provider, err := goth.GetProvider(providerName)
+	if err != nil {
+		return goth.User{}, false, err
+	}
+	data, ok := value.(string)
+	if !ok {
+		return goth.User{}, false, ErrSessionNotFound
+	}
+	marshaled, err := decodeSessionValue(c.Cipher, data)
+	if err != nil {
+		return goth.User{}, false, err
+	}
+	var tokens struct {
+		RefreshToken string
+		ExpiresAt    time.Time
+	}
+	// most sessions keep their tokens in these fields
+	_ = json.Unmarshal([]byte(marshaled), &tokens)
+	canRefresh := tokens.RefreshToken != "" && provider.RefreshTokenAvailable()
+
+	var token *oauth2.Token
+	if canRefresh && !tokens.ExpiresAt.IsZero() && !goth.Now().Before(tokens.ExpiresAt) {
+		if marshaled, token, err = refreshSession(ctx, provider, marshaled, tokens.RefreshToken); err != nil {
+			return goth.User{}, false, err
+		}
+	}
+	user, err := fetchSessionUser(ctx, provider, marshaled)
+	if err != nil && token == nil && canRefresh && ctx.Err() == nil && !errors.Is(err, ErrContextTimeout) {
+		// the access token may have expired without the session knowing
+		refreshed, t, rerr := refreshSession(ctx, provider, marshaled, tokens.RefreshToken)
+		if rerr != nil {
+			return goth.User{}, false, err
+		}
+		token = t
+		user, err = fetchSessionUser(ctx, provider, refreshed)
+	}
+	if err != nil {
+		return goth.User{}, false, err
+	}
+	if token == nil {
+		return user, false, nil
+	}
+	user.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		user.RefreshToken = token.RefreshToken
+	}
+	user.ExpiresAt = token.Expiry
+	return user, true, nil
+}
+
+func fetchSessionUser(ctx context.Context, provider goth.Provider, marshaled string) (goth.User, error) {
+	sess, err := provider.UnmarshalSession(marshaled)
+	if err != nil {
+		return goth.User{}, err
+	}
+	return FetchUser(ctx, provider, sess)
+}
+
+// refreshSession refreshes the access token of the marshaled session, and
+// returns the session with the new tokens.
+func refreshSession(ctx context.Context, provider goth.Provider, marshaled, refreshToken string) (string, *oauth2.Token, error) {
+	token, err := RefreshToken(ctx, provider, refreshToken)
+	if err != nil {
+		return "", nil, err
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(marshaled), &fields); err != nil {
+		return "", nil, err
+	}
+	fields["AccessToken"] = token.AccessToken
+	if token.RefreshToken != "" {
+		fields["RefreshToken"] = token.RefreshToken
+	}
+	if !token.Expiry.IsZero() {
+		fields["ExpiresAt"] = token.Expiry
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(b), token, nil
+}
+
+/*
+SessionUser returns the user of the session of the request, e.g. to
+authenticate the requests which aren't handled by the middleware of the
+application, such as the upgrades of WebSockets: the user of its session in
+the Inventory when it is set, or else the first of its users FetchAllUsers
+fetches without an error, whose session isn't revoked. It returns
+ErrSessionNotFound when the session has no user.
+*/
+func SessionUser(ctx context.Context, req *http.Request) (goth.User, error) {
+	c := current()
+	if c.Inventory != nil {
+		info, err := sessionInfo(c, req)
+		if err != nil {
+			return goth.User{}, err
+		}
+		return goth.User{Provider: info.Provider, UserID: info.UserID, Email: info.Email}, nil
+	}
+	users, err := fetchAllUsers(ctx, c, req)
+	if err != nil {
+		return goth.User{}, err
+	}
+	for _, u := range users {
+		if u.Err == nil {
+			return u.User, nil
+		}
+	}
+	return goth.User{}, ErrSessionNotFound
+}
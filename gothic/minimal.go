@@ -0,0 +1,94 @@
+package gothic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/andreimerlescu/goth"
+)
+
+/*
+MinimalSession, when set, keeps only what the callback needs in the session
+of the provider, e.g. the state and the code verifier, for the cookies to be
+as small as possible: the auth URL is replaced by its state, and the empty
+values are dropped. The users waiting for the second factor are kept without
+their tokens and RawData, which CompleteUserAuth returns with
+ErrSecondFactorRequired, and gives to Tokens when set: VerifySecondFactor
+then returns the user without them.
+*/
+var MinimalSession bool
+
+// TokenStore keeps the tokens of the users server-side, instead of the
+// sessions of the users.
+type TokenStore interface {
+	// SaveTokens saves the tokens of the user, authenticated by its
+	// provider, before the second factor is verified.
+	SaveTokens(ctx context.Context, user goth.User) error
+}
+
+// Tokens is given the tokens of the users authenticated by CompleteUserAuth,
+// when set.
+var Tokens TokenStore
+
+// saveTokens gives the tokens of the user to Tokens, when set.
+func saveTokens(ctx context.Context, c *Config, user goth.User) error {
+	if c.Tokens == nil {
+		return nil
+	}
+	return c.Tokens.SaveTokens(ctx, user)
+}
+
+// marshalSession marshals the session of the provider, in the session of the
+// user, keeping only the state of its auth URL with MinimalSession. The
+// sessions which aren't JSON objects are kept as they are.
+func marshalSession(sess goth.Session, authURL string) string {
+	marshaled := sess.Marshal()
+	if !MinimalSession {
+		return marshaled
+	}
+	var values map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader([]byte(marshaled)))
+	d.UseNumber()
+	if err := d.Decode(&values); err != nil {
+		return marshaled
+	}
+	for key, value := range values {
+		switch value {
+		case nil, "", "0001-01-01T00:00:00Z":
+			delete(values, key)
+		case authURL:
+			// validateState reads the state of the auth URL
+			values[key] = "?" + url.Values{"state": {stateOf(authURL)}}.Encode()
+		}
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return marshaled
+	}
+	return string(b)
+}
+
+// stateOf returns the state of the auth URL.
+func stateOf(authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("state")
+}
+
+// pendingUser returns the user kept in the session until the second factor
+// is verified, without its tokens with MinimalSession.
+func pendingUser(user goth.User) goth.User {
+	if !MinimalSession {
+		return user
+	}
+	user.RawData = nil
+	user.AccessToken = ""
+	user.AccessTokenSecret = ""
+	user.RefreshToken = ""
+	user.IDToken = ""
+	return user
+}
@@ -0,0 +1,81 @@
+package gothic
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// The operations reported to the Observers.
+const (
+	OperationBeginAuth          = "begin_auth"
+	OperationCompleteUserAuth   = "complete_user_auth"
+	OperationVerifySecondFactor = "verify_second_factor"
+	OperationLogout             = "logout"
+	// a panic recovered by Recover
+	OperationRecover = "recover"
+
+	// the token exchange and the fetch of the user of CompleteUserAuth,
+	// and the refresh of the access tokens, only traced by StartSpan
+	OperationAuthorize    = "authorize"
+	OperationFetchUser    = "fetch_user"
+	OperationRefreshToken = "refresh_token"
+)
+
+// Operation describes an operation of gothic once it is done.
+type Operation struct {
+	Name string
+	// Provider is the name of the provider, empty when it couldn't be
+	// determined from the request.
+	Provider string
+	Request  *http.Request
+	Start    time.Time
+	Duration time.Duration
+	// User is the user authenticated by CompleteUserAuth or
+	// VerifySecondFactor.
+	User goth.User
+	Err  error
+}
+
+// Observer is notified of the operations of gothic, e.g. to record metrics.
+type Observer func(op Operation)
+
+// Observers are notified of every operation, in order. They are called by the
+// goroutine handling the request, so they should be quick.
+var Observers []Observer
+
+func observe(c *Config, op Operation) {
+	if len(Observers) == 0 && !subscribed() {
+		return
+	}
+	op.Duration = time.Since(op.Start)
+	if op.Provider == "" {
+		op.Provider, _ = c.GetProviderName(op.Request)
+	}
+	for _, o := range Observers {
+		o(op)
+	}
+	publish(op)
+}
+
+// StartSpan starts the span of an operation of gothic when it is set, as a
+// child of the span of ctx, and returns the context of the new span with the
+// function ending it. The contexts are passed to the providers implementing
+// goth.ContextProvider and the sessions implementing goth.ContextSession, so
+// their requests are part of the trace. See the tracing package to trace
+// with OpenTelemetry.
+var StartSpan func(ctx context.Context, operation, provider string) (context.Context, func(err error))
+
+func startSpan(ctx context.Context, operation, provider string) (context.Context, func(err error)) {
+	ctx, endPhase := startPhase(ctx, operation, provider)
+	if StartSpan == nil {
+		return ctx, endPhase
+	}
+	ctx, end := StartSpan(ctx, operation, provider)
+	return ctx, func(err error) {
+		end(err)
+		endPhase(err)
+	}
+}
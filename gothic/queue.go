@@ -0,0 +1,165 @@
+package gothic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+var (
+	// Queue bounds the logins completing simultaneously against each
+	// provider in CompleteUserAuth, when set, so a slow provider can't tie
+	// up all the workers of the application.
+	Queue *LoginQueue
+
+	ErrProviderBusy = errors.New("too many logins in progress with the provider")
+)
+
+// LoginQueue bounds the logins in progress per provider. Logins beyond the
+// limit wait in a bounded queue, and the logins overflowing it are rejected
+// with ErrProviderBusy without waiting.
+type LoginQueue struct {
+	// MaxConcurrent is the number of logins in progress per provider.
+	MaxConcurrent int
+	// MaxQueued is the number of logins waiting per provider.
+	MaxQueued int
+	// MaxWait is the time logins wait before they are rejected with
+	// ErrProviderBusy, or until their request is canceled when it is 0.
+	MaxWait time.Duration
+
+	mu        sync.Mutex
+	providers map[string]*providerQueue
+}
+
+type providerQueue struct {
+	slots   chan struct{}
+	waiting int
+}
+
+// NewLoginQueue returns a LoginQueue.
+func NewLoginQueue(maxConcurrent, maxQueued int, maxWait time.Duration) *LoginQueue {
+	return &LoginQueue{MaxConcurrent: maxConcurrent, MaxQueued: maxQueued, MaxWait: maxWait}
+}
+
+// Acquire waits for the provider to have fewer logins in progress than
+// MaxConcurrent, and returns the function to call once the login is
+// complete. The providers which aren't used are rejected with the error of
+// goth.GetProvider, so the names of the requests can't grow the queues.
+func (q *LoginQueue) Acquire(ctx context.Context, provider string) (release func(), err error) {
+	if _, err := goth.GetProvider(provider); err != nil {
+		return nil, err
+	}
+	pq := q.queue(provider)
+	release = func() { <-pq.slots }
+	select {
+	case pq.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	q.mu.Lock()
+	if pq.waiting >= q.MaxQueued {
+		q.mu.Unlock()
+		return nil, ErrProviderBusy
+	}
+	pq.waiting++
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		pq.waiting--
+		q.mu.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if q.MaxWait > 0 {
+		timer := time.NewTimer(q.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case pq.slots <- struct{}{}:
+		return release, nil
+	case <-timeout:
+		return nil, ErrProviderBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (q *LoginQueue) queue(provider string) *providerQueue {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.providers == nil {
+		q.providers = map[string]*providerQueue{}
+	}
+	pq, ok := q.providers[provider]
+	if !ok {
+		max := q.MaxConcurrent
+		if max < 1 {
+			max = 1
+		}
+		pq = &providerQueue{slots: make(chan struct{}, max)}
+		q.providers[provider] = pq
+	}
+	return pq
+}
+
+// completeQueued completes the authentication once the Queue of the provider,
+// if any, lets it. The login holds its slot until the calls of the provider
+// return, including the ones which timed out but can't be canceled, so the
+// logins in progress with a slow provider stay bounded.
+func completeQueued(ctx context.Context, c *Config, req *http.Request, providerName string, update *sessionUpdate) (goth.User, error) {
+	if c.Queue != nil {
+		release, err := c.Queue.Acquire(ctx, providerName)
+		if err != nil {
+			return goth.User{}, err
+		}
+		calls := &inflight{}
+		ctx = context.WithValue(ctx, inflightKey, calls)
+		defer calls.finish(release)
+	}
+	return completeUserAuth(ctx, c, req, update)
+}
+
+// inflight counts the calls of the providers withTimeout left running after
+// their timeout, see completeQueued.
+type inflight struct {
+	mu      sync.Mutex
+	calls   int
+	release func()
+}
+
+func (f *inflight) add() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+}
+
+func (f *inflight) done() {
+	f.mu.Lock()
+	f.calls--
+	var release func()
+	if f.calls == 0 {
+		release, f.release = f.release, nil
+	}
+	f.mu.Unlock()
+	if release != nil {
+		release()
+	}
+}
+
+// finish calls release once the calls returned, now when none is running.
+func (f *inflight) finish(release func()) {
+	f.mu.Lock()
+	if f.calls > 0 {
+		f.release = release
+		f.mu.Unlock()
+		return
+	}
+	f.mu.Unlock()
+	release()
+}
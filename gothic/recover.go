@@ -0,0 +1,71 @@
+package gothic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// ErrPanic is matched by the errors of the panics recovered by Recover with
+// errors.Is.
+var ErrPanic = errors.New("gothic: panic recovered")
+
+// PanicError is the error of a panic recovered by Recover.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the goroutine which panicked.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("gothic: panic recovered: %v", e.Value)
+}
+
+// Is reports whether target is ErrPanic.
+func (e *PanicError) Is(target error) bool {
+	return target == ErrPanic
+}
+
+// Unwrap returns the value of the panic when it is an error, e.g. a runtime
+// error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// ErrorHandler writes the errors of the handlers of gothic and the panics
+// recovered by Recover. It defaults to WriteError.
+var ErrorHandler = WriteError
+
+// Recover recovers the panics of the handler, e.g. in the code of a provider
+// or of the session store, so a misbehaving provider can't crash the process.
+// The panic is passed as a *PanicError to the Observers, published as
+// EventPanic, and written by the ErrorHandler with a 500 status code.
+// http.ErrAbortHandler is panicked again, to abort the response.
+func Recover(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			err := &PanicError{Value: v, Stack: debug.Stack()}
+			observe(current(), Operation{Name: OperationRecover, Request: req, Start: start, Err: err})
+			ErrorHandler(res, req, http.StatusInternalServerError, err)
+		}()
+		handler.ServeHTTP(res, req)
+	})
+}
+
+// RecoverFunc is Recover for handler functions, e.g.
+// gothic.RecoverFunc(gothic.BeginAuthHandler).
+func RecoverFunc(handler http.HandlerFunc) http.HandlerFunc {
+	return Recover(handler).ServeHTTP
+}
@@ -0,0 +1,215 @@
+package gothic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+var (
+	// ReturnToParam is the parameter of the request starting the
+	// authentication with the destination of the user once authenticated, see
+	// ReturnTo.
+	ReturnToParam = "returnTo"
+
+	// AllowedRedirects is the allowlist of the destinations of ReturnToParam.
+	// When it is nil, only paths of the application, e.g. /dashboard, are
+	// allowed.
+	AllowedRedirects *RedirectAllowlist
+
+	ErrRedirectNotAllowed = errors.New("redirect destination not allowed")
+)
+
+// RedirectError is returned when a destination isn't allowed by
+// AllowedRedirects. It matches ErrRedirectNotAllowed with errors.Is.
+type RedirectError struct {
+	URL string
+}
+
+func (e *RedirectError) Error() string {
+	return "gothic: redirect to " + e.URL + " not allowed"
+}
+
+// Is reports whether target is ErrRedirectNotAllowed.
+func (e *RedirectError) Is(target error) bool {
+	return target == ErrRedirectNotAllowed
+}
+
+// The session key of the destination of the user.
+const returnToKey = "_gothic_return_to"
+
+// RedirectAllowlist allows redirecting users to destinations matching one of
+// its patterns.
+type RedirectAllowlist struct {
+	patterns []redirectPattern
+}
+
+type redirectPattern struct {
+	// scheme and host are empty for the paths of the application.
+	scheme, host string
+	// subdomains matches the subdomains of host instead of host.
+	subdomains bool
+	path       string
+	// prefix matches the paths under path instead of path.
+	prefix bool
+}
+
+// NewRedirectAllowlist returns the allowlist of the patterns, which are
+// either paths of the application, e.g. /dashboard, or absolute http and
+// https URLs, e.g. https://app.example.com/dashboard. A pattern may start its
+// host with "*." to match the subdomains of the host, e.g.
+// https://*.example.com/, and end its path with "*" to match the paths under
+// it, e.g. /account/*. The destinations must match the scheme and host of a
+// pattern exactly, ports included.
+func NewRedirectAllowlist(patterns ...string) (*RedirectAllowlist, error) {
+	l := &RedirectAllowlist{}
+	for _, pattern := range patterns {
+		p, err := parseRedirectPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		l.patterns = append(l.patterns, p)
+	}
+	return l, nil
+}
+
+func parseRedirectPattern(pattern string) (redirectPattern, error) {
+	invalid := func(reason string) (redirectPattern, error) {
+		return redirectPattern{}, fmt.Errorf("gothic: redirect pattern %q %s", pattern, reason)
+	}
+	u, err := parseRedirect(pattern)
+	if err != nil {
+		return invalid("isn't a path or an http URL")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return invalid("has a query or a fragment")
+	}
+
+	var p redirectPattern
+	if u.Host != "" {
+		p.scheme = u.Scheme
+		p.host = strings.ToLower(u.Host)
+		if strings.HasPrefix(p.host, "*.") {
+			p.subdomains = true
+			p.host = p.host[len("*."):]
+		}
+		if strings.Contains(p.host, "*") {
+			return invalid("has a wildcard which isn't the first label of the host")
+		}
+	}
+	p.path = u.Path
+	if strings.HasSuffix(p.path, "*") {
+		p.prefix = true
+		p.path = strings.TrimSuffix(p.path, "*")
+	}
+	if strings.Contains(p.path, "*") {
+		return invalid("has a wildcard which doesn't end the path")
+	}
+	if p.path == "" {
+		p.path = "/"
+	}
+	return p, nil
+}
+
+// parseRedirect parses a path of the application or an absolute http URL,
+// refusing what browsers could take for another host, e.g. //evil.example or
+// /\evil.example.
+func parseRedirect(raw string) (*url.URL, error) {
+	if raw == "" || strings.ContainsAny(raw, "\\\x00\t\r\n") {
+		return nil, errors.New("invalid redirect")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.User != nil || u.Opaque != "" {
+		return nil, errors.New("invalid redirect")
+	}
+	if u.Scheme == "" && u.Host == "" {
+		if !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") {
+			return nil, errors.New("invalid redirect")
+		}
+		return u, nil
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("invalid redirect")
+	}
+	return u, nil
+}
+
+// Allowed reports whether the destination matches a pattern of the
+// allowlist.
+func (l *RedirectAllowlist) Allowed(destination string) bool {
+	u, err := parseRedirect(destination)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Host)
+	p := path.Clean("/" + u.Path)
+	if strings.HasSuffix(u.Path, "/") && p != "/" {
+		p += "/"
+	}
+	for _, pattern := range l.patterns {
+		if pattern.scheme != u.Scheme {
+			continue
+		}
+		if pattern.subdomains {
+			if !strings.HasSuffix(host, "."+pattern.host) {
+				continue
+			}
+		} else if pattern.host != host {
+			continue
+		}
+		if pattern.prefix && strings.HasPrefix(p, pattern.path) || p == pattern.path {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRedirect returns a *RedirectError when the destination isn't
+// allowed by AllowedRedirects.
+func ValidateRedirect(destination string) error {
+	return validateRedirect(current(), destination)
+}
+
+func validateRedirect(c *Config, destination string) error {
+	if c.AllowedRedirects != nil {
+		if c.AllowedRedirects.Allowed(destination) {
+			return nil
+		}
+	} else if u, err := parseRedirect(destination); err == nil && u.Host == "" {
+		return nil
+	}
+	return &RedirectError{URL: destination}
+}
+
+// returnTo returns the destination requested by the request starting the
+// authentication, if any.
+func returnTo(c *Config, req *http.Request) (string, error) {
+	destination := req.URL.Query().Get(ReturnToParam)
+	if destination == "" && req.Method == http.MethodPost {
+		destination = req.PostFormValue(ReturnToParam)
+	}
+	if destination == "" {
+		return "", nil
+	}
+	return destination, validateRedirect(c, destination)
+}
+
+// ReturnTo returns the destination of the user, requested with
+// ReturnToParam when the authentication started, or an empty string when
+// none was requested. It must be called before CompleteUserAuth, which
+// clears the session. The destination is validated again, in case
+// AllowedRedirects changed.
+func ReturnTo(req *http.Request) (string, error) {
+	c := current()
+	destination, err := getFromSession(c, returnToKey, req)
+	if err != nil {
+		return "", nil
+	}
+	return destination, validateRedirect(c, destination)
+}
@@ -0,0 +1,144 @@
+package gothic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Subject is a user: its ID at a provider.
+type Subject struct {
+	Provider string
+	UserID   string
+}
+
+// SubjectOf returns the subject of the user.
+func SubjectOf(user goth.User) Subject {
+	return Subject{Provider: user.Provider, UserID: user.UserID}
+}
+
+// RevocationList keeps the times before which the sessions of the users are
+// revoked, for the sessions which can't be deleted from the server, e.g.
+// cookies or JWTs.
+type RevocationList interface {
+	Revoke(ctx context.Context, subject Subject, before time.Time) error
+	// RevokedBefore returns the time before which the sessions of the user
+	// are revoked, or the zero time.
+	RevokedBefore(ctx context.Context, subject Subject) (time.Time, error)
+}
+
+// Revocations keeps the revocations of InvalidateAllSessions, when set.
+var Revocations RevocationList
+
+// issuedAtKey is the session key of the time the user logged in, in Unix
+// nanoseconds, kept when Revocations is set.
+const issuedAtKey = "_gothic_iat"
+
+// InvalidateAllSessions signs the user out everywhere, e.g. when the account
+// is compromised: the sessions of the user are deleted from the Inventory,
+// and the sessions issued until now are revoked in the Revocations, which
+// CurrentSession, FetchAllUsers, SessionUser and IsRevoked check. The users
+// cached by FetchUser are forgotten.
+func InvalidateAllSessions(ctx context.Context, subject Subject) error {
+	c := current()
+	cachedUsers.forget(subject)
+	if c.Inventory == nil && c.Revocations == nil {
+		return errors.New("gothic: no session inventory nor revocation list is configured")
+	}
+	if c.Revocations != nil {
+		if err := c.Revocations.Revoke(ctx, subject, goth.Now()); err != nil {
+			return err
+		}
+	}
+	if c.Inventory != nil {
+		sessions, err := c.Inventory.List(subject.Provider, subject.UserID)
+		if err != nil {
+			return err
+		}
+		for _, info := range sessions {
+			if err := c.Inventory.Delete(info.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IsRevoked reports whether a session of the user issued at the time is
+// revoked, e.g. a cookie or a JWT of the application.
+func IsRevoked(ctx context.Context, subject Subject, issuedAt time.Time) (bool, error) {
+	return isRevoked(ctx, current(), subject, issuedAt)
+}
+
+func isRevoked(ctx context.Context, c *Config, subject Subject, issuedAt time.Time) (bool, error) {
+	revocations := c.Revocations
+	if revocations == nil {
+		return false, nil
+	}
+	before, err := revocations.RevokedBefore(ctx, subject)
+	if err != nil {
+		return false, err
+	}
+	if before.IsZero() {
+		return false, nil
+	}
+	return !issuedAt.After(before), nil
+}
+
+// issued records the time the user logged in in the session, for the
+// Revocations of the sessions without an Inventory.
+func issued(c *Config, update *sessionUpdate) {
+	if c.Revocations != nil {
+		update.set(issuedAtKey, strconv.FormatInt(goth.Now().UnixNano(), 10))
+	}
+}
+
+// sessionIssuedAt returns the time the user of the session logged in, or the
+// zero time when it isn't known, e.g. for the sessions issued before the
+// Revocations were set, which are revoked with the sessions of the user.
+func sessionIssuedAt(c *Config, req *http.Request) time.Time {
+	value, err := getFromSession(c, issuedAtKey, req)
+	if err != nil {
+		return time.Time{}
+	}
+	nanos, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// MemoryRevocationList is a RevocationList in memory, for a single instance
+// of the application.
+type MemoryRevocationList struct {
+	mu     sync.Mutex
+	before map[Subject]time.Time
+}
+
+// NewMemoryRevocationList returns an empty MemoryRevocationList.
+func NewMemoryRevocationList() *MemoryRevocationList {
+	return &MemoryRevocationList{before: map[Subject]time.Time{}}
+}
+
+// Revoke revokes the sessions of the user issued before the time.
+func (l *MemoryRevocationList) Revoke(ctx context.Context, subject Subject, before time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if before.After(l.before[subject]) {
+		l.before[subject] = before
+	}
+	return nil
+}
+
+// RevokedBefore returns the time before which the sessions of the user are
+// revoked.
+func (l *MemoryRevocationList) RevokedBefore(ctx context.Context, subject Subject) (time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.before[subject], nil
+}
@@ -0,0 +1,182 @@
+package gothic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// SecondFactor verifies a second factor of users who signed in with a
+// provider, e.g. the codes of an authenticator app with the totp package.
+type SecondFactor interface {
+	// Required reports whether the user must verify the second factor.
+	Required(user goth.User) (bool, error)
+	// Verify checks the code entered by the user.
+	Verify(user goth.User, code string) error
+}
+
+var (
+	// StepUp is the second factor required by CompleteUserAuth, when set.
+	StepUp SecondFactor
+	// StepUpTimeout is the time users have to verify the second factor after
+	// signing in with the provider.
+	StepUpTimeout = 5 * time.Minute
+	// StepUpAttempts is the number of codes users may try. They are counted
+	// in the session, so stores keeping it on the client, like the cookie
+	// store, should be combined with rate limiting.
+	StepUpAttempts = 5
+
+	ErrSecondFactorRequired = errors.New("second factor verification required")
+	ErrSecondFactorFailed   = errors.New("second factor verification failed")
+	ErrSecondFactorExpired  = errors.New("second factor verification expired")
+)
+
+// stepUpKey is the session key of the user waiting for the second factor.
+const stepUpKey = "_gothic_step_up"
+
+type pendingStepUp struct {
+	User      goth.User
+	ExpiresAt time.Time
+	Attempts  int
+	// IP is the address the session is bound to, see BindIP.
+	IP string `json:",omitempty"`
+	// UserAgent is the fingerprint of the user agent the session is bound
+	// to, see BindUserAgent.
+	UserAgent string `json:",omitempty"`
+}
+
+// check checks that the request comes from the client the pending
+// verification is bound to.
+func (p *pendingStepUp) check(req *http.Request) error {
+	if err := checkIP(req, p.IP); err != nil {
+		return err
+	}
+	return checkUserAgent(req, p.UserAgent)
+}
+
+// requireStepUp keeps the user in the session until the second factor is
+// verified, when StepUp requires it or the login is anomalous.
+func requireStepUp(c *Config, req *http.Request, user goth.User, anomalous bool, update *sessionUpdate) (goth.User, error) {
+	required, err := c.StepUp.Required(user)
+	if err != nil {
+		return goth.User{}, err
+	}
+	if !required && !anomalous {
+		return user, nil
+	}
+
+	err = storePendingStepUp(update, &pendingStepUp{
+		User:      pendingUser(user),
+		ExpiresAt: goth.Now().Add(StepUpTimeout),
+		// the request was checked against the address the provider
+		// session was bound to
+		IP:        boundIP(req),
+		UserAgent: userAgentFingerprint(req),
+	})
+	if err != nil {
+		return goth.User{}, err
+	}
+	return user, ErrSecondFactorRequired
+}
+
+/*
+VerifySecondFactor completes the authentication of a user for whom
+CompleteUserAuth returned ErrSecondFactorRequired, with the code read from
+the "code" query parameter or form value. The user is only returned once the
+code is verified by StepUp. The session is saved once, before
+VerifySecondFactor returns.
+*/
+func VerifySecondFactor(res http.ResponseWriter, req *http.Request) (user goth.User, err error) {
+	c := current()
+	stepUp := c.StepUp
+	start := time.Now()
+	provider := ""
+	update := &sessionUpdate{}
+	defer func() {
+		if saveErr := update.save(c, req, res); saveErr != nil && err == nil {
+			user, err = goth.User{}, saveErr
+		}
+		observe(c, Operation{Name: OperationVerifySecondFactor, Provider: provider, Request: req, Start: start, User: user, Err: err})
+	}()
+
+	if stepUp == nil {
+		return goth.User{}, errors.New("gothic: no second factor is configured")
+	}
+	pending, err := getPendingStepUp(c, req)
+	if err != nil {
+		return goth.User{}, err
+	}
+	provider = pending.User.Provider
+	err = pending.check(req)
+	if err != nil {
+		return goth.User{}, err
+	}
+	if goth.Now().After(pending.ExpiresAt) {
+		update.remove(stepUpKey)
+		return goth.User{}, ErrSecondFactorExpired
+	}
+
+	verifyErr := stepUp.Verify(pending.User, req.FormValue("code"))
+	if verifyErr != nil {
+		pending.Attempts++
+		if pending.Attempts >= StepUpAttempts {
+			update.remove(stepUpKey)
+		} else if err := storePendingStepUp(update, pending); err != nil {
+			return goth.User{}, err
+		}
+		return goth.User{}, fmt.Errorf("%w: %v", ErrSecondFactorFailed, verifyErr)
+	}
+
+	update.remove(stepUpKey)
+	err = loggedIn(c, req, pending.User, update)
+	if err != nil {
+		return goth.User{}, err
+	}
+	return pending.User, nil
+}
+
+// SecondFactorUser returns the user waiting to verify the second factor, e.g.
+// to greet them, or to enroll them when StepUp requires it from all users.
+// The user is not authenticated yet.
+func SecondFactorUser(req *http.Request) (goth.User, error) {
+	pending, err := getPendingStepUp(current(), req)
+	if err != nil {
+		return goth.User{}, err
+	}
+	if err := pending.check(req); err != nil {
+		return goth.User{}, err
+	}
+	if goth.Now().After(pending.ExpiresAt) {
+		return goth.User{}, ErrSecondFactorExpired
+	}
+	return pending.User, nil
+}
+
+func getPendingStepUp(c *Config, req *http.Request) (*pendingStepUp, error) {
+	value, err := getFromSession(c, stepUpKey, req)
+	if err != nil {
+		return nil, err
+	}
+	pending := &pendingStepUp{}
+	err = json.Unmarshal([]byte(value), pending)
+	if err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// storePendingStepUp stores the user in a new session, replacing the session
+// of the provider which was logged out.
+func storePendingStepUp(update *sessionUpdate, pending *pendingStepUp) error {
+	b, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	update.clear = true
+	update.set(stepUpKey, string(b))
+	return nil
+}
@@ -0,0 +1,116 @@
+package gothic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// MaxCookieSize is the size of the Set-Cookie headers of the sessions, name
+// and attributes included, above which saving a session fails with a
+// *SessionTooLargeError: the browsers drop the cookies larger than 4096
+// bytes without a word, and the callback then fails with
+// ErrSessionNotFound. The size isn't checked when it is zero.
+var MaxCookieSize = 4096
+
+// ErrSessionTooLarge is matched by the errors of the sessions too large for
+// a cookie with errors.Is.
+var ErrSessionTooLarge = errors.New("session too large")
+
+// SessionTooLargeError is returned when a session is too large for a cookie,
+// e.g. with the tokens of a provider issuing large JWTs. Use a server-side
+// store, e.g. UseFilesystem, for these providers.
+type SessionTooLargeError struct {
+	// Key is the key of the largest value of the session.
+	Key string
+	// Size is the size of the cookie, or, when the store refused to encode
+	// the session, the least size of its cookie.
+	Size int
+	Max  int
+}
+
+func (e *SessionTooLargeError) Error() string {
+	return fmt.Sprintf("gothic: the session is %d bytes, above the %d bytes of a cookie, its largest value is %s", e.Size, e.Max, e.Key)
+}
+
+// Is reports whether target is ErrSessionTooLarge.
+func (e *SessionTooLargeError) Is(target error) bool {
+	return target == ErrSessionTooLarge
+}
+
+// saveSession saves the session, once the cookies the store sets are checked
+// against MaxCookieSize, whatever the store: the server-side stores only set
+// the ID of the session.
+func saveSession(req *http.Request, res http.ResponseWriter, session *sessions.Session) error {
+	if MaxCookieSize <= 0 {
+		return session.Save(req, res)
+	}
+	w := &cookieWriter{header: http.Header{}}
+	if err := session.Save(req, w); err != nil {
+		// securecookie refuses to encode the values longer than its
+		// MaxLength, with an error it doesn't export
+		var cookieErr securecookie.Error
+		if errors.As(err, &cookieErr) && cookieErr.IsUsage() {
+			if size := encodedSize(session); size > MaxCookieSize {
+				key := largestValue(session)
+				return &SessionTooLargeError{Key: key, Size: size, Max: MaxCookieSize}
+			}
+		}
+		return err
+	}
+	for _, cookie := range w.header["Set-Cookie"] {
+		if len(cookie) > MaxCookieSize {
+			key := largestValue(session)
+			return &SessionTooLargeError{Key: key, Size: len(cookie), Max: MaxCookieSize}
+		}
+	}
+	for name, values := range w.header {
+		for _, value := range values {
+			res.Header().Add(name, value)
+		}
+	}
+	return nil
+}
+
+// sizeCodec encodes the sessions without a limit, to measure them.
+var sizeCodec = securecookie.New(make([]byte, 32), nil).MaxLength(0)
+
+// encodedSize returns the size of the cookie of the session encoded by
+// securecookie, without encryption, i.e. the least the session takes in a
+// cookie, or 0 when it can't be encoded.
+func encodedSize(session *sessions.Session) int {
+	value, err := sizeCodec.Encode(session.Name(), session.Values)
+	if err != nil {
+		return 0
+	}
+	return len(session.Name()) + len("=") + len(value)
+}
+
+// largestValue returns the key of the largest value of the session.
+func largestValue(session *sessions.Session) (largest string) {
+	max := -1
+	for key, value := range session.Values {
+		if n := len(fmt.Sprint(value)); n > max {
+			largest, max = fmt.Sprint(key), n
+		}
+	}
+	return largest
+}
+
+// cookieWriter keeps the headers of a session save, i.e. its cookies.
+type cookieWriter struct {
+	header http.Header
+}
+
+func (w *cookieWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *cookieWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *cookieWriter) WriteHeader(int) {}
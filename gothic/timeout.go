@@ -0,0 +1,201 @@
+package gothic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// The time the operations with the providers have, each its own: a slow
+// userinfo endpoint doesn't get the budget of the redirects to the providers.
+// The operations aren't limited when they are zero. WithTimeout overrides
+// them for a request.
+var (
+	// BeginAuthTimeout is the time the providers have to start the
+	// authentication, e.g. with a discovery or a pushed authorization request.
+	BeginAuthTimeout = defaultTimeout
+	// AuthorizeTimeout is the time of the token exchange.
+	AuthorizeTimeout = defaultTimeout
+	// FetchUserTimeout is the time the providers have to fetch the users.
+	FetchUserTimeout = defaultTimeout
+	// RefreshTokenTimeout is the time the providers have to refresh the
+	// access tokens.
+	RefreshTokenTimeout = defaultTimeout
+)
+
+// TimeoutError is returned when an operation with a provider takes longer
+// than its timeout. It matches ErrContextTimeout and context.DeadlineExceeded
+// with errors.Is.
+type TimeoutError struct {
+	// Operation is the operation, e.g. OperationFetchUser.
+	Operation string
+	Timeout   time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("gothic: %s timed out after %s", e.Operation, e.Timeout)
+}
+
+// Is reports whether target is ErrContextTimeout or context.DeadlineExceeded.
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrContextTimeout || target == context.DeadlineExceeded
+}
+
+// CanceledError is returned when the context of the caller is canceled
+// during an operation with a provider. It matches ErrContextCanceled and the
+// error of the context, e.g. context.Canceled, with errors.Is.
+type CanceledError struct {
+	// Operation is the operation, e.g. OperationFetchUser.
+	Operation string
+	Err       error
+}
+
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("gothic: %s canceled: %v", e.Operation, e.Err)
+}
+
+// Is reports whether target is ErrContextCanceled.
+func (e *CanceledError) Is(target error) bool {
+	return target == ErrContextCanceled
+}
+
+// Unwrap returns the error of the context.
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// WithTimeout returns a copy of ctx overriding the timeout of the operation,
+// one of OperationBeginAuth, OperationAuthorize, OperationFetchUser and
+// OperationRefreshToken, e.g. for the requests of a batch job, which can wait
+// longer than the users:
+//
+//	req = req.WithContext(gothic.WithTimeout(req.Context(), gothic.OperationFetchUser, time.Minute))
+func WithTimeout(ctx context.Context, operation string, timeout time.Duration) context.Context {
+	timeouts := map[string]time.Duration{}
+	if parent, ok := ctx.Value(timeoutKey).(map[string]time.Duration); ok {
+		for op, d := range parent {
+			timeouts[op] = d
+		}
+	}
+	timeouts[operation] = timeout
+	return context.WithValue(ctx, timeoutKey, timeouts)
+}
+
+// timeoutOf returns the timeout of the operation, of WithTimeout or of the
+// package variables.
+func timeoutOf(ctx context.Context, operation string) time.Duration {
+	if timeouts, ok := ctx.Value(timeoutKey).(map[string]time.Duration); ok {
+		if d, ok := timeouts[operation]; ok {
+			return d
+		}
+	}
+	switch operation {
+	case OperationBeginAuth:
+		return BeginAuthTimeout
+	case OperationAuthorize:
+		return AuthorizeTimeout
+	case OperationFetchUser:
+		return FetchUserTimeout
+	case OperationRefreshToken:
+		return RefreshTokenTimeout
+	}
+	return 0
+}
+
+// withTimeout calls fn with a context canceled after the timeout of the
+// operation. When the provider honors the context, e.g. a goth.ContextProvider,
+// fn is called directly and returns once the context is done. The providers
+// ignoring the context aren't waited for once it is done, as their requests
+// can't be canceled: fn must not write to the variables of the caller, which
+// are only read once withTimeout returns without an error, and it is counted
+// by the inflight calls of the context, if any, until it returns. The panics
+// of fn are panicked again by the caller, for Recover.
+func withTimeout(ctx context.Context, operation string, honorsContext bool, fn func(ctx context.Context) error) error {
+	timeout := timeoutOf(ctx, operation)
+	if timeout <= 0 {
+		return canceled(ctx, operation, fn(ctx))
+	}
+	opCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if honorsContext {
+		err := fn(opCtx)
+		if err != nil && ctx.Err() == nil && errors.Is(opCtx.Err(), context.DeadlineExceeded) {
+			return &TimeoutError{Operation: operation, Timeout: timeout}
+		}
+		return canceled(ctx, operation, err)
+	}
+
+	type result struct {
+		err      error
+		panicked bool
+		value    interface{}
+	}
+	done := make(chan result, 1)
+	calls, _ := ctx.Value(inflightKey).(*inflight)
+	if calls != nil {
+		calls.add()
+	}
+	go func() {
+		if calls != nil {
+			defer calls.done()
+		}
+		panicked := true
+		defer func() {
+			if panicked {
+				done <- result{panicked: true, value: recover()}
+			}
+		}()
+		err := fn(opCtx)
+		panicked = false
+		done <- result{err: err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.panicked {
+			panic(r.value)
+		}
+		if r.err != nil && ctx.Err() == nil && errors.Is(opCtx.Err(), context.DeadlineExceeded) {
+			return &TimeoutError{Operation: operation, Timeout: timeout}
+		}
+		return canceled(ctx, operation, r.err)
+	case <-opCtx.Done():
+		if err := ctx.Err(); err != nil {
+			return &CanceledError{Operation: operation, Err: err}
+		}
+		return &TimeoutError{Operation: operation, Timeout: timeout}
+	}
+}
+
+// canceled returns a CanceledError instead of the error of an operation
+// failing once the context of the caller is done.
+func canceled(ctx context.Context, operation string, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	return &CanceledError{Operation: operation, Err: ctx.Err()}
+}
+
+// RefreshToken refreshes the access token with the provider, within the
+// RefreshTokenTimeout, traced by StartSpan.
+func RefreshToken(ctx context.Context, provider goth.Provider, refreshToken string) (*oauth2.Token, error) {
+	ctx, end := startSpan(ctx, OperationRefreshToken, provider.Name())
+	var token *oauth2.Token
+	err := withTimeout(ctx, OperationRefreshToken, false, func(context.Context) error {
+		t, err := provider.RefreshToken(refreshToken)
+		if err != nil {
+			return err
+		}
+		token = t
+		return nil
+	})
+	end(err)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
@@ -0,0 +1,173 @@
+package gothic
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+var (
+	// UserCacheTTL is how long the users fetched by FetchUser and
+	// CompleteUserAuth are cached, by the hash of their access token, for
+	// the middleware resolving the user of every request against the rate
+	// limited APIs of the providers. The users aren't cached when it is zero,
+	// the default, nor when their session doesn't marshal its access token
+	// as AccessToken or access_token.
+	UserCacheTTL time.Duration
+	// UserCacheSize is the number of users cached.
+	UserCacheSize = 10000
+)
+
+// cachedUsers caches the users of the access tokens.
+var cachedUsers = &userCache{entries: map[[sha256.Size]byte]cachedUser{}}
+
+type userCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]cachedUser
+}
+
+type cachedUser struct {
+	user    goth.User
+	expires time.Time
+}
+
+// FetchUser fetches the user of the session, authorized by the provider, with
+// the context when the provider is a goth.ContextProvider. The users are
+// served from the cache for UserCacheTTL after they are fetched, unless the
+// session has no access token. The users revoked by InvalidateAllSessions are
+// forgotten.
+func FetchUser(ctx context.Context, provider goth.Provider, sess goth.Session) (goth.User, error) {
+	if UserCacheTTL <= 0 {
+		return fetchUser(ctx, provider, sess)
+	}
+	key, ok := userCacheKey(provider, sess)
+	if !ok {
+		return fetchUser(ctx, provider, sess)
+	}
+	if user, ok := cachedUsers.get(key); ok {
+		return user, nil
+	}
+	user, err := fetchUser(ctx, provider, sess)
+	if err == nil {
+		cachedUsers.put(key, user)
+	}
+	return user, err
+}
+
+// userCacheKey returns the hash of the provider name and the access token of
+// the session. goth.Session has no method returning the access token, so it
+// is read from the AccessToken or access_token field of the marshaled
+// session: the sessions marshaling it under another name, e.g. the "at" of
+// azureadv2, aren't cached.
+func userCacheKey(provider goth.Provider, sess goth.Session) ([sha256.Size]byte, bool) {
+	var token struct {
+		AccessToken      string
+		OAuthAccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal([]byte(sess.Marshal()), &token); err != nil {
+		return [sha256.Size]byte{}, false
+	}
+	if token.AccessToken == "" {
+		token.AccessToken = token.OAuthAccessToken
+	}
+	if token.AccessToken == "" {
+		return [sha256.Size]byte{}, false
+	}
+	return sha256.Sum256([]byte(provider.Name() + "\x00" + token.AccessToken)), true
+}
+
+func (c *userCache) get(key [sha256.Size]byte) (goth.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return goth.User{}, false
+	}
+	if !goth.Now().Before(e.expires) {
+		delete(c.entries, key)
+		return goth.User{}, false
+	}
+	return copyUser(e.user), true
+}
+
+func (c *userCache) put(key [sha256.Size]byte, user goth.User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := goth.Now()
+	if len(c.entries) >= UserCacheSize {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		// evict arbitrary users when none expired
+		for k := range c.entries {
+			if len(c.entries) < UserCacheSize {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	if UserCacheSize > 0 {
+		c.entries[key] = cachedUser{user: copyUser(user), expires: now.Add(UserCacheTTL)}
+	}
+}
+
+// forget removes the users of the subject.
+func (c *userCache) forget(subject Subject) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.entries {
+		if SubjectOf(e.user) == subject {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// copyUser copies the RawData of the user, including the maps and the slices
+// it contains, so the callers modifying it don't modify the cache.
+func copyUser(user goth.User) goth.User {
+	if user.RawData != nil {
+		user.RawData = copyValue(reflect.ValueOf(user.RawData)).Interface().(map[string]interface{})
+	}
+	return user
+}
+
+// copyValue copies the maps and the slices of the value. The pointers are
+// shared.
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(copyValue(v.Elem()))
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), copyValue(iter.Value()))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return c
+	}
+	return v
+}
@@ -0,0 +1,321 @@
+/*
+Package bench measures the latency and the throughput of the session stores
+of gothic, any sessions.Store, under concurrency, so the authors of the stores
+and the operators can compare the backends, e.g. the cookies against Redis.
+
+Each worker writes a session with values of Options.ValueSize bytes, like the
+session of a provider saved by gothic, and reads it back Options.Reads times
+with the cookie of the write, like the middleware of the application:
+
+	result, err := bench.Run(ctx, store, bench.Options{Concurrency: 64, Duration: 30 * time.Second})
+	fmt.Println(result)
+
+Benchmark runs the same operations in a benchmark:
+
+	func BenchmarkRedisStore(b *testing.B) {
+		bench.Benchmark(b, newRedisStore(b), bench.Options{})
+	}
+*/
+package bench
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// Options are the options of Run and Benchmark.
+type Options struct {
+	// Concurrency is the number of workers, GOMAXPROCS by default.
+	Concurrency int
+	// Duration is how long Run runs, unless Operations is set. Ten seconds
+	// by default.
+	Duration time.Duration
+	// Operations is the number of writes Run makes, each followed by its
+	// reads.
+	Operations int
+	// Reads is the number of reads of each session written, 1 by default.
+	Reads int
+	// ValueSize is the size of the value of the sessions, 1024 bytes by
+	// default.
+	ValueSize int
+	// SessionName is the name of the sessions, "_gothic_session" by default.
+	SessionName string
+}
+
+func (o Options) withDefaults() Options {
+	if o.Concurrency <= 0 {
+		o.Concurrency = runtime.GOMAXPROCS(0)
+	}
+	if o.Duration <= 0 && o.Operations <= 0 {
+		o.Duration = 10 * time.Second
+	}
+	if o.Reads <= 0 {
+		o.Reads = 1
+	}
+	if o.ValueSize <= 0 {
+		o.ValueSize = 1024
+	}
+	if o.SessionName == "" {
+		o.SessionName = "_gothic_session"
+	}
+	return o
+}
+
+// Latency are the percentiles of the latency of an operation.
+type Latency struct {
+	Count int
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+func (l Latency) String() string {
+	return fmt.Sprintf("%d ops, mean %s, p50 %s, p90 %s, p99 %s, max %s", l.Count, l.Mean, l.P50, l.P90, l.P99, l.Max)
+}
+
+// latencyOf returns the percentiles of the durations, which it sorts.
+func latencyOf(durations []time.Duration) Latency {
+	l := Latency{Count: len(durations)}
+	if len(durations) == 0 {
+		return l
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	at := func(p float64) time.Duration {
+		return durations[int(p*float64(len(durations)-1))]
+	}
+	l.Mean = total / time.Duration(len(durations))
+	l.P50, l.P90, l.P99, l.Max = at(0.5), at(0.9), at(0.99), durations[len(durations)-1]
+	return l
+}
+
+// Result is the result of Run.
+type Result struct {
+	Concurrency int
+	Duration    time.Duration
+	Read        Latency
+	Write       Latency
+	// Throughput is the number of operations, reads and writes, per second.
+	Throughput float64
+	// Errors is the number of operations which failed, and Err the first
+	// of their errors.
+	Errors int
+	Err    error
+}
+
+func (r *Result) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d workers for %s: %.0f ops/s\n", r.Concurrency, r.Duration.Round(time.Millisecond), r.Throughput)
+	fmt.Fprintf(&b, "write: %s\n", r.Write)
+	fmt.Fprintf(&b, "read:  %s\n", r.Read)
+	if r.Errors > 0 {
+		fmt.Fprintf(&b, "errors: %d, first: %v\n", r.Errors, r.Err)
+	}
+	return b.String()
+}
+
+// ErrValueMismatch is the error of the reads which don't return the value
+// written.
+var ErrValueMismatch = errors.New("bench: the session read isn't the session written")
+
+// worker writes and reads the sessions of a goroutine.
+type worker struct {
+	store  sessions.Store
+	opts   Options
+	value  string
+	reads  []time.Duration
+	writes []time.Duration
+	errors int
+	err    error
+}
+
+func newWorker(store sessions.Store, opts Options) (*worker, error) {
+	b := make([]byte, (opts.ValueSize+1)/2)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	// random values, which the stores compressing the sessions can't shrink
+	return &worker{store: store, opts: opts, value: hex.EncodeToString(b)[:opts.ValueSize]}, nil
+}
+
+func (w *worker) fail(err error) {
+	w.errors++
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// run writes a session and reads it back.
+func (w *worker) run() {
+	req := httptest.NewRequest(http.MethodGet, "/auth/callback", nil)
+	res := httptest.NewRecorder()
+	start := time.Now()
+	err := w.write(req, res)
+	w.writes = append(w.writes, time.Since(start))
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	cookies := res.Result().Cookies()
+	for i := 0; i < w.opts.Reads; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		start := time.Now()
+		err := w.read(req)
+		w.reads = append(w.reads, time.Since(start))
+		if err != nil {
+			w.fail(err)
+		}
+	}
+}
+
+func (w *worker) write(req *http.Request, res http.ResponseWriter) error {
+	session, err := w.store.New(req, w.opts.SessionName)
+	if session == nil {
+		return err
+	}
+	session.Values["bench"] = w.value
+	return w.store.Save(req, res, session)
+}
+
+func (w *worker) read(req *http.Request) error {
+	session, err := w.store.Get(req, w.opts.SessionName)
+	if err != nil {
+		return err
+	}
+	if session.Values["bench"] != w.value {
+		return ErrValueMismatch
+	}
+	return nil
+}
+
+// Run writes and reads sessions with the store from Options.Concurrency
+// goroutines, for Options.Duration or until Options.Operations writes are
+// made, or until ctx is done, and returns their latency and throughput. The
+// operations failing are counted in the result, Run only fails when it
+// couldn't start.
+func Run(ctx context.Context, store sessions.Store, opts Options) (*Result, error) {
+	opts = opts.withDefaults()
+	workers := make([]*worker, opts.Concurrency)
+	for i := range workers {
+		w, err := newWorker(store, opts)
+		if err != nil {
+			return nil, err
+		}
+		workers[i] = w
+	}
+	if opts.Operations <= 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	operations := make(chan struct{})
+	go func() {
+		defer close(operations)
+		for i := 0; opts.Operations <= 0 || i < opts.Operations; i++ {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case operations <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			for range operations {
+				w.run()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	r := &Result{Concurrency: opts.Concurrency, Duration: time.Since(start)}
+	var reads, writes []time.Duration
+	for _, w := range workers {
+		reads = append(reads, w.reads...)
+		writes = append(writes, w.writes...)
+		r.Errors += w.errors
+		if r.Err == nil {
+			r.Err = w.err
+		}
+	}
+	r.Read, r.Write = latencyOf(reads), latencyOf(writes)
+	if r.Duration > 0 {
+		r.Throughput = float64(len(reads)+len(writes)) / r.Duration.Seconds()
+	}
+	return r, nil
+}
+
+// Benchmark writes and reads b.N sessions with the store, in parallel with
+// b.RunParallel, with about Options.Concurrency goroutines, and reports the
+// 99th percentiles of their latency. The benchmark fails when an operation
+// fails.
+func Benchmark(b *testing.B, store sessions.Store, opts Options) {
+	b.Helper()
+	opts = opts.withDefaults()
+	if opts.Concurrency > 1 {
+		b.SetParallelism(opts.Concurrency / runtime.GOMAXPROCS(0))
+	}
+	var (
+		mu     sync.Mutex
+		reads  []time.Duration
+		writes []time.Duration
+		err    error
+	)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		w, werr := newWorker(store, opts)
+		if werr != nil {
+			mu.Lock()
+			err = werr
+			mu.Unlock()
+			return
+		}
+		for pb.Next() {
+			w.run()
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		reads = append(reads, w.reads...)
+		writes = append(writes, w.writes...)
+		if err == nil {
+			err = w.err
+		}
+	})
+	b.StopTimer()
+	if err != nil {
+		b.Fatal(err)
+	}
+	read, write := latencyOf(reads), latencyOf(writes)
+	b.ReportMetric(float64(read.P99.Nanoseconds()), "read-p99-ns")
+	b.ReportMetric(float64(write.P99.Nanoseconds()), "write-p99-ns")
+}
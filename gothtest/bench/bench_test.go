@@ -0,0 +1,73 @@
+package bench_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/andreimerlescu/goth/gothtest/bench"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Run(t *testing.T) {
+	a := assert.New(t)
+	stores := map[string]sessions.Store{
+		"cookie":     sessions.NewCookieStore([]byte("test-session-key")),
+		"filesystem": sessions.NewFilesystemStore(t.TempDir(), []byte("test-session-key")),
+	}
+	for name, store := range stores {
+		result, err := bench.Run(context.Background(), store, bench.Options{Concurrency: 4, Operations: 100, Reads: 3})
+		a.NoError(err, name)
+		a.Equal(4, result.Concurrency, name)
+		a.Equal(100, result.Write.Count, name)
+		a.Equal(300, result.Read.Count, name)
+		a.Zero(result.Errors, name)
+		a.NoError(result.Err, name)
+		a.True(result.Read.P50 <= result.Read.P99 && result.Read.P99 <= result.Read.Max, name)
+		a.True(result.Throughput > 0, name)
+		a.Contains(result.String(), "write: 100 ops", name)
+	}
+}
+
+func Test_Run_Canceled(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := bench.Run(ctx, sessions.NewCookieStore([]byte("test-session-key")), bench.Options{})
+	a.NoError(err)
+	a.Zero(result.Errors)
+	a.Zero(result.Write.Count)
+}
+
+// brokenStore loses the sessions it saves.
+type brokenStore struct {
+	*sessions.CookieStore
+}
+
+func (s brokenStore) Get(req *http.Request, name string) (*sessions.Session, error) {
+	return sessions.NewSession(s, name), nil
+}
+
+func Test_Run_Errors(t *testing.T) {
+	a := assert.New(t)
+	store := brokenStore{sessions.NewCookieStore([]byte("test-session-key"))}
+	result, err := bench.Run(context.Background(), store, bench.Options{Concurrency: 2, Operations: 10})
+	a.NoError(err)
+	a.Equal(10, result.Errors)
+	a.ErrorIs(result.Err, bench.ErrValueMismatch)
+	a.Contains(result.String(), "errors: 10")
+
+	// the sessions too large for a cookie fail
+	result, err = bench.Run(context.Background(), sessions.NewCookieStore([]byte("test-session-key")), bench.Options{Operations: 1, ValueSize: 8192})
+	a.NoError(err)
+	a.Equal(1, result.Errors)
+}
+
+func Benchmark_CookieStore(b *testing.B) {
+	bench.Benchmark(b, sessions.NewCookieStore([]byte("test-session-key")), bench.Options{})
+}
+
+func Benchmark_FilesystemStore(b *testing.B) {
+	bench.Benchmark(b, sessions.NewFilesystemStore(b.TempDir(), []byte("test-session-key")), bench.Options{})
+}
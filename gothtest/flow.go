@@ -0,0 +1,143 @@
+package gothtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+)
+
+// LoginOptions are the options of SimulateLogin.
+type LoginOptions struct {
+	// CallbackURL is the callback of the application, the redirect_uri of
+	// the auth URL by default.
+	CallbackURL string
+	// Params are added to the parameters of the callback, which are the
+	// state of the auth URL and the code "gothtest-code" by default, e.g.
+	// error=access_denied.
+	Params url.Values
+	// Fixtures are the responses of the provider, e.g. of its token
+	// endpoint, see TestProvider.
+	Fixtures []Fixture
+	// Cookies are sent with the requests, e.g. the session of the
+	// application.
+	Cookies []*http.Cookie
+}
+
+// Login is the result of SimulateLogin.
+type Login struct {
+	// User is the user authenticated by gothic.CompleteUserAuth.
+	User goth.User
+	// Err is the error of gothic.CompleteUserAuth, when it failed.
+	Err error
+	// AuthURL is the URL of the provider the user was sent to.
+	AuthURL *url.URL
+	// Response is the response of the callback handler of the application.
+	Response *http.Response
+	// Cookies are the cookies of the browser once signed in.
+	Cookies []*http.Cookie
+}
+
+// SimulateLogin simulates the login of a user with the handlers of the
+// application: it requests the begin URL, e.g. served by
+// gothic.BeginAuthHandler, and requests the callback handled with
+// gothic.CompleteUserAuth with the state of the auth URL it is redirected to,
+// as the provider would. The requests of the provider, e.g. the token
+// exchange, get the fixtures:
+//
+//	login := gothtest.SimulateLogin(t, router, "/auth/github", gothtest.LoginOptions{
+//		Fixtures: gothtest.LoadFixtures(t, "testdata/github.json"),
+//	})
+//
+// Like TestProvider, it replaces goth.DefaultClient while it runs, and
+// subscribes to the events of gothic, so it must not run in parallel with the
+// other tests using them.
+func SimulateLogin(t testing.TB, handler http.Handler, beginURL string, opts LoginOptions) Login {
+	t.Helper()
+	jar := cookieJar{}
+	jar.set(opts.Cookies)
+
+	begin := httptest.NewRecorder()
+	handler.ServeHTTP(begin, jar.request(beginURL))
+	jar.set(begin.Result().Cookies())
+	location := begin.Header().Get("Location")
+	if begin.Code < 300 || begin.Code >= 400 || location == "" {
+		t.Fatalf("gothtest: %s responded with a %d instead of redirecting to the provider", beginURL, begin.Code)
+	}
+	authURL, err := url.Parse(location)
+	if err != nil {
+		t.Fatalf("gothtest: the auth URL %q is invalid: %v", location, err)
+	}
+
+	callbackURL := opts.CallbackURL
+	if callbackURL == "" {
+		callbackURL = authURL.Query().Get("redirect_uri")
+	}
+	if callbackURL == "" {
+		t.Fatalf("gothtest: the auth URL %q has no redirect_uri, set the CallbackURL", location)
+	}
+	callback, err := url.Parse(callbackURL)
+	if err != nil {
+		t.Fatalf("gothtest: the callback URL %q is invalid: %v", callbackURL, err)
+	}
+	q := callback.Query()
+	q.Set("state", authURL.Query().Get("state"))
+	q.Set("code", "gothtest-code")
+	for k, v := range opts.Params {
+		q[k] = v
+	}
+	callback.RawQuery = q.Encode()
+
+	login := Login{AuthURL: authURL}
+	defer gothic.Subscribe(gothic.EventAuthCompleted, func(event gothic.Event) {
+		login.User, login.Err = event.User, nil
+	})()
+	defer gothic.Subscribe(gothic.EventSecondFactorRequired, func(event gothic.Event) {
+		login.User, login.Err = event.User, gothic.ErrSecondFactorRequired
+	})()
+	defer gothic.Subscribe(gothic.EventAuthFailed, func(event gothic.Event) {
+		login.Err = event.Err
+	})()
+	client := goth.DefaultClient
+	defer func() { goth.DefaultClient = client }()
+	goth.DefaultClient = &http.Client{Transport: &fixtureTransport{t: t, fixtures: opts.Fixtures}}
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, jar.request(callback.String()))
+	login.Response = res.Result()
+	jar.set(login.Response.Cookies())
+	login.Cookies = jar.cookies()
+	return login
+}
+
+// cookieJar keeps the cookies of the simulated browser by name.
+type cookieJar map[string]*http.Cookie
+
+func (j cookieJar) set(cookies []*http.Cookie) {
+	for _, c := range cookies {
+		if c.MaxAge < 0 {
+			delete(j, c.Name)
+			continue
+		}
+		j[c.Name] = c
+	}
+}
+
+func (j cookieJar) cookies() []*http.Cookie {
+	cookies := make([]*http.Cookie, 0, len(j))
+	for _, c := range j {
+		cookies = append(cookies, c)
+	}
+	return cookies
+}
+
+func (j cookieJar) request(target string) *http.Request {
+	req := httptest.NewRequest("GET", target, nil)
+	for _, c := range j {
+		req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return req
+}
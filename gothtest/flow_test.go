@@ -0,0 +1,65 @@
+package gothtest_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	"github.com/andreimerlescu/goth/gothtest"
+	"github.com/andreimerlescu/goth/providers/github"
+	"github.com/andreimerlescu/goth/providers/mock"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func app() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth", gothic.BeginAuthHandler)
+	mux.HandleFunc("/auth/callback", func(res http.ResponseWriter, req *http.Request) {
+		user, err := gothic.CompleteUserAuth(res, req)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(res, "Hello %s", user.Email)
+	})
+	return mux
+}
+
+func Test_SimulateLogin(t *testing.T) {
+	a := assert.New(t)
+	goth.UseProviders(
+		github.New("key", "secret", "http://app.example.com/auth/callback?provider=github", "user:email"),
+		mock.New(goth.User{UserID: "42", Email: "homer@example.com"}),
+	)
+	defer goth.ClearProviders()
+	store := gothic.Store
+	gothic.Store = sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	defer func() { gothic.Store = store }()
+
+	login := gothtest.SimulateLogin(t, app(), "/auth?provider=github", gothtest.LoginOptions{
+		Fixtures: gothtest.LoadFixtures(t, "testdata/github.json"),
+	})
+	a.NoError(login.Err)
+	a.Equal("583231", login.User.UserID)
+	a.Equal("octocat@github.com", login.User.Email)
+	a.Equal("github.com", login.AuthURL.Host)
+	a.Equal(http.StatusOK, login.Response.StatusCode)
+	body, _ := io.ReadAll(login.Response.Body)
+	a.Equal("Hello octocat@github.com", string(body))
+	// the gothic session is deleted once the user is authenticated
+	for _, c := range login.Cookies {
+		a.NotEqual(gothic.SessionName, c.Name)
+	}
+
+	login = gothtest.SimulateLogin(t, app(), "/auth?provider=mock", gothtest.LoginOptions{
+		CallbackURL: "/auth/callback?provider=mock",
+		Params:      url.Values{"error": {"access_denied"}},
+	})
+	a.EqualError(login.Err, "mock: the authorization failed: access_denied")
+	a.Equal(http.StatusUnauthorized, login.Response.StatusCode)
+}
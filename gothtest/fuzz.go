@@ -0,0 +1,48 @@
+package gothtest
+
+import (
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// FuzzUnmarshalSession fuzzes the UnmarshalSession of the providers, which
+// decode the sessions of the cookies of gothic: malformed sessions must be
+// rejected with an error, or unmarshaled into a session which marshals and
+// returns its auth URL, without panicking. The corpus is seeded with the
+// sessions begun by the providers, and the seeds.
+//
+//	func Fuzz_UnmarshalSession(f *testing.F) {
+//		gothtest.FuzzUnmarshalSession(f, []goth.Provider{github.New(key, secret, callbackURL)})
+//	}
+func FuzzUnmarshalSession(f *testing.F, providers []goth.Provider, seeds ...string) {
+	f.Helper()
+	for _, p := range providers {
+		if session, err := p.BeginAuth("state"); err == nil {
+			f.Add(session.Marshal())
+		}
+	}
+	for _, seed := range append(seeds, "", "{}", "null", `{"AuthURL":"%"}`, `{"ExpiresAt":"not a time"}`, "[]") {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data string) {
+		for _, p := range providers {
+			func() {
+				defer func() {
+					if v := recover(); v != nil {
+						t.Fatalf("%s: UnmarshalSession(%q) panicked: %v", p.Name(), data, v)
+					}
+				}()
+				session, err := p.UnmarshalSession(data)
+				if err != nil {
+					return
+				}
+				if session == nil {
+					t.Fatalf("%s: UnmarshalSession(%q) returned a nil session without an error", p.Name(), data)
+				}
+				_ = session.Marshal()
+				_, _ = session.GetAuthURL()
+			}()
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package gothtest_test
+
+import (
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothtest"
+	"github.com/andreimerlescu/goth/providers/amazon"
+	"github.com/andreimerlescu/goth/providers/apple"
+	"github.com/andreimerlescu/goth/providers/auth0"
+	"github.com/andreimerlescu/goth/providers/azuread"
+	"github.com/andreimerlescu/goth/providers/bitbucket"
+	"github.com/andreimerlescu/goth/providers/cas"
+	"github.com/andreimerlescu/goth/providers/discord"
+	"github.com/andreimerlescu/goth/providers/facebook"
+	"github.com/andreimerlescu/goth/providers/github"
+	"github.com/andreimerlescu/goth/providers/gitlab"
+	"github.com/andreimerlescu/goth/providers/google"
+	"github.com/andreimerlescu/goth/providers/guest"
+	"github.com/andreimerlescu/goth/providers/linkedin"
+	"github.com/andreimerlescu/goth/providers/microsoftonline"
+	"github.com/andreimerlescu/goth/providers/mock"
+	"github.com/andreimerlescu/goth/providers/okta"
+	"github.com/andreimerlescu/goth/providers/openidConnect"
+	"github.com/andreimerlescu/goth/providers/plex"
+	"github.com/andreimerlescu/goth/providers/slack"
+	"github.com/andreimerlescu/goth/providers/steam"
+	"github.com/andreimerlescu/goth/providers/twitterv2"
+	"github.com/andreimerlescu/goth/providers/wecom"
+)
+
+func Fuzz_UnmarshalSession(f *testing.F) {
+	s := gothtest.NewOIDCServer(f)
+	oidc, err := openidConnect.New(s.ClientID, s.ClientSecret, "http://localhost/callback", s.DiscoveryURL())
+	if err != nil {
+		f.Fatal(err)
+	}
+	const callbackURL = "http://localhost/callback"
+	gothtest.FuzzUnmarshalSession(f, []goth.Provider{
+		oidc,
+		amazon.New("key", "secret", callbackURL),
+		apple.New("key", "secret", callbackURL, nil),
+		auth0.New("key", "secret", callbackURL, "example.auth0.com"),
+		azuread.New("key", "secret", callbackURL, nil),
+		bitbucket.New("key", "secret", callbackURL),
+		cas.New("https://cas.example.com", callbackURL),
+		discord.New("key", "secret", callbackURL),
+		facebook.New("key", "secret", callbackURL),
+		github.New("key", "secret", callbackURL),
+		gitlab.New("key", "secret", callbackURL),
+		google.New("key", "secret", callbackURL),
+		guest.New(callbackURL),
+		linkedin.New("key", "secret", callbackURL),
+		microsoftonline.New("key", "secret", callbackURL),
+		mock.New(),
+		okta.New("key", "secret", "https://example.okta.com", callbackURL),
+		plex.New("client", "goth", callbackURL),
+		slack.New("key", "secret", callbackURL),
+		steam.New("key", callbackURL),
+		twitterv2.New("key", "secret", callbackURL),
+		wecom.New("corp", "secret", "agent", callbackURL),
+	})
+}
@@ -0,0 +1,31 @@
+// Package gothtest helps testing the providers of goth and the applications
+// using them, end to end and without the network.
+//
+// NewOIDCServer starts a fake OpenID Connect identity provider, for the
+// openidConnect provider and the gothic flow:
+//
+//	s := gothtest.NewOIDCServer(t)
+//	s.AddUser("42", map[string]interface{}{"email": "homer@example.com", "name": "Homer"})
+//	p, err := openidConnect.New(s.ClientID, s.ClientSecret, callbackURL, s.DiscoveryURL())
+//
+// SimulateLogin drives the login of a user through the handlers of an
+// application, and returns the user authenticated:
+//
+//	login := gothtest.SimulateLogin(t, router, "/auth/github", gothtest.LoginOptions{
+//		Fixtures: gothtest.LoadFixtures(t, "testdata/github.json"),
+//	})
+//
+// TestProvider is the conformance suite of the providers, run against the
+// recorded responses of the provider:
+//
+//	gothtest.TestProvider(t, github.New(key, secret, callbackURL), gothtest.ProviderOptions{
+//		Fixtures: gothtest.LoadFixtures(t, "testdata/github.json"),
+//		User:     goth.User{UserID: "583231", NickName: "octocat"},
+//	})
+//
+// The fixtures can be recorded from the provider by a RecordTransport, with
+// their secrets scrubbed, and replayed in CI.
+//
+// FuzzUnmarshalSession fuzzes the sessions of the providers, decoded from the
+// cookies of the clients.
+package gothtest
@@ -0,0 +1,378 @@
+package gothtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// The paths of the endpoints of the OIDCServer.
+const (
+	DiscoveryPath = "/.well-known/openid-configuration"
+	JWKSPath      = "/jwks"
+	AuthorizePath = "/authorize"
+	TokenPath     = "/token"
+	UserInfoPath  = "/userinfo"
+)
+
+// DefaultSubject is the subject of the user signed in by the OIDCServer when
+// no user is added.
+const DefaultSubject = "gothtest-user"
+
+// OIDCServer is a fake OpenID Connect identity provider, serving the
+// discovery, JWKS, authorize, token and userinfo endpoints for a single
+// client, with users added by the test. It signs its ID tokens with a RSA
+// key, published in its JWKS.
+//
+// The authorize endpoint signs in the user whose subject is the login_hint
+// parameter, or the first one, and redirects straight to the redirect_uri
+// with a code, see Authorize.
+type OIDCServer struct {
+	*httptest.Server
+	// Issuer is the URL of the server, the issuer of its ID tokens.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Key signs the ID tokens, its public key is published with the KeyID.
+	Key   *rsa.PrivateKey
+	KeyID string
+	// TokenLifetime is the lifetime of the tokens, an hour by default.
+	TokenLifetime time.Duration
+
+	mu            sync.Mutex
+	users         []oidcUser
+	codes         map[string]oidcGrant
+	accessTokens  map[string]oidcGrant
+	refreshTokens map[string]oidcGrant
+}
+
+type oidcUser struct {
+	subject string
+	claims  map[string]interface{}
+}
+
+// oidcGrant is the authorization of a user, by its code or tokens.
+type oidcGrant struct {
+	subject       string
+	nonce         string
+	redirectURI   string
+	codeChallenge string
+	method        string
+	expiresAt     time.Time
+}
+
+// NewOIDCServer starts an OIDCServer, closed when the test ends.
+func NewOIDCServer(t testing.TB) *OIDCServer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &OIDCServer{
+		ClientID:      "gothtest-client",
+		ClientSecret:  "gothtest-secret",
+		Key:           key,
+		KeyID:         "gothtest",
+		TokenLifetime: time.Hour,
+		codes:         map[string]oidcGrant{},
+		accessTokens:  map[string]oidcGrant{},
+		refreshTokens: map[string]oidcGrant{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(DiscoveryPath, s.discovery)
+	mux.HandleFunc(JWKSPath, s.jwks)
+	mux.HandleFunc(AuthorizePath, s.authorize)
+	mux.HandleFunc(TokenPath, s.token)
+	mux.HandleFunc(UserInfoPath, s.userInfo)
+	s.Server = httptest.NewServer(mux)
+	s.Issuer = s.Server.URL
+	t.Cleanup(s.Server.Close)
+	return s
+}
+
+// DiscoveryURL is the URL of the discovery document, passed to
+// openidConnect.New.
+func (s *OIDCServer) DiscoveryURL() string {
+	return s.Issuer + DiscoveryPath
+}
+
+// AddUser adds a user with its claims, e.g. email and name, returned in its
+// ID token and by the userinfo endpoint.
+func (s *OIDCServer) AddUser(subject string, claims map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = append(s.users, oidcUser{subject: subject, claims: claims})
+}
+
+// user returns the user with the subject, or the first one, or a default
+// user when none was added.
+func (s *OIDCServer) user(subject string) (oidcUser, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.users) == 0 {
+		u := oidcUser{subject: DefaultSubject, claims: map[string]interface{}{
+			"name":  "Test User",
+			"email": "user@example.com",
+		}}
+		return u, subject == "" || subject == u.subject
+	}
+	for _, u := range s.users {
+		if subject == "" || u.subject == subject {
+			return u, true
+		}
+	}
+	return oidcUser{}, false
+}
+
+// Sign signs the claims with the Key, e.g. to test a tampered or expired ID
+// token.
+func (s *OIDCServer) Sign(claims map[string]interface{}) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims(claims))
+	token.Header["kid"] = s.KeyID
+	return token.SignedString(s.Key)
+}
+
+// IDToken returns a signed ID token of the user, for the client.
+func (s *OIDCServer) IDToken(subject, nonce string) (string, error) {
+	u, ok := s.user(subject)
+	if !ok {
+		return "", errors.New("gothtest: unknown user " + subject)
+	}
+	now := time.Now()
+	claims := map[string]interface{}{}
+	for k, v := range u.claims {
+		claims[k] = v
+	}
+	claims["iss"] = s.Issuer
+	claims["sub"] = u.subject
+	claims["aud"] = s.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(s.TokenLifetime).Unix()
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	return s.Sign(claims)
+}
+
+// Authorize requests the auth URL, e.g. of gothic.GetAuthURL, and returns the
+// URL the server redirects to, i.e. the callback with the code and the state.
+func (s *OIDCServer) Authorize(authURL string) (*url.URL, error) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(authURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		return nil, errors.New("gothtest: the authorize endpoint responded with " + res.Status)
+	}
+	return res.Location()
+}
+
+func (s *OIDCServer) discovery(res http.ResponseWriter, req *http.Request) {
+	writeJSON(res, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.Issuer,
+		"authorization_endpoint":                s.Issuer + AuthorizePath,
+		"token_endpoint":                        s.Issuer + TokenPath,
+		"userinfo_endpoint":                     s.Issuer + UserInfoPath,
+		"jwks_uri":                              s.Issuer + JWKSPath,
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"scopes_supported":                      []string{"openid", "email", "profile"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"grant_types_supported":                 []string{"authorization_code", "refresh_token"},
+		"code_challenge_methods_supported":      []string{"plain", "S256"},
+	})
+}
+
+func (s *OIDCServer) jwks(res http.ResponseWriter, req *http.Request) {
+	key, err := jwk.New(&s.Key.PublicKey)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = key.Set(jwk.KeyIDKey, s.KeyID)
+	_ = key.Set(jwk.AlgorithmKey, "RS256")
+	_ = key.Set(jwk.KeyUsageKey, "sig")
+	set := jwk.NewSet()
+	set.Add(key)
+	writeJSON(res, http.StatusOK, set)
+}
+
+func (s *OIDCServer) authorize(res http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if q.Get("client_id") != s.ClientID || err != nil || q.Get("redirect_uri") == "" {
+		http.Error(res, "invalid client_id or redirect_uri", http.StatusBadRequest)
+		return
+	}
+	redirect := func(params url.Values) {
+		values := redirectURI.Query()
+		for k, v := range params {
+			values[k] = v
+		}
+		if state := q.Get("state"); state != "" {
+			values.Set("state", state)
+		}
+		redirectURI.RawQuery = values.Encode()
+		http.Redirect(res, req, redirectURI.String(), http.StatusFound)
+	}
+	if q.Get("response_type") != "code" {
+		redirect(url.Values{"error": {"unsupported_response_type"}})
+		return
+	}
+	u, ok := s.user(q.Get("login_hint"))
+	if !ok {
+		redirect(url.Values{"error": {"access_denied"}, "error_description": {"unknown user"}})
+		return
+	}
+
+	code := randomToken()
+	method := q.Get("code_challenge_method")
+	if method == "" && q.Get("code_challenge") != "" {
+		method = "plain"
+	}
+	s.mu.Lock()
+	s.codes[code] = oidcGrant{
+		subject:       u.subject,
+		nonce:         q.Get("nonce"),
+		redirectURI:   q.Get("redirect_uri"),
+		codeChallenge: q.Get("code_challenge"),
+		method:        method,
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+	redirect(url.Values{"code": {code}})
+}
+
+func (s *OIDCServer) token(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(res, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		tokenError(res, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	clientID, clientSecret, ok := req.BasicAuth()
+	if !ok {
+		clientID, clientSecret = req.PostForm.Get("client_id"), req.PostForm.Get("client_secret")
+	}
+	if clientID != s.ClientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(s.ClientSecret)) != 1 {
+		tokenError(res, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	var grant oidcGrant
+	switch req.PostForm.Get("grant_type") {
+	case "authorization_code":
+		s.mu.Lock()
+		grant, ok = s.codes[req.PostForm.Get("code")]
+		// the codes are used once
+		delete(s.codes, req.PostForm.Get("code"))
+		s.mu.Unlock()
+		if !ok || time.Now().After(grant.expiresAt) || grant.redirectURI != req.PostForm.Get("redirect_uri") ||
+			!verifyChallenge(grant, req.PostForm.Get("code_verifier")) {
+			tokenError(res, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+	case "refresh_token":
+		s.mu.Lock()
+		grant, ok = s.refreshTokens[req.PostForm.Get("refresh_token")]
+		s.mu.Unlock()
+		if !ok {
+			tokenError(res, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+	default:
+		tokenError(res, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	idToken, err := s.IDToken(grant.subject, grant.nonce)
+	if err != nil {
+		tokenError(res, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+	accessToken, refreshToken := randomToken(), randomToken()
+	grant.expiresAt = time.Now().Add(s.TokenLifetime)
+	s.mu.Lock()
+	s.accessTokens[accessToken] = grant
+	s.refreshTokens[refreshToken] = grant
+	s.mu.Unlock()
+	res.Header().Set("Cache-Control", "no-store")
+	writeJSON(res, http.StatusOK, map[string]interface{}{
+		"access_token":  accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(s.TokenLifetime.Seconds()),
+		"refresh_token": refreshToken,
+		"id_token":      idToken,
+	})
+}
+
+func (s *OIDCServer) userInfo(res http.ResponseWriter, req *http.Request) {
+	auth := req.Header.Get("Authorization")
+	s.mu.Lock()
+	grant, ok := s.accessTokens[strings.TrimPrefix(auth, "Bearer ")]
+	s.mu.Unlock()
+	if !strings.HasPrefix(auth, "Bearer ") || !ok || time.Now().After(grant.expiresAt) {
+		res.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		res.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	u, _ := s.user(grant.subject)
+	claims := map[string]interface{}{}
+	for k, v := range u.claims {
+		claims[k] = v
+	}
+	claims["sub"] = u.subject
+	writeJSON(res, http.StatusOK, claims)
+}
+
+// verifyChallenge verifies the code verifier of PKCE, see RFC 7636.
+func verifyChallenge(grant oidcGrant, verifier string) bool {
+	switch grant.method {
+	case "":
+		return true
+	case "plain":
+		return verifier == grant.codeChallenge
+	case "S256":
+		sum := sha256.Sum256([]byte(verifier))
+		return base64.RawURLEncoding.EncodeToString(sum[:]) == grant.codeChallenge
+	}
+	return false
+}
+
+func tokenError(res http.ResponseWriter, status int, code string) {
+	writeJSON(res, status, map[string]string{"error": code})
+}
+
+func writeJSON(res http.ResponseWriter, status int, v interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_ = json.NewEncoder(res).Encode(v)
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
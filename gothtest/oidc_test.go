@@ -0,0 +1,141 @@
+package gothtest_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	"github.com/andreimerlescu/goth/gothtest"
+	"github.com/andreimerlescu/goth/providers/openidConnect"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/sessions"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_OIDCServer_Gothic(t *testing.T) {
+	a := assert.New(t)
+	s := gothtest.NewOIDCServer(t)
+	s.AddUser("42", map[string]interface{}{"email": "homer@example.com", "name": "Homer Simpson"})
+	s.AddUser("43", map[string]interface{}{"email": "marge@example.com", "name": "Marge Simpson"})
+
+	p, err := openidConnect.New(s.ClientID, s.ClientSecret, "http://app.example.com/auth/callback?provider=openid-connect", s.DiscoveryURL(), "openid", "email")
+	a.NoError(err)
+	goth.UseProviders(p)
+	defer goth.ClearProviders()
+	store := gothic.Store
+	gothic.Store = sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	defer func() { gothic.Store = store }()
+
+	begin := httptest.NewRecorder()
+	gothic.BeginAuthHandler(begin, httptest.NewRequest("GET", "/auth?provider=openid-connect", nil))
+	a.Equal(http.StatusTemporaryRedirect, begin.Code)
+	authURL := begin.Header().Get("Location")
+	a.True(strings.HasPrefix(authURL, s.Issuer+gothtest.AuthorizePath), authURL)
+
+	callbackURL, err := s.Authorize(authURL + "&login_hint=43")
+	a.NoError(err)
+	a.Equal("app.example.com", callbackURL.Host)
+	a.NotEmpty(callbackURL.Query().Get("code"))
+
+	callback := httptest.NewRequest("GET", callbackURL.String(), nil)
+	for _, c := range begin.Result().Cookies() {
+		callback.AddCookie(c)
+	}
+	user, err := gothic.CompleteUserAuth(httptest.NewRecorder(), callback)
+	a.NoError(err)
+	a.Equal("43", user.UserID)
+	a.Equal("marge@example.com", user.Email)
+	a.Equal("Marge Simpson", user.Name)
+	a.NotEmpty(user.AccessToken)
+	a.NotEmpty(user.RefreshToken)
+	a.WithinDuration(time.Now().Add(time.Hour), user.ExpiresAt, time.Minute)
+
+	// the ID token is signed with the key of the JWKS
+	set, err := jwk.Fetch(callback.Context(), s.Issuer+gothtest.JWKSPath)
+	a.NoError(err)
+	key, ok := set.LookupKeyID(s.KeyID)
+	a.True(ok)
+	var public interface{}
+	a.NoError(key.Raw(&public))
+	token, err := jwt.Parse(user.IDToken, func(*jwt.Token) (interface{}, error) { return public, nil },
+		jwt.WithIssuer(s.Issuer), jwt.WithAudience(s.ClientID), jwt.WithValidMethods([]string{"RS256"}))
+	a.NoError(err)
+	sub, _ := token.Claims.GetSubject()
+	a.Equal("43", sub)
+
+	refreshed, err := p.RefreshToken(user.RefreshToken)
+	a.NoError(err)
+	a.NotEqual(user.AccessToken, refreshed.AccessToken)
+}
+
+func Test_OIDCServer_Errors(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	s := gothtest.NewOIDCServer(t)
+
+	authorize := func(params url.Values) *url.URL {
+		params.Set("client_id", s.ClientID)
+		params.Set("redirect_uri", "http://app.example.com/callback")
+		params.Set("state", "state")
+		u, err := s.Authorize(s.Issuer + gothtest.AuthorizePath + "?" + params.Encode())
+		a.NoError(err)
+		a.Equal("state", u.Query().Get("state"))
+		return u
+	}
+	a.Equal("unsupported_response_type", authorize(url.Values{}).Query().Get("error"))
+	a.Equal("access_denied", authorize(url.Values{"response_type": {"code"}, "login_hint": {"unknown"}}).Query().Get("error"))
+
+	exchange := func(form url.Values) (int, map[string]interface{}) {
+		form.Set("client_id", s.ClientID)
+		form.Set("client_secret", s.ClientSecret)
+		res, err := http.PostForm(s.Issuer+gothtest.TokenPath, form)
+		a.NoError(err)
+		defer res.Body.Close()
+		var body map[string]interface{}
+		a.NoError(json.NewDecoder(res.Body).Decode(&body))
+		return res.StatusCode, body
+	}
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	code := authorize(url.Values{
+		"response_type":         {"code"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}).Query().Get("code")
+	status, body := exchange(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {"http://app.example.com/callback"}, "code_verifier": {"wrong"}})
+	a.Equal(http.StatusBadRequest, status)
+	a.Equal("invalid_grant", body["error"])
+	// the code can't be used again
+	status, _ = exchange(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {"http://app.example.com/callback"}, "code_verifier": {verifier}})
+	a.Equal(http.StatusBadRequest, status)
+
+	code = authorize(url.Values{"response_type": {"code"}}).Query().Get("code")
+	status, body = exchange(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {"http://app.example.com/callback"}})
+	a.Equal(http.StatusOK, status)
+	claims := jwt.MapClaims{}
+	_, _, err := jwt.NewParser().ParseUnverified(body["id_token"].(string), claims)
+	a.NoError(err)
+	a.Equal(gothtest.DefaultSubject, claims["sub"])
+
+	res, err := http.PostForm(s.Issuer+gothtest.TokenPath, url.Values{"grant_type": {"refresh_token"}, "client_id": {s.ClientID}, "client_secret": {"wrong"}})
+	a.NoError(err)
+	res.Body.Close()
+	a.Equal(http.StatusUnauthorized, res.StatusCode)
+
+	req, _ := http.NewRequest("GET", s.Issuer+gothtest.UserInfoPath, nil)
+	req.Header.Set("Authorization", "Bearer unknown")
+	res, err = http.DefaultClient.Do(req)
+	a.NoError(err)
+	res.Body.Close()
+	a.Equal(http.StatusUnauthorized, res.StatusCode)
+}
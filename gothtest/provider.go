@@ -0,0 +1,293 @@
+package gothtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Fixture is a recorded response of a provider, served to the requests of
+// its method and URL, without the query.
+type Fixture struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// LoadFixtures loads the fixtures of a JSON file, e.g. in testdata.
+func LoadFixtures(t testing.TB, path string) []Fixture {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixtures []Fixture
+	if err := json.Unmarshal(b, &fixtures); err != nil {
+		t.Fatalf("gothtest: %s: %v", path, err)
+	}
+	return fixtures
+}
+
+// ProviderOptions are the options of TestProvider.
+type ProviderOptions struct {
+	// State is passed to BeginAuth, and must be in its auth URL unless
+	// SkipState is set. It defaults to "gothtest-state".
+	State     string
+	SkipState bool
+	// Params are the parameters of the callback passed to Authorize, e.g.
+	// the code.
+	Params url.Values
+	// Fixtures are the responses of the provider to the requests of
+	// Authorize and FetchUser. Without fixtures, the provider must not
+	// request the network, e.g. the mock provider.
+	Fixtures []Fixture
+	// Transport serves the requests instead of the Fixtures when set, e.g.
+	// a RecordTransport.
+	Transport http.RoundTripper
+	// User is the user FetchUser must return, compared on its fields which
+	// aren't empty. The Provider is the name of the provider by default.
+	User goth.User
+}
+
+// TestProvider runs the conformance tests of goth.Provider on the provider,
+// for the authors of providers:
+//
+//   - BeginAuth returns a session with an auth URL carrying the state,
+//   - the sessions are marshaled and unmarshaled without losses,
+//   - FetchUser fails before Authorize, and Authorize fails when the
+//     provider responds with errors,
+//   - Authorize and FetchUser, with the fixtures, return the User,
+//   - the sessions don't print their tokens.
+//
+// The fixtures are served by replacing goth.DefaultClient while the tests
+// run, so the provider must use goth.HTTPClientWithFallBack without a client
+// of its own, and the test must not run in parallel with the others using
+// goth.DefaultClient.
+func TestProvider(t *testing.T, p goth.Provider, opts ProviderOptions) {
+	t.Helper()
+	if opts.State == "" {
+		opts.State = "gothtest-state"
+	}
+	if opts.Params == nil {
+		opts.Params = url.Values{"code": {"gothtest-code"}, "state": {opts.State}}
+	}
+	if opts.User.Provider == "" {
+		opts.User.Provider = p.Name()
+	}
+	client := goth.DefaultClient
+	defer func() { goth.DefaultClient = client }()
+
+	var session goth.Session
+	t.Run("BeginAuth", func(t *testing.T) {
+		defer recoverPanic(t)
+		var err error
+		session, err = p.BeginAuth(opts.State)
+		if err != nil {
+			t.Fatalf("BeginAuth: %v", err)
+		}
+		authURL, err := session.GetAuthURL()
+		if err != nil {
+			t.Fatalf("GetAuthURL: %v", err)
+		}
+		u, err := url.Parse(authURL)
+		if err != nil {
+			t.Fatalf("the auth URL %q is invalid: %v", authURL, err)
+		}
+		if !opts.SkipState && !strings.Contains(u.RawQuery, url.QueryEscape(opts.State)) {
+			t.Errorf("the auth URL %q doesn't carry the state %q", authURL, opts.State)
+		}
+		testRoundTrip(t, p, session)
+	})
+	if session == nil {
+		return
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		defer recoverPanic(t)
+		begun, err := p.UnmarshalSession(session.Marshal())
+		if err != nil {
+			t.Fatalf("UnmarshalSession: %v", err)
+		}
+		if _, err := p.FetchUser(begun); err == nil {
+			t.Error("FetchUser succeeded before Authorize")
+		}
+		if len(opts.Fixtures) == 0 && opts.Transport == nil {
+			return
+		}
+		goth.DefaultClient = &http.Client{Transport: errorTransport{}}
+		if _, err := begun.Authorize(p, opts.Params); err == nil {
+			t.Error("Authorize succeeded when the provider responded with errors")
+		}
+	})
+
+	t.Run("Authorize", func(t *testing.T) {
+		defer recoverPanic(t)
+		var transport http.RoundTripper = &fixtureTransport{t: t, fixtures: opts.Fixtures}
+		if opts.Transport != nil {
+			transport = opts.Transport
+		}
+		goth.DefaultClient = &http.Client{Transport: transport}
+		token, err := session.Authorize(p, opts.Params)
+		if err != nil {
+			t.Fatalf("Authorize: %v", err)
+		}
+		if token == "" {
+			t.Error("Authorize returned an empty token")
+		}
+		testRoundTrip(t, p, session)
+		// the tokens of the recorded fixtures are already redacted
+		if printed := fmt.Sprint(session); token != "REDACTED" && strings.Contains(printed, token) {
+			t.Errorf("the session prints its token: %s", printed)
+		}
+
+		user, err := p.FetchUser(session)
+		if err != nil {
+			t.Fatalf("FetchUser: %v", err)
+		}
+		compareUser(t, opts.User, user)
+	})
+}
+
+// testRoundTrip checks that the session is unmarshaled as it was marshaled.
+func testRoundTrip(t *testing.T, p goth.Provider, session goth.Session) {
+	t.Helper()
+	marshaled := session.Marshal()
+	unmarshaled, err := p.UnmarshalSession(marshaled)
+	if err != nil {
+		t.Fatalf("UnmarshalSession: %v", err)
+	}
+	if again := unmarshaled.Marshal(); again != marshaled {
+		t.Errorf("the session changed once unmarshaled:\n%s\n%s", marshaled, again)
+	}
+}
+
+// compareUser compares the fields of the user which are set in want.
+func compareUser(t *testing.T, want, got goth.User) {
+	t.Helper()
+	fields := []struct {
+		name      string
+		want, got string
+	}{
+		{"Provider", want.Provider, got.Provider},
+		{"UserID", want.UserID, got.UserID},
+		{"Email", want.Email, got.Email},
+		{"Name", want.Name, got.Name},
+		{"FirstName", want.FirstName, got.FirstName},
+		{"LastName", want.LastName, got.LastName},
+		{"NickName", want.NickName, got.NickName},
+		{"Description", want.Description, got.Description},
+		{"AvatarURL", want.AvatarURL, got.AvatarURL},
+		{"Location", want.Location, got.Location},
+		{"AccessToken", want.AccessToken, got.AccessToken},
+		{"RefreshToken", want.RefreshToken, got.RefreshToken},
+	}
+	for _, f := range fields {
+		if f.want != "" && f.want != f.got {
+			t.Errorf("FetchUser returned the %s %q, want %q", f.name, f.got, f.want)
+		}
+	}
+	if !want.ExpiresAt.IsZero() && !want.ExpiresAt.Equal(got.ExpiresAt) {
+		t.Errorf("FetchUser returned the ExpiresAt %v, want %v", got.ExpiresAt, want.ExpiresAt)
+	}
+}
+
+func recoverPanic(t *testing.T) {
+	if v := recover(); v != nil {
+		t.Fatalf("panic: %v", v)
+	}
+}
+
+// fixtureTransport serves the fixtures, in order for the ones of the same
+// request.
+type fixtureTransport struct {
+	t        testing.TB
+	mu       sync.Mutex
+	fixtures []Fixture
+	used     map[int]bool
+}
+
+func (f *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	u := *req.URL
+	u.RawQuery, u.Fragment = "", ""
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.used == nil {
+		f.used = map[int]bool{}
+	}
+	for i, fixture := range f.fixtures {
+		method := fixture.Method
+		if method == "" {
+			method = http.MethodGet
+		}
+		if f.used[i] || method != req.Method || strings.SplitN(fixture.URL, "?", 2)[0] != u.String() {
+			continue
+		}
+		// the last fixture of a request is served to the next ones
+		f.used[i] = f.next(i, method) >= 0
+		return fixture.response(req), nil
+	}
+	f.t.Errorf("no fixture for %s %s", req.Method, u.String())
+	return (&Fixture{Status: http.StatusNotFound}).response(req), nil
+}
+
+// next returns the index of the next fixture of the request, or -1.
+func (f *fixtureTransport) next(i int, method string) int {
+	for j := i + 1; j < len(f.fixtures); j++ {
+		other := f.fixtures[j]
+		if (other.Method == method || other.Method == "" && method == http.MethodGet) && other.URL == f.fixtures[i].URL {
+			return j
+		}
+	}
+	return -1
+}
+
+func (f Fixture) response(req *http.Request) *http.Response {
+	status := f.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := http.Header{}
+	for k, v := range f.Header {
+		header[k] = v
+	}
+	if header.Get("Content-Type") == "" && strings.HasPrefix(strings.TrimSpace(f.Body), "{") {
+		header.Set("Content-Type", "application/json")
+	}
+	return &http.Response{
+		StatusCode:    status,
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(f.Body)),
+		ContentLength: int64(len(f.Body)),
+		Request:       req,
+	}
+}
+
+// errorTransport responds to every request with an OAuth error.
+type errorTransport struct{}
+
+func (errorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return (&Fixture{
+		Status: http.StatusBadRequest,
+		Body:   `{"error":"invalid_grant","error_description":"gothtest"}`,
+	}).response(req), nil
+}
@@ -0,0 +1,30 @@
+package gothtest_test
+
+import (
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothtest"
+	"github.com/andreimerlescu/goth/providers/github"
+	"github.com/andreimerlescu/goth/providers/mock"
+)
+
+func Test_TestProvider(t *testing.T) {
+	gothtest.TestProvider(t, github.New("key", "secret", "/auth/callback", "user:email"), gothtest.ProviderOptions{
+		Fixtures: gothtest.LoadFixtures(t, "testdata/github.json"),
+		User: goth.User{
+			UserID:      "583231",
+			NickName:    "octocat",
+			Name:        "The Octocat",
+			Email:       "octocat@github.com",
+			Location:    "San Francisco",
+			AccessToken: "gho_fixture",
+		},
+	})
+}
+
+func Test_TestProvider_Mock(t *testing.T) {
+	gothtest.TestProvider(t, mock.New(goth.User{UserID: "42", Email: "homer@example.com"}), gothtest.ProviderOptions{
+		User: goth.User{UserID: "42", Email: "homer@example.com", AccessToken: mock.AccessToken("42")},
+	})
+}
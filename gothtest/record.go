@@ -0,0 +1,161 @@
+package gothtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// RecordEnv is the environment variable making the RecordTransports record,
+// e.g. GOTHTEST_RECORD=1 go test ./providers/github.
+const RecordEnv = "GOTHTEST_RECORD"
+
+// RecordTransport records the responses of a provider into a golden file of
+// fixtures, and replays them, so the mapping of the users is tested in CI
+// without the credentials of the provider:
+//
+//	goth.DefaultClient = &http.Client{Transport: gothtest.NewRecordTransport(t, "testdata/github.json")}
+//
+// It records when the RecordEnv environment variable is set, with the real
+// credentials of the provider, and replays the golden file otherwise. The
+// recorded fixtures are scrubbed of their secrets by Scrub.
+type RecordTransport struct {
+	// Path is the golden file, e.g. testdata/github.json.
+	Path string
+	// Record makes the transport record instead of replaying.
+	Record bool
+	// Transport sends the recorded requests, http.DefaultTransport when it
+	// is nil.
+	Transport http.RoundTripper
+	// Scrub scrubs the fixtures before they are written, ScrubSecrets by
+	// default, e.g. to also scrub the personal data of the test account.
+	Scrub func(f *Fixture)
+
+	t        testing.TB
+	mu       sync.Mutex
+	fixtures []Fixture
+	replay   *fixtureTransport
+}
+
+// NewRecordTransport returns a RecordTransport of the golden file, recording
+// when the RecordEnv environment variable is set. The golden file is written
+// when the test ends.
+func NewRecordTransport(t testing.TB, path string) *RecordTransport {
+	t.Helper()
+	r := &RecordTransport{Path: path, Record: os.Getenv(RecordEnv) != "", t: t}
+	if r.Record {
+		t.Cleanup(r.save)
+		return r
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("gothtest: %v, record it with %s=1", err, RecordEnv)
+	}
+	r.fixtures = LoadFixtures(t, path)
+	return r
+}
+
+// Fixtures returns the fixtures recorded or replayed.
+func (r *RecordTransport) Fixtures() []Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Fixture(nil), r.fixtures...)
+}
+
+// RoundTrip sends and records the request when recording, or replays its
+// fixture.
+func (r *RecordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !r.Record {
+		r.mu.Lock()
+		if r.replay == nil {
+			r.replay = &fixtureTransport{t: r.t, fixtures: r.fixtures}
+		}
+		r.mu.Unlock()
+		return r.replay.RoundTrip(req)
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return res, err
+	}
+
+	u := *req.URL
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	f := Fixture{
+		Method: req.Method,
+		URL:    u.String(),
+		Status: res.StatusCode,
+		Body:   string(body),
+	}
+	if contentType := res.Header.Get("Content-Type"); contentType != "" {
+		f.Header = http.Header{"Content-Type": {contentType}}
+	}
+	scrub := r.Scrub
+	if scrub == nil {
+		scrub = ScrubSecrets
+	}
+	scrub(&f)
+	r.mu.Lock()
+	r.fixtures = append(r.fixtures, f)
+	r.mu.Unlock()
+	return res, nil
+}
+
+// save writes the fixtures recorded to the golden file.
+func (r *RecordTransport) save() {
+	b, err := json.MarshalIndent(r.Fixtures(), "", "  ")
+	if err != nil {
+		r.t.Error(err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0o755); err != nil {
+		r.t.Error(err)
+		return
+	}
+	if err := os.WriteFile(r.Path, append(b, '\n'), 0o644); err != nil {
+		r.t.Error(err)
+	}
+}
+
+// ScrubSecrets replaces the tokens and the secrets of the JSON or form body
+// of the fixture with REDACTED, see goth.Sensitive. The headers of the
+// fixtures other than Content-Type, e.g. the cookies, and the queries of their
+// URLs are never recorded.
+func ScrubSecrets(f *Fixture) {
+	mediaType, _, _ := mime.ParseMediaType(f.Header.Get("Content-Type"))
+	trimmed := strings.TrimSpace(f.Body)
+	switch {
+	case strings.HasSuffix(mediaType, "json") || strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "["):
+		f.Body = goth.RedactJSON(f.Body)
+	case mediaType == "application/x-www-form-urlencoded" || mediaType == "text/plain" || mediaType == "":
+		values, err := url.ParseQuery(trimmed)
+		if err != nil || !strings.Contains(trimmed, "=") {
+			return
+		}
+		for name := range values {
+			if goth.Sensitive(name) {
+				values[name] = []string{"REDACTED"}
+			}
+		}
+		f.Body = values.Encode()
+	}
+}
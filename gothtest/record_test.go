@@ -0,0 +1,87 @@
+package gothtest_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothtest"
+	"github.com/andreimerlescu/goth/providers/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RecordTransport(t *testing.T) {
+	a := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/login/oauth/access_token":
+			http.SetCookie(res, &http.Cookie{Name: "session", Value: "live-cookie"})
+			res.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+			fmt.Fprint(res, "access_token=gho_live&scope=user&token_type=bearer")
+		case "/user":
+			res.Header().Set("Content-Type", "application/json; charset=utf-8")
+			fmt.Fprint(res, `{"id":583231,"login":"octocat","email":"octocat@github.com","node_id":"MDQ6VXNlcjU4MzIzMQ=="}`)
+		default:
+			http.NotFound(res, req)
+		}
+	}))
+	defer server.Close()
+	provider := func() *github.Provider {
+		return github.NewCustomisedURL("key", "secret", "/auth/callback", server.URL+"/login/oauth/authorize",
+			server.URL+"/login/oauth/access_token", server.URL+"/user", server.URL+"/user/emails")
+	}
+	golden := filepath.Join(t.TempDir(), "testdata", "github.json")
+
+	t.Run("record", func(t *testing.T) {
+		t.Setenv(gothtest.RecordEnv, "1")
+		gothtest.TestProvider(t, provider(), gothtest.ProviderOptions{
+			Transport: gothtest.NewRecordTransport(t, golden),
+			User:      goth.User{UserID: "583231", NickName: "octocat"},
+		})
+	})
+
+	b, err := os.ReadFile(golden)
+	a.NoError(err)
+	recorded := string(b)
+	a.NotContains(recorded, "gho_live")
+	a.NotContains(recorded, "live-cookie")
+	a.NotContains(recorded, "?")
+	a.Contains(recorded, "octocat@github.com")
+	fixtures := gothtest.LoadFixtures(t, golden)
+	// the token exchange and the user, the email being public
+	a.Len(fixtures, 2)
+	a.Equal("access_token=REDACTED&scope=user&token_type=bearer", fixtures[0].Body)
+	a.True(strings.HasSuffix(fixtures[1].URL, "/user"))
+
+	server.Close()
+	t.Run("replay", func(t *testing.T) {
+		replay := gothtest.NewRecordTransport(t, golden)
+		a.False(replay.Record)
+		gothtest.TestProvider(t, provider(), gothtest.ProviderOptions{
+			Transport: replay,
+			User:      goth.User{UserID: "583231", NickName: "octocat", Email: "octocat@github.com", AccessToken: "REDACTED"},
+		})
+	})
+}
+
+func Test_ScrubSecrets(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	f := gothtest.Fixture{Body: `{"access_token":"ya29.secret","expires_in":3599,"id":"42"}`}
+	gothtest.ScrubSecrets(&f)
+	a.JSONEq(`{"access_token":"REDACTED","expires_in":3599,"id":"42"}`, f.Body)
+
+	f = gothtest.Fixture{Body: "oauth_token=secret&oauth_token_secret=secret&user_id=42"}
+	gothtest.ScrubSecrets(&f)
+	a.Equal("oauth_token=REDACTED&oauth_token_secret=REDACTED&user_id=42", f.Body)
+
+	f = gothtest.Fixture{Header: http.Header{"Content-Type": {"text/html"}}, Body: "<p>access_token=secret</p>"}
+	gothtest.ScrubSecrets(&f)
+	a.Equal("<p>access_token=secret</p>", f.Body)
+}
@@ -0,0 +1,218 @@
+/*
+Package grpcauth authenticates the calls of gRPC services with the identity
+layer of the HTTP handlers: the interceptors validate the gothic session of
+the cookie of the call, e.g. of gRPC-Web, or the access token of a provider in
+its authorization metadata, and inject the goth.User into its context.
+
+	auth := &grpcauth.Authenticator{Providers: []string{"github"}}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(auth.UnaryInterceptor()),
+		grpc.StreamInterceptor(auth.StreamInterceptor()),
+	)
+
+The handlers read the user with UserFromContext. The calls without valid
+credentials fail with codes.Unauthenticated. The bearer tokens are only
+accepted from the Providers, which must verify them with their issuer.
+*/
+package grpcauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ProviderMetadata is the metadata naming the provider of the bearer token of
+// a call, required when the services accept the tokens of several providers.
+const ProviderMetadata = "x-goth-provider"
+
+var (
+	// ErrNoCredentials is returned for the calls without a session nor a
+	// bearer token.
+	ErrNoCredentials = errors.New("grpcauth: no credentials")
+	// ErrNoProvider is returned for the bearer tokens whose provider is
+	// unknown, or isn't one of the Providers.
+	ErrNoProvider = errors.New("grpcauth: the provider of the token is unknown")
+	// ErrLocalProvider is returned by FetchToken for the goth.LocalProvider
+	// providers, which don't verify the tokens with an issuer.
+	ErrLocalProvider = errors.New("grpcauth: the provider doesn't verify its tokens")
+)
+
+// Authenticator authenticates the calls of gRPC services.
+type Authenticator struct {
+	// Providers are the names of the providers whose bearer tokens are
+	// accepted, none by default. The calls name the provider of their token
+	// with the ProviderMetadata, unless there is one provider.
+	Providers []string
+	// VerifyToken returns the user of the bearer token of the provider,
+	// which it must verify with the issuer of the provider, e.g. its JWKS.
+	// FetchToken is used when it is nil.
+	VerifyToken func(ctx context.Context, provider goth.Provider, token string) (goth.User, error)
+	// Skip reports the methods which don't require a user, e.g. the health
+	// checks, by their full name, e.g. "/grpc.health.v1.Health/Check".
+	Skip func(fullMethod string) bool
+}
+
+/*
+FetchToken returns the user of the access token with gothic.FetchUser, so the
+users are cached for gothic.UserCacheTTL: the token is set as the AccessToken
+of a session of the provider, which works with the providers whose sessions
+keep it in their AccessToken JSON field, as most do, and request the user
+from the issuer with it. It returns ErrLocalProvider for the
+goth.LocalProvider providers, whose users aren't fetched with their tokens.
+The providers issuing JWTs may be verified locally instead, e.g. with a
+jwks.Cache.
+*/
+func FetchToken(ctx context.Context, provider goth.Provider, token string) (goth.User, error) {
+	if lp, ok := provider.(goth.LocalProvider); ok && lp.Local() {
+		return goth.User{}, ErrLocalProvider
+	}
+	b, err := json.Marshal(map[string]string{"AccessToken": token})
+	if err != nil {
+		return goth.User{}, err
+	}
+	sess, err := provider.UnmarshalSession(string(b))
+	if err != nil {
+		return goth.User{}, err
+	}
+	return gothic.FetchUser(ctx, provider, sess)
+}
+
+/*
+Authenticate returns the user of the call of ctx:
+
+  - the user of the bearer token of its authorization metadata, fetched with
+    VerifyToken;
+  - or the user of the gothic session of its cookie metadata, see
+    gothic.SessionUser.
+*/
+func (a *Authenticator) Authenticate(ctx context.Context) (goth.User, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if token := bearerToken(md); token != "" {
+		return a.verifyToken(ctx, md, token)
+	}
+	if cookies := md.Get("cookie"); len(cookies) > 0 {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+		if err != nil {
+			return goth.User{}, err
+		}
+		for _, c := range cookies {
+			req.Header.Add("Cookie", c)
+		}
+		for _, ua := range md.Get("user-agent") {
+			req.Header.Set("User-Agent", ua)
+		}
+		return gothic.SessionUser(ctx, req)
+	}
+	return goth.User{}, ErrNoCredentials
+}
+
+func (a *Authenticator) verifyToken(ctx context.Context, md metadata.MD, token string) (goth.User, error) {
+	var name string
+	if names := md.Get(ProviderMetadata); len(names) > 0 {
+		name = names[0]
+	} else if len(a.Providers) == 1 {
+		name = a.Providers[0]
+	}
+	if !a.accepts(name) {
+		return goth.User{}, ErrNoProvider
+	}
+	provider, err := goth.GetProvider(name)
+	if err != nil {
+		return goth.User{}, ErrNoProvider
+	}
+	verify := a.VerifyToken
+	if verify == nil {
+		verify = FetchToken
+	}
+	return verify(ctx, provider, token)
+}
+
+// accepts reports whether the name is one of the Providers.
+func (a *Authenticator) accepts(name string) bool {
+	for _, p := range a.Providers {
+		if name != "" && p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// bearerToken returns the bearer token of the authorization metadata.
+func bearerToken(md metadata.MD) string {
+	for _, value := range md.Get("authorization") {
+		if len(value) > 7 && strings.EqualFold(value[:7], "bearer ") {
+			return strings.TrimSpace(value[7:])
+		}
+	}
+	return ""
+}
+
+// authenticate authenticates the call of the method, unless it is skipped,
+// and returns its context with the user.
+func (a *Authenticator) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	if a.Skip != nil && a.Skip(fullMethod) {
+		return ctx, nil
+	}
+	user, err := a.Authenticate(ctx)
+	if err != nil {
+		// the reasons aren't sent to the clients
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	return NewContext(ctx, user), nil
+}
+
+// UnaryInterceptor returns the interceptor authenticating the unary calls.
+func (a *Authenticator) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor returns the interceptor authenticating the streams.
+func (a *Authenticator) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream is a stream with the context of the user.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+type userKey struct{}
+
+// NewContext returns a copy of ctx with the user, e.g. for the tests of the
+// services.
+func NewContext(ctx context.Context, user goth.User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext returns the user authenticated by the interceptors.
+func UserFromContext(ctx context.Context) (goth.User, bool) {
+	user, ok := ctx.Value(userKey{}).(goth.User)
+	return user, ok
+}
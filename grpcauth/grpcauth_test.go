@@ -0,0 +1,181 @@
+package grpcauth_test
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	"github.com/andreimerlescu/goth/grpcauth"
+	"github.com/andreimerlescu/goth/providers/mock"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// serve serves the health service behind the interceptors of auth, and
+// returns its client and a channel of the users of the calls.
+func serve(t *testing.T, auth *grpcauth.Authenticator) (healthpb.HealthClient, chan goth.User) {
+	users := make(chan goth.User, 1)
+	record := func(ctx context.Context) {
+		user, _ := grpcauth.UserFromContext(ctx)
+		users <- user
+	}
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(auth.UnaryInterceptor(), func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			record(ctx)
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(auth.StreamInterceptor(), func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			record(ss.Context())
+			return handler(srv, ss)
+		}),
+	)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return healthpb.NewHealthClient(conn), users
+}
+
+// issuerProvider is a mock provider verifying its tokens, like the providers
+// requesting the users from their issuer.
+type issuerProvider struct {
+	*mock.Provider
+}
+
+func (p *issuerProvider) Local() bool {
+	return false
+}
+
+func Test_Interceptors_BearerToken(t *testing.T) {
+	a := assert.New(t)
+	p := mock.New(goth.User{UserID: "42", Name: "Homer"})
+	p.SetName("grpc-mock")
+	p.FetchUserFunc = func(session *mock.Session) (goth.User, error) {
+		if session.AccessToken != "valid-token" {
+			return goth.User{}, mock.ErrUnknownUser
+		}
+		return goth.User{Provider: "grpc-mock", UserID: "42", Name: "Homer", AccessToken: session.AccessToken}, nil
+	}
+	other := mock.New(goth.User{UserID: "666"})
+	other.SetName("grpc-other")
+	goth.UseProviders(&issuerProvider{p}, &issuerProvider{other})
+	defer goth.UnregisterProviders("grpc-mock")
+	defer goth.UnregisterProviders("grpc-other")
+
+	client, users := serve(t, &grpcauth.Authenticator{Providers: []string{"grpc-mock"}})
+	ctx := context.Background()
+
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	a.Equal(codes.Unauthenticated, status.Code(err))
+	_, err = client.Check(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer invalid-token"), &healthpb.HealthCheckRequest{})
+	a.Equal(codes.Unauthenticated, status.Code(err))
+	_, err = client.Check(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer valid-token", grpcauth.ProviderMetadata, "unknown"), &healthpb.HealthCheckRequest{})
+	a.Equal(codes.Unauthenticated, status.Code(err))
+	// the clients can't pick the providers which aren't accepted
+	_, err = client.Check(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer any-token", grpcauth.ProviderMetadata, "grpc-other"), &healthpb.HealthCheckRequest{})
+	a.Equal(codes.Unauthenticated, status.Code(err))
+
+	authorized := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer valid-token")
+	_, err = client.Check(authorized, &healthpb.HealthCheckRequest{})
+	a.NoError(err)
+	user := <-users
+	a.Equal("42", user.UserID)
+	a.Equal("grpc-mock", user.Provider)
+
+	// the streams have the user too
+	stream, err := client.Watch(authorized, &healthpb.HealthCheckRequest{})
+	a.NoError(err)
+	_, err = stream.Recv()
+	a.NoError(err)
+	a.Equal("42", (<-users).UserID)
+
+	// the calls name the provider of their token when there are several
+	client, users = serve(t, &grpcauth.Authenticator{Providers: []string{"grpc-other", "grpc-mock"}})
+	_, err = client.Check(authorized, &healthpb.HealthCheckRequest{})
+	a.Equal(codes.Unauthenticated, status.Code(err))
+	_, err = client.Check(metadata.AppendToOutgoingContext(authorized, grpcauth.ProviderMetadata, "grpc-mock"), &healthpb.HealthCheckRequest{})
+	a.NoError(err)
+	a.Equal("42", (<-users).UserID)
+}
+
+func Test_FetchToken_LocalProvider(t *testing.T) {
+	a := assert.New(t)
+	p := mock.New(goth.User{UserID: "42"})
+	p.SetName("grpc-local")
+	goth.UseProviders(p)
+	defer goth.UnregisterProviders("grpc-local")
+
+	// the mock provider returns its user whatever the token
+	_, err := grpcauth.FetchToken(context.Background(), p, "forged-token")
+	a.ErrorIs(err, grpcauth.ErrLocalProvider)
+
+	client, _ := serve(t, &grpcauth.Authenticator{Providers: []string{"grpc-local"}})
+	_, err = client.Check(metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer forged-token"), &healthpb.HealthCheckRequest{})
+	a.Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func Test_Interceptors_Session(t *testing.T) {
+	a := assert.New(t)
+	store := gothic.Store
+	gothic.Store = sessions.NewCookieStore([]byte("test-session-key"))
+	defer func() { gothic.Store = store }()
+	p := mock.New(goth.User{UserID: "7", Name: "Marge"})
+	p.SetName("grpc-session")
+	goth.UseProviders(p)
+	defer goth.UnregisterProviders("grpc-session")
+
+	res := httptest.NewRecorder()
+	sess := &mock.Session{UserID: "7", AccessToken: mock.AccessToken("7")}
+	a.NoError(gothic.StoreInSession("grpc-session", sess.Marshal(), httptest.NewRequest("GET", "/", nil), res))
+
+	client, users := serve(t, &grpcauth.Authenticator{})
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "cookie", res.Header().Get("Set-Cookie"))
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	a.NoError(err)
+	user := <-users
+	a.Equal("7", user.UserID)
+	a.Equal("Marge", user.Name)
+
+	// the sessions of the Inventory are required once it is set
+	gothic.Inventory = gothic.NewMemoryInventory()
+	defer func() { gothic.Inventory = nil }()
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	a.Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func Test_Interceptors_Skip(t *testing.T) {
+	a := assert.New(t)
+	client, users := serve(t, &grpcauth.Authenticator{Skip: func(fullMethod string) bool {
+		return fullMethod == "/grpc.health.v1.Health/Check"
+	}})
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	a.NoError(err)
+	_, ok := grpcauth.UserFromContext(context.Background())
+	a.False(ok)
+	a.Empty((<-users).UserID)
+
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	a.NoError(err)
+	_, err = stream.Recv()
+	a.Equal(codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,238 @@
+// Package jwks caches the JSON Web Key Sets of the identity providers, the
+// keys verifying their ID tokens, so verifying a token doesn't wait for the
+// network on the login path:
+//
+//   - Prefetch fetches the keys in the background, e.g. when the provider is
+//     registered, before the first login,
+//   - the keys older than Refresh are still used while they are fetched again
+//     in the background,
+//   - a token signed with an unknown key, e.g. after a rotation, fetches the
+//     keys again, at most once per Retry, the concurrent logins waiting for
+//     the same fetch.
+//
+// The keys verify the tokens with golang-jwt:
+//
+//	keys := jwks.New("https://www.googleapis.com/oauth2/v3/certs", nil)
+//	keys.Prefetch()
+//	token, err := jwt.Parse(idToken, keys.Keyfunc(ctx), jwt.WithValidMethods([]string{"RS256"}))
+//
+// or verify the ID tokens and their claims with VerifyIDToken:
+//
+//	claims, err := keys.VerifyIDToken(ctx, idToken, jwks.IDToken{Issuer: "https://accounts.google.com", Audience: clientID, Nonce: nonce})
+package jwks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// The defaults of the Cache.
+const (
+	DefaultRefresh = time.Hour
+	DefaultRetry   = time.Minute
+)
+
+// Leeway is the clock skew tolerated by VerifyIDToken on the exp, iat and
+// nbf claims.
+const Leeway = 10 * time.Second
+
+// fetchTimeout bounds the fetches, which outlive the requests waiting for
+// them.
+const fetchTimeout = 30 * time.Second
+
+// ErrKeyNotFound is matched by the errors of Key with errors.Is when the key
+// set has no key with the ID.
+var ErrKeyNotFound = errors.New("jwks: key not found")
+
+// Cache is the key set of a URL, fetched once and shared by the concurrent
+// logins. Its methods are safe for concurrent use.
+type Cache struct {
+	URL string
+	// Client fetches the keys, goth.HTTPClientWithFallBack when it is nil.
+	Client *http.Client
+	// Refresh is how long the keys are used before they are fetched again in
+	// the background, DefaultRefresh when it is zero.
+	Refresh time.Duration
+	// Retry is how long the keys are used before a token signed with an
+	// unknown key fetches them again, DefaultRetry when it is zero.
+	Retry time.Duration
+
+	mu        sync.Mutex
+	keys      jwk.Set
+	fetchedAt time.Time
+	err       error
+	// fetching is closed when the fetch in flight completes.
+	fetching chan struct{}
+}
+
+// New returns the cache of the key set of the URL, fetched with the client.
+func New(url string, client *http.Client) *Cache {
+	return &Cache{URL: url, Client: client}
+}
+
+// Prefetch fetches the keys in the background, unless they are already
+// fetched or being fetched. It doesn't block.
+func (c *Cache) Prefetch() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys == nil {
+		c.fetch()
+	}
+}
+
+// Key returns the public key with the ID, or the single key of the set when
+// the ID is empty. The keys are fetched, and the request waits for them, when
+// the set was never fetched or the key is unknown and the set is older than
+// Retry.
+func (c *Cache) Key(ctx context.Context, kid string) (interface{}, error) {
+	c.mu.Lock()
+	k, found := lookupKey(c.keys, kid)
+	age := goth.Now().Sub(c.fetchedAt)
+	var wait chan struct{}
+	switch {
+	case found && age > durationOr(c.Refresh, DefaultRefresh):
+		c.fetch()
+	case !found && (c.keys == nil || c.fetching != nil || age > durationOr(c.Retry, DefaultRetry)):
+		wait = c.fetch()
+	}
+	c.mu.Unlock()
+	if found {
+		return rawKey(k)
+	}
+	if wait != nil {
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		c.mu.Lock()
+		k, found = lookupKey(c.keys, kid)
+		err := c.err
+		c.mu.Unlock()
+		if found {
+			return rawKey(k)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%w: %q in %s", ErrKeyNotFound, kid, c.URL)
+}
+
+// Keyfunc returns the jwt.Keyfunc of the keys, waiting for them with the
+// context.
+func (c *Cache) Keyfunc(ctx context.Context) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return c.Key(ctx, kid)
+	}
+}
+
+// IDToken is what VerifyIDToken expects of an ID token.
+type IDToken struct {
+	// Issuer is the iss claim, the issuer of the tokens.
+	Issuer string
+	// Audience is one of the aud claim, the ID of the client.
+	Audience string
+	// Nonce is the nonce claim, the nonce of the authentication request, when
+	// it is set.
+	Nonce string
+	// Algorithms are the algorithms signing the tokens, RS256 when they are
+	// empty, restricted with goth.JWTAlgorithms.
+	Algorithms []string
+}
+
+// VerifyIDToken verifies the signature of the ID token with the keys, and
+// its iss, aud, exp and nonce claims, and returns its claims.
+// https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
+func (c *Cache) VerifyIDToken(ctx context.Context, idToken string, want IDToken) (map[string]interface{}, error) {
+	if want.Issuer == "" || want.Audience == "" {
+		return nil, errors.New("jwks: the issuer and the audience of the ID tokens are required")
+	}
+	algorithms := want.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{"RS256"}
+	}
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, c.Keyfunc(ctx),
+		jwt.WithValidMethods(goth.JWTAlgorithms(algorithms...)),
+		jwt.WithIssuer(want.Issuer),
+		jwt.WithAudience(want.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(Leeway),
+		jwt.WithTimeFunc(goth.Now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: invalid ID token: %w", err)
+	}
+	if want.Nonce != "" {
+		if nonce, _ := claims["nonce"].(string); nonce != want.Nonce {
+			return nil, errors.New("jwks: invalid ID token: the nonce doesn't match the one of the authentication request")
+		}
+	}
+	return claims, nil
+}
+
+// fetch starts fetching the keys, unless they are being fetched, and returns
+// the channel closed once they are. c.mu must be held.
+func (c *Cache) fetch() chan struct{} {
+	if c.fetching != nil {
+		return c.fetching
+	}
+	done := make(chan struct{})
+	c.fetching = done
+	go func() {
+		defer close(done)
+		ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+		defer cancel()
+		set, err := jwk.Fetch(ctx, c.URL, jwk.WithHTTPClient(goth.HTTPClientWithFallBack(c.Client)))
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.fetching = nil
+		c.fetchedAt = goth.Now()
+		if err != nil {
+			// keep using the known keys while the provider is unavailable
+			c.err = fmt.Errorf("jwks: could not fetch the keys of %s: %w", c.URL, err)
+			return
+		}
+		c.keys, c.err = set, nil
+	}()
+	return done
+}
+
+func durationOr(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+func lookupKey(set jwk.Set, kid string) (jwk.Key, bool) {
+	if set == nil {
+		return nil, false
+	}
+	if kid == "" {
+		if set.Len() == 1 {
+			return set.Get(0)
+		}
+		return nil, false
+	}
+	return set.LookupKeyID(kid)
+}
+
+func rawKey(k jwk.Key) (interface{}, error) {
+	var raw interface{}
+	if err := k.Raw(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
@@ -0,0 +1,221 @@
+package jwks_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/jwks"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/stretchr/testify/assert"
+)
+
+// keyServer serves the public keys of its current key IDs, counting the
+// fetches.
+type keyServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	fetches int32
+	down    bool
+}
+
+func newKeyServer(t *testing.T, kids ...string) *keyServer {
+	s := &keyServer{keys: map[string]*rsa.PrivateKey{}}
+	for _, kid := range kids {
+		s.add(t, kid)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&s.fetches, 1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.down {
+			http.Error(res, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		set := jwk.NewSet()
+		for kid, k := range s.keys {
+			key, _ := jwk.New(&k.PublicKey)
+			_ = key.Set(jwk.KeyIDKey, kid)
+			set.Add(key)
+		}
+		_ = json.NewEncoder(res).Encode(set)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *keyServer) add(t *testing.T, kid string) *rsa.PrivateKey {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.keys[kid] = k
+	s.mu.Unlock()
+	return k
+}
+
+func (s *keyServer) count() int {
+	return int(atomic.LoadInt32(&s.fetches))
+}
+
+func (s *keyServer) sign(kid string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "42"})
+	token.Header["kid"] = kid
+	signed, _ := token.SignedString(s.keys[kid])
+	return signed
+}
+
+func Test_Prefetch(t *testing.T) {
+	a := assert.New(t)
+	s := newKeyServer(t, "k1")
+
+	c := jwks.New(s.URL, nil)
+	c.Prefetch()
+	c.Prefetch()
+	key, err := c.Key(context.Background(), "k1")
+	a.NoError(err)
+	a.IsType(&rsa.PublicKey{}, key)
+	a.Equal(1, s.count())
+
+	// the cached keys verify the tokens without fetching them
+	token, err := jwt.Parse(s.sign("k1"), c.Keyfunc(context.Background()), jwt.WithValidMethods([]string{"RS256"}))
+	a.NoError(err)
+	a.True(token.Valid)
+	key, err = c.Key(context.Background(), "")
+	a.NoError(err)
+	a.NotNil(key)
+	a.Equal(1, s.count())
+}
+
+func Test_Key_Rotation(t *testing.T) {
+	a := assert.New(t)
+	clock := goth.DefaultClock
+	defer func() { goth.DefaultClock = clock }()
+	now := time.Now()
+	goth.DefaultClock = goth.ClockFunc(func() time.Time { return now })
+
+	s := newKeyServer(t, "k1")
+	c := jwks.New(s.URL, nil)
+	_, err := c.Key(context.Background(), "k1")
+	a.NoError(err)
+	a.Equal(1, s.count())
+
+	// the unknown keys are fetched at most once per Retry
+	s.add(t, "k2")
+	_, err = c.Key(context.Background(), "k2")
+	a.ErrorIs(err, jwks.ErrKeyNotFound)
+	a.Equal(1, s.count())
+	now = now.Add(jwks.DefaultRetry + time.Second)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Key(context.Background(), "k2")
+			a.NoError(err)
+		}()
+	}
+	wg.Wait()
+	a.Equal(2, s.count())
+
+	// the stale keys are used while they are fetched again
+	s.mu.Lock()
+	s.down = true
+	s.mu.Unlock()
+	now = now.Add(jwks.DefaultRefresh + time.Second)
+	_, err = c.Key(context.Background(), "k1")
+	a.NoError(err)
+	a.Eventually(func() bool { return s.count() == 3 }, time.Second, 10*time.Millisecond)
+	_, err = c.Key(context.Background(), "k2")
+	a.NoError(err)
+}
+
+func Test_Key_Errors(t *testing.T) {
+	a := assert.New(t)
+	s := newKeyServer(t, "k1")
+	s.down = true
+
+	c := jwks.New(s.URL, nil)
+	_, err := c.Key(context.Background(), "k1")
+	a.Error(err)
+	a.Contains(err.Error(), "jwks: could not fetch the keys of "+s.URL)
+
+	// the keys are fetched again while none were
+	s.mu.Lock()
+	s.down = false
+	s.mu.Unlock()
+	_, err = c.Key(context.Background(), "k1")
+	a.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = jwks.New(s.URL, nil).Key(ctx, "k1")
+	a.ErrorIs(err, context.Canceled)
+}
+
+func Test_VerifyIDToken(t *testing.T) {
+	a := assert.New(t)
+	s := newKeyServer(t, "k1")
+	c := jwks.New(s.URL, nil)
+	want := jwks.IDToken{Issuer: "https://issuer.example.com", Audience: "client-id", Nonce: "n-0S6_WzA2Mj"}
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "k1"
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		signed, _ := token.SignedString(s.keys["k1"])
+		return signed
+	}
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   want.Issuer,
+			"aud":   want.Audience,
+			"sub":   "42",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": want.Nonce,
+		}
+	}
+
+	claims, err := c.VerifyIDToken(context.Background(), sign(valid()), want)
+	a.NoError(err)
+	a.Equal("42", claims["sub"])
+
+	for name, change := range map[string]func(jwt.MapClaims){
+		"issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"audience": func(c jwt.MapClaims) { c["aud"] = "other-client" },
+		"expired":  func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"no exp":   func(c jwt.MapClaims) { delete(c, "exp") },
+		"nonce":    func(c jwt.MapClaims) { c["nonce"] = "replayed" },
+		"no nonce": func(c jwt.MapClaims) { delete(c, "nonce") },
+	} {
+		claims := valid()
+		change(claims)
+		_, err := c.VerifyIDToken(context.Background(), sign(claims), want)
+		a.Error(err, name)
+	}
+
+	_, err = c.VerifyIDToken(context.Background(), sign(valid()), jwks.IDToken{Issuer: want.Issuer})
+	a.Error(err)
+
+	// the signatures are verified
+	token := sign(valid())
+	_, err = c.VerifyIDToken(context.Background(), token[:len(token)-4]+"AAAA", want)
+	a.Error(err)
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, valid())
+	none, _ := unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	_, err = c.VerifyIDToken(context.Background(), none, want)
+	a.Error(err)
+}
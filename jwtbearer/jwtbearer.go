@@ -0,0 +1,257 @@
+// Package jwtbearer implements the JWT bearer grant of RFC 7523, exchanging an
+// assertion signed by the application for tokens, without a user interaction.
+// It is how Google service accounts impersonate the users of a Workspace
+// domain with domain-wide delegation, and how the Salesforce JWT bearer flow
+// authorizes pre-approved users.
+//
+// Providers supporting the grant implement goth.AssertionProvider:
+//
+//	a, err := jwtbearer.GoogleServiceAccount(jsonKey, "user@example.com", "https://www.googleapis.com/auth/drive.readonly")
+//	...
+//	assertion, err := a.Sign()
+//	...
+//	token, err := provider.(goth.AssertionProvider).TokenFromAssertion(assertion)
+package jwtbearer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// GrantType is the grant_type of the token requests.
+const GrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+// Assertion holds the claims of a JWT bearer assertion, and the key signing it.
+type Assertion struct {
+	// Issuer is the client, e.g. the email of a Google service account, or
+	// the consumer key of a Salesforce connected app.
+	Issuer string
+	// Subject is the user the tokens are issued for.
+	Subject string
+	// Audience is the authorization server, usually its token URL.
+	Audience string
+	// Scopes are sent in the "scope" claim, as Google expects them.
+	Scopes []string
+	// Lifetime is how long the assertion is valid, 5 minutes by default.
+	Lifetime time.Duration
+	// Claims are added to the registered ones.
+	Claims map[string]interface{}
+
+	// Key is an *rsa.PrivateKey, signing with RS256, or an
+	// *ecdsa.PrivateKey, signing with the ES algorithm of its curve.
+	Key   crypto.Signer
+	KeyID string
+
+	now func() time.Time
+}
+
+// Sign returns the signed assertion.
+func (a *Assertion) Sign() (string, error) {
+	var method jwt.SigningMethod
+	switch k := a.Key.(type) {
+	case *rsa.PrivateKey:
+		method = jwt.SigningMethodRS256
+	case *ecdsa.PrivateKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			method = jwt.SigningMethodES256
+		case 384:
+			method = jwt.SigningMethodES384
+		case 521:
+			method = jwt.SigningMethodES512
+		default:
+			return "", errors.New("jwtbearer: unsupported elliptic curve")
+		}
+	default:
+		return "", errors.New("jwtbearer: the assertion needs an RSA or ECDSA private key")
+	}
+	if a.Issuer == "" || a.Audience == "" {
+		return "", errors.New("jwtbearer: the assertion needs an issuer and an audience")
+	}
+
+	now := time.Now()
+	if a.now != nil {
+		now = a.now()
+	}
+	lifetime := a.Lifetime
+	if lifetime == 0 {
+		lifetime = 5 * time.Minute
+	}
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{}
+	for k, v := range a.Claims {
+		claims[k] = v
+	}
+	claims["iss"] = a.Issuer
+	claims["aud"] = a.Audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(lifetime).Unix()
+	claims["jti"] = hex.EncodeToString(id)
+	if a.Subject != "" {
+		claims["sub"] = a.Subject
+	}
+	if len(a.Scopes) > 0 {
+		claims["scope"] = strings.Join(a.Scopes, " ")
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	if a.KeyID != "" {
+		token.Header["kid"] = a.KeyID
+	}
+	return token.SignedString(a.Key)
+}
+
+// Exchange posts the assertion to the token URL, with the extra form values,
+// and returns the tokens. The other members of the response are available
+// with the Extra method of the token. Errors of the authorization server are
+// returned as an *oauth2.RetrieveError. The client defaults to the client of
+// the providers, see goth.HTTPClientWithFallBack.
+func Exchange(client *http.Client, tokenURL, assertion string, values url.Values) (*oauth2.Token, error) {
+	form := url.Values{}
+	for k, v := range values {
+		form[k] = v
+	}
+	form.Set("grant_type", GrantType)
+	form.Set("assertion", assertion)
+
+	req, err := http.NewRequest(http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := goth.HTTPClientWithFallBack(client).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &oauth2.RetrieveError{Response: resp, Body: body}
+		var errorResponse struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+			ErrorURI         string `json:"error_uri"`
+		}
+		if json.Unmarshal(body, &errorResponse) == nil {
+			e.ErrorCode = errorResponse.Error
+			e.ErrorDescription = errorResponse.ErrorDescription
+			e.ErrorURI = errorResponse.ErrorURI
+		}
+		return nil, e
+	}
+	if contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); contentType != "" && contentType != "application/json" {
+		return nil, fmt.Errorf("jwtbearer: unexpected token response of type %s", contentType)
+	}
+
+	var raw map[string]interface{}
+	err = json.Unmarshal(body, &raw)
+	if err != nil {
+		return nil, err
+	}
+	token := &oauth2.Token{}
+	token.AccessToken, _ = raw["access_token"].(string)
+	token.TokenType, _ = raw["token_type"].(string)
+	token.RefreshToken, _ = raw["refresh_token"].(string)
+	if expiresIn, ok := raw["expires_in"].(float64); ok && expiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("jwtbearer: the token response has no access token")
+	}
+	return token.WithExtra(raw), nil
+}
+
+// GoogleTokenURL is the token URL of Google, and the audience of the
+// assertions of its service accounts.
+const GoogleTokenURL = "https://oauth2.googleapis.com/token"
+
+// GoogleServiceAccount returns the assertion of the service account, from its
+// JSON key file, for the scopes. With domain-wide delegation, the subject is
+// the email of the user impersonated; it is empty to act as the service
+// account itself.
+func GoogleServiceAccount(jsonKey []byte, subject string, scopes ...string) (*Assertion, error) {
+	var key struct {
+		Type         string `json:"type"`
+		ClientEmail  string `json:"client_email"`
+		PrivateKeyID string `json:"private_key_id"`
+		PrivateKey   string `json:"private_key"`
+		TokenURI     string `json:"token_uri"`
+	}
+	err := json.Unmarshal(jsonKey, &key)
+	if err != nil {
+		return nil, err
+	}
+	if key.Type != "service_account" {
+		return nil, fmt.Errorf("jwtbearer: %q is not a service account key", key.Type)
+	}
+	signer, err := ParsePrivateKey([]byte(key.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = GoogleTokenURL
+	}
+	return &Assertion{
+		Issuer:   key.ClientEmail,
+		Subject:  subject,
+		Audience: key.TokenURI,
+		Scopes:   scopes,
+		Lifetime: time.Hour,
+		Key:      signer,
+		KeyID:    key.PrivateKeyID,
+	}, nil
+}
+
+// ParsePrivateKey parses a PEM encoded RSA or ECDSA private key, in the
+// PKCS #8, PKCS #1 or SEC 1 format.
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwtbearer: no PEM encoded private key")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			return key, nil
+		case *ecdsa.PrivateKey:
+			return key, nil
+		}
+		return nil, errors.New("jwtbearer: the private key is neither an RSA nor an ECDSA key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("jwtbearer: could not parse the private key")
+}
@@ -0,0 +1,135 @@
+package jwtbearer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func Test_Sign(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	now := time.Now()
+	assertion := &Assertion{
+		Issuer:   "client",
+		Subject:  "user@example.com",
+		Audience: "https://login.example.com",
+		Scopes:   []string{"a", "b"},
+		Claims:   map[string]interface{}{"iss": "ignored", "extra": "value"},
+		Key:      key,
+		KeyID:    "k1",
+		now:      func() time.Time { return now },
+	}
+	s, err := assertion.Sign()
+	a.NoError(err)
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(s, claims, func(*jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithAudience("https://login.example.com"))
+	a.NoError(err)
+	a.Equal("k1", token.Header["kid"])
+	a.Equal("client", claims["iss"])
+	a.Equal("user@example.com", claims["sub"])
+	a.Equal("a b", claims["scope"])
+	a.Equal("value", claims["extra"])
+	a.Equal(float64(now.Add(5*time.Minute).Unix()), claims["exp"])
+	a.NotEmpty(claims["jti"])
+
+	_, err = (&Assertion{Issuer: "client", Audience: "aud"}).Sign()
+	a.Error(err)
+	_, err = (&Assertion{Key: key}).Sign()
+	a.Error(err)
+}
+
+func Test_Exchange(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.PostFormValue("grant_type") != GrantType || req.PostFormValue("assertion") != "signed" {
+			res.Header().Set("Content-Type", "application/json")
+			res.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(res, `{"error":"invalid_grant","error_description":"user hasn't approved this consumer"}`)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		fmt.Fprintf(res, `{"access_token":"token","token_type":"Bearer","expires_in":3600,"instance_url":"https://acme.my.salesforce.com","extra":%q}`, req.PostFormValue("extra"))
+	}))
+	defer server.Close()
+
+	token, err := Exchange(server.Client(), server.URL, "signed", map[string][]string{"extra": {"value"}})
+	a.NoError(err)
+	a.Equal("token", token.AccessToken)
+	a.Equal("Bearer", token.TokenType)
+	a.WithinDuration(time.Now().Add(time.Hour), token.Expiry, time.Minute)
+	a.Equal("https://acme.my.salesforce.com", token.Extra("instance_url"))
+	a.Equal("value", token.Extra("extra"))
+
+	_, err = Exchange(server.Client(), server.URL, "other", nil)
+	e, ok := err.(*oauth2.RetrieveError)
+	a.True(ok)
+	a.Equal("invalid_grant", e.ErrorCode)
+	a.Equal("user hasn't approved this consumer", e.ErrorDescription)
+}
+
+func Test_GoogleServiceAccount(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	jsonKey, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "project",
+		"private_key_id": "0123abcd",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "robot@project.iam.gserviceaccount.com",
+		"token_uri":      "https://oauth2.googleapis.com/token",
+	})
+
+	assertion, err := GoogleServiceAccount(jsonKey, "user@example.com", "https://www.googleapis.com/auth/drive.readonly")
+	a.NoError(err)
+	a.Equal("robot@project.iam.gserviceaccount.com", assertion.Issuer)
+	a.Equal("user@example.com", assertion.Subject)
+	a.Equal(GoogleTokenURL, assertion.Audience)
+	a.Equal("0123abcd", assertion.KeyID)
+	a.Equal(key, assertion.Key)
+
+	_, err = GoogleServiceAccount([]byte(`{"type":"authorized_user"}`), "")
+	a.Error(err)
+}
+
+func Test_ParsePrivateKey(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	key, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+	a.NoError(err)
+	a.Equal(rsaKey, key)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	der, _ := x509.MarshalECPrivateKey(ecKey)
+	key, err = ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	a.NoError(err)
+	a.Equal(ecKey, key)
+
+	_, err = ParsePrivateKey([]byte("not a key"))
+	a.Error(err)
+}
@@ -0,0 +1,252 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Age wraps the data keys in age files encrypted to an X25519 recipient, see
+// https://age-encryption.org/v1. The wrapped keys can be unwrapped with the
+// age command line tool and the identity, e.g. to rotate them.
+type Age struct {
+	identity  []byte
+	recipient []byte
+}
+
+// NewAge returns the provider of the identity, an AGE-SECRET-KEY-1... string.
+func NewAge(identity string) (*Age, error) {
+	hrp, secret, err := bech32Decode(identity)
+	if err != nil {
+		return nil, fmt.Errorf("kms: age identity: %w", err)
+	}
+	if hrp != "age-secret-key-" || len(secret) != curve25519.ScalarSize {
+		return nil, errors.New("kms: malformed age identity")
+	}
+	recipient, err := curve25519.X25519(secret, curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("kms: age identity: %w", err)
+	}
+	return &Age{identity: secret, recipient: recipient}, nil
+}
+
+// NewAgeFile returns the provider of the first identity of the file, as
+// written by age-keygen.
+func NewAgeFile(path string) (*Age, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "AGE-SECRET-KEY-1") {
+			return NewAge(line)
+		}
+	}
+	return nil, fmt.Errorf("kms: no age identity in %s", path)
+}
+
+// GenerateAgeIdentity returns a new age identity.
+func GenerateAgeIdentity() (string, error) {
+	secret := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return strings.ToUpper(bech32Encode("age-secret-key-", secret)), nil
+}
+
+// Recipient returns the recipient of the identity, an age1... string.
+func (a *Age) Recipient() string {
+	return bech32Encode("age", a.recipient)
+}
+
+const (
+	ageIntro       = "age-encryption.org/v1\n"
+	ageX25519Label = "age-encryption.org/v1/X25519"
+	ageFileKeySize = 16
+)
+
+var ageBase64 = base64.RawStdEncoding
+
+// WrapKey encrypts the key in an age file to the recipient of the identity.
+func (a *Age) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	if err := ageFIPS(); err != nil {
+		return nil, err
+	}
+	fileKey := make([]byte, ageFileKeySize)
+	ephemeral := make([]byte, curve25519.ScalarSize)
+	nonce := make([]byte, 16)
+	for _, b := range [][]byte{fileKey, ephemeral, nonce} {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+	}
+
+	share, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := curve25519.X25519(ephemeral, a.recipient)
+	if err != nil {
+		return nil, err
+	}
+	wrapKey := ageHKDF(shared, append(append([]byte{}, share...), a.recipient...), ageX25519Label)
+	body, err := ageSeal(wrapKey, make([]byte, chacha20poly1305.NonceSize), fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.WriteString(ageIntro)
+	out.WriteString("-> X25519 " + ageBase64.EncodeToString(share) + "\n")
+	out.WriteString(ageBase64.EncodeToString(body) + "\n")
+	out.WriteString("---")
+	out.WriteString(" " + ageBase64.EncodeToString(ageMAC(fileKey, out.Bytes())) + "\n")
+	out.Write(nonce)
+
+	payload, err := ageSeal(ageHKDF(fileKey, nonce, "payload"), ageChunkNonce(), key)
+	if err != nil {
+		return nil, err
+	}
+	out.Write(payload)
+	return out.Bytes(), nil
+}
+
+// UnwrapKey decrypts the key of an age file encrypted to the recipient of
+// the identity.
+func (a *Age) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	if err := ageFIPS(); err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(wrapped, []byte(ageIntro)) {
+		return nil, errors.New("kms: not an age file")
+	}
+	end := bytes.Index(wrapped, []byte("\n---"))
+	if end < 0 {
+		return nil, errors.New("kms: malformed age header")
+	}
+	header := wrapped[:end+len("\n---")]
+	rest := wrapped[len(header):]
+	newline := bytes.IndexByte(rest, '\n')
+	if newline < 0 || !bytes.HasPrefix(rest, []byte(" ")) {
+		return nil, errors.New("kms: malformed age header")
+	}
+	mac, err := ageBase64.DecodeString(string(rest[1:newline]))
+	if err != nil {
+		return nil, fmt.Errorf("kms: malformed age header: %w", err)
+	}
+	payload := rest[newline+1:]
+
+	var fileKey []byte
+	lines := strings.Split(string(header[len(ageIntro):len(header)-len("\n---")]), "\n")
+	for i := 0; i < len(lines); i++ {
+		args := strings.Fields(strings.TrimPrefix(lines[i], "->"))
+		var body string
+		for i+1 < len(lines) {
+			i++
+			body += lines[i]
+			if len(lines[i]) < 64 {
+				break
+			}
+		}
+		if fileKey != nil || len(args) != 2 || args[0] != "X25519" {
+			continue
+		}
+		fileKey = a.unwrapFileKey(args[1], body)
+	}
+	if fileKey == nil {
+		return nil, errors.New("kms: age file not encrypted to the identity")
+	}
+	if !hmac.Equal(mac, ageMAC(fileKey, header)) {
+		return nil, errors.New("kms: age header MAC mismatch")
+	}
+	if len(payload) < 16 {
+		return nil, errors.New("kms: malformed age payload")
+	}
+	nonce, ciphertext := payload[:16], payload[16:]
+	aead, err := chacha20poly1305.New(ageHKDF(fileKey, nonce, "payload"))
+	if err != nil {
+		return nil, err
+	}
+	key, err := aead.Open(nil, ageChunkNonce(), ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("kms: age payload: %w", err)
+	}
+	return key, nil
+}
+
+// unwrapFileKey returns the file key of an X25519 stanza, or nil when the
+// stanza isn't encrypted to the identity.
+func (a *Age) unwrapFileKey(arg, body string) []byte {
+	share, err := ageBase64.DecodeString(arg)
+	if err != nil || len(share) != curve25519.PointSize {
+		return nil
+	}
+	wrapped, err := ageBase64.DecodeString(body)
+	if err != nil {
+		return nil
+	}
+	shared, err := curve25519.X25519(a.identity, share)
+	if err != nil {
+		return nil
+	}
+	aead, err := chacha20poly1305.New(ageHKDF(shared, append(append([]byte{}, share...), a.recipient...), ageX25519Label))
+	if err != nil {
+		return nil
+	}
+	fileKey, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), wrapped, nil)
+	if err != nil || len(fileKey) != ageFileKeySize {
+		return nil
+	}
+	return fileKey
+}
+
+func ageFIPS() error {
+	if goth.FIPS() {
+		return &goth.FIPSError{What: "age encryption (X25519, ChaCha20-Poly1305)"}
+	}
+	return nil
+}
+
+func ageHKDF(secret, salt []byte, info string) []byte {
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func ageSeal(key, nonce, plaintext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, nonce, plaintext, nil), nil
+}
+
+func ageMAC(fileKey, header []byte) []byte {
+	mac := hmac.New(sha256.New, ageHKDF(fileKey, nil, "header"))
+	mac.Write(header)
+	return mac.Sum(nil)
+}
+
+// ageChunkNonce returns the nonce of the first and last chunk of the payload:
+// the data keys fit in a single chunk.
+func ageChunkNonce() []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	nonce[len(nonce)-1] = 1
+	return nonce
+}
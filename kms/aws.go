@@ -0,0 +1,180 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// AWS wraps the data keys with a key of AWS KMS, with its Encrypt and Decrypt
+// actions.
+type AWS struct {
+	// KeyID is the ID, ARN or alias of the key.
+	KeyID  string
+	Region string
+	// EncryptionContext is bound to the wrapped keys, and required to unwrap
+	// them.
+	EncryptionContext map[string]string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Endpoint defaults to https://kms.<region>.amazonaws.com.
+	Endpoint   string
+	HTTPClient *http.Client
+
+	now func() time.Time
+}
+
+// NewAWS returns the provider of the key in the region, with the credentials
+// of the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+// environment variables.
+func NewAWS(keyID, region string) *AWS {
+	return &AWS{
+		KeyID:           keyID,
+		Region:          region,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// WrapKey encrypts the key with the KMS key.
+func (a *AWS) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	var out struct {
+		CiphertextBlob []byte
+	}
+	err := a.call(ctx, "Encrypt", map[string]interface{}{
+		"KeyId":             a.KeyID,
+		"Plaintext":         key,
+		"EncryptionContext": a.EncryptionContext,
+	}, &out)
+	return out.CiphertextBlob, err
+}
+
+// UnwrapKey decrypts the key with the KMS key.
+func (a *AWS) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var out struct {
+		Plaintext []byte
+	}
+	err := a.call(ctx, "Decrypt", map[string]interface{}{
+		"KeyId":             a.KeyID,
+		"CiphertextBlob":    wrapped,
+		"EncryptionContext": a.EncryptionContext,
+	}, &out)
+	return out.Plaintext, err
+}
+
+// call calls an action of the JSON API of KMS.
+func (a *AWS) call(ctx context.Context, action string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	endpoint := a.Endpoint
+	if endpoint == "" {
+		endpoint = "https://kms." + a.Region + ".amazonaws.com"
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	if a.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", a.SessionToken)
+	}
+	a.sign(req, body, "kms")
+
+	resp, err := goth.HTTPClientWithFallBack(a.HTTPClient).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(b, &e)
+		return fmt.Errorf("kms: AWS KMS %s responded with a %d: %s %s", action, resp.StatusCode, e.Type, e.Message)
+	}
+	return json.Unmarshal(b, out)
+}
+
+// sign signs the request with the Signature Version 4 of AWS, see
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func (a *AWS) sign(req *http.Request, body []byte, service string) {
+	now := time.Now
+	if a.now != nil {
+		now = a.now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	names := []string{"host"}
+	values := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		names = append(names, lower)
+		values[lower] = strings.TrimSpace(req.Header.Get(name))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + values[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	scope := date + "/" + a.Region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+a.SecretAccessKey), date)
+	key = hmacSHA256(key, a.Region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+a.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
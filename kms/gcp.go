@@ -0,0 +1,97 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// GCP wraps the data keys with a key of Google Cloud KMS, with its encrypt and
+// decrypt methods.
+type GCP struct {
+	// KeyName is the resource name of the key, e.g.
+	// projects/p/locations/global/keyRings/r/cryptoKeys/k.
+	KeyName string
+	// AdditionalAuthenticatedData is bound to the wrapped keys, and required
+	// to unwrap them.
+	AdditionalAuthenticatedData []byte
+	// HTTPClient authorizes the requests, e.g. the client of
+	// google.DefaultClient with the https://www.googleapis.com/auth/cloudkms
+	// scope.
+	HTTPClient *http.Client
+	// Endpoint defaults to https://cloudkms.googleapis.com.
+	Endpoint string
+}
+
+// NewGCP returns the provider of the key, with the client authorizing the
+// requests.
+func NewGCP(keyName string, client *http.Client) *GCP {
+	return &GCP{KeyName: keyName, HTTPClient: client}
+}
+
+// WrapKey encrypts the key with the KMS key.
+func (g *GCP) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	var out struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	err := g.call(ctx, "encrypt", map[string]interface{}{
+		"plaintext":                   key,
+		"additionalAuthenticatedData": g.AdditionalAuthenticatedData,
+	}, &out)
+	return out.Ciphertext, err
+}
+
+// UnwrapKey decrypts the key with the KMS key.
+func (g *GCP) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var out struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	err := g.call(ctx, "decrypt", map[string]interface{}{
+		"ciphertext":                  wrapped,
+		"additionalAuthenticatedData": g.AdditionalAuthenticatedData,
+	}, &out)
+	return out.Plaintext, err
+}
+
+func (g *GCP) call(ctx context.Context, method string, in, out interface{}) error {
+	if g.HTTPClient == nil {
+		return fmt.Errorf("kms: Google Cloud KMS needs an authorized HTTP client")
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	endpoint := g.Endpoint
+	if endpoint == "" {
+		endpoint = "https://cloudkms.googleapis.com"
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint+"/v1/"+g.KeyName+":"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error struct {
+				Status  string `json:"status"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(b, &e)
+		return fmt.Errorf("kms: Google Cloud KMS %s responded with a %d: %s %s", method, resp.StatusCode, e.Error.Status, e.Error.Message)
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,132 @@
+// Package kms encrypts the sessions of gothic with data keys wrapped by a key
+// management service, so the session keys never live in environment
+// variables or files: only their wrapped form does, and it is useless without
+// the key encryption key kept by the service.
+//
+// Generate a data key once, and keep its wrapped form in the configuration:
+//
+//	provider := kms.NewAWS("arn:aws:kms:eu-west-1:111122223333:key/...", "eu-west-1")
+//	wrapped, err := kms.NewDataKey(ctx, provider)
+//
+// Then unwrap it when the application starts:
+//
+//	gothic.Store, err = kms.NewCookieStore(ctx, provider, os.Getenv("SESSION_DATA_KEY"))
+//
+// The values of the sessions kept by other stores, e.g. in Redis, can be
+// encrypted at rest too:
+//
+//	gothic.Cipher, err = kms.NewStoreCipher(ctx, provider, os.Getenv("SESSION_DATA_KEY"))
+//
+// The key encryption key is kept by AWS KMS, Google Cloud KMS, or an age
+// identity file, or any KeyProvider.
+package kms
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"golang.org/x/crypto/hkdf"
+)
+
+// KeyProvider wraps and unwraps data keys with a key encryption key it keeps.
+type KeyProvider interface {
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// dataKeySize is the size of a data key: the HMAC-SHA256 key authenticating
+// the sessions followed by the AES-256 key encrypting them.
+const dataKeySize = 64
+
+// NewDataKey generates a data key, and returns it wrapped by the provider,
+// base64 encoded to be kept in the configuration.
+func NewDataKey(ctx context.Context, provider KeyProvider) (string, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	wrapped, err := provider.WrapKey(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+// Codecs unwraps the data keys with the provider, and returns the codecs
+// authenticating and encrypting the sessions with them. The first key
+// encodes the sessions, and the others only decode them, to rotate the keys.
+func Codecs(ctx context.Context, provider KeyProvider, wrappedKeys ...string) ([]securecookie.Codec, error) {
+	pairs, err := keyPairs(ctx, provider, wrappedKeys)
+	if err != nil {
+		return nil, err
+	}
+	return securecookie.CodecsFromPairs(pairs...), nil
+}
+
+// NewCookieStore returns a cookie store of the sessions encrypted with the
+// data keys, see Codecs.
+func NewCookieStore(ctx context.Context, provider KeyProvider, wrappedKeys ...string) (*sessions.CookieStore, error) {
+	pairs, err := keyPairs(ctx, provider, wrappedKeys)
+	if err != nil {
+		return nil, err
+	}
+	store := sessions.NewCookieStore(pairs...)
+	store.Options.HttpOnly = true
+	return store, nil
+}
+
+// NewStoreCipher returns a cipher encrypting the values of the sessions at
+// rest with keys derived from the data keys, see gothic.Cipher. The first key
+// encrypts the values, and the others only decrypt them.
+func NewStoreCipher(ctx context.Context, provider KeyProvider, wrappedKeys ...string) (gothic.StoreCipher, error) {
+	pairs, err := keyPairs(ctx, provider, wrappedKeys)
+	if err != nil {
+		return nil, err
+	}
+	var keys [][]byte
+	for i := 0; i < len(pairs); i += 2 {
+		// the keys of the cookies aren't reused with another mode of AES
+		key := make([]byte, 32)
+		_, err := io.ReadFull(hkdf.New(sha256.New, append(append([]byte{}, pairs[i]...), pairs[i+1]...), nil, []byte("goth store cipher")), key)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return gothic.NewAESCipher(keys...)
+}
+
+func keyPairs(ctx context.Context, provider KeyProvider, wrappedKeys []string) ([][]byte, error) {
+	if len(wrappedKeys) == 0 {
+		return nil, errors.New("kms: no data keys")
+	}
+	var pairs [][]byte
+	for i, wrappedKey := range wrappedKeys {
+		wrapped, err := base64.StdEncoding.DecodeString(wrappedKey)
+		if err != nil {
+			return nil, fmt.Errorf("kms: data key %d: %w", i, err)
+		}
+		key, err := provider.UnwrapKey(ctx, wrapped)
+		if err != nil {
+			return nil, fmt.Errorf("kms: data key %d: %w", i, err)
+		}
+		if len(key) != dataKeySize {
+			return nil, fmt.Errorf("kms: data key %d: unwrapped to %d bytes instead of %d", i, len(key), dataKeySize)
+		}
+		hashKey, blockKey := key[:32], key[32:]
+		if err := goth.CheckSessionKeys(hashKey, blockKey); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, hashKey, blockKey)
+	}
+	return pairs, nil
+}
@@ -0,0 +1,258 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/assert"
+)
+
+// xorProvider wraps the keys with a XOR, to test the codecs.
+type xorProvider struct{}
+
+func (xorProvider) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	return xor(key), nil
+}
+
+func (xorProvider) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return xor(wrapped), nil
+}
+
+func xor(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[i] = b[i] ^ 0x5c
+	}
+	return out
+}
+
+func Test_NewCookieStore(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ctx := context.Background()
+
+	old, err := NewDataKey(ctx, xorProvider{})
+	a.NoError(err)
+	current, err := NewDataKey(ctx, xorProvider{})
+	a.NoError(err)
+	a.NotEqual(old, current)
+
+	oldCodecs, err := Codecs(ctx, xorProvider{}, old)
+	a.NoError(err)
+	encoded, err := securecookie.EncodeMulti("_gothic_session", "value", oldCodecs...)
+	a.NoError(err)
+
+	// the rotated store decodes the sessions of the old key
+	store, err := NewCookieStore(ctx, xorProvider{}, current, old)
+	a.NoError(err)
+	a.True(store.Options.HttpOnly)
+	var value string
+	a.NoError(securecookie.DecodeMulti("_gothic_session", encoded, &value, store.Codecs...))
+	a.Equal("value", value)
+
+	// and encodes them with the current key
+	encoded, err = securecookie.EncodeMulti("_gothic_session", "value", store.Codecs...)
+	a.NoError(err)
+	a.Error(securecookie.DecodeMulti("_gothic_session", encoded, &value, oldCodecs...))
+
+	_, err = NewCookieStore(ctx, xorProvider{})
+	a.Error(err)
+	_, err = NewCookieStore(ctx, xorProvider{}, base64.StdEncoding.EncodeToString([]byte("short")))
+	a.Equal("kms: data key 0: unwrapped to 5 bytes instead of 64", err.Error())
+}
+
+func Test_NewStoreCipher(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ctx := context.Background()
+
+	old, err := NewDataKey(ctx, xorProvider{})
+	a.NoError(err)
+	current, err := NewDataKey(ctx, xorProvider{})
+	a.NoError(err)
+
+	oldCipher, err := NewStoreCipher(ctx, xorProvider{}, old)
+	a.NoError(err)
+	ciphertext, err := oldCipher.Encrypt([]byte("value"))
+	a.NoError(err)
+
+	cipher, err := NewStoreCipher(ctx, xorProvider{}, current, old)
+	a.NoError(err)
+	plaintext, err := cipher.Decrypt(ciphertext)
+	a.NoError(err)
+	a.Equal([]byte("value"), plaintext)
+
+	ciphertext, err = cipher.Encrypt([]byte("value"))
+	a.NoError(err)
+	_, err = oldCipher.Decrypt(ciphertext)
+	a.Error(err)
+}
+
+func Test_AWS(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	kek := byte(0x36)
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		a.Equal("application/x-amz-json-1.1", req.Header.Get("Content-Type"))
+		a.Equal("token", req.Header.Get("X-Amz-Security-Token"))
+		a.True(strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		a.Contains(req.Header.Get("Authorization"), "/eu-west-1/kms/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, Signature=")
+
+		var in struct {
+			KeyId             string
+			Plaintext         []byte
+			CiphertextBlob    []byte
+			EncryptionContext map[string]string
+		}
+		a.NoError(json.NewDecoder(req.Body).Decode(&in))
+		a.Equal("alias/goth", in.KeyId)
+		if in.EncryptionContext["app"] != "goth" {
+			res.WriteHeader(http.StatusBadRequest)
+			_, _ = res.Write([]byte(`{"__type":"InvalidCiphertextException","message":"bad context"}`))
+			return
+		}
+		switch req.Header.Get("X-Amz-Target") {
+		case "TrentService.Encrypt":
+			_ = json.NewEncoder(res).Encode(map[string][]byte{"CiphertextBlob": bytes.Map(func(r rune) rune { return r ^ rune(kek) }, in.Plaintext)})
+		case "TrentService.Decrypt":
+			_ = json.NewEncoder(res).Encode(map[string][]byte{"Plaintext": bytes.Map(func(r rune) rune { return r ^ rune(kek) }, in.CiphertextBlob)})
+		}
+	}))
+	defer ts.Close()
+
+	provider := &AWS{
+		KeyID:             "alias/goth",
+		Region:            "eu-west-1",
+		EncryptionContext: map[string]string{"app": "goth"},
+		AccessKeyID:       "AKID",
+		SecretAccessKey:   "secret",
+		SessionToken:      "token",
+		Endpoint:          ts.URL,
+	}
+	wrapped, err := provider.WrapKey(context.Background(), []byte("key"))
+	a.NoError(err)
+	a.Equal([]byte{'k' ^ kek, 'e' ^ kek, 'y' ^ kek}, wrapped)
+	key, err := provider.UnwrapKey(context.Background(), wrapped)
+	a.NoError(err)
+	a.Equal([]byte("key"), key)
+
+	provider.EncryptionContext = nil
+	_, err = provider.UnwrapKey(context.Background(), wrapped)
+	a.Equal("kms: AWS KMS Decrypt responded with a 400: InvalidCiphertextException bad context", err.Error())
+}
+
+func Test_AWS_Sign(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	// post-vanilla of the test suite of the Signature Version 4
+	provider := &AWS{
+		Region:          "us-east-1",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		now: func() time.Time {
+			return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+		},
+	}
+	req, err := http.NewRequest("POST", "https://example.amazonaws.com/", nil)
+	a.NoError(err)
+	provider.sign(req, nil, "service")
+	a.Equal("20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	a.Equal("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		req.Header.Get("Authorization"))
+}
+
+func Test_GCP(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var in struct {
+			Plaintext  []byte `json:"plaintext"`
+			Ciphertext []byte `json:"ciphertext"`
+		}
+		a.NoError(json.NewDecoder(req.Body).Decode(&in))
+		switch req.URL.Path {
+		case "/v1/projects/p/locations/global/keyRings/r/cryptoKeys/k:encrypt":
+			_ = json.NewEncoder(res).Encode(map[string][]byte{"ciphertext": xor(in.Plaintext)})
+		case "/v1/projects/p/locations/global/keyRings/r/cryptoKeys/k:decrypt":
+			_ = json.NewEncoder(res).Encode(map[string][]byte{"plaintext": xor(in.Ciphertext)})
+		default:
+			res.WriteHeader(http.StatusNotFound)
+			_, _ = res.Write([]byte(`{"error":{"code":404,"status":"NOT_FOUND","message":"key not found"}}`))
+		}
+	}))
+	defer ts.Close()
+
+	provider := NewGCP("projects/p/locations/global/keyRings/r/cryptoKeys/k", ts.Client())
+	provider.Endpoint = ts.URL
+	wrapped, err := provider.WrapKey(context.Background(), []byte("key"))
+	a.NoError(err)
+	a.Equal(xor([]byte("key")), wrapped)
+	key, err := provider.UnwrapKey(context.Background(), wrapped)
+	a.NoError(err)
+	a.Equal([]byte("key"), key)
+
+	provider.KeyName = "projects/p/locations/global/keyRings/r/cryptoKeys/unknown"
+	_, err = provider.UnwrapKey(context.Background(), wrapped)
+	a.Equal("kms: Google Cloud KMS decrypt responded with a 404: NOT_FOUND key not found", err.Error())
+
+	_, err = NewGCP("key", nil).WrapKey(context.Background(), []byte("key"))
+	a.Error(err)
+}
+
+func Test_Age(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	if goth.FIPS() {
+		_, err := (&Age{}).WrapKey(context.Background(), []byte("key"))
+		a.True(errors.Is(err, goth.ErrNotFIPSApproved))
+		return
+	}
+
+	// the test identity of the age specification
+	provider, err := NewAge("AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEX")
+	a.NoError(err)
+	a.Equal(bytes.Repeat([]byte{0x42}, 32), provider.identity)
+	a.Equal("age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj", provider.Recipient())
+
+	wrapped, err := provider.WrapKey(context.Background(), []byte("key"))
+	a.NoError(err)
+	a.True(bytes.HasPrefix(wrapped, []byte("age-encryption.org/v1\n-> X25519 ")))
+	key, err := provider.UnwrapKey(context.Background(), wrapped)
+	a.NoError(err)
+	a.Equal([]byte("key"), key)
+
+	tampered := append([]byte{}, wrapped...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = provider.UnwrapKey(context.Background(), tampered)
+	a.Error(err)
+
+	identity, err := GenerateAgeIdentity()
+	a.NoError(err)
+	a.True(strings.HasPrefix(identity, "AGE-SECRET-KEY-1"))
+	path := filepath.Join(t.TempDir(), "key.txt")
+	a.NoError(os.WriteFile(path, []byte("# created: 2026-10-17\n# public key: ...\n"+identity+"\n"), 0600))
+	other, err := NewAgeFile(path)
+	a.NoError(err)
+	a.True(strings.HasPrefix(other.Recipient(), "age1"))
+	_, err = other.UnwrapKey(context.Background(), wrapped)
+	a.Equal("kms: age file not encrypted to the identity", err.Error())
+
+	_, err = NewAge("AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEY")
+	a.Error(err)
+}
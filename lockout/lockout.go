@@ -0,0 +1,214 @@
+// Package lockout locks out the clients failing to sign in repeatedly, per IP
+// address or identity, e.g. guessing codes or tokens, or bombing a user with
+// consent or second factor prompts. The failures are the auth_failed events
+// of gothic; a client reaching the failures of a policy is locked out for a
+// cooldown, doubled for every lockout in a row.
+//
+//	guard := lockout.New(lockout.NewMemoryStore(),
+//		lockout.Rule{Name: "ip", Key: ratelimit.ByIP, Policy: lockout.DefaultPolicy},
+//	)
+//	guard.OnLockout = func(l lockout.Lockout) { alert(l) }
+//	guard.Register()
+//	http.Handle("/auth/", guard.Handler(http.HandlerFunc(gothic.BeginAuthHandler)))
+package lockout
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/andreimerlescu/goth/gothic"
+	"github.com/andreimerlescu/goth/ratelimit"
+)
+
+// Policy locks out a key after MaxFailures failures within Window, for
+// Cooldown, doubled for every lockout in a row up to MaxCooldown.
+type Policy struct {
+	MaxFailures int
+	Window      time.Duration
+	Cooldown    time.Duration
+	MaxCooldown time.Duration
+}
+
+// DefaultPolicy locks out after 10 failures within 15 minutes, for 5 minutes
+// up to a day.
+var DefaultPolicy = Policy{
+	MaxFailures: 10,
+	Window:      15 * time.Minute,
+	Cooldown:    5 * time.Minute,
+	MaxCooldown: 24 * time.Hour,
+}
+
+// cooldown returns the cooldown of the nth lockout in a row, from 1.
+func (p Policy) cooldown(n int) time.Duration {
+	cooldown := float64(p.Cooldown) * math.Pow(2, float64(n-1))
+	if p.MaxCooldown > 0 && cooldown > float64(p.MaxCooldown) {
+		return p.MaxCooldown
+	}
+	return time.Duration(cooldown)
+}
+
+// Store keeps the failures and lockouts by key.
+type Store interface {
+	// Fail records a failure of the key, and returns the end of its lockout
+	// when the failure locks it out, or else the zero time.
+	Fail(key string, policy Policy) (lockedUntil time.Time, err error)
+	// LockedUntil returns the end of the lockout of the key, or the zero time
+	// when it isn't locked out.
+	LockedUntil(key string) (time.Time, error)
+	// Reset forgets the failures and lockouts of the key.
+	Reset(key string) error
+}
+
+// Rule locks out the requests with the same key.
+type Rule struct {
+	// Name tells the keys of the rule apart in the store, e.g. "ip". It
+	// defaults to the index of the rule.
+	Name   string
+	Key    ratelimit.KeyFunc
+	Policy Policy
+	// ResetOnSuccess forgets the failures of the key once a request with it
+	// signs in, e.g. for the identity but not the IP address, which may be
+	// shared with attackers.
+	ResetOnSuccess bool
+}
+
+func (r Rule) key(i int, req *http.Request) string {
+	key := r.Key(req)
+	if key == "" {
+		return ""
+	}
+	name := r.Name
+	if name == "" {
+		name = strconv.Itoa(i)
+	}
+	return name + ":" + key
+}
+
+// Lockout describes a key locked out.
+type Lockout struct {
+	Rule    string
+	Key     string
+	Until   time.Time
+	Request *http.Request
+	// Provider is the provider of the failure locking out the key.
+	Provider string
+}
+
+// Guard records the failures of its rules, and refuses the requests locked
+// out.
+type Guard struct {
+	Store Store
+	Rules []Rule
+	// OnLockout is called when a key is locked out, e.g. to alert.
+	OnLockout func(lockout Lockout)
+	// ErrorHandler is called when the store fails. It defaults to logging the
+	// error. The requests are allowed when the store fails.
+	ErrorHandler func(req *http.Request, err error)
+}
+
+// New creates a guard of the rules keeping the failures in the store.
+func New(store Store, rules ...Rule) *Guard {
+	return &Guard{Store: store, Rules: rules}
+}
+
+// Register subscribes the guard to the failed and completed authentications
+// of gothic, and returns the function unsubscribing it.
+func (g *Guard) Register() (unsubscribe func()) {
+	failed := gothic.Subscribe(gothic.EventAuthFailed, func(event gothic.Event) {
+		g.Fail(event.Request, event.Provider)
+	})
+	completed := gothic.Subscribe(gothic.EventAuthCompleted, func(event gothic.Event) {
+		g.Succeed(event.Request)
+	})
+	return func() {
+		failed()
+		completed()
+	}
+}
+
+// Fail records a failure of the request with the provider.
+func (g *Guard) Fail(req *http.Request, provider string) {
+	if req == nil {
+		return
+	}
+	for i, rule := range g.Rules {
+		key := rule.key(i, req)
+		if key == "" {
+			continue
+		}
+		until, err := g.Store.Fail(key, rule.Policy)
+		if err != nil {
+			g.handleError(req, err)
+			continue
+		}
+		if !until.IsZero() && g.OnLockout != nil {
+			g.OnLockout(Lockout{Rule: rule.Name, Key: key, Until: until, Request: req, Provider: provider})
+		}
+	}
+}
+
+// Succeed forgets the failures of the rules reset on success.
+func (g *Guard) Succeed(req *http.Request) {
+	if req == nil {
+		return
+	}
+	for i, rule := range g.Rules {
+		if !rule.ResetOnSuccess {
+			continue
+		}
+		if key := rule.key(i, req); key != "" {
+			if err := g.Store.Reset(key); err != nil {
+				g.handleError(req, err)
+			}
+		}
+	}
+}
+
+// LockedUntil returns the end of the lockout of the request, or the zero time
+// when it isn't locked out.
+func (g *Guard) LockedUntil(req *http.Request) time.Time {
+	var lockedUntil time.Time
+	for i, rule := range g.Rules {
+		key := rule.key(i, req)
+		if key == "" {
+			continue
+		}
+		until, err := g.Store.LockedUntil(key)
+		if err != nil {
+			g.handleError(req, err)
+			continue
+		}
+		if until.After(lockedUntil) {
+			lockedUntil = until
+		}
+	}
+	return lockedUntil
+}
+
+// Handler refuses the requests locked out with a 429 response and a
+// Retry-After header.
+func (g *Guard) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if until := g.LockedUntil(req); !until.IsZero() {
+			seconds := int(math.Ceil(time.Until(until).Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			res.Header().Set("Retry-After", strconv.Itoa(seconds))
+			http.Error(res, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+func (g *Guard) handleError(req *http.Request, err error) {
+	if g.ErrorHandler != nil {
+		g.ErrorHandler(req, err)
+		return
+	}
+	log.Printf("lockout: %v", err)
+}
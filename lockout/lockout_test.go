@@ -0,0 +1,116 @@
+package lockout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	"github.com/andreimerlescu/goth/providers/faux"
+	"github.com/andreimerlescu/goth/ratelimit"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time { return c.t }
+
+func newStore() (*MemoryStore, *clock) {
+	c := &clock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := NewMemoryStore()
+	s.now = c.now
+	return s, c
+}
+
+var policy = Policy{MaxFailures: 3, Window: time.Minute, Cooldown: time.Minute, MaxCooldown: 3 * time.Minute}
+
+func Test_MemoryStore(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	s, c := newStore()
+	fail := func() time.Time {
+		until, err := s.Fail("ip:192.0.2.1", policy)
+		a.NoError(err)
+		return until
+	}
+
+	a.True(fail().IsZero())
+	a.True(fail().IsZero())
+	// the failures out of the window are forgotten
+	c.t = c.t.Add(time.Minute)
+	a.True(fail().IsZero())
+	a.True(fail().IsZero())
+	a.Equal(c.t.Add(time.Minute), fail())
+	until, _ := s.LockedUntil("ip:192.0.2.1")
+	a.Equal(c.t.Add(time.Minute), until)
+
+	// the lockouts in a row are longer, up to the maximum
+	for i, cooldown := range []time.Duration{2 * time.Minute, 3 * time.Minute} {
+		c.t = c.t.Add(policy.cooldown(i + 1))
+		until, _ = s.LockedUntil("ip:192.0.2.1")
+		a.True(until.IsZero())
+		fail()
+		fail()
+		a.Equal(c.t.Add(cooldown), fail())
+	}
+
+	a.NoError(s.Reset("ip:192.0.2.1"))
+	until, _ = s.LockedUntil("ip:192.0.2.1")
+	a.True(until.IsZero())
+}
+
+func Test_Guard(t *testing.T) {
+	a := assert.New(t)
+
+	s, _ := newStore()
+	guard := New(s,
+		Rule{Name: "ip", Key: ratelimit.ByIP, Policy: policy},
+		Rule{Name: "username", Key: ratelimit.ByFormValue("username"), Policy: Policy{MaxFailures: 2, Window: time.Minute, Cooldown: time.Minute}, ResetOnSuccess: true},
+	)
+	var lockouts []Lockout
+	guard.OnLockout = func(l Lockout) { lockouts = append(lockouts, l) }
+	defer guard.Register()()
+
+	store := gothic.Store
+	gothic.Store = sessions.NewCookieStore([]byte("secret"))
+	defer func() { gothic.Store = store }()
+	goth.UseProviders(&faux.Provider{})
+	callback := func(username string) *http.Request {
+		req := httptest.NewRequest("GET", "/auth/callback?provider=faux&username="+username, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		return req
+	}
+
+	// the provider session is missing
+	_, err := gothic.CompleteUserAuth(httptest.NewRecorder(), callback("homer"))
+	a.Error(err)
+	guard.Succeed(callback("homer"))
+	_, err = gothic.CompleteUserAuth(httptest.NewRecorder(), callback("homer"))
+	a.Error(err)
+	a.Empty(lockouts)
+	_, err = gothic.CompleteUserAuth(httptest.NewRecorder(), callback("homer"))
+	a.Error(err)
+
+	a.Len(lockouts, 2)
+	a.Equal("ip", lockouts[0].Rule)
+	a.Equal("ip:192.0.2.1", lockouts[0].Key)
+	a.Equal("faux", lockouts[0].Provider)
+	a.Equal("username:homer", lockouts[1].Key)
+
+	res := httptest.NewRecorder()
+	guard.Handler(http.NotFoundHandler()).ServeHTTP(res, callback("marge"))
+	a.Equal(http.StatusTooManyRequests, res.Code)
+	a.NotEmpty(res.Header().Get("Retry-After"))
+
+	other := httptest.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	other.RemoteAddr = "198.51.100.1:1234"
+	res = httptest.NewRecorder()
+	guard.Handler(http.NotFoundHandler()).ServeHTTP(res, other)
+	a.Equal(http.StatusNotFound, res.Code)
+}
@@ -0,0 +1,103 @@
+package lockout
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the failures in memory, for an application running a
+// single instance.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type entry struct {
+	failures []time.Time
+	// lockouts is the number of lockouts in a row
+	lockouts    int
+	lockedUntil time.Time
+	// forget is when the entry can be forgotten
+	forget time.Time
+}
+
+// NewMemoryStore creates an empty memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: map[string]*entry{},
+		now:     time.Now,
+	}
+}
+
+// Fail records a failure of the key.
+func (s *MemoryStore) Fail(key string, policy Policy) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = &entry{}
+		s.entries[key] = e
+	}
+	if now.Before(e.lockedUntil) {
+		return time.Time{}, nil
+	}
+
+	failures := e.failures[:0]
+	for _, t := range e.failures {
+		if now.Sub(t) < policy.Window {
+			failures = append(failures, t)
+		}
+	}
+	e.failures = append(failures, now)
+	e.forget = now.Add(policy.Window)
+	if len(e.failures) < policy.MaxFailures {
+		return time.Time{}, nil
+	}
+
+	// a lockout right after the previous one is in a row
+	if e.lockedUntil.IsZero() || now.Sub(e.lockedUntil) > policy.Window {
+		e.lockouts = 0
+	}
+	e.lockouts++
+	e.failures = nil
+	e.lockedUntil = now.Add(policy.cooldown(e.lockouts))
+	e.forget = e.lockedUntil.Add(policy.Window)
+	return e.lockedUntil, nil
+}
+
+// LockedUntil returns the end of the lockout of the key.
+func (s *MemoryStore) LockedUntil(key string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || !s.now().Before(e.lockedUntil) {
+		return time.Time{}, nil
+	}
+	return e.lockedUntil, nil
+}
+
+// Reset forgets the key.
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep forgets the entries of old failures, at most once a minute.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, e := range s.entries {
+		if now.After(e.forget) {
+			delete(s.entries, key)
+		}
+	}
+}
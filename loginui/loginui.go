@@ -0,0 +1,265 @@
+/*
+Package loginui renders the login buttons of the registered providers and the
+errors of the logins, so the server-rendered applications, e.g. with
+html/template, templ or HTMX, build their login pages from goth.ProviderNames
+instead of maintaining the list of their providers:
+
+	buttons := loginui.Buttons(loginui.Options{ReturnTo: "/dashboard"})
+	err := loginui.RenderButtons(res, buttons)
+
+Each Button has the name and the title of its provider, the URL starting its
+authentication, and the icon of the Options.Icon slot. The templ components
+range over the buttons themselves:
+
+	templ Login(buttons []loginui.Button) {
+		for _, b := range buttons {
+			<a class="goth-login" href={ templ.SafeURL(b.URL) }>
+				@templ.Raw(string(b.Icon))
+				{ b.Title }
+			</a>
+		}
+	}
+
+WriteError renders the errors of the handlers of gothic as HTML partials, for
+gothic.ErrorHandler.
+*/
+package loginui
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+)
+
+// Titles are the titles of the buttons of the providers, by their name. The
+// providers without a title are shown by their name.
+var Titles = map[string]string{
+	"amazon":          "Amazon",
+	"apple":           "Apple",
+	"atlassian":       "Atlassian",
+	"auth0":           "Auth0",
+	"authentik":       "authentik",
+	"azuread":         "Azure AD",
+	"battlenet":       "Battle.net",
+	"bitbucket":       "Bitbucket",
+	"box":             "Box",
+	"dailymotion":     "Dailymotion",
+	"deezer":          "Deezer",
+	"dex":             "Dex",
+	"digitalocean":    "Digital Ocean",
+	"discord":         "Discord",
+	"dropbox":         "Dropbox",
+	"ebay":            "eBay",
+	"etsy":            "Etsy",
+	"eveonline":       "Eve Online",
+	"facebook":        "Facebook",
+	"figma":           "Figma",
+	"fitbit":          "Fitbit",
+	"gitea":           "Gitea",
+	"github":          "GitHub",
+	"gitlab":          "GitLab",
+	"google":          "Google",
+	"gplus":           "Google Plus",
+	"heroku":          "Heroku",
+	"instagram":       "Instagram",
+	"intercom":        "Intercom",
+	"intuit":          "Intuit",
+	"kakao":           "Kakao",
+	"lastfm":          "Last FM",
+	"line":            "LINE",
+	"linkedin":        "LinkedIn",
+	"mastodon":        "Mastodon",
+	"meetup":          "Meetup.com",
+	"microsoftonline": "Microsoft Online",
+	"naver":           "Naver",
+	"nextcloud":       "NextCloud",
+	"notion":          "Notion",
+	"okta":            "Okta",
+	"onedrive":        "OneDrive",
+	"openid-connect":  "OpenID Connect",
+	"patreon":         "Patreon",
+	"paypal":          "PayPal",
+	"plex":            "Plex",
+	"roblox":          "Roblox",
+	"salesforce":      "Salesforce",
+	"seatalk":         "SeaTalk",
+	"shopify":         "Shopify",
+	"slack":           "Slack",
+	"soundcloud":      "SoundCloud",
+	"spotify":         "Spotify",
+	"steam":           "Steam",
+	"strava":          "Strava",
+	"stripe":          "Stripe",
+	"tiktok":          "TikTok",
+	"twitch":          "Twitch",
+	"twitter":         "Twitter",
+	"twitterv2":       "Twitter",
+	"typetalk":        "Typetalk",
+	"uber":            "Uber",
+	"vk":              "VK",
+	"wecom":           "WeCom",
+	"wepay":           "WePay",
+	"wordpress":       "WordPress.com",
+	"xero":            "Xero",
+	"xerov2":          "Xero",
+	"yahoo":           "Yahoo",
+	"yammer":          "Yammer",
+	"yandex":          "Yandex",
+	"zoom":            "Zoom",
+}
+
+// Button is the login button of a provider.
+type Button struct {
+	// Name is the name of the provider, e.g. github.
+	Name string
+	// Title is the title of the provider, e.g. GitHub, see Titles.
+	Title string
+	// URL is the URL starting the authentication with the provider.
+	URL string
+	// Icon is the icon of the provider, e.g. an <img> or an <svg>, when
+	// Options.Icon is set.
+	Icon template.HTML
+}
+
+// Options are the options of Buttons.
+type Options struct {
+	// BeginURL returns the URL starting the authentication with the
+	// provider, which calls gothic.BeginAuthHandler. It is /auth/{provider}
+	// by default.
+	BeginURL func(provider string) string
+	// ReturnTo is the destination of the users once authenticated, added to
+	// the URLs as the gothic.ReturnToParam when it is set.
+	ReturnTo string
+	// Icon returns the icon of the provider, none by default. The icons are
+	// trusted HTML.
+	Icon func(provider string) template.HTML
+	// Include reports the providers shown, all of them by default, e.g. to
+	// hide the providers of the APIs.
+	Include func(provider string) bool
+}
+
+// Buttons returns the buttons of the registered providers, sorted by title,
+// including the providers of goth.UseProviderFactory which aren't constructed
+// yet.
+func Buttons(opts Options) []Button {
+	var buttons []Button
+	for _, name := range goth.ProviderNames() {
+		if opts.Include != nil && !opts.Include(name) {
+			continue
+		}
+		b := Button{Name: name, Title: Titles[name], URL: beginURL(opts, name)}
+		if b.Title == "" {
+			b.Title = name
+		}
+		if opts.Icon != nil {
+			b.Icon = opts.Icon(name)
+		}
+		buttons = append(buttons, b)
+	}
+	sort.Slice(buttons, func(i, j int) bool {
+		if buttons[i].Title != buttons[j].Title {
+			return strings.ToLower(buttons[i].Title) < strings.ToLower(buttons[j].Title)
+		}
+		return buttons[i].Name < buttons[j].Name
+	})
+	return buttons
+}
+
+func beginURL(opts Options, provider string) string {
+	var u string
+	if opts.BeginURL != nil {
+		u = opts.BeginURL(provider)
+	} else {
+		u = "/auth/" + url.PathEscape(provider)
+	}
+	if opts.ReturnTo == "" {
+		return u
+	}
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	return u + sep + url.QueryEscape(gothic.ReturnToParam) + "=" + url.QueryEscape(opts.ReturnTo)
+}
+
+// Templates are the templates of RenderButtons and WriteError, which can be
+// replaced, e.g. with the ones of the application, or be used in its own
+// templates with {{template "goth_login_buttons" .}}:
+//
+//   - goth_login_buttons renders the []Button;
+//   - goth_login_error renders an ErrorPartial.
+var Templates = template.Must(template.New("loginui").Parse(`
+{{- define "goth_login_buttons" -}}
+<ul class="goth-login-buttons">
+{{- range . }}
+<li><a class="goth-login goth-login-{{ .Name }}" href="{{ .URL }}">{{ with .Icon }}<span class="goth-login-icon" aria-hidden="true">{{ . }}</span>{{ end }}Sign in with {{ .Title }}</a></li>
+{{- end }}
+</ul>
+{{- end -}}
+{{- define "goth_login_error" -}}
+<div class="goth-login-error" role="alert" data-error="{{ .Code }}">{{ .Message }}</div>
+{{- end -}}
+`))
+
+// RenderButtons renders the buttons with the goth_login_buttons template.
+func RenderButtons(w io.Writer, buttons []Button) error {
+	return Templates.ExecuteTemplate(w, "goth_login_buttons", buttons)
+}
+
+// ErrorPartial is the data of the goth_login_error template.
+type ErrorPartial struct {
+	// Code is the code of the error, see gothic.ErrorCode.
+	Code   string
+	Status int
+	// Message is the message of the error.
+	Message string
+}
+
+// RenderError renders the error with the goth_login_error template.
+func RenderError(w io.Writer, status int, err error) error {
+	return Templates.ExecuteTemplate(w, "goth_login_error", ErrorPartial{
+		Code:    gothic.ErrorCode(err, status),
+		Status:  status,
+		Message: err.Error(),
+	})
+}
+
+/*
+WriteError writes the error as an HTML partial to the HTMX requests and to the
+clients preferring HTML, and with gothic.WriteError otherwise, e.g. as JSON:
+
+	gothic.ErrorHandler = loginui.WriteError
+
+As HTMX doesn't swap the responses of the errors by default, the partials are
+written to the HTMX requests with the 200 status code, and the status in the
+X-Goth-Status header.
+*/
+func WriteError(res http.ResponseWriter, req *http.Request, status int, err error) {
+	htmx := req.Header.Get("HX-Request") == "true"
+	if !htmx && !prefersHTML(req) {
+		gothic.WriteError(res, req, status, err)
+		return
+	}
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+	if htmx {
+		res.Header().Set("X-Goth-Status", strconv.Itoa(status))
+		res.WriteHeader(http.StatusOK)
+	} else {
+		res.WriteHeader(status)
+	}
+	_ = RenderError(res, status, err)
+}
+
+// prefersHTML reports whether the Accept header of the request names HTML,
+// as the browsers do.
+func prefersHTML(req *http.Request) bool {
+	return strings.Contains(req.Header.Get("Accept"), "text/html")
+}
@@ -0,0 +1,82 @@
+package loginui_test
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	"github.com/andreimerlescu/goth/loginui"
+	"github.com/andreimerlescu/goth/providers/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Buttons(t *testing.T) {
+	a := assert.New(t)
+	goth.ClearProviders()
+	defer goth.ClearProviders()
+	github, gitlab, custom := mock.New(), mock.New(), mock.New()
+	github.SetName("github")
+	gitlab.SetName("gitlab")
+	custom.SetName("acme sso")
+	goth.UseProviders(gitlab, custom, github)
+	goth.UseProviderFactory("okta", func() (goth.Provider, error) {
+		t.Error("the providers of the factories aren't constructed for their buttons")
+		return mock.New(), nil
+	})
+
+	buttons := loginui.Buttons(loginui.Options{ReturnTo: "/dashboard?tab=1"})
+	a.Equal([]loginui.Button{
+		{Name: "acme sso", Title: "acme sso", URL: "/auth/acme%20sso?returnTo=%2Fdashboard%3Ftab%3D1"},
+		{Name: "github", Title: "GitHub", URL: "/auth/github?returnTo=%2Fdashboard%3Ftab%3D1"},
+		{Name: "gitlab", Title: "GitLab", URL: "/auth/gitlab?returnTo=%2Fdashboard%3Ftab%3D1"},
+		{Name: "okta", Title: "Okta", URL: "/auth/okta?returnTo=%2Fdashboard%3Ftab%3D1"},
+	}, buttons)
+
+	buttons = loginui.Buttons(loginui.Options{
+		BeginURL: func(provider string) string { return "/login?provider=" + provider },
+		Icon: func(provider string) template.HTML {
+			return template.HTML(`<img src="/icons/` + provider + `.svg">`)
+		},
+		Include: func(provider string) bool { return provider == "github" },
+	})
+	a.Equal([]loginui.Button{
+		{Name: "github", Title: "GitHub", URL: "/login?provider=github", Icon: `<img src="/icons/github.svg">`},
+	}, buttons)
+
+	var b bytes.Buffer
+	a.NoError(loginui.RenderButtons(&b, append(buttons, loginui.Button{Name: "x", Title: "<X>", URL: "javascript:alert(1)"})))
+	a.Contains(b.String(), `<a class="goth-login goth-login-github" href="/login?provider=github"><span class="goth-login-icon" aria-hidden="true"><img src="/icons/github.svg"></span>Sign in with GitHub</a>`)
+	// the titles and the URLs are escaped
+	a.Contains(b.String(), `href="#ZgotmplZ">Sign in with &lt;X&gt;</a>`)
+}
+
+func Test_WriteError(t *testing.T) {
+	a := assert.New(t)
+
+	req := httptest.NewRequest("GET", "/auth/callback", nil)
+	req.Header.Set("HX-Request", "true")
+	res := httptest.NewRecorder()
+	loginui.WriteError(res, req, http.StatusBadRequest, gothic.ErrStateTokenMismatch)
+	a.Equal(http.StatusOK, res.Code)
+	a.Equal("400", res.Header().Get("X-Goth-Status"))
+	a.Equal(`<div class="goth-login-error" role="alert" data-error="state_mismatch">state token mismatch</div>`, res.Body.String())
+
+	req = httptest.NewRequest("GET", "/auth/callback", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	res = httptest.NewRecorder()
+	loginui.WriteError(res, req, http.StatusInternalServerError, errors.New("<script>"))
+	a.Equal(http.StatusInternalServerError, res.Code)
+	a.Equal("text/html; charset=utf-8", res.Header().Get("Content-Type"))
+	a.Contains(res.Body.String(), `data-error="internal_server_error">&lt;script&gt;</div>`)
+
+	// the other clients get the errors of gothic
+	res = httptest.NewRecorder()
+	loginui.WriteError(res, httptest.NewRequest("GET", "/auth/callback", nil), http.StatusBadRequest, gothic.ErrProviderRequired)
+	a.Equal(http.StatusBadRequest, res.Code)
+	a.Equal("you must select a provider\n", res.Body.String())
+}
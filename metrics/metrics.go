@@ -0,0 +1,179 @@
+// Package metrics records Prometheus metrics of the authentication flows of
+// gothic: the operations by provider, their outcome and the class of their
+// errors, their latency, the refreshes of tokens and the operations of the
+// session store.
+//
+//	m, err := metrics.New(prometheus.DefaultRegisterer)
+//	...
+//	gothic.Observers = append(gothic.Observers, m.Observe)
+//	gothic.Store = m.InstrumentStore(gothic.Store)
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	"github.com/gorilla/sessions"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/oauth2"
+)
+
+// Namespace is the namespace of the metrics.
+var Namespace = "goth"
+
+// Metrics holds the collectors of the metrics.
+type Metrics struct {
+	operations        *prometheus.CounterVec
+	operationDuration *prometheus.HistogramVec
+	refreshes         *prometheus.CounterVec
+	refreshDuration   *prometheus.HistogramVec
+	store             *prometheus.CounterVec
+	storeDuration     *prometheus.HistogramVec
+}
+
+// New creates the collectors of the metrics, and registers them with the
+// registerer.
+func New(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "auth_operations_total",
+			Help:      "Authentication operations of gothic, by operation, provider, result and error class.",
+		}, []string{"operation", "provider", "result", "error_class"}),
+		operationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "auth_operation_duration_seconds",
+			Help:      "Latency of the authentication operations of gothic, by operation and provider.",
+			Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"operation", "provider"}),
+		refreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "token_refreshes_total",
+			Help:      "Token refreshes, by provider, result and error class.",
+		}, []string{"provider", "result", "error_class"}),
+		refreshDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "token_refresh_duration_seconds",
+			Help:      "Latency of the token refreshes, by provider.",
+			Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"provider"}),
+		store: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "session_store_operations_total",
+			Help:      "Operations of the session store, by operation and result.",
+		}, []string{"operation", "result"}),
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "session_store_operation_duration_seconds",
+			Help:      "Latency of the operations of the session store, by operation.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25},
+		}, []string{"operation"}),
+	}
+
+	for _, c := range []prometheus.Collector{m.operations, m.operationDuration, m.refreshes, m.refreshDuration, m.store, m.storeDuration} {
+		err := registerer.Register(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Observe records the operation, it is a gothic.Observer.
+func (m *Metrics) Observe(op gothic.Operation) {
+	provider := op.Provider
+	if provider == "" {
+		provider = "unknown"
+	}
+	m.operations.WithLabelValues(op.Name, provider, result(op.Err), ErrorClass(op.Err)).Inc()
+	m.operationDuration.WithLabelValues(op.Name, provider).Observe(op.Duration.Seconds())
+}
+
+// RefreshToken refreshes the token with the provider, and records it.
+func (m *Metrics) RefreshToken(provider goth.Provider, refreshToken string) (*oauth2.Token, error) {
+	start := time.Now()
+	token, err := provider.RefreshToken(refreshToken)
+	m.refreshes.WithLabelValues(provider.Name(), result(err), ErrorClass(err)).Inc()
+	m.refreshDuration.WithLabelValues(provider.Name()).Observe(time.Since(start).Seconds())
+	return token, err
+}
+
+// InstrumentStore returns the store recording its operations.
+func (m *Metrics) InstrumentStore(store sessions.Store) sessions.Store {
+	return &instrumentedStore{Store: store, m: m}
+}
+
+type instrumentedStore struct {
+	sessions.Store
+	m *Metrics
+}
+
+func (s *instrumentedStore) observe(operation string, start time.Time, err error) {
+	s.m.store.WithLabelValues(operation, result(err)).Inc()
+	s.m.storeDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func (s *instrumentedStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	start := time.Now()
+	session, err := s.Store.Get(r, name)
+	s.observe("get", start, err)
+	return session, err
+}
+
+func (s *instrumentedStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	start := time.Now()
+	session, err := s.Store.New(r, name)
+	s.observe("new", start, err)
+	return session, err
+}
+
+func (s *instrumentedStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	start := time.Now()
+	err := s.Store.Save(r, w, session)
+	s.observe("save", start, err)
+	return err
+}
+
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// ErrorClass returns the class of the error recorded in the error_class label,
+// which is empty without an error.
+func ErrorClass(err error) string {
+	var retrieveErr *oauth2.RetrieveError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, gothic.ErrStateTokenMismatch):
+		return "state_mismatch"
+	case errors.Is(err, gothic.ErrSessionNotFound):
+		return "session_not_found"
+	case errors.Is(err, gothic.ErrProviderRequired):
+		return "provider_required"
+	case errors.Is(err, gothic.ErrSecondFactorRequired):
+		return "second_factor_required"
+	case errors.Is(err, gothic.ErrSecondFactorFailed), errors.Is(err, gothic.ErrSecondFactorExpired):
+		return "second_factor_failed"
+	case errors.Is(err, gothic.ErrPanic):
+		return "panic"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, gothic.ErrContextTimeout):
+		return "timeout"
+	case errors.Is(err, context.Canceled), errors.Is(err, gothic.ErrContextCanceled):
+		return "canceled"
+	case errors.As(err, &retrieveErr):
+		return "token_exchange"
+	case errors.As(err, &netErr):
+		return "network"
+	}
+	return "other"
+}
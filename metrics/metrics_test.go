@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth/gothic"
+	"github.com/andreimerlescu/goth/providers/faux"
+	"github.com/gorilla/sessions"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// gather returns the values of the counters and the sample counts of the
+// histograms, by metric name and labels.
+func gather(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]float64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			key := family.GetName() + labels(metric.GetLabel())
+			switch {
+			case metric.Counter != nil:
+				values[key] = metric.GetCounter().GetValue()
+			case metric.Histogram != nil:
+				values[key] = float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return values
+}
+
+func labels(pairs []*dto.LabelPair) string {
+	s := ""
+	for _, pair := range pairs {
+		s += fmt.Sprintf("{%s=%s}", pair.GetName(), pair.GetValue())
+	}
+	return s
+}
+
+func Test_Observe(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	registry := prometheus.NewRegistry()
+	m, err := New(registry)
+	a.NoError(err)
+	_, err = New(registry)
+	a.Error(err)
+
+	m.Observe(gothic.Operation{Name: gothic.OperationBeginAuth, Provider: "github", Duration: time.Millisecond})
+	m.Observe(gothic.Operation{Name: gothic.OperationCompleteUserAuth, Provider: "github", Duration: time.Second, Err: gothic.ErrStateTokenMismatch})
+	m.Observe(gothic.Operation{Name: gothic.OperationCompleteUserAuth, Duration: time.Second, Err: gothic.ErrProviderRequired})
+
+	values := gather(t, registry)
+	a.Equal(1.0, values["goth_auth_operations_total{error_class=}{operation=begin_auth}{provider=github}{result=success}"])
+	a.Equal(1.0, values["goth_auth_operations_total{error_class=state_mismatch}{operation=complete_user_auth}{provider=github}{result=failure}"])
+	a.Equal(1.0, values["goth_auth_operations_total{error_class=provider_required}{operation=complete_user_auth}{provider=unknown}{result=failure}"])
+	a.Equal(1.0, values["goth_auth_operation_duration_seconds{operation=complete_user_auth}{provider=github}"])
+}
+
+type refreshProvider struct {
+	*faux.Provider
+	err error
+}
+
+func (p refreshProvider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: "new"}, p.err
+}
+
+func Test_RefreshToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	registry := prometheus.NewRegistry()
+	m, err := New(registry)
+	a.NoError(err)
+
+	token, err := m.RefreshToken(refreshProvider{Provider: &faux.Provider{}}, "refresh")
+	a.NoError(err)
+	a.Equal("new", token.AccessToken)
+	_, err = m.RefreshToken(refreshProvider{Provider: &faux.Provider{}, err: &oauth2.RetrieveError{}}, "refresh")
+	a.Error(err)
+
+	values := gather(t, registry)
+	a.Equal(1.0, values["goth_token_refreshes_total{error_class=}{provider=faux}{result=success}"])
+	a.Equal(1.0, values["goth_token_refreshes_total{error_class=token_exchange}{provider=faux}{result=failure}"])
+	a.Equal(2.0, values["goth_token_refresh_duration_seconds{provider=faux}"])
+}
+
+func Test_InstrumentStore(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	registry := prometheus.NewRegistry()
+	m, err := New(registry)
+	a.NoError(err)
+
+	store := m.InstrumentStore(sessions.NewCookieStore([]byte("secret")))
+	req := httptest.NewRequest("GET", "/", nil)
+	session, err := store.Get(req, "session")
+	a.NoError(err)
+	session.Values["key"] = "value"
+	a.NoError(store.Save(req, httptest.NewRecorder(), session))
+
+	invalid := httptest.NewRequest("GET", "/", nil)
+	invalid.AddCookie(&http.Cookie{Name: "session", Value: "invalid"})
+	_, err = store.New(invalid, "session")
+	a.Error(err)
+
+	values := gather(t, registry)
+	a.Equal(1.0, values["goth_session_store_operations_total{operation=get}{result=success}"])
+	a.Equal(1.0, values["goth_session_store_operations_total{operation=save}{result=success}"])
+	a.Equal(1.0, values["goth_session_store_operations_total{operation=new}{result=failure}"])
+}
+
+func Test_ErrorClass(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Equal("", ErrorClass(nil))
+	a.Equal("second_factor_failed", ErrorClass(fmt.Errorf("%w: invalid code", gothic.ErrSecondFactorFailed)))
+	a.Equal("session_not_found", ErrorClass(gothic.ErrSessionNotFound))
+	a.Equal("other", ErrorClass(errors.New("failure")))
+}
@@ -0,0 +1,384 @@
+// Package oidfed is an experimental implementation of OpenID Federation 1.0,
+// resolving the trust chain of an OpenID provider up to a trust anchor of a
+// federation, e.g. a national or academic one, to derive the metadata of the
+// provider from the statements of the federation instead of trusting its
+// discovery document:
+//
+//	resolver := oidfed.NewResolver(oidfed.TrustAnchor{EntityID: "https://federation.example.org", Keys: keys})
+//	chain, err := resolver.Resolve("https://op.example.edu")
+//	...
+//	provider, err := oidfed.NewProvider(chain, clientKey, secret, callbackURL)
+//
+// Only the resolution of trust chains is supported: the client is still
+// registered with the provider beforehand, as automatic and explicit client
+// registration are not implemented.
+package oidfed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// The entity types of the metadata of entity statements.
+const (
+	OpenIDProvider      = "openid_provider"
+	OpenIDRelyingParty  = "openid_relying_party"
+	FederationEntity    = "federation_entity"
+	EntityStatementType = "entity-statement+jwt"
+)
+
+// ConfigurationPath is where entities publish their entity configuration.
+const ConfigurationPath = "/.well-known/openid-federation"
+
+// ErrNoTrustChain is returned when no trust anchor could be reached from the
+// entity.
+var ErrNoTrustChain = errors.New("oidfed: no trust chain to a trust anchor")
+
+var algorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// EntityStatement is a statement of an entity about itself, its entity
+// configuration, or about one of its subordinates.
+type EntityStatement struct {
+	Issuer         string
+	Subject        string
+	IssuedAt       time.Time
+	ExpiresAt      time.Time
+	Keys           jwk.Set
+	AuthorityHints []string
+	// Metadata is the metadata of the subject by entity type.
+	Metadata map[string]map[string]interface{}
+	// MetadataPolicy is the policy of the metadata of the subordinates, by
+	// entity type and parameter.
+	MetadataPolicy map[string]map[string]map[string]interface{}
+	// Raw is the signed JWT of the statement.
+	Raw string
+}
+
+// TrustAnchor is a trust anchor of a federation. Its keys are distributed out
+// of band, and are fetched with its entity configuration when empty.
+type TrustAnchor struct {
+	EntityID string
+	Keys     jwk.Set
+}
+
+// TrustChain is the chain of statements from an entity to a trust anchor: the
+// entity configuration of the entity, the statements of its superiors about
+// their subordinates, and the entity configuration of the trust anchor.
+type TrustChain struct {
+	Statements  []*EntityStatement
+	TrustAnchor string
+	// ExpiresAt is when the first statement of the chain expires.
+	ExpiresAt time.Time
+}
+
+// Entity returns the entity ID of the subject of the chain.
+func (c *TrustChain) Entity() string {
+	return c.Statements[0].Subject
+}
+
+// Metadata returns the metadata of the entity type, e.g. OpenIDProvider, with
+// the metadata and metadata policies of its superiors applied.
+func (c *TrustChain) Metadata(entityType string) (map[string]interface{}, error) {
+	metadata := map[string]interface{}{}
+	for k, v := range c.Statements[0].Metadata[entityType] {
+		metadata[k] = v
+	}
+	if len(c.Statements) > 2 {
+		// the immediate superior may override the metadata
+		for k, v := range c.Statements[1].Metadata[entityType] {
+			metadata[k] = v
+		}
+	}
+
+	policy := map[string]map[string]interface{}{}
+	for i := len(c.Statements) - 2; i > 0; i-- {
+		for parameter, operators := range c.Statements[i].MetadataPolicy[entityType] {
+			merged, err := mergePolicy(policy[parameter], operators)
+			if err != nil {
+				return nil, fmt.Errorf("oidfed: metadata policy of %s from %s: %w", parameter, c.Statements[i].Issuer, err)
+			}
+			policy[parameter] = merged
+		}
+	}
+	for parameter, operators := range policy {
+		err := applyPolicy(metadata, parameter, operators)
+		if err != nil {
+			return nil, fmt.Errorf("oidfed: metadata policy of %s: %w", parameter, err)
+		}
+	}
+	return metadata, nil
+}
+
+// Resolver resolves the trust chains of entities to its trust anchors.
+type Resolver struct {
+	TrustAnchors []TrustAnchor
+	// MaxPathLength is the number of intermediates allowed between an
+	// entity and a trust anchor.
+	MaxPathLength int
+	HTTPClient    *http.Client
+	now           func() time.Time
+}
+
+// NewResolver creates a resolver of the trust chains to the trust anchors.
+func NewResolver(trustAnchors ...TrustAnchor) *Resolver {
+	return &Resolver{
+		TrustAnchors:  trustAnchors,
+		MaxPathLength: 5,
+	}
+}
+
+func (r *Resolver) clock() time.Time {
+	if r.now == nil {
+		return time.Now()
+	}
+	return r.now()
+}
+
+// Resolve returns the first trust chain found from the entity to a trust
+// anchor, following its authority hints.
+func (r *Resolver) Resolve(entityID string) (*TrustChain, error) {
+	ec, err := r.EntityConfiguration(entityID)
+	if err != nil {
+		return nil, err
+	}
+	statements, anchor, err := r.resolve(ec, 0, map[string]bool{entityID: true})
+	if err != nil {
+		return nil, err
+	}
+
+	chain := &TrustChain{Statements: statements, TrustAnchor: anchor}
+	for _, s := range statements {
+		if chain.ExpiresAt.IsZero() || s.ExpiresAt.Before(chain.ExpiresAt) {
+			chain.ExpiresAt = s.ExpiresAt
+		}
+	}
+	return chain, nil
+}
+
+// resolve returns the chain from the entity configuration, whose signature
+// with its own keys has been verified, to a trust anchor.
+func (r *Resolver) resolve(ec *EntityStatement, depth int, visited map[string]bool) ([]*EntityStatement, string, error) {
+	for _, anchor := range r.TrustAnchors {
+		if anchor.EntityID != ec.Subject {
+			continue
+		}
+		if anchor.Keys != nil {
+			// the configuration has to be signed by the keys of the anchor
+			_, err := r.parse(ec.Raw, anchor.Keys)
+			if err != nil {
+				return nil, "", err
+			}
+		}
+		return []*EntityStatement{ec}, anchor.EntityID, nil
+	}
+	if depth > r.MaxPathLength {
+		return nil, "", ErrNoTrustChain
+	}
+
+	var errs []string
+	for _, hint := range ec.AuthorityHints {
+		if visited[hint] {
+			continue
+		}
+		visited[hint] = true
+		statements, anchor, err := r.resolveThrough(ec, hint, depth, visited)
+		if err == nil {
+			return statements, anchor, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return nil, "", fmt.Errorf("%w: %s", ErrNoTrustChain, strings.Join(errs, "; "))
+	}
+	return nil, "", ErrNoTrustChain
+}
+
+func (r *Resolver) resolveThrough(ec *EntityStatement, superior string, depth int, visited map[string]bool) ([]*EntityStatement, string, error) {
+	superiorEC, err := r.EntityConfiguration(superior)
+	if err != nil {
+		return nil, "", err
+	}
+	statement, err := r.SubordinateStatement(superiorEC, ec.Subject)
+	if err != nil {
+		return nil, "", err
+	}
+	// the keys of the entity are the ones its superior states
+	_, err = r.parse(ec.Raw, statement.Keys)
+	if err != nil {
+		return nil, "", err
+	}
+
+	upper, anchor, err := r.resolve(superiorEC, depth+1, visited)
+	if err != nil {
+		return nil, "", err
+	}
+	statements := []*EntityStatement{ec, statement}
+	if len(upper) == 1 {
+		// the superior is the trust anchor, whose configuration ends the chain
+		return append(statements, upper...), anchor, nil
+	}
+	// the configurations of the intermediates are not part of the chain
+	return append(statements, upper[1:]...), anchor, nil
+}
+
+// EntityConfiguration fetches the entity configuration of the entity, and
+// verifies it is signed by the keys it contains.
+func (r *Resolver) EntityConfiguration(entityID string) (*EntityStatement, error) {
+	raw, err := r.fetch(strings.TrimSuffix(entityID, "/") + ConfigurationPath)
+	if err != nil {
+		return nil, err
+	}
+	unverified, err := decodeStatement(raw)
+	if err != nil {
+		return nil, err
+	}
+	ec, err := r.parse(raw, unverified.Keys)
+	if err != nil {
+		return nil, err
+	}
+	if ec.Issuer != entityID || ec.Subject != entityID {
+		return nil, fmt.Errorf("oidfed: the entity configuration of %s is issued by %s about %s", entityID, ec.Issuer, ec.Subject)
+	}
+	return ec, nil
+}
+
+// SubordinateStatement fetches the statement of the superior about the
+// subordinate from its fetch endpoint.
+func (r *Resolver) SubordinateStatement(superior *EntityStatement, subordinate string) (*EntityStatement, error) {
+	endpoint, _ := superior.Metadata[FederationEntity]["federation_fetch_endpoint"].(string)
+	if endpoint == "" {
+		return nil, fmt.Errorf("oidfed: %s has no fetch endpoint", superior.Subject)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	query.Set("sub", subordinate)
+	u.RawQuery = query.Encode()
+
+	raw, err := r.fetch(u.String())
+	if err != nil {
+		return nil, err
+	}
+	statement, err := r.parse(raw, superior.Keys)
+	if err != nil {
+		return nil, err
+	}
+	if statement.Issuer != superior.Subject || statement.Subject != subordinate {
+		return nil, fmt.Errorf("oidfed: the statement fetched from %s is issued by %s about %s", superior.Subject, statement.Issuer, statement.Subject)
+	}
+	return statement, nil
+}
+
+func (r *Resolver) fetch(u string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/"+EntityStatementType)
+
+	resp, err := goth.HTTPClientWithFallBack(r.HTTPClient).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oidfed: %s responded with a %d", u, resp.StatusCode)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// parse verifies the signature of the statement with the keys, and its times.
+func (r *Resolver) parse(raw string, keys jwk.Set) (*EntityStatement, error) {
+	if keys == nil || keys.Len() == 0 {
+		return nil, errors.New("oidfed: no keys to verify the entity statement")
+	}
+	_, err := jwt.Parse(raw, func(t *jwt.Token) (interface{}, error) {
+		if typ, _ := t.Header["typ"].(string); typ != EntityStatementType {
+			return nil, fmt.Errorf("oidfed: unexpected type %q of entity statement", typ)
+		}
+		kid, _ := t.Header["kid"].(string)
+		key, ok := keys.LookupKeyID(kid)
+		if !ok {
+			return nil, fmt.Errorf("oidfed: unknown key %q", kid)
+		}
+		var raw interface{}
+		err := key.Raw(&raw)
+		if err != nil {
+			return nil, err
+		}
+		return raw, nil
+	}, jwt.WithValidMethods(algorithms), jwt.WithExpirationRequired(), jwt.WithIssuedAt(), jwt.WithTimeFunc(r.clock))
+	if err != nil {
+		return nil, fmt.Errorf("oidfed: invalid entity statement: %w", err)
+	}
+	return decodeStatement(raw)
+}
+
+// decodeStatement decodes the claims of the statement, without verifying it.
+func decodeStatement(raw string) (*EntityStatement, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oidfed: the entity statement is not a JWT")
+	}
+	payload, err := jwt.NewParser().DecodeSegment(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	var claims struct {
+		Issuer         string                                       `json:"iss"`
+		Subject        string                                       `json:"sub"`
+		IssuedAt       int64                                        `json:"iat"`
+		ExpiresAt      int64                                        `json:"exp"`
+		Keys           json.RawMessage                              `json:"jwks"`
+		AuthorityHints []string                                     `json:"authority_hints"`
+		Metadata       map[string]map[string]interface{}            `json:"metadata"`
+		MetadataPolicy map[string]map[string]map[string]interface{} `json:"metadata_policy"`
+	}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &EntityStatement{
+		Issuer:         claims.Issuer,
+		Subject:        claims.Subject,
+		IssuedAt:       time.Unix(claims.IssuedAt, 0),
+		ExpiresAt:      time.Unix(claims.ExpiresAt, 0),
+		AuthorityHints: claims.AuthorityHints,
+		Metadata:       claims.Metadata,
+		MetadataPolicy: claims.MetadataPolicy,
+		Raw:            raw,
+	}
+	if len(claims.Keys) > 0 {
+		s.Keys, err = jwk.Parse(claims.Keys)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// FetchKeys fetches the JSON Web Key Set of a trust anchor, for the
+// federations publishing it at a URL.
+func FetchKeys(client *http.Client, u string) (jwk.Set, error) {
+	return jwk.Fetch(context.Background(), u, jwk.WithHTTPClient(goth.HTTPClientWithFallBack(client)))
+}
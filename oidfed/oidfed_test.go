@@ -0,0 +1,231 @@
+package oidfed
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/stretchr/testify/assert"
+)
+
+type entity struct {
+	id  string
+	key *ecdsa.PrivateKey
+	kid string
+}
+
+func newEntity(t *testing.T, id, kid string) *entity {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &entity{id: id, key: key, kid: kid}
+}
+
+func (e *entity) keys() jwk.Set {
+	key, _ := jwk.New(&e.key.PublicKey)
+	_ = key.Set(jwk.KeyIDKey, e.kid)
+	set := jwk.NewSet()
+	set.Add(key)
+	return set
+}
+
+func (e *entity) sign(claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = EntityStatementType
+	token.Header["kid"] = e.kid
+	s, _ := token.SignedString(e.key)
+	return s
+}
+
+func (e *entity) statement(subject *entity, extra jwt.MapClaims) string {
+	claims := jwt.MapClaims{
+		"iss":  e.id,
+		"sub":  subject.id,
+		"iat":  time.Now().Unix(),
+		"exp":  time.Now().Add(time.Hour).Unix(),
+		"jwks": subject.keys(),
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	return e.sign(claims)
+}
+
+// federation serves a trust anchor, an intermediate and an OpenID provider.
+type federation struct {
+	server                 *httptest.Server
+	anchor, intermediate   *entity
+	provider               *entity
+	providerStatement      jwt.MapClaims
+	intermediatePolicy     jwt.MapClaims
+	providerConfigOverride string
+}
+
+func newFederation(t *testing.T) *federation {
+	f := &federation{}
+	mux := http.NewServeMux()
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+
+	f.anchor = newEntity(t, f.server.URL+"/ta", "ta")
+	f.intermediate = newEntity(t, f.server.URL+"/ia", "ia")
+	f.provider = newEntity(t, f.server.URL+"/op", "op")
+
+	federationMetadata := func(e *entity) map[string]interface{} {
+		return map[string]interface{}{FederationEntity: map[string]interface{}{"federation_fetch_endpoint": e.id + "/fetch"}}
+	}
+	serve := func(path, body string) {
+		mux.HandleFunc(path, func(res http.ResponseWriter, req *http.Request) {
+			res.Header().Set("Content-Type", "application/"+EntityStatementType)
+			_, _ = res.Write([]byte(body))
+		})
+	}
+	serve("/ta"+ConfigurationPath, f.anchor.statement(f.anchor, jwt.MapClaims{"metadata": federationMetadata(f.anchor)}))
+	serve("/ia"+ConfigurationPath, f.intermediate.statement(f.intermediate, jwt.MapClaims{
+		"metadata":        federationMetadata(f.intermediate),
+		"authority_hints": []string{f.anchor.id},
+	}))
+	mux.HandleFunc("/op"+ConfigurationPath, func(res http.ResponseWriter, req *http.Request) {
+		if f.providerConfigOverride != "" {
+			_, _ = res.Write([]byte(f.providerConfigOverride))
+			return
+		}
+		_, _ = res.Write([]byte(f.provider.statement(f.provider, jwt.MapClaims{
+			"authority_hints": []string{f.server.URL + "/unknown", f.intermediate.id},
+			"metadata": map[string]interface{}{OpenIDProvider: map[string]interface{}{
+				"issuer":                                f.provider.id,
+				"authorization_endpoint":                f.provider.id + "/authorize",
+				"token_endpoint":                        f.provider.id + "/token",
+				"userinfo_endpoint":                     f.provider.id + "/userinfo",
+				"scopes_supported":                      []string{"openid", "email", "offline_access"},
+				"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "private_key_jwt"},
+			}},
+		})))
+	})
+	mux.HandleFunc("/ta/fetch", func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("sub") != f.intermediate.id {
+			res.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = res.Write([]byte(f.anchor.statement(f.intermediate, jwt.MapClaims{"metadata_policy": map[string]interface{}{
+			OpenIDProvider: map[string]interface{}{
+				"scopes_supported": map[string]interface{}{"subset_of": []string{"openid", "email", "profile"}},
+				"contacts":         map[string]interface{}{"default": []string{"ops@federation.example.org"}},
+			},
+		}})))
+	})
+	mux.HandleFunc("/ia/fetch", func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("sub") != f.provider.id {
+			res.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = res.Write([]byte(f.intermediate.statement(f.provider, f.providerStatement)))
+	})
+	f.providerStatement = jwt.MapClaims{"metadata_policy": map[string]interface{}{
+		OpenIDProvider: map[string]interface{}{
+			"token_endpoint_auth_methods_supported": map[string]interface{}{"value": []string{"private_key_jwt"}},
+			"scopes_supported":                      map[string]interface{}{"subset_of": []string{"openid", "email"}},
+		},
+	}}
+	return f
+}
+
+func Test_Resolve(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	f := newFederation(t)
+	r := NewResolver(TrustAnchor{EntityID: f.anchor.id, Keys: f.anchor.keys()})
+	chain, err := r.Resolve(f.provider.id)
+	a.NoError(err)
+	a.Equal(f.anchor.id, chain.TrustAnchor)
+	a.Equal(f.provider.id, chain.Entity())
+	a.Len(chain.Statements, 4)
+	a.Equal(f.intermediate.id, chain.Statements[1].Issuer)
+	a.Equal(f.anchor.id, chain.Statements[2].Issuer)
+	a.WithinDuration(time.Now().Add(time.Hour), chain.ExpiresAt, time.Minute)
+
+	metadata, err := chain.Metadata(OpenIDProvider)
+	a.NoError(err)
+	a.Equal([]interface{}{"openid", "email"}, metadata["scopes_supported"])
+	a.Equal([]interface{}{"private_key_jwt"}, metadata["token_endpoint_auth_methods_supported"])
+	a.Equal([]interface{}{"ops@federation.example.org"}, metadata["contacts"])
+
+	p, err := NewProvider(chain, "client", "secret", "/callback")
+	a.NoError(err)
+	a.Equal(f.provider.id+"/token", p.OpenIDConfig.TokenEndpoint)
+	a.Equal(f.provider.id, p.OpenIDConfig.Issuer)
+}
+
+func Test_ResolveUntrusted(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	f := newFederation(t)
+	other := newEntity(t, f.anchor.id, "ta")
+	_, err := NewResolver(TrustAnchor{EntityID: f.anchor.id, Keys: other.keys()}).Resolve(f.provider.id)
+	a.True(errors.Is(err, ErrNoTrustChain))
+
+	_, err = NewResolver(TrustAnchor{EntityID: f.server.URL + "/other"}).Resolve(f.provider.id)
+	a.True(errors.Is(err, ErrNoTrustChain))
+
+	// a provider configuration signed with keys its superior doesn't state
+	impostor := newEntity(t, f.provider.id, "op")
+	f.providerConfigOverride = impostor.statement(impostor, jwt.MapClaims{"authority_hints": []string{f.intermediate.id}})
+	_, err = NewResolver(TrustAnchor{EntityID: f.anchor.id, Keys: f.anchor.keys()}).Resolve(f.provider.id)
+	a.True(errors.Is(err, ErrNoTrustChain))
+}
+
+func Test_ResolveExpired(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	f := newFederation(t)
+	r := NewResolver(TrustAnchor{EntityID: f.anchor.id, Keys: f.anchor.keys()})
+	r.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, err := r.Resolve(f.provider.id)
+	a.Error(err)
+	a.True(strings.Contains(err.Error(), "expired"))
+}
+
+func Test_MergePolicy(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	merged, err := mergePolicy(
+		map[string]interface{}{"subset_of": []interface{}{"a", "b", "c"}, "add": []interface{}{"x"}},
+		map[string]interface{}{"subset_of": []interface{}{"b", "c", "d"}, "add": []interface{}{"y"}, "essential": true},
+	)
+	a.NoError(err)
+	a.Equal([]interface{}{"b", "c"}, merged["subset_of"])
+	a.Equal([]interface{}{"x", "y"}, merged["add"])
+	a.Equal(true, merged["essential"])
+
+	_, err = mergePolicy(map[string]interface{}{"value": "a"}, map[string]interface{}{"value": "b"})
+	a.True(errors.Is(err, errPolicyConflict))
+	_, err = mergePolicy(map[string]interface{}{"one_of": []interface{}{"a", "b"}}, map[string]interface{}{"value": "c"})
+	a.True(errors.Is(err, errPolicyConflict))
+}
+
+func Test_ApplyPolicy(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	metadata := map[string]interface{}{"alg": "RS256", "remove": "me"}
+	a.NoError(applyPolicy(metadata, "alg", map[string]interface{}{"one_of": []interface{}{"RS256", "ES256"}}))
+	a.Error(applyPolicy(metadata, "alg", map[string]interface{}{"one_of": []interface{}{"ES256"}}))
+	a.NoError(applyPolicy(metadata, "remove", map[string]interface{}{"value": nil}))
+	a.NotContains(metadata, "remove")
+	a.Error(applyPolicy(metadata, "missing", map[string]interface{}{"essential": true}))
+	a.NoError(applyPolicy(metadata, "grants", map[string]interface{}{"add": []interface{}{"authorization_code"}, "superset_of": []interface{}{"authorization_code"}}))
+	a.Equal([]interface{}{"authorization_code"}, metadata["grants"])
+}
@@ -0,0 +1,141 @@
+package oidfed
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var errPolicyConflict = errors.New("conflicting policies")
+
+// mergePolicy merges the operators of a subordinate's policy into the
+// operators of its superiors.
+func mergePolicy(superior, subordinate map[string]interface{}) (map[string]interface{}, error) {
+	merged := map[string]interface{}{}
+	for op, v := range superior {
+		merged[op] = v
+	}
+	for op, v := range subordinate {
+		current, ok := merged[op]
+		if !ok {
+			merged[op] = v
+			continue
+		}
+		switch op {
+		case "value", "default":
+			if !reflect.DeepEqual(current, v) {
+				return nil, fmt.Errorf("%w of %s", errPolicyConflict, op)
+			}
+		case "add", "superset_of":
+			merged[op] = union(values(current), values(v))
+		case "one_of", "subset_of":
+			merged[op] = intersection(values(current), values(v))
+		case "essential":
+			currentEssential, _ := current.(bool)
+			essential, _ := v.(bool)
+			merged[op] = currentEssential || essential
+		default:
+			return nil, fmt.Errorf("unsupported operator %s", op)
+		}
+	}
+
+	if value, ok := merged["value"]; ok {
+		if oneOf, ok := merged["one_of"]; ok && !contains(values(oneOf), value) {
+			return nil, fmt.Errorf("%w of value and one_of", errPolicyConflict)
+		}
+		if subsetOf, ok := merged["subset_of"]; ok && !isSubset(values(value), values(subsetOf)) {
+			return nil, fmt.Errorf("%w of value and subset_of", errPolicyConflict)
+		}
+	}
+	return merged, nil
+}
+
+// applyPolicy applies the operators to the parameter of the metadata.
+func applyPolicy(metadata map[string]interface{}, parameter string, operators map[string]interface{}) error {
+	if value, ok := operators["value"]; ok {
+		if value == nil {
+			delete(metadata, parameter)
+		} else {
+			metadata[parameter] = value
+		}
+	}
+	if add, ok := operators["add"]; ok {
+		if current, ok := metadata[parameter]; ok {
+			metadata[parameter] = union(values(current), values(add))
+		} else {
+			metadata[parameter] = values(add)
+		}
+	}
+	if def, ok := operators["default"]; ok {
+		if _, ok := metadata[parameter]; !ok {
+			metadata[parameter] = def
+		}
+	}
+
+	current, present := metadata[parameter]
+	if oneOf, ok := operators["one_of"]; ok && present && !contains(values(oneOf), current) {
+		return fmt.Errorf("%v is not one of %v", current, oneOf)
+	}
+	if subsetOf, ok := operators["subset_of"]; ok && present {
+		subset := intersection(values(current), values(subsetOf))
+		if len(subset) == 0 {
+			delete(metadata, parameter)
+			present = false
+		} else {
+			metadata[parameter] = subset
+		}
+	}
+	if supersetOf, ok := operators["superset_of"]; ok && present && !isSubset(values(supersetOf), values(current)) {
+		return fmt.Errorf("%v is not a superset of %v", current, supersetOf)
+	}
+	if essential, _ := operators["essential"].(bool); essential && !present {
+		return errors.New("the essential parameter is missing")
+	}
+	return nil
+}
+
+// values returns the values of a JSON array, or the single value.
+func values(v interface{}) []interface{} {
+	if list, ok := v.([]interface{}); ok {
+		return list
+	}
+	return []interface{}{v}
+}
+
+func contains(list []interface{}, v interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSubset(subset, set []interface{}) bool {
+	for _, v := range subset {
+		if !contains(set, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func union(a, b []interface{}) []interface{} {
+	result := append([]interface{}{}, a...)
+	for _, v := range b {
+		if !contains(result, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func intersection(a, b []interface{}) []interface{} {
+	result := []interface{}{}
+	for _, v := range a {
+		if contains(b, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,30 @@
+package oidfed
+
+import (
+	"fmt"
+
+	"github.com/andreimerlescu/goth/providers/openidConnect"
+)
+
+// NewProvider creates an OpenID Connect provider with the endpoints of the
+// openid_provider metadata of the chain, for a client registered with it.
+func NewProvider(chain *TrustChain, clientKey, secret, callbackURL string, scopes ...string) (*openidConnect.Provider, error) {
+	metadata, err := chain.Metadata(OpenIDProvider)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := func(name string) string {
+		s, _ := metadata[name].(string)
+		return s
+	}
+	if endpoint("authorization_endpoint") == "" || endpoint("token_endpoint") == "" {
+		return nil, fmt.Errorf("oidfed: %s is not an OpenID provider", chain.Entity())
+	}
+	issuer := endpoint("issuer")
+	if issuer == "" {
+		issuer = chain.Entity()
+	}
+	return openidConnect.NewCustomisedURL(clientKey, secret, callbackURL,
+		endpoint("authorization_endpoint"), endpoint("token_endpoint"), issuer,
+		endpoint("userinfo_endpoint"), endpoint("end_session_endpoint"), scopes...)
+}
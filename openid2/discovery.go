@@ -0,0 +1,235 @@
+package openid2
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	typeServer = "http://specs.openid.net/auth/2.0/server"
+	typeSignon = "http://specs.openid.net/auth/2.0/signon"
+
+	xrdsContentType = "application/xrds+xml"
+	maxDocumentSize = 1 << 20
+)
+
+var (
+	linkTag      = regexp.MustCompile(`(?is)<(link|meta)\s[^>]*>`)
+	tagAttribute = regexp.MustCompile(`(?s)([\w-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+)
+
+// xrds is the Yadis document of an identifier.
+type xrds struct {
+	XRD []struct {
+		Service []struct {
+			Priority string   `xml:"priority,attr"`
+			Type     []string `xml:"Type"`
+			URI      []string `xml:"URI"`
+			LocalID  string   `xml:"LocalID"`
+		} `xml:"Service"`
+	} `xml:"XRD"`
+}
+
+// Discover finds the OpenID provider endpoint of the identifier the user
+// entered, with Yadis discovery and then HTML-based discovery.
+func (rp *RelyingParty) Discover(identifier string) (*Endpoint, error) {
+	claimedID, err := normalize(identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	body, header, finalURL, err := rp.fetch(claimedID)
+	if err != nil {
+		return nil, err
+	}
+	// the claimed identifier is the URL after redirects
+	claimedID = finalURL
+
+	if isXRDS(header) {
+		return parseXRDS(body, claimedID)
+	}
+	location := header.Get("X-XRDS-Location")
+	if location == "" {
+		location = htmlXRDSLocation(body)
+	}
+	if location != "" {
+		document, documentHeader, _, err := rp.fetch(location)
+		if err == nil && isXRDS(documentHeader) {
+			return parseXRDS(document, claimedID)
+		}
+	}
+	return parseHTML(body, claimedID)
+}
+
+// fetch gets the document, and returns its final URL without fragment.
+func (rp *RelyingParty) fetch(documentURL string) ([]byte, http.Header, string, error) {
+	req, err := http.NewRequest("GET", documentURL, nil)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	req.Header.Set("Accept", xrdsContentType+", text/html;q=0.9, application/xhtml+xml;q=0.9")
+	resp, err := rp.Client().Do(req)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, "", fmt.Errorf("openid2: %s responded with a %d to discovery", documentURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	final := *resp.Request.URL
+	final.Fragment = ""
+	return body, resp.Header, final.String(), nil
+}
+
+// normalize normalizes the identifier the user entered, see
+// https://openid.net/specs/openid-authentication-2_0.html#normalization
+func normalize(identifier string) (string, error) {
+	identifier = strings.TrimSpace(identifier)
+	identifier = strings.TrimPrefix(identifier, "xri://")
+	if identifier == "" {
+		return "", errors.New("openid2: no identifier")
+	}
+	if strings.ContainsAny(identifier[:1], "=@+$!(") {
+		return "", errors.New("openid2: XRI identifiers are not supported")
+	}
+	if !strings.HasPrefix(identifier, "http://") && !strings.HasPrefix(identifier, "https://") {
+		identifier = "http://" + identifier
+	}
+	u, err := url.Parse(identifier)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("openid2: invalid identifier %q", identifier)
+	}
+	u.Fragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String(), nil
+}
+
+func isXRDS(header http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return mediaType == xrdsContentType
+}
+
+// parseXRDS picks the OpenID 2.0 service with the highest priority of the
+// last XRD of the document.
+func parseXRDS(document []byte, claimedID string) (*Endpoint, error) {
+	d := xml.NewDecoder(bytes.NewReader(document))
+	d.Strict = true
+	var doc xrds
+	err := d.Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.XRD) == 0 {
+		return nil, errors.New("openid2: the XRDS document has no XRD")
+	}
+	services := doc.XRD[len(doc.XRD)-1].Service
+	sort.SliceStable(services, func(i, j int) bool {
+		return priority(services[i].Priority) < priority(services[j].Priority)
+	})
+
+	// OP identifiers take precedence over claimed identifiers
+	for _, serviceType := range []string{typeServer, typeSignon} {
+		for _, service := range services {
+			if !contains(service.Type, serviceType) || len(service.URI) == 0 {
+				continue
+			}
+			e := &Endpoint{URL: strings.TrimSpace(service.URI[0])}
+			if serviceType == typeServer {
+				e.ClaimedID = IdentifierSelect
+			} else {
+				e.ClaimedID = claimedID
+				e.LocalID = strings.TrimSpace(service.LocalID)
+			}
+			return e, nil
+		}
+	}
+	return nil, errors.New("openid2: no OpenID 2.0 service was discovered")
+}
+
+func priority(p string) int {
+	n, err := strconv.Atoi(p)
+	if err != nil || n < 0 {
+		// services without a priority come last
+		return int(^uint(0) >> 1)
+	}
+	return n
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.TrimSpace(v) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// tagAttributes returns the attributes of the link and meta tags of the page.
+func tagAttributes(document []byte) []map[string]string {
+	var tags []map[string]string
+	for _, tag := range linkTag.FindAll(document, -1) {
+		attributes := map[string]string{"": strings.ToLower(string(linkTag.FindSubmatch(tag)[1]))}
+		for _, match := range tagAttribute.FindAllSubmatch(tag, -1) {
+			value := strings.Trim(string(match[2]), `"'`)
+			attributes[strings.ToLower(string(match[1]))] = html.UnescapeString(value)
+		}
+		tags = append(tags, attributes)
+	}
+	return tags
+}
+
+func htmlXRDSLocation(document []byte) string {
+	for _, tag := range tagAttributes(document) {
+		if tag[""] == "meta" && strings.EqualFold(tag["http-equiv"], "X-XRDS-Location") {
+			return tag["content"]
+		}
+	}
+	return ""
+}
+
+// parseHTML reads the openid2.provider and openid2.local_id links of the page.
+func parseHTML(document []byte, claimedID string) (*Endpoint, error) {
+	e := &Endpoint{ClaimedID: claimedID}
+	for _, tag := range tagAttributes(document) {
+		if tag[""] != "link" {
+			continue
+		}
+		for _, rel := range strings.Fields(tag["rel"]) {
+			switch rel {
+			case "openid2.provider":
+				if e.URL == "" {
+					e.URL = tag["href"]
+				}
+			case "openid2.local_id":
+				if e.LocalID == "" {
+					e.LocalID = tag["href"]
+				}
+			}
+		}
+	}
+	if e.URL == "" {
+		return nil, errors.New("openid2: no OpenID 2.0 provider was discovered")
+	}
+	return e, nil
+}
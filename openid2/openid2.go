@@ -0,0 +1,353 @@
+// Package openid2 implements an OpenID 2.0 relying party, for the legacy
+// identity providers which never moved to OAuth 2.0 or OpenID Connect.
+//
+// The RelyingParty discovers OpenID providers, sends users to them with
+// checkid_setup requests, and verifies their positive assertions with
+// check_authentication requests, see https://openid.net/specs/openid-authentication-2_0.html
+// Provider builds a goth.Provider on top of it, providers with a fixed
+// endpoint such as Steam use the RelyingParty directly.
+package openid2
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+const (
+	// Namespace is the openid.ns of OpenID 2.0 messages.
+	Namespace = "http://specs.openid.net/auth/2.0"
+	// IdentifierSelect lets the OpenID provider choose the identifier of the user.
+	IdentifierSelect = "http://specs.openid.net/auth/2.0/identifier_select"
+)
+
+var (
+	// ErrCanceled is returned when the user canceled the authentication.
+	ErrCanceled = errors.New("openid2: the authentication was canceled")
+	// ErrNonceUsed is returned when a positive assertion is replayed.
+	ErrNonceUsed = errors.New("openid2: the response nonce has already been used")
+)
+
+// Endpoint is an OpenID provider endpoint, as discovered for an identifier.
+type Endpoint struct {
+	// URL is the OP endpoint URL the authentication requests are sent to.
+	URL string
+	// ClaimedID is the identifier of the user, or IdentifierSelect when the
+	// user entered the identifier of the OpenID provider.
+	ClaimedID string
+	// LocalID is the identifier of the user at the OpenID provider, when it
+	// isn't the ClaimedID.
+	LocalID string
+	// Authoritative trusts the OpenID provider to assert any identifier,
+	// instead of verifying the identifiers it chose with discovery. This is
+	// only safe for providers with their own identifiers, such as Steam.
+	Authoritative bool
+}
+
+// NonceStore remembers the response nonces which have been used, until they
+// expire.
+type NonceStore interface {
+	// UseNonce records the use of the nonce, or returns ErrNonceUsed.
+	UseNonce(nonce string, expiresAt time.Time) error
+}
+
+// NewMemoryNonceStore returns a NonceStore which only knows about the nonces
+// used with this process.
+func NewMemoryNonceStore() NonceStore {
+	return &memoryNonceStore{used: map[string]time.Time{}}
+}
+
+type memoryNonceStore struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+func (m *memoryNonceStore) UseNonce(nonce string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for used, expiry := range m.used {
+		if now.After(expiry) {
+			delete(m.used, used)
+		}
+	}
+	if _, ok := m.used[nonce]; ok {
+		return ErrNonceUsed
+	}
+	m.used[nonce] = expiresAt
+	return nil
+}
+
+// RelyingParty sends authentication requests to OpenID providers and verifies
+// their responses.
+type RelyingParty struct {
+	// Realm is the URL pattern the OpenID provider asks the user to trust,
+	// it must match the return_to URLs. It defaults to the scheme and host of
+	// the return_to URL.
+	Realm      string
+	HTTPClient *http.Client
+	// Nonces rejects replayed assertions. Assertions aren't checked for
+	// replays without one.
+	Nonces NonceStore
+	// MaxNonceAge is how old response nonces may be, 5 minutes by default.
+	MaxNonceAge time.Duration
+}
+
+// Assertion is a verified positive assertion.
+type Assertion struct {
+	ClaimedID     string
+	LocalID       string
+	OPEndpoint    string
+	ResponseNonce string
+	// Signed holds the signed fields of the response, without their
+	// "openid." prefix.
+	Signed map[string]string
+}
+
+// Extension returns the signed fields of the extension with the namespace,
+// without their alias.
+func (a *Assertion) Extension(namespace string) map[string]string {
+	alias := ""
+	for key, value := range a.Signed {
+		if strings.HasPrefix(key, "ns.") && value == namespace {
+			alias = strings.TrimPrefix(key, "ns.")
+			break
+		}
+	}
+	if alias == "" {
+		return nil
+	}
+	fields := map[string]string{}
+	for key, value := range a.Signed {
+		if strings.HasPrefix(key, alias+".") {
+			fields[strings.TrimPrefix(key, alias+".")] = value
+		}
+	}
+	return fields
+}
+
+func (rp *RelyingParty) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(rp.HTTPClient)
+}
+
+func (rp *RelyingParty) realm(returnTo string) (string, error) {
+	if rp.Realm != "" {
+		return rp.Realm, nil
+	}
+	u, err := url.Parse(returnTo)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s://%s/", u.Scheme, u.Host), nil
+}
+
+// AuthURL returns the checkid_setup request sending the user to the endpoint,
+// and back to returnTo. Extensions holds the fields of the extensions to
+// request, with their "openid." prefix.
+func (rp *RelyingParty) AuthURL(e *Endpoint, returnTo string, extensions url.Values) (string, error) {
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return "", err
+	}
+	realm, err := rp.realm(returnTo)
+	if err != nil {
+		return "", err
+	}
+
+	claimedID := e.ClaimedID
+	if claimedID == "" {
+		claimedID = IdentifierSelect
+	}
+	identity := e.LocalID
+	if identity == "" {
+		identity = claimedID
+	}
+
+	v := u.Query()
+	for key, values := range extensions {
+		v[key] = values
+	}
+	v.Set("openid.ns", Namespace)
+	v.Set("openid.mode", "checkid_setup")
+	v.Set("openid.claimed_id", claimedID)
+	v.Set("openid.identity", identity)
+	v.Set("openid.return_to", returnTo)
+	v.Set("openid.realm", realm)
+	u.RawQuery = v.Encode()
+	return u.String(), nil
+}
+
+// Verify verifies the response of the endpoint, received at returnTo, with
+// a check_authentication request.
+func (rp *RelyingParty) Verify(params goth.Params, returnTo string, e *Endpoint) (*Assertion, error) {
+	switch params.Get("openid.mode") {
+	case "id_res":
+	case "cancel":
+		return nil, ErrCanceled
+	case "error":
+		return nil, fmt.Errorf("openid2: %s", params.Get("openid.error"))
+	default:
+		return nil, fmt.Errorf("openid2: unexpected mode %q", params.Get("openid.mode"))
+	}
+	if params.Get("openid.ns") != Namespace {
+		return nil, errors.New("openid2: not an OpenID 2.0 response")
+	}
+	if params.Get("openid.return_to") != returnTo {
+		return nil, errors.New("openid2: the return_to URL doesn't match")
+	}
+	if params.Get("openid.op_endpoint") != e.URL {
+		return nil, errors.New("openid2: the response is from another OP endpoint")
+	}
+
+	a := &Assertion{
+		ClaimedID:     params.Get("openid.claimed_id"),
+		LocalID:       params.Get("openid.identity"),
+		OPEndpoint:    params.Get("openid.op_endpoint"),
+		ResponseNonce: params.Get("openid.response_nonce"),
+		Signed:        map[string]string{},
+	}
+	for _, field := range strings.Split(params.Get("openid.signed"), ",") {
+		if field != "" {
+			a.Signed[field] = params.Get("openid." + field)
+		}
+	}
+	required := []string{"op_endpoint", "return_to", "response_nonce", "assoc_handle"}
+	if a.ClaimedID != "" {
+		required = append(required, "claimed_id", "identity")
+	}
+	for _, field := range required {
+		if _, ok := a.Signed[field]; !ok {
+			return nil, fmt.Errorf("openid2: the %s field isn't signed", field)
+		}
+	}
+	if a.ClaimedID == "" {
+		return nil, errors.New("openid2: the response has no claimed identifier")
+	}
+
+	err := rp.checkNonce(a)
+	if err != nil {
+		return nil, err
+	}
+	err = rp.checkAuthentication(params, a)
+	if err != nil {
+		return nil, err
+	}
+	err = rp.verifyClaimedID(a, e)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (rp *RelyingParty) checkNonce(a *Assertion) error {
+	if len(a.ResponseNonce) < len("2006-01-02T15:04:05Z") {
+		return errors.New("openid2: invalid response nonce")
+	}
+	issuedAt, err := time.Parse(time.RFC3339, a.ResponseNonce[:len("2006-01-02T15:04:05Z")])
+	if err != nil {
+		return errors.New("openid2: invalid response nonce")
+	}
+	maxAge := rp.MaxNonceAge
+	if maxAge == 0 {
+		maxAge = 5 * time.Minute
+	}
+	now := time.Now()
+	if issuedAt.Add(maxAge).Before(now) || issuedAt.After(now.Add(maxAge)) {
+		return errors.New("openid2: the response nonce has expired")
+	}
+	if rp.Nonces == nil {
+		return nil
+	}
+	return rp.Nonces.UseNonce(a.OPEndpoint+" "+a.ResponseNonce, issuedAt.Add(2*maxAge))
+}
+
+// checkAuthentication asks the endpoint to verify the signature of the response.
+func (rp *RelyingParty) checkAuthentication(params goth.Params, a *Assertion) error {
+	v := url.Values{}
+	v.Set("openid.ns", Namespace)
+	v.Set("openid.mode", "check_authentication")
+	v.Set("openid.sig", params.Get("openid.sig"))
+	v.Set("openid.signed", params.Get("openid.signed"))
+	v.Set("openid.assoc_handle", params.Get("openid.assoc_handle"))
+	for field, value := range a.Signed {
+		if field != "mode" {
+			v.Set("openid."+field, value)
+		}
+	}
+
+	resp, err := rp.Client().PostForm(a.OPEndpoint, v)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("openid2: the OP endpoint responded with a %d to check_authentication", resp.StatusCode)
+	}
+	response, err := parseKeyValue(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return err
+	}
+	if response["ns"] != Namespace || response["is_valid"] != "true" {
+		return errors.New("openid2: the signature of the response is invalid")
+	}
+	return nil
+}
+
+// verifyClaimedID checks that the endpoint is authorized to assert the
+// claimed identifier, see https://openid.net/specs/openid-authentication-2_0.html#verify_disco
+func (rp *RelyingParty) verifyClaimedID(a *Assertion, e *Endpoint) error {
+	if e.Authoritative {
+		return nil
+	}
+	if e.ClaimedID != "" && e.ClaimedID != IdentifierSelect {
+		localID := e.LocalID
+		if localID == "" {
+			localID = e.ClaimedID
+		}
+		if a.ClaimedID != e.ClaimedID || a.LocalID != localID {
+			return errors.New("openid2: the response is for another identifier")
+		}
+		return nil
+	}
+
+	discovered, err := rp.Discover(a.ClaimedID)
+	if err != nil {
+		return err
+	}
+	localID := discovered.LocalID
+	if localID == "" {
+		localID = discovered.ClaimedID
+	}
+	if discovered.URL != a.OPEndpoint || localID != a.LocalID || discovered.ClaimedID == IdentifierSelect {
+		return errors.New("openid2: the OP endpoint isn't authorized to assert the claimed identifier")
+	}
+	return nil
+}
+
+// parseKeyValue parses the key-value form encoding of direct responses.
+func parseKeyValue(r io.Reader) (map[string]string, error) {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			return nil, errors.New("openid2: invalid key-value form response")
+		}
+		values[line[:colon]] = line[colon+1:]
+	}
+	return values, scanner.Err()
+}
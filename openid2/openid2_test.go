@@ -0,0 +1,275 @@
+package openid2_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/openid2"
+	"github.com/stretchr/testify/assert"
+)
+
+const xrdsDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<xrds:XRDS xmlns:xrds="xri://$xrds" xmlns="xri://$xrd*($v*2.0)">
+  <XRD>
+    <Service priority="10">
+      <Type>http://specs.openid.net/auth/2.0/%s</Type>
+      <URI>%s</URI>
+      <LocalID>%s</LocalID>
+    </Service>
+    <Service priority="0">
+      <Type>http://openid.net/signon/1.1</Type>
+      <URI>https://legacy.example.com/</URI>
+    </Service>
+  </XRD>
+</xrds:XRDS>`
+
+// opServer is an OpenID provider whose valid signature is "valid".
+func opServer(t *testing.T) *httptest.Server {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/op":
+			_ = r.ParseForm()
+			if r.Method != "POST" || r.Form.Get("openid.mode") != "check_authentication" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			valid := r.Form.Get("openid.sig") == "valid"
+			fmt.Fprintf(w, "ns:%s\nis_valid:%t\n", openid2.Namespace, valid)
+		case "/":
+			w.Header().Set("Content-Type", "application/xrds+xml")
+			fmt.Fprintf(w, xrdsDocument, "server", ts.URL+"/op", "")
+		case "/id/jdoe":
+			w.Header().Set("Content-Type", "application/xrds+xml")
+			fmt.Fprintf(w, xrdsDocument, "signon", ts.URL+"/op", "")
+		case "/id/other":
+			w.Header().Set("Content-Type", "application/xrds+xml")
+			fmt.Fprintf(w, xrdsDocument, "signon", "https://other.example.com/op", "")
+		case "/yadis":
+			w.Header().Set("X-XRDS-Location", ts.URL+"/id/jdoe")
+			fmt.Fprint(w, "<html></html>")
+		case "/jdoe.html":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<html><head>
+<link rel="stylesheet" href="/style.css">
+<LINK REL="openid2.provider openid.server" HREF="%s/op">
+<link href='%s/id/jdoe' rel='openid2.local_id'>
+</head></html>`, ts.URL, ts.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// response is a positive assertion of the OpenID provider.
+func response(opEndpoint, returnTo, claimedID string, extra map[string]string) url.Values {
+	v := url.Values{}
+	v.Set("openid.ns", openid2.Namespace)
+	v.Set("openid.mode", "id_res")
+	v.Set("openid.op_endpoint", opEndpoint)
+	v.Set("openid.claimed_id", claimedID)
+	v.Set("openid.identity", claimedID)
+	v.Set("openid.return_to", returnTo)
+	v.Set("openid.response_nonce", time.Now().UTC().Format("2006-01-02T15:04:05Z")+fmt.Sprint(time.Now().UnixNano()))
+	v.Set("openid.assoc_handle", "handle")
+	for key, value := range extra {
+		v.Set("openid."+key, value)
+	}
+	var signed []string
+	for key := range v {
+		signed = append(signed, strings.TrimPrefix(key, "openid."))
+	}
+	sort.Strings(signed)
+	v.Set("openid.signed", strings.Join(signed, ","))
+	v.Set("openid.sig", "valid")
+	return v
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), openid2.New("", "/foo"))
+	a.Implements((*goth.BeginAuthWithParamsProvider)(nil), openid2.New("", "/foo"))
+}
+
+func Test_Discover(t *testing.T) {
+	t.Parallel()
+	ts := opServer(t)
+	rp := &openid2.RelyingParty{}
+
+	tests := []struct {
+		identifier string
+		endpoint   openid2.Endpoint
+	}{
+		{ts.URL, openid2.Endpoint{URL: ts.URL + "/op", ClaimedID: openid2.IdentifierSelect}},
+		{ts.URL + "/id/jdoe#fragment", openid2.Endpoint{URL: ts.URL + "/op", ClaimedID: ts.URL + "/id/jdoe"}},
+		{strings.TrimPrefix(ts.URL, "http://") + "/yadis", openid2.Endpoint{URL: ts.URL + "/op", ClaimedID: ts.URL + "/yadis"}},
+		{ts.URL + "/jdoe.html", openid2.Endpoint{URL: ts.URL + "/op", ClaimedID: ts.URL + "/jdoe.html", LocalID: ts.URL + "/id/jdoe"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.identifier, func(t *testing.T) {
+			a := assert.New(t)
+			e, err := rp.Discover(tt.identifier)
+			a.NoError(err)
+			a.Equal(&tt.endpoint, e)
+		})
+	}
+
+	for _, identifier := range []string{"", "=example", ts.URL + "/missing"} {
+		_, err := rp.Discover(identifier)
+		assert.Error(t, err, identifier)
+	}
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ts := opServer(t)
+	p := openid2.New(ts.URL, "https://example.com/auth/openid2/callback")
+	p.Profile = true
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*openid2.Session)
+	a.Equal("https://example.com/auth/openid2/callback?state=test_state", s.ReturnTo)
+	a.Equal(ts.URL+"/op", s.Endpoint.URL)
+
+	u, err := url.Parse(s.AuthURL)
+	a.NoError(err)
+	q := u.Query()
+	a.Equal(ts.URL+"/op", u.Scheme+"://"+u.Host+u.Path)
+	a.Equal("checkid_setup", q.Get("openid.mode"))
+	a.Equal(openid2.IdentifierSelect, q.Get("openid.claimed_id"))
+	a.Equal(openid2.IdentifierSelect, q.Get("openid.identity"))
+	a.Equal(s.ReturnTo, q.Get("openid.return_to"))
+	a.Equal("https://example.com/", q.Get("openid.realm"))
+	a.Equal("http://openid.net/extensions/sreg/1.1", q.Get("openid.ns.sreg"))
+
+	p = openid2.New("", "https://example.com/auth/openid2/callback")
+	_, err = p.BeginAuth("test_state")
+	a.Error(err)
+	session, err = p.BeginAuthWithParams("test_state", url.Values{"openid_identifier": {ts.URL + "/jdoe.html"}})
+	a.NoError(err)
+	u, _ = url.Parse(session.(*openid2.Session).AuthURL)
+	a.Equal(ts.URL+"/jdoe.html", u.Query().Get("openid.claimed_id"))
+	a.Equal(ts.URL+"/id/jdoe", u.Query().Get("openid.identity"))
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ts := opServer(t)
+	p := openid2.New(ts.URL, "https://example.com/auth/openid2/callback")
+
+	session, _ := p.BeginAuth("test_state")
+	s := session.(*openid2.Session)
+	_, err := p.FetchUser(s)
+	a.Error(err)
+
+	params := response(ts.URL+"/op", s.ReturnTo, ts.URL+"/id/jdoe", map[string]string{
+		"ns.ext1":         "http://openid.net/extensions/sreg/1.1",
+		"ext1.email":      "jdoe@example.com",
+		"ext1.nickname":   "jdoe",
+		"ns.ax":           "http://openid.net/srv/ax/1.0",
+		"ax.type.a1":      "http://axschema.org/namePerson/first",
+		"ax.value.a1":     "John",
+		"ax.type.a2":      "http://axschema.org/namePerson/last",
+		"ax.value.a2":     "Doe",
+		"ax.type.unknown": "http://example.com/unknown",
+	})
+	nonce, err := s.Authorize(p, params)
+	a.NoError(err)
+	a.Equal(params.Get("openid.response_nonce"), nonce)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal(ts.URL+"/id/jdoe", user.UserID)
+	a.Equal("jdoe@example.com", user.Email)
+	a.Equal("jdoe", user.NickName)
+	a.Equal("John", user.FirstName)
+	a.Equal("Doe", user.LastName)
+	a.Equal("John Doe", user.Name)
+
+	// the same response can't be used twice
+	_, err = (&openid2.Session{ReturnTo: s.ReturnTo, Endpoint: s.Endpoint}).Authorize(p, params)
+	a.Equal(openid2.ErrNonceUsed, err)
+}
+
+func Test_AuthorizeRejected(t *testing.T) {
+	t.Parallel()
+	ts := opServer(t)
+	p := openid2.New(ts.URL, "https://example.com/auth/openid2/callback")
+	session, _ := p.BeginAuth("test_state")
+	returnTo := session.(*openid2.Session).ReturnTo
+	claimedID := ts.URL + "/id/jdoe"
+
+	tests := []struct {
+		name   string
+		params url.Values
+	}{
+		{"canceled", url.Values{"openid.ns": {openid2.Namespace}, "openid.mode": {"cancel"}}},
+		{"other return_to", response(ts.URL+"/op", returnTo+"x", claimedID, nil)},
+		{"other OP endpoint", response("https://other.example.com/op", returnTo, claimedID, nil)},
+		{"invalid signature", func() url.Values {
+			v := response(ts.URL+"/op", returnTo, claimedID, nil)
+			v.Set("openid.sig", "forged")
+			return v
+		}()},
+		{"unsigned claimed_id", func() url.Values {
+			v := response(ts.URL+"/op", returnTo, claimedID, nil)
+			v.Set("openid.signed", "op_endpoint,return_to,response_nonce,assoc_handle")
+			return v
+		}()},
+		{"expired nonce", response(ts.URL+"/op", returnTo, claimedID, map[string]string{
+			"response_nonce": time.Now().Add(-time.Hour).UTC().Format("2006-01-02T15:04:05Z") + "abc",
+		})},
+		{"claimed_id of another OP", response(ts.URL+"/op", returnTo, ts.URL+"/id/other", nil)},
+		{"undiscoverable claimed_id", response(ts.URL+"/op", returnTo, ts.URL+"/missing", nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+			s := &openid2.Session{ReturnTo: returnTo, Endpoint: session.(*openid2.Session).Endpoint}
+			_, err := s.Authorize(p, tt.params)
+			a.Error(err)
+			a.Empty(s.ClaimedID)
+		})
+	}
+}
+
+func Test_VerifyAuthoritative(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ts := opServer(t)
+	rp := &openid2.RelyingParty{}
+	e := &openid2.Endpoint{URL: ts.URL + "/op", ClaimedID: openid2.IdentifierSelect, Authoritative: true}
+
+	// the claimed identifier isn't discovered again
+	assertion, err := rp.Verify(response(ts.URL+"/op", "/foo", ts.URL+"/missing", nil), "/foo", e)
+	a.NoError(err)
+	a.Equal(ts.URL+"/missing", assertion.ClaimedID)
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := openid2.New("", "/foo")
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://openid.example.com/op","ReturnTo":"/foo?state=x","Endpoint":{"URL":"https://openid.example.com/op","ClaimedID":"http://specs.openid.net/auth/2.0/identifier_select","LocalID":"","Authoritative":false},"ClaimedID":"https://openid.example.com/id/jdoe"}`)
+	a.NoError(err)
+
+	s := session.(*openid2.Session)
+	a.Equal("https://openid.example.com/op", s.AuthURL)
+	a.Equal("/foo?state=x", s.ReturnTo)
+	a.Equal("https://openid.example.com/op", s.Endpoint.URL)
+	a.Equal("https://openid.example.com/id/jdoe", s.ClaimedID)
+}
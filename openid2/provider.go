@@ -0,0 +1,220 @@
+package openid2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// Namespaces of the extensions requesting the profile of the user.
+const (
+	sregNamespace = "http://openid.net/extensions/sreg/1.1"
+	axNamespace   = "http://openid.net/srv/ax/1.0"
+)
+
+// axTypes are the attribute exchange types requested, by attribute.
+var axTypes = map[string]string{
+	"email":     "http://axschema.org/contact/email",
+	"firstname": "http://axschema.org/namePerson/first",
+	"lastname":  "http://axschema.org/namePerson/last",
+	"fullname":  "http://axschema.org/namePerson",
+	"nickname":  "http://axschema.org/namePerson/friendly",
+}
+
+// New creates a new OpenID 2.0 provider for the identifier of an OpenID
+// provider, or of a user. When the identifier is empty, users enter theirs,
+// posted as the "openid_identifier" parameter.
+// You should always call `openid2.New` to get a new Provider. Never try to
+// create one manually.
+func New(identifier, callbackURL string) *Provider {
+	return &Provider{
+		Identifier:   identifier,
+		CallbackURL:  callbackURL,
+		Nonces:       NewMemoryNonceStore(),
+		providerName: "openid2",
+	}
+}
+
+// Provider is the implementation of `goth.Provider` for OpenID 2.0 providers.
+type Provider struct {
+	Identifier string
+	// Endpoint skips the discovery of the identifier.
+	Endpoint    *Endpoint
+	CallbackURL string
+	Realm       string
+	HTTPClient  *http.Client
+	Nonces      NonceStore
+	// Profile requests the email and name of the user with the simple
+	// registration and attribute exchange extensions.
+	Profile      bool
+	providerName string
+}
+
+var _ goth.BeginAuthWithParamsProvider = &Provider{}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Local reports that the provider is a goth.LocalProvider: the assertions are verified during the login, not the users fetched with a token.
+func (p *Provider) Local() bool {
+	return true
+}
+
+// Debug is a no-op for the openid2 package.
+func (p *Provider) Debug(debug bool) {}
+
+func (p *Provider) relyingParty() *RelyingParty {
+	return &RelyingParty{
+		Realm:      p.Realm,
+		HTTPClient: p.HTTPClient,
+		Nonces:     p.Nonces,
+	}
+}
+
+// BeginAuth discovers the OpenID provider of the Identifier, and asks it to
+// authenticate the user.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return p.BeginAuthWithParams(state, url.Values{})
+}
+
+// BeginAuthWithParams discovers the OpenID provider of the identifier the user
+// entered, unless the provider has an Identifier or Endpoint.
+func (p *Provider) BeginAuthWithParams(state string, params goth.Params) (goth.Session, error) {
+	rp := p.relyingParty()
+	endpoint := p.Endpoint
+	if endpoint == nil {
+		identifier := p.Identifier
+		if identifier == "" {
+			identifier = params.Get("openid_identifier")
+		}
+		var err error
+		endpoint, err = rp.Discover(identifier)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	returnTo, err := url.Parse(p.CallbackURL)
+	if err != nil {
+		return nil, err
+	}
+	q := returnTo.Query()
+	q.Set("state", state)
+	returnTo.RawQuery = q.Encode()
+
+	var extensions url.Values
+	if p.Profile {
+		extensions = profileExtensions()
+	}
+	authURL, err := rp.AuthURL(endpoint, returnTo.String(), extensions)
+	if err != nil {
+		return nil, err
+	}
+	session := &Session{
+		AuthURL:  authURL,
+		ReturnTo: returnTo.String(),
+		Endpoint: endpoint,
+	}
+	return session, nil
+}
+
+func profileExtensions() url.Values {
+	v := url.Values{}
+	v.Set("openid.ns.sreg", sregNamespace)
+	v.Set("openid.sreg.optional", "email,fullname,nickname")
+	v.Set("openid.ns.ax", axNamespace)
+	v.Set("openid.ax.mode", "fetch_request")
+	var aliases []string
+	for alias, axType := range axTypes {
+		v.Set("openid.ax.type."+alias, axType)
+		aliases = append(aliases, alias)
+	}
+	v.Set("openid.ax.if_available", strings.Join(aliases, ","))
+	return v
+}
+
+// profile reads the signed attributes of the extensions.
+func profile(a *Assertion) map[string]string {
+	attributes := map[string]string{}
+	for name, value := range a.Extension(sregNamespace) {
+		if name != "" && value != "" {
+			attributes[name] = value
+		}
+	}
+	ax := a.Extension(axNamespace)
+	for key, axType := range ax {
+		if !strings.HasPrefix(key, "type.") {
+			continue
+		}
+		alias := strings.TrimPrefix(key, "type.")
+		for name, t := range axTypes {
+			if t == axType && ax["value."+alias] != "" {
+				if _, ok := attributes[name]; !ok {
+					attributes[name] = ax["value."+alias]
+				}
+			}
+		}
+	}
+	return attributes
+}
+
+// FetchUser returns the claimed identifier, and the profile of the user when
+// it was requested.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	s := session.(*Session)
+	user := goth.User{
+		Provider: p.Name(),
+	}
+
+	if s.ClaimedID == "" {
+		// the response hasn't been verified yet
+		return user, fmt.Errorf("%s cannot get user information without a claimed identifier", p.providerName)
+	}
+
+	user.UserID = s.ClaimedID
+	user.RawData = map[string]interface{}{"claimed_id": s.ClaimedID}
+	for name, value := range s.Attributes {
+		user.RawData[name] = value
+	}
+	user.Email = s.Attributes["email"]
+	user.NickName = s.Attributes["nickname"]
+	user.FirstName = s.Attributes["firstname"]
+	user.LastName = s.Attributes["lastname"]
+	user.Name = s.Attributes["fullname"]
+	if user.Name == "" {
+		user.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+	}
+	return user, nil
+}
+
+// RefreshToken refresh token is not provided by openid2
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by openid2")
+}
+
+// RefreshTokenAvailable refresh token is not provided by openid2
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
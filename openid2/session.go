@@ -0,0 +1,61 @@
+package openid2
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Session stores data during the auth process with an OpenID 2.0 provider.
+type Session struct {
+	AuthURL    string
+	ReturnTo   string
+	Endpoint   *Endpoint
+	ClaimedID  string
+	Attributes map[string]string
+}
+
+var _ goth.Session = &Session{}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` function on the OpenID 2.0 provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize the session by verifying the positive assertion of the OpenID
+// provider, and return its response nonce.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	if s.Endpoint == nil {
+		return "", errors.New("openid2: the session has no OP endpoint")
+	}
+	a, err := p.relyingParty().Verify(params, s.ReturnTo, s.Endpoint)
+	if err != nil {
+		return "", err
+	}
+	s.ClaimedID = a.ClaimedID
+	s.Attributes = profile(a)
+	return a.ResponseNonce, nil
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return goth.RedactJSON(s.Marshal())
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	s := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
+	return s, err
+}
@@ -0,0 +1,160 @@
+/*
+Package option is the functional options of the constructors of the
+providers, so the providers share the handling of the scopes, of the HTTP
+client and of the overrides of their endpoints instead of each implementing
+it slightly differently:
+
+	p := github.NewWithOptions(key, secret, callbackURL,
+		option.WithScopes("user:email"),
+		option.WithHTTPClient(client),
+		option.WithEndpoint(option.ProfileURL, "https://github.acme.com/api/v3/user"),
+		github.WithEmailURL("https://github.acme.com/api/v3/user/emails"),
+	)
+
+The providers create themselves from the Settings with Build:
+
+	func NewWithOptions(clientKey, secret, callbackURL string, opts ...option.Option) *Provider {
+		return option.Build(func(s *option.Settings) *Provider {
+			p := &Provider{ClientKey: clientKey, Secret: secret, CallbackURL: callbackURL, HTTPClient: s.HTTPClient, providerName: "example"}
+			p.config = newConfig(p, s.URL(option.AuthURL, AuthURL), s.URL(option.TokenURL, TokenURL), s.Scopes)
+			return p
+		}, opts...)
+	}
+
+and their own options with With.
+*/
+package option
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Endpoint names an endpoint of a provider.
+type Endpoint string
+
+// The endpoints of most providers. The providers may name others, e.g. the
+// endpoint of the emails of github.
+const (
+	AuthURL    Endpoint = "auth"
+	TokenURL   Endpoint = "token"
+	ProfileURL Endpoint = "profile"
+)
+
+// Settings are the settings of a provider the options change.
+type Settings struct {
+	// Scopes are the scopes requested, the default scopes of the provider
+	// when there are none.
+	Scopes []string
+	// HTTPClient is the client of the provider, goth.DefaultClient when it is
+	// nil.
+	HTTPClient *http.Client
+	// Name is the name of the provider, its default name when it is empty.
+	Name string
+	// BaseURL replaces the scheme and the host of the default endpoints,
+	// e.g. of a self-hosted GitLab.
+	BaseURL string
+	// Endpoints override the endpoints.
+	Endpoints map[Endpoint]string
+
+	custom []func(p interface{}) bool
+}
+
+// URL returns the URL of the endpoint: its override, or the default URL on
+// the BaseURL, when set.
+func (s *Settings) URL(endpoint Endpoint, defaultURL string) string {
+	if u, ok := s.Endpoints[endpoint]; ok {
+		return u
+	}
+	if s.BaseURL == "" {
+		return defaultURL
+	}
+	base, err := url.Parse(s.BaseURL)
+	if err != nil {
+		return defaultURL
+	}
+	u, err := url.Parse(defaultURL)
+	if err != nil {
+		return defaultURL
+	}
+	u.Scheme, u.Host = base.Scheme, base.Host
+	return u.String()
+}
+
+// Option changes the Settings of a provider.
+type Option func(s *Settings)
+
+// WithScopes adds the scopes requested.
+func WithScopes(scopes ...string) Option {
+	return func(s *Settings) {
+		s.Scopes = append(s.Scopes, scopes...)
+	}
+}
+
+// WithHTTPClient sets the client of the provider.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Settings) {
+		s.HTTPClient = client
+	}
+}
+
+// WithName sets the name of the provider, e.g. to use two providers of the
+// same type.
+func WithName(name string) Option {
+	return func(s *Settings) {
+		s.Name = name
+	}
+}
+
+// WithBaseURL replaces the scheme and the host of the default endpoints of
+// the provider, e.g. with https://gitlab.acme.com.
+func WithBaseURL(baseURL string) Option {
+	return func(s *Settings) {
+		s.BaseURL = baseURL
+	}
+}
+
+// WithEndpoint overrides the URL of an endpoint.
+func WithEndpoint(endpoint Endpoint, url string) Option {
+	return func(s *Settings) {
+		if s.Endpoints == nil {
+			s.Endpoints = map[Endpoint]string{}
+		}
+		s.Endpoints[endpoint] = url
+	}
+}
+
+// With returns an option of the providers of type P, applied once they are
+// created, e.g. github.WithEmailURL. Build panics when it is given to another
+// provider.
+func With[P any](apply func(p P)) Option {
+	return func(s *Settings) {
+		s.custom = append(s.custom, func(p interface{}) bool {
+			provider, ok := p.(P)
+			if ok {
+				apply(provider)
+			}
+			return ok
+		})
+	}
+}
+
+// Build creates a provider with newProvider from the settings of the
+// options, sets its Name, and applies its own options.
+func Build[P interface{ SetName(name string) }](newProvider func(s *Settings) P, opts ...Option) P {
+	s := &Settings{}
+	for _, opt := range opts {
+		opt(s)
+	}
+	p := newProvider(s)
+	if s.Name != "" {
+		p.SetName(s.Name)
+	}
+	for _, apply := range s.custom {
+		if !apply(p) {
+			panic(fmt.Sprintf("option: an option of another provider was given to %T", p))
+		}
+	}
+	return p
+}
@@ -0,0 +1,83 @@
+package option_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/andreimerlescu/goth/option"
+	"github.com/stretchr/testify/assert"
+)
+
+type provider struct {
+	name    string
+	scopes  []string
+	client  *http.Client
+	authURL string
+	prompt  string
+}
+
+func (p *provider) SetName(name string) {
+	p.name = name
+}
+
+func newProvider(opts ...option.Option) *provider {
+	return option.Build(func(s *option.Settings) *provider {
+		return &provider{
+			name:    "example",
+			scopes:  s.Scopes,
+			client:  s.HTTPClient,
+			authURL: s.URL(option.AuthURL, "https://example.com/oauth/authorize?x=1"),
+		}
+	}, opts...)
+}
+
+func withPrompt(prompt string) option.Option {
+	return option.With(func(p *provider) {
+		p.prompt = prompt
+	})
+}
+
+type otherProvider struct{}
+
+func (p *otherProvider) SetName(name string) {}
+
+func Test_Build(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := newProvider()
+	a.Equal("example", p.name)
+	a.Empty(p.scopes)
+	a.Nil(p.client)
+	a.Equal("https://example.com/oauth/authorize?x=1", p.authURL)
+
+	client := &http.Client{}
+	p = newProvider(
+		option.WithScopes("openid"),
+		option.WithScopes("email", "profile"),
+		option.WithHTTPClient(client),
+		option.WithName("example-eu"),
+		withPrompt("consent"),
+	)
+	a.Equal([]string{"openid", "email", "profile"}, p.scopes)
+	a.Same(client, p.client)
+	a.Equal("example-eu", p.name)
+	a.Equal("consent", p.prompt)
+
+	// the options of another provider are refused
+	a.PanicsWithValue("option: an option of another provider was given to *option_test.otherProvider", func() {
+		option.Build(func(s *option.Settings) *otherProvider { return &otherProvider{} }, withPrompt("consent"))
+	})
+}
+
+func Test_Settings_URL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := newProvider(option.WithBaseURL("http://127.0.0.1:8080"))
+	a.Equal("http://127.0.0.1:8080/oauth/authorize?x=1", p.authURL)
+
+	// the overrides win over the base URL
+	p = newProvider(option.WithBaseURL("http://127.0.0.1:8080"), option.WithEndpoint(option.AuthURL, "https://auth.example.com/authorize"))
+	a.Equal("https://auth.example.com/authorize", p.authURL)
+}
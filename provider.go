@@ -2,8 +2,11 @@ package goth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"sync"
 
 	"golang.org/x/oauth2"
 )
@@ -21,6 +24,71 @@ type Provider interface {
 	RefreshTokenAvailable() bool                             // Refresh token is provided by auth provider or not
 }
 
+// BeginAuthWithParamsProvider can be implemented by providers whose
+// authentication end-point depends on the request that starts the
+// authentication process, e.g. the shop a Shopify app is being installed on.
+// gothic passes the query parameters of that request, or its form values when
+// it is posted, to BeginAuthWithParams instead of calling BeginAuth.
+type BeginAuthWithParamsProvider interface {
+	Provider
+	BeginAuthWithParams(state string, params Params) (Session, error)
+}
+
+// MetadataProvider can be implemented by providers which publish metadata the
+// other party needs to trust the application, e.g. the service provider
+// metadata of SAML. gothic serves it with MetadataHandler.
+type MetadataProvider interface {
+	Provider
+	Metadata() (contentType string, metadata []byte, err error)
+}
+
+// AssertionProvider can be implemented by providers supporting the JWT bearer
+// grant of RFC 7523, which exchanges an assertion signed by the application
+// for tokens, e.g. with a Google service account. See the jwtbearer package
+// to sign the assertions.
+type AssertionProvider interface {
+	Provider
+	TokenFromAssertion(assertion string) (*oauth2.Token, error)
+}
+
+// HealthProvider is implemented by providers knowing the endpoint to request
+// to check whether they are reachable, e.g. the token endpoint of OpenID
+// Connect, see gothic.ProvidersHealthHandler.
+type HealthProvider interface {
+	Provider
+	HealthEndpoint() string
+}
+
+// ContextProvider can be implemented by providers fetching users with a
+// context, e.g. the one of the callback request, so their requests can be
+// canceled with it or traced. gothic calls FetchUserContext instead of
+// FetchUser.
+type ContextProvider interface {
+	Provider
+	FetchUserContext(ctx context.Context, session Session) (User, error)
+}
+
+// PrefetchProvider can be implemented by providers needing documents or keys
+// during the logins, e.g. the keys verifying the ID tokens of OpenID Connect,
+// so they are fetched before the first login. UseProviders, and GetProvider
+// once it constructed a provider of UseProviderFactory, call Prefetch, which
+// must fetch in the background without blocking.
+type PrefetchProvider interface {
+	Provider
+	Prefetch()
+}
+
+// LocalProvider is implemented by the providers whose users aren't fetched
+// from an issuer verifying their tokens, e.g. LDAP or SAML, which keep the
+// users verified during the login in their sessions, or the mock and guest
+// providers, which return their users whatever the tokens. Local reports
+// true: the access tokens of their sessions, if any, prove nothing and must
+// never be accepted as bearer tokens, see grpcauth.
+type LocalProvider interface {
+	Provider
+	Local() bool
+}
+
 const NoAuthUrlErrorMessage = "an AuthURL has not been set"
 
 // Providers is list of known/available providers.
@@ -32,38 +100,205 @@ var providers = Providers{}
 // Can be called multiple times. If you pass the same provider more
 // than once, the last will be used.
 func UseProviders(viders ...Provider) {
+	lazyMu.Lock()
+	defer lazyMu.Unlock()
 	for _, provider := range viders {
 		providers[provider.Name()] = provider
+		delete(lazyProviders, provider.Name())
+		prefetch(provider)
 	}
 }
 
-// GetProviders returns a list of all the providers currently in use.
+func prefetch(provider Provider) {
+	if p, ok := provider.(PrefetchProvider); ok {
+		p.Prefetch()
+	}
+}
+
+// GetProviders returns a copy of the list of all the providers currently in
+// use. The providers of UseProviderFactory are only listed once GetProvider
+// has constructed them, see ProviderNames.
 func GetProviders() Providers {
-	return providers
+	lazyMu.RLock()
+	defer lazyMu.RUnlock()
+	c := make(Providers, len(providers))
+	for name, provider := range providers {
+		c[name] = provider
+	}
+	return c
+}
+
+// ProviderNames returns the sorted names of all the providers in use,
+// including the providers of UseProviderFactory which aren't constructed
+// yet, e.g. to list the providers of a login page without constructing them.
+func ProviderNames() []string {
+	lazyMu.RLock()
+	defer lazyMu.RUnlock()
+	names := make([]string, 0, len(providers)+len(lazyProviders))
+	for name := range providers {
+		names = append(names, name)
+	}
+	for name := range lazyProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // GetProvider returns a previously created provider. If Goth has not
 // been told to use the named provider it will return an error.
+// The providers of UseProviderFactory are constructed on their first use,
+// and their construction errors are returned as a *ProviderFactoryError.
 func GetProvider(name string) (Provider, error) {
-	provider := providers[name]
-	if provider == nil {
-		return nil, fmt.Errorf("no provider for %s exists", name)
+	lazyMu.RLock()
+	provider, lazy := providers[name], lazyProviders[name]
+	lazyMu.RUnlock()
+	if provider != nil {
+		return provider, nil
 	}
+	if lazy == nil {
+		return nil, &ProviderNotFoundError{Name: name}
+	}
+	return lazy.get(name)
+}
+
+// ProviderFactory constructs a provider, see UseProviderFactory.
+type ProviderFactory func() (Provider, error)
+
+// UseProviderFactory uses the provider of the factory, which is constructed
+// on its first use by GetProvider, e.g. an OpenID Connect provider fetching
+// its discovery document when it is created: the applications configuring
+// dozens of providers don't fetch the documents and keys of the providers
+// nobody logs in with, and start when one of them is unreachable. The
+// provider is named name. When the factory fails, GetProvider returns a
+// *ProviderFactoryError, and the construction is retried on the next use.
+//
+//	goth.UseProviderFactory("openid-connect", func() (goth.Provider, error) {
+//		return openidConnect.New(key, secret, callbackURL, discoveryURL)
+//	})
+func UseProviderFactory(name string, factory ProviderFactory) {
+	lazyMu.Lock()
+	defer lazyMu.Unlock()
+	delete(providers, name)
+	lazyProviders[name] = &lazyProvider{factory: factory}
+}
+
+var (
+	lazyMu        sync.RWMutex
+	lazyProviders = map[string]*lazyProvider{}
+)
+
+// lazyProvider is a provider of UseProviderFactory, constructed once.
+type lazyProvider struct {
+	mu       sync.Mutex
+	factory  ProviderFactory
+	provider Provider
+}
+
+// get returns the provider, constructing it on the first call; the
+// concurrent first uses wait for the construction.
+func (l *lazyProvider) get(name string) (Provider, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.provider != nil {
+		return l.provider, nil
+	}
+	provider, err := l.factory()
+	if err == nil && provider == nil {
+		err = errors.New("the factory returned no provider")
+	}
+	if err != nil {
+		return nil, &ProviderFactoryError{Name: name, Err: err}
+	}
+	if provider.Name() != name {
+		provider.SetName(name)
+	}
+	l.provider = provider
+	prefetch(provider)
+
+	// the provider is used like the others from now on, unless it was
+	// replaced meanwhile
+	lazyMu.Lock()
+	if lazyProviders[name] == l {
+		providers[name] = provider
+		delete(lazyProviders, name)
+	}
+	lazyMu.Unlock()
 	return provider, nil
 }
 
+// ErrProviderUnavailable is matched by the errors of GetProvider with
+// errors.Is when the provider can't be constructed.
+var ErrProviderUnavailable = errors.New("provider unavailable")
+
+// ProviderFactoryError is returned by GetProvider when the factory of a
+// provider fails, see UseProviderFactory.
+type ProviderFactoryError struct {
+	Name string
+	Err  error
+}
+
+func (e *ProviderFactoryError) Error() string {
+	return fmt.Sprintf("the provider %s can't be created: %v", e.Name, e.Err)
+}
+
+// Unwrap returns the error of the factory.
+func (e *ProviderFactoryError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrProviderUnavailable.
+func (e *ProviderFactoryError) Is(target error) bool {
+	return target == ErrProviderUnavailable
+}
+
+// ErrProviderNotFound is matched by the errors of GetProvider with
+// errors.Is.
+var ErrProviderNotFound = errors.New("provider not found")
+
+// ProviderNotFoundError is returned by GetProvider for the providers which
+// aren't used.
+type ProviderNotFoundError struct {
+	Name string
+}
+
+func (e *ProviderNotFoundError) Error() string {
+	return fmt.Sprintf("no provider for %s exists", e.Name)
+}
+
+// Is reports whether target is ErrProviderNotFound.
+func (e *ProviderNotFoundError) Is(target error) bool {
+	return target == ErrProviderNotFound
+}
+
 // ClearProviders will remove all providers currently in use.
 // This is useful, mostly, for testing purposes.
 func ClearProviders() {
+	lazyMu.Lock()
+	defer lazyMu.Unlock()
 	providers = Providers{}
+	lazyProviders = map[string]*lazyProvider{}
+}
+
+// UnregisterProviders removes the named providers, including the providers
+// of UseProviderFactory. This is useful, mostly, for testing purposes.
+func UnregisterProviders(names ...string) {
+	lazyMu.Lock()
+	defer lazyMu.Unlock()
+	for _, name := range names {
+		delete(providers, name)
+		delete(lazyProviders, name)
+	}
 }
 
 // ContextForClient provides a context for use with oauth2.
 func ContextForClient(h *http.Client) context.Context {
-	if h == nil {
-		return oauth2.NoContext
-	}
-	return context.WithValue(oauth2.NoContext, oauth2.HTTPClient, h)
+	return ContextWithClient(oauth2.NoContext, h)
+}
+
+// ContextWithClient is like ContextForClient, but derives the context from ctx.
+func ContextWithClient(ctx context.Context, h *http.Client) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, HTTPClientWithFallBack(h))
 }
 
 // HTTPClientWithFallBack to be used in all fetch operations.
@@ -71,5 +306,8 @@ func HTTPClientWithFallBack(h *http.Client) *http.Client {
 	if h != nil {
 		return h
 	}
-	return http.DefaultClient
+	if DefaultClient != nil {
+		return DefaultClient
+	}
+	return sharedClient
 }
@@ -1,10 +1,17 @@
 package goth_test
 
 import (
+	"errors"
+	"go/build"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/andreimerlescu/goth"
 	"github.com/andreimerlescu/goth/providers/faux"
+	"github.com/andreimerlescu/goth/providers/mock"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,5 +38,177 @@ func Test_GetProvider(t *testing.T) {
 	_, err = goth.GetProvider("unknown")
 	a.Error(err)
 	a.Equal(err.Error(), "no provider for unknown exists")
+	a.True(errors.Is(err, goth.ErrProviderNotFound))
 	goth.ClearProviders()
 }
+
+func Test_UseProviderFactory(t *testing.T) {
+	a := assert.New(t)
+	defer goth.ClearProviders()
+
+	calls := 0
+	fail := errors.New("discovery unreachable")
+	goth.UseProviderFactory("lazy", func() (goth.Provider, error) {
+		calls++
+		if calls == 1 {
+			return nil, fail
+		}
+		return mock.New(), nil
+	})
+	a.Equal(0, calls)
+	a.Empty(goth.GetProviders())
+	a.Equal([]string{"lazy"}, goth.ProviderNames())
+
+	// the construction errors are returned, and retried
+	_, err := goth.GetProvider("lazy")
+	a.ErrorIs(err, fail)
+	a.ErrorIs(err, goth.ErrProviderUnavailable)
+	a.False(errors.Is(err, goth.ErrProviderNotFound))
+	a.Equal("the provider lazy can't be created: discovery unreachable", err.Error())
+
+	p, err := goth.GetProvider("lazy")
+	a.NoError(err)
+	a.Equal("lazy", p.Name())
+	again, err := goth.GetProvider("lazy")
+	a.NoError(err)
+	a.Same(p, again)
+	a.Equal(2, calls)
+
+	// the providers constructed are listed
+	a.Len(goth.GetProviders(), 1)
+	a.Same(p, goth.GetProviders()["lazy"])
+	a.Equal([]string{"lazy"}, goth.ProviderNames())
+
+	// the providers replace each other
+	goth.UseProviders(&faux.Provider{})
+	goth.UseProviderFactory("faux", func() (goth.Provider, error) { return nil, nil })
+	_, err = goth.GetProvider("faux")
+	a.ErrorIs(err, goth.ErrProviderUnavailable)
+	goth.UseProviders(&faux.Provider{})
+	_, err = goth.GetProvider("faux")
+	a.NoError(err)
+
+	// the list is a copy, the providers are removed with UnregisterProviders
+	delete(goth.GetProviders(), "faux")
+	a.Contains(goth.GetProviders(), "faux")
+	goth.UseProviderFactory("other", func() (goth.Provider, error) { return mock.New(), nil })
+	goth.UnregisterProviders("faux", "other")
+	a.Equal([]string{"lazy"}, goth.ProviderNames())
+}
+
+func Test_LocalProvider(t *testing.T) {
+	a := assert.New(t)
+	for _, p := range []goth.Provider{mock.New(), &faux.Provider{}} {
+		lp, ok := p.(goth.LocalProvider)
+		a.True(ok)
+		a.True(lp.Local())
+	}
+}
+
+func Test_UseProviderFactory_Concurrent(t *testing.T) {
+	a := assert.New(t)
+	defer goth.ClearProviders()
+
+	var mu sync.Mutex
+	calls := 0
+	goth.UseProviderFactory("mock", func() (goth.Provider, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		return mock.New(), nil
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := goth.GetProvider("mock")
+			a.NoError(err)
+			a.Equal([]string{"mock"}, goth.ProviderNames())
+		}()
+	}
+	wg.Wait()
+	a.Equal(1, calls)
+	a.Contains(goth.GetProviders(), "mock")
+}
+
+// prefetchProvider counts the calls of Prefetch.
+type prefetchProvider struct {
+	*mock.Provider
+	prefetched int
+}
+
+func (p *prefetchProvider) Prefetch() {
+	p.prefetched++
+}
+
+func Test_UseProviders_Prefetch(t *testing.T) {
+	a := assert.New(t)
+	defer goth.ClearProviders()
+
+	p := &prefetchProvider{Provider: mock.New()}
+	goth.UseProviders(p)
+	a.Equal(1, p.prefetched)
+
+	lazy := &prefetchProvider{Provider: mock.New()}
+	goth.UseProviderFactory("lazy", func() (goth.Provider, error) { return lazy, nil })
+	a.Equal(0, lazy.prefetched)
+	_, err := goth.GetProvider("lazy")
+	a.NoError(err)
+	_, err = goth.GetProvider("lazy")
+	a.NoError(err)
+	a.Equal(1, lazy.prefetched)
+}
+
+// Test_ProviderImports keeps the binaries from compiling the providers they
+// don't import: the providers don't import each other, and the packages of
+// goth only import the providers they are built on.
+func Test_ProviderImports(t *testing.T) {
+	a := assert.New(t)
+	const providers = "github.com/andreimerlescu/goth/providers/"
+	builtOn := map[string]string{
+		"oidfed": providers + "openidConnect",
+	}
+
+	err := filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		path = filepath.ToSlash(path)
+		if path == "examples" || path == "cmd" || d.Name() == "testdata" {
+			return filepath.SkipDir
+		}
+		pkg, err := build.ImportDir(path, 0)
+		if _, ok := err.(*build.NoGoError); ok {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, imported := range pkg.Imports {
+			if !strings.HasPrefix(imported, providers) || imported == builtOn[path] {
+				continue
+			}
+			if strings.HasPrefix(path, "providers/") {
+				a.Fail("a provider imports another provider", "%s imports %s", path, imported)
+			} else {
+				a.Fail("a package imports a provider", "%s imports %s", path, imported)
+			}
+		}
+		return nil
+	})
+	a.NoError(err)
+
+	// the goth command only compiles the providers of its tags
+	ctx := build.Default
+	ctx.BuildTags = []string{"goth_providers", "goth_github"}
+	pkg, err := ctx.ImportDir("cmd/goth", 0)
+	a.NoError(err)
+	var imported []string
+	for _, path := range pkg.Imports {
+		if strings.HasPrefix(path, providers) {
+			imported = append(imported, path)
+		}
+	}
+	a.Equal([]string{providers + "github"}, imported)
+}
@@ -9,7 +9,6 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"net/url"
 
 	"github.com/andreimerlescu/goth"
 	"golang.org/x/oauth2"
@@ -21,6 +20,19 @@ const (
 	endpointProfile string = "https://api.amazon.com/user/profile"
 )
 
+// These are the customer profile scopes of Login with Amazon.
+// See https://developer.amazon.com/docs/login-with-amazon/customer-profile.html
+const (
+	// ScopeProfile grants access to the user id, name and email address of the customer.
+	ScopeProfile string = "profile"
+	// ScopeProfileUserID grants access to the user id of the customer only. It
+	// is enough for account linking, e.g. for Alexa skills.
+	ScopeProfileUserID string = "profile:user_id"
+	// ScopePostalCode grants access to the postal code of the customer's
+	// primary address.
+	ScopePostalCode string = "postal_code"
+)
+
 // Provider is the implementation of `goth.Provider` for accessing Amazon.
 type Provider struct {
 	ClientKey    string
@@ -45,6 +57,7 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 	return p
 }
 
+// Client returns an HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -59,6 +72,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 // Debug is a no-op for the amazon package.
 func (p *Provider) Debug(debug bool) {}
 
@@ -84,8 +107,13 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	response, err := goth.HTTPClientWithFallBack(p.Client()).Get(endpointProfile + "?access_token=" + url.QueryEscape(sess.AccessToken))
-
+	req, err := http.NewRequest("GET", endpointProfile, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	req.Header.Set("Accept", "application/json")
+	response, err := p.Client().Do(req)
 	if err != nil {
 		return user, err
 	}
@@ -117,6 +145,8 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  authURL,
 			TokenURL: tokenURL,
+			// Login with Amazon documents the client credentials in the request body
+			AuthStyle: oauth2.AuthStyleInParams,
 		},
 		Scopes: []string{},
 	}
@@ -126,7 +156,7 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 			c.Scopes = append(c.Scopes, scope)
 		}
 	} else {
-		c.Scopes = append(c.Scopes, "profile", "postal_code")
+		c.Scopes = append(c.Scopes, ScopeProfile, ScopePostalCode)
 	}
 	return c
 }
@@ -150,6 +180,14 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	return nil
 }
 
+// PostalCode returns the postal code of the primary address of a user fetched
+// by this provider. It is only returned by Amazon when the postal_code scope
+// was granted, and empty otherwise.
+func PostalCode(user goth.User) string {
+	postalCode, _ := user.RawData["postal_code"].(string)
+	return postalCode
+}
+
 // RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
 	return true
@@ -1,6 +1,12 @@
 package amazon_test
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -35,6 +41,53 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "www.amazon.com/ap/oa")
 }
 
+func Test_BeginAuthScopes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	session, err := provider().BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*amazon.Session).AuthURL, "scope=profile+postal_code")
+
+	p := amazon.New(os.Getenv("AMAZON_KEY"), os.Getenv("AMAZON_SECRET"), "/foo", amazon.ScopeProfileUserID)
+	session, err = p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*amazon.Session).AuthURL, "scope=profile%3Auser_id")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/user/profile" || req.Header.Get("Authorization") != "Bearer 1234567890" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(res, `{"user_id":"amzn1.account.K2LI23KL2LK2","email":"mhashimoto-04@plaxo.com","name":"Mork Hashimoto","postal_code":"98052"}`)
+	}))
+	defer server.Close()
+
+	p := provider()
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	user, err := p.FetchUser(&amazon.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("amzn1.account.K2LI23KL2LK2", user.UserID)
+	a.Equal("Mork Hashimoto", user.Name)
+	a.Equal("mhashimoto-04@plaxo.com", user.Email)
+	a.Equal("98052", user.Location)
+	a.Equal("98052", amazon.PostalCode(user))
+	a.Empty(amazon.PostalCode(goth.User{}))
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
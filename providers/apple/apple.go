@@ -13,8 +13,9 @@ import (
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/jwks"
+	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/oauth2"
 )
 
@@ -37,6 +38,7 @@ type Provider struct {
 	httpClient           *http.Client
 	formPostResponseMode bool
 	timeNowFn            func() time.Time
+	keys                 *jwks.Cache
 }
 
 func New(clientId, secret, redirectURL string, httpClient *http.Client, scopes ...string) *Provider {
@@ -48,6 +50,7 @@ func New(clientId, secret, redirectURL string, httpClient *http.Client, scopes .
 	}
 	p.configure(scopes)
 	p.httpClient = httpClient
+	p.keys = jwks.New(idTokenVerificationKeyEndpoint, httpClient)
 	return p
 }
 
@@ -59,6 +62,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 func (p Provider) ClientId() string {
 	return p.clientId
 }
@@ -143,6 +156,20 @@ func (p Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}, nil
 }
 
+// Prefetch fetches the keys verifying the identity tokens in the background,
+// so the logins don't wait for them, see goth.PrefetchProvider.
+func (p Provider) Prefetch() {
+	p.keySet().Prefetch()
+}
+
+// keySet returns the keys verifying the identity tokens.
+func (p Provider) keySet() *jwks.Cache {
+	if p.keys == nil {
+		return jwks.New(idTokenVerificationKeyEndpoint, p.httpClient)
+	}
+	return p.keys
+}
+
 // Debug is a no-op for the apple package.
 func (Provider) Debug(bool) {}
 
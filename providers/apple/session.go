@@ -10,9 +10,9 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/jwks"
+	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/oauth2"
 )
 
@@ -63,7 +63,7 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 		oauth2.SetAuthURLParam("client_id", p.clientId),
 		oauth2.SetAuthURLParam("client_secret", p.secret),
 	}
-	token, err := p.config.Exchange(context.Background(), params.Get("code"), opts...)
+	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"), opts...)
 	if err != nil {
 		return "", err
 	}
@@ -78,7 +78,6 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 
 	if idToken := token.Extra("id_token"); idToken != nil {
 		idToken, err := jwt.ParseWithClaims(idToken.(string), &IDTokenClaims{}, func(t *jwt.Token) (interface{}, error) {
-			kid := t.Header["kid"].(string)
 			claims := t.Claims.(*IDTokenClaims)
 			validator := jwt.NewValidator(jwt.WithAudience(p.clientId), jwt.WithIssuer(AppleAudOrIss))
 			err := validator.Validate(claims)
@@ -94,22 +93,22 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 				return nil, fmt.Errorf(`identity token invalid`)
 			}
 
-			// get the public key for verifying the identity token signature
-			set, err := jwk.Fetch(context.Background(), idTokenVerificationKeyEndpoint, jwk.WithHTTPClient(p.Client()))
-			if err != nil {
-				return nil, err
-			}
-			selectedKey, found := set.LookupKeyID(kid)
-			if !found {
+			// get the public key for verifying the identity token signature,
+			// prefetched unless Apple rotated its keys
+			kid, _ := t.Header["kid"].(string)
+			key, err := p.keySet().Key(context.Background(), kid)
+			if errors.Is(err, jwks.ErrKeyNotFound) {
 				return nil, errors.New("could not find matching public key")
 			}
-			pubKey := &rsa.PublicKey{}
-			err = selectedKey.Raw(pubKey)
 			if err != nil {
 				return nil, err
 			}
+			pubKey, ok := key.(*rsa.PublicKey)
+			if !ok {
+				return nil, errors.New("could not find matching public key")
+			}
 			return pubKey, nil
-		})
+		}, jwt.WithValidMethods(goth.JWTAlgorithms("RS256")))
 		if err != nil {
 			return "", err
 		}
@@ -125,7 +124,7 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 }
 
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // BoolString is a type that can be unmarshalled from a JSON field that can be either a boolean or a string.
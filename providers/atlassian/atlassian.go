@@ -0,0 +1,267 @@
+// Package atlassian implements the OAuth 2.0 (3LO) protocol for authenticating users through
+// Atlassian Cloud, and lists the Jira and Confluence sites the user granted access to.
+// This package can be used as a reference implementation of an OAuth2 provider for Goth.
+package atlassian
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// See https://developer.atlassian.com/cloud/jira/platform/oauth-2-3lo-apps/
+const (
+	authURL                    string = "https://auth.atlassian.com/authorize"
+	tokenURL                   string = "https://auth.atlassian.com/oauth/token"
+	endpointProfile            string = "https://api.atlassian.com/me"
+	endpointAccessibleResource string = "https://api.atlassian.com/oauth/token/accessible-resources"
+	apiURL                     string = "https://api.atlassian.com/ex/"
+)
+
+// Scopes
+const (
+	ScopeReadMe                string = "read:me"
+	ScopeOfflineAccess         string = "offline_access"
+	ScopeReadJiraUser          string = "read:jira-user"
+	ScopeReadJiraWork          string = "read:jira-work"
+	ScopeWriteJiraWork         string = "write:jira-work"
+	ScopeReadConfluenceContent string = "read:confluence-content.all"
+	ScopeReadConfluenceSpace   string = "read:confluence-space.summary"
+)
+
+// Resource is an Atlassian Cloud site, such as a Jira or Confluence instance,
+// the access token can be used with.
+type Resource struct {
+	// ID is the cloud ID that API requests are routed with.
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	AvatarURL string   `json:"avatarUrl"`
+}
+
+// APIURL returns the base URL of the REST API of a product, e.g. "jira" or
+// "confluence", on this site.
+func (r Resource) APIURL(product string) string {
+	return apiURL + product + "/" + r.ID
+}
+
+// Provider is the implementation of `goth.Provider` for accessing Atlassian.
+type Provider struct {
+	ClientKey    string
+	Secret       string
+	CallbackURL  string
+	HTTPClient   *http.Client
+	config       *oauth2.Config
+	providerName string
+}
+
+// New creates a new Atlassian provider and sets up important connection details.
+// You should always call `atlassian.New` to get a new provider.  Never try to
+// create one manually.
+func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "atlassian",
+	}
+	p.config = newConfig(p, scopes)
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the atlassian package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks Atlassian for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	url := p.config.AuthCodeURL(state,
+		oauth2.SetAuthURLParam("audience", "api.atlassian.com"),
+		oauth2.SetAuthURLParam("prompt", "consent"),
+	)
+	return &Session{
+		AuthURL: url,
+	}, nil
+}
+
+// FetchUser will go to Atlassian and access basic information about the user,
+// along with the sites the access token can be used with.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		Provider:     p.Name(),
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	resp, err := p.get(endpointProfile, sess.AccessToken)
+	if err != nil {
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+	}
+
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+
+	err = userFromReader(bytes.NewReader(bits), &user)
+	if err != nil {
+		return user, err
+	}
+
+	resources, err := p.AccessibleResources(sess.AccessToken)
+	if err != nil {
+		return user, err
+	}
+	user.RawData["accessible_resources"] = resources
+	return user, nil
+}
+
+// AccessibleResources lists the sites the access token can be used with. The
+// ID of a site is the cloud ID every API request has to be routed with.
+func (p *Provider) AccessibleResources(accessToken string) ([]Resource, error) {
+	resp, err := p.get(endpointAccessibleResource, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to fetch accessible resources", p.providerName, resp.StatusCode)
+	}
+
+	var resources []Resource
+	err = json.NewDecoder(resp.Body).Decode(&resources)
+	if err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// Resources returns the sites a user fetched by this provider granted access to.
+func Resources(user goth.User) []Resource {
+	resources, _ := user.RawData["accessible_resources"].([]Resource)
+	return resources
+}
+
+func (p *Provider) get(endpoint, accessToken string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	return p.Client().Do(req)
+}
+
+func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   authURL,
+			TokenURL:  tokenURL,
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+		Scopes: []string{},
+	}
+
+	if len(scopes) > 0 {
+		c.Scopes = append(c.Scopes, scopes...)
+	} else {
+		c.Scopes = append(c.Scopes, ScopeReadMe, ScopeOfflineAccess)
+	}
+	return c
+}
+
+func userFromReader(r io.Reader, user *goth.User) error {
+	u := struct {
+		AccountID       string `json:"account_id"`
+		Email           string `json:"email"`
+		Name            string `json:"name"`
+		Nickname        string `json:"nickname"`
+		Picture         string `json:"picture"`
+		ExtendedProfile struct {
+			Location string `json:"location"`
+		} `json:"extended_profile"`
+	}{}
+
+	err := json.NewDecoder(r).Decode(&u)
+	if err != nil {
+		return err
+	}
+
+	user.UserID = u.AccountID
+	user.Email = u.Email
+	user.Name = u.Name
+	user.NickName = u.Nickname
+	user.AvatarURL = u.Picture
+	user.Location = u.ExtendedProfile.Location
+	return nil
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token. Atlassian
+// rotates refresh tokens, so the returned refresh token replaces the old one.
+// A refresh token is only issued with the offline_access scope.
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
+	newToken, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return newToken, err
+}
@@ -0,0 +1,108 @@
+package atlassian_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/atlassian"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.ClientKey, os.Getenv("ATLASSIAN_KEY"))
+	a.Equal(p.Secret, os.Getenv("ATLASSIAN_SECRET"))
+	a.Equal(p.CallbackURL, "/foo")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	session, err := p.BeginAuth("test_state")
+	s := session.(*atlassian.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "https://auth.atlassian.com/authorize")
+	a.Contains(s.AuthURL, "audience=api.atlassian.com")
+	a.Contains(s.AuthURL, "prompt=consent")
+	a.Contains(s.AuthURL, "scope=read%3Ame+offline_access")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer 1234567890" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch req.URL.Path {
+		case "/me":
+			fmt.Fprint(res, `{"account_type":"atlassian","account_id":"112233aa-bb11-cc22-33dd-445566abcabc","email":"mia@example.com","name":"Mia Krystof","picture":"https://avatar-management--avatars.us-west-2.prod.public.atl-paas.net/default.png","account_status":"active","nickname":"mkrystof","zoneinfo":"Australia/Sydney","locale":"en-US","extended_profile":{"job_title":"Designer","location":"Sydney"}}`)
+		case "/oauth/token/accessible-resources":
+			fmt.Fprint(res, `[{"id":"1324a887-45db-1bf4-1e99-ef0ff456d421","name":"Site name","url":"https://your-domain.atlassian.net","scopes":["write:jira-work","read:jira-user"],"avatarUrl":"https://site-admin-avatar-cdn.prod.public.atl-paas.net/avatars/240/flag.png"}]`)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := provider()
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	user, err := p.FetchUser(&atlassian.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("112233aa-bb11-cc22-33dd-445566abcabc", user.UserID)
+	a.Equal("mia@example.com", user.Email)
+	a.Equal("Mia Krystof", user.Name)
+	a.Equal("mkrystof", user.NickName)
+	a.Equal("Sydney", user.Location)
+
+	resources := atlassian.Resources(user)
+	a.Len(resources, 1)
+	a.Equal("1324a887-45db-1bf4-1e99-ef0ff456d421", resources[0].ID)
+	a.Equal("https://your-domain.atlassian.net", resources[0].URL)
+	a.Equal([]string{"write:jira-work", "read:jira-user"}, resources[0].Scopes)
+	a.Equal("https://api.atlassian.com/ex/jira/1324a887-45db-1bf4-1e99-ef0ff456d421", resources[0].APIURL("jira"))
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://auth.atlassian.com/authorize","AccessToken":"1234567890"}`)
+	a.NoError(err)
+
+	s := session.(*atlassian.Session)
+	a.Equal(s.AuthURL, "https://auth.atlassian.com/authorize")
+	a.Equal(s.AccessToken, "1234567890")
+}
+
+func provider() *atlassian.Provider {
+	return atlassian.New(os.Getenv("ATLASSIAN_KEY"), os.Getenv("ATLASSIAN_SECRET"), "/foo")
+}
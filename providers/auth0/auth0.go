@@ -64,6 +64,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -174,7 +184,7 @@ func (p *Provider) RefreshTokenAvailable() bool {
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
 	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(oauth2.NoContext, token)
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
 	newToken, err := ts.Token()
 	if err != nil {
 		return nil, err
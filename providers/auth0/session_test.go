@@ -45,4 +45,8 @@ func Test_String(t *testing.T) {
 	s := &auth0.Session{}
 
 	a.Equal(s.String(), s.Marshal())
+
+	s.AccessToken = "at-secret"
+	a.NotContains(s.String(), "at-secret")
+	a.Contains(s.Marshal(), "at-secret")
 }
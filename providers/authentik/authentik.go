@@ -0,0 +1,316 @@
+// Package authentik implements the OpenID Connect protocol for authenticating users through authentik.
+// This package can be used as a reference implementation of an OAuth2 provider for Goth.
+package authentik
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// GroupsClaim is the claim authentik uses to list the groups a user belongs to.
+const GroupsClaim = "groups"
+
+// Provider is the implementation of `goth.Provider` for accessing authentik.
+type Provider struct {
+	ClientKey    string
+	Secret       string
+	CallbackURL  string
+	HTTPClient   *http.Client
+	OpenIDConfig *OpenIDConfig
+	config       *oauth2.Config
+	providerName string
+
+	// GroupsClaim is the userinfo claim that is mapped into the "groups" key of
+	// the user's RawData. It defaults to authentik's own "groups" claim.
+	GroupsClaim string
+}
+
+// OpenIDConfig holds the endpoints advertised by the authentik application.
+type OpenIDConfig struct {
+	AuthEndpoint       string `json:"authorization_endpoint"`
+	TokenEndpoint      string `json:"token_endpoint"`
+	UserInfoEndpoint   string `json:"userinfo_endpoint"`
+	EndSessionEndpoint string `json:"end_session_endpoint,omitempty"`
+	Issuer             string `json:"issuer"`
+}
+
+// New creates a new authentik provider for the application identified by slug on
+// the authentik instance at baseURL, and sets up important connection details by
+// querying the application's discovery document.
+// You should always call `authentik.New` to get a new provider. Never try to
+// create one manually.
+func New(clientKey, secret, callbackURL, baseURL, slug string, scopes ...string) (*Provider, error) {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		GroupsClaim:  GroupsClaim,
+		providerName: "authentik",
+	}
+
+	openIDConfig, err := getOpenIDConfig(p, DiscoveryURL(baseURL, slug))
+	if err != nil {
+		return nil, err
+	}
+	p.OpenIDConfig = openIDConfig
+	p.config = newConfig(p, scopes)
+	return p, nil
+}
+
+// NewCustomisedURL is similar to New(...) but can be used to set custom URLs hence omit the discovery step
+func NewCustomisedURL(clientKey, secret, callbackURL, authURL, tokenURL, issuerURL, userInfoURL, endSessionURL string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:   clientKey,
+		Secret:      secret,
+		CallbackURL: callbackURL,
+		GroupsClaim: GroupsClaim,
+		OpenIDConfig: &OpenIDConfig{
+			AuthEndpoint:       authURL,
+			TokenEndpoint:      tokenURL,
+			Issuer:             issuerURL,
+			UserInfoEndpoint:   userInfoURL,
+			EndSessionEndpoint: endSessionURL,
+		},
+		providerName: "authentik",
+	}
+	p.config = newConfig(p, scopes)
+	return p
+}
+
+// DiscoveryURL returns the OpenID discovery URL of the application identified by
+// slug on the authentik instance at baseURL.
+func DiscoveryURL(baseURL, slug string) string {
+	return fmt.Sprintf("%s/application/o/%s/.well-known/openid-configuration", strings.TrimSuffix(baseURL, "/"), slug)
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the authentik package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks authentik for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state),
+	}, nil
+}
+
+// FetchUser will go to authentik and access basic information about the user.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		Provider:     p.Name(),
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+		IDToken:      sess.IDToken,
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	req, err := http.NewRequest("GET", p.OpenIDConfig.UserInfoEndpoint, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	response, err := p.Client().Do(req)
+	if err != nil {
+		if response != nil {
+			response.Body.Close()
+		}
+		return user, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
+	}
+
+	bits, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+
+	err = userFromReader(bytes.NewReader(bits), &user)
+	if err != nil {
+		return user, err
+	}
+
+	user.RawData[GroupsClaim] = groupsFromClaims(user.RawData, p.GroupsClaim)
+	return user, nil
+}
+
+// Groups returns the authentik groups of a user fetched by this provider.
+func Groups(user goth.User) []string {
+	groups, _ := user.RawData[GroupsClaim].([]string)
+	return groups
+}
+
+// EndSessionURL returns the URL the user should be sent to in order to end their
+// authentik session. The idToken is passed as a hint so authentik can skip the
+// logout confirmation, and postLogoutRedirectURL (if set) is where the user ends up.
+func (p *Provider) EndSessionURL(idToken, postLogoutRedirectURL string) (string, error) {
+	if p.OpenIDConfig.EndSessionEndpoint == "" {
+		return "", errors.New("authentik: no end_session_endpoint has been configured")
+	}
+
+	u, err := url.Parse(p.OpenIDConfig.EndSessionEndpoint)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	if idToken != "" {
+		q.Set("id_token_hint", idToken)
+	}
+	if postLogoutRedirectURL != "" {
+		q.Set("post_logout_redirect_uri", postLogoutRedirectURL)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func getOpenIDConfig(p *Provider, discoveryURL string) (*OpenIDConfig, error) {
+	res, err := p.Client().Get(discoveryURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("Non-success code for Discovery URL: %d", res.StatusCode)
+	}
+
+	openIDConfig := &OpenIDConfig{}
+	err = json.NewDecoder(res.Body).Decode(openIDConfig)
+	if err != nil {
+		return nil, err
+	}
+	return openIDConfig, nil
+}
+
+func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  provider.OpenIDConfig.AuthEndpoint,
+			TokenURL: provider.OpenIDConfig.TokenEndpoint,
+		},
+		Scopes: []string{},
+	}
+
+	if len(scopes) > 0 {
+		foundOpenIDScope := false
+		for _, scope := range scopes {
+			if scope == "openid" {
+				foundOpenIDScope = true
+			}
+			c.Scopes = append(c.Scopes, scope)
+		}
+		if !foundOpenIDScope {
+			c.Scopes = append(c.Scopes, "openid")
+		}
+	} else {
+		c.Scopes = []string{"openid", "profile", "email"}
+	}
+	return c
+}
+
+func userFromReader(r io.Reader, user *goth.User) error {
+	u := struct {
+		ID        string `json:"sub"`
+		Email     string `json:"email"`
+		Name      string `json:"name"`
+		FirstName string `json:"given_name"`
+		LastName  string `json:"family_name"`
+		NickName  string `json:"nickname"`
+		Username  string `json:"preferred_username"`
+		Picture   string `json:"picture"`
+	}{}
+
+	err := json.NewDecoder(r).Decode(&u)
+	if err != nil {
+		return err
+	}
+
+	user.UserID = u.ID
+	user.Email = u.Email
+	user.Name = u.Name
+	user.FirstName = u.FirstName
+	user.LastName = u.LastName
+	user.NickName = u.Username
+	if user.NickName == "" {
+		user.NickName = u.NickName
+	}
+	user.AvatarURL = u.Picture
+	return nil
+}
+
+func groupsFromClaims(claims map[string]interface{}, claim string) []string {
+	var groups []string
+	values, _ := claims[claim].([]interface{})
+	for _, value := range values {
+		if s, ok := value.(string); ok && s != "" {
+			groups = append(groups, s)
+		}
+	}
+	return groups
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
+	newToken, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return newToken, err
+}
@@ -0,0 +1,138 @@
+package authentik_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/authentik"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	server := authentikServer()
+	defer server.Close()
+
+	p, err := authentik.New(os.Getenv("AUTHENTIK_KEY"), os.Getenv("AUTHENTIK_SECRET"), "/foo", server.URL+"/", "goth")
+	a.NoError(err)
+	a.Equal(p.ClientKey, os.Getenv("AUTHENTIK_KEY"))
+	a.Equal(p.Secret, os.Getenv("AUTHENTIK_SECRET"))
+	a.Equal(p.CallbackURL, "/foo")
+	a.Equal(server.URL+"/application/o/authorize/", p.OpenIDConfig.AuthEndpoint)
+	a.Equal(server.URL+"/application/o/goth/end-session/", p.OpenIDConfig.EndSessionEndpoint)
+}
+
+func Test_NewUnknownApplication(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	server := authentikServer()
+	defer server.Close()
+
+	_, err := authentik.New("key", "secret", "/foo", server.URL, "unknown")
+	a.Error(err)
+}
+
+func Test_Name(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Equal(provider().Name(), "authentik")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	session, err := p.BeginAuth("test_state")
+	s := session.(*authentik.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "https://auth.example.com/application/o/authorize/")
+	a.Contains(s.AuthURL, "scope=openid+profile+email")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	server := authentikServer()
+	defer server.Close()
+
+	p, err := authentik.New("key", "secret", "/foo", server.URL, "goth")
+	a.NoError(err)
+
+	user, err := p.FetchUser(&authentik.Session{AccessToken: "1234567890", IDToken: "id-token"})
+	a.NoError(err)
+	a.Equal("8b5dc1a0", user.UserID)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("homer", user.NickName)
+	a.Equal("id-token", user.IDToken)
+	a.Equal([]string{"admins", "nuclear-plant"}, authentik.Groups(user))
+}
+
+func Test_EndSessionURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	u, err := p.EndSessionURL("id-token", "http://localhost/bye")
+	a.NoError(err)
+	a.Contains(u, "https://auth.example.com/application/o/goth/end-session/?")
+	a.Contains(u, "id_token_hint=id-token")
+	a.Contains(u, "post_logout_redirect_uri=http%3A%2F%2Flocalhost%2Fbye")
+
+	p.OpenIDConfig.EndSessionEndpoint = ""
+	_, err = p.EndSessionURL("id-token", "")
+	a.Error(err)
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://auth.example.com/application/o/authorize/","AccessToken":"1234567890","IDToken":"abc"}`)
+	a.NoError(err)
+
+	s := session.(*authentik.Session)
+	a.Equal(s.AuthURL, "https://auth.example.com/application/o/authorize/")
+	a.Equal(s.AccessToken, "1234567890")
+	a.Equal(s.IDToken, "abc")
+}
+
+func provider() *authentik.Provider {
+	return authentik.NewCustomisedURL(os.Getenv("AUTHENTIK_KEY"), os.Getenv("AUTHENTIK_SECRET"), "/foo",
+		"https://auth.example.com/application/o/authorize/",
+		"https://auth.example.com/application/o/token/",
+		"https://auth.example.com/application/o/goth/",
+		"https://auth.example.com/application/o/userinfo/",
+		"https://auth.example.com/application/o/goth/end-session/")
+}
+
+func authentikServer() *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/application/o/goth/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer":"%[1]s/application/o/goth/","authorization_endpoint":"%[1]s/application/o/authorize/","token_endpoint":"%[1]s/application/o/token/","userinfo_endpoint":"%[1]s/application/o/userinfo/","end_session_endpoint":"%[1]s/application/o/goth/end-session/"}`, server.URL)
+		case "/application/o/userinfo/":
+			if r.Header.Get("Authorization") != "Bearer 1234567890" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"sub":"8b5dc1a0","email":"homer@example.com","name":"Homer Simpson","given_name":"Homer","preferred_username":"homer","groups":["admins","nuclear-plant"]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return server
+}
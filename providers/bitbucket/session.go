@@ -57,5 +57,5 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 }
 
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
@@ -3,10 +3,14 @@
 package box
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/andreimerlescu/goth"
 	"golang.org/x/oauth2"
@@ -15,9 +19,13 @@ import (
 const (
 	authURL         string = "https://app.box.com/api/oauth2/authorize"
 	tokenURL        string = "https://app.box.com/api/oauth2/token"
-	endpointProfile string = "https://api.box.com/2.0/users/me"
+	endpointProfile string = "https://api.box.com/2.0/users/me?fields=id,name,login,address,avatar_url,enterprise"
 )
 
+// expiryDelta refreshes tokens shortly before they expire, so a request made
+// with the token doesn't fail on the way. Box access tokens last 60 minutes.
+const expiryDelta = time.Minute
+
 // Provider is the implementation of `goth.Provider` for accessing Box.
 type Provider struct {
 	ClientKey    string
@@ -52,6 +60,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -81,11 +99,20 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
+	// refresh the access token when it expired since the session was stored
+	token, err := p.TokenSource(s).Token()
+	if err != nil {
+		return user, err
+	}
+	user.AccessToken = s.AccessToken
+	user.RefreshToken = s.RefreshToken
+	user.ExpiresAt = s.ExpiresAt
+
 	req, err := http.NewRequest("GET", endpointProfile, nil)
 	if err != nil {
 		return user, err
 	}
-	req.Header.Set("Authorization", "Bearer "+s.AccessToken)
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
 	resp, err := p.Client().Do(req)
 	if err != nil {
 		return user, err
@@ -96,10 +123,69 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
 	}
 
-	err = userFromReader(resp.Body, &user)
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+
+	err = userFromReader(bytes.NewReader(bits), &user)
 	return user, err
 }
 
+// EnterpriseID returns the ID of the Box enterprise a user fetched by this
+// provider belongs to. It is empty for users of free personal accounts.
+func EnterpriseID(user goth.User) string {
+	enterprise, _ := user.RawData["enterprise"].(map[string]interface{})
+	enterpriseID, _ := enterprise["id"].(string)
+	return enterpriseID
+}
+
+// TokenSource returns a token source for the tokens of the session, which
+// refreshes the access token once it expired. Box rotates refresh tokens, so
+// the refreshed tokens are written back to the session, which has to be stored
+// again afterwards.
+func (p *Provider) TokenSource(session *Session) oauth2.TokenSource {
+	token := &oauth2.Token{
+		AccessToken:  session.AccessToken,
+		RefreshToken: session.RefreshToken,
+		Expiry:       session.ExpiresAt,
+	}
+	// the config's token source only holds the refresh token, so it refreshes
+	// whenever the reused token is about to expire
+	refresher := p.config.TokenSource(goth.ContextForClient(p.Client()), &oauth2.Token{RefreshToken: session.RefreshToken})
+	return &sessionTokenSource{
+		session: session,
+		source:  oauth2.ReuseTokenSourceWithExpiry(token, refresher, expiryDelta),
+	}
+}
+
+type sessionTokenSource struct {
+	mu      sync.Mutex
+	session *Session
+	source  oauth2.TokenSource
+}
+
+func (ts *sessionTokenSource) Token() (*oauth2.Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	token, err := ts.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token.AccessToken != ts.session.AccessToken {
+		ts.session.AccessToken = token.AccessToken
+		ts.session.RefreshToken = token.RefreshToken
+		ts.session.ExpiresAt = token.Expiry
+	}
+	return token, nil
+}
+
 func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 	c := &oauth2.Config{
 		ClientID:     provider.ClientKey,
@@ -138,6 +224,7 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	user.NickName = u.Name
 	user.UserID = u.ID
 	user.Location = u.Location
+	user.AvatarURL = u.AvatarURL
 	return nil
 }
 
@@ -146,7 +233,8 @@ func (p *Provider) RefreshTokenAvailable() bool {
 	return true
 }
 
-// RefreshToken get new access token based on the refresh token
+// RefreshToken get new access token based on the refresh token. Box refresh
+// tokens can only be used once, store the refresh token that is returned.
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
 	token := &oauth2.Token{RefreshToken: refreshToken}
 	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
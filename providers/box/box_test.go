@@ -1,8 +1,15 @@
 package box_test
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/andreimerlescu/goth"
 	"github.com/andreimerlescu/goth/providers/box"
@@ -35,6 +42,64 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "app.box.com/api/oauth2/authorize")
 }
 
+func Test_FetchUserRefreshesExpiredToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	refreshes := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/oauth2/token":
+			if req.FormValue("refresh_token") != "refresh-1" {
+				res.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			refreshes++
+			res.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(res, `{"access_token":"access-2","expires_in":3600,"token_type":"bearer","refresh_token":"refresh-2"}`)
+		case "/2.0/users/me":
+			if req.Header.Get("Authorization") != "Bearer access-2" {
+				res.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(res, `{"type":"user","id":"11446498","name":"Aaron Levie","login":"ceo@example.com","address":"900 Jefferson Ave, Redwood City, CA 94063","avatar_url":"https://www.box.com/api/avatar/large/181216415","enterprise":{"type":"enterprise","id":"11446499","name":"Acme Inc."}}`)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := provider()
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	s := &box.Session{AccessToken: "access-1", RefreshToken: "refresh-1", ExpiresAt: time.Now().Add(30 * time.Second)}
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal(1, refreshes)
+	a.Equal("access-2", s.AccessToken)
+	a.Equal("refresh-2", s.RefreshToken)
+	a.Equal("access-2", user.AccessToken)
+	a.Equal("refresh-2", user.RefreshToken)
+	a.True(user.ExpiresAt.After(time.Now().Add(50 * time.Minute)))
+
+	a.Equal("11446498", user.UserID)
+	a.Equal("ceo@example.com", user.Email)
+	a.Equal("https://www.box.com/api/avatar/large/181216415", user.AvatarURL)
+	a.Equal("11446499", box.EnterpriseID(user))
+
+	// the refreshed token is reused
+	_, err = p.FetchUser(s)
+	a.NoError(err)
+	a.Equal(1, refreshes)
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -0,0 +1,246 @@
+// Package cas implements the CAS 2.0 and 3.0 protocols for authenticating
+// users through a Central Authentication Service server, as used by many
+// universities.
+package cas
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// AttributeMap lists, for the fields of goth.User, the names of the CAS
+// attributes they are read from. The first attribute found is used. The
+// UserID and NickName are the CAS user unless one of their attributes is found.
+type AttributeMap struct {
+	Email     []string
+	Name      []string
+	FirstName []string
+	LastName  []string
+	NickName  []string
+	UserID    []string
+}
+
+// DefaultAttributeMap maps the attribute names commonly released by CAS servers.
+var DefaultAttributeMap = AttributeMap{
+	Email:     []string{"email", "mail"},
+	Name:      []string{"displayName", "name", "cn"},
+	FirstName: []string{"givenName", "firstName", "first_name"},
+	LastName:  []string{"sn", "surname", "lastName", "last_name"},
+}
+
+// New creates a new CAS provider for the server at serverURL, the URL the
+// /login and /serviceValidate endpoints are relative to, e.g.
+// "https://cas.example.edu/cas".
+// You should always call `cas.New` to get a new Provider. Never try to create
+// one manually.
+func New(serverURL, callbackURL string) *Provider {
+	return &Provider{
+		ServerURL:    strings.TrimSuffix(serverURL, "/"),
+		CallbackURL:  callbackURL,
+		ValidatePath: "/serviceValidate",
+		AttributeMap: DefaultAttributeMap,
+		providerName: "cas",
+	}
+}
+
+// Provider is the implementation of `goth.Provider` for a CAS server.
+type Provider struct {
+	ServerURL   string
+	CallbackURL string
+	// ValidatePath is the path of the ticket validation endpoint. CAS 3.0
+	// servers only release attributes on "/p3/serviceValidate" unless they
+	// are configured to on "/serviceValidate" too.
+	ValidatePath string
+	// Renew forces users to enter their credentials again, instead of
+	// relying on their single sign-on session.
+	Renew        bool
+	AttributeMap AttributeMap
+	HTTPClient   *http.Client
+	providerName string
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Local reports that the provider is a goth.LocalProvider: the tickets are validated during the login, not the users fetched with a token.
+func (p *Provider) Local() bool {
+	return true
+}
+
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the cas package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks the CAS server to authenticate the user. The state is kept
+// in the service URL, which the server redirects back to with a ticket.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	service, err := url.Parse(p.CallbackURL)
+	if err != nil {
+		return nil, err
+	}
+	q := service.Query()
+	q.Set("state", state)
+	service.RawQuery = q.Encode()
+
+	v := url.Values{"service": {service.String()}}
+	if p.Renew {
+		v.Set("renew", "true")
+	}
+	session := &Session{
+		AuthURL: p.ServerURL + "/login?" + v.Encode(),
+		Service: service.String(),
+	}
+	return session, nil
+}
+
+// FetchUser maps the validated ticket to a goth.User.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	s := session.(*Session)
+	user := goth.User{
+		Provider: p.Name(),
+	}
+
+	if s.User == "" {
+		// the ticket hasn't been validated yet
+		return user, fmt.Errorf("%s cannot get user information without a validated ticket", p.providerName)
+	}
+
+	user.RawData = make(map[string]interface{}, len(s.Attributes)+1)
+	for name, values := range s.Attributes {
+		if len(values) == 1 {
+			user.RawData[name] = values[0]
+		} else {
+			user.RawData[name] = values
+		}
+	}
+	user.RawData["user"] = s.User
+
+	m := p.AttributeMap
+	user.UserID = s.attribute(m.UserID)
+	if user.UserID == "" {
+		user.UserID = s.User
+	}
+	user.NickName = s.attribute(m.NickName)
+	if user.NickName == "" {
+		user.NickName = s.User
+	}
+	user.Email = s.attribute(m.Email)
+	user.FirstName = s.attribute(m.FirstName)
+	user.LastName = s.attribute(m.LastName)
+	user.Name = s.attribute(m.Name)
+	if user.Name == "" {
+		user.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+	}
+	return user, nil
+}
+
+// RefreshToken refresh token is not provided by cas
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by cas")
+}
+
+// RefreshTokenAvailable refresh token is not provided by cas
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
+
+// ValidationError is the authenticationFailure returned by the CAS server.
+type ValidationError struct {
+	Code    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("cas: %s: %s", e.Code, e.Message)
+}
+
+// serviceResponse is the response of /serviceValidate, see
+// https://apereo.github.io/cas/development/protocol/CAS-Protocol-Specification.html#25-servicevalidate-cas-20
+type serviceResponse struct {
+	XMLName xml.Name `xml:"http://www.yale.edu/tp/cas serviceResponse"`
+	Success *struct {
+		User       string `xml:"user"`
+		Attributes struct {
+			Values []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:"attributes"`
+		// attributes released by some servers as <cas:attribute name="" value=""/>
+		Attribute []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:"value,attr"`
+		} `xml:"attribute"`
+	} `xml:"authenticationSuccess"`
+	Failure *struct {
+		Code    string `xml:"code,attr"`
+		Message string `xml:",chardata"`
+	} `xml:"authenticationFailure"`
+}
+
+// validate validates the ticket issued for the service, and returns the user
+// and attributes.
+func (p *Provider) validate(service, ticket string) (string, map[string][]string, error) {
+	v := url.Values{"service": {service}, "ticket": {ticket}}
+	if p.Renew {
+		v.Set("renew", "true")
+	}
+	resp, err := p.Client().Get(p.ServerURL + p.ValidatePath + "?" + v.Encode())
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("%s responded with a %d trying to validate the ticket", p.providerName, resp.StatusCode)
+	}
+
+	var r serviceResponse
+	err = xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&r)
+	if err != nil {
+		return "", nil, err
+	}
+	if r.Failure != nil {
+		return "", nil, &ValidationError{Code: r.Failure.Code, Message: strings.TrimSpace(r.Failure.Message)}
+	}
+	if r.Success == nil || strings.TrimSpace(r.Success.User) == "" {
+		return "", nil, errors.New("cas: the validation response has no user")
+	}
+
+	attributes := make(map[string][]string)
+	for _, a := range r.Success.Attributes.Values {
+		attributes[a.XMLName.Local] = append(attributes[a.XMLName.Local], strings.TrimSpace(a.Value))
+	}
+	for _, a := range r.Success.Attribute {
+		attributes[a.Name] = append(attributes[a.Name], a.Value)
+	}
+	return strings.TrimSpace(r.Success.User), attributes, nil
+}
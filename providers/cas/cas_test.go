@@ -0,0 +1,182 @@
+package cas_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/cas"
+	"github.com/stretchr/testify/assert"
+)
+
+const successResponse = `<cas:serviceResponse xmlns:cas="http://www.yale.edu/tp/cas">
+  <cas:authenticationSuccess>
+    <cas:user>jdoe</cas:user>
+    <cas:attributes>
+      <cas:mail>jdoe@example.edu</cas:mail>
+      <cas:givenName>John</cas:givenName>
+      <cas:sn>Doe</cas:sn>
+      <cas:memberOf>faculty</cas:memberOf>
+      <cas:memberOf>staff</cas:memberOf>
+    </cas:attributes>
+  </cas:authenticationSuccess>
+</cas:serviceResponse>`
+
+const attributeResponse = `<cas:serviceResponse xmlns:cas="http://www.yale.edu/tp/cas">
+  <cas:authenticationSuccess>
+    <cas:user>jdoe</cas:user>
+    <cas:attribute name="displayName" value="John Doe"/>
+    <cas:attribute name="employeeNumber" value="1234"/>
+  </cas:authenticationSuccess>
+</cas:serviceResponse>`
+
+const failureResponse = `<cas:serviceResponse xmlns:cas="http://www.yale.edu/tp/cas">
+  <cas:authenticationFailure code="INVALID_TICKET">
+    Ticket ST-1856339-aA5Yuvrxzpv8Tau1cYQ7 not recognized
+  </cas:authenticationFailure>
+</cas:serviceResponse>`
+
+func provider() *cas.Provider {
+	return cas.New("https://cas.example.edu/cas/", "https://example.com/auth/cas/callback")
+}
+
+// server answers the validation of ST-1 with the response.
+func server(t *testing.T, response string) (*httptest.Server, *url.Values) {
+	received := &url.Values{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*received = r.URL.Query()
+		if r.URL.Path != "/cas/serviceValidate" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("ticket") != "ST-1" {
+			fmt.Fprint(w, failureResponse)
+			return
+		}
+		fmt.Fprint(w, response)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, received
+}
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.ServerURL, "https://cas.example.edu/cas")
+	a.Equal(p.CallbackURL, "https://example.com/auth/cas/callback")
+	a.Equal(p.ValidatePath, "/serviceValidate")
+	a.Equal(p.AttributeMap, cas.DefaultAttributeMap)
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*cas.Session)
+	a.Equal("https://example.com/auth/cas/callback?state=test_state", s.Service)
+	a.Equal("https://cas.example.edu/cas/login?service="+url.QueryEscape(s.Service), s.AuthURL)
+
+	p.Renew = true
+	session, err = p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*cas.Session).AuthURL, "renew=true")
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ts, received := server(t, successResponse)
+	p := cas.New(ts.URL+"/cas", "https://example.com/auth/cas/callback")
+
+	session, _ := p.BeginAuth("test_state")
+	s := session.(*cas.Session)
+	_, err := p.FetchUser(s)
+	a.Error(err)
+
+	_, err = s.Authorize(p, url.Values{})
+	a.Error(err)
+
+	ticket, err := s.Authorize(p, url.Values{"ticket": {"ST-1"}, "state": {"test_state"}})
+	a.NoError(err)
+	a.Equal("ST-1", ticket)
+	a.Equal(s.Service, received.Get("service"))
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("jdoe", user.UserID)
+	a.Equal("jdoe", user.NickName)
+	a.Equal("jdoe@example.edu", user.Email)
+	a.Equal("John", user.FirstName)
+	a.Equal("Doe", user.LastName)
+	a.Equal("John Doe", user.Name)
+	a.Equal([]string{"faculty", "staff"}, user.RawData["memberOf"])
+}
+
+func Test_AuthorizeAttributeElements(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ts, _ := server(t, attributeResponse)
+	p := cas.New(ts.URL+"/cas", "https://example.com/auth/cas/callback")
+	p.AttributeMap.UserID = []string{"employeeNumber"}
+
+	session, _ := p.BeginAuth("test_state")
+	s := session.(*cas.Session)
+	_, err := s.Authorize(p, url.Values{"ticket": {"ST-1"}})
+	a.NoError(err)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+	a.Equal("jdoe", user.NickName)
+	a.Equal("John Doe", user.Name)
+}
+
+func Test_AuthorizeFailure(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ts, _ := server(t, successResponse)
+	p := cas.New(ts.URL+"/cas", "https://example.com/auth/cas/callback")
+
+	session, _ := p.BeginAuth("test_state")
+	s := session.(*cas.Session)
+	_, err := s.Authorize(p, url.Values{"ticket": {"ST-2"}})
+	a.Error(err)
+	var validationErr *cas.ValidationError
+	a.ErrorAs(err, &validationErr)
+	a.Equal("INVALID_TICKET", validationErr.Code)
+	a.Equal("Ticket ST-1856339-aA5Yuvrxzpv8Tau1cYQ7 not recognized", validationErr.Message)
+	a.Empty(s.User)
+
+	p.ValidatePath = "/p3/serviceValidate"
+	_, err = s.Authorize(p, url.Values{"ticket": {"ST-1"}})
+	a.Error(err)
+	a.Empty(s.User)
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://cas.example.edu/cas/login","Service":"https://example.com/auth/cas/callback?state=x","User":"jdoe"}`)
+	a.NoError(err)
+
+	s := session.(*cas.Session)
+	a.Equal("https://cas.example.edu/cas/login", s.AuthURL)
+	a.Equal("https://example.com/auth/cas/callback?state=x", s.Service)
+	a.Equal("jdoe", s.User)
+}
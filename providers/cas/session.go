@@ -0,0 +1,72 @@
+package cas
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Session stores data during the auth process with a CAS server.
+type Session struct {
+	AuthURL    string
+	Service    string
+	User       string
+	Attributes map[string][]string
+}
+
+var _ goth.Session = &Session{}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` function on the CAS provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize the session by validating the service ticket with the CAS server,
+// and return the ticket.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	ticket := params.Get("ticket")
+	if ticket == "" {
+		return "", errors.New("cas: no ticket received")
+	}
+
+	user, attributes, err := p.validate(s.Service, ticket)
+	if err != nil {
+		return "", err
+	}
+	s.User = user
+	s.Attributes = attributes
+	return ticket, nil
+}
+
+// attribute returns the first value of the first attribute found.
+func (s Session) attribute(names []string) string {
+	for _, name := range names {
+		if values := s.Attributes[name]; len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return goth.RedactJSON(s.Marshal())
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	s := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
+	return s, err
+}
@@ -45,5 +45,5 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 }
 
 func (s *Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
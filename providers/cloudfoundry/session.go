@@ -55,7 +55,7 @@ func (s Session) Marshal() string {
 }
 
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // UnmarshalSession wil unmarshal a JSON string into a session.
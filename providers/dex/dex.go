@@ -0,0 +1,322 @@
+// Package dex implements the OpenID Connect protocol for authenticating users through Dex.
+// This package can be used as a reference implementation of an OAuth2 provider for Goth.
+package dex
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// ScopeGroups asks Dex to include the upstream groups of the user.
+	ScopeGroups = "groups"
+	// ScopeFederatedID asks Dex to include the connector and upstream user ID
+	// in the id_token's federated_claims.
+	ScopeFederatedID = "federated:id"
+	// ScopeOfflineAccess asks Dex to issue a refresh token.
+	ScopeOfflineAccess = "offline_access"
+)
+
+// Provider is the implementation of `goth.Provider` for accessing Dex.
+type Provider struct {
+	ClientKey    string
+	Secret       string
+	CallbackURL  string
+	HTTPClient   *http.Client
+	OpenIDConfig *OpenIDConfig
+	config       *oauth2.Config
+	providerName string
+
+	// ConnectorID is sent as the connector_id parameter of the authorization
+	// request, which makes Dex skip its connector selection page and send the
+	// user straight to the given upstream connector.
+	ConnectorID string
+}
+
+var _ goth.BeginAuthWithParamsProvider = &Provider{}
+
+// OpenIDConfig holds the endpoints advertised by the Dex issuer.
+type OpenIDConfig struct {
+	AuthEndpoint     string `json:"authorization_endpoint"`
+	TokenEndpoint    string `json:"token_endpoint"`
+	UserInfoEndpoint string `json:"userinfo_endpoint"`
+	Issuer           string `json:"issuer"`
+}
+
+// New creates a new Dex provider for the given issuer URL, and sets up important
+// connection details by querying the issuer's discovery document.
+// You should always call `dex.New` to get a new provider. Never try to
+// create one manually.
+func New(clientKey, secret, callbackURL, issuerURL string, scopes ...string) (*Provider, error) {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "dex",
+	}
+
+	openIDConfig, err := getOpenIDConfig(p, strings.TrimSuffix(issuerURL, "/")+"/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	p.OpenIDConfig = openIDConfig
+	p.config = newConfig(p, scopes)
+	return p, nil
+}
+
+// NewCustomisedURL is similar to New(...) but can be used to set custom URLs hence omit the discovery step
+func NewCustomisedURL(clientKey, secret, callbackURL, authURL, tokenURL, issuerURL, userInfoURL string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:   clientKey,
+		Secret:      secret,
+		CallbackURL: callbackURL,
+		OpenIDConfig: &OpenIDConfig{
+			AuthEndpoint:     authURL,
+			TokenEndpoint:    tokenURL,
+			Issuer:           issuerURL,
+			UserInfoEndpoint: userInfoURL,
+		},
+		providerName: "dex",
+	}
+	p.config = newConfig(p, scopes)
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type).
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// SetConnectorID sets the upstream connector users are sent to.
+func (p *Provider) SetConnectorID(connectorID string) {
+	p.ConnectorID = connectorID
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the dex package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks Dex for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return p.BeginAuthWithConnector(state, p.ConnectorID)
+}
+
+// BeginAuthWithParams deep-links the user to the upstream connector of the
+// connector_id parameter, e.g. of /auth/dex?connector_id=github when gothic
+// begins the authentication, or to the provider's default one without it.
+func (p *Provider) BeginAuthWithParams(state string, params goth.Params) (goth.Session, error) {
+	connectorID := params.Get("connector_id")
+	if connectorID == "" {
+		connectorID = p.ConnectorID
+	}
+	return p.BeginAuthWithConnector(state, connectorID)
+}
+
+// BeginAuthWithConnector is similar to BeginAuth(...) but deep-links the user to
+// the given upstream connector instead of the provider's default one.
+func (p *Provider) BeginAuthWithConnector(state, connectorID string) (goth.Session, error) {
+	var opts []oauth2.AuthCodeOption
+	if connectorID != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("connector_id", connectorID))
+	}
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state, opts...),
+	}, nil
+}
+
+// FetchUser will go to Dex and access basic information about the user.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		Provider:     p.Name(),
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+		IDToken:      sess.IDToken,
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	req, err := http.NewRequest("GET", p.OpenIDConfig.UserInfoEndpoint, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	response, err := p.Client().Do(req)
+	if err != nil {
+		if response != nil {
+			response.Body.Close()
+		}
+		return user, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
+	}
+
+	bits, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+
+	// Dex only puts the federated claims into the id_token, so merge them in
+	// when the token is available.
+	if sess.IDToken != "" {
+		claims, err := decodeJWT(sess.IDToken)
+		if err != nil {
+			return user, err
+		}
+		if federated, ok := claims["federated_claims"]; ok {
+			user.RawData["federated_claims"] = federated
+		}
+	}
+
+	err = userFromReader(bytes.NewReader(bits), &user)
+	return user, err
+}
+
+// ConnectorID returns the ID of the upstream connector a user fetched by this
+// provider logged in with. It requires the "federated:id" scope.
+func ConnectorID(user goth.User) string {
+	federated, _ := user.RawData["federated_claims"].(map[string]interface{})
+	connectorID, _ := federated["connector_id"].(string)
+	return connectorID
+}
+
+func getOpenIDConfig(p *Provider, discoveryURL string) (*OpenIDConfig, error) {
+	res, err := p.Client().Get(discoveryURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("Non-success code for Discovery URL: %d", res.StatusCode)
+	}
+
+	openIDConfig := &OpenIDConfig{}
+	err = json.NewDecoder(res.Body).Decode(openIDConfig)
+	if err != nil {
+		return nil, err
+	}
+	return openIDConfig, nil
+}
+
+func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  provider.OpenIDConfig.AuthEndpoint,
+			TokenURL: provider.OpenIDConfig.TokenEndpoint,
+		},
+		Scopes: []string{},
+	}
+
+	if len(scopes) > 0 {
+		foundOpenIDScope := false
+		for _, scope := range scopes {
+			if scope == "openid" {
+				foundOpenIDScope = true
+			}
+			c.Scopes = append(c.Scopes, scope)
+		}
+		if !foundOpenIDScope {
+			c.Scopes = append(c.Scopes, "openid")
+		}
+	} else {
+		c.Scopes = []string{"openid", "profile", "email", ScopeFederatedID}
+	}
+	return c
+}
+
+func userFromReader(r io.Reader, user *goth.User) error {
+	u := struct {
+		ID       string `json:"sub"`
+		Email    string `json:"email"`
+		Name     string `json:"name"`
+		Username string `json:"preferred_username"`
+	}{}
+
+	err := json.NewDecoder(r).Decode(&u)
+	if err != nil {
+		return err
+	}
+
+	user.UserID = u.ID
+	user.Email = u.Email
+	user.Name = u.Name
+	user.NickName = u.Username
+	return nil
+}
+
+// decodeJWT decodes the payload of a JSON Web Token into a simple map
+func decodeJWT(jwt string) (map[string]interface{}, error) {
+	jwtParts := strings.Split(jwt, ".")
+	if len(jwtParts) != 3 {
+		return nil, errors.New("jws: invalid token received, not all parts available")
+	}
+
+	decodedPayload, err := base64.RawURLEncoding.DecodeString(jwtParts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]interface{})
+	return claims, json.Unmarshal(decodedPayload, &claims)
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
+	newToken, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return newToken, err
+}
@@ -0,0 +1,134 @@
+package dex_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/dex"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	server := dexServer()
+	defer server.Close()
+
+	p, err := dex.New(os.Getenv("DEX_KEY"), os.Getenv("DEX_SECRET"), "/foo", server.URL+"/dex")
+	a.NoError(err)
+	a.Equal(p.ClientKey, os.Getenv("DEX_KEY"))
+	a.Equal(p.Secret, os.Getenv("DEX_SECRET"))
+	a.Equal(p.CallbackURL, "/foo")
+	a.Equal(server.URL+"/dex/auth", p.OpenIDConfig.AuthEndpoint)
+	a.Equal(server.URL+"/dex/userinfo", p.OpenIDConfig.UserInfoEndpoint)
+}
+
+func Test_Name(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Equal(provider().Name(), "dex")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	session, err := p.BeginAuth("test_state")
+	s := session.(*dex.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "https://dex.example.com/dex/auth")
+	a.NotContains(s.AuthURL, "connector_id")
+}
+
+func Test_BeginAuthWithConnector(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	p.SetConnectorID("github")
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*dex.Session).AuthURL, "connector_id=github")
+
+	session, err = p.BeginAuthWithConnector("test_state", "ldap")
+	a.NoError(err)
+	a.Contains(session.(*dex.Session).AuthURL, "connector_id=ldap")
+
+	session, err = p.BeginAuthWithParams("test_state", url.Values{"connector_id": {"saml"}})
+	a.NoError(err)
+	a.Contains(session.(*dex.Session).AuthURL, "connector_id=saml")
+
+	session, err = p.BeginAuthWithParams("test_state", url.Values{})
+	a.NoError(err)
+	a.Contains(session.(*dex.Session).AuthURL, "connector_id=github")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	server := dexServer()
+	defer server.Close()
+
+	p, err := dex.New("key", "secret", "/foo", server.URL+"/dex")
+	a.NoError(err)
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"Cg0wLTM4NS0yODA4OS0wEgRtb2Nr","federated_claims":{"connector_id":"github","user_id":"42"}}`))
+	user, err := p.FetchUser(&dex.Session{AccessToken: "1234567890", IDToken: "e30." + payload + ".sig"})
+	a.NoError(err)
+	a.Equal("Cg0wLTM4NS0yODA4OS0wEgRtb2Nr", user.UserID)
+	a.Equal("kilgore@kilgore.trout", user.Email)
+	a.Equal("Kilgore Trout", user.Name)
+	a.Equal("github", dex.ConnectorID(user))
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://dex.example.com/dex/auth","AccessToken":"1234567890"}`)
+	a.NoError(err)
+
+	s := session.(*dex.Session)
+	a.Equal(s.AuthURL, "https://dex.example.com/dex/auth")
+	a.Equal(s.AccessToken, "1234567890")
+}
+
+func provider() *dex.Provider {
+	return dex.NewCustomisedURL(os.Getenv("DEX_KEY"), os.Getenv("DEX_SECRET"), "/foo",
+		"https://dex.example.com/dex/auth",
+		"https://dex.example.com/dex/token",
+		"https://dex.example.com/dex",
+		"https://dex.example.com/dex/userinfo")
+}
+
+func dexServer() *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/dex/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"issuer":"%[1]s/dex","authorization_endpoint":"%[1]s/dex/auth","token_endpoint":"%[1]s/dex/token","userinfo_endpoint":"%[1]s/dex/userinfo"}`, server.URL)
+		case "/dex/userinfo":
+			if r.Header.Get("Authorization") != "Bearer 1234567890" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"sub":"Cg0wLTM4NS0yODA4OS0wEgRtb2Nr","email":"kilgore@kilgore.trout","email_verified":true,"name":"Kilgore Trout"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return server
+}
@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/andreimerlescu/goth"
 	"golang.org/x/oauth2"
@@ -34,8 +35,10 @@ type Provider struct {
 
 // Session stores data during the auth process with Dropbox.
 type Session struct {
-	AuthURL string
-	Token   string
+	AuthURL      string
+	Token        string
+	RefreshToken string `json:",omitempty"`
+	ExpiresAt    time.Time
 }
 
 // New creates a new Dropbox provider and sets up important connection details.
@@ -63,6 +66,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -70,10 +83,13 @@ func (p *Provider) Client() *http.Client {
 // Debug is a no-op for the dropbox package.
 func (p *Provider) Debug(debug bool) {}
 
-// BeginAuth asks Dropbox for an authentication end-point.
+// BeginAuth asks Dropbox for an authentication end-point. Dropbox only issues
+// short-lived access tokens, so offline access is requested to also receive a
+// refresh token.
+// See https://developers.dropbox.com/oauth-guide#using-refresh-tokens
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 	return &Session{
-		AuthURL: p.config.AuthCodeURL(state),
+		AuthURL: p.config.AuthCodeURL(state, oauth2.SetAuthURLParam("token_access_type", "offline")),
 	}, nil
 }
 
@@ -81,8 +97,10 @@ func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	s := session.(*Session)
 	user := goth.User{
-		AccessToken: s.Token,
-		Provider:    p.Name(),
+		AccessToken:  s.Token,
+		Provider:     p.Name(),
+		RefreshToken: s.RefreshToken,
+		ExpiresAt:    s.ExpiresAt,
 	}
 
 	if user.AccessToken == "" {
@@ -147,6 +165,8 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	}
 
 	s.Token = token.AccessToken
+	s.RefreshToken = token.RefreshToken
+	s.ExpiresAt = token.Expiry
 	return token.AccessToken, nil
 }
 
@@ -157,7 +177,7 @@ func (s *Session) Marshal() string {
 }
 
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 func newConfig(p *Provider, scopes []string) *oauth2.Config {
@@ -201,12 +221,19 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	return nil
 }
 
-// RefreshToken refresh token is not provided by dropbox
+// RefreshToken get new access token based on the refresh token. Dropbox
+// refresh tokens don't expire and aren't rotated.
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	return nil, errors.New("Refresh token is not provided by dropbox")
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
+	newToken, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return newToken, err
 }
 
-// RefreshTokenAvailable refresh token is not provided by dropbox
+// RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
-	return false
+	return true
 }
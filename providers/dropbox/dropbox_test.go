@@ -3,8 +3,10 @@ package dropbox
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/andreimerlescu/goth"
 	"github.com/stretchr/testify/assert"
@@ -45,6 +47,42 @@ func Test_BeginAuth(t *testing.T) {
 	s := session.(*Session)
 	a.NoError(err)
 	a.Contains(s.AuthURL, "www.dropbox.com/oauth2/authorize")
+	a.Contains(s.AuthURL, "token_access_type=offline")
+}
+
+func Test_AuthorizeAndRefresh(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.FormValue("grant_type") {
+		case "authorization_code":
+			w.Write([]byte(`{"access_token":"sl.short","expires_in":14400,"token_type":"bearer","scope":"account_info.read","refresh_token":"long-lived","account_id":"dbid:AAH4f99T0taONIb-OurWxbNQ6ywGRopQngc","uid":"12345"}`))
+		case "refresh_token":
+			a.Equal("long-lived", r.FormValue("refresh_token"))
+			w.Write([]byte(`{"access_token":"sl.renewed","expires_in":14400,"token_type":"bearer"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	p := provider()
+	p.config.Endpoint.TokenURL = ts.URL + "/oauth2/token"
+
+	s := &Session{}
+	_, err := s.Authorize(p, url.Values{"code": {"code"}})
+	a.NoError(err)
+	a.Equal("sl.short", s.Token)
+	a.Equal("long-lived", s.RefreshToken)
+	a.WithinDuration(time.Now().Add(4*time.Hour), s.ExpiresAt, time.Minute)
+
+	a.True(p.RefreshTokenAvailable())
+	token, err := p.RefreshToken(s.RefreshToken)
+	a.NoError(err)
+	a.Equal("sl.renewed", token.AccessToken)
+	a.Equal("long-lived", token.RefreshToken)
 }
 
 func Test_FetchUser(t *testing.T) {
@@ -101,7 +139,7 @@ func Test_SessionToJSON(t *testing.T) {
 	s := &Session{}
 
 	data := s.Marshal()
-	a.Equal(data, `{"AuthURL":"","Token":""}`)
+	a.Equal(data, `{"AuthURL":"","Token":"","ExpiresAt":"0001-01-01T00:00:00Z"}`)
 }
 
 func Test_GetAuthURL(t *testing.T) {
@@ -0,0 +1,277 @@
+// Package ebay implements the OAuth2 protocol for authenticating users through eBay,
+// using the user consent flow and the Commerce Identity API.
+// This package can be used as a reference implementation of an OAuth2 provider for Goth.
+package ebay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// URLs and endpoints
+const (
+	authURL         string = "https://auth.ebay.com/oauth2/authorize"
+	tokenURL        string = "https://api.ebay.com/identity/v1/oauth2/token"
+	endpointProfile string = "https://apiz.ebay.com/commerce/identity/v1/user/"
+
+	sandboxAuthURL         string = "https://auth.sandbox.ebay.com/oauth2/authorize"
+	sandboxTokenURL        string = "https://api.sandbox.ebay.com/identity/v1/oauth2/token"
+	sandboxEndpointProfile string = "https://apiz.sandbox.ebay.com/commerce/identity/v1/user/"
+)
+
+// These are the scopes to read the identity of the user. ScopeAPI and
+// ScopeIdentity are requested when no scopes are passed to New.
+// See https://developer.ebay.com/api-docs/static/oauth-scopes.html
+const (
+	ScopeAPI                 string = "https://api.ebay.com/oauth/api_scope"
+	ScopeIdentity            string = "https://api.ebay.com/oauth/api_scope/commerce.identity.readonly"
+	ScopeIdentityEmail       string = "https://api.ebay.com/oauth/api_scope/commerce.identity.email.readonly"
+	ScopeIdentityPhone       string = "https://api.ebay.com/oauth/api_scope/commerce.identity.phone.readonly"
+	ScopeIdentityAddress     string = "https://api.ebay.com/oauth/api_scope/commerce.identity.address.readonly"
+	ScopeIdentityName        string = "https://api.ebay.com/oauth/api_scope/commerce.identity.name.readonly"
+	ScopeIdentityStatus      string = "https://api.ebay.com/oauth/api_scope/commerce.identity.status.readonly"
+	ScopeNotificationRead    string = "https://api.ebay.com/oauth/api_scope/commerce.notification.subscription.readonly"
+	ScopeCatalogRead         string = "https://api.ebay.com/oauth/api_scope/commerce.catalog.readonly"
+	ScopeBuyOrderRead        string = "https://api.ebay.com/oauth/api_scope/buy.order.readonly"
+	ScopeBuyGuestOrder       string = "https://api.ebay.com/oauth/api_scope/buy.guest.order"
+	ScopeBuyShoppingCart     string = "https://api.ebay.com/oauth/api_scope/buy.shopping.cart"
+	ScopeBuyOfferAuction     string = "https://api.ebay.com/oauth/api_scope/buy.offer.auction"
+	ScopeSellAccount         string = "https://api.ebay.com/oauth/api_scope/sell.account"
+	ScopeSellAccountRead     string = "https://api.ebay.com/oauth/api_scope/sell.account.readonly"
+	ScopeSellAnalyticsRead   string = "https://api.ebay.com/oauth/api_scope/sell.analytics.readonly"
+	ScopeSellFinances        string = "https://api.ebay.com/oauth/api_scope/sell.finances"
+	ScopeSellFulfillment     string = "https://api.ebay.com/oauth/api_scope/sell.fulfillment"
+	ScopeSellFulfillmentRead string = "https://api.ebay.com/oauth/api_scope/sell.fulfillment.readonly"
+	ScopeSellInventory       string = "https://api.ebay.com/oauth/api_scope/sell.inventory"
+	ScopeSellInventoryRead   string = "https://api.ebay.com/oauth/api_scope/sell.inventory.readonly"
+	ScopeSellMarketing       string = "https://api.ebay.com/oauth/api_scope/sell.marketing"
+	ScopeSellMarketingRead   string = "https://api.ebay.com/oauth/api_scope/sell.marketing.readonly"
+	ScopeSellStores          string = "https://api.ebay.com/oauth/api_scope/sell.stores"
+	ScopeSellStoresRead      string = "https://api.ebay.com/oauth/api_scope/sell.stores.readonly"
+)
+
+// Provider is the implementation of `goth.Provider` for accessing eBay.
+type Provider struct {
+	ClientKey string
+	Secret    string
+	// CallbackURL holds the RuName (eBay redirect URL name) of the application
+	// instead of a URL. eBay sends it as the redirect_uri and redirects to the
+	// accept URL configured for the RuName in the developer portal, which has
+	// to point at the callback handler of the application.
+	CallbackURL  string
+	HTTPClient   *http.Client
+	config       *oauth2.Config
+	providerName string
+	profileURL   string
+}
+
+// New creates a new eBay provider and sets up important connection details.
+// The ruName is the RuName of the application, not the callback URL itself.
+// You should always call `ebay.New` to get a new provider.  Never try to
+// create one manually.
+func New(clientKey, secret, ruName string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  ruName,
+		providerName: "ebay",
+		profileURL:   endpointProfile,
+	}
+	p.config = newConfig(p, authURL, tokenURL, scopes)
+	return p
+}
+
+// NewSandbox is similar to New(...) but connects to the eBay sandbox
+// environment, which has separate keysets and RuNames.
+func NewSandbox(clientKey, secret, ruName string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  ruName,
+		providerName: "ebay",
+		profileURL:   sandboxEndpointProfile,
+	}
+	p.config = newConfig(p, sandboxAuthURL, sandboxTokenURL, scopes)
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the ebay package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks eBay for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state),
+	}, nil
+}
+
+// FetchUser will go to eBay and access basic information about the user.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		Provider:     p.Name(),
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	req, err := http.NewRequest("GET", p.profileURL, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+	}
+
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+
+	err = userFromReader(bytes.NewReader(bits), &user)
+	return user, err
+}
+
+// Marketplace returns the ID of the eBay marketplace a user fetched by this
+// provider registered on, e.g. EBAY_US or EBAY_DE.
+func Marketplace(user goth.User) string {
+	marketplace, _ := user.RawData["registrationMarketplaceId"].(string)
+	return marketplace
+}
+
+// AccountType returns whether a user fetched by this provider has an
+// INDIVIDUAL or a BUSINESS account.
+func AccountType(user goth.User) string {
+	accountType, _ := user.RawData["accountType"].(string)
+	return accountType
+}
+
+func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   authURL,
+			TokenURL:  tokenURL,
+			AuthStyle: oauth2.AuthStyleInHeader,
+		},
+		Scopes: []string{},
+	}
+
+	if len(scopes) > 0 {
+		c.Scopes = append(c.Scopes, scopes...)
+	} else {
+		c.Scopes = append(c.Scopes, ScopeAPI, ScopeIdentity)
+	}
+	return c
+}
+
+func userFromReader(r io.Reader, user *goth.User) error {
+	u := struct {
+		UserID            string `json:"userId"`
+		Username          string `json:"username"`
+		IndividualAccount struct {
+			FirstName string `json:"firstName"`
+			LastName  string `json:"lastName"`
+			Email     string `json:"email"`
+		} `json:"individualAccount"`
+		BusinessAccount struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+		} `json:"businessAccount"`
+	}{}
+
+	err := json.NewDecoder(r).Decode(&u)
+	if err != nil {
+		return err
+	}
+
+	user.UserID = u.UserID
+	user.NickName = u.Username
+	user.FirstName = u.IndividualAccount.FirstName
+	user.LastName = u.IndividualAccount.LastName
+	user.Name = strings.TrimSpace(u.IndividualAccount.FirstName + " " + u.IndividualAccount.LastName)
+	user.Email = u.IndividualAccount.Email
+	if u.BusinessAccount.Name != "" {
+		user.Name = u.BusinessAccount.Name
+	}
+	if u.BusinessAccount.Email != "" {
+		user.Email = u.BusinessAccount.Email
+	}
+	if user.Name == "" {
+		user.Name = u.Username
+	}
+	return nil
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token. The new access
+// token is granted the scopes the user consented to.
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
+	newToken, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return newToken, err
+}
@@ -0,0 +1,122 @@
+package ebay_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/ebay"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.ClientKey, os.Getenv("EBAY_KEY"))
+	a.Equal(p.Secret, os.Getenv("EBAY_SECRET"))
+	a.Equal(p.CallbackURL, "Goth_App-GothApp-Prod-abcdefg")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	session, err := p.BeginAuth("test_state")
+	s := session.(*ebay.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "https://auth.ebay.com/oauth2/authorize")
+	a.Contains(s.AuthURL, "redirect_uri=Goth_App-GothApp-Prod-abcdefg")
+	a.Contains(s.AuthURL, url.QueryEscape(ebay.ScopeIdentity))
+
+	p = ebay.NewSandbox(os.Getenv("EBAY_KEY"), os.Getenv("EBAY_SECRET"), "Goth_App-GothApp-SBX-abcdefg", ebay.ScopeSellInventory)
+	session, err = p.BeginAuth("test_state")
+	s = session.(*ebay.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "https://auth.sandbox.ebay.com/oauth2/authorize")
+	a.Contains(s.AuthURL, url.QueryEscape(ebay.ScopeSellInventory))
+}
+
+func Test_AuthorizeAndFetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/identity/v1/oauth2/token":
+			id, secret, ok := req.BasicAuth()
+			if !ok || id != "client-id" || secret != "client-secret" || req.FormValue("redirect_uri") != "Goth_App-GothApp-Prod-abcdefg" {
+				res.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			res.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(res, `{"access_token":"v^1.1#i^1","expires_in":7200,"refresh_token":"v^1.1#r^1","refresh_token_expires_in":47304000,"token_type":"User Access Token"}`)
+		case "/commerce/identity/v1/user/":
+			if req.Header.Get("Authorization") != "Bearer v^1.1#i^1" {
+				res.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(res, `{"userId":"abcd1234","username":"goth_seller","accountType":"INDIVIDUAL","registrationMarketplaceId":"EBAY_US","individualAccount":{"firstName":"Jane","lastName":"Doe","email":"jane@example.com"},"status":"CONFIRMED"}`)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := ebay.New("client-id", "client-secret", "Goth_App-GothApp-Prod-abcdefg")
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	s := &ebay.Session{}
+	token, err := s.Authorize(p, url.Values{"code": {"v^1.1#i^1#p^3"}})
+	a.NoError(err)
+	a.Equal("v^1.1#i^1", token)
+	a.Equal("v^1.1#r^1", s.RefreshToken)
+	a.False(s.RefreshTokenExpiresAt.IsZero())
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("abcd1234", user.UserID)
+	a.Equal("goth_seller", user.NickName)
+	a.Equal("Jane Doe", user.Name)
+	a.Equal("jane@example.com", user.Email)
+	a.Equal("EBAY_US", ebay.Marketplace(user))
+	a.Equal("INDIVIDUAL", ebay.AccountType(user))
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://auth.ebay.com/oauth2/authorize","AccessToken":"1234567890"}`)
+	a.NoError(err)
+
+	s := session.(*ebay.Session)
+	a.Equal(s.AuthURL, "https://auth.ebay.com/oauth2/authorize")
+	a.Equal(s.AccessToken, "1234567890")
+}
+
+func provider() *ebay.Provider {
+	return ebay.New(os.Getenv("EBAY_KEY"), os.Getenv("EBAY_SECRET"), "Goth_App-GothApp-Prod-abcdefg")
+}
@@ -0,0 +1,274 @@
+// Package etsy implements the OAuth2 protocol for authenticating users through Etsy,
+// using the Open API v3 with the mandatory PKCE flow.
+// This package can be used as a reference implementation of an OAuth2 provider for Goth.
+package etsy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// URLs and endpoints
+const (
+	authURL      string = "https://www.etsy.com/oauth/connect"
+	tokenURL     string = "https://api.etsy.com/v3/public/oauth/token"
+	endpointMe   string = "https://openapi.etsy.com/v3/application/users/me"
+	endpointUser string = "https://openapi.etsy.com/v3/application/users/"
+)
+
+// These are the scopes an application can request from Etsy.
+// See https://developers.etsy.com/documentation/essentials/authentication#scopes
+const (
+	ScopeAddressRead       string = "address_r"
+	ScopeAddressWrite      string = "address_w"
+	ScopeBillingRead       string = "billing_r"
+	ScopeCartRead          string = "cart_r"
+	ScopeCartWrite         string = "cart_w"
+	ScopeEmailRead         string = "email_r"
+	ScopeFavoritesRead     string = "favorites_r"
+	ScopeFavoritesWrite    string = "favorites_w"
+	ScopeFeedbackRead      string = "feedback_r"
+	ScopeListingsDelete    string = "listings_d"
+	ScopeListingsRead      string = "listings_r"
+	ScopeListingsWrite     string = "listings_w"
+	ScopeProfileRead       string = "profile_r"
+	ScopeProfileWrite      string = "profile_w"
+	ScopeRecommendRead     string = "recommend_r"
+	ScopeRecommendWrite    string = "recommend_w"
+	ScopeShopsRead         string = "shops_r"
+	ScopeShopsWrite        string = "shops_w"
+	ScopeTransactionsRead  string = "transactions_r"
+	ScopeTransactionsWrite string = "transactions_w"
+)
+
+// Provider is the implementation of `goth.Provider` for accessing Etsy.
+type Provider struct {
+	ClientKey    string
+	Secret       string
+	CallbackURL  string
+	HTTPClient   *http.Client
+	config       *oauth2.Config
+	providerName string
+	meURL        string
+	userURL      string
+}
+
+// New creates a new Etsy provider and sets up important connection details.
+// The clientKey is the keystring of the Etsy app. The token exchange doesn't
+// use the secret, it is only sent along with the keystring in the x-api-key
+// header, which Etsy requires on every API request.
+// You should always call `etsy.New` to get a new provider.  Never try to
+// create one manually.
+func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "etsy",
+		meURL:        endpointMe,
+		userURL:      endpointUser,
+	}
+	p.config = newConfig(p, scopes)
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the etsy package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks Etsy for an authentication end-point. Etsy only supports
+// the PKCE flow, so the session always carries a code verifier.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	verifier, err := goth.GenerateVerifier()
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		AuthURL:      p.config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)),
+		CodeVerifier: verifier,
+	}, nil
+}
+
+// FetchUser will go to Etsy and access basic information about the user.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		Provider:     p.Name(),
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	// users/me only returns the ids of the user and their shop, the profile
+	// itself is read from the user resource
+	bits, err := p.get(p.meURL, sess.AccessToken)
+	if err != nil {
+		return user, err
+	}
+	me := struct {
+		UserID int64 `json:"user_id"`
+		ShopID int64 `json:"shop_id"`
+	}{}
+	if err := json.Unmarshal(bits, &me); err != nil {
+		return user, err
+	}
+
+	bits, err = p.get(p.userURL+strconv.FormatInt(me.UserID, 10), sess.AccessToken)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+	if me.ShopID != 0 {
+		user.RawData["shop_id"] = me.ShopID
+	}
+
+	err = userFromReader(bytes.NewReader(bits), &user)
+	return user, err
+}
+
+func (p *Provider) get(url, accessToken string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("x-api-key", p.apiKey())
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// apiKey returns the value of the x-api-key header, which is the keystring of
+// the app followed by its shared secret.
+func (p *Provider) apiKey() string {
+	if p.Secret == "" {
+		return p.ClientKey
+	}
+	return p.ClientKey + ":" + p.Secret
+}
+
+// ShopID returns the ID of the Etsy shop of a user fetched by this provider,
+// or 0 if the user doesn't own a shop.
+func ShopID(user goth.User) int64 {
+	switch shopID := user.RawData["shop_id"].(type) {
+	case int64:
+		return shopID
+	case float64:
+		// users restored from JSON decode numbers as float64
+		return int64(shopID)
+	}
+	return 0
+}
+
+func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID: provider.ClientKey,
+		// Etsy apps are public clients, the token endpoint only takes the keystring
+		RedirectURL: provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   authURL,
+			TokenURL:  tokenURL,
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+		Scopes: []string{},
+	}
+
+	if len(scopes) > 0 {
+		c.Scopes = append(c.Scopes, scopes...)
+	} else {
+		c.Scopes = append(c.Scopes, ScopeEmailRead, ScopeShopsRead)
+	}
+	return c
+}
+
+func userFromReader(r io.Reader, user *goth.User) error {
+	u := struct {
+		UserID    int64  `json:"user_id"`
+		Email     string `json:"primary_email"`
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Image     string `json:"image_url_75x75"`
+	}{}
+
+	err := json.NewDecoder(r).Decode(&u)
+	if err != nil {
+		return err
+	}
+
+	user.UserID = strconv.FormatInt(u.UserID, 10)
+	user.Email = u.Email
+	user.FirstName = u.FirstName
+	user.LastName = u.LastName
+	user.Name = strings.TrimSpace(u.FirstName + " " + u.LastName)
+	user.AvatarURL = u.Image
+	return nil
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
+	newToken, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return newToken, err
+}
@@ -0,0 +1,118 @@
+package etsy_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/etsy"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.ClientKey, os.Getenv("ETSY_KEY"))
+	a.Equal(p.Secret, os.Getenv("ETSY_SECRET"))
+	a.Equal(p.CallbackURL, "/foo")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	session, err := p.BeginAuth("test_state")
+	s := session.(*etsy.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "https://www.etsy.com/oauth/connect")
+	a.Contains(s.AuthURL, "scope=email_r+shops_r")
+	a.Contains(s.AuthURL, "code_challenge_method=S256")
+	a.NotEmpty(s.CodeVerifier)
+}
+
+func Test_AuthorizeAndFetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/v3/public/oauth/token" {
+			if req.FormValue("client_id") != "keystring" || req.FormValue("code_verifier") != "verifier" || req.FormValue("client_secret") != "" {
+				res.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			res.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(res, `{"access_token":"12345678.O1zLuwveeKjpIqCQFfmR-PaMMpBmagH6DljRAkK9qt05OtRKiANJOyZlMx3WQ_o2FdComQGuoiAWy3dxyGI4Ke_76PR","token_type":"Bearer","expires_in":3600,"refresh_token":"12345678.JNGIJtvLmwfDMhlYoOJl8aLR1BWottyHC6yhNcET-eC7RogSR5e1GTIXGrgrelWZalvh3YvvyLfKYYqvymd-u37Sjtx"}`)
+			return
+		}
+
+		if req.Header.Get("x-api-key") != "keystring:shared-secret" || req.Header.Get("Authorization") == "" {
+			res.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch req.URL.Path {
+		case "/v3/application/users/me":
+			fmt.Fprint(res, `{"user_id":12345678,"shop_id":87654321}`)
+		case "/v3/application/users/12345678":
+			fmt.Fprint(res, `{"user_id":12345678,"primary_email":"seller@example.com","first_name":"Jane","last_name":"Doe","image_url_75x75":"https://i.etsystatic.com/iusa/75x75.jpg"}`)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := etsy.New("keystring", "shared-secret", "/foo")
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	s := &etsy.Session{CodeVerifier: "verifier"}
+	_, err := s.Authorize(p, url.Values{"code": {"auth-code"}})
+	a.NoError(err)
+	a.NotEmpty(s.RefreshToken)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("12345678", user.UserID)
+	a.Equal("Jane Doe", user.Name)
+	a.Equal("seller@example.com", user.Email)
+	a.Equal("https://i.etsystatic.com/iusa/75x75.jpg", user.AvatarURL)
+	a.Equal(int64(87654321), etsy.ShopID(user))
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://www.etsy.com/oauth/connect","AccessToken":"1234567890","CodeVerifier":"verifier"}`)
+	a.NoError(err)
+
+	s := session.(*etsy.Session)
+	a.Equal(s.AuthURL, "https://www.etsy.com/oauth/connect")
+	a.Equal(s.AccessToken, "1234567890")
+	a.Equal(s.CodeVerifier, "verifier")
+}
+
+func provider() *etsy.Provider {
+	return etsy.New(os.Getenv("ETSY_KEY"), os.Getenv("ETSY_SECRET"), "/foo")
+}
@@ -0,0 +1,203 @@
+// Package figma implements the OAuth2 protocol for authenticating users through Figma.
+// This package can be used as a reference implementation of an OAuth2 provider for Goth.
+package figma
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// See https://www.figma.com/developers/api#oauth2
+const (
+	authURL         string = "https://www.figma.com/oauth"
+	tokenURL        string = "https://api.figma.com/v1/oauth/token"
+	refreshURL      string = "https://api.figma.com/v1/oauth/refresh"
+	endpointProfile string = "https://api.figma.com/v1/me"
+)
+
+// Scopes
+const (
+	ScopeCurrentUserRead   string = "current_user:read"
+	ScopeFileContentRead   string = "file_content:read"
+	ScopeFileMetadataRead  string = "file_metadata:read"
+	ScopeFileCommentsRead  string = "file_comments:read"
+	ScopeFileCommentsWrite string = "file_comments:write"
+	ScopeFileDevResources  string = "file_dev_resources:read"
+	ScopeProjectsRead      string = "projects:read"
+	ScopeWebhooksWrite     string = "webhooks:write"
+
+	// ScopeFileRead is the legacy scope granting read access to the user and
+	// their files. It is replaced by the granular scopes above.
+	ScopeFileRead string = "file_read"
+)
+
+// Provider is the implementation of `goth.Provider` for accessing Figma.
+type Provider struct {
+	ClientKey     string
+	Secret        string
+	CallbackURL   string
+	HTTPClient    *http.Client
+	config        *oauth2.Config
+	refreshConfig *oauth2.Config
+	providerName  string
+}
+
+// New creates a new Figma provider and sets up important connection details.
+// You should always call `figma.New` to get a new provider.  Never try to
+// create one manually.
+func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "figma",
+	}
+	p.config = newConfig(p, tokenURL, scopes)
+	// Figma refreshes tokens at a separate end-point
+	p.refreshConfig = newConfig(p, refreshURL, scopes)
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the figma package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks Figma for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state),
+	}, nil
+}
+
+// FetchUser will go to Figma and access basic information about the user.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		Provider:     p.Name(),
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	req, err := http.NewRequest("GET", endpointProfile, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+	}
+
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+
+	err = userFromReader(bytes.NewReader(bits), &user)
+	return user, err
+}
+
+func newConfig(provider *Provider, tokenURL string, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  authURL,
+			TokenURL: tokenURL,
+		},
+		Scopes: []string{},
+	}
+
+	if len(scopes) > 0 {
+		c.Scopes = append(c.Scopes, scopes...)
+	} else {
+		c.Scopes = append(c.Scopes, ScopeCurrentUserRead)
+	}
+	return c
+}
+
+func userFromReader(r io.Reader, user *goth.User) error {
+	u := struct {
+		ID     string `json:"id"`
+		Email  string `json:"email"`
+		Handle string `json:"handle"`
+		ImgURL string `json:"img_url"`
+	}{}
+
+	err := json.NewDecoder(r).Decode(&u)
+	if err != nil {
+		return err
+	}
+
+	user.UserID = u.ID
+	user.Email = u.Email
+	user.Name = u.Handle
+	user.NickName = u.Handle
+	user.AvatarURL = u.ImgURL
+	return nil
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token. Figma keeps
+// the refresh token, only the access token is renewed.
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	ts := p.refreshConfig.TokenSource(goth.ContextForClient(p.Client()), token)
+	newToken, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return newToken, err
+}
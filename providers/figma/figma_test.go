@@ -0,0 +1,108 @@
+package figma_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/figma"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.ClientKey, os.Getenv("FIGMA_KEY"))
+	a.Equal(p.Secret, os.Getenv("FIGMA_SECRET"))
+	a.Equal(p.CallbackURL, "/foo")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	session, err := p.BeginAuth("test_state")
+	s := session.(*figma.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "https://www.figma.com/oauth")
+	a.Contains(s.AuthURL, "scope=current_user%3Aread")
+}
+
+func Test_FetchUserAndRefresh(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/v1/me":
+			if req.Header.Get("Authorization") != "Bearer 1234567890" {
+				res.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(res, `{"id":"1234","email":"designer@example.com","handle":"Designer","img_url":"https://s3-alpha.figma.com/profile/1234"}`)
+		case "/v1/oauth/refresh":
+			if req.FormValue("refresh_token") != "refresh" {
+				res.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			res.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(res, `{"access_token":"0987654321","expires_in":7776000}`)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := provider()
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	user, err := p.FetchUser(&figma.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+	a.Equal("designer@example.com", user.Email)
+	a.Equal("Designer", user.NickName)
+	a.Equal("https://s3-alpha.figma.com/profile/1234", user.AvatarURL)
+
+	token, err := p.RefreshToken("refresh")
+	a.NoError(err)
+	a.Equal("0987654321", token.AccessToken)
+	a.Equal("refresh", token.RefreshToken)
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://www.figma.com/oauth","AccessToken":"1234567890"}`)
+	a.NoError(err)
+
+	s := session.(*figma.Session)
+	a.Equal(s.AuthURL, "https://www.figma.com/oauth")
+	a.Equal(s.AccessToken, "1234567890")
+}
+
+func provider() *figma.Provider {
+	return figma.New(os.Getenv("FIGMA_KEY"), os.Getenv("FIGMA_SECRET"), "/foo")
+}
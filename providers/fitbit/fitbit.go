@@ -1,5 +1,5 @@
-// Package fitbit implements the OAuth protocol for authenticating users through Fitbit.
-// This package can be used as a reference implementation of an OAuth provider for Goth.
+// Package fitbit implements the OAuth2 protocol for authenticating users through Fitbit.
+// This package can be used as a reference implementation of an OAuth2 provider for Goth.
 package fitbit
 
 import (
@@ -37,6 +37,29 @@ const (
 	ScopeSocial = "social"
 	// ScopeWeight includes weight and related information, such as body mass index, body fat percentage, and goals
 	ScopeWeight = "weight"
+	// ScopeCardioFitness includes the maximum or estimated maximum volume of oxygen uptake
+	ScopeCardioFitness = "cardio_fitness"
+	// ScopeElectrocardiogram includes the electrocardiogram readings
+	ScopeElectrocardiogram = "electrocardiogram"
+	// ScopeIrregularRhythmNotifications includes the irregular rhythm notifications
+	ScopeIrregularRhythmNotifications = "irregular_rhythm_notifications"
+	// ScopeOxygenSaturation includes the SpO2 data
+	ScopeOxygenSaturation = "oxygen_saturation"
+	// ScopeRespiratoryRate includes the breathing rate data
+	ScopeRespiratoryRate = "respiratory_rate"
+	// ScopeTemperature includes the skin and core temperature data
+	ScopeTemperature = "temperature"
+)
+
+// These are presets of scopes which are usually requested together. They can be
+// passed straight to New, e.g. `fitbit.New(key, secret, callback, fitbit.ScopesSleep...)`.
+var (
+	// ScopesActivity reads the user's activities, exercises and their GPS data.
+	ScopesActivity = []string{ScopeActivity, ScopeLocation, ScopeCardioFitness}
+	// ScopesHeartRate reads the user's heart rate and heart rhythm data.
+	ScopesHeartRate = []string{ScopeHeartRate, ScopeElectrocardiogram, ScopeIrregularRhythmNotifications}
+	// ScopesSleep reads the user's sleep logs and the vitals measured during sleep.
+	ScopesSleep = []string{ScopeSleep, ScopeOxygenSaturation, ScopeRespiratoryRate, ScopeTemperature}
 )
 
 // New creates a new Fitbit provider, and sets up important connection details.
@@ -48,6 +71,8 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 		Secret:       secret,
 		CallbackURL:  callbackURL,
 		providerName: "fitbit",
+		// Fitbit recommends PKCE for all application types.
+		pkce: true,
 	}
 	p.config = newConfig(p, scopes)
 	return p
@@ -61,6 +86,7 @@ type Provider struct {
 	HTTPClient   *http.Client
 	config       *oauth2.Config
 	providerName string
+	pkce         bool
 }
 
 // Name is the name used to retrieve this provider later.
@@ -73,6 +99,17 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -80,11 +117,25 @@ func (p *Provider) Client() *http.Client {
 // Debug is a no-op for the fitbit package.
 func (p *Provider) Debug(debug bool) {}
 
+// SetPKCE enables or disables PKCE, which is enabled by default. When disabled,
+// a code verifier can still be passed to the callback as `code_verifier`.
+// See https://dev.fitbit.com/build/reference/web-api/developer-guide/authorization/
+func (p *Provider) SetPKCE(enabled bool) {
+	p.pkce = enabled
+}
+
 // BeginAuth asks Fitbit for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	url := p.config.AuthCodeURL(state)
-	session := &Session{
-		AuthURL: url,
+	session := &Session{}
+	if p.pkce {
+		verifier, err := goth.GenerateVerifier()
+		if err != nil {
+			return nil, err
+		}
+		session.CodeVerifier = verifier
+		session.AuthURL = p.config.AuthCodeURL(state, oauth2.S256ChallengeOption(session.CodeVerifier))
+	} else {
+		session.AuthURL = p.config.AuthCodeURL(state)
 	}
 	return session, nil
 }
@@ -131,6 +182,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 func userFromReader(reader io.Reader, user *goth.User) error {
 	u := struct {
 		User struct {
+			EncodedID   string `json:"encodedId"`
 			Avatar      string `json:"avatar"`
 			Country     string `json:"country"`
 			FullName    string `json:"fullName"`
@@ -143,6 +195,9 @@ func userFromReader(reader io.Reader, user *goth.User) error {
 		return err
 	}
 
+	if user.UserID == "" {
+		user.UserID = u.User.EncodedID
+	}
 	user.Location = u.User.Country
 	user.Name = u.User.FullName
 	user.NickName = u.User.DisplayName
@@ -159,6 +214,9 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  authURL,
 			TokenURL: tokenURL,
+			// Fitbit expects the client credentials base64 encoded in a
+			// basic Authorization header
+			AuthStyle: oauth2.AuthStyleInHeader,
 		},
 		Scopes: []string{
 			ScopeProfile,
@@ -181,7 +239,7 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
 	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(oauth2.NoContext, token)
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
 	newToken, err := ts.Token()
 	if err != nil {
 		return nil, err
@@ -189,7 +247,7 @@ func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
 	return newToken, err
 }
 
-// RefreshTokenAvailable refresh token is not provided by fitbit
+// RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
 	return true
 }
@@ -1,6 +1,13 @@
 package fitbit_test
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 
@@ -40,6 +47,63 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "www.fitbit.com/oauth2/authorize")
 }
 
+func Test_BeginAuthPKCE(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := fitbit.New(os.Getenv("FITBIT_KEY"), os.Getenv("FITBIT_SECRET"), "/foo", fitbit.ScopesSleep...)
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*fitbit.Session)
+	a.NotEmpty(s.CodeVerifier)
+	a.Contains(s.AuthURL, "code_challenge_method=S256")
+	a.Contains(s.AuthURL, "scope=profile+sleep+oxygen_saturation+respiratory_rate+temperature")
+
+	p.SetPKCE(false)
+	session, err = p.BeginAuth("test_state")
+	a.NoError(err)
+	s = session.(*fitbit.Session)
+	a.Empty(s.CodeVerifier)
+	a.NotContains(s.AuthURL, "code_challenge")
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		id, secret, ok := req.BasicAuth()
+		if req.URL.Path != "/oauth2/token" || !ok || id != "client-id" || secret != "client-secret" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if req.FormValue("code") != "auth-code" || req.FormValue("code_verifier") != "verifier" {
+			res.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(res, `{"access_token":"access","expires_in":28800,"refresh_token":"refresh","scope":"profile","token_type":"Bearer","user_id":"GGNJL9"}`)
+	}))
+	defer server.Close()
+
+	p := fitbit.New("client-id", "client-secret", "/foo")
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	s := &fitbit.Session{CodeVerifier: "verifier"}
+	token, err := s.Authorize(p, url.Values{"code": {"auth-code"}})
+	a.NoError(err)
+	a.Equal("access", token)
+	a.Equal("refresh", s.RefreshToken)
+	a.Equal("GGNJL9", s.UserID)
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
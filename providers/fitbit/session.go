@@ -16,6 +16,7 @@ type Session struct {
 	RefreshToken string
 	ExpiresAt    time.Time
 	UserID       string
+	CodeVerifier string `json:",omitempty"`
 }
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the
@@ -31,14 +32,27 @@ func (s Session) GetAuthURL() (string, error) {
 // token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	token, err := p.config.Exchange(oauth2.NoContext, params.Get("code"), oauth2.SetAuthURLParam("code_verifier", params.Get("code_verifier")))
+	verifier := s.CodeVerifier
+	if verifier == "" {
+		verifier = params.Get("code_verifier")
+	}
+
+	var opts []oauth2.AuthCodeOption
+	if verifier != "" {
+		opts = append(opts, oauth2.VerifierOption(verifier))
+	}
+	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"), opts...)
 	if err != nil {
 		return "", err
 	}
+
+	if !token.Valid() {
+		return "", errors.New("Invalid token received from provider")
+	}
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
-	s.UserID = token.Extra("user_id").(string)
+	s.UserID, _ = token.Extra("user_id").(string)
 	return token.AccessToken, err
 }
 
@@ -48,9 +62,10 @@ func (s Session) Marshal() string {
 	return string(j)
 }
 
-// String is equivalent to Marshal.  It returns a JSON representation of the session.
+// String returns a JSON representation of the session, with its tokens
+// redacted.
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // UnmarshalSession will unmarshal a JSON string into a session.
@@ -14,6 +14,7 @@ import (
 	"strings"
 
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/option"
 	"golang.org/x/oauth2"
 )
 
@@ -42,21 +43,46 @@ var (
 // You should always call `github.New` to get a new Provider. Never try to create
 // one manually.
 func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
-	return NewCustomisedURL(clientKey, secret, callbackURL, AuthURL, TokenURL, ProfileURL, EmailURL, scopes...)
+	return NewWithOptions(clientKey, secret, callbackURL, option.WithScopes(scopes...))
 }
 
 // NewCustomisedURL is similar to New(...) but can be used to set custom URLs to connect to
 func NewCustomisedURL(clientKey, secret, callbackURL, authURL, tokenURL, profileURL, emailURL string, scopes ...string) *Provider {
-	p := &Provider{
-		ClientKey:    clientKey,
-		Secret:       secret,
-		CallbackURL:  callbackURL,
-		providerName: "github",
-		profileURL:   profileURL,
-		emailURL:     emailURL,
-	}
-	p.config = newConfig(p, authURL, tokenURL, scopes)
-	return p
+	return NewWithOptions(clientKey, secret, callbackURL,
+		option.WithEndpoint(option.AuthURL, authURL),
+		option.WithEndpoint(option.TokenURL, tokenURL),
+		option.WithEndpoint(option.ProfileURL, profileURL),
+		WithEmailURL(emailURL),
+		option.WithScopes(scopes...),
+	)
+}
+
+// NewWithOptions creates a new Github provider with the options, e.g.
+// option.WithScopes, or the endpoints of GitHub Enterprise with
+// option.WithEndpoint and WithEmailURL.
+func NewWithOptions(clientKey, secret, callbackURL string, opts ...option.Option) *Provider {
+	return option.Build(func(s *option.Settings) *Provider {
+		p := &Provider{
+			ClientKey:    clientKey,
+			Secret:       secret,
+			CallbackURL:  callbackURL,
+			HTTPClient:   s.HTTPClient,
+			providerName: "github",
+			profileURL:   s.URL(option.ProfileURL, ProfileURL),
+			emailURL:     s.URL(emailEndpoint, EmailURL),
+		}
+		p.config = newConfig(p, s.URL(option.AuthURL, AuthURL), s.URL(option.TokenURL, TokenURL), s.Scopes)
+		return p
+	}, opts...)
+}
+
+// emailEndpoint is the endpoint of the emails of the user.
+const emailEndpoint option.Endpoint = "email"
+
+// WithEmailURL overrides the endpoint of the emails of the user, e.g. of
+// GitHub Enterprise.
+func WithEmailURL(emailURL string) option.Option {
+	return option.WithEndpoint(emailEndpoint, emailURL)
 }
 
 // Provider is the implementation of `goth.Provider` for accessing Github.
@@ -81,6 +107,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/option"
 	"github.com/andreimerlescu/goth/providers/github"
 	"github.com/stretchr/testify/assert"
 )
@@ -30,6 +31,34 @@ func Test_NewCustomisedURL(t *testing.T) {
 	a.Contains(s.AuthURL, "http://authURL")
 }
 
+func Test_NewWithOptions(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := github.NewWithOptions("key", "secret", "/foo",
+		option.WithScopes("user:email"),
+		option.WithBaseURL("https://github.acme.com"),
+		option.WithName("github-acme"),
+	)
+	a.Equal("github-acme", p.Name())
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*github.Session)
+	a.Contains(s.AuthURL, "https://github.acme.com/login/oauth/authorize")
+	a.Contains(s.AuthURL, "scope=user%3Aemail")
+}
+
+func Test_Provider_String(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := github.New("github-key", "github-secret", "/foo")
+	for _, format := range []string{"%v", "%+v", "%#v"} {
+		s := fmt.Sprintf(format, p)
+		a.Contains(s, "github-key", format)
+		a.NotContains(s, "github-secret", format)
+	}
+}
+
 func Test_Implements_Provider(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -13,6 +13,7 @@ import (
 	"strconv"
 
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/option"
 	"golang.org/x/oauth2"
 )
 
@@ -47,20 +48,34 @@ type Provider struct {
 // You should always call `gitlab.New` to get a new provider.  Never try to
 // create one manually.
 func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
-	return NewCustomisedURL(clientKey, secret, callbackURL, AuthURL, TokenURL, ProfileURL, scopes...)
+	return NewWithOptions(clientKey, secret, callbackURL, option.WithScopes(scopes...))
 }
 
 // NewCustomisedURL is similar to New(...) but can be used to set custom URLs to connect to
 func NewCustomisedURL(clientKey, secret, callbackURL, authURL, tokenURL, profileURL string, scopes ...string) *Provider {
-	p := &Provider{
-		ClientKey:    clientKey,
-		Secret:       secret,
-		CallbackURL:  callbackURL,
-		providerName: "gitlab",
-		profileURL:   profileURL,
-	}
-	p.config = newConfig(p, authURL, tokenURL, scopes)
-	return p
+	return NewWithOptions(clientKey, secret, callbackURL,
+		option.WithEndpoint(option.AuthURL, authURL),
+		option.WithEndpoint(option.TokenURL, tokenURL),
+		option.WithEndpoint(option.ProfileURL, profileURL),
+		option.WithScopes(scopes...),
+	)
+}
+
+// NewWithOptions creates a new Gitlab provider with the options, e.g.
+// option.WithScopes, or option.WithBaseURL for GitLab CE or EE.
+func NewWithOptions(clientKey, secret, callbackURL string, opts ...option.Option) *Provider {
+	return option.Build(func(s *option.Settings) *Provider {
+		p := &Provider{
+			ClientKey:    clientKey,
+			Secret:       secret,
+			CallbackURL:  callbackURL,
+			HTTPClient:   s.HTTPClient,
+			providerName: "gitlab",
+			profileURL:   s.URL(option.ProfileURL, ProfileURL),
+		}
+		p.config = newConfig(p, s.URL(option.AuthURL, AuthURL), s.URL(option.TokenURL, TokenURL), s.Scopes)
+		return p
+	}, opts...)
 }
 
 // Name is the name used to retrieve this provider later.
@@ -73,6 +88,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -1,10 +1,12 @@
 package gitlab_test
 
 import (
+	"net/http"
 	"os"
 	"testing"
 
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/option"
 	"github.com/andreimerlescu/goth/providers/gitlab"
 	"github.com/stretchr/testify/assert"
 )
@@ -29,6 +31,21 @@ func Test_NewCustomisedURL(t *testing.T) {
 	a.Contains(s.AuthURL, "http://authURL")
 }
 
+func Test_NewWithOptions(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	client := &http.Client{}
+	p := gitlab.NewWithOptions("key", "secret", "/foo",
+		option.WithBaseURL("https://gitlab.acme.com"),
+		option.WithHTTPClient(client),
+	)
+	a.Same(client, p.Client())
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*gitlab.Session)
+	a.Contains(s.AuthURL, "https://gitlab.acme.com/oauth/authorize")
+}
+
 func Test_Implements_Provider(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -11,6 +11,8 @@ import (
 	"strings"
 
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/jwtbearer"
+	"github.com/andreimerlescu/goth/option"
 	"golang.org/x/oauth2"
 )
 
@@ -20,20 +22,33 @@ const endpointProfile string = "https://www.googleapis.com/oauth2/v2/userinfo"
 // You should always call `google.New` to get a new Provider. Never try to create
 // one manually.
 func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
-	p := &Provider{
-		ClientKey:    clientKey,
-		Secret:       secret,
-		CallbackURL:  callbackURL,
-		providerName: "google",
-
-		// We can get a refresh token from Google by this option.
-		// See https://developers.google.com/identity/protocols/oauth2/openid-connect#access-type-param
-		authCodeOptions: []oauth2.AuthCodeOption{
-			oauth2.AccessTypeOffline,
-		},
-	}
-	p.config = newConfig(p, scopes)
-	return p
+	return NewWithOptions(clientKey, secret, callbackURL, option.WithScopes(scopes...))
+}
+
+// NewWithOptions creates a new Google provider with the options, e.g.
+// option.WithScopes and option.WithHTTPClient.
+func NewWithOptions(clientKey, secret, callbackURL string, opts ...option.Option) *Provider {
+	return option.Build(func(s *option.Settings) *Provider {
+		p := &Provider{
+			ClientKey:    clientKey,
+			Secret:       secret,
+			CallbackURL:  callbackURL,
+			HTTPClient:   s.HTTPClient,
+			providerName: "google",
+			profileURL:   s.URL(option.ProfileURL, endpointProfile),
+
+			// We can get a refresh token from Google by this option.
+			// See https://developers.google.com/identity/protocols/oauth2/openid-connect#access-type-param
+			authCodeOptions: []oauth2.AuthCodeOption{
+				oauth2.AccessTypeOffline,
+			},
+		}
+		endpoint := Endpoint
+		endpoint.AuthURL = s.URL(option.AuthURL, endpoint.AuthURL)
+		endpoint.TokenURL = s.URL(option.TokenURL, endpoint.TokenURL)
+		p.config = newConfig(p, endpoint, s.Scopes)
+		return p
+	}, opts...)
 }
 
 // Provider is the implementation of `goth.Provider` for accessing Google.
@@ -45,6 +60,7 @@ type Provider struct {
 	config          *oauth2.Config
 	authCodeOptions []oauth2.AuthCodeOption
 	providerName    string
+	profileURL      string
 }
 
 // Name is the name used to retrieve this provider later.
@@ -57,6 +73,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 // Client returns an HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
@@ -100,7 +126,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	response, err := p.Client().Get(endpointProfile + "?access_token=" + url.QueryEscape(sess.AccessToken))
+	response, err := p.Client().Get(p.profileURL + "?access_token=" + url.QueryEscape(sess.AccessToken))
 	if err != nil {
 		return user, err
 	}
@@ -136,12 +162,12 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	return user, nil
 }
 
-func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+func newConfig(provider *Provider, endpoint oauth2.Endpoint, scopes []string) *oauth2.Config {
 	c := &oauth2.Config{
 		ClientID:     provider.ClientKey,
 		ClientSecret: provider.Secret,
 		RedirectURL:  provider.CallbackURL,
-		Endpoint:     Endpoint,
+		Endpoint:     endpoint,
 		Scopes:       []string{},
 	}
 
@@ -169,6 +195,12 @@ func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
 	return newToken, err
 }
 
+// TokenFromAssertion exchanges the assertion of a service account for an
+// access token, see jwtbearer.GoogleServiceAccount.
+func (p *Provider) TokenFromAssertion(assertion string) (*oauth2.Token, error) {
+	return jwtbearer.Exchange(p.Client(), p.config.Endpoint.TokenURL, assertion, nil)
+}
+
 // SetPrompt sets the prompt values for the google OAuth call. Use this to
 // force users to choose and account every time by passing "select_account",
 // for example.
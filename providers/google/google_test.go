@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/option"
 	"github.com/andreimerlescu/goth/providers/google"
 	"github.com/stretchr/testify/assert"
 )
@@ -95,11 +96,27 @@ func Test_BeginAuthWithLoginHint(t *testing.T) {
 	a.Contains(s.AuthURL, "login_hint=john%40example.com")
 }
 
+func Test_NewWithOptions(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := google.NewWithOptions("key", "secret", "/foo",
+		option.WithScopes("openid", "email"),
+		option.WithEndpoint(option.AuthURL, "http://127.0.0.1:8080/auth"),
+	)
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*google.Session)
+	a.Contains(s.AuthURL, "http://127.0.0.1:8080/auth")
+	a.Contains(s.AuthURL, "scope=openid+email")
+	a.Contains(s.AuthURL, "access_type=offline")
+}
+
 func Test_Implements_Provider(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
 
 	a.Implements((*goth.Provider)(nil), googleProvider())
+	a.Implements((*goth.AssertionProvider)(nil), googleProvider())
 }
 
 func Test_SessionFromJSON(t *testing.T) {
@@ -0,0 +1,176 @@
+// Package guest implements a provider signing in fake users, so development
+// and staging environments can be tested without the credentials of a real
+// provider.
+//
+// The provider is disabled until Enabled is set, which should come from the
+// configuration of the environment, never be hardcoded:
+//
+//	p := guest.New("https://staging.example.com/auth/guest/callback",
+//		goth.User{UserID: "admin", Name: "Admin", Email: "admin@example.com"},
+//		goth.User{UserID: "viewer", Name: "Viewer", Email: "viewer@example.com"},
+//	)
+//	p.Enabled = os.Getenv("GUEST_LOGIN") == "true"
+//
+// gothic.BeginAuthHandler signs in the user whose UserID is the "user"
+// parameter of the request, or the first user without it, and redirects to the
+// callback handled with gothic.CompleteUserAuth.
+package guest
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrDisabled is returned by every step of the authentication until the
+	// provider is enabled.
+	ErrDisabled = errors.New("guest: the provider is disabled")
+	// ErrUnknownUser is returned for users which are not configured.
+	ErrUnknownUser = errors.New("guest: unknown user")
+)
+
+// New creates a new, disabled, guest provider signing in one of the users.
+// You should always call `guest.New` to get a new Provider. Never try to
+// create one manually.
+func New(callbackURL string, users ...goth.User) *Provider {
+	return &Provider{
+		CallbackURL:  callbackURL,
+		Users:        users,
+		providerName: "guest",
+	}
+}
+
+// Provider is the implementation of `goth.Provider` for guest users.
+type Provider struct {
+	CallbackURL string
+	// Enabled allows users to sign in, it must only be set in environments
+	// where anyone may impersonate the users.
+	Enabled bool
+	// Users are the users who can sign in, selected by their UserID.
+	Users        []goth.User
+	HTTPClient   *http.Client
+	providerName string
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Local reports that the provider is a goth.LocalProvider: the guest users are signed in without an issuer.
+func (p *Provider) Local() bool {
+	return true
+}
+
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the guest package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth signs in the first user.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return p.BeginAuthWithParams(state, url.Values{})
+}
+
+// BeginAuthWithParams signs in the user selected by the "user" parameter, and
+// sends them straight to the callback.
+func (p *Provider) BeginAuthWithParams(state string, params goth.Params) (goth.Session, error) {
+	if !p.Enabled {
+		return nil, ErrDisabled
+	}
+	user, err := p.user(params.Get("user"))
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(p.CallbackURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("state", state)
+	u.RawQuery = q.Encode()
+
+	return &Session{
+		AuthURL: u.String(),
+		UserID:  user.UserID,
+	}, nil
+}
+
+// FetchUser returns the configured user of the session.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	if !p.Enabled {
+		return goth.User{Provider: p.Name()}, ErrDisabled
+	}
+	if sess.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return goth.User{Provider: p.Name()}, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	user, err := p.user(sess.UserID)
+	if err != nil {
+		return goth.User{Provider: p.Name()}, err
+	}
+	user.Provider = p.Name()
+	user.AccessToken = sess.AccessToken
+
+	raw := make(map[string]interface{}, len(user.RawData)+1)
+	for k, v := range user.RawData {
+		raw[k] = v
+	}
+	raw["guest"] = true
+	user.RawData = raw
+	return user, nil
+}
+
+// RefreshToken refresh token is not provided by guest
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by guest")
+}
+
+// RefreshTokenAvailable refresh token is not provided by guest
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
+
+// user returns the configured user with the ID, or the first one.
+func (p *Provider) user(id string) (goth.User, error) {
+	for _, u := range p.Users {
+		if id == "" || u.UserID == id {
+			return u, nil
+		}
+	}
+	return goth.User{}, ErrUnknownUser
+}
+
+func newAccessToken() (string, error) {
+	b, err := goth.RandomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	return "guest-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,110 @@
+package guest_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/guest"
+	"github.com/stretchr/testify/assert"
+)
+
+func provider() *guest.Provider {
+	p := guest.New("https://staging.example.com/auth/guest/callback?provider=guest",
+		goth.User{UserID: "admin", Name: "Admin", Email: "admin@example.com", RawData: map[string]interface{}{"role": "admin"}},
+		goth.User{UserID: "viewer", Name: "Viewer", Email: "viewer@example.com"},
+	)
+	p.Enabled = true
+	return p
+}
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := guest.New("/foo")
+	a.Equal("/foo", p.CallbackURL)
+	a.Equal("guest", p.Name())
+	a.False(p.Enabled)
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+	a.Implements((*goth.BeginAuthWithParamsProvider)(nil), provider())
+}
+
+func Test_Disabled(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+
+	p.Enabled = false
+	_, err = p.BeginAuth("test_state")
+	a.Equal(guest.ErrDisabled, err)
+	_, err = session.Authorize(p, url.Values{})
+	a.Equal(guest.ErrDisabled, err)
+	_, err = p.FetchUser(&guest.Session{UserID: "admin", AccessToken: "guest-token"})
+	a.Equal(guest.ErrDisabled, err)
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*guest.Session)
+	a.Equal("admin", s.UserID)
+	a.Equal("https://staging.example.com/auth/guest/callback?provider=guest&state=test_state", s.AuthURL)
+
+	session, err = p.BeginAuthWithParams("test_state", url.Values{"user": {"viewer"}})
+	a.NoError(err)
+	a.Equal("viewer", session.(*guest.Session).UserID)
+
+	_, err = p.BeginAuthWithParams("test_state", url.Values{"user": {"root"}})
+	a.Equal(guest.ErrUnknownUser, err)
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	session, err := p.BeginAuthWithParams("test_state", url.Values{"user": {"admin"}})
+	a.NoError(err)
+	_, err = p.FetchUser(session)
+	a.Error(err)
+
+	token, err := session.Authorize(p, url.Values{})
+	a.NoError(err)
+	a.NotEmpty(token)
+
+	user, err := p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("guest", user.Provider)
+	a.Equal("admin", user.UserID)
+	a.Equal("admin@example.com", user.Email)
+	a.Equal(token, user.AccessToken)
+	a.Equal(true, user.RawData["guest"])
+	a.Equal("admin", user.RawData["role"])
+
+	// the configured user is left untouched
+	a.Nil(p.Users[0].RawData["guest"])
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	s, err := p.UnmarshalSession(`{"AuthURL":"https://staging.example.com/auth/guest/callback","UserID":"viewer","AccessToken":"guest-token"}`)
+	a.NoError(err)
+	session := s.(*guest.Session)
+	a.Equal("viewer", session.UserID)
+	a.Equal("guest-token", session.AccessToken)
+}
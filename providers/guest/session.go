@@ -0,0 +1,62 @@
+package guest
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Session stores data during the auth process of a guest user.
+type Session struct {
+	AuthURL     string
+	UserID      string
+	AccessToken string
+}
+
+var _ goth.Session = &Session{}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` function on the guest provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize the session of the user selected when it began, and return a
+// random access token.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	if !p.Enabled {
+		return "", ErrDisabled
+	}
+	_, err := p.user(s.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	s.AccessToken, err = newAccessToken()
+	if err != nil {
+		return "", err
+	}
+	return s.AccessToken, nil
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return goth.RedactJSON(s.Marshal())
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	s := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
+	return s, err
+}
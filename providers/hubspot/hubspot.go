@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -20,6 +21,26 @@ var (
 
 const (
 	userEndpoint = "https://api.hubapi.com/oauth/v1/access-tokens/"
+
+	// HubSpot access tokens expire after 30 minutes.
+	defaultTokenLifetime = 30 * time.Minute
+)
+
+// Commonly used HubSpot scopes, see https://developers.hubspot.com/docs/api/scopes
+const (
+	ScopeOAuth               = "oauth"
+	ScopeContactsRead        = "crm.objects.contacts.read"
+	ScopeContactsWrite       = "crm.objects.contacts.write"
+	ScopeCompaniesRead       = "crm.objects.companies.read"
+	ScopeCompaniesWrite      = "crm.objects.companies.write"
+	ScopeDealsRead           = "crm.objects.deals.read"
+	ScopeDealsWrite          = "crm.objects.deals.write"
+	ScopeAccountInfoRead     = "account-info.security.read"
+	ScopeCRMSchemasRead      = "crm.schemas.contacts.read"
+	ScopeSettingsUsersRead   = "settings.users.read"
+	ScopeSettingsUsersWrite  = "settings.users.write"
+	ScopeFormsRead           = "forms"
+	ScopeTimelineEventsWrite = "timeline"
 )
 
 type hubspotUser struct {
@@ -45,6 +66,8 @@ type Provider struct {
 	HTTPClient   *http.Client
 	config       *oauth2.Config
 	providerName string
+
+	optionalScopes []string
 }
 
 // New creates a new Hubspot provider and sets up important connection details.
@@ -71,6 +94,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -80,11 +113,22 @@ func (p *Provider) Debug(debug bool) {}
 
 // BeginAuth asks Hubspot for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	var opts []oauth2.AuthCodeOption
+	if len(p.optionalScopes) > 0 {
+		opts = append(opts, oauth2.SetAuthURLParam("optional_scope", strings.Join(p.optionalScopes, " ")))
+	}
 	return &Session{
-		AuthURL: p.config.AuthCodeURL(state),
+		AuthURL: p.config.AuthCodeURL(state, opts...),
 	}, nil
 }
 
+// SetOptionalScopes sets the optional_scope parameter for the HubSpot OAuth call.
+// Optional scopes are granted when the installing portal's subscription allows
+// them and silently dropped otherwise, instead of failing the installation.
+func (p *Provider) SetOptionalScopes(scopes ...string) {
+	p.optionalScopes = scopes
+}
+
 // FetchUser will go to Hubspot and access basic information about the user.
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	s := session.(*Session)
@@ -92,6 +136,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		AccessToken:  s.AccessToken,
 		Provider:     p.Name(),
 		RefreshToken: s.RefreshToken,
+		ExpiresAt:    s.ExpiresAt,
 	}
 
 	if user.AccessToken == "" {
@@ -119,17 +164,20 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, err
 	}
 
-	// Extract the user data we got from Google into our goth.User.
+	// Extract the user data we got from HubSpot into our goth.User.
 	user.Email = u.User
 	user.UserID = strconv.Itoa(u.UserID)
-	accessTokenExpiration := time.Now()
-	if u.ExpiresIn > 0 {
-		accessTokenExpiration = accessTokenExpiration.Add(time.Duration(u.ExpiresIn) * time.Second)
-	} else {
-		accessTokenExpiration = accessTokenExpiration.Add(30 * time.Minute)
+	user.NickName = u.HubDomain
+	if user.ExpiresAt.IsZero() {
+		accessTokenExpiration := goth.Now()
+		if u.ExpiresIn > 0 {
+			accessTokenExpiration = accessTokenExpiration.Add(time.Duration(u.ExpiresIn) * time.Second)
+		} else {
+			accessTokenExpiration = accessTokenExpiration.Add(defaultTokenLifetime)
+		}
+		user.ExpiresAt = accessTokenExpiration
 	}
-	user.ExpiresAt = accessTokenExpiration
-	// Google provides other useful fields such as 'hd'; get them from RawData
+	// HubSpot provides other useful fields such as 'hub_id' and 'scopes'; get them from RawData
 	if err := json.Unmarshal(responseBytes, &user.RawData); err != nil {
 		return user, err
 	}
@@ -143,8 +191,9 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 		ClientSecret: provider.Secret,
 		RedirectURL:  provider.CallbackURL,
 		Endpoint: oauth2.Endpoint{
-			AuthURL:  AuthURL,
-			TokenURL: TokenURL,
+			AuthURL:   AuthURL,
+			TokenURL:  TokenURL,
+			AuthStyle: oauth2.AuthStyleInParams,
 		},
 		Scopes: []string{},
 	}
@@ -156,6 +205,25 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 	return c
 }
 
+// HubID returns the ID of the HubSpot portal (hub) that installed the app for a
+// user fetched by this provider. API calls made with the token act on this portal.
+func HubID(user goth.User) int {
+	hubID, _ := user.RawData["hub_id"].(float64)
+	return int(hubID)
+}
+
+// Scopes returns the scopes granted to the access token of a user fetched by this provider.
+func Scopes(user goth.User) []string {
+	var scopes []string
+	values, _ := user.RawData["scopes"].([]interface{})
+	for _, value := range values {
+		if scope, ok := value.(string); ok {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
 // RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
 	return true
@@ -1,9 +1,16 @@
 package hubspot_test
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
 	"github.com/andreimerlescu/goth/providers/hubspot"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/andreimerlescu/goth"
 	"github.com/stretchr/testify/assert"
@@ -35,6 +42,52 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "https://app.hubspot.com/oauth/authoriz")
 }
 
+func Test_BeginAuthOptionalScopes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := hubspot.New(os.Getenv("HUBSPOT_KEY"), os.Getenv("HUBSPOT_SECRET"), "/foo", hubspot.ScopeOAuth)
+	p.SetOptionalScopes(hubspot.ScopeContactsRead, hubspot.ScopeDealsRead)
+	session, err := p.BeginAuth("test_state")
+	s := session.(*hubspot.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "scope=oauth")
+	a.Contains(s.AuthURL, "optional_scope=crm.objects.contacts.read+crm.objects.deals.read")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/oauth/v1/access-tokens/1234567890" {
+			res.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(res, `{"token":"1234567890","user":"test@hubspot.com","hub_domain":"demo.hubapi.com","scopes":["oauth","crm.objects.contacts.read"],"hub_id":62515,"app_id":456,"expires_in":1754,"user_id":123,"token_type":"access"}`)
+	}))
+	defer server.Close()
+
+	p := provider()
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	expiresAt := time.Now().Add(30 * time.Minute).Truncate(time.Second)
+	user, err := p.FetchUser(&hubspot.Session{AccessToken: "1234567890", RefreshToken: "refresh", ExpiresAt: expiresAt})
+	a.NoError(err)
+	a.Equal("123", user.UserID)
+	a.Equal("test@hubspot.com", user.Email)
+	a.Equal("refresh", user.RefreshToken)
+	a.Equal(expiresAt, user.ExpiresAt)
+	a.Equal(62515, hubspot.HubID(user))
+	a.Equal([]string{"oauth", "crm.objects.contacts.read"}, hubspot.Scopes(user))
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"github.com/andreimerlescu/goth"
-	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with intercom.
@@ -28,7 +27,7 @@ func (s Session) GetAuthURL() (string, error) {
 // Authorize the session with intercom and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	token, err := p.config.Exchange(oauth2.NoContext, params.Get("code"))
+	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
 	if err != nil {
 		return "", err
 	}
@@ -49,7 +48,7 @@ func (s Session) Marshal() string {
 }
 
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // UnmarshalSession will unmarshal a JSON string into a session.
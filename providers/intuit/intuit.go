@@ -0,0 +1,226 @@
+// Package intuit implements the OpenID Connect protocol for authenticating users through Intuit,
+// including the QuickBooks Online company (realm) a user connects.
+// This package can be used as a reference implementation of an OAuth2 provider for Goth.
+package intuit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// URLs and endpoints
+const (
+	authURL                string = "https://appcenter.intuit.com/connect/oauth2"
+	tokenURL               string = "https://oauth.platform.intuit.com/oauth2/v1/tokens/bearer"
+	endpointProfile        string = "https://accounts.platform.intuit.com/v1/openid_connect/userinfo"
+	endpointSandboxProfile string = "https://sandbox-accounts.platform.intuit.com/v1/openid_connect/userinfo"
+)
+
+// Scopes
+const (
+	ScopeAccounting string = "com.intuit.quickbooks.accounting"
+	ScopePayment    string = "com.intuit.quickbooks.payment"
+	ScopeOpenID     string = "openid"
+	ScopeProfile    string = "profile"
+	ScopeEmail      string = "email"
+	ScopePhone      string = "phone"
+	ScopeAddress    string = "address"
+)
+
+// Provider is the implementation of `goth.Provider` for accessing Intuit.
+type Provider struct {
+	ClientKey    string
+	Secret       string
+	CallbackURL  string
+	HTTPClient   *http.Client
+	config       *oauth2.Config
+	providerName string
+	profileURL   string
+}
+
+// New creates a new Intuit provider and sets up important connection details.
+// You should always call `intuit.New` to get a new provider.  Never try to
+// create one manually.
+func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "intuit",
+		profileURL:   endpointProfile,
+	}
+	p.config = newConfig(p, scopes)
+	return p
+}
+
+// NewSandbox is similar to New(...) but fetches user information from the
+// sandbox environment that development keys are issued for.
+func NewSandbox(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	p := New(clientKey, secret, callbackURL, scopes...)
+	p.profileURL = endpointSandboxProfile
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the intuit package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks Intuit for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state),
+	}, nil
+}
+
+// FetchUser will go to Intuit and access basic information about the user.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		Provider:     p.Name(),
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+		IDToken:      sess.IDToken,
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	req, err := http.NewRequest("GET", p.profileURL, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+	}
+
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+	user.RawData["realmId"] = sess.RealmID
+
+	err = userFromReader(bytes.NewReader(bits), &user)
+	return user, err
+}
+
+// RealmID returns the ID of the QuickBooks company a user fetched by this
+// provider connected. Every QuickBooks Online API call is scoped to it.
+func RealmID(user goth.User) string {
+	realmID, _ := user.RawData["realmId"].(string)
+	return realmID
+}
+
+func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   authURL,
+			TokenURL:  tokenURL,
+			AuthStyle: oauth2.AuthStyleInHeader,
+		},
+		Scopes: []string{},
+	}
+
+	if len(scopes) > 0 {
+		c.Scopes = append(c.Scopes, scopes...)
+	} else {
+		c.Scopes = append(c.Scopes, ScopeAccounting, ScopeOpenID, ScopeProfile, ScopeEmail)
+	}
+	return c
+}
+
+func userFromReader(r io.Reader, user *goth.User) error {
+	u := struct {
+		ID        string `json:"sub"`
+		Email     string `json:"email"`
+		FirstName string `json:"givenName"`
+		LastName  string `json:"familyName"`
+		Address   struct {
+			Locality string `json:"locality"`
+			Country  string `json:"country"`
+		} `json:"address"`
+	}{}
+
+	err := json.NewDecoder(r).Decode(&u)
+	if err != nil {
+		return err
+	}
+
+	user.UserID = u.ID
+	user.Email = u.Email
+	user.FirstName = u.FirstName
+	user.LastName = u.LastName
+	if u.FirstName != "" || u.LastName != "" {
+		user.Name = fmt.Sprintf("%s %s", u.FirstName, u.LastName)
+	}
+	user.Location = u.Address.Locality
+	return nil
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
+	newToken, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return newToken, err
+}
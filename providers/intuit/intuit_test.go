@@ -0,0 +1,113 @@
+package intuit_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/intuit"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.ClientKey, os.Getenv("INTUIT_KEY"))
+	a.Equal(p.Secret, os.Getenv("INTUIT_SECRET"))
+	a.Equal(p.CallbackURL, "/foo")
+}
+
+func Test_Name(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Equal(provider().Name(), "intuit")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	session, err := p.BeginAuth("test_state")
+	s := session.(*intuit.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "https://appcenter.intuit.com/connect/oauth2")
+	a.Contains(s.AuthURL, "scope=com.intuit.quickbooks.accounting+openid+profile+email")
+}
+
+func Test_AuthorizeCapturesRealmID(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/oauth2/v1/tokens/bearer":
+			res.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(res, `{"access_token":"1234567890","refresh_token":"AB11","token_type":"bearer","expires_in":3600,"x_refresh_token_expires_in":8726400,"id_token":"eyJ.e30.sig"}`)
+		case "/v1/openid_connect/userinfo":
+			if req.Header.Get("Authorization") != "Bearer 1234567890" {
+				res.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(res, `{"sub":"1182d6ec-2a1f-4aa3-af3d-b26c2f1a4b3c","email":"john@doe.com","emailVerified":true,"givenName":"John","familyName":"Doe"}`)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := intuit.NewSandbox("key", "secret", "/foo")
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	s := &intuit.Session{}
+	_, err := s.Authorize(p, url.Values{"code": {"AB1"}, "state": {"test_state"}, "realmId": {"4620816365178453710"}})
+	a.NoError(err)
+	a.Equal("4620816365178453710", s.RealmID)
+	a.Equal("eyJ.e30.sig", s.IDToken)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("1182d6ec-2a1f-4aa3-af3d-b26c2f1a4b3c", user.UserID)
+	a.Equal("john@doe.com", user.Email)
+	a.Equal("John Doe", user.Name)
+	a.Equal("4620816365178453710", intuit.RealmID(user))
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://appcenter.intuit.com/connect/oauth2","AccessToken":"1234567890","RealmID":"123"}`)
+	a.NoError(err)
+
+	s := session.(*intuit.Session)
+	a.Equal(s.AuthURL, "https://appcenter.intuit.com/connect/oauth2")
+	a.Equal(s.AccessToken, "1234567890")
+	a.Equal(s.RealmID, "123")
+}
+
+func provider() *intuit.Provider {
+	return intuit.New(os.Getenv("INTUIT_KEY"), os.Getenv("INTUIT_SECRET"), "/foo")
+}
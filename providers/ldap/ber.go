@@ -0,0 +1,191 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BER tags used by LDAP, see https://www.rfc-editor.org/rfc/rfc4511#section-5.1
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x30
+	tagSet         = 0x31
+
+	classApplication = 0x40
+	classContext     = 0x80
+	constructed      = 0x20
+)
+
+// maxPacketSize bounds the size of the messages read from servers, and
+// maxDepth the nesting of their elements.
+const (
+	maxPacketSize = 16 << 20
+	maxDepth      = 32
+)
+
+// berPacket is a decoded BER element. Tags are single bytes, which is all
+// LDAP uses.
+type berPacket struct {
+	tag      byte
+	value    []byte
+	children []*berPacket
+}
+
+func (p *berPacket) constructed() bool {
+	return p.tag&constructed != 0
+}
+
+func (p *berPacket) int() (int64, error) {
+	if len(p.value) == 0 || len(p.value) > 8 {
+		return 0, errors.New("ldap: invalid integer")
+	}
+	v := int64(int8(p.value[0]))
+	for _, b := range p.value[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+func (p *berPacket) string() string {
+	return string(p.value)
+}
+
+// readPacket reads a BER element from the stream.
+func readPacket(r *bufio.Reader) (*berPacket, error) {
+	tag, length, err := readHeader(r.ReadByte)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxPacketSize {
+		return nil, fmt.Errorf("ldap: BER element of %d bytes is too large", length)
+	}
+
+	value := make([]byte, length)
+	_, err = io.ReadFull(r, value)
+	if err != nil {
+		return nil, err
+	}
+	return parsePacket(tag, value, 0)
+}
+
+// readHeader reads the tag and the length of a BER element.
+func readHeader(next func() (byte, error)) (byte, int, error) {
+	tag, err := next()
+	if err != nil {
+		return 0, 0, err
+	}
+	if tag&0x1f == 0x1f {
+		return 0, 0, errors.New("ldap: multi-byte BER tags are not supported")
+	}
+
+	first, err := next()
+	if err != nil {
+		return 0, 0, err
+	}
+	length := int(first)
+	if first&0x80 != 0 {
+		n := int(first & 0x7f)
+		if n == 0 || n > 4 {
+			return 0, 0, errors.New("ldap: unsupported BER length")
+		}
+		length = 0
+		for i := 0; i < n; i++ {
+			b, err := next()
+			if err != nil {
+				return 0, 0, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	return tag, length, nil
+}
+
+// parsePacket decodes the children of constructed elements, which must fit
+// in the value of their parent and be nested at most maxDepth deep.
+func parsePacket(tag byte, value []byte, depth int) (*berPacket, error) {
+	p := &berPacket{tag: tag, value: value}
+	if !p.constructed() {
+		return p, nil
+	}
+	if depth >= maxDepth {
+		return nil, errors.New("ldap: BER elements are nested too deeply")
+	}
+	for rest := value; len(rest) > 0; {
+		offset := 0
+		childTag, length, err := readHeader(func() (byte, error) {
+			if offset == len(rest) {
+				return 0, errTruncated
+			}
+			offset++
+			return rest[offset-1], nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if length > len(rest)-offset {
+			return nil, errTruncated
+		}
+		child, err := parsePacket(childTag, rest[offset:offset+length], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, child)
+		rest = rest[offset+length:]
+	}
+	return p, nil
+}
+
+var errTruncated = errors.New("ldap: truncated BER element")
+
+// ber encodes an element with the content.
+func ber(tag byte, content []byte) []byte {
+	n := len(content)
+	var header []byte
+	switch {
+	case n < 0x80:
+		header = []byte{tag, byte(n)}
+	case n < 0x100:
+		header = []byte{tag, 0x81, byte(n)}
+	case n < 0x10000:
+		header = []byte{tag, 0x82, byte(n >> 8), byte(n)}
+	default:
+		header = []byte{tag, 0x84, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+	return append(header, content...)
+}
+
+func berConstructed(tag byte, children ...[]byte) []byte {
+	var content []byte
+	for _, child := range children {
+		content = append(content, child...)
+	}
+	return ber(tag, content)
+}
+
+func berInt(tag byte, v int64) []byte {
+	var content []byte
+	for {
+		content = append([]byte{byte(v)}, content...)
+		if (v < 0x80 && v >= -0x80) || len(content) == 8 {
+			break
+		}
+		v >>= 8
+	}
+	return ber(tag, content)
+}
+
+func berString(tag byte, s string) []byte {
+	return ber(tag, []byte(s))
+}
+
+func berBool(v bool) []byte {
+	if v {
+		return ber(tagBoolean, []byte{0xff})
+	}
+	return ber(tagBoolean, []byte{0x00})
+}
@@ -0,0 +1,251 @@
+package ldap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Protocol operations, see https://www.rfc-editor.org/rfc/rfc4511#section-4.2
+const (
+	opBindRequest            = classApplication | constructed | 0
+	opBindResponse           = classApplication | constructed | 1
+	opUnbindRequest          = classApplication | 2
+	opSearchRequest          = classApplication | constructed | 3
+	opSearchResultEntry      = classApplication | constructed | 4
+	opSearchResultDone       = classApplication | constructed | 5
+	opSearchResultRef        = classApplication | constructed | 19
+	opExtendedRequest        = classApplication | constructed | 23
+	opExtendedResponse       = classApplication | constructed | 24
+	oidStartTLS              = "1.3.6.1.4.1.1466.20037"
+	resultSuccess            = 0
+	resultInvalidCredentials = 49
+)
+
+// ResultError is an error result returned by the LDAP server.
+type ResultError struct {
+	Code    int64
+	Message string
+}
+
+func (e *ResultError) Error() string {
+	return fmt.Sprintf("ldap: result code %d: %s", e.Code, e.Message)
+}
+
+// entry is an entry returned by a search.
+type entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// conn is a minimal LDAPv3 client connection.
+type conn struct {
+	c         net.Conn
+	r         *bufio.Reader
+	messageID int64
+}
+
+// dial connects to an ldap:// or ldaps:// URL, and upgrades ldap:// connections
+// with StartTLS when asked to.
+func dial(serverURL string, startTLS bool, tlsConfig *tls.Config, timeout time.Duration) (*conn, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Hostname()
+	port := u.Port()
+
+	config := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if tlsConfig != nil {
+		config = tlsConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName = host
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var c net.Conn
+	switch u.Scheme {
+	case "ldap":
+		if port == "" {
+			port = "389"
+		}
+		c, err = dialer.Dial("tcp", net.JoinHostPort(host, port))
+	case "ldaps":
+		if port == "" {
+			port = "636"
+		}
+		c, err = tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), config)
+	default:
+		return nil, fmt.Errorf("ldap: unsupported URL scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lc := &conn{c: c, r: bufio.NewReader(c)}
+	if timeout > 0 {
+		_ = c.SetDeadline(time.Now().Add(timeout))
+	}
+	if u.Scheme == "ldap" && startTLS {
+		err = lc.startTLS(config)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return lc, nil
+}
+
+func (c *conn) send(op []byte) (int64, error) {
+	c.messageID++
+	_, err := c.c.Write(berConstructed(tagSequence, berInt(tagInteger, c.messageID), op))
+	return c.messageID, err
+}
+
+// receive reads the next message for the request, and returns its operation.
+func (c *conn) receive(id int64) (*berPacket, error) {
+	for {
+		msg, err := readPacket(c.r)
+		if err != nil {
+			return nil, err
+		}
+		if msg.tag != tagSequence || len(msg.children) < 2 {
+			return nil, errors.New("ldap: invalid message")
+		}
+		msgID, err := msg.children[0].int()
+		if err != nil {
+			return nil, err
+		}
+		if msgID == id {
+			return msg.children[1], nil
+		}
+		// unsolicited notifications have the ID 0, and mean the server is
+		// closing the connection
+		if msgID == 0 {
+			return nil, errors.New("ldap: connection closed by the server")
+		}
+	}
+}
+
+// result checks the LDAPResult of a response.
+func result(op *berPacket, tag byte) error {
+	if op.tag != tag || len(op.children) < 3 {
+		return errors.New("ldap: unexpected response")
+	}
+	code, err := op.children[0].int()
+	if err != nil {
+		return err
+	}
+	if code != resultSuccess {
+		return &ResultError{Code: code, Message: op.children[2].string()}
+	}
+	return nil
+}
+
+func (c *conn) startTLS(config *tls.Config) error {
+	id, err := c.send(berConstructed(opExtendedRequest, berString(classContext|0, oidStartTLS)))
+	if err != nil {
+		return err
+	}
+	op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	err = result(op, opExtendedResponse)
+	if err != nil {
+		return err
+	}
+
+	tc := tls.Client(c.c, config)
+	err = tc.Handshake()
+	if err != nil {
+		return err
+	}
+	c.c = tc
+	c.r = bufio.NewReader(tc)
+	return nil
+}
+
+// bind authenticates the connection with a simple bind.
+func (c *conn) bind(dn, password string) error {
+	id, err := c.send(berConstructed(opBindRequest,
+		berInt(tagInteger, 3),
+		berString(tagOctetString, dn),
+		berString(classContext|0, password),
+	))
+	if err != nil {
+		return err
+	}
+	op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	return result(op, opBindResponse)
+}
+
+// search returns the entries of the subtree of baseDN matching the filter.
+func (c *conn) search(baseDN, filter string, attributes []string) ([]entry, error) {
+	compiled, err := compileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make([][]byte, 0, len(attributes))
+	for _, attr := range attributes {
+		attrs = append(attrs, berString(tagOctetString, attr))
+	}
+
+	id, err := c.send(berConstructed(opSearchRequest,
+		berString(tagOctetString, baseDN),
+		berInt(tagEnumerated, 2), // wholeSubtree
+		berInt(tagEnumerated, 0), // neverDerefAliases
+		berInt(tagInteger, 0),    // no size limit
+		berInt(tagInteger, 0),    // no time limit
+		berBool(false),
+		compiled,
+		berConstructed(tagSequence, attrs...),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	for {
+		op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		switch op.tag {
+		case opSearchResultEntry:
+			if len(op.children) < 2 {
+				return nil, errors.New("ldap: invalid search result entry")
+			}
+			e := entry{DN: op.children[0].string(), Attributes: map[string][]string{}}
+			for _, attr := range op.children[1].children {
+				if len(attr.children) < 2 {
+					continue
+				}
+				name := attr.children[0].string()
+				for _, value := range attr.children[1].children {
+					e.Attributes[name] = append(e.Attributes[name], value.string())
+				}
+			}
+			entries = append(entries, e)
+		case opSearchResultRef:
+			// referrals to other servers are not followed
+		case opSearchResultDone:
+			return entries, result(op, opSearchResultDone)
+		default:
+			return nil, errors.New("ldap: unexpected search response")
+		}
+	}
+}
+
+func (c *conn) close() error {
+	_, _ = c.send(ber(opUnbindRequest, nil))
+	return c.c.Close()
+}
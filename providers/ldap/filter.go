@@ -0,0 +1,227 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Filter choices, see https://www.rfc-editor.org/rfc/rfc4511#section-4.5.1.7
+const (
+	filterAnd             = classContext | constructed | 0
+	filterOr              = classContext | constructed | 1
+	filterNot             = classContext | constructed | 2
+	filterEqualityMatch   = classContext | constructed | 3
+	filterSubstrings      = classContext | constructed | 4
+	filterGreaterOrEqual  = classContext | constructed | 5
+	filterLessOrEqual     = classContext | constructed | 6
+	filterPresent         = classContext | 7
+	filterApproxMatch     = classContext | constructed | 8
+	filterExtensibleMatch = classContext | constructed | 9
+)
+
+// EscapeFilter escapes a value to be used in a search filter, as in
+// https://www.rfc-editor.org/rfc/rfc4515#section-3
+func EscapeFilter(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch c {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// compileFilter encodes the string representation of a search filter.
+func compileFilter(filter string) ([]byte, error) {
+	encoded, rest, err := parseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("ldap: unexpected %q after the filter", rest)
+	}
+	return encoded, nil
+}
+
+func parseFilter(s string) ([]byte, string, error) {
+	if !strings.HasPrefix(s, "(") {
+		return nil, "", errors.New("ldap: filters must be enclosed in parentheses")
+	}
+	s = s[1:]
+	if s == "" {
+		return nil, "", errors.New("ldap: unterminated filter")
+	}
+
+	var encoded []byte
+	switch s[0] {
+	case '&', '|':
+		tag := byte(filterAnd)
+		if s[0] == '|' {
+			tag = filterOr
+		}
+		s = s[1:]
+		var children [][]byte
+		for strings.HasPrefix(s, "(") {
+			child, rest, err := parseFilter(s)
+			if err != nil {
+				return nil, "", err
+			}
+			children = append(children, child)
+			s = rest
+		}
+		encoded = berConstructed(tag, children...)
+	case '!':
+		child, rest, err := parseFilter(s[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		encoded = berConstructed(filterNot, child)
+		s = rest
+	default:
+		end := strings.IndexByte(s, ')')
+		if end < 0 {
+			return nil, "", errors.New("ldap: unterminated filter")
+		}
+		item, err := parseItem(s[:end])
+		if err != nil {
+			return nil, "", err
+		}
+		encoded = item
+		s = s[end:]
+	}
+
+	if !strings.HasPrefix(s, ")") {
+		return nil, "", errors.New("ldap: unterminated filter")
+	}
+	return encoded, s[1:], nil
+}
+
+func parseItem(item string) ([]byte, error) {
+	eq := strings.IndexByte(item, '=')
+	if eq <= 0 {
+		return nil, fmt.Errorf("ldap: invalid filter item %q", item)
+	}
+	attr, value := item[:eq], item[eq+1:]
+
+	switch attr[len(attr)-1] {
+	case '~', '>', '<', ':':
+		op := attr[len(attr)-1]
+		attr = attr[:len(attr)-1]
+		decoded, err := unescapeFilter(value)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case '~':
+			return berConstructed(filterApproxMatch, berString(tagOctetString, attr), berString(tagOctetString, decoded)), nil
+		case '>':
+			return berConstructed(filterGreaterOrEqual, berString(tagOctetString, attr), berString(tagOctetString, decoded)), nil
+		case '<':
+			return berConstructed(filterLessOrEqual, berString(tagOctetString, attr), berString(tagOctetString, decoded)), nil
+		default:
+			return extensibleMatch(attr, decoded)
+		}
+	}
+
+	if attr == "" {
+		return nil, fmt.Errorf("ldap: invalid filter item %q", item)
+	}
+	if value == "*" {
+		return berString(filterPresent, attr), nil
+	}
+	if strings.Contains(value, "*") {
+		return substrings(attr, value)
+	}
+	decoded, err := unescapeFilter(value)
+	if err != nil {
+		return nil, err
+	}
+	return berConstructed(filterEqualityMatch, berString(tagOctetString, attr), berString(tagOctetString, decoded)), nil
+}
+
+// extensibleMatch encodes "attr:dn:rule:=value", the ":=" is already split off.
+func extensibleMatch(attr, value string) ([]byte, error) {
+	parts := strings.Split(attr, ":")
+	var attrType, rule string
+	dnAttributes := false
+	attrType = parts[0]
+	for _, part := range parts[1:] {
+		switch {
+		case strings.EqualFold(part, "dn"):
+			dnAttributes = true
+		case part != "":
+			rule = part
+		default:
+			return nil, fmt.Errorf("ldap: invalid extensible match %q", attr)
+		}
+	}
+	if attrType == "" && rule == "" {
+		return nil, fmt.Errorf("ldap: invalid extensible match %q", attr)
+	}
+
+	var children [][]byte
+	if rule != "" {
+		children = append(children, berString(classContext|1, rule))
+	}
+	if attrType != "" {
+		children = append(children, berString(classContext|2, attrType))
+	}
+	children = append(children, berString(classContext|3, value))
+	if dnAttributes {
+		children = append(children, ber(classContext|4, []byte{0xff}))
+	}
+	return berConstructed(filterExtensibleMatch, children...), nil
+}
+
+func substrings(attr, value string) ([]byte, error) {
+	parts := strings.Split(value, "*")
+	var children [][]byte
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		decoded, err := unescapeFilter(part)
+		if err != nil {
+			return nil, err
+		}
+		var tag byte = classContext | 1 // any
+		switch i {
+		case 0:
+			tag = classContext | 0 // initial
+		case len(parts) - 1:
+			tag = classContext | 2 // final
+		}
+		children = append(children, berString(tag, decoded))
+	}
+	return berConstructed(filterSubstrings, berString(tagOctetString, attr), berConstructed(tagSequence, children...)), nil
+}
+
+// unescapeFilter decodes the \XX escapes of a filter value.
+func unescapeFilter(value string) (string, error) {
+	if !strings.Contains(value, `\`) {
+		return value, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			b.WriteByte(value[i])
+			continue
+		}
+		if i+3 > len(value) {
+			return "", fmt.Errorf("ldap: invalid escape in filter value %q", value)
+		}
+		decoded, err := hex.DecodeString(value[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("ldap: invalid escape in filter value %q", value)
+		}
+		b.Write(decoded)
+		i += 2
+	}
+	return b.String(), nil
+}
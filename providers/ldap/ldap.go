@@ -0,0 +1,344 @@
+// Package ldap implements authentication against an LDAP directory or Active
+// Directory, by binding with the username and password the user posts.
+//
+// gothic.BeginAuthHandler redirects to the login form of the application, which
+// posts the "username", "password" and "state" values to the callback handled
+// with gothic.CompleteUserAuth. The password is only used to bind, it is never
+// stored in the session.
+package ldap
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// ErrInvalidCredentials is returned when the username or password is wrong.
+var ErrInvalidCredentials = errors.New("ldap: invalid username or password")
+
+// AttributeMap lists the attributes of the user entry mapped to goth.User.
+type AttributeMap struct {
+	UserID    string
+	Email     string
+	Name      string
+	FirstName string
+	LastName  string
+	NickName  string
+	// Groups is read from the user entry when the provider has no GroupFilter.
+	Groups string
+}
+
+// DefaultAttributeMap is the mapping of OpenLDAP and other RFC 4519 directories.
+var DefaultAttributeMap = AttributeMap{
+	UserID:    "uid",
+	Email:     "mail",
+	Name:      "cn",
+	FirstName: "givenName",
+	LastName:  "sn",
+	NickName:  "uid",
+	Groups:    "memberOf",
+}
+
+// ActiveDirectoryAttributeMap is the mapping of Active Directory.
+var ActiveDirectoryAttributeMap = AttributeMap{
+	UserID:    "objectGUID",
+	Email:     "mail",
+	Name:      "displayName",
+	FirstName: "givenName",
+	LastName:  "sn",
+	NickName:  "sAMAccountName",
+	Groups:    "memberOf",
+}
+
+// New creates a new LDAP provider for the directory at serverURL (ldap:// or
+// ldaps://), looking users up under baseDN. Users are sent to loginURL to enter
+// their username and password.
+// You should always call `ldap.New` to get a new Provider. Never try to create
+// one manually.
+func New(serverURL, baseDN, loginURL string) *Provider {
+	return &Provider{
+		URL:          serverURL,
+		BaseDN:       baseDN,
+		LoginURL:     loginURL,
+		UserFilter:   "(&(objectClass=person)(uid=%s))",
+		GroupFilter:  "",
+		GroupName:    "cn",
+		AttributeMap: DefaultAttributeMap,
+		Timeout:      10 * time.Second,
+		providerName: "ldap",
+	}
+}
+
+// NewActiveDirectory creates a new LDAP provider for Active Directory, where
+// users sign in with their sAMAccountName or userPrincipalName, and the groups
+// they are a member of, directly or through nested groups, are looked up.
+func NewActiveDirectory(serverURL, baseDN, loginURL string) *Provider {
+	p := New(serverURL, baseDN, loginURL)
+	p.UserFilter = "(&(objectCategory=person)(objectClass=user)(|(sAMAccountName=%[1]s)(userPrincipalName=%[1]s)))"
+	// LDAP_MATCHING_RULE_IN_CHAIN follows nested groups
+	p.GroupFilter = "(&(objectClass=group)(member:1.2.840.113556.1.4.1941:=%s))"
+	p.AttributeMap = ActiveDirectoryAttributeMap
+	return p
+}
+
+// Provider is the implementation of `goth.Provider` for LDAP directories.
+type Provider struct {
+	URL string
+	// StartTLS upgrades ldap:// connections to TLS before binding.
+	StartTLS  bool
+	TLSConfig *tls.Config
+	// BindDN and BindPassword are the service account searching for users,
+	// users are searched anonymously without one.
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	LoginURL     string
+	// UserFilter finds the entry of the user, %s is the escaped username.
+	UserFilter string
+	// GroupBaseDN and GroupFilter find the groups of the user, %s is the
+	// escaped DN of the user. Groups are read from the user entry without
+	// a GroupFilter.
+	GroupBaseDN  string
+	GroupFilter  string
+	GroupName    string
+	AttributeMap AttributeMap
+	Timeout      time.Duration
+	providerName string
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Local reports that the provider is a goth.LocalProvider: the users are bound to the directory during the login, not fetched with a token.
+func (p *Provider) Local() bool {
+	return true
+}
+
+// Debug is a no-op for the ldap package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth sends the user to the login form, with the state it has to post.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	sep := "?"
+	if strings.Contains(p.LoginURL, "?") {
+		sep = "&"
+	}
+	session := &Session{
+		AuthURL: p.LoginURL + sep + url.Values{"state": {state}}.Encode(),
+	}
+	return session, nil
+}
+
+// FetchUser maps the entry of the authenticated user to a goth.User.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	s := session.(*Session)
+	user := goth.User{
+		Provider: p.Name(),
+	}
+
+	if s.DN == "" {
+		// the user hasn't signed in yet
+		return user, fmt.Errorf("%s cannot get user information without a bound user", p.providerName)
+	}
+
+	user.RawData = make(map[string]interface{}, len(s.Attributes)+2)
+	for name, values := range s.Attributes {
+		if len(values) == 1 {
+			user.RawData[name] = values[0]
+		} else {
+			user.RawData[name] = values
+		}
+	}
+	user.RawData["dn"] = s.DN
+	user.RawData["groups"] = s.Groups
+
+	m := p.AttributeMap
+	user.UserID = s.attribute(m.UserID)
+	if user.UserID == "" {
+		user.UserID = s.DN
+	}
+	user.Email = s.attribute(m.Email)
+	user.FirstName = s.attribute(m.FirstName)
+	user.LastName = s.attribute(m.LastName)
+	user.Name = s.attribute(m.Name)
+	if user.Name == "" {
+		user.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+	}
+	user.NickName = s.attribute(m.NickName)
+	if user.NickName == "" {
+		user.NickName = s.Username
+	}
+	return user, nil
+}
+
+// Groups returns the names of the groups the user is a member of, as looked
+// up when signing in.
+func Groups(user goth.User) []string {
+	groups, _ := user.RawData["groups"].([]string)
+	return groups
+}
+
+// authenticate binds as the user, and returns the user entry and groups.
+func (p *Provider) authenticate(username, password string) (*entry, []string, error) {
+	// an empty password would be an unauthenticated bind, which succeeds
+	if username == "" || password == "" {
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	c, err := dial(p.URL, p.StartTLS, p.TLSConfig, p.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer c.close()
+
+	if p.BindDN != "" {
+		err = c.bind(p.BindDN, p.BindPassword)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ldap: binding the service account: %w", err)
+		}
+	}
+
+	entries, err := c.search(p.BaseDN, fmt.Sprintf(p.UserFilter, EscapeFilter(username)), p.attributes())
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(entries) != 1 {
+		// unknown and ambiguous users can't be told apart from a wrong password
+		return nil, nil, ErrInvalidCredentials
+	}
+	user := &entries[0]
+
+	err = c.bind(user.DN, password)
+	if err != nil {
+		var re *ResultError
+		if errors.As(err, &re) && re.Code == resultInvalidCredentials {
+			return nil, nil, ErrInvalidCredentials
+		}
+		return nil, nil, err
+	}
+
+	var groups []string
+	if p.GroupFilter == "" {
+		for _, dn := range attributeValues(user.Attributes, p.AttributeMap.Groups) {
+			groups = append(groups, groupName(dn))
+		}
+		return user, groups, nil
+	}
+
+	// the groups are looked up with the service account again, as users may
+	// not be allowed to search them
+	if p.BindDN != "" {
+		err = c.bind(p.BindDN, p.BindPassword)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ldap: binding the service account: %w", err)
+		}
+	}
+	baseDN := p.GroupBaseDN
+	if baseDN == "" {
+		baseDN = p.BaseDN
+	}
+	entries, err = c.search(baseDN, fmt.Sprintf(p.GroupFilter, EscapeFilter(user.DN)), []string{p.GroupName})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, group := range entries {
+		name := ""
+		if values := attributeValues(group.Attributes, p.GroupName); len(values) > 0 {
+			name = values[0]
+		}
+		if name == "" {
+			name = groupName(group.DN)
+		}
+		groups = append(groups, name)
+	}
+	return user, groups, nil
+}
+
+func (p *Provider) attributes() []string {
+	m := p.AttributeMap
+	var attributes []string
+	for _, name := range []string{m.UserID, m.Email, m.Name, m.FirstName, m.LastName, m.NickName, m.Groups} {
+		if name != "" {
+			attributes = append(attributes, name)
+		}
+	}
+	return attributes
+}
+
+// RefreshToken refresh token is not provided by ldap
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by ldap")
+}
+
+// RefreshTokenAvailable refresh token is not provided by ldap
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
+
+// attributeValues looks attributes up by name, which is case-insensitive in LDAP.
+func attributeValues(attributes map[string][]string, name string) []string {
+	if name == "" {
+		return nil
+	}
+	if values, ok := attributes[name]; ok {
+		return values
+	}
+	for key, values := range attributes {
+		if strings.EqualFold(key, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// groupName returns the value of the first RDN of a group DN, usually its cn.
+func groupName(dn string) string {
+	rdn := dn
+	for i := 0; i < len(dn); i++ {
+		if dn[i] == '\\' {
+			i++
+			continue
+		}
+		if dn[i] == ',' {
+			rdn = dn[:i]
+			break
+		}
+	}
+	if eq := strings.IndexByte(rdn, '='); eq >= 0 {
+		rdn = rdn[eq+1:]
+	}
+	return strings.NewReplacer(`\,`, ",", `\\`, `\`, `\+`, "+", `\"`, `"`, `\<`, "<", `\>`, ">", `\;`, ";", `\=`, "=").Replace(rdn)
+}
+
+// formatGUID formats the binary objectGUID of Active Directory.
+func formatGUID(b []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(b[0:4]),
+		binary.LittleEndian.Uint16(b[4:6]),
+		binary.LittleEndian.Uint16(b[6:8]),
+		b[8:10], b[10:16])
+}
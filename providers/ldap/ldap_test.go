@@ -0,0 +1,521 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeServer is an in-memory directory answering binds and searches.
+type fakeServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	passwords map[string]string
+	entries   []entry
+	// startTLS records whether each connection was upgraded
+	startTLS chan bool
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{
+		listener:  l,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{testCertificate(t)}},
+		passwords: map[string]string{
+			"cn=admin,dc=example,dc=com":            "admin",
+			"uid=jdoe,ou=people,dc=example,dc=com":  "secret",
+			"uid=other,ou=people,dc=example,dc=com": "other",
+		},
+		entries: []entry{
+			{DN: "uid=jdoe,ou=people,dc=example,dc=com", Attributes: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"jdoe"},
+				"mail":        {"jdoe@example.com"},
+				"cn":          {"John Doe"},
+				"givenName":   {"John"},
+				"sn":          {"Doe"},
+				"memberOf":    {"cn=admins,ou=groups,dc=example,dc=com", `cn=dev\, ops,ou=groups,dc=example,dc=com`},
+			}},
+			{DN: "uid=other,ou=people,dc=example,dc=com", Attributes: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"other"},
+			}},
+			{DN: "cn=admins,ou=groups,dc=example,dc=com", Attributes: map[string][]string{
+				"objectClass": {"groupOfNames"},
+				"cn":          {"admins"},
+				"member":      {"uid=jdoe,ou=people,dc=example,dc=com"},
+			}},
+			{DN: "cn=users,ou=groups,dc=example,dc=com", Attributes: map[string][]string{
+				"objectClass": {"groupOfNames"},
+				"cn":          {"users"},
+				"member":      {"uid=jdoe,ou=people,dc=example,dc=com", "uid=other,ou=people,dc=example,dc=com"},
+			}},
+		},
+		startTLS: make(chan bool, 16),
+	}
+	go s.serve()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeServer) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *fakeServer) serve() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(c)
+	}
+}
+
+func (s *fakeServer) handle(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	bound := ""
+	upgraded := false
+	defer func() { s.startTLS <- upgraded }()
+
+	reply := func(id int64, op []byte) {
+		_, _ = c.Write(berConstructed(tagSequence, berInt(tagInteger, id), op))
+	}
+	ldapResult := func(tag byte, code int64, message string) []byte {
+		return berConstructed(tag, berInt(tagEnumerated, code), berString(tagOctetString, ""), berString(tagOctetString, message))
+	}
+
+	for {
+		msg, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		id, _ := msg.children[0].int()
+		op := msg.children[1]
+		switch op.tag {
+		case opBindRequest:
+			dn, password := op.children[1].string(), op.children[2].string()
+			if expected, ok := s.passwords[dn]; !ok || expected != password {
+				reply(id, ldapResult(opBindResponse, resultInvalidCredentials, "invalid credentials"))
+				continue
+			}
+			bound = dn
+			reply(id, ldapResult(opBindResponse, resultSuccess, ""))
+		case opExtendedRequest:
+			reply(id, ldapResult(opExtendedResponse, resultSuccess, ""))
+			tc := tls.Server(c, s.tlsConfig)
+			if tc.Handshake() != nil {
+				return
+			}
+			c = tc
+			r = bufio.NewReader(tc)
+			upgraded = true
+		case opSearchRequest:
+			if bound == "" {
+				reply(id, ldapResult(opSearchResultDone, 50, "insufficient access rights"))
+				continue
+			}
+			baseDN := strings.ToLower(op.children[0].string())
+			var attributes []string
+			for _, attr := range op.children[7].children {
+				attributes = append(attributes, attr.string())
+			}
+			for _, e := range s.entries {
+				if !strings.HasSuffix(strings.ToLower(e.DN), baseDN) || !matches(op.children[6], e) {
+					continue
+				}
+				var attrs [][]byte
+				for _, name := range attributes {
+					values := attributeValues(e.Attributes, name)
+					if values == nil {
+						continue
+					}
+					var encoded [][]byte
+					for _, v := range values {
+						encoded = append(encoded, berString(tagOctetString, v))
+					}
+					attrs = append(attrs, berConstructed(tagSequence, berString(tagOctetString, name), berConstructed(tagSet, encoded...)))
+				}
+				reply(id, berConstructed(opSearchResultEntry, berString(tagOctetString, e.DN), berConstructed(tagSequence, attrs...)))
+			}
+			reply(id, ldapResult(opSearchResultDone, resultSuccess, ""))
+		case opUnbindRequest:
+			return
+		}
+	}
+}
+
+// matches evaluates the filters the provider sends.
+func matches(filter *berPacket, e entry) bool {
+	switch filter.tag {
+	case filterAnd:
+		for _, child := range filter.children {
+			if !matches(child, e) {
+				return false
+			}
+		}
+		return true
+	case filterOr:
+		for _, child := range filter.children {
+			if matches(child, e) {
+				return true
+			}
+		}
+		return false
+	case filterNot:
+		return !matches(filter.children[0], e)
+	case filterPresent:
+		return attributeValues(e.Attributes, filter.string()) != nil
+	case filterEqualityMatch:
+		for _, v := range attributeValues(e.Attributes, filter.children[0].string()) {
+			if strings.EqualFold(v, filter.children[1].string()) {
+				return true
+			}
+		}
+		return false
+	case filterExtensibleMatch:
+		// matching rules are ignored, only direct members are found
+		var attr, value string
+		for _, child := range filter.children {
+			switch child.tag {
+			case classContext | 2:
+				attr = child.string()
+			case classContext | 3:
+				value = child.string()
+			}
+		}
+		for _, v := range attributeValues(e.Attributes, attr) {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+func testCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func provider(s *fakeServer) *Provider {
+	p := New(s.URL(), "dc=example,dc=com", "/login")
+	p.BindDN = "cn=admin,dc=example,dc=com"
+	p.BindPassword = "admin"
+	return p
+}
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := New("ldap://ldap.example.com", "dc=example,dc=com", "/login")
+
+	a.Equal(p.Name(), "ldap")
+	a.Equal(p.URL, "ldap://ldap.example.com")
+	a.Equal(p.BaseDN, "dc=example,dc=com")
+	a.Equal(p.UserFilter, "(&(objectClass=person)(uid=%s))")
+	a.Equal(p.AttributeMap, DefaultAttributeMap)
+	a.False(p.RefreshTokenAvailable())
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), New("ldap://ldap.example.com", "dc=example,dc=com", "/login"))
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := New("ldap://ldap.example.com", "dc=example,dc=com", "/login")
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Equal("/login?state=test_state", session.(*Session).AuthURL)
+
+	p.LoginURL = "/login?next=%2F"
+	session, err = p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Equal("/login?next=%2F&state=test_state", session.(*Session).AuthURL)
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider(newFakeServer(t))
+
+	session, _ := p.BeginAuth("test_state")
+	s := session.(*Session)
+	_, err := p.FetchUser(s)
+	a.Error(err)
+
+	dn, err := s.Authorize(p, url.Values{"username": {"jdoe"}, "password": {"secret"}})
+	a.NoError(err)
+	a.Equal("uid=jdoe,ou=people,dc=example,dc=com", dn)
+	a.NotContains(s.Marshal(), "secret")
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("jdoe", user.UserID)
+	a.Equal("jdoe@example.com", user.Email)
+	a.Equal("John Doe", user.Name)
+	a.Equal("John", user.FirstName)
+	a.Equal("Doe", user.LastName)
+	a.Equal("jdoe", user.NickName)
+	a.Equal(dn, user.RawData["dn"])
+	a.Equal([]string{"admins", "dev, ops"}, Groups(user))
+}
+
+func Test_AuthorizeGroupFilter(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider(newFakeServer(t))
+	p.GroupBaseDN = "ou=groups,dc=example,dc=com"
+	p.GroupFilter = "(&(objectClass=groupOfNames)(member=%s))"
+
+	s := &Session{}
+	_, err := s.Authorize(p, url.Values{"username": {"jdoe"}, "password": {"secret"}})
+	a.NoError(err)
+	a.Equal([]string{"admins", "users"}, s.Groups)
+
+	p.GroupFilter = "(&(objectClass=groupOfNames)(member:1.2.840.113556.1.4.1941:=%s))"
+	s = &Session{}
+	_, err = s.Authorize(p, url.Values{"username": {"other"}, "password": {"other"}})
+	a.NoError(err)
+	a.Equal([]string{"users"}, s.Groups)
+}
+
+func Test_AuthorizeStartTLS(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	server := newFakeServer(t)
+	p := provider(server)
+	p.StartTLS = true
+
+	s := &Session{}
+	_, err := s.Authorize(p, url.Values{"username": {"jdoe"}, "password": {"secret"}})
+	// the certificate of the server isn't trusted
+	a.Error(err)
+	a.Empty(s.DN)
+
+	pool := x509.NewCertPool()
+	cert, _ := x509.ParseCertificate(server.tlsConfig.Certificates[0].Certificate[0])
+	pool.AddCert(cert)
+	p.TLSConfig = &tls.Config{RootCAs: pool}
+	_, err = s.Authorize(p, url.Values{"username": {"jdoe"}, "password": {"secret"}})
+	a.NoError(err)
+	a.Equal("uid=jdoe,ou=people,dc=example,dc=com", s.DN)
+
+	first, second := <-server.startTLS, <-server.startTLS
+	a.True(first || second)
+}
+
+func Test_AuthorizeRejected(t *testing.T) {
+	t.Parallel()
+	p := provider(newFakeServer(t))
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"wrong password", "jdoe", "wrong"},
+		{"empty password", "jdoe", ""},
+		{"empty username", "", "secret"},
+		{"unknown user", "nobody", "secret"},
+		{"filter injection", "jdoe)(uid=*", "secret"},
+		{"wildcard", "*", "secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+			s := &Session{}
+			_, err := s.Authorize(p, url.Values{"username": {tt.username}, "password": {tt.password}})
+			a.Equal(ErrInvalidCredentials, err)
+			a.Empty(s.DN)
+		})
+	}
+
+	t.Run("service account", func(t *testing.T) {
+		a := assert.New(t)
+		p := provider(newFakeServer(t))
+		p.BindPassword = "wrong"
+		_, err := (&Session{}).Authorize(p, url.Values{"username": {"jdoe"}, "password": {"secret"}})
+		a.Error(err)
+		a.NotEqual(ErrInvalidCredentials, err)
+	})
+}
+
+func Test_EscapeFilter(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Equal(`jdoe`, EscapeFilter("jdoe"))
+	a.Equal(`\2a\28uid=\5c\29\00`, EscapeFilter("*(uid=\\)\x00"))
+	a.Equal("Jöhn", EscapeFilter("Jöhn"))
+}
+
+func Test_CompileFilter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		filter  string
+		encoded []byte
+	}{
+		{"(cn=Babs Jensen)", berConstructed(filterEqualityMatch, berString(tagOctetString, "cn"), berString(tagOctetString, "Babs Jensen"))},
+		{"(objectClass=*)", berString(filterPresent, "objectClass")},
+		{`(o=Parens R Us \28for all your parenthetical needs\29)`, berConstructed(filterEqualityMatch, berString(tagOctetString, "o"), berString(tagOctetString, "Parens R Us (for all your parenthetical needs)"))},
+		{"(!(cn=Tim Howes))", berConstructed(filterNot, berConstructed(filterEqualityMatch, berString(tagOctetString, "cn"), berString(tagOctetString, "Tim Howes")))},
+		{"(&(a=1)(|(b=2)(c>=3)))", berConstructed(filterAnd,
+			berConstructed(filterEqualityMatch, berString(tagOctetString, "a"), berString(tagOctetString, "1")),
+			berConstructed(filterOr,
+				berConstructed(filterEqualityMatch, berString(tagOctetString, "b"), berString(tagOctetString, "2")),
+				berConstructed(filterGreaterOrEqual, berString(tagOctetString, "c"), berString(tagOctetString, "3"))))},
+		{"(cn=*jen*sen)", berConstructed(filterSubstrings, berString(tagOctetString, "cn"), berConstructed(tagSequence,
+			berString(classContext|1, "jen"), berString(classContext|2, "sen")))},
+		{"(cn:dn:2.4.6.8.10:=Dino)", berConstructed(filterExtensibleMatch,
+			berString(classContext|1, "2.4.6.8.10"), berString(classContext|2, "cn"), berString(classContext|3, "Dino"), ber(classContext|4, []byte{0xff}))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			a := assert.New(t)
+			encoded, err := compileFilter(tt.filter)
+			a.NoError(err)
+			a.Equal(tt.encoded, encoded)
+		})
+	}
+
+	for _, filter := range []string{"", "cn=x", "(cn=x", "(cn=x))", "(=x)", `(cn=\2)`, `(cn=\zz)`, "(&(cn=x)"} {
+		_, err := compileFilter(filter)
+		assert.Error(t, err, filter)
+	}
+}
+
+func Test_BER(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	for _, v := range []int64{0, 1, 127, 128, 255, 256, -1, -128, -129, 1 << 40} {
+		p, err := readPacket(bufio.NewReader(strings.NewReader(string(berInt(tagInteger, v)))))
+		a.NoError(err)
+		decoded, err := p.int()
+		a.NoError(err)
+		a.Equal(v, decoded)
+	}
+
+	long := strings.Repeat("x", 70000)
+	p, err := readPacket(bufio.NewReader(strings.NewReader(string(berConstructed(tagSequence, berString(tagOctetString, long), berBool(true))))))
+	a.NoError(err)
+	a.Len(p.children, 2)
+	a.Equal(long, p.children[0].string())
+
+	_, err = readPacket(bufio.NewReader(strings.NewReader("\x30\x05\x04\x07abc")))
+	a.Error(err)
+	_, err = readPacket(bufio.NewReader(strings.NewReader("\x04\x84\x7f\xff\xff\xff")))
+	a.Error(err)
+
+	// the children must fit in their parent
+	_, err = readPacket(bufio.NewReader(strings.NewReader("\x30\x06\x04\x84\x00\xff\xff\xff")))
+	a.Equal(errTruncated, err)
+
+	nested := berString(tagOctetString, "x")
+	for i := 0; i < maxDepth; i++ {
+		nested = berConstructed(tagSequence, nested)
+	}
+	_, err = readPacket(bufio.NewReader(strings.NewReader(string(nested))))
+	a.NoError(err)
+	_, err = readPacket(bufio.NewReader(strings.NewReader(string(berConstructed(tagSequence, nested)))))
+	a.EqualError(err, "ldap: BER elements are nested too deeply")
+}
+
+func Fuzz_Decode(f *testing.F) {
+	f.Add(berInt(tagInteger, 1<<40))
+	f.Add(berConstructed(tagSequence, berInt(tagInteger, 1), berConstructed(classApplication|constructed|3, berString(tagOctetString, "dc=example,dc=com"), berBool(true))))
+	f.Add([]byte("\x30\x05\x04\x07abc"))
+	f.Add([]byte("\x30\x84\x00\x00\x00\x02\x30\x00"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := readPacket(bufio.NewReader(bytes.NewReader(data)))
+		if err != nil {
+			return
+		}
+		var check func(p *berPacket, depth int)
+		check = func(p *berPacket, depth int) {
+			if depth > maxDepth {
+				t.Fatalf("BER elements nested %d deep", depth)
+			}
+			size := 0
+			for _, child := range p.children {
+				size += len(child.value)
+				check(child, depth+1)
+			}
+			if size > len(p.value) {
+				t.Fatalf("the children of %d bytes don't fit in the %d bytes of their parent", size, len(p.value))
+			}
+		}
+		check(p, 0)
+	})
+}
+
+func Test_GroupName(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Equal("admins", groupName("cn=admins,ou=groups,dc=example,dc=com"))
+	a.Equal("dev, ops", groupName(`cn=dev\, ops,ou=groups,dc=example,dc=com`))
+	a.Equal("admins", groupName("admins"))
+}
+
+func Test_FormatGUID(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	guid := []byte{0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+	a.Equal("00112233-4455-6677-8899-aabbccddeeff", formatGUID(guid))
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := New("ldap://ldap.example.com", "dc=example,dc=com", "/login")
+	session, err := p.UnmarshalSession(`{"AuthURL":"/login?state=x","Username":"jdoe","DN":"uid=jdoe,dc=example,dc=com","Groups":["admins"]}`)
+	a.NoError(err)
+
+	s := session.(*Session)
+	a.Equal("/login?state=x", s.AuthURL)
+	a.Equal("uid=jdoe,dc=example,dc=com", s.DN)
+	a.Equal([]string{"admins"}, s.Groups)
+}
@@ -0,0 +1,80 @@
+package ldap
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Session stores data during the auth process with an LDAP directory.
+type Session struct {
+	AuthURL    string
+	Username   string
+	DN         string
+	Attributes map[string][]string
+	Groups     []string
+}
+
+var _ goth.Session = &Session{}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` function on the LDAP provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize the session by binding with the "username" and "password"
+// parameters, and return the DN of the user.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	user, groups, err := p.authenticate(params.Get("username"), params.Get("password"))
+	if err != nil {
+		return "", err
+	}
+
+	s.Username = params.Get("username")
+	s.DN = user.DN
+	s.Attributes = make(map[string][]string, len(user.Attributes))
+	for name, values := range user.Attributes {
+		if strings.EqualFold(name, "objectGUID") {
+			for i, v := range values {
+				if len(v) == 16 {
+					values[i] = formatGUID([]byte(v))
+				}
+			}
+		}
+		s.Attributes[name] = values
+	}
+	s.Groups = groups
+	return s.DN, nil
+}
+
+// attribute returns the first value of the attribute.
+func (s Session) attribute(name string) string {
+	values := attributeValues(s.Attributes, name)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return goth.RedactJSON(s.Marshal())
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	s := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
+	return s, err
+}
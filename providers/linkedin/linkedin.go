@@ -1,30 +1,43 @@
-// Package linkedin implements the OAuth2 protocol for authenticating users through Linkedin.
+// Package linkedin implements the OpenID Connect protocol for authenticating users through Linkedin.
 package linkedin
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
-	"net/url"
+	"strings"
 
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/jwks"
+	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/oauth2"
 )
 
-// more details about linkedin fields:
-// User Profile and Email Address - https://docs.microsoft.com/en-gb/linkedin/consumer/integrations/self-serve/sign-in-with-linkedin
-// User Avatar - https://docs.microsoft.com/en-gb/linkedin/shared/references/v2/digital-media-asset
+// more details about Sign In with LinkedIn using OpenID Connect:
+// https://learn.microsoft.com/en-us/linkedin/consumer/integrations/self-serve/sign-in-with-linkedin-v2
 
 const (
 	authURL  string = "https://www.linkedin.com/oauth/v2/authorization"
 	tokenURL string = "https://www.linkedin.com/oauth/v2/accessToken"
+	issuer   string = "https://www.linkedin.com"
+	keysURL  string = "https://www.linkedin.com/oauth/openid/jwks"
 
-	// userEndpoint requires scope "r_liteprofile"
-	userEndpoint string = "//api.linkedin.com/v2/me?projection=(id,firstName,lastName,profilePicture(displayImage~:playableStreams))"
-	// emailEndpoint requires scope "r_emailaddress"
-	emailEndpoint string = "//api.linkedin.com/v2/emailAddress?q=members&projection=(elements*(handle~))"
+	// userInfoEndpoint requires the scopes "openid" and "profile"
+	userInfoEndpoint string = "https://api.linkedin.com/v2/userinfo"
+)
+
+// Scopes of Sign In with LinkedIn. They replace the deprecated r_liteprofile
+// and r_emailaddress scopes.
+const (
+	ScopeOpenID  string = "openid"
+	ScopeProfile string = "profile"
+	ScopeEmail   string = "email"
 )
 
 // New creates a new linkedin provider, and sets up important connection details.
@@ -36,6 +49,7 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 		Secret:       secret,
 		CallbackURL:  callbackURL,
 		providerName: "linkedin",
+		Keys:         jwks.New(keysURL, nil),
 	}
 	p.config = newConfig(p, scopes)
 	return p
@@ -43,10 +57,13 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 
 // Provider is the implementation of `goth.Provider` for accessing Linkedin.
 type Provider struct {
-	ClientKey    string
-	Secret       string
-	CallbackURL  string
-	HTTPClient   *http.Client
+	ClientKey   string
+	Secret      string
+	CallbackURL string
+	HTTPClient  *http.Client
+	// Keys verify the signatures of the ID tokens, fetched from LinkedIn and
+	// prefetched once the provider is registered with goth.UseProviders.
+	Keys         *jwks.Cache
 	config       *oauth2.Config
 	providerName string
 }
@@ -61,6 +78,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 // Client returns an HTTPClientWithFallback
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
@@ -69,22 +96,50 @@ func (p *Provider) Client() *http.Client {
 // Debug is a no-op for the linkedin package.
 func (p *Provider) Debug(debug bool) {}
 
-// BeginAuth asks Linkedin for an authentication end-point.
+// Prefetch fetches the keys verifying the ID tokens in the background, so
+// the logins don't wait for them, see goth.PrefetchProvider.
+func (p *Provider) Prefetch() {
+	p.keys().Prefetch()
+}
+
+func (p *Provider) keys() *jwks.Cache {
+	if p.Keys == nil {
+		return jwks.New(keysURL, p.HTTPClient)
+	}
+	return p.Keys
+}
+
+// BeginAuth asks Linkedin for an authentication end-point. The nonce of the
+// request is checked in the ID token.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	url := p.config.AuthCodeURL(state)
+	b, err := goth.RandomBytes(16)
+	if err != nil {
+		return nil, err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b)
 	session := &Session{
-		AuthURL: url,
+		AuthURL: p.config.AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce)),
+		Nonce:   nonce,
 	}
 	return session, nil
 }
 
-// FetchUser will go to Linkedin and access basic information about the user.
+// FetchUser will read the user from the ID token LinkedIn issued with the
+// access token. The userinfo endpoint is only called when there is no ID
+// token, or once it expired.
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	return p.FetchUserContext(context.Background(), session)
+}
+
+// FetchUserContext is FetchUser, fetching the keys and the user with the
+// context.
+func (p *Provider) FetchUserContext(ctx context.Context, session goth.Session) (goth.User, error) {
 	s := session.(*Session)
 	user := goth.User{
 		AccessToken: s.AccessToken,
 		Provider:    p.Name(),
 		ExpiresAt:   s.ExpiresAt,
+		IDToken:     s.IDToken,
 	}
 
 	if user.AccessToken == "" {
@@ -92,19 +147,27 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	// create request for user r_liteprofile
-	req, err := http.NewRequest("GET", "", nil)
-	if err != nil {
-		return user, err
+	if s.IDToken != "" {
+		claims, err := p.validateIDToken(ctx, s.IDToken, s.Nonce)
+		if err == nil {
+			bits, err := json.Marshal(claims)
+			if err != nil {
+				return user, err
+			}
+			err = userFromReader(bytes.NewReader(bits), &user)
+			return user, err
+		}
+		if !errors.Is(err, jwt.ErrTokenExpired) {
+			return user, err
+		}
+		// the session outlived its ID token, the access token is checked by
+		// the userinfo endpoint
 	}
 
-	// add url as opaque to avoid escaping of "("
-	req.URL = &url.URL{
-		Scheme: "https",
-		Host:   "api.linkedin.com",
-		Opaque: userEndpoint,
+	req, err := http.NewRequestWithContext(ctx, "GET", userInfoEndpoint, nil)
+	if err != nil {
+		return user, err
 	}
-
 	req.Header.Set("Authorization", "Bearer "+s.AccessToken)
 	resp, err := p.Client().Do(req)
 	if err != nil {
@@ -116,131 +179,62 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s responded with a %d trying to fetch user profile", p.providerName, resp.StatusCode)
 	}
 
-	// read r_liteprofile information
 	err = userFromReader(resp.Body, &user)
-	if err != nil {
-		return user, err
-	}
-
-	// create request for user r_emailaddress
-	reqEmail, err := http.NewRequest("GET", "", nil)
-	if err != nil {
-		return user, err
-	}
-
-	// add url as opaque to avoid escaping of "("
-	reqEmail.URL = &url.URL{
-		Scheme: "https",
-		Host:   "api.linkedin.com",
-		Opaque: emailEndpoint,
-	}
+	return user, err
+}
 
-	reqEmail.Header.Set("Authorization", "Bearer "+s.AccessToken)
-	respEmail, err := p.Client().Do(reqEmail)
+// validateIDToken verifies the signature of the ID token with the Keys, and
+// that it was issued by LinkedIn for this client and the nonce of the
+// session, and returns its claims.
+func (p *Provider) validateIDToken(ctx context.Context, idToken, nonce string) (map[string]interface{}, error) {
+	claims, err := p.keys().VerifyIDToken(ctx, idToken, jwks.IDToken{Issuer: issuer, Audience: p.ClientKey, Nonce: nonce})
 	if err != nil {
-		return user, err
-	}
-	defer respEmail.Body.Close()
-
-	if respEmail.StatusCode != http.StatusOK {
-		return user, fmt.Errorf("%s responded with a %d trying to fetch user email", p.providerName, respEmail.StatusCode)
+		return nil, fmt.Errorf("%s id_token: %w", p.providerName, err)
 	}
-
-	// read r_emailaddress information
-	err = emailFromReader(respEmail.Body, &user)
-
-	return user, err
+	return claims, nil
 }
 
 func userFromReader(reader io.Reader, user *goth.User) error {
 	u := struct {
-		ID        string `json:"id"`
-		FirstName struct {
-			PreferredLocale struct {
-				Country  string `json:"country"`
-				Language string `json:"language"`
-			} `json:"preferredLocale"`
-			Localized map[string]string `json:"localized"`
-		} `json:"firstName"`
-		LastName struct {
-			Localized       map[string]string
-			PreferredLocale struct {
-				Country  string `json:"country"`
-				Language string `json:"language"`
-			} `json:"preferredLocale"`
-		} `json:"lastName"`
-		ProfilePicture struct {
-			DisplayImage struct {
-				Elements []struct {
-					AuthorizationMethod string `json:"authorizationMethod"`
-					Identifiers         []struct {
-						Identifier     string `json:"identifier"`
-						IdentifierType string `json:"identifierType"`
-					} `json:"identifiers"`
-				} `json:"elements"`
-			} `json:"displayImage~"`
-		} `json:"profilePicture"`
+		ID        string `json:"sub"`
+		Name      string `json:"name"`
+		FirstName string `json:"given_name"`
+		LastName  string `json:"family_name"`
+		Picture   string `json:"picture"`
+		Email     string `json:"email"`
+		Locale    struct {
+			Country  string `json:"country"`
+			Language string `json:"language"`
+		} `json:"locale"`
 	}{}
 
-	err := json.NewDecoder(reader).Decode(&u)
+	bits, err := ioutil.ReadAll(reader)
 	if err != nil {
 		return err
 	}
 
-	user.FirstName = u.FirstName.Localized[u.FirstName.PreferredLocale.Language+"_"+u.FirstName.PreferredLocale.Country]
-	user.LastName = u.LastName.Localized[u.LastName.PreferredLocale.Language+"_"+u.LastName.PreferredLocale.Country]
-	user.Name = user.FirstName + " " + user.LastName
-	user.NickName = user.FirstName
-	user.UserID = u.ID
-
-	avatarURL := ""
-	// loop all displayimage elements
-	for _, element := range u.ProfilePicture.DisplayImage.Elements {
-		// only retrieve data where the authorization method allows public (unauthorized) access
-		if element.AuthorizationMethod == "PUBLIC" {
-			for _, identifier := range element.Identifiers {
-				// check to ensure the identifier type is a url linking to the image
-				if identifier.IdentifierType == "EXTERNAL_URL" {
-					avatarURL = identifier.Identifier
-					// we only need the first image url
-					break
-				}
-			}
-		}
-		// if we have a valid image, exit the loop as we only support a single avatar image
-		if len(avatarURL) > 0 {
-			break
-		}
-	}
-
-	user.AvatarURL = avatarURL
-
-	return err
-}
-
-func emailFromReader(reader io.Reader, user *goth.User) error {
-	e := struct {
-		Elements []struct {
-			Handle struct {
-				EmailAddress string `json:"emailAddress"`
-			} `json:"handle~"`
-		} `json:"elements"`
-	}{}
-
-	err := json.NewDecoder(reader).Decode(&e)
+	err = json.Unmarshal(bits, &u)
 	if err != nil {
 		return err
 	}
 
-	if len(e.Elements) > 0 {
-		user.Email = e.Elements[0].Handle.EmailAddress
+	err = json.Unmarshal(bits, &user.RawData)
+	if err != nil {
+		return err
 	}
 
-	if len(user.Email) == 0 {
-		return errors.New("Unable to retrieve email address")
+	user.UserID = u.ID
+	user.FirstName = u.FirstName
+	user.LastName = u.LastName
+	user.Name = u.Name
+	if user.Name == "" {
+		user.Name = strings.TrimSpace(u.FirstName + " " + u.LastName)
 	}
-
-	return err
+	user.NickName = u.FirstName
+	user.AvatarURL = u.Picture
+	user.Email = u.Email
+	user.Location = u.Locale.Country
+	return nil
 }
 
 func newConfig(provider *Provider, scopes []string) *oauth2.Config {
@@ -256,14 +250,11 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 	}
 
 	if len(scopes) == 0 {
-		// add helper as new API requires the scope to be specified and these are the minimum to retrieve profile information and user's email address
-		scopes = append(scopes, "r_liteprofile", "r_emailaddress")
-	}
-
-	for _, scope := range scopes {
-		c.Scopes = append(c.Scopes, scope)
+		// the minimum scopes to retrieve the profile information and the user's email address
+		scopes = append(scopes, ScopeOpenID, ScopeProfile, ScopeEmail)
 	}
 
+	c.Scopes = append(c.Scopes, scopes...)
 	return c
 }
 
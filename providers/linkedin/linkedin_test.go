@@ -1,11 +1,22 @@
 package linkedin_test
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothtest"
+	"github.com/andreimerlescu/goth/jwks"
 	"github.com/andreimerlescu/goth/providers/linkedin"
 	"github.com/stretchr/testify/assert"
 )
@@ -38,7 +49,9 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "linkedin.com/oauth/v2/authorization")
 	a.Contains(s.AuthURL, fmt.Sprintf("client_id=%s", os.Getenv("LINKEDIN_KEY")))
 	a.Contains(s.AuthURL, "state=test_state")
-	a.Contains(s.AuthURL, "scope=r_liteprofile+r_emailaddress&state")
+	a.Contains(s.AuthURL, "scope=openid+profile+email&state")
+	a.NotEmpty(s.Nonce)
+	a.Contains(s.AuthURL, "nonce="+s.Nonce)
 }
 
 func Test_SessionFromJSON(t *testing.T) {
@@ -54,6 +67,92 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(session.AccessToken, "1234567890")
 }
 
+func Test_FetchUserFromIDToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := gothtest.NewOIDCServer(t)
+	provider := linkedin.New("client-id", "secret", "/foo")
+	provider.Keys = jwks.New(server.Issuer+gothtest.JWKSPath, nil)
+	claims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss": "https://www.linkedin.com", "aud": "client-id", "iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix(), "nonce": "n-0S6_WzA2Mj",
+			"sub": "782bbtaQ", "name": "John Doe", "given_name": "John", "family_name": "Doe", "picture": "https://media.licdn.com/dms/image/photo.jpg",
+			"email": "doe@email.com", "email_verified": true, "locale": map[string]interface{}{"country": "US", "language": "en"},
+		}
+	}
+	idToken, err := server.Sign(claims())
+	a.NoError(err)
+
+	user, err := provider.FetchUser(&linkedin.Session{AccessToken: "1234567890", IDToken: idToken, Nonce: "n-0S6_WzA2Mj"})
+	a.NoError(err)
+	a.Equal("782bbtaQ", user.UserID)
+	a.Equal("John Doe", user.Name)
+	a.Equal("John", user.FirstName)
+	a.Equal("Doe", user.LastName)
+	a.Equal("doe@email.com", user.Email)
+	a.Equal("https://media.licdn.com/dms/image/photo.jpg", user.AvatarURL)
+	a.Equal("US", user.Location)
+	a.Equal(true, user.RawData["email_verified"])
+
+	other := linkedin.New("other-client", "secret", "/foo")
+	other.Keys = provider.Keys
+	_, err = other.FetchUser(&linkedin.Session{AccessToken: "1234567890", IDToken: idToken})
+	a.Error(err)
+	_, err = provider.FetchUser(&linkedin.Session{AccessToken: "1234567890", IDToken: idToken, Nonce: "other-nonce"})
+	a.Error(err)
+
+	// the signatures are verified
+	parts := strings.Split(idToken, ".")
+	forged := claims()
+	forged["sub"] = "someone-else"
+	payload, _ := json.Marshal(forged)
+	_, err = provider.FetchUser(&linkedin.Session{AccessToken: "1234567890", IDToken: parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]})
+	a.Error(err)
+}
+
+func Test_FetchUserFromUserInfo(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v2/userinfo" || req.Header.Get("Authorization") != "Bearer 1234567890" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(res, `{"sub":"782bbtaQ","name":"John Doe","given_name":"John","family_name":"Doe","email":"doe@email.com","email_verified":true}`)
+	}))
+	defer server.Close()
+
+	provider := linkedinProvider()
+	provider.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	user, err := provider.FetchUser(&linkedin.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("782bbtaQ", user.UserID)
+	a.Equal("doe@email.com", user.Email)
+	a.Equal("John Doe", user.Name)
+
+	// the sessions outliving their ID token fetch the user info
+	keys := gothtest.NewOIDCServer(t)
+	client := provider.HTTPClient
+	provider = linkedin.New("client-id", "secret", "/foo")
+	provider.HTTPClient = client
+	provider.Keys = jwks.New(keys.Issuer+gothtest.JWKSPath, nil)
+	expired, err := keys.Sign(map[string]interface{}{"iss": "https://www.linkedin.com", "aud": "client-id", "exp": time.Now().Add(-time.Hour).Unix(), "sub": "someone-else"})
+	a.NoError(err)
+	user, err = provider.FetchUser(&linkedin.Session{AccessToken: "1234567890", IDToken: expired})
+	a.NoError(err)
+	a.Equal("782bbtaQ", user.UserID)
+}
+
 func linkedinProvider() *linkedin.Provider {
-	return linkedin.New(os.Getenv("LINKEDIN_KEY"), os.Getenv("LINKEDIN_SECRET"), "/foo", "r_liteprofile", "r_emailaddress")
+	return linkedin.New(os.Getenv("LINKEDIN_KEY"), os.Getenv("LINKEDIN_SECRET"), "/foo")
 }
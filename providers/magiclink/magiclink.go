@@ -0,0 +1,298 @@
+// Package magiclink implements passwordless authentication with a login link
+// sent to the email address of the user, for users without an account with
+// any of the other providers.
+//
+// gothic.BeginAuthHandler sends the link to the "email" parameter of the
+// request, posted by a form or in the query, and redirects to a page telling
+// the user to check their email. The link leads to the callback handled with
+// gothic.CompleteUserAuth, which must happen in the same browser, as the link
+// is bound to the gothic session.
+package magiclink
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrEmailRequired is returned when the authentication is started without an email address.
+	ErrEmailRequired = errors.New("magiclink: an email address is required")
+	// ErrInvalidToken is returned for links which weren't issued by the provider.
+	ErrInvalidToken = errors.New("magiclink: invalid login link")
+	// ErrTokenExpired is returned for links which are no longer valid.
+	ErrTokenExpired = errors.New("magiclink: the login link has expired")
+	// ErrTokenUsed is returned for links which have already been used.
+	ErrTokenUsed = errors.New("magiclink: the login link has already been used")
+)
+
+// Mailer sends login links.
+type Mailer interface {
+	SendLoginLink(email, link string) error
+}
+
+// MailerFunc adapts a function to the Mailer interface.
+type MailerFunc func(email, link string) error
+
+// SendLoginLink calls f(email, link).
+func (f MailerFunc) SendLoginLink(email, link string) error {
+	return f(email, link)
+}
+
+// SMTPMailer sends login links as plain text emails with net/smtp.
+type SMTPMailer struct {
+	Addr    string
+	Auth    smtp.Auth
+	From    string
+	Subject string
+}
+
+// SendLoginLink sends the link to the email address.
+func (m *SMTPMailer) SendLoginLink(email, link string) error {
+	subject := m.Subject
+	if subject == "" {
+		subject = "Your login link"
+	}
+	msg := "From: " + m.From + "\r\n" +
+		"To: " + email + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		"Use this link to log in:\r\n\r\n" + link + "\r\n"
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{email}, []byte(msg))
+}
+
+// TokenStore remembers the login links which have been used, until they expire.
+type TokenStore interface {
+	// UseToken records the use of the token, or returns ErrTokenUsed.
+	UseToken(id string, expiresAt time.Time) error
+}
+
+// memoryTokenStore is the default TokenStore, it only knows about the links
+// used with this process.
+type memoryTokenStore struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+func (m *memoryTokenStore) UseToken(id string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := goth.Now()
+	for usedID, expiry := range m.used {
+		if now.After(expiry) {
+			delete(m.used, usedID)
+		}
+	}
+	if _, ok := m.used[id]; ok {
+		return ErrTokenUsed
+	}
+	m.used[id] = expiresAt
+	return nil
+}
+
+// New creates a new magic link provider, which signs links with the secret and
+// sends them with the mailer. Users are sent to sentURL after the link has been
+// sent, and the link leads them to callbackURL.
+// You should always call `magiclink.New` to get a new Provider. Never try to
+// create one manually.
+func New(secret []byte, callbackURL, sentURL string, mailer Mailer) *Provider {
+	return &Provider{
+		CallbackURL:  callbackURL,
+		SentURL:      sentURL,
+		Mailer:       mailer,
+		Expiry:       15 * time.Minute,
+		Tokens:       &memoryTokenStore{used: map[string]time.Time{}},
+		secret:       secret,
+		providerName: "magiclink",
+		now:          goth.Now,
+	}
+}
+
+// Provider is the implementation of `goth.Provider` for magic links.
+type Provider struct {
+	CallbackURL string
+	// SentURL is the page telling users to check their email.
+	SentURL string
+	Mailer  Mailer
+	// Expiry is how long login links are valid.
+	Expiry time.Duration
+	// Tokens makes login links single-use. Applications running more than
+	// one instance should share it between them.
+	Tokens       TokenStore
+	secret       []byte
+	providerName string
+	now          func() time.Time
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Local reports that the provider is a goth.LocalProvider: the email addresses are verified during the login, not with a token.
+func (p *Provider) Local() bool {
+	return true
+}
+
+// Debug is a no-op for the magiclink package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth can't send a link without an email address, use BeginAuthWithParams.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return nil, ErrEmailRequired
+}
+
+// BeginAuthWithParams sends a login link to the "email" parameter.
+func (p *Provider) BeginAuthWithParams(state string, params goth.Params) (goth.Session, error) {
+	if params.Get("email") == "" {
+		return nil, ErrEmailRequired
+	}
+	address, err := mail.ParseAddress(params.Get("email"))
+	if err != nil {
+		return nil, fmt.Errorf("magiclink: invalid email address: %w", err)
+	}
+	email := strings.ToLower(address.Address)
+
+	b, err := goth.RandomBytes(16)
+	if err != nil {
+		return nil, err
+	}
+	claims := linkClaims{
+		ID:        hex.EncodeToString(b),
+		Email:     email,
+		ExpiresAt: p.now().Add(p.Expiry).Unix(),
+	}
+	token, err := p.sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	link := p.CallbackURL + separator(p.CallbackURL) + url.Values{"token": {token}, "state": {state}}.Encode()
+	err = p.Mailer.SendLoginLink(email, link)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		AuthURL:   p.SentURL + separator(p.SentURL) + url.Values{"state": {state}}.Encode(),
+		Email:     email,
+		TokenID:   claims.ID,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(),
+	}
+	return session, nil
+}
+
+// FetchUser returns the user whose email address has been verified with the link.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	s := session.(*Session)
+	user := goth.User{
+		Provider: p.Name(),
+		Email:    s.Email,
+		UserID:   s.Email,
+	}
+
+	if !s.Verified {
+		// the link hasn't been opened yet
+		return user, fmt.Errorf("%s cannot get user information without a verified email address", p.providerName)
+	}
+
+	user.RawData = map[string]interface{}{
+		"email":          s.Email,
+		"email_verified": true,
+	}
+	return user, nil
+}
+
+// RefreshToken refresh token is not provided by magiclink
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by magiclink")
+}
+
+// RefreshTokenAvailable refresh token is not provided by magiclink
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
+
+type linkClaims struct {
+	ID        string `json:"jti"`
+	Email     string `json:"email"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// sign encodes the claims and their HMAC-SHA256 signature.
+func (p *Provider) sign(claims linkClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(p.mac(encoded)), nil
+}
+
+// verify decodes the claims of a token after checking its signature and expiry.
+func (p *Provider) verify(token string) (*linkClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, p.mac(parts[0])) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	claims := &linkClaims{}
+	err = json.Unmarshal(payload, claims)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !p.now().Before(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrTokenExpired
+	}
+	return claims, nil
+}
+
+func (p *Provider) mac(payload string) []byte {
+	h := hmac.New(sha256.New, p.secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+func separator(u string) string {
+	if strings.Contains(u, "?") {
+		return "&"
+	}
+	return "?"
+}
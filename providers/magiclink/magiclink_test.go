@@ -0,0 +1,141 @@
+package magiclink_test
+
+import (
+	"crypto/rand"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/magiclink"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p, _ := provider()
+
+	a.Equal(p.CallbackURL, "https://example.com/auth/magiclink/callback")
+	a.Equal(p.SentURL, "/check-your-email")
+	a.Equal(p.Expiry, 15*time.Minute)
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p, _ := provider()
+	a.Implements((*goth.Provider)(nil), p)
+	a.Implements((*goth.BeginAuthWithParamsProvider)(nil), p)
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p, links := provider()
+
+	_, err := p.BeginAuth("test_state")
+	a.Equal(magiclink.ErrEmailRequired, err)
+
+	_, err = p.BeginAuthWithParams("test_state", url.Values{"email": {"not an address"}})
+	a.Error(err)
+
+	session, err := p.BeginAuthWithParams("test_state", url.Values{"email": {"John Doe <John.Doe@Example.com>"}})
+	a.NoError(err)
+	s := session.(*magiclink.Session)
+	a.Equal("/check-your-email?state=test_state", s.AuthURL)
+	a.Equal("john.doe@example.com", s.Email)
+	a.NotEmpty(s.TokenID)
+
+	link, err := url.Parse(links["john.doe@example.com"])
+	a.NoError(err)
+	a.Equal("example.com", link.Host)
+	a.Equal("/auth/magiclink/callback", link.Path)
+	a.Equal("test_state", link.Query().Get("state"))
+	a.NotEmpty(link.Query().Get("token"))
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p, links := provider()
+
+	session, err := p.BeginAuthWithParams("test_state", url.Values{"email": {"jdoe@example.com"}})
+	a.NoError(err)
+	s := session.(*magiclink.Session)
+
+	_, err = p.FetchUser(s)
+	a.Error(err)
+
+	link, _ := url.Parse(links["jdoe@example.com"])
+	email, err := s.Authorize(p, link.Query())
+	a.NoError(err)
+	a.Equal("jdoe@example.com", email)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("magiclink", user.Provider)
+	a.Equal("jdoe@example.com", user.Email)
+	a.Equal("jdoe@example.com", user.UserID)
+
+	// links are single-use
+	s.Verified = false
+	_, err = s.Authorize(p, link.Query())
+	a.Equal(magiclink.ErrTokenUsed, err)
+	a.False(s.Verified)
+}
+
+func Test_AuthorizeRejectsInvalidLinks(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p, links := provider()
+
+	first, _ := p.BeginAuthWithParams("test_state", url.Values{"email": {"jdoe@example.com"}})
+	firstLink, _ := url.Parse(links["jdoe@example.com"])
+	second, _ := p.BeginAuthWithParams("test_state", url.Values{"email": {"jdoe@example.com"}})
+
+	// only the last link sent for a session completes it
+	_, err := second.Authorize(p, firstLink.Query())
+	a.Error(err)
+
+	params := firstLink.Query()
+	params.Set("token", params.Get("token")+"x")
+	_, err = first.Authorize(p, params)
+	a.Equal(magiclink.ErrInvalidToken, err)
+
+	other, _ := provider()
+	_, err = first.Authorize(other, firstLink.Query())
+	a.Equal(magiclink.ErrInvalidToken, err)
+
+	p.Expiry = -time.Minute
+	expired, _ := p.BeginAuthWithParams("test_state", url.Values{"email": {"jdoe@example.com"}})
+	expiredLink, _ := url.Parse(links["jdoe@example.com"])
+	_, err = expired.Authorize(p, expiredLink.Query())
+	a.Equal(magiclink.ErrTokenExpired, err)
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p, _ := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"/check-your-email","Email":"jdoe@example.com","TokenID":"1234567890"}`)
+	a.NoError(err)
+
+	s := session.(*magiclink.Session)
+	a.Equal(s.AuthURL, "/check-your-email")
+	a.Equal(s.Email, "jdoe@example.com")
+	a.Equal(s.TokenID, "1234567890")
+}
+
+// provider returns a provider whose mailer keeps the last link sent to each address.
+func provider() (*magiclink.Provider, map[string]string) {
+	links := map[string]string{}
+	mailer := magiclink.MailerFunc(func(email, link string) error {
+		links[email] = link
+		return nil
+	})
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+	return magiclink.New(secret, "https://example.com/auth/magiclink/callback", "/check-your-email", mailer), links
+}
@@ -0,0 +1,68 @@
+package magiclink
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Session stores data during the auth process with a magic link.
+type Session struct {
+	AuthURL   string
+	Email     string
+	TokenID   string // ID of the link sent, only that link completes the session
+	ExpiresAt time.Time
+	Verified  bool
+}
+
+var _ goth.Session = &Session{}
+
+// GetAuthURL will return the URL set by calling the `BeginAuthWithParams` function on the magiclink provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize the session with the "token" parameter of the login link, and
+// return the verified email address.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	claims, err := p.verify(params.Get("token"))
+	if err != nil {
+		return "", err
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.ID), []byte(s.TokenID)) != 1 || claims.Email != s.Email {
+		return "", errors.New("magiclink: the login link was sent for another session")
+	}
+
+	err = p.Tokens.UseToken(claims.ID, time.Unix(claims.ExpiresAt, 0))
+	if err != nil {
+		return "", err
+	}
+
+	s.Verified = true
+	return s.Email, nil
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return goth.RedactJSON(s.Marshal())
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	s := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
+	return s, err
+}
@@ -0,0 +1,220 @@
+// Package mock implements a scriptable provider for the tests of the
+// applications, so their authentication handlers can be tested without the
+// network. It signs in deterministic users, and every step can fail or be
+// replaced by a function of the test:
+//
+//	p := mock.New(goth.User{UserID: "42", Name: "Homer", Email: "homer@example.com"})
+//	p.CallbackURL = "/auth/callback?provider=mock"
+//	p.FetchUserErr = errors.New("provider unavailable")
+//	goth.UseProviders(p)
+//
+// The auth URL of BeginAuth is the CallbackURL with the state and a code, so
+// the redirect of gothic.BeginAuthHandler can be followed straight to the
+// callback handled with gothic.CompleteUserAuth. The user is selected by the
+// "user" parameter of the request beginning the authentication, or is the
+// first one, and the callback fails like the providers do when it has an
+// "error" parameter, e.g. error=access_denied.
+package mock
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// ErrUnknownUser is returned for users which are not configured.
+var ErrUnknownUser = errors.New("mock: unknown user")
+
+// DefaultUser is the user signed in by the providers created without users.
+var DefaultUser = goth.User{
+	UserID:    "mock-user",
+	Name:      "Mock User",
+	FirstName: "Mock",
+	LastName:  "User",
+	NickName:  "mock",
+	Email:     "mock@example.com",
+}
+
+// New creates a new mock provider signing in one of the users, or the
+// DefaultUser without users.
+func New(users ...goth.User) *Provider {
+	if len(users) == 0 {
+		users = []goth.User{DefaultUser}
+	}
+	return &Provider{
+		Users:        users,
+		providerName: "mock",
+	}
+}
+
+// Provider is the implementation of `goth.Provider` for the tests.
+type Provider struct {
+	// CallbackURL is the URL BeginAuth sends the user to, with the state and
+	// a code. It defaults to https://mock.example.com/authorize.
+	CallbackURL string
+	// Users are the users who can sign in, selected by their UserID.
+	Users []goth.User
+	// ExpiresIn is the lifetime of the access tokens, they don't expire
+	// when it is zero.
+	ExpiresIn time.Duration
+
+	// The errors returned by the steps of the authentication, when set.
+	BeginAuthErr    error
+	AuthorizeErr    error
+	FetchUserErr    error
+	RefreshTokenErr error
+
+	// The functions replacing the steps of the authentication, when set.
+	BeginAuthFunc    func(state string, params goth.Params) (goth.Session, error)
+	AuthorizeFunc    func(session *Session, params goth.Params) (string, error)
+	FetchUserFunc    func(session *Session) (goth.User, error)
+	RefreshTokenFunc func(refreshToken string) (*oauth2.Token, error)
+
+	HTTPClient   *http.Client
+	providerName string
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Local reports that the provider is a goth.LocalProvider: the users of the mock provider are its own, whatever the tokens.
+func (p *Provider) Local() bool {
+	return true
+}
+
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the mock package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth begins the authentication of the first user.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return p.BeginAuthWithParams(state, url.Values{})
+}
+
+// BeginAuthWithParams begins the authentication of the user selected by the
+// "user" parameter.
+func (p *Provider) BeginAuthWithParams(state string, params goth.Params) (goth.Session, error) {
+	if p.BeginAuthErr != nil {
+		return nil, p.BeginAuthErr
+	}
+	if p.BeginAuthFunc != nil {
+		return p.BeginAuthFunc(state, params)
+	}
+	user, err := p.user(params.Get("user"))
+	if err != nil {
+		return nil, err
+	}
+
+	callbackURL := p.CallbackURL
+	if callbackURL == "" {
+		callbackURL = "https://mock.example.com/authorize"
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("state", state)
+	q.Set("code", "mock-code-"+user.UserID)
+	u.RawQuery = q.Encode()
+
+	return &Session{
+		AuthURL: u.String(),
+		UserID:  user.UserID,
+	}, nil
+}
+
+// FetchUser returns the configured user of the session.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	if p.FetchUserErr != nil {
+		return goth.User{Provider: p.Name()}, p.FetchUserErr
+	}
+	if p.FetchUserFunc != nil {
+		return p.FetchUserFunc(sess)
+	}
+	if sess.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return goth.User{Provider: p.Name()}, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	user, err := p.user(sess.UserID)
+	if err != nil {
+		return goth.User{Provider: p.Name()}, err
+	}
+	user.Provider = p.Name()
+	user.AccessToken = sess.AccessToken
+	user.RefreshToken = sess.RefreshToken
+	user.ExpiresAt = sess.ExpiresAt
+	return user, nil
+}
+
+// RefreshToken returns a new deterministic token, see AccessToken.
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	if p.RefreshTokenErr != nil {
+		return nil, p.RefreshTokenErr
+	}
+	if p.RefreshTokenFunc != nil {
+		return p.RefreshTokenFunc(refreshToken)
+	}
+	token := &oauth2.Token{
+		AccessToken:  "mock-refreshed-" + refreshToken,
+		TokenType:    "Bearer",
+		RefreshToken: refreshToken,
+	}
+	if p.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(p.ExpiresIn)
+	}
+	return token, nil
+}
+
+// RefreshTokenAvailable returns true, the tokens of the mock users can be
+// refreshed.
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// user returns the configured user with the ID, or the first one.
+func (p *Provider) user(id string) (goth.User, error) {
+	for _, u := range p.Users {
+		if id == "" || u.UserID == id {
+			return u, nil
+		}
+	}
+	return goth.User{}, ErrUnknownUser
+}
+
+// AccessToken is the access token of the user authorized by the provider.
+func AccessToken(userID string) string {
+	return "mock-access-" + userID
+}
+
+// RefreshToken is the refresh token of the user authorized by the provider.
+func RefreshToken(userID string) string {
+	return "mock-refresh-" + userID
+}
@@ -0,0 +1,165 @@
+package mock_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/mock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func provider() *mock.Provider {
+	p := mock.New(
+		goth.User{UserID: "admin", Name: "Admin", Email: "admin@example.com"},
+		goth.User{UserID: "viewer", Name: "Viewer", Email: "viewer@example.com"},
+	)
+	p.CallbackURL = "/auth/callback?provider=mock"
+	return p
+}
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := mock.New()
+	a.Equal("mock", p.Name())
+	a.Equal([]goth.User{mock.DefaultUser}, p.Users)
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+	a.Implements((*goth.BeginAuthWithParamsProvider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*mock.Session)
+	a.Equal("admin", s.UserID)
+	a.Equal("/auth/callback?code=mock-code-admin&provider=mock&state=test_state", s.AuthURL)
+
+	session, err = p.BeginAuthWithParams("test_state", url.Values{"user": {"viewer"}})
+	a.NoError(err)
+	a.Equal("viewer", session.(*mock.Session).UserID)
+
+	_, err = p.BeginAuthWithParams("test_state", url.Values{"user": {"root"}})
+	a.Equal(mock.ErrUnknownUser, err)
+
+	session, _ = mock.New().BeginAuth("test_state")
+	a.Equal("https://mock.example.com/authorize?code=mock-code-mock-user&state=test_state", session.(*mock.Session).AuthURL)
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	p.ExpiresIn = time.Hour
+
+	session, err := p.BeginAuthWithParams("test_state", url.Values{"user": {"viewer"}})
+	a.NoError(err)
+	_, err = p.FetchUser(session)
+	a.Error(err)
+
+	token, err := session.Authorize(p, url.Values{"code": {"mock-code-viewer"}})
+	a.NoError(err)
+	a.Equal(mock.AccessToken("viewer"), token)
+
+	user, err := p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("mock", user.Provider)
+	a.Equal("viewer", user.UserID)
+	a.Equal("viewer@example.com", user.Email)
+	a.Equal("mock-access-viewer", user.AccessToken)
+	a.Equal("mock-refresh-viewer", user.RefreshToken)
+	a.WithinDuration(time.Now().Add(time.Hour), user.ExpiresAt, time.Minute)
+
+	_, err = session.Authorize(p, url.Values{"error": {"access_denied"}})
+	a.EqualError(err, "mock: the authorization failed: access_denied")
+}
+
+func Test_Failures(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	failure := errors.New("provider unavailable")
+
+	p := provider()
+	p.BeginAuthErr = failure
+	_, err := p.BeginAuth("test_state")
+	a.Equal(failure, err)
+
+	p = provider()
+	session, _ := p.BeginAuth("test_state")
+	p.AuthorizeErr = failure
+	_, err = session.Authorize(p, url.Values{})
+	a.Equal(failure, err)
+
+	p = provider()
+	session, _ = p.BeginAuth("test_state")
+	_, _ = session.Authorize(p, url.Values{})
+	p.FetchUserErr = failure
+	user, err := p.FetchUser(session)
+	a.Equal(failure, err)
+	a.Equal("mock", user.Provider)
+
+	p = provider()
+	token, err := p.RefreshToken("mock-refresh-admin")
+	a.NoError(err)
+	a.Equal("mock-refreshed-mock-refresh-admin", token.AccessToken)
+	p.RefreshTokenErr = failure
+	_, err = p.RefreshToken("mock-refresh-admin")
+	a.Equal(failure, err)
+}
+
+func Test_Funcs(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	p.BeginAuthFunc = func(state string, params goth.Params) (goth.Session, error) {
+		return &mock.Session{AuthURL: "https://idp.example.com/?state=" + state, UserID: params.Get("user")}, nil
+	}
+	p.AuthorizeFunc = func(session *mock.Session, params goth.Params) (string, error) {
+		session.AccessToken = "scripted-" + params.Get("code")
+		return session.AccessToken, nil
+	}
+	p.FetchUserFunc = func(session *mock.Session) (goth.User, error) {
+		return goth.User{Provider: "mock", UserID: session.UserID, AccessToken: session.AccessToken}, nil
+	}
+	p.RefreshTokenFunc = func(refreshToken string) (*oauth2.Token, error) {
+		return &oauth2.Token{AccessToken: "scripted"}, nil
+	}
+
+	session, err := p.BeginAuthWithParams("test_state", url.Values{"user": {"someone"}})
+	a.NoError(err)
+	authURL, _ := session.GetAuthURL()
+	a.Equal("https://idp.example.com/?state=test_state", authURL)
+	_, err = session.Authorize(p, url.Values{"code": {"abc"}})
+	a.NoError(err)
+	user, err := p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("someone", user.UserID)
+	a.Equal("scripted-abc", user.AccessToken)
+	token, _ := p.RefreshToken("any")
+	a.Equal("scripted", token.AccessToken)
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	s, err := p.UnmarshalSession(`{"AuthURL":"/auth/callback","UserID":"viewer","AccessToken":"mock-access-viewer"}`)
+	a.NoError(err)
+	session := s.(*mock.Session)
+	a.Equal("viewer", session.UserID)
+	a.Equal("mock-access-viewer", session.AccessToken)
+}
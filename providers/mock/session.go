@@ -0,0 +1,75 @@
+package mock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Session stores data during the auth process of a mock user.
+type Session struct {
+	AuthURL      string
+	UserID       string
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+var _ goth.Session = &Session{}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` function on the mock provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize the session of the user selected when it began, and return its
+// deterministic access token, see AccessToken. It fails with the "error"
+// parameter of the callback, like the providers do when the user denies the
+// authorization.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	if p.AuthorizeErr != nil {
+		return "", p.AuthorizeErr
+	}
+	if p.AuthorizeFunc != nil {
+		return p.AuthorizeFunc(s, params)
+	}
+	if e := params.Get("error"); e != "" {
+		return "", fmt.Errorf("mock: the authorization failed: %s", e)
+	}
+	_, err := p.user(s.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	s.AccessToken = AccessToken(s.UserID)
+	s.RefreshToken = RefreshToken(s.UserID)
+	if p.ExpiresIn > 0 {
+		s.ExpiresAt = time.Now().Add(p.ExpiresIn)
+	}
+	return s.AccessToken, nil
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return goth.RedactJSON(s.Marshal())
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	s := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
+	return s, err
+}
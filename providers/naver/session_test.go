@@ -49,5 +49,5 @@ func Test_String(t *testing.T) {
 		AccessToken: "1234567890",
 	}
 
-	a.Equal(s.String(), s.Marshal())
+	a.Equal(`{"AccessToken":"REDACTED","AuthURL":"https://nid.naver.com/oauth2.0/authorize","ExpiresAt":"0001-01-01T00:00:00Z","RefreshToken":""}`, s.String())
 }
@@ -9,6 +9,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	"github.com/andreimerlescu/goth"
 	"golang.org/x/oauth2"
@@ -62,6 +63,7 @@ func NewCustomisedURL(clientKey, secret, callbackURL, authURL, tokenURL, profile
 // NewCustomisedDNS is the simplest method to create a provider based only on your key/secret
 // and the beginning of the URL to your server, e.g. https://my.server.name/
 func NewCustomisedDNS(clientKey, secret, callbackURL, nextcloudURL string, scopes ...string) *Provider {
+	nextcloudURL = strings.TrimRight(nextcloudURL, "/")
 	return NewCustomisedURL(
 		clientKey,
 		secret,
@@ -83,6 +85,17 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -117,6 +130,9 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, err
 	}
 	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	req.Header.Set("Accept", "application/json")
+	// Nextcloud rejects OCS requests without this header as a CSRF measure.
+	req.Header.Set("OCS-APIRequest", "true")
 	response, err := p.Client().Do(req)
 
 	if err != nil {
@@ -143,8 +159,16 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 
 	err = userFromReader(bytes.NewReader(bits), &user)
+	if err != nil {
+		return user, err
+	}
 
-	return user, err
+	if user.UserID == "" {
+		// the token response already names the user, fall back to it
+		user.UserID = sess.UserID
+		user.NickName = sess.UserID
+	}
+	return user, nil
 }
 
 func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *oauth2.Config {
@@ -170,22 +194,38 @@ func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *o
 func userFromReader(r io.Reader, user *goth.User) error {
 	u := struct {
 		Ocs struct {
-			Data struct {
-				EMail       string `json:"email"`
-				DisplayName string `json:"display-name"`
-				ID          string `json:"id"`
-				Address     string `json:"address"`
-			}
+			Meta struct {
+				Status     string `json:"status"`
+				StatusCode int    `json:"statuscode"`
+				Message    string `json:"message"`
+			} `json:"meta"`
+			Data json.RawMessage `json:"data"`
 		} `json:"ocs"`
 	}{}
 	err := json.NewDecoder(r).Decode(&u)
 	if err != nil {
 		return err
 	}
-	user.Email = u.Ocs.Data.EMail
-	user.Name = u.Ocs.Data.DisplayName
-	user.UserID = u.Ocs.Data.ID
-	user.Location = u.Ocs.Data.Address
+	if u.Ocs.Meta.Status != "" && u.Ocs.Meta.Status != "ok" {
+		return fmt.Errorf("nextcloud OCS request failed with status %d: %s", u.Ocs.Meta.StatusCode, u.Ocs.Meta.Message)
+	}
+
+	data := struct {
+		EMail       string `json:"email"`
+		DisplayName string `json:"display-name"`
+		ID          string `json:"id"`
+		Address     string `json:"address"`
+	}{}
+	if len(u.Ocs.Data) > 0 {
+		if err := json.Unmarshal(u.Ocs.Data, &data); err != nil {
+			return err
+		}
+	}
+	user.Email = data.EMail
+	user.Name = data.DisplayName
+	user.NickName = data.ID
+	user.UserID = data.ID
+	user.Location = data.Address
 	return nil
 }
 
@@ -1,6 +1,9 @@
 package nextcloud_test
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -45,6 +48,54 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "/apps/oauth2/authorize?client_id=")
 }
 
+func Test_NewCustomisedDNS_TrailingSlash(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := nextcloud.NewCustomisedDNS(os.Getenv("NEXTCLOUD_KEY"), os.Getenv("NEXTCLOUD_SECRET"), "/foo", "https://cloud.example.com/")
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*nextcloud.Session)
+	a.Contains(s.AuthURL, "https://cloud.example.com/apps/oauth2/authorize?")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ocs/v2.php/cloud/user" || r.Header.Get("OCS-APIRequest") != "true" || r.Header.Get("Authorization") != "Bearer 1234567890" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"ocs":{"meta":{"status":"ok","statuscode":200,"message":"OK"},"data":{"id":"jdoe","display-name":"Jane Doe","email":"jane@example.com","address":"Berlin"}}}`)
+	}))
+	defer server.Close()
+
+	p := nextcloud.NewCustomisedDNS(os.Getenv("NEXTCLOUD_KEY"), os.Getenv("NEXTCLOUD_SECRET"), "/foo", server.URL+"/")
+	user, err := p.FetchUser(&nextcloud.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("jdoe", user.UserID)
+	a.Equal("jdoe", user.NickName)
+	a.Equal("Jane Doe", user.Name)
+	a.Equal("jane@example.com", user.Email)
+	a.Equal("Berlin", user.Location)
+}
+
+func Test_FetchUser_OCSFailure(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ocs":{"meta":{"status":"failure","statuscode":997,"message":"Current user is not logged in"},"data":[]}}`)
+	}))
+	defer server.Close()
+
+	p := nextcloud.NewCustomisedDNS(os.Getenv("NEXTCLOUD_KEY"), os.Getenv("NEXTCLOUD_SECRET"), "/foo", server.URL)
+	_, err := p.FetchUser(&nextcloud.Session{AccessToken: "1234567890"})
+	a.Error(err)
+	a.Contains(err.Error(), "997")
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -15,6 +15,7 @@ type Session struct {
 	AccessToken  string
 	RefreshToken string
 	ExpiresAt    time.Time
+	UserID       string `json:",omitempty"`
 }
 
 var _ goth.Session = &Session{}
@@ -42,6 +43,10 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
+	// Nextcloud returns the id of the authorising user alongside the token.
+	if userID, ok := token.Extra("user_id").(string); ok {
+		s.UserID = userID
+	}
 	return token.AccessToken, err
 }
 
@@ -52,7 +57,7 @@ func (s Session) Marshal() string {
 }
 
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // UnmarshalSession wil unmarshal a JSON string into a session.
@@ -0,0 +1,155 @@
+// Package notion implements the OAuth2 protocol for authenticating users through Notion.
+// This package can be used as a reference implementation of an OAuth2 provider for Goth.
+package notion
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// See https://developers.notion.com/docs/authorization#public-integration-auth-flow-set-up
+const (
+	authURL  string = "https://api.notion.com/v1/oauth/authorize"
+	tokenURL string = "https://api.notion.com/v1/oauth/token"
+)
+
+// Provider is the implementation of `goth.Provider` for accessing Notion.
+type Provider struct {
+	ClientKey    string
+	Secret       string
+	CallbackURL  string
+	HTTPClient   *http.Client
+	config       *oauth2.Config
+	providerName string
+}
+
+// New creates a new Notion provider and sets up important connection details.
+// You should always call `notion.New` to get a new provider.  Never try to
+// create one manually.
+//
+// Notion doesn't use scopes, the capabilities of the integration are configured
+// in Notion and the user picks the pages it can access.
+func New(clientKey, secret, callbackURL string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "notion",
+	}
+	p.config = newConfig(p)
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the notion package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks Notion for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state, oauth2.SetAuthURLParam("owner", "user")),
+	}, nil
+}
+
+// FetchUser returns the user that authorized the integration. Notion sends the
+// user along with the bot and workspace in the token response, so no further
+// request is made.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		Provider:     p.Name(),
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	user.RawData = map[string]interface{}{
+		"bot_id":                 sess.BotID,
+		"workspace_id":           sess.WorkspaceID,
+		"workspace_name":         sess.WorkspaceName,
+		"workspace_icon":         sess.WorkspaceIcon,
+		"duplicated_template_id": sess.DuplicatedTemplateID,
+		"owner":                  sess.Owner,
+	}
+
+	if sess.Owner.User == nil {
+		// internal integrations are owned by the workspace instead of a user
+		return user, fmt.Errorf("%s integration is not owned by a user", p.providerName)
+	}
+	user.UserID = sess.Owner.User.ID
+	user.Name = sess.Owner.User.Name
+	user.NickName = sess.Owner.User.Name
+	user.AvatarURL = sess.Owner.User.AvatarURL
+	if sess.Owner.User.Person != nil {
+		user.Email = sess.Owner.User.Person.Email
+	}
+	return user, nil
+}
+
+// WorkspaceID returns the ID of the workspace a user fetched by this provider
+// authorized the integration in.
+func WorkspaceID(user goth.User) string {
+	workspaceID, _ := user.RawData["workspace_id"].(string)
+	return workspaceID
+}
+
+// BotID returns the ID of the bot user the integration acts as in the workspace.
+func BotID(user goth.User) string {
+	botID, _ := user.RawData["bot_id"].(string)
+	return botID
+}
+
+func newConfig(provider *Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  authURL,
+			TokenURL: tokenURL,
+		},
+	}
+}
+
+// RefreshTokenAvailable refresh token is not provided by notion
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
+
+// RefreshToken refresh token is not provided by notion, its access tokens don't expire
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by notion")
+}
@@ -0,0 +1,123 @@
+package notion_test
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/notion"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.ClientKey, os.Getenv("NOTION_KEY"))
+	a.Equal(p.Secret, os.Getenv("NOTION_SECRET"))
+	a.Equal(p.CallbackURL, "/foo")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	session, err := p.BeginAuth("test_state")
+	s := session.(*notion.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "https://api.notion.com/v1/oauth/authorize")
+	a.Contains(s.AuthURL, "owner=user")
+	a.Contains(s.AuthURL, "response_type=code")
+}
+
+func Test_AuthorizeAndFetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if id, secret, ok := req.BasicAuth(); !ok || id != "key" || secret != "secret" {
+			res.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(res, `{"error":"invalid_client","error_description":"Client authentication failed."}`)
+			return
+		}
+		body := map[string]string{}
+		json.NewDecoder(req.Body).Decode(&body)
+		if body["code"] != "code" || body["grant_type"] != "authorization_code" {
+			res.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(res, `{"error":"invalid_grant","error_description":"Invalid code."}`)
+			return
+		}
+		fmt.Fprint(res, `{"access_token":"secret_1234","token_type":"bearer","bot_id":"bot-1","workspace_name":"Acme","workspace_icon":"https://example.com/icon.png","workspace_id":"ws-1","owner":{"type":"user","user":{"object":"user","id":"user-1","name":"Ada Lovelace","avatar_url":"https://example.com/ada.png","type":"person","person":{"email":"ada@example.com"}}},"duplicated_template_id":null}`)
+	}))
+	defer server.Close()
+
+	p := notion.New("key", "secret", "/foo")
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	s := &notion.Session{}
+	_, err := s.Authorize(p, url.Values{"code": {"wrong"}})
+	a.EqualError(err, "invalid_grant: Invalid code.")
+
+	token, err := s.Authorize(p, url.Values{"code": {"code"}})
+	a.NoError(err)
+	a.Equal("secret_1234", token)
+	a.Equal("ws-1", s.WorkspaceID)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("user-1", user.UserID)
+	a.Equal("Ada Lovelace", user.Name)
+	a.Equal("ada@example.com", user.Email)
+	a.Equal("https://example.com/ada.png", user.AvatarURL)
+	a.Equal("ws-1", notion.WorkspaceID(user))
+	a.Equal("bot-1", notion.BotID(user))
+	a.Equal("Acme", user.RawData["workspace_name"])
+
+	// the session survives a round trip through the store
+	restored, err := p.UnmarshalSession(s.Marshal())
+	a.NoError(err)
+	user, err = p.FetchUser(restored)
+	a.NoError(err)
+	a.Equal("ada@example.com", user.Email)
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://api.notion.com/v1/oauth/authorize","AccessToken":"1234567890","WorkspaceID":"ws-1"}`)
+	a.NoError(err)
+
+	s := session.(*notion.Session)
+	a.Equal(s.AuthURL, "https://api.notion.com/v1/oauth/authorize")
+	a.Equal(s.AccessToken, "1234567890")
+	a.Equal(s.WorkspaceID, "ws-1")
+}
+
+func provider() *notion.Provider {
+	return notion.New(os.Getenv("NOTION_KEY"), os.Getenv("NOTION_SECRET"), "/foo")
+}
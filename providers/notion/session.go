@@ -0,0 +1,145 @@
+package notion
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Session stores data during the auth process with Notion.
+type Session struct {
+	AuthURL              string
+	AccessToken          string
+	RefreshToken         string
+	ExpiresAt            time.Time
+	BotID                string
+	WorkspaceID          string
+	WorkspaceName        string
+	WorkspaceIcon        string
+	DuplicatedTemplateID string
+	Owner                Owner
+}
+
+// Owner is the owner of the integration returned with the token. For public
+// integrations it is the user who authorized it.
+type Owner struct {
+	Type string `json:"type"`
+	User *User  `json:"user,omitempty"`
+}
+
+// User is a Notion user.
+type User struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+	Type      string `json:"type"`
+	Person    *struct {
+		Email string `json:"email"`
+	} `json:"person,omitempty"`
+}
+
+var _ goth.Session = &Session{}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` function on the Notion provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize the session with Notion and return the access token to be stored for future use.
+// The token endpoint is called directly since it takes a JSON body.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+
+	body, err := json.Marshal(map[string]string{
+		"grant_type":   "authorization_code",
+		"code":         params.Get("code"),
+		"redirect_uri": p.config.RedirectURL,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.config.Endpoint.TokenURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(p.config.ClientID, p.config.ClientSecret)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	token := struct {
+		AccessToken          string `json:"access_token"`
+		RefreshToken         string `json:"refresh_token"`
+		ExpiresIn            int64  `json:"expires_in"`
+		BotID                string `json:"bot_id"`
+		WorkspaceID          string `json:"workspace_id"`
+		WorkspaceName        string `json:"workspace_name"`
+		WorkspaceIcon        string `json:"workspace_icon"`
+		DuplicatedTemplateID string `json:"duplicated_template_id"`
+		Owner                Owner  `json:"owner"`
+		Error                string `json:"error"`
+		ErrorDescription     string `json:"error_description"`
+	}{}
+	err = json.Unmarshal(bits, &token)
+	if err != nil {
+		return "", err
+	}
+
+	if token.Error != "" {
+		return "", fmt.Errorf("%s: %s", token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", errors.New("Invalid token received from provider")
+	}
+
+	s.AccessToken = token.AccessToken
+	s.RefreshToken = token.RefreshToken
+	if token.ExpiresIn > 0 {
+		s.ExpiresAt = goth.Now().UTC().Add(time.Second * time.Duration(token.ExpiresIn))
+	}
+	s.BotID = token.BotID
+	s.WorkspaceID = token.WorkspaceID
+	s.WorkspaceName = token.WorkspaceName
+	s.WorkspaceIcon = token.WorkspaceIcon
+	s.DuplicatedTemplateID = token.DuplicatedTemplateID
+	s.Owner = token.Owner
+	return s.AccessToken, nil
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return goth.RedactJSON(s.Marshal())
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	s := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
+	return s, err
+}
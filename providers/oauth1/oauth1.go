@@ -0,0 +1,289 @@
+// Package oauth1 implements a configurable OAuth 1.0a provider, for the
+// services which have no dedicated provider, e.g. Discogs or the server and
+// data center editions of the Atlassian products.
+//
+// The provider runs the three legged flow of https://oauth.net/core/1.0a/:
+// it gets a request token, sends the user to the authorize URL, exchanges
+// the verifier for an access token and fetches the user from a profile URL
+// with a signed request.
+//
+//	p := oauth1.New(key, secret, "https://example.com/auth/discogs/callback", oauth1.Endpoints{
+//		RequestTokenURL: "https://api.discogs.com/oauth/request_token",
+//		AuthorizeURL:    "https://www.discogs.com/oauth/authorize",
+//		AccessTokenURL:  "https://api.discogs.com/oauth/access_token",
+//		ProfileURL:      "https://api.discogs.com/oauth/identity",
+//	})
+//	p.SetName("discogs")
+package oauth1
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/mrjones/oauth"
+	"golang.org/x/oauth2"
+)
+
+// Endpoints are the URLs of an OAuth 1.0a service provider.
+type Endpoints struct {
+	RequestTokenURL string
+	AuthorizeURL    string
+	AccessTokenURL  string
+	// ProfileURL returns the user as JSON. When it is empty the user is read
+	// from the additional parameters of the access token response, like
+	// the user_id and screen_name of Twitter.
+	ProfileURL string
+}
+
+// Provider is the implementation of `goth.Provider` for accessing an OAuth 1.0a service.
+type Provider struct {
+	ClientKey   string
+	Secret      string
+	CallbackURL string
+	HTTPClient  *http.Client
+	Endpoints   Endpoints
+	// PrivateKey signs the requests with RSA-SHA1 instead of HMAC-SHA1, and
+	// the Secret is not used. Atlassian application links require it.
+	PrivateKey *rsa.PrivateKey
+	// HTTPMethod is the method of the token requests, GET by default.
+	HTTPMethod string
+
+	// RequestTokenParams are sent with the request token request, e.g. a scope.
+	RequestTokenParams map[string]string
+	// AuthorizeParams are added to the authorize URL.
+	AuthorizeParams map[string]string
+	// ProfileParams are sent with the profile request.
+	ProfileParams map[string]string
+
+	// The keys of the profile fields of the user, the first one found is
+	// used. Nested fields are separated by dots, e.g. "avatarUrls.48x48".
+	UserIDKeys      []string
+	NameKeys        []string
+	NickNameKeys    []string
+	EmailKeys       []string
+	AvatarURLKeys   []string
+	FirstNameKeys   []string
+	LastNameKeys    []string
+	DescriptionKeys []string
+	LocationKeys    []string
+
+	debug        bool
+	providerName string
+}
+
+// New creates a new OAuth 1.0a provider signing with HMAC-SHA1, and sets up
+// important connection details.
+// You should always call `oauth1.New` to get a new Provider. Never try to create
+// one manually.
+func New(clientKey, secret, callbackURL string, endpoints Endpoints) *Provider {
+	return &Provider{
+		ClientKey:       clientKey,
+		Secret:          secret,
+		CallbackURL:     callbackURL,
+		Endpoints:       endpoints,
+		UserIDKeys:      []string{"id_str", "id", "user_id", "key", "account_id"},
+		NameKeys:        []string{"name", "displayName", "full_name"},
+		NickNameKeys:    []string{"username", "screen_name", "login", "nickname"},
+		EmailKeys:       []string{"email", "emailAddress"},
+		AvatarURLKeys:   []string{"avatar_url", "profile_image_url", "avatarUrl"},
+		FirstNameKeys:   []string{"first_name", "given_name"},
+		LastNameKeys:    []string{"last_name", "family_name"},
+		DescriptionKeys: []string{"description", "bio"},
+		LocationKeys:    []string{"location"},
+		providerName:    "oauth1",
+	}
+}
+
+// NewRSA is the same as New, but signs the requests with RSA-SHA1 using the
+// private key registered with the service.
+func NewRSA(clientKey string, privateKey *rsa.PrivateKey, callbackURL string, endpoints Endpoints) *Provider {
+	p := New(clientKey, "", callbackURL, endpoints)
+	p.PrivateKey = privateKey
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug sets the logging of the OAuth client to verbose.
+func (p *Provider) Debug(debug bool) {
+	p.debug = debug
+}
+
+// BeginAuth asks the service for a request token and returns the URL to
+// authorize it. OAuth 1.0a has no state parameter, so the state is added to
+// the callback URL, which the service keeps when redirecting back.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	callbackURL := p.CallbackURL
+	if state != "" {
+		u, err := url.Parse(callbackURL)
+		if err != nil {
+			return nil, err
+		}
+		q := u.Query()
+		q.Set("state", state)
+		u.RawQuery = q.Encode()
+		callbackURL = u.String()
+	}
+
+	c, err := p.consumer()
+	if err != nil {
+		return nil, err
+	}
+	requestToken, authURL, err := c.GetRequestTokenAndUrlWithParams(callbackURL, p.RequestTokenParams)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		AuthURL:      authURL,
+		RequestToken: requestToken,
+	}, nil
+}
+
+// FetchUser will go to the service and access basic information about the user.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		Provider: p.Name(),
+	}
+
+	if sess.AccessToken == nil {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+	user.AccessToken = sess.AccessToken.Token
+	user.AccessTokenSecret = sess.AccessToken.Secret
+
+	if p.Endpoints.ProfileURL == "" {
+		user.RawData = make(map[string]interface{}, len(sess.AccessToken.AdditionalData))
+		for k, v := range sess.AccessToken.AdditionalData {
+			user.RawData[k] = v
+		}
+		p.userFromProfile(&user)
+		return user, nil
+	}
+
+	c, err := p.consumer()
+	if err != nil {
+		return user, err
+	}
+	response, err := c.Get(p.Endpoints.ProfileURL, p.ProfileParams, sess.AccessToken)
+	if err != nil {
+		return user, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
+	}
+
+	d := json.NewDecoder(io.LimitReader(response.Body, 1<<20))
+	d.UseNumber()
+	if err := d.Decode(&user.RawData); err != nil {
+		return user, err
+	}
+	p.userFromProfile(&user)
+	return user, nil
+}
+
+// RefreshToken refresh token is not provided by OAuth 1.0a
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by OAuth 1.0a")
+}
+
+// RefreshTokenAvailable refresh token is not provided by OAuth 1.0a
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
+
+// consumer builds the OAuth client from the current configuration.
+func (p *Provider) consumer() (*oauth.Consumer, error) {
+	if p.Endpoints.RequestTokenURL == "" || p.Endpoints.AuthorizeURL == "" || p.Endpoints.AccessTokenURL == "" {
+		return nil, fmt.Errorf("%s is missing its request token, authorize or access token URL", p.providerName)
+	}
+	sp := oauth.ServiceProvider{
+		RequestTokenUrl:   p.Endpoints.RequestTokenURL,
+		AuthorizeTokenUrl: p.Endpoints.AuthorizeURL,
+		AccessTokenUrl:    p.Endpoints.AccessTokenURL,
+		HttpMethod:        p.HTTPMethod,
+	}
+
+	var c *oauth.Consumer
+	if p.PrivateKey != nil {
+		c = oauth.NewCustomRSAConsumer(p.ClientKey, p.PrivateKey, crypto.SHA1, sp, p.Client())
+	} else {
+		c = oauth.NewCustomHttpClientConsumer(p.ClientKey, p.Secret, sp, p.Client())
+	}
+	for k, v := range p.AuthorizeParams {
+		c.AdditionalAuthorizationUrlParams[k] = v
+	}
+	c.Debug(p.debug)
+	return c, nil
+}
+
+func (p *Provider) userFromProfile(user *goth.User) {
+	user.UserID = profileValue(user.RawData, p.UserIDKeys)
+	user.Name = profileValue(user.RawData, p.NameKeys)
+	user.NickName = profileValue(user.RawData, p.NickNameKeys)
+	user.Email = profileValue(user.RawData, p.EmailKeys)
+	user.AvatarURL = profileValue(user.RawData, p.AvatarURLKeys)
+	user.FirstName = profileValue(user.RawData, p.FirstNameKeys)
+	user.LastName = profileValue(user.RawData, p.LastNameKeys)
+	user.Description = profileValue(user.RawData, p.DescriptionKeys)
+	user.Location = profileValue(user.RawData, p.LocationKeys)
+}
+
+// profileValue returns the first of the keys found in the profile, as a string.
+func profileValue(profile map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		var v interface{} = profile
+		for _, part := range strings.Split(key, ".") {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				v = nil
+				break
+			}
+			v = m[part]
+		}
+		switch v := v.(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case json.Number:
+			return v.String()
+		case bool:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,269 @@
+package oauth1_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/oauth1"
+	"github.com/mrjones/oauth"
+	"github.com/stretchr/testify/assert"
+)
+
+// authorization parses the oauth parameters of the Authorization header.
+func authorization(req *http.Request) map[string]string {
+	params := map[string]string{}
+	header := strings.TrimPrefix(req.Header.Get("Authorization"), "OAuth ")
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		v, _ := url.QueryUnescape(strings.Trim(kv[1], `"`))
+		params[kv[0]] = v
+	}
+	return params
+}
+
+func server(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/request_token", func(res http.ResponseWriter, req *http.Request) {
+		params := authorization(req)
+		if params["oauth_consumer_key"] != "KEY" || params["oauth_callback"] == "" {
+			http.Error(res, "bad request", http.StatusBadRequest)
+			return
+		}
+		res.Header().Set("X-Signature-Method", params["oauth_signature_method"])
+		res.Header().Set("X-Callback", params["oauth_callback"])
+		fmt.Fprint(res, "oauth_token=REQUEST&oauth_token_secret=REQUEST_SECRET&oauth_callback_confirmed=true")
+	})
+	mux.HandleFunc("/access_token", func(res http.ResponseWriter, req *http.Request) {
+		params := authorization(req)
+		if params["oauth_token"] != "REQUEST" || params["oauth_verifier"] != "VERIFIER" {
+			http.Error(res, "bad verifier", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(res, "oauth_token=ACCESS&oauth_token_secret=ACCESS_SECRET&user_id=42&screen_name=homer")
+	})
+	mux.HandleFunc("/profile", func(res http.ResponseWriter, req *http.Request) {
+		if authorization(req)["oauth_token"] != "ACCESS" {
+			http.Error(res, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(res, `{"key":"JIRAUSER10000","name":"homer","displayName":"Homer Simpson","emailAddress":"homer@example.com","avatarUrls":{"48x48":"https://example.com/homer.png"},"id":1234567}`)
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func provider(ts *httptest.Server) *oauth1.Provider {
+	p := oauth1.New("KEY", "SECRET", ts.URL+"/callback", oauth1.Endpoints{
+		RequestTokenURL: ts.URL + "/request_token",
+		AuthorizeURL:    ts.URL + "/authorize",
+		AccessTokenURL:  ts.URL + "/access_token",
+		ProfileURL:      ts.URL + "/profile",
+	})
+	p.UserIDKeys = []string{"key"}
+	p.NameKeys = []string{"displayName"}
+	p.NickNameKeys = []string{"name"}
+	p.AvatarURLKeys = []string{"avatarUrls.48x48"}
+	return p
+}
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := oauth1.New("KEY", "SECRET", "/foo", oauth1.Endpoints{})
+	a.Equal("KEY", p.ClientKey)
+	a.Equal("SECRET", p.Secret)
+	a.Equal("/foo", p.CallbackURL)
+	a.Equal("oauth1", p.Name())
+
+	p.SetName("discogs")
+	a.Equal("discogs", p.Name())
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Implements((*goth.Provider)(nil), oauth1.New("KEY", "SECRET", "/foo", oauth1.Endpoints{}))
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ts := server(t)
+
+	p := provider(ts)
+	p.AuthorizeParams = map[string]string{"name": "example"}
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*oauth1.Session)
+	a.Equal("REQUEST", s.RequestToken.Token)
+	a.Equal("REQUEST_SECRET", s.RequestToken.Secret)
+
+	u, err := url.Parse(s.AuthURL)
+	a.NoError(err)
+	a.Equal("/authorize", u.Path)
+	a.Equal("REQUEST", u.Query().Get("oauth_token"))
+	a.Equal("example", u.Query().Get("name"))
+}
+
+func Test_BeginAuth_Callback(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ts := server(t)
+
+	var callback, method string
+	p := provider(ts)
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		res, err := http.DefaultTransport.RoundTrip(req)
+		if err == nil {
+			callback = res.Header.Get("X-Callback")
+			method = res.Header.Get("X-Signature-Method")
+		}
+		return res, err
+	})}
+	_, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Equal(ts.URL+"/callback?state=test_state", callback)
+	a.Equal("HMAC-SHA1", method)
+}
+
+func Test_BeginAuth_RSA(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ts := server(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+
+	var method string
+	p := oauth1.NewRSA("KEY", key, ts.URL+"/callback", provider(ts).Endpoints)
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		res, err := http.DefaultTransport.RoundTrip(req)
+		if err == nil {
+			method = res.Header.Get("X-Signature-Method")
+		}
+		return res, err
+	})}
+	_, err = p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Equal("RSA-SHA1", method)
+}
+
+func Test_BeginAuth_MissingEndpoints(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	_, err := oauth1.New("KEY", "SECRET", "/foo", oauth1.Endpoints{}).BeginAuth("test_state")
+	a.Error(err)
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ts := server(t)
+
+	p := provider(ts)
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+
+	token, err := session.Authorize(p, url.Values{"oauth_token": {"REQUEST"}, "oauth_verifier": {"VERIFIER"}})
+	a.NoError(err)
+	a.Equal("ACCESS", token)
+	a.Equal("ACCESS_SECRET", session.(*oauth1.Session).AccessToken.Secret)
+
+	session, err = p.BeginAuth("test_state")
+	a.NoError(err)
+	_, err = session.Authorize(p, url.Values{"oauth_token": {"OTHER"}, "oauth_verifier": {"VERIFIER"}})
+	a.Error(err)
+
+	_, err = session.Authorize(p, url.Values{"oauth_token": {"REQUEST"}, "oauth_verifier": {"WRONG"}})
+	a.Error(err)
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ts := server(t)
+
+	p := provider(ts)
+	user, err := p.FetchUser(&oauth1.Session{AccessToken: &oauth.AccessToken{Token: "ACCESS", Secret: "ACCESS_SECRET"}})
+	a.NoError(err)
+	a.Equal("oauth1", user.Provider)
+	a.Equal("JIRAUSER10000", user.UserID)
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("homer", user.NickName)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("https://example.com/homer.png", user.AvatarURL)
+	a.Equal("ACCESS", user.AccessToken)
+	a.Equal("ACCESS_SECRET", user.AccessTokenSecret)
+
+	p.UserIDKeys = []string{"id"}
+	user, err = p.FetchUser(&oauth1.Session{AccessToken: &oauth.AccessToken{Token: "ACCESS", Secret: "ACCESS_SECRET"}})
+	a.NoError(err)
+	a.Equal("1234567", user.UserID)
+
+	_, err = p.FetchUser(&oauth1.Session{AccessToken: &oauth.AccessToken{Token: "REVOKED", Secret: "ACCESS_SECRET"}})
+	a.Error(err)
+}
+
+func Test_FetchUser_AccessTokenData(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	ts := server(t)
+
+	p := provider(ts)
+	p.Endpoints.ProfileURL = ""
+	p.UserIDKeys = []string{"user_id"}
+	p.NickNameKeys = []string{"screen_name"}
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	_, err = session.Authorize(p, url.Values{"oauth_verifier": {"VERIFIER"}})
+	a.NoError(err)
+
+	user, err := p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("42", user.UserID)
+	a.Equal("homer", user.NickName)
+	a.Equal("ACCESS", user.AccessToken)
+}
+
+func Test_FetchUser_WithoutAccessToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := oauth1.New("KEY", "SECRET", "/foo", oauth1.Endpoints{})
+	_, err := p.FetchUser(&oauth1.Session{})
+	a.Error(err)
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := oauth1.New("KEY", "SECRET", "/foo", oauth1.Endpoints{})
+	s, err := p.UnmarshalSession(`{"AuthURL":"http://com/auth_url","AccessToken":{"Token":"1234567890","Secret":"secret!!","AdditionalData":{}},"RequestToken":{"Token":"0987654321","Secret":"!!secret"}}`)
+	a.NoError(err)
+	session := s.(*oauth1.Session)
+	a.Equal("http://com/auth_url", session.AuthURL)
+	a.Equal("1234567890", session.AccessToken.Token)
+	a.Equal("0987654321", session.RequestToken.Token)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,67 @@
+package oauth1
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/mrjones/oauth"
+)
+
+var _ goth.Session = &Session{}
+
+// Session stores data during the auth process with the OAuth 1.0a service.
+type Session struct {
+	AuthURL      string
+	AccessToken  *oauth.AccessToken
+	RequestToken *oauth.RequestToken
+}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` function on the OAuth 1.0a provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize the session with the service and return the access token to be stored for future use.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	if s.RequestToken == nil {
+		return "", errors.New("oauth1: the session has no request token")
+	}
+	if token := params.Get("oauth_token"); token != "" && token != s.RequestToken.Token {
+		return "", errors.New("oauth1: the callback is for another request token")
+	}
+
+	c, err := p.consumer()
+	if err != nil {
+		return "", err
+	}
+	accessToken, err := c.AuthorizeToken(s.RequestToken, params.Get("oauth_verifier"))
+	if err != nil {
+		return "", err
+	}
+
+	s.AccessToken = accessToken
+	return accessToken.Token, nil
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return goth.RedactJSON(s.Marshal())
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	sess := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(sess)
+	return sess, err
+}
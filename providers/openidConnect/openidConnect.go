@@ -2,6 +2,7 @@ package openidConnect
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -13,6 +14,8 @@ import (
 	"time"
 
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/jwks"
+	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/oauth2"
 )
 
@@ -56,6 +59,13 @@ type Provider struct {
 	CallbackURL  string
 	HTTPClient   *http.Client
 	OpenIDConfig *OpenIDConfig
+	// Keys verify the signatures of the ID tokens. New creates them from the
+	// jwks_uri of the discovery document, fetched with the HTTPClient of the
+	// provider when it is created, and prefetched once the provider is
+	// registered with goth.UseProviders. Without keys, e.g. with
+	// NewCustomisedURL, the ID tokens received from the token endpoint are
+	// trusted without verifying their signatures.
+	Keys         *jwks.Cache
 	config       *oauth2.Config
 	providerName string
 
@@ -81,6 +91,11 @@ type OpenIDConfig struct {
 	// https://openid.net/specs/openid-connect-session-1_0-17.html#OPMetadata
 	EndSessionEndpoint string `json:"end_session_endpoint,omitempty"`
 	Issuer             string `json:"issuer"`
+
+	// JWKSURI is the key set verifying the signatures of the ID tokens, signed
+	// with one of the IDTokenSigningAlgs, RS256 when there are none.
+	JWKSURI            string   `json:"jwks_uri,omitempty"`
+	IDTokenSigningAlgs []string `json:"id_token_signing_alg_values_supported,omitempty"`
 }
 
 type RefreshTokenResponse struct {
@@ -90,7 +105,7 @@ type RefreshTokenResponse struct {
 	// refresh token flow. As a result, a new ID token may not be returned in a successful
 	// response.
 	// See more: https://openid.net/specs/openid-connect-core-1_0.html#RefreshingAccessToken
-	IdToken string `json:"id_token, omitempty"`
+	IdToken string `json:"id_token,omitempty"`
 
 	// The OAuth spec defines the refresh token as an optional response field in the
 	// refresh token flow. As a result, a new refresh token may not be returned in a successful
@@ -141,6 +156,9 @@ func NewNamed(name, clientKey, secret, callbackURL, openIDAutoDiscoveryURL strin
 		return nil, err
 	}
 	p.OpenIDConfig = openIDConfig
+	if openIDConfig.JWKSURI != "" {
+		p.Keys = jwks.New(openIDConfig.JWKSURI, p.HTTPClient)
+	}
 
 	p.config = newConfig(p, scopes, openIDConfig)
 	return p, nil
@@ -186,6 +204,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -193,6 +221,20 @@ func (p *Provider) Client() *http.Client {
 // Debug is a no-op for the openidConnect package.
 func (p *Provider) Debug(debug bool) {}
 
+// HealthEndpoint returns the token endpoint, to check whether the provider is
+// reachable.
+func (p *Provider) HealthEndpoint() string {
+	return p.OpenIDConfig.TokenEndpoint
+}
+
+// Prefetch fetches the keys verifying the ID tokens in the background, so
+// the logins don't wait for them, see goth.PrefetchProvider.
+func (p *Provider) Prefetch() {
+	if p.Keys != nil {
+		p.Keys.Prefetch()
+	}
+}
+
 // BeginAuth asks the OpenID Connect provider for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 	url := p.config.AuthCodeURL(state)
@@ -204,6 +246,11 @@ func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 
 // FetchUser will use the id_token and access requested information about the user.
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	return p.FetchUserContext(context.Background(), session)
+}
+
+// FetchUserContext is FetchUser, requesting the user info with the context.
+func (p *Provider) FetchUserContext(ctx context.Context, session goth.Session) (goth.User, error) {
 	sess := session.(*Session)
 
 	expiresAt := sess.ExpiresAt
@@ -212,6 +259,10 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return goth.User{}, fmt.Errorf("%s cannot get user information without id_token", p.providerName)
 	}
 
+	if err := p.verifySignature(ctx, sess.IDToken); err != nil {
+		return goth.User{}, fmt.Errorf("oauth2: error verifying JWT token: %v", err)
+	}
+
 	// decode returned id token to get expiry
 	claims, err := decodeJWT(sess.IDToken)
 
@@ -228,7 +279,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		expiresAt = expiry
 	}
 
-	if err := p.getUserInfo(sess.AccessToken, claims); err != nil {
+	if err := p.getUserInfo(ctx, sess.AccessToken, claims); err != nil {
 		return goth.User{}, err
 	}
 
@@ -253,7 +304,7 @@ func (p *Provider) RefreshTokenAvailable() bool {
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
 	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(oauth2.NoContext, token)
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
 	newToken, err := ts.Token()
 	if err != nil {
 		return nil, err
@@ -329,14 +380,39 @@ func (p *Provider) validateClaims(claims map[string]interface{}) (time.Time, err
 
 	// expiry is required for JWT, not for UserInfoResponse
 	// is actually a int64, so force it in to that type
-	expiryClaim := int64(claims[expiryClaim].(float64))
-	expiry := time.Unix(expiryClaim, 0)
-	if expiry.Add(clockSkew).Before(time.Now()) {
+	exp, ok := claims[expiryClaim].(float64)
+	if !ok {
+		return time.Time{}, errors.New("user info JWT token has no expiry")
+	}
+	expiry := time.Unix(int64(exp), 0)
+	if expiry.Add(clockSkew).Before(goth.Now()) {
 		return time.Time{}, errors.New("user info JWT token is expired")
 	}
 	return expiry, nil
 }
 
+// verifySignature verifies the signature of the ID token with the Keys, or
+// the client secret for the HMAC algorithms, when the provider has keys.
+// The claims are validated by validateClaims.
+// http://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
+func (p *Provider) verifySignature(ctx context.Context, idToken string) error {
+	if p.Keys == nil {
+		return nil
+	}
+	algorithms := p.OpenIDConfig.IDTokenSigningAlgs
+	if len(algorithms) == 0 {
+		algorithms = []string{"RS256"}
+	}
+	keyfunc := p.Keys.Keyfunc(ctx)
+	_, err := jwt.Parse(idToken, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+			return []byte(p.Secret), nil
+		}
+		return keyfunc(t)
+	}, jwt.WithValidMethods(goth.JWTAlgorithms(algorithms...)), jwt.WithoutClaimsValidation())
+	return err
+}
+
 func (p *Provider) userFromClaims(claims map[string]interface{}, user *goth.User) {
 	// required
 	user.UserID = getClaimValue(claims, p.UserIdClaims)
@@ -350,13 +426,13 @@ func (p *Provider) userFromClaims(claims map[string]interface{}, user *goth.User
 	user.Location = getClaimValue(claims, p.LocationClaims)
 }
 
-func (p *Provider) getUserInfo(accessToken string, claims map[string]interface{}) error {
+func (p *Provider) getUserInfo(ctx context.Context, accessToken string, claims map[string]interface{}) error {
 	// skip if there is no UserInfoEndpoint or is explicitly disabled
 	if p.OpenIDConfig.UserInfoEndpoint == "" || p.SkipUserInfoRequest {
 		return nil
 	}
 
-	userInfoClaims, err := p.fetchUserInfo(p.OpenIDConfig.UserInfoEndpoint, accessToken)
+	userInfoClaims, err := p.fetchUserInfo(ctx, p.OpenIDConfig.UserInfoEndpoint, accessToken)
 	if err != nil {
 		return err
 	}
@@ -385,8 +461,8 @@ func (p *Provider) getUserInfo(accessToken string, claims map[string]interface{}
 }
 
 // fetch and decode JSON from the given UserInfo URL
-func (p *Provider) fetchUserInfo(url, accessToken string) (map[string]interface{}, error) {
-	req, _ := http.NewRequest("GET", url, nil)
+func (p *Provider) fetchUserInfo(ctx context.Context, url, accessToken string) (map[string]interface{}, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
 
 	resp, err := p.Client().Do(req)
@@ -1,13 +1,18 @@
 package openidConnect
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothtest"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,6 +40,8 @@ func Test_New(t *testing.T) {
 	a.Equal("https://accounts.google.com/o/oauth2/v2/auth", provider.OpenIDConfig.AuthEndpoint)
 	a.Equal("https://www.googleapis.com/oauth2/v4/token", provider.OpenIDConfig.TokenEndpoint)
 	a.Equal("https://www.googleapis.com/oauth2/v3/userinfo", provider.OpenIDConfig.UserInfoEndpoint)
+	a.Equal("https://www.googleapis.com/oauth2/v3/certs", provider.Keys.URL)
+	a.Equal([]string{"RS256"}, provider.OpenIDConfig.IDTokenSigningAlgs)
 }
 
 func Test_NewCustomisedURL(t *testing.T) {
@@ -61,6 +68,7 @@ func Test_NewCustomisedURL(t *testing.T) {
 	a.Equal("https://www.googleapis.com/oauth2/v4/token", provider.OpenIDConfig.TokenEndpoint)
 	a.Equal("https://www.googleapis.com/oauth2/v3/userinfo", provider.OpenIDConfig.UserInfoEndpoint)
 	a.Equal("", provider.OpenIDConfig.EndSessionEndpoint)
+	a.Nil(provider.Keys)
 }
 
 func Test_BeginAuth(t *testing.T) {
@@ -99,6 +107,48 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal("abc", session.IDToken)
 }
 
+func Test_FetchUser_VerifiesIDToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	s := gothtest.NewOIDCServer(t)
+	s.AddUser("42", map[string]interface{}{"email": "homer@example.com"})
+	provider, err := New(s.ClientID, s.ClientSecret, "http://localhost/foo", s.DiscoveryURL())
+	a.NoError(err)
+	provider.SkipUserInfoRequest = true
+	provider.Prefetch()
+
+	idToken, err := s.IDToken("42", "")
+	a.NoError(err)
+	user, err := provider.FetchUser(&Session{AccessToken: "access", IDToken: idToken})
+	a.NoError(err)
+	a.Equal("42", user.UserID)
+	a.Equal("homer@example.com", user.Email)
+
+	// the tokens not signed by the keys of the provider are rejected
+	forger, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	claims := jwt.MapClaims{"iss": s.Issuer, "aud": s.ClientID, "sub": "1", "exp": time.Now().Add(time.Hour).Unix()}
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	forged.Header["kid"] = s.KeyID
+	signed, err := forged.SignedString(forger)
+	a.NoError(err)
+	_, err = provider.FetchUser(&Session{AccessToken: "access", IDToken: signed})
+	a.ErrorContains(err, "error verifying JWT token")
+
+	hmac, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.ClientSecret))
+	a.NoError(err)
+	_, err = provider.FetchUser(&Session{AccessToken: "access", IDToken: hmac})
+	a.ErrorContains(err, "error verifying JWT token")
+
+	// the tokens without expiry are rejected
+	delete(claims, "exp")
+	signed, err = s.Sign(claims)
+	a.NoError(err)
+	_, err = provider.FetchUser(&Session{AccessToken: "access", IDToken: signed})
+	a.ErrorContains(err, "has no expiry")
+}
+
 func openidConnectProvider() *Provider {
 	provider, _ := New(os.Getenv("OPENID_CONNECT_KEY"), os.Getenv("OPENID_CONNECT_SECRET"), "http://localhost/foo", server.URL)
 	return provider
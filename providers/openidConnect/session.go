@@ -1,6 +1,7 @@
 package openidConnect
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"strings"
@@ -29,6 +30,11 @@ func (s Session) GetAuthURL() (string, error) {
 
 // Authorize the session with the OpenID Connect provider and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	return s.AuthorizeContext(context.Background(), provider, params)
+}
+
+// AuthorizeContext is Authorize, exchanging the code with the context.
+func (s *Session) AuthorizeContext(ctx context.Context, provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
 
 	var authParams []oauth2.AuthCodeOption
@@ -45,7 +51,7 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 		authParams = append(authParams, oauth2.SetAuthURLParam("code_verifier", codeVerifier))
 	}
 
-	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"), authParams...)
+	token, err := p.config.Exchange(goth.ContextWithClient(ctx, p.Client()), params.Get("code"), authParams...)
 	if err != nil {
 		return "", err
 	}
@@ -70,7 +76,7 @@ func (s Session) Marshal() string {
 }
 
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // UnmarshalSession will unmarshal a JSON string into a session.
@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/andreimerlescu/goth"
-	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with Oura.
@@ -31,7 +30,7 @@ func (s Session) GetAuthURL() (string, error) {
 // token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	token, err := p.config.Exchange(oauth2.NoContext, params.Get("code"))
+	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
 	if err != nil {
 		return "", err
 	}
@@ -51,9 +50,10 @@ func (s Session) Marshal() string {
 	return string(j)
 }
 
-// String is equivalent to Marshal.  It returns a JSON representation of the session.
+// String returns a JSON representation of the session, with its tokens
+// redacted.
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // UnmarshalSession will unmarshal a JSON string into a session.
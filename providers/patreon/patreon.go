@@ -22,9 +22,47 @@ const (
 	// See Example_refreshToken for examples.
 	tokenURL = "https://www.patreon.com/api/oauth2/token"
 
-	profileURL = "https://www.patreon.com/api/oauth2/v2/identity?fields%5Buser%5D=created,email,full_name,image_url,vanity"
+	// profileURL includes the memberships of the user and their entitled tiers. Without the
+	// identity.memberships scope only the membership to the client's own campaign is returned.
+	profileURL = "https://www.patreon.com/api/oauth2/v2/identity" +
+		"?include=memberships,memberships.currently_entitled_tiers,memberships.campaign" +
+		"&fields%5Buser%5D=created,email,full_name,image_url,vanity" +
+		"&fields%5Bmember%5D=patron_status,currently_entitled_amount_cents,lifetime_support_cents,last_charge_status,last_charge_date,pledge_relationship_start" +
+		"&fields%5Btier%5D=title,amount_cents"
 )
 
+// Patron statuses of a Membership.
+const (
+	PatronStatusActive   = "active_patron"
+	PatronStatusDeclined = "declined_patron"
+	PatronStatusFormer   = "former_patron"
+)
+
+// Membership is the membership of a user to a campaign, as included by the identity endpoint.
+type Membership struct {
+	ID                           string
+	CampaignID                   string
+	PatronStatus                 string
+	CurrentlyEntitledAmountCents int
+	LifetimeSupportCents         int
+	LastChargeStatus             string
+	LastChargeDate               string
+	PledgeRelationshipStart      string
+	Tiers                        []Tier
+}
+
+// Active reports whether the member is currently an active patron of the campaign.
+func (m Membership) Active() bool {
+	return m.PatronStatus == PatronStatusActive
+}
+
+// Tier is a reward tier of a campaign that a member is entitled to.
+type Tier struct {
+	ID          string
+	Title       string
+	AmountCents int
+}
+
 //goland:noinspection GoUnusedConst
 const (
 	// ScopeIdentity provides read access to data about the user. See the /identity endpoint documentation for details about what data is available.
@@ -98,6 +136,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -154,10 +202,41 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 
 	err = userFromReader(bytes.NewReader(bits), &user)
+	if err != nil {
+		return user, err
+	}
+
+	memberships, err := membershipsFromReader(bytes.NewReader(bits))
+	if err != nil {
+		return user, err
+	}
+	user.RawData["memberships"] = memberships
 
 	return user, err
 }
 
+// Memberships returns the campaign memberships of a user fetched by this provider.
+func Memberships(user goth.User) []Membership {
+	memberships, _ := user.RawData["memberships"].([]Membership)
+	return memberships
+}
+
+// EntitledTo reports whether a user fetched by this provider is an active
+// patron entitled to the given tier, so content can be gated at login time.
+func EntitledTo(user goth.User, tierID string) bool {
+	for _, membership := range Memberships(user) {
+		if !membership.Active() {
+			continue
+		}
+		for _, tier := range membership.Tiers {
+			if tier.ID == tierID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
 	return true
@@ -218,3 +297,75 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	user.AvatarURL = u.Data.Attributes.ImageURL
 	return nil
 }
+
+func membershipsFromReader(r io.Reader) ([]Membership, error) {
+	type reference struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	doc := struct {
+		Included []struct {
+			ID         string `json:"id"`
+			Type       string `json:"type"`
+			Attributes struct {
+				PatronStatus                 string `json:"patron_status"`
+				CurrentlyEntitledAmountCents int    `json:"currently_entitled_amount_cents"`
+				LifetimeSupportCents         int    `json:"lifetime_support_cents"`
+				LastChargeStatus             string `json:"last_charge_status"`
+				LastChargeDate               string `json:"last_charge_date"`
+				PledgeRelationshipStart      string `json:"pledge_relationship_start"`
+				Title                        string `json:"title"`
+				AmountCents                  int    `json:"amount_cents"`
+			} `json:"attributes"`
+			Relationships struct {
+				Campaign struct {
+					Data reference `json:"data"`
+				} `json:"campaign"`
+				CurrentlyEntitledTiers struct {
+					Data []reference `json:"data"`
+				} `json:"currently_entitled_tiers"`
+			} `json:"relationships"`
+		} `json:"included"`
+	}{}
+	err := json.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	tiers := map[string]Tier{}
+	for _, resource := range doc.Included {
+		if resource.Type == "tier" {
+			tiers[resource.ID] = Tier{
+				ID:          resource.ID,
+				Title:       resource.Attributes.Title,
+				AmountCents: resource.Attributes.AmountCents,
+			}
+		}
+	}
+
+	memberships := []Membership{}
+	for _, resource := range doc.Included {
+		if resource.Type != "member" {
+			continue
+		}
+		membership := Membership{
+			ID:                           resource.ID,
+			CampaignID:                   resource.Relationships.Campaign.Data.ID,
+			PatronStatus:                 resource.Attributes.PatronStatus,
+			CurrentlyEntitledAmountCents: resource.Attributes.CurrentlyEntitledAmountCents,
+			LifetimeSupportCents:         resource.Attributes.LifetimeSupportCents,
+			LastChargeStatus:             resource.Attributes.LastChargeStatus,
+			LastChargeDate:               resource.Attributes.LastChargeDate,
+			PledgeRelationshipStart:      resource.Attributes.PledgeRelationshipStart,
+		}
+		for _, ref := range resource.Relationships.CurrentlyEntitledTiers.Data {
+			tier, ok := tiers[ref.ID]
+			if !ok {
+				tier = Tier{ID: ref.ID}
+			}
+			membership.Tiers = append(membership.Tiers, tier)
+		}
+		memberships = append(memberships, membership)
+	}
+	return memberships, nil
+}
@@ -1,6 +1,9 @@
 package patreon
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -39,6 +42,49 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "www.patreon.com/oauth2/authorize")
 }
 
+func Test_FetchUserMemberships(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer 1234567890" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		a.Equal("memberships,memberships.currently_entitled_tiers,memberships.campaign", req.URL.Query().Get("include"))
+		fmt.Fprint(res, `{
+			"data": {"id": "101", "type": "user", "attributes": {"email": "patron@example.com", "full_name": "Pat Ron", "vanity": "patron", "image_url": "https://c8.patreon.com/avatar.png"}},
+			"included": [
+				{"id": "m-1", "type": "member", "attributes": {"patron_status": "active_patron", "currently_entitled_amount_cents": 500, "last_charge_status": "Paid"},
+					"relationships": {"campaign": {"data": {"id": "c-1", "type": "campaign"}}, "currently_entitled_tiers": {"data": [{"id": "t-5", "type": "tier"}]}}},
+				{"id": "m-2", "type": "member", "attributes": {"patron_status": "former_patron"},
+					"relationships": {"campaign": {"data": {"id": "c-2", "type": "campaign"}}, "currently_entitled_tiers": {"data": [{"id": "t-9", "type": "tier"}]}}},
+				{"id": "t-5", "type": "tier", "attributes": {"title": "Supporter", "amount_cents": 500}},
+				{"id": "t-9", "type": "tier", "attributes": {"title": "Backer", "amount_cents": 900}},
+				{"id": "c-1", "type": "campaign", "attributes": {}}
+			]
+		}`)
+	}))
+	defer server.Close()
+
+	p := NewCustomisedURL("key", "secret", "/foo", authorizationURL, tokenURL, server.URL+"/api/oauth2/v2/identity?include=memberships,memberships.currently_entitled_tiers,memberships.campaign", ScopeIdentity, ScopeIdentityMemberships)
+	user, err := p.FetchUser(&Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("101", user.UserID)
+	a.Equal("Pat Ron", user.Name)
+
+	memberships := Memberships(user)
+	a.Len(memberships, 2)
+	a.Equal("c-1", memberships[0].CampaignID)
+	a.True(memberships[0].Active())
+	a.Equal(500, memberships[0].CurrentlyEntitledAmountCents)
+	a.Equal([]Tier{{ID: "t-5", Title: "Supporter", AmountCents: 500}}, memberships[0].Tiers)
+	a.False(memberships[1].Active())
+
+	a.True(EntitledTo(user, "t-5"))
+	a.False(EntitledTo(user, "t-9"))
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -50,9 +50,10 @@ func (s *Session) Marshal() string {
 	return string(j)
 }
 
-// String is equivalent to Marshal.  It returns a JSON representation of the session.
+// String returns a JSON representation of the session, with its tokens
+// redacted.
 func (s *Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // UnmarshalSession will unmarshal a JSON string into a session.
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 
 	"github.com/andreimerlescu/goth"
 	"golang.org/x/oauth2"
@@ -21,14 +22,27 @@ const (
 	envKey  string = "PAYPAL_ENV"
 
 	// Endpoints for paypal sandbox env
-	authURLSandbox         string = "https://www.sandbox.paypal.com/webapps/auth/protocol/openidconnect/v1/authorize"
-	tokenURLSandbox        string = "https://www.sandbox.paypal.com/webapps/auth/protocol/openidconnect/v1/tokenservice"
-	endpointProfileSandbox string = "https://www.sandbox.paypal.com/webapps/auth/protocol/openidconnect/v1/userinfo"
+	authURLSandbox         string = "https://www.sandbox.paypal.com/signin/authorize"
+	tokenURLSandbox        string = "https://api-m.sandbox.paypal.com/v1/oauth2/token"
+	endpointProfileSandbox string = "https://api-m.sandbox.paypal.com/v1/identity/oauth2/userinfo?schema=paypalv1.1"
 
 	// Endpoints for paypal production env
-	authURLProduction         string = "https://www.paypal.com/webapps/auth/protocol/openidconnect/v1/authorize"
-	tokenURLProduction        string = "https://www.paypal.com/webapps/auth/protocol/openidconnect/v1/tokenservice"
-	endpointProfileProduction string = "https://www.paypal.com/webapps/auth/protocol/openidconnect/v1/userinfo"
+	authURLProduction         string = "https://www.paypal.com/signin/authorize"
+	tokenURLProduction        string = "https://api-m.paypal.com/v1/oauth2/token"
+	endpointProfileProduction string = "https://api-m.paypal.com/v1/identity/oauth2/userinfo?schema=paypalv1.1"
+)
+
+// These are the scopes of Log in with PayPal. Each of them has to be enabled
+// for the app in the PayPal developer dashboard as well.
+// See https://developer.paypal.com/docs/log-in-with-paypal/integrate/reference/#scope-attributes
+const (
+	ScopeOpenID  string = "openid"
+	ScopeProfile string = "profile"
+	ScopeEmail   string = "email"
+	ScopeAddress string = "address"
+	// ScopePayPalAttributes grants access to the payer id and whether the
+	// PayPal account is verified.
+	ScopePayPalAttributes string = "https://uri.paypal.com/services/paypalattributes"
 )
 
 // Provider is the implementation of `goth.Provider` for accessing Paypal.
@@ -43,22 +57,27 @@ type Provider struct {
 }
 
 // New creates a new Paypal provider and sets up important connection details.
+// It connects to the live environment, unless the PAYPAL_ENV environment
+// variable is set to "sandbox".
 // You should always call `paypal.New` to get a new provider.  Never try to
 // create one manually.
 func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
-	paypalEnv := os.Getenv(envKey)
-
-	authURL := authURLProduction
-	tokenURL := tokenURLProduction
-	profileEndPoint := endpointProfileProduction
-
-	if paypalEnv == sandbox {
-		authURL = authURLSandbox
-		tokenURL = tokenURLSandbox
-		profileEndPoint = endpointProfileSandbox
+	if os.Getenv(envKey) == sandbox {
+		return NewSandbox(clientKey, secret, callbackURL, scopes...)
 	}
+	return NewLive(clientKey, secret, callbackURL, scopes...)
+}
+
+// NewLive is similar to New(...) but always connects to the live environment
+// of PayPal, regardless of the PAYPAL_ENV environment variable.
+func NewLive(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	return NewCustomisedURL(clientKey, secret, callbackURL, authURLProduction, tokenURLProduction, endpointProfileProduction, scopes...)
+}
 
-	return NewCustomisedURL(clientKey, secret, callbackURL, authURL, tokenURL, profileEndPoint, scopes...)
+// NewSandbox is similar to New(...) but always connects to the sandbox
+// environment of PayPal, which has separate app credentials.
+func NewSandbox(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	return NewCustomisedURL(clientKey, secret, callbackURL, authURLSandbox, tokenURLSandbox, endpointProfileSandbox, scopes...)
 }
 
 // NewCustomisedURL is similar to New(...) but can be used to set custom URLs to connect to
@@ -84,6 +103,17 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -113,7 +143,23 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	response, err := p.Client().Get(p.profileURL + "?schema=openid&access_token=" + url.QueryEscape(sess.AccessToken))
+	profileURL, err := url.Parse(p.profileURL)
+	if err != nil {
+		return user, err
+	}
+	// PayPal requires the schema of the returned profile to be chosen
+	if query := profileURL.Query(); query.Get("schema") == "" {
+		query.Set("schema", "paypalv1.1")
+		profileURL.RawQuery = query.Encode()
+	}
+
+	req, err := http.NewRequest("GET", profileURL.String(), nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	req.Header.Set("Accept", "application/json")
+	response, err := p.Client().Do(req)
 	if err != nil {
 		if response != nil {
 			response.Body.Close()
@@ -141,6 +187,28 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	return user, err
 }
 
+// VerifiedAccount reports whether the PayPal account of a user fetched by this
+// provider is verified. PayPal only returns it when the paypalattributes scope
+// was granted.
+func VerifiedAccount(user goth.User) bool {
+	switch verified := user.RawData["verified_account"].(type) {
+	case bool:
+		return verified
+	case string:
+		// the paypalv1.1 schema returns the claim as a string
+		v, _ := strconv.ParseBool(verified)
+		return v
+	}
+	return false
+}
+
+// PayerID returns the payer ID of the PayPal account of a user fetched by this
+// provider. PayPal only returns it when the paypalattributes scope was granted.
+func PayerID(user goth.User) string {
+	payerID, _ := user.RawData["payer_id"].(string)
+	return payerID
+}
+
 func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *oauth2.Config {
 	c := &oauth2.Config{
 		ClientID:     provider.ClientKey,
@@ -149,6 +217,8 @@ func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *o
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  authURL,
 			TokenURL: tokenURL,
+			// PayPal expects the client credentials in a basic Authorization header
+			AuthStyle: oauth2.AuthStyleInHeader,
 		},
 		Scopes: []string{},
 	}
@@ -158,26 +228,40 @@ func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *o
 			c.Scopes = append(c.Scopes, scope)
 		}
 	} else {
-		c.Scopes = append(c.Scopes, "profile", "email")
+		c.Scopes = append(c.Scopes, ScopeOpenID, ScopeProfile, ScopeEmail)
 	}
 	return c
 }
 
 func userFromReader(r io.Reader, user *goth.User) error {
 	u := struct {
-		Name    string `json:"name"`
-		Address struct {
+		Name      string `json:"name"`
+		FirstName string `json:"given_name"`
+		LastName  string `json:"family_name"`
+		Address   struct {
 			Locality string `json:"locality"`
 		} `json:"address"`
-		Email string `json:"email"`
-		ID    string `json:"user_id"`
+		Email  string `json:"email"`
+		Emails []struct {
+			Value   string `json:"value"`
+			Primary bool   `json:"primary"`
+		} `json:"emails"`
+		ID string `json:"user_id"`
 	}{}
 	err := json.NewDecoder(r).Decode(&u)
 	if err != nil {
 		return err
 	}
 	user.Email = u.Email
+	// the paypalv1.1 schema returns a list of email addresses instead
+	for _, email := range u.Emails {
+		if user.Email == "" || email.Primary {
+			user.Email = email.Value
+		}
+	}
 	user.Name = u.Name
+	user.FirstName = u.FirstName
+	user.LastName = u.LastName
 	user.UserID = u.ID
 	user.Location = u.Address.Locality
 	return nil
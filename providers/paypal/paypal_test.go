@@ -1,6 +1,9 @@
 package paypal_test
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -42,7 +45,49 @@ func Test_BeginAuth(t *testing.T) {
 	session, err := p.BeginAuth("test_state")
 	s := session.(*paypal.Session)
 	a.NoError(err)
-	a.Contains(s.AuthURL, "paypal.com/webapps/auth/protocol/openidconnect/v1/authorize")
+	a.Contains(s.AuthURL, "paypal.com/signin/authorize")
+	a.Contains(s.AuthURL, "scope=openid+profile+email")
+}
+
+func Test_NewSandbox(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	session, err := paypal.NewSandbox(os.Getenv("PAYPAL_KEY"), os.Getenv("PAYPAL_SECRET"), "/foo").BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*paypal.Session).AuthURL, "https://www.sandbox.paypal.com/signin/authorize")
+
+	session, err = paypal.NewLive(os.Getenv("PAYPAL_KEY"), os.Getenv("PAYPAL_SECRET"), "/foo").BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*paypal.Session).AuthURL, "https://www.paypal.com/signin/authorize")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/identity/oauth2/userinfo" || req.URL.Query().Get("schema") != "paypalv1.1" || req.Header.Get("Authorization") != "Bearer 1234567890" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(res, `{"user_id":"https://www.paypal.com/webapps/auth/identity/user/mWq6_1sU85v5EG9yHdPxJRrhGHrnMJ-1PQKtX6pcsmA","name":"identity test","payer_id":"WDJJHEBZ4X2LY","address":{"street_address":"1 Main St","locality":"San Jose","region":"CA","postal_code":"95131","country":"US"},"verified_account":"true","emails":[{"value":"other@example.com","primary":false,"confirmed":true},{"value":"user1@example.com","primary":true,"confirmed":true}]}`)
+	}))
+	defer server.Close()
+
+	p := paypal.NewCustomisedURL(os.Getenv("PAYPAL_KEY"), os.Getenv("PAYPAL_SECRET"), "/foo", "http://authURL", "http://tokenURL", server.URL+"/v1/identity/oauth2/userinfo")
+	user, err := p.FetchUser(&paypal.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("https://www.paypal.com/webapps/auth/identity/user/mWq6_1sU85v5EG9yHdPxJRrhGHrnMJ-1PQKtX6pcsmA", user.UserID)
+	a.Equal("identity test", user.Name)
+	a.Equal("user1@example.com", user.Email)
+	a.Equal("San Jose", user.Location)
+	a.True(paypal.VerifiedAccount(user))
+	a.Equal("WDJJHEBZ4X2LY", paypal.PayerID(user))
+
+	a.True(paypal.VerifiedAccount(goth.User{RawData: map[string]interface{}{"verified_account": true}}))
+	a.False(paypal.VerifiedAccount(goth.User{RawData: map[string]interface{}{"verified_account": "false"}}))
+	a.False(paypal.VerifiedAccount(goth.User{}))
 }
 
 func Test_SessionFromJSON(t *testing.T) {
@@ -50,11 +95,11 @@ func Test_SessionFromJSON(t *testing.T) {
 	a := assert.New(t)
 
 	p := provider()
-	session, err := p.UnmarshalSession(`{"AuthURL":"https://www.paypal.com/webapps/auth/protocol/openidconnect/v1/authorize","AccessToken":"1234567890"}`)
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://www.paypal.com/signin/authorize","AccessToken":"1234567890"}`)
 	a.NoError(err)
 
 	s := session.(*paypal.Session)
-	a.Equal(s.AuthURL, "https://www.paypal.com/webapps/auth/protocol/openidconnect/v1/authorize")
+	a.Equal(s.AuthURL, "https://www.paypal.com/signin/authorize")
 	a.Equal(s.AccessToken, "1234567890")
 }
 
@@ -0,0 +1,303 @@
+// Package plex implements the PIN based authentication of Plex for authenticating users
+// through their plex.tv account. Plex doesn't support OAuth, instead the app creates a
+// PIN, the user claims it by signing in to Plex, and the app polls the PIN for the token.
+package plex
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// URLs and endpoints
+const (
+	authURL         string = "https://app.plex.tv/auth"
+	endpointPins    string = "https://plex.tv/api/v2/pins"
+	endpointProfile string = "https://plex.tv/api/v2/user"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultPollTimeout  = 10 * time.Second
+)
+
+// ErrPINNotClaimed is returned when the PIN of a session wasn't claimed by the
+// user before polling it timed out.
+var ErrPINNotClaimed = errors.New("plex PIN was not claimed")
+
+// ErrPINExpired is returned when the PIN of a session expired before it was
+// claimed. A new authentication has to be started.
+var ErrPINExpired = errors.New("plex PIN expired")
+
+// Provider is the implementation of `goth.Provider` for accessing Plex.
+type Provider struct {
+	// ClientKey is the X-Plex-Client-Identifier, a unique and stable identifier
+	// of the app installation.
+	ClientKey string
+	// Product is the X-Plex-Product, the name of the app shown to the user.
+	Product      string
+	CallbackURL  string
+	HTTPClient   *http.Client
+	providerName string
+	pollInterval time.Duration
+	pollTimeout  time.Duration
+}
+
+// New creates a new Plex provider and sets up important connection details.
+// You should always call `plex.New` to get a new provider.  Never try to
+// create one manually.
+func New(clientIdentifier, product, callbackURL string) *Provider {
+	return &Provider{
+		ClientKey:    clientIdentifier,
+		Product:      product,
+		CallbackURL:  callbackURL,
+		providerName: "plex",
+		pollInterval: defaultPollInterval,
+		pollTimeout:  defaultPollTimeout,
+	}
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the plex package.
+func (p *Provider) Debug(debug bool) {}
+
+// SetPolling sets how often and how long Authorize polls the PIN until it is
+// claimed. Plex may redirect the user back before the claim is visible, so
+// it is polled for 10 seconds by default.
+func (p *Provider) SetPolling(interval, timeout time.Duration) {
+	p.pollInterval = interval
+	p.pollTimeout = timeout
+}
+
+type pin struct {
+	ID        int64     `json:"id"`
+	Code      string    `json:"code"`
+	AuthToken string    `json:"authToken"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// BeginAuth creates a new PIN at Plex and returns the URL the user claims it at.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	req, err := http.NewRequest("POST", endpointPins+"?strong=true", nil)
+	if err != nil {
+		return nil, err
+	}
+	p.setHeaders(req)
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to create a PIN", p.providerName, resp.StatusCode)
+	}
+
+	var pin pin
+	if err := json.NewDecoder(resp.Body).Decode(&pin); err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		AuthURL:      p.authURL(pin.Code, state),
+		PinID:        pin.ID,
+		PinCode:      pin.Code,
+		PinExpiresAt: pin.ExpiresAt,
+	}, nil
+}
+
+// authURL builds the URL of the Plex sign in page. Its parameters are part of
+// the fragment, the state is passed through the forward URL instead.
+func (p *Provider) authURL(code, state string) string {
+	params := url.Values{}
+	params.Set("clientID", p.ClientKey)
+	params.Set("code", code)
+	params.Set("context[device][product]", p.Product)
+	if p.CallbackURL != "" {
+		forwardURL := p.CallbackURL
+		if state != "" {
+			forwardURL = appendQuery(forwardURL, "state", state)
+		}
+		params.Set("forwardUrl", forwardURL)
+	}
+	return authURL + "#?" + params.Encode()
+}
+
+func appendQuery(rawURL, key, value string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	query.Set(key, value)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// CheckPIN asks Plex once whether the PIN of the session was claimed and
+// returns its auth token, which is empty while the PIN is unclaimed.
+func (p *Provider) CheckPIN(session *Session) (string, error) {
+	req, err := http.NewRequest("GET", endpointPins+"/"+strconv.FormatInt(session.PinID, 10)+"?code="+url.QueryEscape(session.PinCode), nil)
+	if err != nil {
+		return "", err
+	}
+	p.setHeaders(req)
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		// Plex deletes PINs once they expired
+		return "", ErrPINExpired
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s responded with a %d trying to check a PIN", p.providerName, resp.StatusCode)
+	}
+
+	var pin pin
+	if err := json.NewDecoder(resp.Body).Decode(&pin); err != nil {
+		return "", err
+	}
+	return pin.AuthToken, nil
+}
+
+// WaitForPIN polls the PIN of the session until it is claimed, it expired, or
+// the timeout elapsed, and returns its auth token. Apps which can't redirect
+// the user back, e.g. on TVs, use it with a timeout of several minutes.
+func (p *Provider) WaitForPIN(session *Session, interval, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		token, err := p.CheckPIN(session)
+		if err != nil || token != "" {
+			return token, err
+		}
+		if !session.PinExpiresAt.IsZero() && time.Now().After(session.PinExpiresAt) {
+			return "", ErrPINExpired
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return "", ErrPINNotClaimed
+		}
+		time.Sleep(interval)
+	}
+}
+
+// FetchUser will go to Plex and access basic information about the account.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken: sess.AccessToken,
+		Provider:    p.Name(),
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	req, err := http.NewRequest("GET", endpointProfile, nil)
+	if err != nil {
+		return user, err
+	}
+	p.setHeaders(req)
+	req.Header.Set("X-Plex-Token", sess.AccessToken)
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+	}
+
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+
+	err = userFromReader(bytes.NewReader(bits), &user)
+	return user, err
+}
+
+func (p *Provider) setHeaders(req *http.Request) {
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Plex-Client-Identifier", p.ClientKey)
+	req.Header.Set("X-Plex-Product", p.Product)
+}
+
+func userFromReader(r io.Reader, user *goth.User) error {
+	u := struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+		Title    string `json:"title"`
+		Email    string `json:"email"`
+		Thumb    string `json:"thumb"`
+	}{}
+
+	err := json.NewDecoder(r).Decode(&u)
+	if err != nil {
+		return err
+	}
+
+	user.UserID = strconv.FormatInt(u.ID, 10)
+	user.NickName = u.Username
+	user.Name = u.Title
+	user.Email = u.Email
+	user.AvatarURL = u.Thumb
+	return nil
+}
+
+// RefreshToken refresh token is not provided by Plex, its auth tokens don't
+// expire.
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by plex")
+}
+
+// RefreshTokenAvailable refresh token is not provided by Plex
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
@@ -0,0 +1,163 @@
+package plex_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/plex"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.ClientKey, "goth-client-identifier")
+	a.Equal(p.Product, "Goth")
+	a.Equal(p.CallbackURL, "http://localhost:3000/auth/plex/callback")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+}
+
+func Test_AuthFlow(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var checks int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Plex-Client-Identifier") != "goth-client-identifier" || req.Header.Get("X-Plex-Product") != "Goth" {
+			res.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch {
+		case req.Method == "POST" && req.URL.Path == "/api/v2/pins":
+			res.WriteHeader(http.StatusCreated)
+			fmt.Fprint(res, `{"id":564964751,"code":"3ybnh4wuybyovyqfjfocq3ogi","expiresIn":1800,"expiresAt":"2099-01-01T00:00:00Z","authToken":null}`)
+		case req.URL.Path == "/api/v2/pins/564964751":
+			if req.URL.Query().Get("code") != "3ybnh4wuybyovyqfjfocq3ogi" {
+				res.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			// the PIN is claimed on the second check
+			if atomic.AddInt32(&checks, 1) < 2 {
+				fmt.Fprint(res, `{"id":564964751,"code":"3ybnh4wuybyovyqfjfocq3ogi","authToken":null}`)
+				return
+			}
+			fmt.Fprint(res, `{"id":564964751,"code":"3ybnh4wuybyovyqfjfocq3ogi","authToken":"plex-token"}`)
+		case req.URL.Path == "/api/v2/user":
+			if req.Header.Get("X-Plex-Token") != "plex-token" {
+				res.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(res, `{"id":12345678,"uuid":"a1b2c3d4e5f6","username":"janedoe","title":"Jane Doe","email":"jane@example.com","thumb":"https://plex.tv/users/a1b2c3d4e5f6/avatar"}`)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := testProvider(server)
+	p.SetPolling(10*time.Millisecond, time.Second)
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*plex.Session)
+	a.Equal(int64(564964751), s.PinID)
+	a.Equal("3ybnh4wuybyovyqfjfocq3ogi", s.PinCode)
+	a.True(strings.HasPrefix(s.AuthURL, "https://app.plex.tv/auth#?"))
+
+	fragment, err := url.ParseQuery(strings.SplitN(s.AuthURL, "#?", 2)[1])
+	a.NoError(err)
+	a.Equal("goth-client-identifier", fragment.Get("clientID"))
+	a.Equal("3ybnh4wuybyovyqfjfocq3ogi", fragment.Get("code"))
+	a.Equal("Goth", fragment.Get("context[device][product]"))
+	a.Equal("http://localhost:3000/auth/plex/callback?state=test_state", fragment.Get("forwardUrl"))
+
+	token, err := s.Authorize(p, url.Values{})
+	a.NoError(err)
+	a.Equal("plex-token", token)
+	a.Equal(int32(2), atomic.LoadInt32(&checks))
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("12345678", user.UserID)
+	a.Equal("janedoe", user.NickName)
+	a.Equal("Jane Doe", user.Name)
+	a.Equal("jane@example.com", user.Email)
+	a.Equal("https://plex.tv/users/a1b2c3d4e5f6/avatar", user.AvatarURL)
+	a.Equal("a1b2c3d4e5f6", user.RawData["uuid"])
+}
+
+func Test_AuthorizeUnclaimedPIN(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v2/pins/1":
+			fmt.Fprint(res, `{"id":1,"code":"unclaimed","authToken":null}`)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := testProvider(server)
+	p.SetPolling(10*time.Millisecond, 50*time.Millisecond)
+
+	_, err := (&plex.Session{PinID: 1, PinCode: "unclaimed"}).Authorize(p, url.Values{})
+	a.Equal(plex.ErrPINNotClaimed, err)
+
+	_, err = (&plex.Session{PinID: 2, PinCode: "expired"}).Authorize(p, url.Values{})
+	a.Equal(plex.ErrPINExpired, err)
+
+	_, err = (&plex.Session{}).Authorize(p, url.Values{})
+	a.Error(err)
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://app.plex.tv/auth#?code=abc","AccessToken":"1234567890","PinID":42,"PinCode":"abc"}`)
+	a.NoError(err)
+
+	s := session.(*plex.Session)
+	a.Equal(s.AuthURL, "https://app.plex.tv/auth#?code=abc")
+	a.Equal(s.AccessToken, "1234567890")
+	a.Equal(s.PinID, int64(42))
+	a.Equal(s.PinCode, "abc")
+}
+
+func provider() *plex.Provider {
+	return plex.New("goth-client-identifier", "Goth", "http://localhost:3000/auth/plex/callback")
+}
+
+func testProvider(server *httptest.Server) *plex.Provider {
+	p := provider()
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	return p
+}
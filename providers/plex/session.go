@@ -0,0 +1,63 @@
+package plex
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Session stores data during the auth process with Plex.
+type Session struct {
+	AuthURL      string
+	AccessToken  string
+	PinID        int64
+	PinCode      string
+	PinExpiresAt time.Time
+}
+
+var _ goth.Session = &Session{}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` function on the Plex provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize polls the PIN of the session until the user claimed it, and
+// returns the auth token of the Plex account to be stored for future use.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	if s.PinID == 0 {
+		return "", errors.New("plex session has no PIN to authorize")
+	}
+
+	token, err := p.WaitForPIN(s, p.pollInterval, p.pollTimeout)
+	if err != nil {
+		return "", err
+	}
+
+	s.AccessToken = token
+	return token, nil
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return goth.RedactJSON(s.Marshal())
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	s := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
+	return s, err
+}
@@ -12,15 +12,36 @@ import (
 
 const (
 	authURL = "https://www.reddit.com/api/v1/authorize"
+
+	// TokenURL is the token endpoint to pass to New.
+	TokenURL = "https://www.reddit.com/api/v1/access_token"
+	// UserURL is the endpoint of the authenticated user to pass to New. It
+	// requires the identity scope.
+	UserURL = "https://oauth.reddit.com/api/v1/me"
+
+	// ScopeIdentity is needed to fetch the user.
+	ScopeIdentity = "identity"
+)
+
+// Durations of the access that can be passed to New. Reddit only issues a
+// refresh token for permanent access; temporary access ends after an hour.
+const (
+	DurationTemporary = "temporary"
+	DurationPermanent = "permanent"
 )
 
+// defaultUserAgent is sent until SetUserAgent is called. Reddit throttles or
+// blocks generic user agents, so every application should set its own.
+const defaultUserAgent = "go:github.com/andreimerlescu/goth:v1 (goth reddit provider)"
+
 type Provider struct {
 	providerName string
 	duration     string
 	config       oauth2.Config
 	client       http.Client
 	// TODO: userURL should be a constant
-	userURL string
+	userURL   string
+	userAgent string
 }
 
 func New(clientID string, clientSecret string, redirectURI string, duration string, tokenEndpoint string, userURL string, scopes ...string) Provider {
@@ -30,10 +51,11 @@ func New(clientID string, clientSecret string, redirectURI string, duration stri
 		config: oauth2.Config{
 			ClientID:     clientID,
 			ClientSecret: clientSecret,
+			// reddit only accepts the client credentials with basic auth
 			Endpoint: oauth2.Endpoint{
 				AuthURL:   authURL,
 				TokenURL:  tokenEndpoint,
-				AuthStyle: 0,
+				AuthStyle: oauth2.AuthStyleInHeader,
 			},
 			RedirectURL: redirectURI,
 			Scopes:      scopes,
@@ -51,6 +73,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 func (p *Provider) UnmarshalSession(s string) (goth.Session, error) {
 	session := &Session{}
 	err := json.Unmarshal([]byte(s), session)
@@ -63,12 +95,40 @@ func (p *Provider) UnmarshalSession(s string) (goth.Session, error) {
 
 func (p *Provider) Debug(b bool) {}
 
+// SetUserAgent sets the User-Agent sent with every request to reddit. Reddit
+// requires a unique and descriptive one, in the form
+// `<platform>:<app ID>:<version string> (by /u/<reddit username>)`.
+// See https://github.com/reddit-archive/reddit/wiki/API#rules
+func (p *Provider) SetUserAgent(userAgent string) {
+	p.userAgent = userAgent
+}
+
+// Client returns an HTTP client that sends the User-Agent with every request.
+func (p *Provider) Client() *http.Client {
+	userAgent := p.userAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	client := p.client
+	client.Transport = &userAgentTransport{userAgent: userAgent, base: p.client.Transport}
+	return &client
+}
+
+// RefreshToken get new access token based on the refresh token. It only works
+// for permanent access.
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	return nil, nil
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
+	newToken, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return newToken, err
 }
 
+// RefreshTokenAvailable is only true when permanent access was requested.
 func (p *Provider) RefreshTokenAvailable() bool {
-	return true
+	return p.duration == DurationPermanent
 }
 
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
@@ -91,7 +151,7 @@ func (p *Provider) FetchUser(s goth.Session) (goth.User, error) {
 	bearer := "Bearer " + session.AccessToken
 	request.Header.Add("Authorization", bearer)
 
-	res, err := p.client.Do(request)
+	res, err := p.Client().Do(request)
 	if err != nil {
 		return goth.User{}, err
 	}
@@ -134,3 +194,18 @@ func (p *Provider) FetchUser(s goth.Session) (goth.User, error) {
 
 	return gothUser, nil
 }
+
+type userAgentTransport struct {
+	userAgent string
+	base      http.RoundTripper
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = goth.DefaultTransport
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return base.RoundTrip(req)
+}
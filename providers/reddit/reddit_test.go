@@ -6,7 +6,9 @@ import (
 	"golang.org/x/oauth2"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -28,7 +30,7 @@ func TestProvider(t *testing.T) {
 				Endpoint: oauth2.Endpoint{
 					AuthURL:   authURL,
 					TokenURL:  "example.com",
-					AuthStyle: 0,
+					AuthStyle: oauth2.AuthStyleInHeader,
 				},
 				RedirectURL: "redirect uri",
 				Scopes:      []string{"scope1", "scope2", "scope 3"},
@@ -85,4 +87,61 @@ func TestProvider(t *testing.T) {
 			t.Errorf("\033[31;1;4mgot\033[0m %+v, \n\t\t \033[31;1;4mwant\033[0m %+v", got, want)
 		}
 	})
+
+	t.Run("request permanent access", func(t *testing.T) {
+		p := New("client id", "client secret", "redirect uri", DurationPermanent, TokenURL, UserURL, ScopeIdentity)
+		s, err := p.BeginAuth("state")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		authURL, _ := s.GetAuthURL()
+		if !strings.Contains(authURL, "duration=permanent") {
+			t.Errorf("expected the auth URL %s to request permanent access", authURL)
+		}
+		if !p.RefreshTokenAvailable() {
+			t.Error("expected a refresh token to be available for permanent access")
+		}
+	})
+
+	t.Run("exchange the code with basic auth and the user agent", func(t *testing.T) {
+		redditServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if request.Header.Get("User-Agent") != "web:goth-test:v1.0 (by /u/goth)" {
+				writer.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			writer.Header().Add("Content-Type", "application/json")
+			if request.URL.Path == "/api/v1/access_token" {
+				if id, secret, ok := request.BasicAuth(); !ok || id != "client-id" || secret != "client-secret" {
+					writer.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				writer.Write([]byte(`{"access_token":"i am a token","token_type":"bearer","expires_in":3600,"refresh_token":"your refresh token","scope":"identity"}`))
+				return
+			}
+			b, _ := json.Marshal(response)
+			writer.Write(b)
+		}))
+		defer redditServer.Close()
+
+		p := New("client-id", "client-secret", "redirect uri", DurationPermanent, redditServer.URL+"/api/v1/access_token", redditServer.URL+"/api/v1/me", ScopeIdentity)
+		p.SetUserAgent("web:goth-test:v1.0 (by /u/goth)")
+
+		s := &Session{}
+		token, err := s.Authorize(&p, url.Values{"code": {"code"}})
+		if err != nil {
+			t.Fatalf("did not expect an error: %s", err)
+		}
+		if token != "i am a token" || s.RefreshToken != "your refresh token" {
+			t.Errorf("unexpected session %+v", s)
+		}
+
+		user, err := p.FetchUser(s)
+		if err != nil {
+			t.Fatalf("did not expect an error: %s", err)
+		}
+		if user.UserID != "invader21" {
+			t.Errorf("unexpected user %+v", user)
+		}
+	})
 }
@@ -1,11 +1,9 @@
 package reddit
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"github.com/andreimerlescu/goth"
-	"golang.org/x/oauth2"
 	"time"
 )
 
@@ -28,7 +26,7 @@ func (s *Session) Marshal() string {
 
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	t, err := p.config.Exchange(context.WithValue(context.Background(), oauth2.HTTPClient, p.client), params.Get("code"))
+	t, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,221 @@
+// Package roblox implements the OAuth2 protocol for authenticating users through Roblox,
+// which requires the PKCE flow for every client.
+// This package can be used as a reference implementation of an OAuth2 provider for Goth.
+package roblox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// URLs and endpoints
+const (
+	authURL         string = "https://apis.roblox.com/oauth/v1/authorize"
+	tokenURL        string = "https://apis.roblox.com/oauth/v1/token"
+	endpointProfile string = "https://apis.roblox.com/oauth/v1/userinfo"
+)
+
+// These are the scopes to sign users in. The scopes to access resources on
+// behalf of the user, such as "asset:read" or "group:read", are configured
+// for each app and can be passed to New as well.
+// See https://create.roblox.com/docs/cloud/reference/oauth2
+const (
+	ScopeOpenID  string = "openid"
+	ScopeProfile string = "profile"
+)
+
+// Provider is the implementation of `goth.Provider` for accessing Roblox.
+type Provider struct {
+	ClientKey    string
+	Secret       string
+	CallbackURL  string
+	HTTPClient   *http.Client
+	config       *oauth2.Config
+	providerName string
+}
+
+// New creates a new Roblox provider and sets up important connection details.
+// The secret can be left empty for public clients.
+// You should always call `roblox.New` to get a new provider.  Never try to
+// create one manually.
+func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "roblox",
+	}
+	p.config = newConfig(p, scopes)
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the roblox package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks Roblox for an authentication end-point. Roblox requires
+// PKCE, so the session always carries a code verifier.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	verifier, err := goth.GenerateVerifier()
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		AuthURL:      p.config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)),
+		CodeVerifier: verifier,
+	}, nil
+}
+
+// FetchUser will go to Roblox and access basic information about the user.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		Provider:     p.Name(),
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+		IDToken:      sess.IDToken,
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	req, err := http.NewRequest("GET", endpointProfile, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+	}
+
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+
+	err = userFromReader(bytes.NewReader(bits), &user)
+	return user, err
+}
+
+// ProfileURL returns the URL of the Roblox profile page of a user fetched by
+// this provider. It is only returned by Roblox when the profile scope was granted.
+func ProfileURL(user goth.User) string {
+	profile, _ := user.RawData["profile"].(string)
+	return profile
+}
+
+func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   authURL,
+			TokenURL:  tokenURL,
+			AuthStyle: oauth2.AuthStyleInHeader,
+		},
+		Scopes: []string{},
+	}
+
+	if provider.Secret == "" {
+		// public clients only identify themselves with the client id
+		c.Endpoint.AuthStyle = oauth2.AuthStyleInParams
+	}
+
+	if len(scopes) > 0 {
+		c.Scopes = append(c.Scopes, scopes...)
+	} else {
+		c.Scopes = append(c.Scopes, ScopeOpenID, ScopeProfile)
+	}
+	return c
+}
+
+func userFromReader(r io.Reader, user *goth.User) error {
+	u := struct {
+		ID                string `json:"sub"`
+		Name              string `json:"name"`
+		Nickname          string `json:"nickname"`
+		PreferredUsername string `json:"preferred_username"`
+		Picture           string `json:"picture"`
+	}{}
+
+	err := json.NewDecoder(r).Decode(&u)
+	if err != nil {
+		return err
+	}
+
+	user.UserID = u.ID
+	// name is the username, nickname the display name shown in experiences
+	user.NickName = u.PreferredUsername
+	user.Name = u.Nickname
+	if user.Name == "" {
+		user.Name = u.Name
+	}
+	user.AvatarURL = u.Picture
+	return nil
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token. Roblox rotates
+// refresh tokens, store the refresh token that is returned.
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
+	newToken, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return newToken, err
+}
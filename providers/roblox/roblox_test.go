@@ -0,0 +1,114 @@
+package roblox_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/roblox"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.ClientKey, os.Getenv("ROBLOX_KEY"))
+	a.Equal(p.Secret, os.Getenv("ROBLOX_SECRET"))
+	a.Equal(p.CallbackURL, "/foo")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	session, err := p.BeginAuth("test_state")
+	s := session.(*roblox.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "https://apis.roblox.com/oauth/v1/authorize")
+	a.Contains(s.AuthURL, "scope=openid+profile")
+	a.Contains(s.AuthURL, "code_challenge_method=S256")
+	a.NotEmpty(s.CodeVerifier)
+}
+
+func Test_AuthorizeAndFetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/oauth/v1/token":
+			id, secret, ok := req.BasicAuth()
+			if !ok || id != "client-id" || secret != "client-secret" || req.FormValue("code_verifier") != "verifier" {
+				res.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			res.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(res, `{"access_token":"access","refresh_token":"refresh","token_type":"Bearer","expires_in":899,"id_token":"id-token","scope":"openid profile"}`)
+		case "/oauth/v1/userinfo":
+			if req.Header.Get("Authorization") != "Bearer access" {
+				res.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(res, `{"sub":"1516563360","name":"exampleuser","nickname":"Example User","preferred_username":"exampleuser","created_at":1584682495,"profile":"https://www.roblox.com/users/1516563360/profile","picture":"https://tr.rbxcdn.com/avatar.png"}`)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := roblox.New("client-id", "client-secret", "/foo")
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	s := &roblox.Session{CodeVerifier: "verifier"}
+	_, err := s.Authorize(p, url.Values{"code": {"auth-code"}})
+	a.NoError(err)
+	a.Equal("refresh", s.RefreshToken)
+	a.Equal("id-token", s.IDToken)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("1516563360", user.UserID)
+	a.Equal("exampleuser", user.NickName)
+	a.Equal("Example User", user.Name)
+	a.Equal("https://tr.rbxcdn.com/avatar.png", user.AvatarURL)
+	a.Equal("https://www.roblox.com/users/1516563360/profile", roblox.ProfileURL(user))
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://apis.roblox.com/oauth/v1/authorize","AccessToken":"1234567890"}`)
+	a.NoError(err)
+
+	s := session.(*roblox.Session)
+	a.Equal(s.AuthURL, "https://apis.roblox.com/oauth/v1/authorize")
+	a.Equal(s.AccessToken, "1234567890")
+}
+
+func provider() *roblox.Provider {
+	return roblox.New(os.Getenv("ROBLOX_KEY"), os.Getenv("ROBLOX_SECRET"), "/foo")
+}
@@ -4,13 +4,17 @@ package salesforce
 
 import (
 	"bytes"
+	"crypto"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/jwtbearer"
 	"golang.org/x/oauth2"
 )
 
@@ -28,6 +32,15 @@ var (
 	// endpointProfile    string = "https://api.salesforce.com/2.0/users/me"
 )
 
+// Login domains for Salesforce production and sandbox orgs.
+const (
+	LoginDomain   = "https://login.salesforce.com"
+	SandboxDomain = "https://test.salesforce.com"
+
+	authPath  = "/services/oauth2/authorize"
+	tokenPath = "/services/oauth2/token"
+)
+
 // Provider is the implementation of `goth.Provider` for accessing Salesforce.
 type Provider struct {
 	ClientKey    string
@@ -48,7 +61,31 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 		CallbackURL:  callbackURL,
 		providerName: "salesforce",
 	}
-	p.config = newConfig(p, scopes)
+	p.config = newConfig(p, AuthURL, TokenURL, scopes)
+	return p
+}
+
+// NewSandbox is similar to New(...) but authenticates users against
+// Salesforce sandbox orgs through test.salesforce.com.
+func NewSandbox(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	return NewCustomDomain(clientKey, secret, callbackURL, SandboxDomain, scopes...)
+}
+
+// NewCustomDomain is similar to New(...) but authenticates users against the
+// given login domain, such as a My Domain URL ("https://acme.my.salesforce.com")
+// or an Experience Cloud site.
+func NewCustomDomain(clientKey, secret, callbackURL, domainURL string, scopes ...string) *Provider {
+	domainURL = strings.TrimSuffix(domainURL, "/")
+	if !strings.Contains(domainURL, "://") {
+		domainURL = "https://" + domainURL
+	}
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "salesforce",
+	}
+	p.config = newConfig(p, domainURL+authPath, domainURL+tokenPath, scopes)
 	return p
 }
 
@@ -62,6 +99,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -90,13 +137,17 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	url, err := url.Parse(s.ID)
+	// the identity URL is returned alongside the tokens and points at the
+	// instance the user lives on, which is the only place to fetch them from
+	identityURL, err := url.Parse(s.ID)
 	if err != nil {
 		return user, err
 	}
+	if identityURL.Scheme != "https" || identityURL.Host == "" {
+		return user, fmt.Errorf("%s cannot get user information without an identity URL", p.providerName)
+	}
 
-	// creating dynamic url to retrieve user information
-	userURL := url.Scheme + "://" + url.Host + "/" + url.Path
+	userURL := identityURL.Scheme + "://" + identityURL.Host + identityURL.Path
 	req, err := http.NewRequest("GET", userURL, nil)
 	if err != nil {
 		return user, err
@@ -116,14 +167,14 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	return user, err
 }
 
-func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *oauth2.Config {
 	c := &oauth2.Config{
 		ClientID:     provider.ClientKey,
 		ClientSecret: provider.Secret,
 		RedirectURL:  provider.CallbackURL,
 		Endpoint: oauth2.Endpoint{
-			AuthURL:  AuthURL,
-			TokenURL: TokenURL,
+			AuthURL:  authURL,
+			TokenURL: tokenURL,
 		},
 		Scopes: []string{},
 	}
@@ -154,10 +205,14 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	u := struct {
 		Name      string `json:"display_name"`
 		NickName  string `json:"nick_name"`
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
 		Location  string `json:"addr_country"`
 		Email     string `json:"email"`
-		AvatarURL string `json:"photos.picture"`
-		ID        string `json:"user_id"`
+		Photos    struct {
+			Picture string `json:"picture"`
+		} `json:"photos"`
+		ID string `json:"user_id"`
 	}{}
 
 	err = json.Unmarshal(buf.Bytes(), &u)
@@ -166,7 +221,10 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	}
 	user.Email = u.Email
 	user.Name = u.Name
-	user.NickName = u.Name
+	user.NickName = u.NickName
+	user.FirstName = u.FirstName
+	user.LastName = u.LastName
+	user.AvatarURL = u.Photos.Picture
 	user.UserID = u.ID
 	user.Location = u.Location
 	user.RawData = rawData
@@ -174,6 +232,12 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	return nil
 }
 
+// OrganizationID returns the ID of the Salesforce org a user fetched by this provider belongs to.
+func OrganizationID(user goth.User) string {
+	organizationID, _ := user.RawData["organization_id"].(string)
+	return organizationID
+}
+
 // RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
 	return true
@@ -189,3 +253,26 @@ func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
 	}
 	return newToken, err
 }
+
+// Assertion returns the assertion of the JWT bearer flow for the user with the
+// username, signed with the private key of the certificate uploaded to the
+// connected app. The user must be pre-authorized for the app.
+func (p *Provider) Assertion(username string, key crypto.Signer) *jwtbearer.Assertion {
+	audience := p.config.Endpoint.TokenURL
+	if u, err := url.Parse(audience); err == nil {
+		audience = u.Scheme + "://" + u.Host
+	}
+	return &jwtbearer.Assertion{
+		Issuer:   p.ClientKey,
+		Subject:  username,
+		Audience: audience,
+		Lifetime: 3 * time.Minute,
+		Key:      key,
+	}
+}
+
+// TokenFromAssertion exchanges the assertion for an access token. The identity
+// URL and instance URL of the user are in the "id" and "instance_url" extras.
+func (p *Provider) TokenFromAssertion(assertion string) (*oauth2.Token, error) {
+	return jwtbearer.Exchange(p.Client(), p.config.Endpoint.TokenURL, assertion, nil)
+}
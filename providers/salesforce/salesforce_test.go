@@ -1,11 +1,17 @@
 package salesforce_test
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
 	"github.com/andreimerlescu/goth"
 	"github.com/andreimerlescu/goth/providers/salesforce"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,6 +41,86 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "login.salesforce.com/services/oauth2/authorize")
 }
 
+func Test_BeginAuthSandbox(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := salesforce.NewSandbox(os.Getenv("SALESFORCE_KEY"), os.Getenv("SALESFORCE_SECRET"), "/foo")
+	session, err := p.BeginAuth("test_state")
+	s := session.(*salesforce.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "https://test.salesforce.com/services/oauth2/authorize")
+}
+
+func Test_BeginAuthCustomDomain(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := salesforce.NewCustomDomain(os.Getenv("SALESFORCE_KEY"), os.Getenv("SALESFORCE_SECRET"), "/foo", "acme.my.salesforce.com/")
+	session, err := p.BeginAuth("test_state")
+	s := session.(*salesforce.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "https://acme.my.salesforce.com/services/oauth2/authorize")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/id/00Dx0000001T0zk/005x0000001S2b9" || req.Header.Get("Authorization") != "Bearer 1234567890" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(res, `{"user_id":"005x0000001S2b9","organization_id":"00Dx0000001T0zk","display_name":"Alan Van","nick_name":"alan","first_name":"Alan","last_name":"Van","email":"admin@2060747062.com","addr_country":"US","photos":{"picture":"https://example.my.salesforce.com/profilephoto/005/F"}}`)
+	}))
+	defer server.Close()
+
+	p := provider()
+	p.HTTPClient = server.Client()
+
+	user, err := p.FetchUser(&salesforce.Session{AccessToken: "1234567890", ID: server.URL + "/id/00Dx0000001T0zk/005x0000001S2b9"})
+	a.NoError(err)
+	a.Equal("005x0000001S2b9", user.UserID)
+	a.Equal("Alan Van", user.Name)
+	a.Equal("alan", user.NickName)
+	a.Equal("admin@2060747062.com", user.Email)
+	a.Equal("https://example.my.salesforce.com/profilephoto/005/F", user.AvatarURL)
+	a.Equal("00Dx0000001T0zk", salesforce.OrganizationID(user))
+
+	_, err = p.FetchUser(&salesforce.Session{AccessToken: "1234567890"})
+	a.Error(err)
+}
+
+func Test_TokenFromAssertion(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		claims := jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(req.PostFormValue("assertion"), claims, func(*jwt.Token) (interface{}, error) {
+			return &key.PublicKey, nil
+		}, jwt.WithAudience(server.URL), jwt.WithIssuer("consumer"), jwt.WithSubject("admin@acme.com"))
+		if req.URL.Path != "/services/oauth2/token" || err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(res, `{"access_token":"1234567890","scope":"api","instance_url":"https://acme.my.salesforce.com","id":"https://login.salesforce.com/id/00Dx0000001T0zk/005x0000001S2b9","token_type":"Bearer"}`)
+	}))
+	defer server.Close()
+
+	p := salesforce.NewCustomDomain("consumer", "", "/foo", server.URL)
+	a.Implements((*goth.AssertionProvider)(nil), p)
+
+	assertion, err := p.Assertion("admin@acme.com", key).Sign()
+	a.NoError(err)
+	token, err := p.TokenFromAssertion(assertion)
+	a.NoError(err)
+	a.Equal("1234567890", token.AccessToken)
+	a.Equal("https://acme.my.salesforce.com", token.Extra("instance_url"))
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -23,6 +23,7 @@ type Session struct {
 	AccessToken  string
 	RefreshToken string
 	ID           string // Required to get the user info from sales force
+	InstanceURL  string `json:",omitempty"` // Base URL of the org's REST API
 }
 
 var _ goth.Session = &Session{}
@@ -50,7 +51,12 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
-	s.ID = token.Extra("id").(string) // Required to get the user info from sales force
+	id, ok := token.Extra("id").(string) // Required to get the user info from sales force
+	if !ok {
+		return "", errors.New("salesforce: token response did not include an identity URL")
+	}
+	s.ID = id
+	s.InstanceURL, _ = token.Extra("instance_url").(string)
 	return token.AccessToken, err
 }
 
@@ -61,7 +67,7 @@ func (s Session) Marshal() string {
 }
 
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // UnmarshalSession wil unmarshal a JSON string into a session.
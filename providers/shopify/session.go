@@ -1,24 +1,14 @@
 package shopify
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"os"
-	"regexp"
 	"strings"
 	"time"
 
 	"github.com/andreimerlescu/goth"
 )
 
-const (
-	shopifyHostnameRegex = `^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\-]*[A-Za-z0-9])$`
-)
-
 // Session stores data during the auth process with Shopify.
 type Session struct {
 	AuthURL     string
@@ -26,6 +16,7 @@ type Session struct {
 	Hostname    string
 	HMAC        string
 	ExpiresAt   time.Time
+	Shop        string `json:",omitempty"`
 }
 
 var _ goth.Session = &Session{}
@@ -40,35 +31,26 @@ func (s Session) GetAuthURL() (string, error) {
 
 // Authorize the session with Shopify and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
-	// Validate the incoming HMAC is valid.
-	// See: https://help.shopify.com/en/api/getting-started/authentication/oauth#verification
-	digest := fmt.Sprintf(
-		"code=%s&host=%s&shop=%s&state=%s&timestamp=%s",
-		params.Get("code"),
-		params.Get("host"),
-		params.Get("shop"),
-		params.Get("state"),
-		params.Get("timestamp"),
-	)
-	h := hmac.New(sha256.New, []byte(os.Getenv("SHOPIFY_SECRET")))
-	h.Write([]byte(digest))
-	sha := hex.EncodeToString(h.Sum(nil))
+	p := provider.(*Provider)
 
-	// Ensure our HMAC hash's match.
-	if sha != params.Get("hmac") {
-		return "", errors.New("Invalid HMAC received")
+	// Validate the incoming HMAC is valid.
+	// See: https://shopify.dev/docs/apps/auth/oauth/getting-started#step-6-verify-the-authorization-code
+	if !p.validHMAC(params) {
+		return "", ErrInvalidHMAC
 	}
 
-	// Validate the hostname matches what we're expecting.
-	// See: https://help.shopify.com/en/api/getting-started/authentication/oauth#step-3-confirm-installation
-	re := regexp.MustCompile(shopifyHostnameRegex)
-	if !re.MatchString(params.Get("shop")) {
-		return "", errors.New("Invalid hostname received")
+	// Validate the shop matches what we're expecting.
+	// See: https://shopify.dev/docs/apps/auth/oauth/getting-started#step-3-confirm-installation
+	shop := params.Get("shop")
+	if !ValidShopDomain(shop) {
+		return "", ErrInvalidShop
+	}
+	if s.Shop != "" && s.Shop != shop {
+		return "", errors.New("shop in callback does not match the shop the authentication was started for")
 	}
 
-	// Make the exchange for an access token.
-	p := provider.(*Provider)
-	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
+	// Make the exchange for an access token with the shop that authorized the app.
+	token, err := p.configForShop(shop).Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
 	if err != nil {
 		return "", err
 	}
@@ -81,6 +63,7 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	s.AccessToken = token.AccessToken
 	s.Hostname = params.Get("hostname")
 	s.HMAC = params.Get("hmac")
+	s.Shop = shop
 
 	return token.AccessToken, err
 }
@@ -92,7 +75,7 @@ func (s Session) Marshal() string {
 }
 
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // UnmarshalSession wil unmarshal a JSON string into a session.
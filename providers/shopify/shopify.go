@@ -3,12 +3,19 @@
 package shopify
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/andreimerlescu/goth"
 	"golang.org/x/oauth2"
@@ -21,8 +28,19 @@ const (
 	authURL         = "myshopify.com/admin/oauth/authorize"
 	tokenURL        = "myshopify.com/admin/oauth/access_token"
 	endpointProfile = "myshopify.com/admin/api/2019-04/shop.json"
+
+	shopDomainSuffix = ".myshopify.com"
 )
 
+var shopDomainRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9\-]*\.myshopify\.com$`)
+
+// ErrInvalidHMAC is returned when the hmac parameter Shopify signs its
+// requests with does not match the request's other parameters.
+var ErrInvalidHMAC = errors.New("Invalid HMAC received")
+
+// ErrInvalidShop is returned when the shop parameter is not a myshopify.com domain.
+var ErrInvalidShop = errors.New("Invalid hostname received")
+
 // Provider is the implementation of `goth.Provider` for accessing Shopify.
 type Provider struct {
 	ClientKey    string
@@ -65,6 +83,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 // SetShopName is to update the shopify shop name, needed when interfacing with different shops.
 func (p *Provider) SetShopName(name string) {
 	p.shopName = name
@@ -76,13 +104,91 @@ func (p *Provider) SetShopName(name string) {
 // Debug is a no-op for the Shopify package.
 func (p *Provider) Debug(debug bool) {}
 
-// BeginAuth asks Shopify for an authentication end-point.
+// BeginAuth asks Shopify for an authentication end-point of the shop set with SetShopName.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 	return &Session{
 		AuthURL: p.config.AuthCodeURL(state),
 	}, nil
 }
 
+// BeginAuthWithParams asks Shopify for an authentication end-point of the shop
+// given in the "shop" parameter, falling back to the shop set with SetShopName.
+// This lets a single provider serve every shop an embedded app is installed on.
+// When the parameters are signed, as they are when Shopify sends a merchant to
+// the app, the signature is verified first.
+func (p *Provider) BeginAuthWithParams(state string, params goth.Params) (goth.Session, error) {
+	shop := params.Get("shop")
+	if shop == "" {
+		return p.BeginAuth(state)
+	}
+	if !ValidShopDomain(shop) {
+		return nil, ErrInvalidShop
+	}
+	if params.Get("hmac") != "" && !p.validHMAC(params) {
+		return nil, ErrInvalidHMAC
+	}
+
+	return &Session{
+		AuthURL: p.configForShop(shop).AuthCodeURL(state),
+		Shop:    shop,
+	}, nil
+}
+
+// ValidShopDomain reports whether shop is a well-formed myshopify.com domain,
+// such as "example.myshopify.com".
+func ValidShopDomain(shop string) bool {
+	return shopDomainRegexp.MatchString(shop)
+}
+
+// VerifyHMAC checks the hmac parameter Shopify adds to the requests it sends to
+// an app, such as the OAuth callback, against the other parameters.
+// See https://shopify.dev/docs/apps/auth/oauth/getting-started#step-2-verify-the-installation-request
+func VerifyHMAC(params url.Values, secret string) bool {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if key == "hmac" || key == "signature" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, strings.Join(params[key], ",")))
+	}
+
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strings.Join(pairs, "&")))
+	expected := hex.EncodeToString(h.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(params.Get("hmac")))
+}
+
+func (p *Provider) validHMAC(params goth.Params) bool {
+	values, ok := params.(url.Values)
+	if !ok {
+		// only the parameters Shopify sends on the callback are known
+		values = url.Values{}
+		for _, key := range []string{"code", "host", "shop", "state", "timestamp", "hmac"} {
+			if v := params.Get(key); v != "" {
+				values.Set(key, v)
+			}
+		}
+	}
+	return VerifyHMAC(values, p.Secret)
+}
+
+func (p *Provider) configForShop(shop string) *oauth2.Config {
+	c := *p.config
+	subdomain := strings.TrimSuffix(shop, shopDomainSuffix)
+	c.Endpoint = oauth2.Endpoint{
+		AuthURL:  fmt.Sprintf("https://%s.%s", subdomain, authURL),
+		TokenURL: fmt.Sprintf("https://%s.%s", subdomain, tokenURL),
+	}
+	return &c
+}
+
 // RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
 	return false
@@ -106,8 +212,13 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return shop, fmt.Errorf("%s cannot get shop information without accessToken", p.providerName)
 	}
 
+	shopName := p.shopName
+	if s.Shop != "" {
+		shopName = strings.TrimSuffix(s.Shop, shopDomainSuffix)
+	}
+
 	// Build the request.
-	req, err := http.NewRequest("GET", fmt.Sprintf("https://%s.%s", p.shopName, endpointProfile), nil)
+	req, err := http.NewRequest("GET", fmt.Sprintf("https://%s.%s", shopName, endpointProfile), nil)
 	if err != nil {
 		return shop, err
 	}
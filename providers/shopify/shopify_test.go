@@ -1,6 +1,7 @@
 package shopify_test
 
 import (
+	"net/url"
 	"os"
 	"testing"
 
@@ -35,6 +36,64 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "https://test-shop.myshopify.com/admin/oauth/authorize")
 }
 
+func Test_BeginAuthWithParams(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := shopify.New(os.Getenv("SHOPIFY_KEY"), "hush", "/foo")
+
+	session, err := p.BeginAuthWithParams("test_state", url.Values{"shop": {"other-shop.myshopify.com"}})
+	a.NoError(err)
+	s := session.(*shopify.Session)
+	a.Contains(s.AuthURL, "https://other-shop.myshopify.com/admin/oauth/authorize")
+	a.Equal("other-shop.myshopify.com", s.Shop)
+
+	_, err = p.BeginAuthWithParams("test_state", url.Values{"shop": {"evil.example.com"}})
+	a.Equal(shopify.ErrInvalidShop, err)
+
+	_, err = p.BeginAuthWithParams("test_state", url.Values{"shop": {"other-shop.myshopify.com"}, "timestamp": {"1337178173"}, "hmac": {"deadbeef"}})
+	a.Equal(shopify.ErrInvalidHMAC, err)
+}
+
+func Test_VerifyHMAC(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	// example from https://shopify.dev/docs/apps/auth/oauth/getting-started
+	params := url.Values{
+		"code":      {"0907a61c0c8d55e99db179b68161bc00"},
+		"shop":      {"some-shop.myshopify.com"},
+		"state":     {"0.6784241404160823"},
+		"timestamp": {"1337178173"},
+		"hmac":      {"700e2dadb827fcc8609e9d5ce208b2e9cdaab9df07390d2cbca10d7c328fc4bf"},
+	}
+	a.True(shopify.VerifyHMAC(params, "hush"))
+	a.False(shopify.VerifyHMAC(params, "not-hush"))
+
+	params.Set("shop", "other-shop.myshopify.com")
+	a.False(shopify.VerifyHMAC(params, "hush"))
+}
+
+func Test_AuthorizeRejectsInvalidCallback(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := shopify.New(os.Getenv("SHOPIFY_KEY"), "hush", "/foo")
+
+	s := &shopify.Session{Shop: "other-shop.myshopify.com"}
+	_, err := s.Authorize(p, url.Values{"shop": {"some-shop.myshopify.com"}, "hmac": {"deadbeef"}})
+	a.Equal(shopify.ErrInvalidHMAC, err)
+
+	params := url.Values{
+		"code":      {"0907a61c0c8d55e99db179b68161bc00"},
+		"shop":      {"some-shop.myshopify.com"},
+		"state":     {"0.6784241404160823"},
+		"timestamp": {"1337178173"},
+		"hmac":      {"700e2dadb827fcc8609e9d5ce208b2e9cdaab9df07390d2cbca10d7c328fc4bf"},
+	}
+	_, err = s.Authorize(p, params)
+	a.Error(err)
+	a.Empty(s.AccessToken)
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
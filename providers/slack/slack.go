@@ -17,6 +17,11 @@ import (
 // Scopes
 const (
 	ScopeUserRead string = "users:read"
+
+	// Sign in with Slack (OpenID Connect) scopes
+	ScopeOpenID  string = "openid"
+	ScopeProfile string = "profile"
+	ScopeEmail   string = "email"
 )
 
 // URLs and endpoints
@@ -25,6 +30,18 @@ const (
 	tokenURL        string = "https://slack.com/api/oauth.access"
 	endpointUser    string = "https://slack.com/api/auth.test"
 	endpointProfile string = "https://slack.com/api/users.info"
+
+	openIDAuthURL      string = "https://slack.com/openid/connect/authorize"
+	openIDTokenURL     string = "https://slack.com/api/openid.connect.token"
+	endpointOpenIDUser string = "https://slack.com/api/openid.connect.userInfo"
+)
+
+// Claims returned by the Sign in with Slack userinfo endpoint
+const (
+	TeamIDClaim     string = "https://slack.com/team_id"
+	TeamNameClaim   string = "https://slack.com/team_name"
+	TeamDomainClaim string = "https://slack.com/team_domain"
+	UserIDClaim     string = "https://slack.com/user_id"
 )
 
 // Provider is the implementation of `goth.Provider` for accessing Slack.
@@ -35,6 +52,8 @@ type Provider struct {
 	HTTPClient   *http.Client
 	config       *oauth2.Config
 	providerName string
+	openID       bool
+	team         string
 }
 
 // New creates a new Slack provider and sets up important connection details.
@@ -51,6 +70,28 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 	return p
 }
 
+// NewOpenID creates a new Slack provider that uses Sign in with Slack, Slack's
+// OpenID Connect flow, which replaces the legacy identity scopes. When no scopes
+// are given "openid profile email" is requested.
+// See https://api.slack.com/authentication/sign-in-with-slack
+func NewOpenID(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "slack",
+		openID:       true,
+	}
+	p.config = newOpenIDConfig(p, scopes)
+	return p
+}
+
+// SetTeam sets the team parameter for the Sign in with Slack call. When the user
+// is already signed in to that workspace, Slack skips the workspace picker.
+func (p *Provider) SetTeam(teamID string) {
+	p.team = teamID
+}
+
 // Name is the name used to retrieve this provider later.
 func (p *Provider) Name() string {
 	return p.providerName
@@ -61,6 +102,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 // Client returns the http.Client used in the provider.
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
@@ -71,8 +122,12 @@ func (p *Provider) Debug(debug bool) {}
 
 // BeginAuth asks Slack for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	var opts []oauth2.AuthCodeOption
+	if p.team != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("team", p.team))
+	}
 	return &Session{
-		AuthURL: p.config.AuthCodeURL(state),
+		AuthURL: p.config.AuthCodeURL(state, opts...),
 	}, nil
 }
 
@@ -91,6 +146,11 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
+	if p.openID {
+		user.IDToken = sess.IDToken
+		return user, p.fetchOpenIDUser(sess.AccessToken, &user)
+	}
+
 	// Get the userID, Slack needs userID in order to get user profile info
 	req, _ := http.NewRequest("GET", endpointUser, nil)
 	req.Header.Add("Authorization", "Bearer "+sess.AccessToken)
@@ -146,6 +206,39 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	return user, err
 }
 
+func (p *Provider) fetchOpenIDUser(accessToken string, user *goth.User) error {
+	req, _ := http.NewRequest("GET", endpointOpenIDUser, nil)
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
+	}
+
+	bits, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return err
+	}
+
+	return openIDUserFromReader(bytes.NewReader(bits), user)
+}
+
+// TeamID returns the ID of the Slack workspace a user fetched by a Sign in with
+// Slack provider belongs to. Use it to restrict logins to known workspaces.
+func TeamID(user goth.User) string {
+	teamID, _ := user.RawData[TeamIDClaim].(string)
+	return teamID
+}
+
 func (p *Provider) hasScope(scope string) bool {
 	hasScope := false
 
@@ -181,6 +274,28 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 	return c
 }
 
+func newOpenIDConfig(provider *Provider, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  openIDAuthURL,
+			TokenURL: openIDTokenURL,
+		},
+		Scopes: []string{},
+	}
+
+	if len(scopes) > 0 {
+		for _, scope := range scopes {
+			c.Scopes = append(c.Scopes, scope)
+		}
+	} else {
+		c.Scopes = append(c.Scopes, ScopeOpenID, ScopeProfile, ScopeEmail)
+	}
+	return c
+}
+
 func simpleUserFromReader(r io.Reader, user *goth.User) error {
 	u := struct {
 		UserID string `json:"user_id"`
@@ -226,6 +341,33 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	return nil
 }
 
+func openIDUserFromReader(r io.Reader, user *goth.User) error {
+	u := struct {
+		OK        bool   `json:"ok"`
+		Error     string `json:"error"`
+		UserID    string `json:"https://slack.com/user_id"`
+		Email     string `json:"email"`
+		Name      string `json:"name"`
+		FirstName string `json:"given_name"`
+		LastName  string `json:"family_name"`
+		Picture   string `json:"picture"`
+	}{}
+	err := json.NewDecoder(r).Decode(&u)
+	if err != nil {
+		return err
+	}
+	if !u.OK {
+		return fmt.Errorf("slack responded with an error trying to fetch user information: %s", u.Error)
+	}
+	user.UserID = u.UserID
+	user.Email = u.Email
+	user.Name = u.Name
+	user.FirstName = u.FirstName
+	user.LastName = u.LastName
+	user.AvatarURL = u.Picture
+	return nil
+}
+
 // RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
 	return false
@@ -198,6 +198,54 @@ func Test_FetchUser(t *testing.T) {
 	}
 }
 
+func Test_BeginAuthOpenID(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := slack.NewOpenID(os.Getenv("SLACK_KEY"), os.Getenv("SLACK_SECRET"), "/foo")
+	p.SetTeam("T0123456")
+	session, err := p.BeginAuth("test_state")
+	s := session.(*slack.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "slack.com/openid/connect/authorize")
+	a.Contains(s.AuthURL, "scope=openid+profile+email")
+	a.Contains(s.AuthURL, "team=T0123456")
+}
+
+func Test_FetchUserOpenID(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	handler := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/openid.connect.userInfo" || req.Header.Get("Authorization") != "Bearer TOKEN" {
+			res.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(res).Encode(map[string]interface{}{
+			"ok":                          true,
+			"sub":                         "U0R7JM",
+			"https://slack.com/user_id":   "U0R7JM",
+			"https://slack.com/team_id":   "T0R7GR",
+			"https://slack.com/team_name": "kraneflannel",
+			"email":                       "krane@slack-corp.com",
+			"name":                        "krane",
+			"given_name":                  "Krane",
+			"family_name":                 "Flannel",
+			"picture":                     "https://secure.gravatar.com/avatar/bc.png",
+		})
+	})
+
+	withMockServer(slack.NewOpenID(os.Getenv("SLACK_KEY"), os.Getenv("SLACK_SECRET"), "/foo"), handler, func(p *slack.Provider) {
+		user, err := p.FetchUser(&slack.Session{AccessToken: "TOKEN", IDToken: "ID_TOKEN"})
+		a.NoError(err)
+		a.Equal("U0R7JM", user.UserID)
+		a.Equal("krane@slack-corp.com", user.Email)
+		a.Equal("Krane", user.FirstName)
+		a.Equal("Flannel", user.LastName)
+		a.Equal("ID_TOKEN", user.IDToken)
+		a.Equal("T0R7GR", slack.TeamID(user))
+	})
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
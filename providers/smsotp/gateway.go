@@ -0,0 +1,214 @@
+package smsotp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Sender sends text messages.
+type Sender interface {
+	SendSMS(phone, message string) error
+}
+
+// SenderFunc adapts a function to the Sender interface.
+type SenderFunc func(phone, message string) error
+
+// SendSMS calls f(phone, message).
+func (f SenderFunc) SendSMS(phone, message string) error {
+	return f(phone, message)
+}
+
+// SMSGateway is a Gateway generating the codes, and sending them with any SMS
+// service. The codes are kept in memory, so applications running more than
+// one instance should route the users of a phone number to the same one, or
+// use a service checking the codes like Twilio Verify.
+type SMSGateway struct {
+	Sender Sender
+	// Message is the text of the message, with a %s verb for the code.
+	Message string
+	Digits  int
+	// Expiry is how long codes are valid.
+	Expiry time.Duration
+	// MaxAttempts is the number of codes which may be tried, before a new
+	// code has to be sent.
+	MaxAttempts int
+
+	mu    sync.Mutex
+	codes map[string]*sentCode
+	now   func() time.Time
+}
+
+type sentCode struct {
+	hash      [sha256.Size]byte
+	expiresAt time.Time
+	attempts  int
+}
+
+var _ Gateway = &SMSGateway{}
+
+// NewSMSGateway creates a gateway sending codes of 6 digits, valid for 10
+// minutes, with the sender.
+func NewSMSGateway(sender Sender) *SMSGateway {
+	return &SMSGateway{
+		Sender:      sender,
+		Message:     "Your verification code is %s",
+		Digits:      6,
+		Expiry:      10 * time.Minute,
+		MaxAttempts: 5,
+		codes:       map[string]*sentCode{},
+		now:         goth.Now,
+	}
+}
+
+// SendCode sends a new code to the phone number, replacing the previous one.
+func (g *SMSGateway) SendCode(phone string) error {
+	limit := big.NewInt(1)
+	for i := 0; i < g.Digits; i++ {
+		limit.Mul(limit, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return err
+	}
+	code := fmt.Sprintf("%0*d", g.Digits, n)
+
+	g.mu.Lock()
+	now := g.now()
+	for p, c := range g.codes {
+		if now.After(c.expiresAt) {
+			delete(g.codes, p)
+		}
+	}
+	g.codes[phone] = &sentCode{
+		hash:      sha256.Sum256([]byte(code)),
+		expiresAt: now.Add(g.Expiry),
+	}
+	g.mu.Unlock()
+
+	return g.Sender.SendSMS(phone, fmt.Sprintf(g.Message, code))
+}
+
+// CheckCode reports whether the code is the one sent to the phone number.
+func (g *SMSGateway) CheckCode(phone, code string) (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c, ok := g.codes[phone]
+	if !ok || g.now().After(c.expiresAt) {
+		return false, nil
+	}
+	hash := sha256.Sum256([]byte(code))
+	if subtle.ConstantTimeCompare(hash[:], c.hash[:]) == 1 {
+		delete(g.codes, phone)
+		return true, nil
+	}
+	c.attempts++
+	if c.attempts >= g.MaxAttempts {
+		delete(g.codes, phone)
+	}
+	return false, nil
+}
+
+// TwilioVerify is a Gateway using the Twilio Verify service, which generates,
+// sends and checks the codes, see https://www.twilio.com/docs/verify/api
+type TwilioVerify struct {
+	AccountSID string
+	AuthToken  string
+	// ServiceSID is the SID of the Verify service, starting with "VA".
+	ServiceSID string
+	// Channel is the channel the codes are sent with, "sms" by default.
+	Channel    string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+var _ Gateway = &TwilioVerify{}
+
+// NewTwilioVerify creates a gateway sending the codes of the Verify service by SMS.
+func NewTwilioVerify(accountSID, authToken, serviceSID string) *TwilioVerify {
+	return &TwilioVerify{
+		AccountSID: accountSID,
+		AuthToken:  authToken,
+		ServiceSID: serviceSID,
+		Channel:    "sms",
+		BaseURL:    "https://verify.twilio.com",
+	}
+}
+
+// TwilioError is an error returned by the Twilio API.
+type TwilioError struct {
+	Status   int    `json:"status"`
+	Code     int    `json:"code"`
+	Message  string `json:"message"`
+	MoreInfo string `json:"more_info"`
+}
+
+func (e *TwilioError) Error() string {
+	return fmt.Sprintf("smsotp: twilio responded with a %d: %d %s", e.Status, e.Code, e.Message)
+}
+
+// SendCode starts a verification of the phone number.
+func (t *TwilioVerify) SendCode(phone string) error {
+	_, err := t.post("Verifications", url.Values{"To": {phone}, "Channel": {t.Channel}})
+	return err
+}
+
+// CheckCode checks the code of the pending verification of the phone number.
+func (t *TwilioVerify) CheckCode(phone, code string) (bool, error) {
+	status, err := t.post("VerificationCheck", url.Values{"To": {phone}, "Code": {code}})
+	if e, ok := err.(*TwilioError); ok && e.Status == http.StatusNotFound {
+		// the verification has expired, was approved, or had too many
+		// attempts
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return status == "approved", nil
+}
+
+// post calls the endpoint of the service, and returns the status of the verification.
+func (t *TwilioVerify) post(endpoint string, form url.Values) (string, error) {
+	u := strings.TrimRight(t.BaseURL, "/") + "/v2/Services/" + url.PathEscape(t.ServiceSID) + "/" + endpoint
+	req, err := http.NewRequest(http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := goth.HTTPClientWithFallBack(t.HTTPClient).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body := io.LimitReader(resp.Body, 1<<20)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &TwilioError{}
+		_ = json.NewDecoder(body).Decode(e)
+		e.Status = resp.StatusCode
+		return "", e
+	}
+
+	var verification struct {
+		Status string `json:"status"`
+	}
+	err = json.NewDecoder(body).Decode(&verification)
+	if err != nil {
+		return "", err
+	}
+	return verification.Status, nil
+}
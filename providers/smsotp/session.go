@@ -0,0 +1,63 @@
+package smsotp
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Session stores data during the auth process with an SMS one-time code.
+type Session struct {
+	AuthURL  string
+	Phone    string
+	Verified bool
+}
+
+var _ goth.Session = &Session{}
+
+// GetAuthURL will return the URL set by calling the `BeginAuthWithParams` function on the smsotp provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize the session with the "code" parameter, and return the verified
+// phone number.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	code := strings.TrimSpace(params.Get("code"))
+	if code == "" {
+		return "", ErrInvalidCode
+	}
+	ok, err := p.Gateway.CheckCode(s.Phone, code)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrInvalidCode
+	}
+
+	s.Verified = true
+	return s.Phone, nil
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return goth.RedactJSON(s.Marshal())
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	s := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
+	return s, err
+}
@@ -0,0 +1,190 @@
+// Package smsotp implements authentication with a one-time code sent by SMS to
+// the phone number of the user, through a pluggable Gateway: Twilio Verify, or
+// any SMS service sending the codes of an SMSGateway.
+//
+// gothic.BeginAuthHandler sends the code to the "phone" parameter of the
+// request, posted by a form or in the query, and redirects to the page where
+// the user enters the code. That page posts the "code", with the "state" it was
+// given, to the callback handled with gothic.CompleteUserAuth:
+//
+//	<form method="post" action="/auth/smsotp/callback">
+//		<input type="hidden" name="state" value="{{.State}}">
+//		<input name="code" autocomplete="one-time-code" inputmode="numeric">
+//	</form>
+package smsotp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrPhoneRequired is returned when the authentication is started without a phone number.
+	ErrPhoneRequired = errors.New("smsotp: a phone number is required")
+	// ErrInvalidPhone is returned for phone numbers which can't be written in the E.164 format.
+	ErrInvalidPhone = errors.New("smsotp: invalid phone number")
+	// ErrInvalidCode is returned for codes which weren't sent to the phone number,
+	// or have expired.
+	ErrInvalidCode = errors.New("smsotp: invalid code")
+)
+
+// Gateway sends one-time codes to phone numbers, and checks the codes users
+// enter. Phone numbers are in the E.164 format, e.g. "+14155550100".
+type Gateway interface {
+	SendCode(phone string) error
+	// CheckCode reports whether the code is the one sent to the phone
+	// number. A code should only be accepted once.
+	CheckCode(phone, code string) (bool, error)
+}
+
+// New creates a new SMS one-time code provider sending the codes with the
+// gateway. Users are sent to codeURL to enter the code, which is posted to
+// callbackURL.
+// You should always call `smsotp.New` to get a new Provider. Never try to
+// create one manually.
+func New(callbackURL, codeURL string, gateway Gateway) *Provider {
+	return &Provider{
+		CallbackURL:  callbackURL,
+		CodeURL:      codeURL,
+		Gateway:      gateway,
+		providerName: "smsotp",
+	}
+}
+
+// Provider is the implementation of `goth.Provider` for SMS one-time codes.
+type Provider struct {
+	CallbackURL string
+	// CodeURL is the page where users enter the code they received.
+	CodeURL string
+	Gateway Gateway
+	// DefaultCountryCode is the calling code of the numbers entered without
+	// one, e.g. "44". Such numbers are rejected when it is empty.
+	DefaultCountryCode string
+	providerName       string
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Local reports that the provider is a goth.LocalProvider: the phone numbers are verified during the login, not with a token.
+func (p *Provider) Local() bool {
+	return true
+}
+
+// Debug is a no-op for the smsotp package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth can't send a code without a phone number, use BeginAuthWithParams.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return nil, ErrPhoneRequired
+}
+
+// BeginAuthWithParams sends a code to the "phone" parameter.
+func (p *Provider) BeginAuthWithParams(state string, params goth.Params) (goth.Session, error) {
+	if params.Get("phone") == "" {
+		return nil, ErrPhoneRequired
+	}
+	phone, err := p.NormalizePhone(params.Get("phone"))
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.Gateway.SendCode(phone)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		AuthURL: p.CodeURL + separator(p.CodeURL) + url.Values{"state": {state}}.Encode(),
+		Phone:   phone,
+	}
+	return session, nil
+}
+
+// FetchUser returns the user whose phone number has been verified with the code.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	s := session.(*Session)
+	user := goth.User{
+		Provider: p.Name(),
+		UserID:   s.Phone,
+	}
+
+	if !s.Verified {
+		// the code hasn't been entered yet
+		return user, fmt.Errorf("%s cannot get user information without a verified phone number", p.providerName)
+	}
+
+	user.RawData = map[string]interface{}{
+		"phone_number":          s.Phone,
+		"phone_number_verified": true,
+	}
+	return user, nil
+}
+
+// RefreshToken refresh token is not provided by smsotp
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by smsotp")
+}
+
+// RefreshTokenAvailable refresh token is not provided by smsotp
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
+
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// NormalizePhone writes the phone number in the E.164 format, removing the
+// punctuation and adding the DefaultCountryCode to national numbers.
+func (p *Provider) NormalizePhone(phone string) (string, error) {
+	phone = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')', '\u00a0':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+
+	switch {
+	case strings.HasPrefix(phone, "+"):
+	case strings.HasPrefix(phone, "00"):
+		phone = "+" + phone[2:]
+	case p.DefaultCountryCode != "":
+		// the trunk prefix of national numbers
+		phone = "+" + strings.TrimPrefix(p.DefaultCountryCode, "+") + strings.TrimPrefix(phone, "0")
+	}
+
+	if !e164.MatchString(phone) {
+		return "", ErrInvalidPhone
+	}
+	return phone, nil
+}
+
+func separator(u string) string {
+	if strings.Contains(u, "?") {
+		return "&"
+	}
+	return "?"
+}
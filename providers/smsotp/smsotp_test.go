@@ -0,0 +1,197 @@
+package smsotp_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/smsotp"
+	"github.com/stretchr/testify/assert"
+)
+
+// provider returns a provider sending codes to the returned map.
+func provider() (*smsotp.Provider, map[string]string) {
+	codes := map[string]string{}
+	gateway := smsotp.NewSMSGateway(smsotp.SenderFunc(func(phone, message string) error {
+		codes[phone] = strings.TrimPrefix(message, "Your verification code is ")
+		return nil
+	}))
+	return smsotp.New("https://example.com/auth/smsotp/callback", "/enter-code", gateway), codes
+}
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p, _ := provider()
+
+	a.Equal("https://example.com/auth/smsotp/callback", p.CallbackURL)
+	a.Equal("/enter-code", p.CodeURL)
+	a.Equal("smsotp", p.Name())
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p, _ := provider()
+	a.Implements((*goth.Provider)(nil), p)
+	a.Implements((*goth.BeginAuthWithParamsProvider)(nil), p)
+}
+
+func Test_NormalizePhone(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p, _ := provider()
+
+	phone, err := p.NormalizePhone("+1 (415) 555-0100")
+	a.NoError(err)
+	a.Equal("+14155550100", phone)
+
+	phone, err = p.NormalizePhone("0044 20 7946 0958")
+	a.NoError(err)
+	a.Equal("+442079460958", phone)
+
+	_, err = p.NormalizePhone("020 7946 0958")
+	a.Equal(smsotp.ErrInvalidPhone, err)
+	p.DefaultCountryCode = "44"
+	phone, err = p.NormalizePhone("020 7946 0958")
+	a.NoError(err)
+	a.Equal("+442079460958", phone)
+
+	for _, invalid := range []string{"+0123456789", "+1 555", "+1415555010012345", "+1415abc0100"} {
+		_, err = p.NormalizePhone(invalid)
+		a.Equal(smsotp.ErrInvalidPhone, err, invalid)
+	}
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p, codes := provider()
+
+	_, err := p.BeginAuth("test_state")
+	a.Equal(smsotp.ErrPhoneRequired, err)
+
+	session, err := p.BeginAuthWithParams("test_state", url.Values{"phone": {"+1 415 555 0100"}})
+	a.NoError(err)
+	s := session.(*smsotp.Session)
+	a.Equal("/enter-code?state=test_state", s.AuthURL)
+	a.Equal("+14155550100", s.Phone)
+	a.Len(codes["+14155550100"], 6)
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p, codes := provider()
+
+	session, err := p.BeginAuthWithParams("test_state", url.Values{"phone": {"+14155550100"}})
+	a.NoError(err)
+
+	_, err = p.FetchUser(session)
+	a.Error(err)
+
+	wrong := "000000"
+	if codes["+14155550100"] == wrong {
+		wrong = "111111"
+	}
+	_, err = session.Authorize(p, url.Values{"code": {wrong}})
+	a.Equal(smsotp.ErrInvalidCode, err)
+	_, err = session.Authorize(p, url.Values{})
+	a.Equal(smsotp.ErrInvalidCode, err)
+
+	phone, err := session.Authorize(p, url.Values{"code": {codes["+14155550100"]}})
+	a.NoError(err)
+	a.Equal("+14155550100", phone)
+
+	user, err := p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("smsotp", user.Provider)
+	a.Equal("+14155550100", user.UserID)
+	a.Equal(true, user.RawData["phone_number_verified"])
+
+	// codes are single-use
+	_, err = (&smsotp.Session{Phone: "+14155550100"}).Authorize(p, url.Values{"code": {codes["+14155550100"]}})
+	a.Equal(smsotp.ErrInvalidCode, err)
+}
+
+func Test_MaxAttempts(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	codes := map[string]string{}
+	gateway := smsotp.NewSMSGateway(smsotp.SenderFunc(func(phone, message string) error {
+		codes[phone] = strings.TrimPrefix(message, "Your verification code is ")
+		return nil
+	}))
+
+	a.NoError(gateway.SendCode("+14155550100"))
+	code := codes["+14155550100"]
+	for i := 0; i < gateway.MaxAttempts; i++ {
+		ok, err := gateway.CheckCode("+14155550100", "wrong")
+		a.NoError(err)
+		a.False(ok)
+	}
+	ok, err := gateway.CheckCode("+14155550100", code)
+	a.NoError(err)
+	a.False(ok)
+
+	// a new code can be sent
+	a.NoError(gateway.SendCode("+14155550100"))
+	ok, err = gateway.CheckCode("+14155550100", codes["+14155550100"])
+	a.NoError(err)
+	a.True(ok)
+}
+
+func Test_TwilioVerify(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		user, password, _ := req.BasicAuth()
+		if user != "AC123" || password != "token" {
+			res.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(res, `{"code":20003,"message":"Authenticate","status":401}`)
+			return
+		}
+		_ = req.ParseForm()
+		switch {
+		case req.URL.Path == "/v2/Services/VA123/Verifications" && req.Form.Get("To") == "+14155550100" && req.Form.Get("Channel") == "sms":
+			res.WriteHeader(http.StatusCreated)
+			fmt.Fprint(res, `{"sid":"VE123","status":"pending"}`)
+		case req.URL.Path == "/v2/Services/VA123/VerificationCheck" && req.Form.Get("To") == "+14155550100":
+			if req.Form.Get("Code") == "123456" {
+				fmt.Fprint(res, `{"sid":"VE123","status":"approved","valid":true}`)
+			} else {
+				fmt.Fprint(res, `{"sid":"VE123","status":"pending","valid":false}`)
+			}
+		default:
+			res.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(res, `{"code":20404,"message":"The requested resource was not found","status":404}`)
+		}
+	}))
+	defer ts.Close()
+
+	gateway := smsotp.NewTwilioVerify("AC123", "token", "VA123")
+	gateway.BaseURL = ts.URL
+
+	a.NoError(gateway.SendCode("+14155550100"))
+	ok, err := gateway.CheckCode("+14155550100", "000000")
+	a.NoError(err)
+	a.False(ok)
+	ok, err = gateway.CheckCode("+14155550100", "123456")
+	a.NoError(err)
+	a.True(ok)
+
+	// no pending verification
+	ok, err = gateway.CheckCode("+14155550199", "123456")
+	a.NoError(err)
+	a.False(ok)
+
+	gateway.AuthToken = "wrong"
+	err = gateway.SendCode("+14155550100")
+	a.Error(err)
+	a.Equal(20003, err.(*smsotp.TwilioError).Code)
+}
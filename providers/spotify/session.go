@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with Spotify.
@@ -14,6 +15,7 @@ type Session struct {
 	AccessToken  string
 	RefreshToken string
 	ExpiresAt    time.Time
+	CodeVerifier string `json:",omitempty"`
 }
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the
@@ -29,7 +31,11 @@ func (s Session) GetAuthURL() (string, error) {
 // token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
+	var opts []oauth2.AuthCodeOption
+	if s.CodeVerifier != "" {
+		opts = append(opts, oauth2.VerifierOption(s.CodeVerifier))
+	}
+	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"), opts...)
 	if err != nil {
 		return "", err
 	}
@@ -50,9 +56,10 @@ func (s Session) Marshal() string {
 	return string(j)
 }
 
-// String is equivalent to Marshal.  It returns a JSON representation of the session.
+// String returns a JSON representation of the session, with its tokens
+// redacted.
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // UnmarshalSession will unmarshal a JSON string into a session.
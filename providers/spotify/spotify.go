@@ -3,9 +3,11 @@
 package spotify
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/andreimerlescu/goth"
@@ -70,6 +72,46 @@ const (
 	ScopeUserReadRecentlyPlayed = "user-read-recently-played"
 )
 
+// Scope presets group the scopes commonly requested together. They can be
+// passed straight to New, e.g. `spotify.New(key, secret, callback, spotify.ScopesPlayback...)`.
+var (
+	// ScopesProfile reads the user's email address and subscription details.
+	ScopesProfile = []string{ScopeUserReadEmail, ScopeUserReadPrivate}
+	// ScopesLibrary reads and modifies the user's "Your Music" library.
+	ScopesLibrary = []string{ScopeUserLibraryRead, ScopeUserLibraryModify}
+	// ScopesPlaylists reads and modifies all of the user's playlists.
+	ScopesPlaylists = []string{
+		ScopePlaylistReadPrivate,
+		ScopePlaylistReadCollaborative,
+		ScopePlaylistModifyPublic,
+		ScopePlaylistModifyPrivate,
+	}
+	// ScopesPlayback reads and controls the user's player.
+	ScopesPlayback = []string{
+		ScopeUserReadPlaybackState,
+		ScopeUserModifyPlaybackState,
+		ScopeUserReadCurrentlyPlaying,
+		ScopeStreaming,
+	}
+	// ScopesListeningHistory reads the user's top and recently played items.
+	ScopesListeningHistory = []string{ScopeUserTopRead, ScopeUserReadRecentlyPlayed}
+)
+
+// Product tiers reported for a user. The product is only returned when the
+// user-read-private scope was granted.
+const (
+	ProductPremium = "premium"
+	ProductFree    = "free"
+	ProductOpen    = "open"
+)
+
+// Image is a user's profile image in one of the sizes Spotify serves.
+type Image struct {
+	URL    string `json:"url"`
+	Height int    `json:"height"`
+	Width  int    `json:"width"`
+}
+
 // New creates a new Spotify provider and sets up important connection details.
 // You should always call `spotify.New` to get a new Provider.  Never try to
 // create one manually.
@@ -84,6 +126,15 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 	return p
 }
 
+// NewPKCE creates a new Spotify provider for public clients, such as mobile
+// or single page apps, that cannot keep a client secret. The authorization
+// code is protected with PKCE instead.
+func NewPKCE(clientKey, callbackURL string, scopes ...string) *Provider {
+	p := New(clientKey, "", callbackURL, scopes...)
+	p.pkce = true
+	return p
+}
+
 // Provider is the implementation of `goth.Provider` for accessing Spotify.
 type Provider struct {
 	ClientKey    string
@@ -92,6 +143,7 @@ type Provider struct {
 	HTTPClient   *http.Client
 	config       *oauth2.Config
 	providerName string
+	pkce         bool
 }
 
 // Name gets the name used to retrieve this provider.
@@ -104,6 +156,17 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -111,12 +174,26 @@ func (p *Provider) Client() *http.Client {
 // Debug is a no-op for the spotify package.
 func (p *Provider) Debug(debug bool) {}
 
+// SetPKCE enables or disables PKCE. It is enabled by NewPKCE, but can also be
+// used by confidential clients as an extra protection of the authorization code.
+// See https://developer.spotify.com/documentation/web-api/tutorials/code-pkce-flow
+func (p *Provider) SetPKCE(enabled bool) {
+	p.pkce = enabled
+}
+
 // BeginAuth asks Spotify for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	url := p.config.AuthCodeURL(state)
-	session := &Session{
-		AuthURL: url,
+	session := &Session{}
+	if p.pkce {
+		verifier, err := goth.GenerateVerifier()
+		if err != nil {
+			return nil, err
+		}
+		session.CodeVerifier = verifier
+		session.AuthURL = p.config.AuthCodeURL(state, oauth2.S256ChallengeOption(session.CodeVerifier))
+		return session, nil
 	}
+	session.AuthURL = p.config.AuthCodeURL(state)
 	return session, nil
 }
 
@@ -150,20 +227,41 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
 	}
 
-	// err = userFromReader(io.TeeReader(resp.Body, os.Stdout), &user)
-	err = userFromReader(resp.Body, &user)
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+
+	err = userFromReader(bytes.NewReader(bits), &user)
 	return user, err
 }
 
+// Product returns the subscription tier (premium, free or open) of a user
+// fetched by this provider.
+func Product(user goth.User) string {
+	product, _ := user.RawData["product"].(string)
+	return product
+}
+
+// Images returns every size of the profile image of a user fetched by this
+// provider.
+func Images(user goth.User) []Image {
+	images, _ := user.RawData["images"].([]Image)
+	return images
+}
+
 func userFromReader(r io.Reader, user *goth.User) error {
 	u := struct {
-		Country     string `json:"country"`
-		DisplayName string `json:"display_name"`
-		Email       string `json:"email"`
-		ID          string `json:"id"`
-		Images      []struct {
-			URL string `json:"url"`
-		} `json:"images"`
+		Country     string  `json:"country"`
+		DisplayName string  `json:"display_name"`
+		Email       string  `json:"email"`
+		ID          string  `json:"id"`
+		Images      []Image `json:"images"`
 	}{}
 
 	err := json.NewDecoder(r).Decode(&u)
@@ -172,11 +270,21 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	}
 
 	user.Name = u.DisplayName
+	user.NickName = u.DisplayName
 	user.Email = u.Email
 	user.UserID = u.ID
 	user.Location = u.Country
-	if len(u.Images) > 0 {
-		user.AvatarURL = u.Images[0].URL
+	// Spotify doesn't guarantee the order of the images, so use the largest
+	// one as the avatar.
+	width := -1
+	for _, image := range u.Images {
+		if image.Width > width {
+			user.AvatarURL = image.URL
+			width = image.Width
+		}
+	}
+	if user.RawData != nil {
+		user.RawData["images"] = u.Images
 	}
 	return nil
 }
@@ -1,6 +1,13 @@
 package spotify_test
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 
@@ -40,6 +47,71 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "accounts.spotify.com/authorize")
 }
 
+func Test_BeginAuthPKCE(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := spotify.NewPKCE(os.Getenv("SPOTIFY_KEY"), "/foo", spotify.ScopesPlayback...)
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*spotify.Session)
+	a.NotEmpty(s.CodeVerifier)
+	a.Contains(s.AuthURL, "code_challenge_method=S256")
+	a.Contains(s.AuthURL, "code_challenge=")
+	a.Contains(s.AuthURL, "scope=user-read-email+user-read-private+user-read-playback-state+user-modify-playback-state+user-read-currently-playing+streaming")
+
+	session, err = provider().BeginAuth("test_state")
+	a.NoError(err)
+	a.Empty(session.(*spotify.Session).CodeVerifier)
+	a.NotContains(session.(*spotify.Session).AuthURL, "code_challenge")
+}
+
+func Test_AuthorizeAndFetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/token":
+			if req.FormValue("code_verifier") != "verifier" {
+				res.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			res.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(res, `{"access_token":"1234567890","token_type":"Bearer","expires_in":3600,"refresh_token":"refresh"}`)
+		case "/v1/me":
+			fmt.Fprint(res, `{"country":"SE","display_name":"John Doe","email":"john@doe.com","id":"johndoe","product":"premium","images":[{"url":"https://i.scdn.co/image/small","height":64,"width":64},{"url":"https://i.scdn.co/image/large","height":300,"width":300}]}`)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := spotify.NewPKCE("key", "/foo")
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	s := &spotify.Session{CodeVerifier: "verifier"}
+	_, err := s.Authorize(p, url.Values{"code": {"code"}})
+	a.NoError(err)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("johndoe", user.UserID)
+	a.Equal("John Doe", user.Name)
+	a.Equal("john@doe.com", user.Email)
+	a.Equal("SE", user.Location)
+	a.Equal("https://i.scdn.co/image/large", user.AvatarURL)
+	a.Equal(spotify.ProductPremium, spotify.Product(user))
+	a.Len(spotify.Images(user), 2)
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -4,8 +4,6 @@ package steam
 import (
 	"encoding/json"
 	"errors"
-	"io/ioutil"
-	"net/url"
 	"regexp"
 	"strings"
 
@@ -31,53 +29,19 @@ func (s Session) GetAuthURL() (string, error) {
 // Authorize the session with Steam and return the unique response_nonce by OpenID.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	if params.Get("openid.mode") != "id_res" {
-		return "", errors.New("Mode must equal to \"id_res\".")
-	}
-
-	if params.Get("openid.return_to") != s.CallbackURL {
-		return "", errors.New("The \"return_to url\" must match the url of current request.")
-	}
-
-	v := make(url.Values)
-	v.Set("openid.assoc_handle", params.Get("openid.assoc_handle"))
-	v.Set("openid.signed", params.Get("openid.signed"))
-	v.Set("openid.sig", params.Get("openid.sig"))
-	v.Set("openid.ns", params.Get("openid.ns"))
-
-	split := strings.Split(params.Get("openid.signed"), ",")
-	for _, item := range split {
-		v.Set("openid."+item, params.Get("openid."+item))
-	}
-	v.Set("openid.mode", "check_authentication")
-
-	resp, err := p.Client().PostForm(apiLoginEndpoint, v)
+	a, err := p.relyingParty().Verify(params, s.CallbackURL, endpoint)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-	content, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	response := strings.Split(string(content), "\n")
-	if response[0] != "ns:"+openIDNs {
-		return "", errors.New("Wrong ns in the response.")
-	}
-
-	if response[1] == "is_valid:false" {
-		return "", errors.New("Unable validate openId.")
-	}
 
-	openIDURL := params.Get("openid.claimed_id")
+	openIDURL := a.ClaimedID
 	validationRegExp := regexp.MustCompile("^(http|https)://steamcommunity.com/openid/id/[0-9]{15,25}$")
 	if !validationRegExp.MatchString(openIDURL) {
 		return "", errors.New("Invalid Steam ID pattern.")
 	}
 
 	s.SteamID = regexp.MustCompile("\\D+").ReplaceAllString(openIDURL, "")
-	s.ResponseNonce = params.Get("openid.response_nonce")
+	s.ResponseNonce = a.ResponseNonce
 
 	return s.ResponseNonce, nil
 }
@@ -89,7 +53,7 @@ func (s Session) Marshal() string {
 }
 
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // UnmarshalSession will unmarshal a JSON string into a session.
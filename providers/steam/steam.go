@@ -9,6 +9,7 @@ import (
 	"net/url"
 
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/openid2"
 	"golang.org/x/oauth2"
 )
 
@@ -16,13 +17,16 @@ const (
 	// Steam API Endpoints
 	apiLoginEndpoint       = "https://steamcommunity.com/openid/login"
 	apiUserSummaryEndpoint = "http://api.steampowered.com/ISteamUser/GetPlayerSummaries/v0002/?key=%s&steamids=%s"
-
-	// OpenID settings
-	openIDMode       = "checkid_setup"
-	openIDNs         = "http://specs.openid.net/auth/2.0"
-	openIDIdentifier = "http://specs.openid.net/auth/2.0/identifier_select"
 )
 
+// endpoint is the OpenID provider of Steam, which is trusted to assert the
+// identifiers of Steam users.
+var endpoint = &openid2.Endpoint{
+	URL:           apiLoginEndpoint,
+	ClaimedID:     openid2.IdentifierSelect,
+	Authoritative: true,
+}
+
 // New creates a new Steam provider, and sets up important connection details.
 // You should always call `steam.New` to get a new Provider. Never try to create
 // one manually.
@@ -30,6 +34,7 @@ func New(apiKey string, callbackURL string) *Provider {
 	p := &Provider{
 		APIKey:       apiKey,
 		CallbackURL:  callbackURL,
+		Nonces:       openid2.NewMemoryNonceStore(),
 		providerName: "steam",
 	}
 	return p
@@ -37,9 +42,11 @@ func New(apiKey string, callbackURL string) *Provider {
 
 // Provider is the implementation of `goth.Provider` for accessing Steam
 type Provider struct {
-	APIKey       string
-	CallbackURL  string
-	HTTPClient   *http.Client
+	APIKey      string
+	CallbackURL string
+	HTTPClient  *http.Client
+	// Nonces rejects replayed Steam responses.
+	Nonces       openid2.NonceStore
 	providerName string
 }
 
@@ -53,6 +60,21 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Local reports that the provider is a goth.LocalProvider: the users are fetched with the API key of the application, not with a token.
+func (p *Provider) Local() bool {
+	return true
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -73,32 +95,24 @@ func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 // getAuthURL is an internal function to build the correct
 // authentication url to redirect the user to Steam.
 func (p *Provider) getAuthURL() (*url.URL, error) {
-	callbackURL, err := url.Parse(p.CallbackURL)
+	authURL, err := p.relyingParty().AuthURL(endpoint, p.CallbackURL, nil)
 	if err != nil {
 		return nil, err
 	}
+	return url.Parse(authURL)
+}
 
-	urlValues := map[string]string{
-		"openid.claimed_id": openIDIdentifier,
-		"openid.identity":   openIDIdentifier,
-		"openid.mode":       openIDMode,
-		"openid.ns":         openIDNs,
-		"openid.realm":      fmt.Sprintf("%s://%s", callbackURL.Scheme, callbackURL.Host),
-		"openid.return_to":  callbackURL.String(),
-	}
-
-	u, err := url.Parse(apiLoginEndpoint)
-	if err != nil {
-		return nil, err
+// relyingParty is the OpenID 2.0 relying party verifying Steam responses.
+func (p *Provider) relyingParty() *openid2.RelyingParty {
+	realm := ""
+	if callbackURL, err := url.Parse(p.CallbackURL); err == nil {
+		realm = fmt.Sprintf("%s://%s", callbackURL.Scheme, callbackURL.Host)
 	}
-
-	v := u.Query()
-	for key, value := range urlValues {
-		v.Set(key, value)
+	return &openid2.RelyingParty{
+		Realm:      realm,
+		HTTPClient: p.HTTPClient,
+		Nonces:     p.Nonces,
 	}
-	u.RawQuery = v.Encode()
-
-	return u, nil
 }
 
 // FetchUser will go to Steam and access basic info about the user.
@@ -15,6 +15,7 @@ type Session struct {
 	AccessToken  string
 	RefreshToken string
 	ExpiresAt    time.Time
+	Scope        string `json:",omitempty"`
 }
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the Strava provider.
@@ -40,9 +41,23 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
+	// the athlete can decline scopes, Strava reports the granted ones
+	// comma separated on the callback
+	s.Scope = params.Get("scope")
 	return token.AccessToken, err
 }
 
+// HasScope reports whether the athlete granted the given scope when
+// authorizing the application.
+func (s Session) HasScope(scope string) bool {
+	for _, granted := range strings.Split(s.Scope, ",") {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
 // Marshal the session into a string
 func (s Session) Marshal() string {
 	b, _ := json.Marshal(s)
@@ -50,7 +65,7 @@ func (s Session) Marshal() string {
 }
 
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // UnmarshalSession will unmarshal a JSON string into a session.
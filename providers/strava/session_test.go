@@ -46,3 +46,13 @@ func Test_String(t *testing.T) {
 
 	a.Equal(s.String(), s.Marshal())
 }
+
+func Test_HasScope(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	s := &strava.Session{Scope: "read,activity:read_all"}
+	a.True(s.HasScope(strava.ScopeRead))
+	a.True(s.HasScope(strava.ScopeActivityReadAll))
+	a.False(s.HasScope(strava.ScopeActivityWrite))
+}
@@ -9,8 +9,9 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/andreimerlescu/goth"
 	"golang.org/x/oauth2"
@@ -22,6 +23,25 @@ const (
 	endpointProfile string = "https://www.strava.com/api/v3/athlete"
 )
 
+// These are the scopes an application can request from Strava. Scopes are
+// sent comma separated, and the athlete may decline some of them on the
+// consent screen, see Session.Scope for the scopes which were granted.
+// See https://developers.strava.com/docs/authentication/#details-about-requesting-access
+const (
+	ScopeRead            string = "read"
+	ScopeReadAll         string = "read_all"
+	ScopeProfileReadAll  string = "profile:read_all"
+	ScopeProfileWrite    string = "profile:write"
+	ScopeActivityRead    string = "activity:read"
+	ScopeActivityReadAll string = "activity:read_all"
+	ScopeActivityWrite   string = "activity:write"
+)
+
+// expiryDelta refreshes tokens before they expire. Strava access tokens last
+// six hours, and Strava only hands out a new one once the current token
+// expires within the next hour.
+const expiryDelta = time.Hour
+
 // New creates a new Strava provider, and sets up important connection details.
 // You should always call `strava.New` to get a new Provider. Never try to create
 // one manually.
@@ -56,6 +76,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 // Client returns an HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
@@ -88,10 +118,21 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	reqUrl := fmt.Sprint(endpointProfile,
-		"?access_token=", url.QueryEscape(sess.AccessToken),
-	)
-	response, err := p.Client().Get(reqUrl)
+	// refresh the access token when it expired since the session was stored
+	token, err := p.TokenSource(sess).Token()
+	if err != nil {
+		return user, err
+	}
+	user.AccessToken = sess.AccessToken
+	user.RefreshToken = sess.RefreshToken
+	user.ExpiresAt = sess.ExpiresAt
+
+	req, err := http.NewRequest("GET", endpointProfile, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	response, err := p.Client().Do(req)
 	if err != nil {
 		return user, err
 	}
@@ -134,7 +175,7 @@ func userFromReader(reader io.Reader, user *goth.User) error {
 	}
 
 	user.UserID = fmt.Sprintf("%d", u.ID)
-	user.Name = fmt.Sprintf("%s %s", u.FirstName, u.LastName)
+	user.Name = strings.TrimSpace(u.FirstName + " " + u.LastName)
 	user.FirstName = u.FirstName
 	user.LastName = u.LastName
 	user.NickName = u.Username
@@ -145,6 +186,48 @@ func userFromReader(reader io.Reader, user *goth.User) error {
 	return err
 }
 
+// TokenSource returns a token source for the tokens of the session, which
+// refreshes the access token before it expires. Strava may return a new
+// refresh token with every refresh and invalidates the old one, so the
+// refreshed tokens are written back to the session, which has to be stored
+// again afterwards.
+func (p *Provider) TokenSource(session *Session) oauth2.TokenSource {
+	token := &oauth2.Token{
+		AccessToken:  session.AccessToken,
+		RefreshToken: session.RefreshToken,
+		Expiry:       session.ExpiresAt,
+	}
+	// the config's token source only holds the refresh token, so it refreshes
+	// whenever the reused token is about to expire
+	refresher := p.config.TokenSource(goth.ContextForClient(p.Client()), &oauth2.Token{RefreshToken: session.RefreshToken})
+	return &sessionTokenSource{
+		session: session,
+		source:  oauth2.ReuseTokenSourceWithExpiry(token, refresher, expiryDelta),
+	}
+}
+
+type sessionTokenSource struct {
+	mu      sync.Mutex
+	session *Session
+	source  oauth2.TokenSource
+}
+
+func (ts *sessionTokenSource) Token() (*oauth2.Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	token, err := ts.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token.AccessToken != ts.session.AccessToken {
+		ts.session.AccessToken = token.AccessToken
+		ts.session.RefreshToken = token.RefreshToken
+		ts.session.ExpiresAt = token.Expiry
+	}
+	return token, nil
+}
+
 func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 	c := &oauth2.Config{
 		ClientID:     provider.ClientKey,
@@ -153,6 +236,8 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  authURL,
 			TokenURL: tokenURL,
+			// Strava expects the client credentials in the request body
+			AuthStyle: oauth2.AuthStyleInParams,
 		},
 		Scopes: []string{},
 	}
@@ -160,18 +245,19 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 	if len(scopes) > 0 {
 		c.Scopes = []string{strings.Join(scopes, ",")}
 	} else {
-		c.Scopes = []string{"read"}
+		c.Scopes = []string{ScopeRead}
 	}
 
 	return c
 }
 
-// RefreshTokenAvailable refresh token is not provided by Strava
+// RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
 	return true
 }
 
-// RefreshToken refresh token is not provided by Strava
+// RefreshToken get new access token based on the refresh token. Strava may
+// rotate refresh tokens, store the refresh token that is returned.
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
 	token := &oauth2.Token{RefreshToken: refreshToken}
 	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
@@ -1,9 +1,15 @@
 package strava_test
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/andreimerlescu/goth"
 	"github.com/andreimerlescu/goth/providers/strava"
@@ -41,6 +47,76 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "scope=read")
 }
 
+func Test_BeginAuthWithScopes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := strava.New(os.Getenv("STRAVA_KEY"), os.Getenv("STRAVA_SECRET"), "/foo", strava.ScopeRead, strava.ScopeActivityReadAll)
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*strava.Session)
+	a.Contains(s.AuthURL, "scope=read%2Cactivity%3Aread_all")
+}
+
+func Test_FetchUserRefreshesExpiredToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	refreshes := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/oauth/token":
+			if req.FormValue("grant_type") != "refresh_token" || req.FormValue("refresh_token") != "refresh-1" || req.FormValue("client_id") != "client-id" {
+				res.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			refreshes++
+			res.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(res, `{"token_type":"Bearer","access_token":"access-2","expires_at":1568775134,"expires_in":21600,"refresh_token":"refresh-2"}`)
+		case "/api/v3/athlete":
+			if req.Header.Get("Authorization") != "Bearer access-2" {
+				res.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(res, `{"id":1234567890987654400,"username":"marianne_t","firstname":"Marianne","lastname":"Teutenberg","city":"San Francisco","state":"CA","country":"US","sex":"F","profile":"https://example.com/large.jpg"}`)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := strava.New("client-id", "client-secret", "/foo")
+	provider.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	// tokens expiring within the next hour are refreshed
+	s := &strava.Session{AccessToken: "access-1", RefreshToken: "refresh-1", ExpiresAt: time.Now().Add(30 * time.Minute)}
+	user, err := provider.FetchUser(s)
+	a.NoError(err)
+	a.Equal(1, refreshes)
+	a.Equal("access-2", s.AccessToken)
+	a.Equal("refresh-2", s.RefreshToken)
+	a.Equal("access-2", user.AccessToken)
+	a.Equal("refresh-2", user.RefreshToken)
+	a.True(user.ExpiresAt.After(time.Now().Add(5 * time.Hour)))
+
+	a.Equal("1234567890987654400", user.UserID)
+	a.Equal("Marianne Teutenberg", user.Name)
+	a.Equal("marianne_t", user.NickName)
+	a.Equal("https://example.com/large.jpg", user.AvatarURL)
+
+	// the refreshed token is reused
+	_, err = provider.FetchUser(s)
+	a.NoError(err)
+	a.Equal(1, refreshes)
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
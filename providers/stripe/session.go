@@ -16,6 +16,12 @@ type Session struct {
 	RefreshToken string
 	ExpiresAt    time.Time
 	ID           string
+
+	// Livemode, Scope and StripePublishableKey are returned alongside the
+	// connected account's ID when the code is exchanged.
+	Livemode             bool   `json:",omitempty"`
+	Scope                string `json:",omitempty"`
+	StripePublishableKey string `json:",omitempty"`
 }
 
 var _ goth.Session = &Session{}
@@ -43,7 +49,14 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
-	s.ID = token.Extra("stripe_user_id").(string) // Required to get the user info from sales force
+	id, ok := token.Extra("stripe_user_id").(string) // Required to get the connected account info from Stripe
+	if !ok {
+		return "", errors.New("stripe: token response did not include a stripe_user_id")
+	}
+	s.ID = id
+	s.Livemode, _ = token.Extra("livemode").(bool)
+	s.Scope, _ = token.Extra("scope").(string)
+	s.StripePublishableKey, _ = token.Extra("stripe_publishable_key").(string)
 	return token.AccessToken, err
 }
 
@@ -54,7 +67,7 @@ func (s Session) Marshal() string {
 }
 
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // UnmarshalSession wil unmarshal a JSON string into a session.
@@ -3,9 +3,11 @@
 package stripe
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/andreimerlescu/goth"
@@ -18,6 +20,12 @@ const (
 	endPointAccount string = "https://api.stripe.com/v1/accounts/"
 )
 
+// Scopes a platform can request for a connected Standard account.
+const (
+	ScopeReadOnly  string = "read_only"
+	ScopeReadWrite string = "read_write"
+)
+
 // Provider is the implementation of `goth.Provider` for accessing Stripe.
 type Provider struct {
 	ClientKey    string
@@ -52,6 +60,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -85,7 +103,13 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	if err != nil {
 		return user, err
 	}
-	req.Header.Set("Authorization", "Bearer "+s.AccessToken)
+	// Stripe recommends reading connected accounts with the platform's own
+	// secret key rather than the connected account's access token.
+	apiKey := p.Secret
+	if apiKey == "" {
+		apiKey = s.AccessToken
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
 	resp, err := p.Client().Do(req)
 	if err != nil {
 		if resp != nil {
@@ -99,11 +123,41 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
 	}
 
-	err = userFromReader(resp.Body, &user)
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+
+	// expose what the token exchange told us about the connection
+	user.RawData["stripe_user_id"] = s.ID
+	user.RawData["livemode"] = s.Livemode
+	user.RawData["scope"] = s.Scope
+	user.RawData["stripe_publishable_key"] = s.StripePublishableKey
+
+	err = userFromReader(bytes.NewReader(bits), &user)
 
 	return user, err
 }
 
+// Livemode reports whether the connection of a user fetched by this provider
+// was made in live mode rather than test mode.
+func Livemode(user goth.User) bool {
+	livemode, _ := user.RawData["livemode"].(bool)
+	return livemode
+}
+
+// Scope returns the scope granted to the platform by the connected account of a
+// user fetched by this provider, either ScopeReadOnly or ScopeReadWrite.
+func Scope(user goth.User) string {
+	scope, _ := user.RawData["scope"].(string)
+	return scope
+}
+
 func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 	c := &oauth2.Config{
 		ClientID:     provider.ClientKey,
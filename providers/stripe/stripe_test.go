@@ -1,6 +1,13 @@
 package stripe_test
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 
@@ -35,6 +42,54 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "connect.stripe.com/oauth/authorize")
 }
 
+func Test_AuthorizeAndFetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/oauth/token":
+			res.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(res, `{"access_token":"sk_test_connected","livemode":false,"refresh_token":"rt_123","token_type":"bearer","stripe_publishable_key":"pk_test_connected","stripe_user_id":"acct_1032D82eZvKYlo2C","scope":"read_write"}`)
+		case "/v1/accounts/acct_1032D82eZvKYlo2C":
+			if req.Header.Get("Authorization") != "Bearer sk_test_platform" {
+				res.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(res, `{"id":"acct_1032D82eZvKYlo2C","email":"site@stripe.com","display_name":"Stripe.com","support_address":{"city":"San Francisco"}}`)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := stripe.New("ca_platform", "sk_test_platform", "/foo", stripe.ScopeReadWrite)
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	s := &stripe.Session{}
+	_, err := s.Authorize(p, url.Values{"code": {"ac_123"}})
+	a.NoError(err)
+	a.Equal("acct_1032D82eZvKYlo2C", s.ID)
+	a.Equal("read_write", s.Scope)
+	a.False(s.Livemode)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("acct_1032D82eZvKYlo2C", user.UserID)
+	a.Equal("site@stripe.com", user.Email)
+	a.Equal("San Francisco", user.Location)
+	a.Equal("read_write", stripe.Scope(user))
+	a.False(stripe.Livemode(user))
+	a.Equal("pk_test_connected", user.RawData["stripe_publishable_key"])
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
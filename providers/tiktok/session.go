@@ -3,8 +3,6 @@ package tiktok
 import (
 	"encoding/json"
 	"errors"
-	"io/ioutil"
-	"net/http"
 	"net/url"
 	"time"
 
@@ -19,6 +17,7 @@ type Session struct {
 	OpenID           string
 	RefreshToken     string
 	RefreshExpiresAt time.Time
+	CodeVerifier     string `json:",omitempty"`
 }
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the TikTok provider.
@@ -29,8 +28,7 @@ func (s Session) GetAuthURL() (string, error) {
 	return s.AuthURL, nil
 }
 
-// Authorize the session with TikTok and return the access token to be stored for future use. Note that
-// we call the endpoints directly vs calling *oauth2.Config.Exchange() due to inconsistent TikTok param names.
+// Authorize the session with TikTok and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
 
@@ -42,54 +40,21 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	if p.config.RedirectURL != "" {
 		v.Set("redirect_uri", p.config.RedirectURL)
 	}
-
-	req, err := http.NewRequest(http.MethodPost, endpointToken, nil)
-	if err != nil {
-		return "", err
-	}
-	v.Add("client_key", p.config.ClientID)
-	v.Add("client_secret", p.config.ClientSecret)
-
-	req.URL.RawQuery = v.Encode()
-	response, err := p.GetClient().Do(req)
-	if err != nil {
-		return "", err
+	if s.CodeVerifier != "" {
+		v.Set("code_verifier", s.CodeVerifier)
 	}
 
-	tokenResp := struct {
-		Data struct {
-			OpenID           string `json:"open_id"`
-			Scope            string `json:"scope"`
-			AccessToken      string `json:"access_token"`
-			ExpiresIn        int64  `json:"expires_in"`
-			RefreshToken     string `json:"refresh_token"`
-			RefreshExpiresIn int64  `json:"refresh_expires_in"`
-		} `json:"data"`
-	}{}
-
-	// Get the body bytes in case we have to parse an error response
-	bodyBytes, err := ioutil.ReadAll(response.Body)
+	tokenResp, err := p.requestToken(v)
 	if err != nil {
 		return "", err
 	}
-	defer response.Body.Close()
-
-	err = json.Unmarshal(bodyBytes, &tokenResp)
-	if err != nil {
-		return "", err
-	}
-
-	// If we do not have an access token we assume we have an error response payload
-	if tokenResp.Data.AccessToken == "" {
-		return "", handleErrorResponse(bodyBytes)
-	}
 
 	// Create and Bind the Access Token
-	s.AccessToken = tokenResp.Data.AccessToken
-	s.ExpiresAt = time.Now().UTC().Add(time.Second * time.Duration(tokenResp.Data.ExpiresIn))
-	s.OpenID = tokenResp.Data.OpenID
-	s.RefreshToken = tokenResp.Data.RefreshToken
-	s.RefreshExpiresAt = time.Now().UTC().Add(time.Second * time.Duration(tokenResp.Data.RefreshExpiresIn))
+	s.AccessToken = tokenResp.AccessToken
+	s.ExpiresAt = goth.Now().UTC().Add(time.Second * time.Duration(tokenResp.ExpiresIn))
+	s.OpenID = tokenResp.OpenID
+	s.RefreshToken = tokenResp.RefreshToken
+	s.RefreshExpiresAt = goth.Now().UTC().Add(time.Second * time.Duration(tokenResp.RefreshExpiresIn))
 	return s.AccessToken, nil
 }
 
@@ -100,5 +65,5 @@ func (s Session) Marshal() string {
 }
 
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
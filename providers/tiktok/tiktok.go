@@ -1,9 +1,11 @@
-// Package tiktok implements the OAuth2 protocol for authenticating users through TikTok.
+// Package tiktok implements the OAuth2 protocol for authenticating users through TikTok Login Kit.
 // This package can be used as a reference implementation of an OAuth2 provider for Goth.
 package tiktok
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -17,15 +19,18 @@ import (
 	"golang.org/x/oauth2"
 )
 
+// See https://developers.tiktok.com/doc/login-kit-web
 const (
-	endpointAuth     = "https://open-api.tiktok.com/platform/oauth/connect/"
-	endpointToken    = "https://open-api.tiktok.com/oauth/access_token/"
-	endpointRefresh  = "https://open-api.tiktok.com/oauth/refresh_token/"
-	endpointUserInfo = "https://open-api.tiktok.com/oauth/userinfo/"
+	endpointAuth     = "https://www.tiktok.com/v2/auth/authorize/"
+	endpointToken    = "https://open.tiktokapis.com/v2/oauth/token/"
+	endpointUserInfo = "https://open.tiktokapis.com/v2/user/info/"
 
 	ScopeUserInfoBasic    = "user.info.basic"
+	ScopeUserInfoProfile  = "user.info.profile"
+	ScopeUserInfoStats    = "user.info.stats"
 	ScopeVideoList        = "video.list"
 	ScopeVideoUpload      = "video.upload"
+	ScopeVideoPublish     = "video.publish"
 	ScopeShareSoundCreate = "share.sound.create"
 )
 
@@ -37,9 +42,11 @@ type Provider struct {
 	ClientSecret string
 	config       *oauth2.Config
 	providerName string
+	pkce         bool
 }
 
 // New creates a new TikTok provider, and sets up connection details.
+// TikTok calls the client ID of an app its client key.
 func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 	p := &Provider{
 		ClientKey:    clientKey,
@@ -61,16 +68,35 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// GetClient returns an HTTP client to be used in all fetch operations.
 func (p *Provider) GetClient() *http.Client {
 	return goth.HTTPClientWithFallBack(p.Client)
 }
 
+// SetPKCE enables or disables PKCE, which TikTok requires for desktop apps.
+// See https://developers.tiktok.com/doc/login-kit-desktop
+func (p *Provider) SetPKCE(enabled bool) {
+	p.pkce = enabled
+}
+
 // Debug TODO
 func (p *Provider) Debug(debug bool) {}
 
 // BeginAuth asks TikTok for an authentication end-point. Note that we create our own URL string instead
 // of calling oauth2.AuthCodeURL() due to TikTok param name requirements.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	session := &Session{}
+
 	var buf bytes.Buffer
 	buf.WriteString(p.config.Endpoint.AuthURL)
 	v := url.Values{
@@ -88,15 +114,31 @@ func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 		v.Set("scope", strings.Join(p.config.Scopes, ","))
 	}
 
+	if p.pkce {
+		verifier, err := goth.GenerateVerifier()
+		if err != nil {
+			return nil, err
+		}
+		session.CodeVerifier = verifier
+		v.Set("code_challenge", codeChallenge(session.CodeVerifier))
+		v.Set("code_challenge_method", "S256")
+	}
+
 	if strings.Contains(p.config.Endpoint.AuthURL, "?") {
 		buf.WriteByte('&')
 	} else {
 		buf.WriteByte('?')
 	}
 	buf.WriteString(v.Encode())
-	return &Session{
-		AuthURL: buf.String(),
-	}, nil
+	session.AuthURL = buf.String()
+	return session, nil
+}
+
+// codeChallenge derives the PKCE code challenge of a verifier. Unlike RFC 7636,
+// TikTok expects the SHA256 hash to be hex encoded.
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return hex.EncodeToString(sum[:])
 }
 
 // FetchUser will go to TikTok and access basic information about the user.
@@ -115,32 +157,62 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken and userID", p.providerName)
 	}
 
-	// Set up the url params to post to get a new access token from a code
-	v := url.Values{
-		"access_token": {user.AccessToken},
-		"open_id":      {user.UserID},
+	fields := []string{"open_id", "union_id", "avatar_url", "display_name"}
+	for _, scope := range p.config.Scopes {
+		if scope == ScopeUserInfoProfile {
+			fields = append(fields, "username", "bio_description", "profile_deep_link", "is_verified")
+		}
 	}
-	response, err := p.GetClient().Get(endpointUserInfo + "?" + v.Encode())
+
+	req, err := http.NewRequest(http.MethodGet, endpointUserInfo+"?"+url.Values{"fields": {strings.Join(fields, ",")}}.Encode(), nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+user.AccessToken)
+	response, err := p.GetClient().Do(req)
+	if err != nil {
+		return user, err
+	}
+	defer response.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		return user, err
 	}
 
 	if response.StatusCode != http.StatusOK {
+		if err := handleErrorResponse(bodyBytes); err != nil {
+			return user, err
+		}
 		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
 	}
 
-	err = userFromReader(response.Body, &user)
-	response.Body.Close()
+	err = userFromReader(bytes.NewReader(bodyBytes), &user)
 	return user, err
 }
 
+// UnionID returns the ID of a user fetched by this provider that is shared by
+// all apps of the same developer. The open_id in goth.User.UserID is unique per app.
+func UnionID(user goth.User) string {
+	unionID, _ := user.RawData["union_id"].(string)
+	return unionID
+}
+
 func userFromReader(reader io.Reader, user *goth.User) error {
 	u := struct {
 		Data struct {
-			OpenID      string `json:"open_id"`
-			Avatar      string `json:"avatar"`
-			DisplayName string `json:"display_name"`
+			User struct {
+				OpenID         string `json:"open_id"`
+				UnionID        string `json:"union_id"`
+				AvatarURL      string `json:"avatar_url"`
+				DisplayName    string `json:"display_name"`
+				Username       string `json:"username"`
+				BioDescription string `json:"bio_description"`
+			} `json:"user"`
 		} `json:"data"`
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
 	}{}
 
 	bodyBytes, err := ioutil.ReadAll(reader)
@@ -152,18 +224,33 @@ func userFromReader(reader io.Reader, user *goth.User) error {
 	if err != nil {
 		return err
 	}
-	user.AvatarURL = u.Data.Avatar
-	user.Name = u.Data.DisplayName
-	user.NickName = u.Data.DisplayName
 
-	// On no display name, we assume an error response. TikTok returns error codes and descriptions inside
-	// the same struct/body. Sigh...refer https://developers.tiktok.com/doc/login-kit-user-info-basic
-	if user.Name == "" {
+	// TikTok returns error codes and descriptions inside the same body as the data,
+	// refer https://developers.tiktok.com/doc/tiktok-api-v2-error-handling
+	if u.Error.Code != "" && u.Error.Code != "ok" {
 		return handleErrorResponse(bodyBytes)
 	}
 
-	// Bind the all the bytes to the raw data returning err
-	return json.Unmarshal(bodyBytes, &user.RawData)
+	if u.Data.User.OpenID != "" {
+		user.UserID = u.Data.User.OpenID
+	}
+	user.AvatarURL = u.Data.User.AvatarURL
+	user.Name = u.Data.User.DisplayName
+	user.NickName = u.Data.User.DisplayName
+	if u.Data.User.Username != "" {
+		user.NickName = u.Data.User.Username
+	}
+	user.Description = u.Data.User.BioDescription
+
+	// Bind the user data to the raw data
+	raw := struct {
+		Data struct {
+			User map[string]interface{} `json:"user"`
+		} `json:"data"`
+	}{}
+	err = json.Unmarshal(bodyBytes, &raw)
+	user.RawData = raw.Data.User
+	return err
 }
 
 func newConfig(p *Provider, scopes []string) *oauth2.Config {
@@ -172,7 +259,8 @@ func newConfig(p *Provider, scopes []string) *oauth2.Config {
 		ClientSecret: p.ClientSecret,
 		RedirectURL:  p.CallbackURL,
 		Endpoint: oauth2.Endpoint{
-			AuthURL: endpointAuth,
+			AuthURL:  endpointAuth,
+			TokenURL: endpointToken,
 		},
 		Scopes: []string{ScopeUserInfoBasic},
 	}
@@ -187,63 +275,78 @@ func newConfig(p *Provider, scopes []string) *oauth2.Config {
 	return c
 }
 
-// RefreshToken will refresh a TikTok access token.
-func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	req, err := http.NewRequest(http.MethodPost, endpointRefresh, nil)
+// tokenResponse is the response of the token endpoint, both when exchanging a
+// code and when refreshing a token.
+type tokenResponse struct {
+	OpenID           string `json:"open_id"`
+	Scope            string `json:"scope"`
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in"`
+	TokenType        string `json:"token_type"`
+}
+
+// requestToken posts to the token endpoint. Note that we call the endpoint directly vs
+// using *oauth2.Config due to the TikTok client_key param name.
+func (p *Provider) requestToken(v url.Values) (*tokenResponse, error) {
+	v.Set("client_key", p.config.ClientID)
+	v.Set("client_secret", p.config.ClientSecret)
+
+	req, err := http.NewRequest(http.MethodPost, p.config.Endpoint.TokenURL, strings.NewReader(v.Encode()))
 	if err != nil {
 		return nil, err
 	}
-
-	// Set up the url params to post to get a new access token from a code
-	v := url.Values{
-		"client_key":    {p.config.ClientID},
-		"grant_type":    {"refresh_token"},
-		"refresh_token": {refreshToken},
-	}
-	req.URL.RawQuery = v.Encode()
-	refreshResponse, err := p.GetClient().Do(req)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := p.GetClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer response.Body.Close()
 
-	// We get the body bytes in case we need to parse an error response
-	bodyBytes, err := ioutil.ReadAll(refreshResponse.Body)
+	// Get the body bytes in case we have to parse an error response
+	bodyBytes, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		return nil, err
 	}
-	defer refreshResponse.Body.Close()
 
-	refresh := struct {
-		Data struct {
-			OpenID           string `json:"open_id"`
-			Scope            string `json:"scope"`
-			AccessToken      string `json:"access_token"`
-			ExpiresIn        int64  `json:"expires_in"`
-			RefreshToken     string `json:"refresh_token"`
-			RefreshExpiresIn int64  `json:"refresh_expires_in"`
-		} `json:"data"`
-	}{}
-	err = json.Unmarshal(bodyBytes, &refresh)
+	tokenResp := &tokenResponse{}
+	err = json.Unmarshal(bodyBytes, tokenResp)
 	if err != nil {
 		return nil, err
 	}
 
 	// If we do not have an access token we assume we have an error response payload
-	if refresh.Data.AccessToken == "" {
-		return nil, handleErrorResponse(bodyBytes)
+	if tokenResp.AccessToken == "" {
+		if err := handleErrorResponse(bodyBytes); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s responded with a %d without an access token", p.providerName, response.StatusCode)
+	}
+	return tokenResp, nil
+}
+
+// RefreshToken will refresh a TikTok access token.
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	refresh, err := p.requestToken(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	token := &oauth2.Token{
-		AccessToken:  refresh.Data.AccessToken,
+		AccessToken:  refresh.AccessToken,
 		TokenType:    "Bearer",
-		RefreshToken: refresh.Data.RefreshToken,
-		Expiry:       time.Now().Add(time.Second * time.Duration(refresh.Data.ExpiresIn)),
+		RefreshToken: refresh.RefreshToken,
+		Expiry:       goth.Now().Add(time.Second * time.Duration(refresh.ExpiresIn)),
 	}
 
 	tokenExtra := map[string]interface{}{
-		"open_id":            refresh.Data.OpenID,
-		"scope":              refresh.Data.Scope,
-		"refresh_expires_in": refresh.Data.RefreshExpiresIn,
+		"open_id":            refresh.OpenID,
+		"scope":              refresh.Scope,
+		"refresh_expires_in": refresh.RefreshExpiresIn,
 	}
 
 	return token.WithExtra(tokenExtra), nil
@@ -261,19 +364,30 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	return s, err
 }
 
+// handleErrorResponse returns the error described by a TikTok response body. The OAuth
+// endpoints and the API endpoints describe errors differently, so both are handled.
 func handleErrorResponse(data []byte) error {
-	errResp := struct {
-		Data struct {
-			Captcha     string `json:"captcha"`
-			DescURL     string `json:"desc_url"`
-			Description string `json:"description"`
-			ErrorCode   int    `json:"error_code"`
-		} `json:"data"`
-		Message string `json:"message"`
+	oauthErr := struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+		LogID            string `json:"log_id"`
 	}{}
-	if err := json.Unmarshal(data, &errResp); err != nil {
-		return err
+	if err := json.Unmarshal(data, &oauthErr); err == nil && oauthErr.Error != "" {
+		return fmt.Errorf("%s [%s]", oauthErr.ErrorDescription, oauthErr.Error)
 	}
 
-	return fmt.Errorf("%s [%d]", errResp.Data.Description, errResp.Data.ErrorCode)
+	apiErr := struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+			LogID   string `json:"log_id"`
+		} `json:"error"`
+	}{}
+	if err := json.Unmarshal(data, &apiErr); err != nil {
+		return err
+	}
+	if apiErr.Error.Code == "" || apiErr.Error.Code == "ok" {
+		return nil
+	}
+	return fmt.Errorf("%s [%s]", apiErr.Error.Message, apiErr.Error.Code)
 }
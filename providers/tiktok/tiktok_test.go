@@ -1,7 +1,15 @@
 package tiktok_test
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 
@@ -36,8 +44,90 @@ func Test_BeginAuth(t *testing.T) {
 	session, err := p.BeginAuth("test_state")
 	s := session.(*tiktok.Session)
 	a.NoError(err)
-	a.Contains(s.AuthURL, "https://open-api.tiktok.com/platform/oauth/connect")
+	a.Contains(s.AuthURL, "https://www.tiktok.com/v2/auth/authorize/")
+	a.Contains(s.AuthURL, "client_key=")
 	a.Contains(s.AuthURL, fmt.Sprintf("%s%%2C%s", tiktok.ScopeUserInfoBasic, tiktok.ScopeVideoList))
+	a.NotContains(s.AuthURL, "code_challenge")
+}
+
+func Test_BeginAuthPKCE(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	p.SetPKCE(true)
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*tiktok.Session)
+	a.NotEmpty(s.CodeVerifier)
+
+	sum := sha256.Sum256([]byte(s.CodeVerifier))
+	a.Contains(s.AuthURL, "code_challenge="+hex.EncodeToString(sum[:]))
+	a.Contains(s.AuthURL, "code_challenge_method=S256")
+}
+
+func Test_AuthorizeAndFetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/v2/oauth/token/":
+			if req.PostFormValue("client_key") != "key" || req.PostFormValue("client_secret") != "secret" {
+				fmt.Fprint(res, `{"error":"invalid_client","error_description":"Client key or secret is incorrect.","log_id":"1"}`)
+				return
+			}
+			if req.PostFormValue("code_verifier") != "verifier" {
+				fmt.Fprint(res, `{"error":"invalid_grant","error_description":"Code verifier is invalid.","log_id":"2"}`)
+				return
+			}
+			fmt.Fprint(res, `{"access_token":"act.1234","expires_in":86400,"open_id":"open-1","refresh_expires_in":31536000,"refresh_token":"rft.1234","scope":"user.info.basic,user.info.profile","token_type":"Bearer"}`)
+		case "/v2/user/info/":
+			if req.Header.Get("Authorization") != "Bearer act.1234" {
+				res.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(res, `{"error":{"code":"access_token_invalid","message":"The access token is invalid or not found in the request.","log_id":"3"}}`)
+				return
+			}
+			a.Contains(req.URL.Query().Get("fields"), "username")
+			fmt.Fprint(res, `{"data":{"user":{"open_id":"open-1","union_id":"union-1","avatar_url":"https://p16.tiktokcdn.com/avatar.jpeg","display_name":"Tik Toker","username":"tiktoker","bio_description":"creator"}},"error":{"code":"ok","message":"","log_id":"4"}}`)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := tiktok.New("key", "secret", callbackURL, tiktok.ScopeUserInfoProfile)
+	p.Client = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	s := &tiktok.Session{CodeVerifier: "wrong"}
+	_, err := s.Authorize(p, url.Values{"code": {"code"}})
+	a.EqualError(err, "Code verifier is invalid. [invalid_grant]")
+
+	s = &tiktok.Session{CodeVerifier: "verifier"}
+	_, err = s.Authorize(p, url.Values{"code": {"code"}})
+	a.NoError(err)
+	a.Equal("open-1", s.OpenID)
+	a.Equal("rft.1234", s.RefreshToken)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("open-1", user.UserID)
+	a.Equal("union-1", tiktok.UnionID(user))
+	a.Equal("Tik Toker", user.Name)
+	a.Equal("tiktoker", user.NickName)
+	a.Equal("creator", user.Description)
+	a.Equal("https://p16.tiktokcdn.com/avatar.jpeg", user.AvatarURL)
+
+	_, err = p.FetchUser(&tiktok.Session{AccessToken: "expired", OpenID: "open-1"})
+	a.EqualError(err, "The access token is invalid or not found in the request. [access_token_invalid]")
 }
 
 func Test_SessionFromJSON(t *testing.T) {
@@ -45,11 +135,11 @@ func Test_SessionFromJSON(t *testing.T) {
 	a := assert.New(t)
 
 	p := provider()
-	session, err := p.UnmarshalSession(`{"AuthURL":"https://open-api.tiktok.com/platform/oauth/connect","AccessToken":"1234567890"}"`)
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://www.tiktok.com/v2/auth/authorize/","AccessToken":"1234567890"}"`)
 	a.NoError(err)
 
 	s := session.(*tiktok.Session)
-	a.Equal(s.AuthURL, "https://open-api.tiktok.com/platform/oauth/connect")
+	a.Equal(s.AuthURL, "https://www.tiktok.com/v2/auth/authorize/")
 	a.Equal(s.AccessToken, "1234567890")
 }
 
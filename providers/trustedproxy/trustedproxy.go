@@ -0,0 +1,415 @@
+// Package trustedproxy authenticates the users signed in by an identity-aware
+// proxy in front of the application, e.g. oauth2-proxy, Cloudflare Access or
+// Pomerium, from the identity headers the proxy adds to the requests.
+//
+// The headers are only trusted once the JWT signed by the proxy is validated
+// with its published keys, or, for proxies which don't sign their headers,
+// when the request comes from the network of the proxy:
+//
+//	a := trustedproxy.NewCloudflareAccess("example.cloudflareaccess.com", audienceTag)
+//	http.Handle("/auth/proxy", a.Handler(func(res http.ResponseWriter, req *http.Request, user goth.User) {
+//		// sign the user in
+//	}))
+//
+// oauth2-proxy signs nothing unless it passes the ID token of the identity
+// provider with --pass-authorization-header, which New validates with the
+// keys of the identity provider. Otherwise use NewOAuth2Proxy with the
+// addresses of the proxy.
+package trustedproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+var (
+	// ErrNoIdentity is returned when the request has no identity header.
+	ErrNoIdentity = errors.New("trustedproxy: the request has no identity header")
+	// ErrUntrustedSource is returned for requests which didn't come through
+	// the trusted networks.
+	ErrUntrustedSource = errors.New("trustedproxy: the request didn't come from a trusted proxy")
+)
+
+// Headers are the names of the unsigned identity headers set by the proxy.
+type Headers struct {
+	UserID   string
+	Email    string
+	Name     string
+	NickName string
+	// Groups holds a comma separated list.
+	Groups      string
+	AccessToken string
+}
+
+// Authenticator reads the users from the identity headers of a proxy.
+type Authenticator struct {
+	// Header is the header of the JWT signed by the proxy, optionally with
+	// the Bearer scheme. The Headers are used when it is empty.
+	Header string
+	// KeysURL is the JSON Web Key Set of the keys signing the JWT.
+	KeysURL string
+	// Issuer and Audience of the JWT, checked when set.
+	Issuer   string
+	Audience string
+	// Algorithms are the accepted signature algorithms of the JWT.
+	Algorithms []string
+	ClockSkew  time.Duration
+
+	// The claims of the fields of the user, the first one found is used.
+	UserIDClaims   []string
+	EmailClaims    []string
+	NameClaims     []string
+	NickNameClaims []string
+	GroupsClaims   []string
+
+	Headers Headers
+	// TrustedNetworks are the networks of the proxy. They are required to
+	// trust unsigned Headers, and restrict the signed ones when set. The
+	// address of the connection is used, never the forwarded headers.
+	TrustedNetworks []*net.IPNet
+
+	HTTPClient   *http.Client
+	providerName string
+
+	mu        sync.Mutex
+	keys      jwk.Set
+	fetchedAt time.Time
+	now       func() time.Time
+}
+
+// New creates an Authenticator validating the JWT of the header, signed by
+// the keys of keysURL for the audience.
+func New(header, keysURL, issuer, audience string) *Authenticator {
+	return &Authenticator{
+		Header:         header,
+		KeysURL:        keysURL,
+		Issuer:         issuer,
+		Audience:       audience,
+		Algorithms:     []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "PS256"},
+		ClockSkew:      time.Minute,
+		UserIDClaims:   []string{"sub"},
+		EmailClaims:    []string{"email"},
+		NameClaims:     []string{"name"},
+		NickNameClaims: []string{"preferred_username", "user"},
+		GroupsClaims:   []string{"groups"},
+		providerName:   "trustedproxy",
+		now:            goth.Now,
+	}
+}
+
+// NewCloudflareAccess creates an Authenticator for the Cloudflare Access team
+// domain, e.g. "example.cloudflareaccess.com", and the Application Audience
+// tag of the application.
+func NewCloudflareAccess(teamDomain, audience string) *Authenticator {
+	issuer := "https://" + strings.TrimSuffix(strings.TrimPrefix(teamDomain, "https://"), "/")
+	a := New("Cf-Access-Jwt-Assertion", issuer+"/cdn-cgi/access/certs", issuer, audience)
+	a.Algorithms = []string{"RS256"}
+	a.SetName("cloudflareaccess")
+	return a
+}
+
+// NewPomerium creates an Authenticator for the Pomerium route of the
+// application, e.g. "https://app.example.com", whose host is the audience.
+func NewPomerium(routeURL string) *Authenticator {
+	routeURL = strings.TrimSuffix(routeURL, "/")
+	audience := strings.TrimPrefix(strings.TrimPrefix(routeURL, "https://"), "http://")
+	a := New("X-Pomerium-Jwt-Assertion", routeURL+"/.well-known/pomerium/jwks.json", "", audience)
+	a.Algorithms = []string{"ES256"}
+	a.SetName("pomerium")
+	return a
+}
+
+// NewOAuth2Proxy creates an Authenticator trusting the unsigned headers of
+// oauth2-proxy with --set-xauthrequest or --pass-user-headers, from the
+// networks of the proxy, e.g. "10.0.0.0/8" or "127.0.0.1".
+func NewOAuth2Proxy(trustedNetworks ...string) (*Authenticator, error) {
+	a := New("", "", "", "")
+	a.Headers = Headers{
+		UserID:      "X-Forwarded-User",
+		Email:       "X-Forwarded-Email",
+		NickName:    "X-Forwarded-Preferred-Username",
+		Groups:      "X-Forwarded-Groups",
+		AccessToken: "X-Forwarded-Access-Token",
+	}
+	a.SetName("oauth2proxy")
+	for _, n := range trustedNetworks {
+		network, err := ParseNetwork(n)
+		if err != nil {
+			return nil, err
+		}
+		a.TrustedNetworks = append(a.TrustedNetworks, network)
+	}
+	if len(a.TrustedNetworks) == 0 {
+		return nil, errors.New("trustedproxy: unsigned headers need the networks of the proxy")
+	}
+	return a, nil
+}
+
+// ParseNetwork parses a network in CIDR notation, or a single address.
+func ParseNetwork(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("trustedproxy: invalid address %q", s)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("trustedproxy: invalid network %q: %w", s, err)
+	}
+	return network, nil
+}
+
+// Name is the name set as the Provider of the users.
+func (a *Authenticator) Name() string {
+	return a.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (a *Authenticator) SetName(name string) {
+	a.providerName = name
+}
+
+func (a *Authenticator) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(a.HTTPClient)
+}
+
+// Authenticate returns the user signed in by the proxy.
+func (a *Authenticator) Authenticate(req *http.Request) (goth.User, error) {
+	if len(a.TrustedNetworks) > 0 && !a.trusted(req) {
+		return goth.User{}, ErrUntrustedSource
+	}
+	if a.Header == "" {
+		if len(a.TrustedNetworks) == 0 {
+			return goth.User{}, ErrUntrustedSource
+		}
+		return a.userFromHeaders(req)
+	}
+
+	token := strings.TrimSpace(req.Header.Get(a.Header))
+	if len(token) > 7 && strings.EqualFold(token[:7], "bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+	if token == "" {
+		return goth.User{}, ErrNoIdentity
+	}
+	claims, err := a.validate(token)
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	user := goth.User{
+		Provider:  a.Name(),
+		RawData:   claims,
+		UserID:    claimValue(claims, a.UserIDClaims),
+		Email:     claimValue(claims, a.EmailClaims),
+		Name:      claimValue(claims, a.NameClaims),
+		NickName:  claimValue(claims, a.NickNameClaims),
+		IDToken:   token,
+		ExpiresAt: claimTime(claims, "exp"),
+	}
+	if user.UserID == "" {
+		return goth.User{}, errors.New("trustedproxy: the JWT identifies no user")
+	}
+	return user, nil
+}
+
+func (a *Authenticator) userFromHeaders(req *http.Request) (goth.User, error) {
+	get := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		return strings.TrimSpace(req.Header.Get(name))
+	}
+	user := goth.User{
+		Provider:    a.Name(),
+		UserID:      get(a.Headers.UserID),
+		Email:       get(a.Headers.Email),
+		Name:        get(a.Headers.Name),
+		NickName:    get(a.Headers.NickName),
+		AccessToken: get(a.Headers.AccessToken),
+		RawData:     map[string]interface{}{},
+	}
+	if user.UserID == "" {
+		user.UserID = user.Email
+	}
+	if user.UserID == "" {
+		return goth.User{}, ErrNoIdentity
+	}
+	if groups := get(a.Headers.Groups); groups != "" {
+		var list []interface{}
+		for _, g := range strings.Split(groups, ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				list = append(list, g)
+			}
+		}
+		user.RawData["groups"] = list
+	}
+	return user, nil
+}
+
+// Groups returns the groups of the user, from the groups claims or header.
+func Groups(user goth.User) []string {
+	var groups []string
+	if list, ok := user.RawData["groups"].([]interface{}); ok {
+		for _, g := range list {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+	return groups
+}
+
+// trusted reports whether the connection comes from the trusted networks.
+func (a *Authenticator) trusted(req *http.Request) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range a.TrustedNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks the signature and claims of the JWT.
+func (a *Authenticator) validate(token string) (jwt.MapClaims, error) {
+	if err := goth.CheckJWTAlgorithms(a.Algorithms...); err != nil {
+		return nil, err
+	}
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(a.Algorithms),
+		jwt.WithLeeway(a.ClockSkew),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(a.clock),
+	}
+	if a.Issuer != "" {
+		options = append(options, jwt.WithIssuer(a.Issuer))
+	}
+	if a.Audience != "" {
+		options = append(options, jwt.WithAudience(a.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return a.key(kid)
+	}, options...)
+	if err != nil {
+		return nil, fmt.Errorf("trustedproxy: invalid JWT: %w", err)
+	}
+	return claims, nil
+}
+
+func (a *Authenticator) clock() time.Time {
+	if a.now == nil {
+		return goth.Now()
+	}
+	return a.now()
+}
+
+// keysRefresh is how long the keys are used before they are fetched again.
+// Unknown keys are fetched at most once per keysRetry, for the proxies
+// rotating their keys.
+const (
+	keysRefresh = time.Hour
+	keysRetry   = time.Minute
+)
+
+// key returns the public key with the ID, fetching the keys when needed.
+func (a *Authenticator) key(kid string) (interface{}, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.clock()
+	k, found := lookupKey(a.keys, kid)
+	if a.keys == nil || now.Sub(a.fetchedAt) > keysRefresh || !found && now.Sub(a.fetchedAt) > keysRetry {
+		set, err := jwk.Fetch(context.Background(), a.KeysURL, jwk.WithHTTPClient(a.Client()))
+		if err != nil {
+			if found {
+				// keep using the known key while the proxy is unavailable
+				return rawKey(k)
+			}
+			return nil, fmt.Errorf("trustedproxy: could not fetch the keys: %w", err)
+		}
+		a.keys, a.fetchedAt = set, now
+		k, found = lookupKey(set, kid)
+	}
+	if !found {
+		return nil, fmt.Errorf("trustedproxy: unknown key %q", kid)
+	}
+	return rawKey(k)
+}
+
+func lookupKey(set jwk.Set, kid string) (jwk.Key, bool) {
+	if set == nil {
+		return nil, false
+	}
+	if kid == "" {
+		if set.Len() == 1 {
+			return set.Get(0)
+		}
+		return nil, false
+	}
+	return set.LookupKeyID(kid)
+}
+
+func rawKey(k jwk.Key) (interface{}, error) {
+	var raw interface{}
+	err := k.Raw(&raw)
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+func claimValue(claims map[string]interface{}, names []string) string {
+	for _, name := range names {
+		if v, ok := claims[name].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func claimTime(claims map[string]interface{}, name string) time.Time {
+	if v, ok := claims[name].(float64); ok {
+		return time.Unix(int64(v), 0)
+	}
+	return time.Time{}
+}
+
+// Handler returns a handler calling success with the user signed in by the
+// proxy. Requests without a valid identity get a 401 Unauthorized response.
+func (a *Authenticator) Handler(success func(res http.ResponseWriter, req *http.Request, user goth.User)) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		user, err := a.Authenticate(req)
+		if err != nil {
+			http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		success(res, req, user)
+	})
+}
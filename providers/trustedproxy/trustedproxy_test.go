@@ -0,0 +1,288 @@
+package trustedproxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/stretchr/testify/assert"
+)
+
+type keyServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    map[string]interface{}
+	fetches int
+}
+
+// newKeyServer serves the public keys in a Cloudflare style certs document,
+// with extra members besides the keys.
+func newKeyServer(t *testing.T, keys map[string]interface{}) *keyServer {
+	s := &keyServer{keys: keys}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetches++
+		set := jwk.NewSet()
+		for kid, private := range s.keys {
+			var public interface{}
+			switch k := private.(type) {
+			case *rsa.PrivateKey:
+				public = &k.PublicKey
+			case *ecdsa.PrivateKey:
+				public = &k.PublicKey
+			}
+			key, err := jwk.New(public)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = key.Set(jwk.KeyIDKey, kid)
+			set.Add(key)
+		}
+		b, _ := json.Marshal(set)
+		doc := map[string]interface{}{}
+		_ = json.Unmarshal(b, &doc)
+		doc["public_cert"] = map[string]string{"kid": "x", "cert": "-----BEGIN CERTIFICATE-----"}
+		_ = json.NewEncoder(res).Encode(doc)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *keyServer) setKey(kid string, key interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[kid] = key
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func Test_CloudflareAccess(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	server := newKeyServer(t, map[string]interface{}{"k1": rsaKey})
+
+	p := NewCloudflareAccess("example.cloudflareaccess.com", "aud-tag")
+	p.KeysURL = server.URL
+	a.Equal("cloudflareaccess", p.Name())
+	a.Equal("https://example.cloudflareaccess.com", p.Issuer)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	token := sign(t, jwt.SigningMethodRS256, "k1", rsaKey, jwt.MapClaims{
+		"iss":    "https://example.cloudflareaccess.com",
+		"aud":    []string{"aud-tag"},
+		"sub":    "7335d417-61da-459d-899c-0a01c76a2e31",
+		"email":  "homer@example.com",
+		"exp":    exp,
+		"groups": []string{"admins", "users"},
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Cf-Access-Jwt-Assertion", token)
+
+	user, err := p.Authenticate(req)
+	a.NoError(err)
+	a.Equal("cloudflareaccess", user.Provider)
+	a.Equal("7335d417-61da-459d-899c-0a01c76a2e31", user.UserID)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal(token, user.IDToken)
+	a.Equal(exp, user.ExpiresAt.Unix())
+	a.Equal([]string{"admins", "users"}, Groups(user))
+
+	// another audience
+	req.Header.Set("Cf-Access-Jwt-Assertion", sign(t, jwt.SigningMethodRS256, "k1", rsaKey, jwt.MapClaims{
+		"iss": "https://example.cloudflareaccess.com",
+		"aud": "other",
+		"sub": "1",
+		"exp": exp,
+	}))
+	_, err = p.Authenticate(req)
+	a.Error(err)
+
+	// expired
+	req.Header.Set("Cf-Access-Jwt-Assertion", sign(t, jwt.SigningMethodRS256, "k1", rsaKey, jwt.MapClaims{
+		"iss": "https://example.cloudflareaccess.com",
+		"aud": "aud-tag",
+		"sub": "1",
+		"exp": time.Now().Add(-time.Hour).Unix(),
+	}))
+	_, err = p.Authenticate(req)
+	a.Error(err)
+
+	// signed with another key
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	req.Header.Set("Cf-Access-Jwt-Assertion", sign(t, jwt.SigningMethodRS256, "k1", otherKey, jwt.MapClaims{
+		"iss": "https://example.cloudflareaccess.com",
+		"aud": "aud-tag",
+		"sub": "1",
+		"exp": exp,
+	}))
+	_, err = p.Authenticate(req)
+	a.Error(err)
+
+	req.Header.Del("Cf-Access-Jwt-Assertion")
+	_, err = p.Authenticate(req)
+	a.Equal(ErrNoIdentity, err)
+}
+
+func Test_Pomerium(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	server := newKeyServer(t, map[string]interface{}{"ec": ecKey})
+
+	p := NewPomerium("https://app.example.com/")
+	a.Equal("https://app.example.com/.well-known/pomerium/jwks.json", p.KeysURL)
+	a.Equal("app.example.com", p.Audience)
+	p.KeysURL = server.URL
+
+	claims := jwt.MapClaims{
+		"iss":    "authenticate.example.com",
+		"aud":    "app.example.com",
+		"sub":    "105843",
+		"user":   "homer",
+		"email":  "homer@example.com",
+		"name":   "Homer Simpson",
+		"exp":    time.Now().Add(time.Minute).Unix(),
+		"groups": []string{"springfield"},
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Pomerium-Jwt-Assertion", sign(t, jwt.SigningMethodES256, "ec", ecKey, claims))
+
+	user, err := p.Authenticate(req)
+	a.NoError(err)
+	a.Equal("105843", user.UserID)
+	a.Equal("homer", user.NickName)
+	a.Equal("Homer Simpson", user.Name)
+
+	// only ES256 is accepted
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	server.setKey("rsa", rsaKey)
+	req.Header.Set("X-Pomerium-Jwt-Assertion", sign(t, jwt.SigningMethodRS256, "rsa", rsaKey, claims))
+	_, err = p.Authenticate(req)
+	a.Error(err)
+}
+
+func Test_KeyRotation(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	server := newKeyServer(t, map[string]interface{}{"old": oldKey})
+
+	now := time.Now()
+	p := New("Authorization", server.URL, "", "")
+	p.now = func() time.Time { return now }
+
+	claims := jwt.MapClaims{"sub": "1", "exp": now.Add(time.Hour).Unix()}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodES256, "old", oldKey, claims))
+	_, err := p.Authenticate(req)
+	a.NoError(err)
+	_, err = p.Authenticate(req)
+	a.NoError(err)
+	a.Equal(1, server.fetches)
+
+	// the unknown key isn't fetched again right away
+	server.setKey("new", newKey)
+	req.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodES256, "new", newKey, claims))
+	_, err = p.Authenticate(req)
+	a.Error(err)
+	a.Equal(1, server.fetches)
+
+	now = now.Add(2 * time.Minute)
+	_, err = p.Authenticate(req)
+	a.NoError(err)
+	a.Equal(2, server.fetches)
+}
+
+func Test_OAuth2Proxy(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	_, err := NewOAuth2Proxy()
+	a.Error(err)
+	_, err = NewOAuth2Proxy("10.0.0.0/33")
+	a.Error(err)
+
+	p, err := NewOAuth2Proxy("10.0.0.0/8", "::1")
+	a.NoError(err)
+	a.Equal("oauth2proxy", p.Name())
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.1.2.3:51234"
+	req.Header.Set("X-Forwarded-User", "homer")
+	req.Header.Set("X-Forwarded-Email", "homer@example.com")
+	req.Header.Set("X-Forwarded-Preferred-Username", "hsimpson")
+	req.Header.Set("X-Forwarded-Groups", "admins, users")
+	req.Header.Set("X-Forwarded-Access-Token", "token")
+
+	user, err := p.Authenticate(req)
+	a.NoError(err)
+	a.Equal("homer", user.UserID)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("hsimpson", user.NickName)
+	a.Equal("token", user.AccessToken)
+	a.Equal([]string{"admins", "users"}, Groups(user))
+
+	req.RemoteAddr = "[::1]:51234"
+	_, err = p.Authenticate(req)
+	a.NoError(err)
+
+	// the forwarded addresses are not trusted
+	req.RemoteAddr = "192.0.2.1:51234"
+	req.Header.Set("X-Forwarded-For", "10.1.2.3")
+	_, err = p.Authenticate(req)
+	a.Equal(ErrUntrustedSource, err)
+
+	req.RemoteAddr = "10.1.2.3:51234"
+	req.Header.Del("X-Forwarded-User")
+	req.Header.Del("X-Forwarded-Email")
+	_, err = p.Authenticate(req)
+	a.Equal(ErrNoIdentity, err)
+}
+
+func Test_Handler(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p, _ := NewOAuth2Proxy("192.0.2.1")
+	h := p.Handler(func(res http.ResponseWriter, req *http.Request, user goth.User) {
+		_, _ = res.Write([]byte(user.UserID))
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-Email", "homer@example.com")
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	a.Equal(http.StatusOK, res.Code)
+	a.Equal("homer@example.com", res.Body.String())
+
+	req.RemoteAddr = "192.0.2.2:1234"
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	a.Equal(http.StatusUnauthorized, res.Code)
+}
@@ -4,16 +4,19 @@ import (
 	"encoding/json"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/andreimerlescu/goth"
-	"github.com/mrjones/oauth"
+	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with Twitter.
 type Session struct {
 	AuthURL      string
-	AccessToken  *oauth.AccessToken
-	RequestToken *oauth.RequestToken
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+	CodeVerifier string
 }
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the Twitter provider.
@@ -27,13 +30,19 @@ func (s Session) GetAuthURL() (string, error) {
 // Authorize the session with Twitter and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	accessToken, err := p.consumer.AuthorizeToken(s.RequestToken, params.Get("oauth_verifier"))
+	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"), oauth2.VerifierOption(s.CodeVerifier))
 	if err != nil {
 		return "", err
 	}
 
-	s.AccessToken = accessToken
-	return accessToken.Token, err
+	if !token.Valid() {
+		return "", errors.New("Invalid token received from provider")
+	}
+
+	s.AccessToken = token.AccessToken
+	s.RefreshToken = token.RefreshToken
+	s.ExpiresAt = token.Expiry
+	return token.AccessToken, err
 }
 
 // Marshal the session into a string
@@ -43,7 +52,7 @@ func (s Session) Marshal() string {
 }
 
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // UnmarshalSession will unmarshal a JSON string into a session.
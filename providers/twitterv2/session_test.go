@@ -36,7 +36,7 @@ func Test_ToJSON(t *testing.T) {
 	s := &twitterv2.Session{}
 
 	data := s.Marshal()
-	a.Equal(data, `{"AuthURL":"","AccessToken":null,"RequestToken":null}`)
+	a.Equal(data, `{"AuthURL":"","AccessToken":"","RefreshToken":"","ExpiresAt":"0001-01-01T00:00:00Z","CodeVerifier":""}`)
 }
 
 func Test_String(t *testing.T) {
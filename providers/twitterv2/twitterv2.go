@@ -1,55 +1,62 @@
-// Package twitterv2 implements the OAuth protocol for authenticating users through Twitter.
-// This package can be used as a reference implementation of an OAuth provider for Goth.
+// Package twitterv2 implements the OAuth 2.0 user-context flow for authenticating users through Twitter (X).
+// This package can be used as a reference implementation of an OAuth2 provider for Goth.
 package twitterv2
 
 import (
-	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/andreimerlescu/goth"
-	"github.com/mrjones/oauth"
 	"golang.org/x/oauth2"
 )
 
 var (
-	requestURL      = "https://api.twitter.com/oauth/request_token"
-	authorizeURL    = "https://api.twitter.com/oauth/authorize"
-	authenticateURL = "https://api.twitter.com/oauth/authenticate"
-	tokenURL        = "https://api.twitter.com/oauth/access_token"
+	authURL         = "https://twitter.com/i/oauth2/authorize"
+	tokenURL        = "https://api.twitter.com/2/oauth2/token"
 	endpointProfile = "https://api.twitter.com/2/users/me"
 )
 
+// Scopes
+// See https://developer.twitter.com/en/docs/authentication/oauth-2-0/authorization-code
+const (
+	ScopeTweetRead     string = "tweet.read"
+	ScopeTweetWrite    string = "tweet.write"
+	ScopeUsersRead     string = "users.read"
+	ScopeFollowsRead   string = "follows.read"
+	ScopeFollowsWrite  string = "follows.write"
+	ScopeLikeRead      string = "like.read"
+	ScopeLikeWrite     string = "like.write"
+	ScopeListRead      string = "list.read"
+	ScopeBookmarkRead  string = "bookmark.read"
+	ScopeOfflineAccess string = "offline.access"
+)
+
 // New creates a new Twitter provider, and sets up important connection details.
-// You should always call `twitter.New` to get a new Provider. Never try to create
+// You should always call `twitterv2.New` to get a new Provider. Never try to create
 // one manually.
 //
-// If you'd like to use authenticate instead of authorize, use NewAuthenticate instead.
-func New(clientKey, secret, callbackURL string) *Provider {
+// Every authorization is protected with PKCE, as Twitter mandates. Public clients
+// can pass an empty secret. Unless other scopes are requested, offline.access is
+// included so that the tokens can be refreshed.
+func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 	p := &Provider{
 		ClientKey:    clientKey,
 		Secret:       secret,
 		CallbackURL:  callbackURL,
 		providerName: "twitterv2",
 	}
-	p.consumer = newConsumer(p, authorizeURL)
+	p.config = newConfig(p, scopes)
 	return p
 }
 
-// NewAuthenticate is the almost same as New.
-// NewAuthenticate uses the authenticate URL instead of the authorize URL.
-func NewAuthenticate(clientKey, secret, callbackURL string) *Provider {
-	p := &Provider{
-		ClientKey:    clientKey,
-		Secret:       secret,
-		CallbackURL:  callbackURL,
-		providerName: "twitterv2",
-	}
-	p.consumer = newConsumer(p, authenticateURL)
-	return p
+// NewAuthenticate is the same as New.
+//
+// Deprecated: OAuth 2.0 has no separate authenticate end-point, use New instead.
+func NewAuthenticate(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	return New(clientKey, secret, callbackURL, scopes...)
 }
 
 // Provider is the implementation of `goth.Provider` for accessing Twitter.
@@ -58,8 +65,7 @@ type Provider struct {
 	Secret       string
 	CallbackURL  string
 	HTTPClient   *http.Client
-	debug        bool
-	consumer     *oauth.Consumer
+	config       *oauth2.Config
 	providerName string
 }
 
@@ -73,42 +79,60 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
 
-// Debug sets the logging of the OAuth client to verbose.
-func (p *Provider) Debug(debug bool) {
-	p.debug = debug
-}
+// Debug is a no-op for the twitterv2 package.
+func (p *Provider) Debug(debug bool) {}
 
-// BeginAuth asks Twitter for an authentication end-point and a request token for a session.
-// Twitter does not support the "state" variable.
+// BeginAuth asks Twitter for an authentication end-point. The PKCE code
+// verifier is kept in the session until the code is exchanged.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	requestToken, url, err := p.consumer.GetRequestTokenAndUrl(p.CallbackURL)
+	verifier, err := goth.GenerateVerifier()
+	if err != nil {
+		return nil, err
+	}
 	session := &Session{
-		AuthURL:      url,
-		RequestToken: requestToken,
+		AuthURL:      p.config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)),
+		CodeVerifier: verifier,
 	}
-	return session, err
+	return session, nil
 }
 
 // FetchUser will go to Twitter and access basic information about the user.
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	sess := session.(*Session)
 	user := goth.User{
-		Provider: p.Name(),
+		AccessToken:  sess.AccessToken,
+		Provider:     p.Name(),
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
 	}
 
-	if sess.AccessToken == nil {
+	if user.AccessToken == "" {
 		// data is not yet retrieved since accessToken is still empty
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	response, err := p.consumer.Get(
-		endpointProfile,
-		map[string]string{"user.fields": "id,name,username,description,profile_image_url,location"},
-		sess.AccessToken)
+	query := url.Values{"user.fields": {"id,name,username,description,profile_image_url,location"}}
+	req, err := http.NewRequest("GET", endpointProfile+"?"+query.Encode(), nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	response, err := p.Client().Do(req)
 	if err != nil {
 		return user, err
 	}
@@ -118,56 +142,69 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
 	}
 
-	bits, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return user, err
-	}
+	err = userFromReader(response.Body, &user)
+	return user, err
+}
 
+func userFromReader(r io.Reader, user *goth.User) error {
 	userInfo := struct {
 		Data map[string]interface{} `json:"data"`
 	}{}
 
-	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&userInfo)
+	err := json.NewDecoder(r).Decode(&userInfo)
 	if err != nil {
-		return user, err
+		return err
 	}
 
 	user.RawData = userInfo.Data
-	user.Name = user.RawData["name"].(string)
-	user.NickName = user.RawData["username"].(string)
-	if user.RawData["description"] != nil {
-		user.Description = user.RawData["description"].(string)
+	user.Name, _ = user.RawData["name"].(string)
+	user.NickName, _ = user.RawData["username"].(string)
+	user.Description, _ = user.RawData["description"].(string)
+	user.AvatarURL, _ = user.RawData["profile_image_url"].(string)
+	user.UserID, _ = user.RawData["id"].(string)
+	user.Location, _ = user.RawData["location"].(string)
+	return nil
+}
+
+func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  authURL,
+			TokenURL: tokenURL,
+			// confidential clients authenticate with basic auth, public
+			// clients only send their client_id
+			AuthStyle: oauth2.AuthStyleInHeader,
+		},
+		Scopes: []string{},
 	}
-	user.AvatarURL = user.RawData["profile_image_url"].(string)
-	user.UserID = user.RawData["id"].(string)
-	if user.RawData["location"] != nil {
-		user.Location = user.RawData["location"].(string)
+	if provider.Secret == "" {
+		c.Endpoint.AuthStyle = oauth2.AuthStyleInParams
 	}
-	user.AccessToken = sess.AccessToken.Token
-	user.AccessTokenSecret = sess.AccessToken.Secret
-	return user, err
-}
 
-func newConsumer(provider *Provider, authURL string) *oauth.Consumer {
-	c := oauth.NewConsumer(
-		provider.ClientKey,
-		provider.Secret,
-		oauth.ServiceProvider{
-			RequestTokenUrl:   requestURL,
-			AuthorizeTokenUrl: authURL,
-			AccessTokenUrl:    tokenURL,
-		})
-
-	c.Debug(provider.debug)
+	if len(scopes) > 0 {
+		c.Scopes = append(c.Scopes, scopes...)
+	} else {
+		c.Scopes = append(c.Scopes, ScopeTweetRead, ScopeUsersRead, ScopeOfflineAccess)
+	}
 	return c
 }
 
-// RefreshToken refresh token is not provided by twitter
-func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	return nil, errors.New("Refresh token is not provided by twitter")
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
 }
 
-// RefreshTokenAvailable refresh token is not provided by twitter
-func (p *Provider) RefreshTokenAvailable() bool {
-	return false
+// RefreshToken get new access token based on the refresh token. Twitter only
+// issues refresh tokens when the offline.access scope was granted.
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
+	newToken, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return newToken, err
 }
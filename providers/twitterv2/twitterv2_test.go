@@ -5,13 +5,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 
-	"github.com/gorilla/pat"
 	"github.com/andreimerlescu/goth"
-	"github.com/mrjones/oauth"
+	"github.com/gorilla/pat"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
 )
 
 func Test_New(t *testing.T) {
@@ -39,17 +40,37 @@ func Test_BeginAuth(t *testing.T) {
 	session, err := provider.BeginAuth("state")
 	s := session.(*Session)
 	a.NoError(err)
-	a.Contains(s.AuthURL, "authorize?oauth_token=TOKEN")
-	a.Equal("TOKEN", s.RequestToken.Token)
-	a.Equal("SECRET", s.RequestToken.Secret)
+	a.Contains(s.AuthURL, authURL)
+	a.Contains(s.AuthURL, "state=state")
+	a.Contains(s.AuthURL, "code_challenge_method=S256")
+	a.Contains(s.AuthURL, "scope=tweet.read+users.read+offline.access")
+	a.NotEmpty(s.CodeVerifier)
 
 	provider = twitterProviderAuthenticate()
 	session, err = provider.BeginAuth("state")
-	s = session.(*Session)
 	a.NoError(err)
-	a.Contains(s.AuthURL, "authenticate?oauth_token=TOKEN")
-	a.Equal("TOKEN", s.RequestToken.Token)
-	a.Equal("SECRET", s.RequestToken.Secret)
+	a.Contains(session.(*Session).AuthURL, authURL)
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := New("KEY", "", "/foo")
+	a.Equal(oauth2.AuthStyleInParams, provider.config.Endpoint.AuthStyle)
+	a.Equal(oauth2.AuthStyleInHeader, New("KEY", "SECRET", "/foo").config.Endpoint.AuthStyle)
+
+	session := Session{CodeVerifier: "VERIFIER"}
+	token, err := session.Authorize(provider, url.Values{"code": {"CODE"}})
+	a.NoError(err)
+	a.Equal("TOKEN", token)
+	a.Equal("TOKEN", session.AccessToken)
+	a.Equal("REFRESH", session.RefreshToken)
+	a.False(session.ExpiresAt.IsZero())
+
+	session = Session{CodeVerifier: "WRONG"}
+	_, err = session.Authorize(provider, url.Values{"code": {"CODE"}})
+	a.Error(err)
 }
 
 func Test_FetchUser(t *testing.T) {
@@ -57,7 +78,7 @@ func Test_FetchUser(t *testing.T) {
 	a := assert.New(t)
 
 	provider := twitterProvider()
-	session := Session{AccessToken: &oauth.AccessToken{Token: "TOKEN", Secret: "SECRET"}}
+	session := Session{AccessToken: "TOKEN", RefreshToken: "REFRESH"}
 
 	user, err := provider.FetchUser(&session)
 	a.NoError(err)
@@ -69,6 +90,7 @@ func Test_FetchUser(t *testing.T) {
 	a.Equal("1234", user.UserID)
 	a.Equal("Springfield", user.Location)
 	a.Equal("TOKEN", user.AccessToken)
+	a.Equal("REFRESH", user.RefreshToken)
 	a.Equal("", user.Email)
 }
 
@@ -78,14 +100,13 @@ func Test_SessionFromJSON(t *testing.T) {
 
 	provider := twitterProvider()
 
-	s, err := provider.UnmarshalSession(`{"AuthURL":"http://com/auth_url","AccessToken":{"Token":"1234567890","Secret":"secret!!","AdditionalData":{}},"RequestToken":{"Token":"0987654321","Secret":"!!secret"}}`)
+	s, err := provider.UnmarshalSession(`{"AuthURL":"http://com/auth_url","AccessToken":"1234567890","RefreshToken":"0987654321","CodeVerifier":"verifier"}`)
 	a.NoError(err)
 	session := s.(*Session)
 	a.Equal(session.AuthURL, "http://com/auth_url")
-	a.Equal(session.AccessToken.Token, "1234567890")
-	a.Equal(session.AccessToken.Secret, "secret!!")
-	a.Equal(session.RequestToken.Token, "0987654321")
-	a.Equal(session.RequestToken.Secret, "!!secret")
+	a.Equal(session.AccessToken, "1234567890")
+	a.Equal(session.RefreshToken, "0987654321")
+	a.Equal(session.CodeVerifier, "verifier")
 }
 
 func twitterProvider() *Provider {
@@ -98,10 +119,19 @@ func twitterProviderAuthenticate() *Provider {
 
 func init() {
 	p := pat.New()
-	p.Get("/oauth/request_token", func(res http.ResponseWriter, req *http.Request) {
-		fmt.Fprint(res, "oauth_token=TOKEN&oauth_token_secret=SECRET")
+	p.Post("/2/oauth2/token", func(res http.ResponseWriter, req *http.Request) {
+		if req.FormValue("code_verifier") != "VERIFIER" || req.FormValue("client_id") != "KEY" {
+			res.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(res, `{"token_type":"bearer","expires_in":7200,"access_token":"TOKEN","scope":"tweet.read users.read offline.access","refresh_token":"REFRESH"}`)
 	})
 	p.Get("/2/users/me", func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer TOKEN" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
 		data := map[string]interface{}{
 			"data": map[string]string{
 				"name":              "Homer",
@@ -117,6 +147,6 @@ func init() {
 	})
 	ts := httptest.NewServer(p)
 
-	requestURL = ts.URL + "/oauth/request_token"
+	tokenURL = ts.URL + "/2/oauth2/token"
 	endpointProfile = ts.URL + "/2/users/me"
 }
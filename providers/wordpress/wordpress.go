@@ -0,0 +1,257 @@
+// Package wordpress implements the OAuth2 protocol for authenticating users through
+// WordPress.com, including self-hosted sites connected to WordPress.com with Jetpack.
+// This package can be used as a reference implementation of an OAuth2 provider for Goth.
+package wordpress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// URLs and endpoints
+const (
+	authURL         string = "https://public-api.wordpress.com/oauth2/authorize"
+	tokenURL        string = "https://public-api.wordpress.com/oauth2/token"
+	endpointProfile string = "https://public-api.wordpress.com/rest/v1.1/me"
+)
+
+// These are the scopes a token can be granted. Without the global scope, a
+// token is only valid for the single blog the user picks during the
+// authorization, which is returned in Session.BlogID and Session.BlogURL.
+// See https://developer.wordpress.com/docs/oauth2/
+const (
+	// ScopeAuth only grants access to the /me endpoints, to log users in.
+	ScopeAuth string = "auth"
+	// ScopeGlobal grants access to all of the user's blogs.
+	ScopeGlobal   string = "global"
+	ScopePosts    string = "posts"
+	ScopeMedia    string = "media"
+	ScopeComments string = "comments"
+	ScopeSites    string = "sites"
+	ScopeStats    string = "stats"
+	ScopeUsers    string = "users"
+)
+
+// Provider is the implementation of `goth.Provider` for accessing WordPress.com.
+type Provider struct {
+	ClientKey    string
+	Secret       string
+	CallbackURL  string
+	HTTPClient   *http.Client
+	config       *oauth2.Config
+	providerName string
+	blog         string
+}
+
+// New creates a new WordPress.com provider and sets up important connection details.
+// You should always call `wordpress.New` to get a new provider.  Never try to
+// create one manually.
+func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "wordpress",
+	}
+	p.config = newConfig(p, scopes)
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the wordpress package.
+func (p *Provider) Debug(debug bool) {}
+
+// SetBlog preselects the blog a blog-scoped token is requested for, given by
+// its ID or URL. This is how tokens for self-hosted sites connected with
+// Jetpack are requested, e.g. SetBlog("https://example.com").
+func (p *Provider) SetBlog(blog string) {
+	p.blog = blog
+}
+
+// BeginAuth asks WordPress.com for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return p.beginAuth(state, p.blog), nil
+}
+
+// BeginAuthWithParams asks WordPress.com for an authentication end-point for
+// the blog given in the "blog" parameter, falling back to the blog set with
+// SetBlog. This lets a single provider serve several Jetpack sites.
+func (p *Provider) BeginAuthWithParams(state string, params goth.Params) (goth.Session, error) {
+	blog := params.Get("blog")
+	if blog == "" {
+		blog = p.blog
+	}
+	return p.beginAuth(state, blog), nil
+}
+
+func (p *Provider) beginAuth(state, blog string) *Session {
+	var opts []oauth2.AuthCodeOption
+	if blog != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("blog", blog))
+	}
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state, opts...),
+	}
+}
+
+// FetchUser will go to WordPress.com and access basic information about the user.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken: sess.AccessToken,
+		Provider:    p.Name(),
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	req, err := http.NewRequest("GET", endpointProfile, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+	}
+
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+	// the blog and scope of the token are only part of the token response
+	user.RawData["token_blog_id"] = sess.BlogID
+	user.RawData["token_blog_url"] = sess.BlogURL
+	user.RawData["token_scope"] = sess.Scope
+
+	err = userFromReader(bytes.NewReader(bits), &user)
+	return user, err
+}
+
+// PrimaryBlog returns the ID and URL of the primary blog of a user fetched by
+// this provider.
+func PrimaryBlog(user goth.User) (id string, url string) {
+	if blogID, ok := user.RawData["primary_blog"].(float64); ok && blogID != 0 {
+		id = strconv.FormatInt(int64(blogID), 10)
+	}
+	url, _ = user.RawData["primary_blog_url"].(string)
+	return id, url
+}
+
+// TokenBlog returns the ID and URL of the blog the access token of a user
+// fetched by this provider is valid for. Both are empty for tokens with the
+// global scope.
+func TokenBlog(user goth.User) (id string, url string) {
+	id, _ = user.RawData["token_blog_id"].(string)
+	url, _ = user.RawData["token_blog_url"].(string)
+	return id, url
+}
+
+// TokenScope returns the scope WordPress.com granted the access token of a
+// user fetched by this provider.
+func TokenScope(user goth.User) string {
+	scope, _ := user.RawData["token_scope"].(string)
+	return scope
+}
+
+func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   authURL,
+			TokenURL:  tokenURL,
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+		Scopes: []string{},
+	}
+
+	if len(scopes) > 0 {
+		c.Scopes = append(c.Scopes, scopes...)
+	} else {
+		c.Scopes = append(c.Scopes, ScopeAuth)
+	}
+	return c
+}
+
+func userFromReader(r io.Reader, user *goth.User) error {
+	u := struct {
+		ID          int64  `json:"ID"`
+		DisplayName string `json:"display_name"`
+		Username    string `json:"username"`
+		Email       string `json:"email"`
+		AvatarURL   string `json:"avatar_URL"`
+	}{}
+
+	err := json.NewDecoder(r).Decode(&u)
+	if err != nil {
+		return err
+	}
+
+	user.UserID = strconv.FormatInt(u.ID, 10)
+	user.Name = u.DisplayName
+	user.NickName = u.Username
+	user.Email = u.Email
+	user.AvatarURL = u.AvatarURL
+	return nil
+}
+
+// RefreshToken refresh token is not provided by WordPress.com, its access
+// tokens don't expire.
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by wordpress")
+}
+
+// RefreshTokenAvailable refresh token is not provided by WordPress.com
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
@@ -0,0 +1,137 @@
+package wordpress_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/wordpress"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.ClientKey, os.Getenv("WORDPRESS_KEY"))
+	a.Equal(p.Secret, os.Getenv("WORDPRESS_SECRET"))
+	a.Equal(p.CallbackURL, "/foo")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+	a.Implements((*goth.BeginAuthWithParamsProvider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	session, err := p.BeginAuth("test_state")
+	s := session.(*wordpress.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "https://public-api.wordpress.com/oauth2/authorize")
+	a.Contains(s.AuthURL, "scope=auth")
+	a.NotContains(s.AuthURL, "blog=")
+}
+
+func Test_BeginAuthForBlog(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	p.SetBlog("https://jetpack.example.com")
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*wordpress.Session).AuthURL, "blog="+url.QueryEscape("https://jetpack.example.com"))
+
+	session, err = p.BeginAuthWithParams("test_state", url.Values{"blog": {"12345"}})
+	a.NoError(err)
+	a.Contains(session.(*wordpress.Session).AuthURL, "blog=12345")
+}
+
+func Test_AuthorizeAndFetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/oauth2/token":
+			if req.FormValue("client_id") != "client-id" || req.FormValue("client_secret") != "client-secret" {
+				res.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			res.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(res, `{"access_token":"YOUR_API_TOKEN","blog_id":"12345","blog_url":"https:\/\/jetpack.example.com","token_type":"bearer","scope":"posts"}`)
+		case "/rest/v1.1/me":
+			if req.Header.Get("Authorization") != "Bearer YOUR_API_TOKEN" {
+				res.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(res, `{"ID":1234567,"display_name":"Jane Doe","username":"janedoe","email":"jane@example.com","primary_blog":98765,"primary_blog_url":"https:\/\/janedoe.wordpress.com","avatar_URL":"https:\/\/secure.gravatar.com\/avatar\/abc","email_verified":true}`)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := wordpress.New("client-id", "client-secret", "/foo")
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	s := &wordpress.Session{}
+	_, err := s.Authorize(p, url.Values{"code": {"auth-code"}})
+	a.NoError(err)
+	a.Equal("12345", s.BlogID)
+	a.Equal("https://jetpack.example.com", s.BlogURL)
+	a.Equal("posts", s.Scope)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("1234567", user.UserID)
+	a.Equal("Jane Doe", user.Name)
+	a.Equal("janedoe", user.NickName)
+	a.Equal("jane@example.com", user.Email)
+	a.Equal("https://secure.gravatar.com/avatar/abc", user.AvatarURL)
+
+	id, blogURL := wordpress.PrimaryBlog(user)
+	a.Equal("98765", id)
+	a.Equal("https://janedoe.wordpress.com", blogURL)
+	id, blogURL = wordpress.TokenBlog(user)
+	a.Equal("12345", id)
+	a.Equal("https://jetpack.example.com", blogURL)
+	a.Equal("posts", wordpress.TokenScope(user))
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://public-api.wordpress.com/oauth2/authorize","AccessToken":"1234567890"}`)
+	a.NoError(err)
+
+	s := session.(*wordpress.Session)
+	a.Equal(s.AuthURL, "https://public-api.wordpress.com/oauth2/authorize")
+	a.Equal(s.AccessToken, "1234567890")
+}
+
+func provider() *wordpress.Provider {
+	return wordpress.New(os.Getenv("WORDPRESS_KEY"), os.Getenv("WORDPRESS_SECRET"), "/foo")
+}
@@ -0,0 +1,261 @@
+// Package xerov2 implements the OpenID Connect protocol for authenticating users through Xero.
+// Unlike the OAuth 1.0a based xero package it lists the Xero organisations (tenants) the
+// user connected once they have signed in.
+package xerov2
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// URLs and endpoints
+const (
+	authURL             string = "https://login.xero.com/identity/connect/authorize"
+	tokenURL            string = "https://identity.xero.com/connect/token"
+	endpointProfile     string = "https://identity.xero.com/connect/userinfo"
+	endpointConnections string = "https://api.xero.com/connections"
+)
+
+// Scopes
+const (
+	ScopeOpenID        string = "openid"
+	ScopeProfile       string = "profile"
+	ScopeEmail         string = "email"
+	ScopeOfflineAccess string = "offline_access"
+	ScopeAccounting    string = "accounting.transactions"
+	ScopeSettings      string = "accounting.settings"
+	ScopeContacts      string = "accounting.contacts"
+)
+
+// Connection is a Xero organisation or practice the access token is authorised for.
+// See https://developer.xero.com/documentation/guides/oauth2/auth-flow/#5-check-the-tenants-youre-authorized-to-access
+type Connection struct {
+	ID          string `json:"id"`
+	AuthEventID string `json:"authEventId"`
+	TenantID    string `json:"tenantId"`
+	TenantType  string `json:"tenantType"`
+	TenantName  string `json:"tenantName"`
+	// Xero sends the dates without a zone designator, so they are kept as sent
+	CreatedDateUTC string `json:"createdDateUtc"`
+	UpdatedDateUTC string `json:"updatedDateUtc"`
+}
+
+// Provider is the implementation of `goth.Provider` for accessing Xero.
+type Provider struct {
+	ClientKey    string
+	Secret       string
+	CallbackURL  string
+	HTTPClient   *http.Client
+	config       *oauth2.Config
+	providerName string
+}
+
+// New creates a new Xero provider and sets up important connection details.
+// You should always call `xerov2.New` to get a new provider.  Never try to
+// create one manually.
+func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "xerov2",
+	}
+	p.config = newConfig(p, scopes)
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the xerov2 package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks Xero for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state),
+	}, nil
+}
+
+// FetchUser will go to Xero and access basic information about the user,
+// along with the organisations the access token covers.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		Provider:     p.Name(),
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+		IDToken:      sess.IDToken,
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	resp, err := p.get(endpointProfile, sess.AccessToken)
+	if err != nil {
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+	}
+
+	err = userFromReader(resp.Body, &user)
+	if err != nil {
+		return user, err
+	}
+
+	connections, err := p.Connections(sess.AccessToken)
+	if err != nil {
+		return user, err
+	}
+	user.RawData["connections"] = connections
+	return user, nil
+}
+
+// Connections lists the Xero organisations (tenants) the access token is
+// authorised for. Every Xero API call needs one of their tenant IDs in the
+// Xero-tenant-id header.
+func (p *Provider) Connections(accessToken string) ([]Connection, error) {
+	resp, err := p.get(endpointConnections, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to fetch connections", p.providerName, resp.StatusCode)
+	}
+
+	var connections []Connection
+	err = json.NewDecoder(resp.Body).Decode(&connections)
+	if err != nil {
+		return nil, err
+	}
+	return connections, nil
+}
+
+// Connections returns the Xero organisations a user fetched by this provider
+// connected.
+func Connections(user goth.User) []Connection {
+	connections, _ := user.RawData["connections"].([]Connection)
+	return connections
+}
+
+func (p *Provider) get(endpoint, accessToken string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	return p.Client().Do(req)
+}
+
+func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   authURL,
+			TokenURL:  tokenURL,
+			AuthStyle: oauth2.AuthStyleInHeader,
+		},
+		Scopes: []string{},
+	}
+
+	if len(scopes) > 0 {
+		c.Scopes = append(c.Scopes, scopes...)
+	} else {
+		c.Scopes = append(c.Scopes, ScopeOpenID, ScopeProfile, ScopeEmail, ScopeOfflineAccess)
+	}
+	return c
+}
+
+func userFromReader(r io.Reader, user *goth.User) error {
+	u := struct {
+		ID        string `json:"sub"`
+		XeroID    string `json:"xero_userid"`
+		Email     string `json:"email"`
+		FirstName string `json:"given_name"`
+		LastName  string `json:"family_name"`
+		Name      string `json:"name"`
+		NickName  string `json:"preferred_username"`
+	}{}
+
+	bits, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(bits, &u)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(bits, &user.RawData)
+	if err != nil {
+		return err
+	}
+
+	user.UserID = u.XeroID
+	if user.UserID == "" {
+		user.UserID = u.ID
+	}
+	user.Email = u.Email
+	user.FirstName = u.FirstName
+	user.LastName = u.LastName
+	user.Name = u.Name
+	if user.Name == "" && (u.FirstName != "" || u.LastName != "") {
+		user.Name = fmt.Sprintf("%s %s", u.FirstName, u.LastName)
+	}
+	user.NickName = u.NickName
+	return nil
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
+	newToken, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return newToken, err
+}
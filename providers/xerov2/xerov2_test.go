@@ -0,0 +1,103 @@
+package xerov2_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/providers/xerov2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.ClientKey, os.Getenv("XERO_KEY"))
+	a.Equal(p.Secret, os.Getenv("XERO_SECRET"))
+	a.Equal(p.CallbackURL, "/foo")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	session, err := p.BeginAuth("test_state")
+	s := session.(*xerov2.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "https://login.xero.com/identity/connect/authorize")
+	a.Contains(s.AuthURL, "scope=openid+profile+email+offline_access")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer 1234567890" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch req.URL.Path {
+		case "/connect/userinfo":
+			fmt.Fprint(res, `{"sub":"a3a4dbafh3495a808ed7a7b964388f53","xero_userid":"1945393b-6eb7-4143-b083-7ab26cd7690b","email":"john@doe.com","given_name":"John","family_name":"Doe","name":"John Doe","preferred_username":"john@doe.com"}`)
+		case "/connections":
+			fmt.Fprint(res, `[{"id":"e1eede29-f875-4a5d-8470-17f6a29a88b1","authEventId":"d99ecdfe-391d-43d2-b834-17636ba90e8d","tenantId":"70784a63-d24b-46a9-a4db-0e70a274b056","tenantType":"ORGANISATION","tenantName":"Maple Florist","createdDateUtc":"2019-07-09T23:40:30.1833130","updatedDateUtc":"2020-05-15T01:35:13.8491980"}]`)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := provider()
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	user, err := p.FetchUser(&xerov2.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("1945393b-6eb7-4143-b083-7ab26cd7690b", user.UserID)
+	a.Equal("john@doe.com", user.Email)
+	a.Equal("John Doe", user.Name)
+
+	connections := xerov2.Connections(user)
+	a.Len(connections, 1)
+	a.Equal("70784a63-d24b-46a9-a4db-0e70a274b056", connections[0].TenantID)
+	a.Equal("Maple Florist", connections[0].TenantName)
+	a.Equal("ORGANISATION", connections[0].TenantType)
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://login.xero.com/identity/connect/authorize","AccessToken":"1234567890"}`)
+	a.NoError(err)
+
+	s := session.(*xerov2.Session)
+	a.Equal(s.AuthURL, "https://login.xero.com/identity/connect/authorize")
+	a.Equal(s.AccessToken, "1234567890")
+}
+
+func provider() *xerov2.Provider {
+	return xerov2.New(os.Getenv("XERO_KEY"), os.Getenv("XERO_SECRET"), "/foo")
+}
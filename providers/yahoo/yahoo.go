@@ -1,29 +1,52 @@
-// Package yahoo implements the OAuth2 protocol for authenticating users through yahoo.
+// Package yahoo implements the OpenID Connect protocol for authenticating users through yahoo.
 // This package can be used as a reference implementation of an OAuth2 provider for Goth.
 package yahoo
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/jwks"
+	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/oauth2"
 )
 
+// more details about Sign in with Yahoo using OpenID Connect:
+// https://developer.yahoo.com/sign-in-with-yahoo/
+
 const (
 	authURL         string = "https://api.login.yahoo.com/oauth2/request_auth"
 	tokenURL        string = "https://api.login.yahoo.com/oauth2/get_token"
 	endpointProfile string = "https://api.login.yahoo.com/openid/v1/userinfo"
+	issuer          string = "https://api.login.yahoo.com"
+	keysURL         string = "https://api.login.yahoo.com/openid/v1/certs"
+)
+
+// Scopes of Sign in with Yahoo. The profile and email scopes have to be
+// enabled as OpenID Connect permissions of the app as well.
+const (
+	ScopeOpenID  string = "openid"
+	ScopeProfile string = "profile"
+	ScopeEmail   string = "email"
 )
 
 // Provider is the implementation of `goth.Provider` for accessing Yahoo.
 type Provider struct {
-	ClientKey    string
-	Secret       string
-	CallbackURL  string
-	HTTPClient   *http.Client
+	ClientKey   string
+	Secret      string
+	CallbackURL string
+	HTTPClient  *http.Client
+	// Keys verify the signatures of the ID tokens, fetched from Yahoo and
+	// prefetched once the provider is registered with goth.UseProviders.
+	Keys         *jwks.Cache
 	config       *oauth2.Config
 	providerName string
 }
@@ -37,6 +60,7 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 		Secret:       secret,
 		CallbackURL:  callbackURL,
 		providerName: "yahoo",
+		Keys:         jwks.New(keysURL, nil),
 	}
 	p.config = newConfig(p, scopes)
 	return p
@@ -52,6 +76,17 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -59,21 +94,50 @@ func (p *Provider) Client() *http.Client {
 // Debug is a no-op for the yahoo package.
 func (p *Provider) Debug(debug bool) {}
 
-// BeginAuth asks Yahoo for an authentication end-point.
+// Prefetch fetches the keys verifying the ID tokens in the background, so
+// the logins don't wait for them, see goth.PrefetchProvider.
+func (p *Provider) Prefetch() {
+	p.keys().Prefetch()
+}
+
+func (p *Provider) keys() *jwks.Cache {
+	if p.Keys == nil {
+		return jwks.New(keysURL, p.HTTPClient)
+	}
+	return p.Keys
+}
+
+// BeginAuth asks Yahoo for an authentication end-point. The nonce of the
+// request is checked in the ID token.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	b, err := goth.RandomBytes(16)
+	if err != nil {
+		return nil, err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b)
 	return &Session{
-		AuthURL: p.config.AuthCodeURL(state),
+		AuthURL: p.config.AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce)),
+		Nonce:   nonce,
 	}, nil
 }
 
-// FetchUser will go to Yahoo and access basic information about the user.
+// FetchUser will read the user from the ID token Yahoo issued with the access
+// token. The userinfo endpoint is only called when there is no ID token, or
+// once it expired.
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	return p.FetchUserContext(context.Background(), session)
+}
+
+// FetchUserContext is FetchUser, fetching the keys and the user with the
+// context.
+func (p *Provider) FetchUserContext(ctx context.Context, session goth.Session) (goth.User, error) {
 	s := session.(*Session)
 	user := goth.User{
 		AccessToken:  s.AccessToken,
 		Provider:     p.Name(),
 		RefreshToken: s.RefreshToken,
 		ExpiresAt:    s.ExpiresAt,
+		IDToken:      s.IDToken,
 	}
 
 	if user.AccessToken == "" {
@@ -81,28 +145,71 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	req, err := http.NewRequest("GET", endpointProfile, nil)
-	if err != nil {
-		return user, err
+	var bits []byte
+	if s.IDToken != "" {
+		claims, err := p.validateIDToken(ctx, s.IDToken, s.Nonce)
+		switch {
+		case err == nil:
+			bits, err = json.Marshal(claims)
+			if err != nil {
+				return user, err
+			}
+		case !errors.Is(err, jwt.ErrTokenExpired):
+			return user, err
+		}
+		// the sessions outliving their ID token fetch the user info, which
+		// checks the access token
 	}
-	req.Header.Set("Authorization", "Bearer "+s.AccessToken)
-	resp, err := p.Client().Do(req)
-	if err != nil {
-		if resp != nil {
-			resp.Body.Close()
+	if bits == nil {
+		req, err := http.NewRequestWithContext(ctx, "GET", endpointProfile, nil)
+		if err != nil {
+			return user, err
+		}
+		req.Header.Set("Authorization", "Bearer "+s.AccessToken)
+		resp, err := p.Client().Do(req)
+		if err != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return user, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+		}
+
+		bits, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return user, err
 		}
-		return user, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+	err := json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
 	}
 
-	err = userFromReader(resp.Body, &user)
+	err = userFromReader(bytes.NewReader(bits), &user)
 	return user, err
 }
 
+// validateIDToken verifies the signature of the ID token with the Keys, and
+// that it was issued by Yahoo for this client and the nonce of the session,
+// and returns its claims.
+func (p *Provider) validateIDToken(ctx context.Context, idToken, nonce string) (map[string]interface{}, error) {
+	claims, err := p.keys().VerifyIDToken(ctx, idToken, jwks.IDToken{
+		Issuer:     issuer,
+		Audience:   p.ClientKey,
+		Nonce:      nonce,
+		Algorithms: []string{"ES256", "RS256"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s id_token: %w", p.providerName, err)
+	}
+	return claims, nil
+}
+
 func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 	c := &oauth2.Config{
 		ClientID:     provider.ClientKey,
@@ -119,6 +226,8 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 		for _, scope := range scopes {
 			c.Scopes = append(c.Scopes, scope)
 		}
+	} else {
+		c.Scopes = append(c.Scopes, ScopeOpenID, ScopeProfile, ScopeEmail)
 	}
 	return c
 }
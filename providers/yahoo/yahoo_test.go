@@ -1,10 +1,22 @@
 package yahoo_test
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothtest"
+	"github.com/andreimerlescu/goth/jwks"
 	"github.com/andreimerlescu/goth/providers/yahoo"
 	"github.com/stretchr/testify/assert"
 )
@@ -33,6 +45,94 @@ func Test_BeginAuth(t *testing.T) {
 	s := session.(*yahoo.Session)
 	a.NoError(err)
 	a.Contains(s.AuthURL, "api.login.yahoo.com/oauth2/request_auth")
+	a.Contains(s.AuthURL, "scope=openid+profile+email")
+	a.NotEmpty(s.Nonce)
+	a.Contains(s.AuthURL, "nonce="+s.Nonce)
+}
+
+func Test_FetchUserFromIDToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := gothtest.NewOIDCServer(t)
+	p := yahoo.New("client-id", "secret", "/foo")
+	p.Keys = jwks.New(server.Issuer+gothtest.JWKSPath, nil)
+	claims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss": "https://api.login.yahoo.com", "aud": []string{"client-id"}, "iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix(), "nonce": "n-0S6_WzA2Mj",
+			"sub": "FSVIDUW3D7FSVIDUW3D72F2F", "name": "Jasmine Smith", "given_name": "Jasmine", "family_name": "Smith", "nickname": "jasmine",
+			"picture": "https://s.yimg.com/ag/images/default_user_profile_pic_192sq.jpg", "email": "jasmine@yahoo.com", "email_verified": true, "locale": "en-US",
+		}
+	}
+	idToken, err := server.Sign(claims())
+	a.NoError(err)
+
+	user, err := p.FetchUser(&yahoo.Session{AccessToken: "1234567890", IDToken: idToken, Nonce: "n-0S6_WzA2Mj"})
+	a.NoError(err)
+	a.Equal("FSVIDUW3D7FSVIDUW3D72F2F", user.UserID)
+	a.Equal("Jasmine Smith", user.Name)
+	a.Equal("Jasmine", user.FirstName)
+	a.Equal("Smith", user.LastName)
+	a.Equal("jasmine", user.NickName)
+	a.Equal("jasmine@yahoo.com", user.Email)
+	a.Equal(idToken, user.IDToken)
+	a.Equal(true, user.RawData["email_verified"])
+
+	other := yahoo.New("other-client", "secret", "/foo")
+	other.Keys = p.Keys
+	_, err = other.FetchUser(&yahoo.Session{AccessToken: "1234567890", IDToken: idToken})
+	a.Error(err)
+	_, err = p.FetchUser(&yahoo.Session{AccessToken: "1234567890", IDToken: idToken, Nonce: "other-nonce"})
+	a.Error(err)
+
+	// the signatures are verified
+	parts := strings.Split(idToken, ".")
+	forged := claims()
+	forged["sub"] = "someone-else"
+	payload, _ := json.Marshal(forged)
+	_, err = p.FetchUser(&yahoo.Session{AccessToken: "1234567890", IDToken: parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]})
+	a.Error(err)
+}
+
+func Test_FetchUserFromUserInfo(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/openid/v1/userinfo" || req.Header.Get("Authorization") != "Bearer 1234567890" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(res, `{"sub":"FSVIDUW3D7FSVIDUW3D72F2F","name":"Jasmine Smith","given_name":"Jasmine","family_name":"Smith","email":"jasmine@yahoo.com","email_verified":true}`)
+	}))
+	defer server.Close()
+
+	p := provider()
+	p.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	user, err := p.FetchUser(&yahoo.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("FSVIDUW3D7FSVIDUW3D72F2F", user.UserID)
+	a.Equal("jasmine@yahoo.com", user.Email)
+
+	// the sessions outliving their ID token fetch the user info
+	keys := gothtest.NewOIDCServer(t)
+	client := p.HTTPClient
+	p = yahoo.New("client-id", "secret", "/foo")
+	p.HTTPClient = client
+	p.Keys = jwks.New(keys.Issuer+gothtest.JWKSPath, nil)
+	expired, err := keys.Sign(map[string]interface{}{"iss": "https://api.login.yahoo.com", "aud": "client-id", "exp": time.Now().Add(-time.Hour).Unix(), "sub": "someone-else"})
+	a.NoError(err)
+	user, err = p.FetchUser(&yahoo.Session{AccessToken: "1234567890", IDToken: expired})
+	a.NoError(err)
+	a.Equal("FSVIDUW3D7FSVIDUW3D72F2F", user.UserID)
 }
 
 func Test_SessionFromJSON(t *testing.T) {
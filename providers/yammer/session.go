@@ -57,7 +57,7 @@ func (s Session) Marshal() string {
 }
 
 func (s Session) String() string {
-	return s.Marshal()
+	return goth.RedactJSON(s.Marshal())
 }
 
 // Custom implementation for yammer to get access token and user data
@@ -81,7 +81,7 @@ func retrieveAuthData(p *Provider, TokenURL string, v url.Values) (map[string]ma
 		return nil, fmt.Errorf("oauth2: cannot fetch token: %v", err)
 	}
 	if code := r.StatusCode; code < 200 || code > 299 {
-		return nil, fmt.Errorf("oauth2: cannot fetch token: %v\nResponse: %s", r.Status, body)
+		return nil, fmt.Errorf("oauth2: cannot fetch token: %v\nResponse: %s", r.Status, goth.RedactBody(r.Header.Get("Content-Type"), body))
 	}
 
 	var objmap map[string]map[string]interface{}
@@ -5,12 +5,14 @@ package yandex
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 
 	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/option"
 	"golang.org/x/oauth2"
 )
 
@@ -30,20 +32,40 @@ type Provider struct {
 	HTTPClient   *http.Client
 	config       *oauth2.Config
 	providerName string
+
+	loginHint    string
+	forceConfirm bool
+	// deviceOptions are sent with both the authorization and token requests
+	// so that Yandex issues a token bound to a specific device.
+	deviceOptions []oauth2.AuthCodeOption
+	deviceErr     error
 }
 
+// ErrInvalidDeviceID is returned by BeginAuth when the device ID given to
+// WithDevice is not between 6 and 50 characters long.
+var ErrInvalidDeviceID = errors.New("yandex: the device ID must be between 6 and 50 characters long")
+
 // New creates a new Yandex provider and sets up important connection details.
 // You should always call `yandex.New` to get a new provider.  Never try to
 // create one manually.
 func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
-	p := &Provider{
-		ClientKey:    clientKey,
-		Secret:       secret,
-		CallbackURL:  callbackURL,
-		providerName: "yandex",
-	}
-	p.config = newConfig(p, scopes)
-	return p
+	return NewWithOptions(clientKey, secret, callbackURL, option.WithScopes(scopes...))
+}
+
+// NewWithOptions creates a new Yandex provider with the options, e.g.
+// option.WithScopes or WithDevice.
+func NewWithOptions(clientKey, secret, callbackURL string, opts ...option.Option) *Provider {
+	return option.Build(func(s *option.Settings) *Provider {
+		p := &Provider{
+			ClientKey:    clientKey,
+			Secret:       secret,
+			CallbackURL:  callbackURL,
+			HTTPClient:   s.HTTPClient,
+			providerName: "yandex",
+		}
+		p.config = newConfig(p, s.Scopes)
+		return p
+	}, opts...)
 }
 
 func (p *Provider) Client() *http.Client {
@@ -60,13 +82,33 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 // Debug is a no-op for the yandex package.
 func (p *Provider) Debug(debug bool) {}
 
 // BeginAuth asks Yandex for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	if p.deviceErr != nil {
+		return nil, p.deviceErr
+	}
+	opts := append([]oauth2.AuthCodeOption{}, p.deviceOptions...)
+	if p.loginHint != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("login_hint", p.loginHint))
+	}
+	if p.forceConfirm {
+		opts = append(opts, oauth2.SetAuthURLParam("force_confirm", "true"))
+	}
 	return &Session{
-		AuthURL: p.config.AuthCodeURL(state),
+		AuthURL: p.config.AuthCodeURL(state, opts...),
 	}, nil
 }
 
@@ -160,7 +202,7 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	user.Name = u.Name
 	user.FirstName = u.FirstName
 	user.LastName = u.LastName
-	if u.AvatarID != `` {
+	if u.AvatarID != `` && !u.IsAvatarEmpty {
 		user.AvatarURL = fmt.Sprintf("%s/%s/%s", avatarURL, u.AvatarID, avatarSize)
 	}
 	return nil
@@ -181,3 +223,38 @@ func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
 	}
 	return newToken, err
 }
+
+// WithDevice sets the device_id and device_name parameters of the Yandex
+// OAuth calls, so that Yandex issues tokens bound to the device which can be
+// revoked independently of the user's other tokens. The ID must be between 6
+// and 50 characters long, BeginAuth fails otherwise; the name is shown to the
+// user in the list of devices with access to their account.
+// See https://yandex.com/dev/id/doc/en/codes/code-url
+func WithDevice(deviceID, deviceName string) option.Option {
+	return option.With(func(p *Provider) {
+		if n := len([]rune(deviceID)); n < 6 || n > 50 {
+			p.deviceErr = ErrInvalidDeviceID
+			return
+		}
+		p.deviceOptions = []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("device_id", deviceID)}
+		if deviceName != "" {
+			p.deviceOptions = append(p.deviceOptions, oauth2.SetAuthURLParam("device_name", deviceName))
+		}
+	})
+}
+
+// WithLoginHint sets the login_hint parameter of the Yandex OAuth call, to
+// suggest the account the user should log in with.
+func WithLoginHint(loginHint string) option.Option {
+	return option.With(func(p *Provider) {
+		p.loginHint = loginHint
+	})
+}
+
+// WithForceConfirm sets the force_confirm parameter of the Yandex OAuth call:
+// the user is asked to grant access even if they already did.
+func WithForceConfirm(force bool) option.Option {
+	return option.With(func(p *Provider) {
+		p.forceConfirm = force
+	})
+}
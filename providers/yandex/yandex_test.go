@@ -2,6 +2,7 @@ package yandex_test
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/andreimerlescu/goth"
@@ -43,6 +44,33 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "https://oauth.yandex.ru/authorize")
 }
 
+func Test_BeginAuthWithDevice(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := yandex.NewWithOptions("key", "secret", "/foo",
+		yandex.WithDevice("device-123456", "Kitchen tablet"),
+		yandex.WithLoginHint("homer"),
+		yandex.WithForceConfirm(true),
+	)
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*yandex.Session)
+	a.Contains(s.AuthURL, "device_id=device-123456")
+	a.Contains(s.AuthURL, "device_name=Kitchen+tablet")
+	a.Contains(s.AuthURL, "login_hint=homer")
+	a.Contains(s.AuthURL, "force_confirm=true")
+
+	session, err = p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Equal(1, strings.Count(session.(*yandex.Session).AuthURL, "device_id="))
+
+	for _, id := range []string{"short", strings.Repeat("x", 51)} {
+		p = yandex.NewWithOptions("key", "secret", "/foo", yandex.WithDevice(id, ""))
+		_, err = p.BeginAuth("test_state")
+		a.ErrorIs(err, yandex.ErrInvalidDeviceID)
+	}
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -30,12 +30,28 @@ type Provider struct {
 	providerName string
 }
 
+// UserType is the plan type of a Zoom user.
+// See https://developers.zoom.us/docs/api/rest/reference/user/methods/#operation/user
+type UserType int
+
+// Zoom user types
+const (
+	UserTypeBasic    UserType = 1
+	UserTypeLicensed UserType = 2
+	UserTypeUnified  UserType = 4
+	UserTypeNone     UserType = 99
+)
+
 type profileResp struct {
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	Email     string `json:"email"`
-	AvatarURL string `json:"pic_url"`
-	ID        string `json:"id"`
+	FirstName   string   `json:"first_name"`
+	LastName    string   `json:"last_name"`
+	DisplayName string   `json:"display_name"`
+	Email       string   `json:"email"`
+	AvatarURL   string   `json:"pic_url"`
+	ID          string   `json:"id"`
+	AccountID   string   `json:"account_id"`
+	Type        UserType `json:"type"`
+	Location    string   `json:"location"`
 }
 
 // New creates a new Zoom provider and sets up connection details.
@@ -60,6 +76,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -81,6 +107,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		AccessToken:  s.AccessToken,
 		Provider:     p.Name(),
 		RefreshToken: s.RefreshToken,
+		ExpiresAt:    s.ExpiresAt,
 	}
 
 	if user.AccessToken == "" {
@@ -109,6 +136,18 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	return user, err
 }
 
+// AccountID returns the ID of the Zoom account a user fetched by this provider belongs to.
+func AccountID(user goth.User) string {
+	accountID, _ := user.RawData["account_id"].(string)
+	return accountID
+}
+
+// GetUserType returns the plan type of a user fetched by this provider.
+func GetUserType(user goth.User) UserType {
+	userType, _ := user.RawData["type"].(float64)
+	return UserType(userType)
+}
+
 // RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
 	return true
@@ -170,8 +209,10 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	user.FirstName = u.FirstName
 	user.LastName = u.LastName
 	user.Name = fmt.Sprintf("%s %s", u.FirstName, u.LastName)
+	user.NickName = u.DisplayName
 	user.UserID = u.ID
 	user.AvatarURL = u.AvatarURL
+	user.Location = u.Location
 	user.RawData = rawData
 
 	return nil
@@ -1,7 +1,12 @@
 package zoom_test
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -53,3 +58,35 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(session.AuthURL, "https://app.zoom.io/oauth")
 	a.Equal(session.AccessToken, "1234567890")
 }
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v2/users/me" || req.Header.Get("Authorization") != "Bearer 1234567890" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(res, `{"id":"KDcuGIm1QgePTO8WbOqwIQ","first_name":"Jill","last_name":"Chill","display_name":"Jill Chill","email":"jchill@example.com","type":2,"account_id":"q6gBJVO5TzexKYTb_I2rpg","pic_url":"https://example.com/photo.jpg"}`)
+	}))
+	defer server.Close()
+
+	provider := zoomProvider()
+	provider.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return net.Dial(network, server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	user, err := provider.FetchUser(&zoom.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("KDcuGIm1QgePTO8WbOqwIQ", user.UserID)
+	a.Equal("Jill Chill", user.Name)
+	a.Equal("jchill@example.com", user.Email)
+	a.Equal("q6gBJVO5TzexKYTb_I2rpg", zoom.AccountID(user))
+	a.Equal(zoom.UserTypeLicensed, zoom.GetUserType(user))
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the limits in memory, for an application running a
+// single instance.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket is full again, and can be forgotten
+	full time.Time
+}
+
+// NewMemoryStore creates an empty memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Take takes a token of the bucket of the key.
+func (s *MemoryStore) Take(key string, limit Limit) (bool, time.Duration, error) {
+	if limit.Requests <= 0 || limit.Window <= 0 {
+		return false, limit.Window, nil
+	}
+	// the time to refill a token
+	interval := limit.Window / time.Duration(limit.Requests)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now, limit.Window)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), last: now}
+		s.buckets[key] = b
+	}
+	b.tokens += float64(now.Sub(b.last)) / float64(interval)
+	if b.tokens > float64(limit.Requests) {
+		b.tokens = float64(limit.Requests)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(interval)), nil
+	}
+	b.tokens--
+	b.full = now.Add(time.Duration((float64(limit.Requests) - b.tokens) * float64(interval)))
+	return true, 0, nil
+}
+
+// sweep forgets the full buckets, at most once per window.
+func (s *MemoryStore) sweep(now time.Time, window time.Duration) {
+	if now.Sub(s.lastSweep) < window {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package ratelimit rate-limits the authentication endpoints, e.g. the begin
+// and callback handlers of gothic, per IP address or subject, to blunt login
+// floods and the spraying of states. Requests over the limits get a 429
+// response with a Retry-After header.
+//
+//	limiter := ratelimit.New(ratelimit.NewMemoryStore(),
+//		ratelimit.Rule{Key: ratelimit.ByIP, Limit: ratelimit.Limit{Requests: 20, Window: time.Minute}},
+//		ratelimit.Rule{Key: ratelimit.ByFormValue("username"), Limit: ratelimit.Limit{Requests: 5, Window: time.Minute}},
+//	)
+//	http.Handle("/auth/", limiter.Handler(http.HandlerFunc(gothic.BeginAuthHandler)))
+//
+// The limits are kept by a Store, in memory by default; implement it with a
+// shared store, e.g. Redis, when the application runs several instances.
+package ratelimit
+
+import (
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows a number of requests in a window, refilled continuously: a
+// client over the limit may make a request again after Window/Requests.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Store keeps the limits by key.
+type Store interface {
+	// Take takes a request of the limit of the key, and returns whether it is
+	// allowed, or else the delay before the next request is.
+	Take(key string, limit Limit) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// KeyFunc returns the key of the limits of a request, or an empty key when
+// the rule doesn't apply to the request.
+type KeyFunc func(req *http.Request) string
+
+// ByIP is the key of the IP address of the connection of the request.
+func ByIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// ByFormValue returns the key of the subject posted in a form value or given
+// in the query, e.g. the username of the LDAP provider or the email of magic
+// links. The value is compared without case.
+func ByFormValue(name string) KeyFunc {
+	return func(req *http.Request) string {
+		return strings.ToLower(strings.TrimSpace(req.FormValue(name)))
+	}
+}
+
+// Rule limits the requests with the same key.
+type Rule struct {
+	// Name tells the keys of the rule apart in the store, e.g. "ip". It
+	// defaults to the index of the rule.
+	Name  string
+	Key   KeyFunc
+	Limit Limit
+}
+
+// Limiter rate-limits requests by its rules.
+type Limiter struct {
+	Store Store
+	Rules []Rule
+	// FailOpen allows the requests when the store fails. They are refused
+	// with a 503 response otherwise.
+	FailOpen bool
+	// ErrorHandler is called when the store fails. It defaults to logging the
+	// error.
+	ErrorHandler func(req *http.Request, err error)
+}
+
+// New creates a limiter of the rules keeping the limits in the store.
+func New(store Store, rules ...Rule) *Limiter {
+	return &Limiter{Store: store, Rules: rules}
+}
+
+// Allow takes a request of every rule applying to the request, and returns
+// whether it is allowed, or else the delay before the next request is.
+func (l *Limiter) Allow(req *http.Request) (allowed bool, retryAfter time.Duration, err error) {
+	allowed = true
+	for i, rule := range l.Rules {
+		key := rule.Key(req)
+		if key == "" {
+			continue
+		}
+		name := rule.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		ok, after, err := l.Store.Take(name+":"+key, rule.Limit)
+		if err != nil {
+			return false, 0, err
+		}
+		if !ok {
+			allowed = false
+			if after > retryAfter {
+				retryAfter = after
+			}
+		}
+	}
+	return allowed, retryAfter, nil
+}
+
+// Handler rate-limits the requests of the handler.
+func (l *Limiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		allowed, retryAfter, err := l.Allow(req)
+		if err != nil {
+			l.handleError(req, err)
+			if !l.FailOpen {
+				http.Error(res, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+			allowed = true
+		}
+		if !allowed {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			res.Header().Set("Retry-After", strconv.Itoa(seconds))
+			http.Error(res, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+func (l *Limiter) handleError(req *http.Request, err error) {
+	if l.ErrorHandler != nil {
+		l.ErrorHandler(req, err)
+		return
+	}
+	log.Printf("ratelimit: %v", err)
+}
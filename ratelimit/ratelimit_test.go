@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time { return c.t }
+
+func newStore() (*MemoryStore, *clock) {
+	c := &clock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := NewMemoryStore()
+	s.now = c.now
+	return s, c
+}
+
+func Test_MemoryStore(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	s, c := newStore()
+	limit := Limit{Requests: 2, Window: time.Minute}
+
+	for i := 0; i < 2; i++ {
+		allowed, _, err := s.Take("ip:192.0.2.1", limit)
+		a.NoError(err)
+		a.True(allowed)
+	}
+	allowed, retryAfter, _ := s.Take("ip:192.0.2.1", limit)
+	a.False(allowed)
+	a.Equal(30*time.Second, retryAfter)
+
+	allowed, _, _ = s.Take("ip:192.0.2.2", limit)
+	a.True(allowed)
+
+	c.t = c.t.Add(15 * time.Second)
+	_, retryAfter, _ = s.Take("ip:192.0.2.1", limit)
+	a.Equal(15*time.Second, retryAfter)
+	c.t = c.t.Add(15 * time.Second)
+	allowed, _, _ = s.Take("ip:192.0.2.1", limit)
+	a.True(allowed)
+
+	// the full buckets are forgotten
+	c.t = c.t.Add(2 * time.Minute)
+	_, _, _ = s.Take("ip:192.0.2.3", limit)
+	a.Len(s.buckets, 1)
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(key string, limit Limit) (bool, time.Duration, error) {
+	return false, 0, errors.New("unavailable")
+}
+
+func Test_Handler(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	s, _ := newStore()
+	limiter := New(s,
+		Rule{Name: "ip", Key: ByIP, Limit: Limit{Requests: 3, Window: time.Minute}},
+		Rule{Name: "username", Key: ByFormValue("username"), Limit: Limit{Requests: 1, Window: time.Minute}},
+	)
+	handler := limiter.Handler(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+
+	serve := func(username string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/auth/ldap/callback", strings.NewReader(url.Values{"username": {username}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = "192.0.2.1:1234"
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	a.Equal(http.StatusOK, serve("homer").Code)
+	res := serve("Homer")
+	a.Equal(http.StatusTooManyRequests, res.Code)
+	a.Equal("60", res.Header().Get("Retry-After"))
+	a.Equal(http.StatusOK, serve("marge").Code)
+	res = serve("bart")
+	a.Equal(http.StatusTooManyRequests, res.Code)
+	a.Equal("20", res.Header().Get("Retry-After"))
+
+	var failures int
+	limiter = New(failingStore{}, Rule{Key: ByIP, Limit: Limit{Requests: 1, Window: time.Minute}})
+	limiter.ErrorHandler = func(req *http.Request, err error) { failures++ }
+	res = httptest.NewRecorder()
+	limiter.Handler(http.NotFoundHandler()).ServeHTTP(res, httptest.NewRequest("GET", "/auth/github", nil))
+	a.Equal(http.StatusServiceUnavailable, res.Code)
+	limiter.FailOpen = true
+	res = httptest.NewRecorder()
+	limiter.Handler(http.NotFoundHandler()).ServeHTTP(res, httptest.NewRequest("GET", "/auth/github", nil))
+	a.Equal(http.StatusNotFound, res.Code)
+	a.Equal(2, failures)
+}
@@ -0,0 +1,246 @@
+package saml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type response struct {
+	XMLName      xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol Response"`
+	ID           string   `xml:"ID,attr"`
+	InResponseTo string   `xml:"InResponseTo,attr"`
+	Destination  string   `xml:"Destination,attr"`
+	Issuer       string   `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+	Status       struct {
+		StatusCode struct {
+			Value      string `xml:"Value,attr"`
+			StatusCode struct {
+				Value string `xml:"Value,attr"`
+			} `xml:"urn:oasis:names:tc:SAML:2.0:protocol StatusCode"`
+		} `xml:"urn:oasis:names:tc:SAML:2.0:protocol StatusCode"`
+		StatusMessage string `xml:"urn:oasis:names:tc:SAML:2.0:protocol StatusMessage"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:protocol Status"`
+	Assertion *assertion `xml:"urn:oasis:names:tc:SAML:2.0:assertion Assertion"`
+}
+
+type assertion struct {
+	XMLName xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:assertion Assertion"`
+	ID      string   `xml:"ID,attr"`
+	Issuer  string   `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+	Subject struct {
+		NameID struct {
+			Format string `xml:"Format,attr"`
+			Value  string `xml:",chardata"`
+		} `xml:"urn:oasis:names:tc:SAML:2.0:assertion NameID"`
+		SubjectConfirmations []struct {
+			Method string `xml:"Method,attr"`
+			Data   struct {
+				NotOnOrAfter string `xml:"NotOnOrAfter,attr"`
+				Recipient    string `xml:"Recipient,attr"`
+				InResponseTo string `xml:"InResponseTo,attr"`
+			} `xml:"urn:oasis:names:tc:SAML:2.0:assertion SubjectConfirmationData"`
+		} `xml:"urn:oasis:names:tc:SAML:2.0:assertion SubjectConfirmation"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:assertion Subject"`
+	Conditions struct {
+		NotBefore            string `xml:"NotBefore,attr"`
+		NotOnOrAfter         string `xml:"NotOnOrAfter,attr"`
+		AudienceRestrictions []struct {
+			Audiences []string `xml:"urn:oasis:names:tc:SAML:2.0:assertion Audience"`
+		} `xml:"urn:oasis:names:tc:SAML:2.0:assertion AudienceRestriction"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:assertion Conditions"`
+	AuthnStatements []struct {
+		SessionIndex        string `xml:"SessionIndex,attr"`
+		SessionNotOnOrAfter string `xml:"SessionNotOnOrAfter,attr"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:assertion AuthnStatement"`
+	AttributeStatements []struct {
+		Attributes []struct {
+			Name         string   `xml:"Name,attr"`
+			FriendlyName string   `xml:"FriendlyName,attr"`
+			Values       []string `xml:"urn:oasis:names:tc:SAML:2.0:assertion AttributeValue"`
+		} `xml:"urn:oasis:names:tc:SAML:2.0:assertion Attribute"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:assertion AttributeStatement"`
+}
+
+// parseResponse verifies the base64 encoded response posted by the identity
+// provider for the session, and returns its assertion. Either the response or
+// the assertion must be signed by the identity provider.
+func (p *Provider) parseResponse(encoded string, s *Session) (*assertion, error) {
+	if p.IdP == nil || len(p.IdP.Certificates) == 0 {
+		return nil, errors.New("saml: the identity provider has no certificate")
+	}
+	if p.IdP.EntityID == "" {
+		return nil, errors.New("saml: the identity provider has no entity ID")
+	}
+	now := p.now()
+
+	raw, err := decodeBase64(encoded)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseXML(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !is(root, nsProtocol, "Response") {
+		return nil, errors.New("saml: not a SAML response")
+	}
+
+	assertions := childElements(root, nsAssertion, "Assertion")
+	if len(assertions) == 0 && len(childElements(root, nsAssertion, "EncryptedAssertion")) > 0 {
+		return nil, ErrEncryptedAssertion
+	}
+	if len(assertions) > 1 {
+		return nil, errors.New("saml: response has more than one assertion")
+	}
+
+	// everything is read from the signed bytes only, so nothing can be
+	// injected next to the signed elements
+	resp := &response{}
+	signedResponse, err := verifySignature(root, p.IdP.Certificates, now)
+	switch {
+	case err == nil:
+		err = xml.Unmarshal(signedResponse, resp)
+		if err != nil {
+			return nil, err
+		}
+	case err == errSignatureMissing:
+		err = xml.Unmarshal(raw, resp)
+		if err != nil {
+			return nil, err
+		}
+		resp.Assertion = nil
+		if len(assertions) == 0 {
+			break
+		}
+		signedAssertion, err := verifySignature(assertions[0], p.IdP.Certificates, now)
+		if err != nil {
+			return nil, err
+		}
+		resp.Assertion = &assertion{}
+		err = xml.Unmarshal(signedAssertion, resp.Assertion)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if code := resp.Status.StatusCode.Value; code != statusSuccess {
+		if sub := resp.Status.StatusCode.StatusCode.Value; sub != "" {
+			code += " (" + sub + ")"
+		}
+		return nil, fmt.Errorf("saml: authentication failed with status %s: %s", code, resp.Status.StatusMessage)
+	}
+	if resp.Assertion == nil {
+		return nil, errors.New("saml: response has no assertion")
+	}
+	if resp.Destination != "" && resp.Destination != p.CallbackURL {
+		return nil, fmt.Errorf("saml: response sent to %q", resp.Destination)
+	}
+	if resp.InResponseTo != "" && resp.InResponseTo != s.RequestID {
+		return nil, errors.New("saml: response to another authentication request")
+	}
+	if resp.Issuer != "" && resp.Issuer != p.IdP.EntityID {
+		return nil, fmt.Errorf("saml: response issued by %q", resp.Issuer)
+	}
+
+	expiresAt, err := p.validateAssertion(resp.Assertion, s, now)
+	if err != nil {
+		return nil, err
+	}
+	if p.Assertions != nil {
+		// the assertion can't be used again until it has expired
+		err = p.Assertions.UseAssertion(resp.Assertion.ID, expiresAt.Add(p.ClockSkew))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp.Assertion, nil
+}
+
+// validateAssertion checks the assertion is meant for this service provider,
+// for the authentication request of the session, and currently valid. It
+// returns the expiry of its bearer subject confirmation.
+func (p *Provider) validateAssertion(a *assertion, s *Session, now time.Time) (time.Time, error) {
+	if a.ID == "" {
+		return time.Time{}, errors.New("saml: assertion has no ID")
+	}
+	if a.Issuer != p.IdP.EntityID {
+		return time.Time{}, fmt.Errorf("saml: assertion issued by %q", a.Issuer)
+	}
+
+	if a.Conditions.NotBefore != "" {
+		notBefore, err := time.Parse(time.RFC3339, a.Conditions.NotBefore)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if now.Add(p.ClockSkew).Before(notBefore) {
+			return time.Time{}, errors.New("saml: assertion is not yet valid")
+		}
+	}
+	if a.Conditions.NotOnOrAfter != "" {
+		notOnOrAfter, err := time.Parse(time.RFC3339, a.Conditions.NotOnOrAfter)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if !now.Add(-p.ClockSkew).Before(notOnOrAfter) {
+			return time.Time{}, errors.New("saml: assertion has expired")
+		}
+	}
+
+	// every audience restriction must include this service provider
+	if len(a.Conditions.AudienceRestrictions) == 0 {
+		return time.Time{}, errors.New("saml: assertion has no audience restriction")
+	}
+	for _, restriction := range a.Conditions.AudienceRestrictions {
+		found := false
+		for _, audience := range restriction.Audiences {
+			if audience == p.EntityID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return time.Time{}, errors.New("saml: assertion is meant for another audience")
+		}
+	}
+
+	if a.Subject.NameID.Value == "" {
+		return time.Time{}, errors.New("saml: assertion has no subject")
+	}
+
+	var lastErr error = errors.New("saml: assertion has no bearer subject confirmation")
+	for _, confirmation := range a.Subject.SubjectConfirmations {
+		if confirmation.Method != methodBearer {
+			continue
+		}
+		var expiresAt time.Time
+		expiresAt, lastErr = p.validateSubjectConfirmation(confirmation.Data.Recipient, confirmation.Data.InResponseTo, confirmation.Data.NotOnOrAfter, s, now)
+		if lastErr == nil {
+			return expiresAt, nil
+		}
+	}
+	return time.Time{}, lastErr
+}
+
+func (p *Provider) validateSubjectConfirmation(recipient, inResponseTo, notOnOrAfter string, s *Session, now time.Time) (time.Time, error) {
+	if recipient != p.CallbackURL {
+		return time.Time{}, fmt.Errorf("saml: assertion sent to %q", recipient)
+	}
+	if inResponseTo != s.RequestID {
+		return time.Time{}, errors.New("saml: assertion for another authentication request")
+	}
+	if notOnOrAfter == "" {
+		return time.Time{}, errors.New("saml: subject confirmation has no NotOnOrAfter")
+	}
+	expiry, err := time.Parse(time.RFC3339, notOnOrAfter)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !now.Add(-p.ClockSkew).Before(expiry) {
+		return time.Time{}, errors.New("saml: subject confirmation has expired")
+	}
+	return expiry, nil
+}
@@ -0,0 +1,461 @@
+// Package saml implements a SAML 2.0 service provider for SP-initiated single
+// sign-on, so identity providers speaking SAML can be used like any other goth
+// provider.
+//
+// The authentication request is sent with the HTTP-Redirect binding, and the
+// identity provider posts its response to the assertion consumer service (ACS)
+// URL with the HTTP-POST binding. With gothic the ACS URL is the callback URL
+// of the provider, which calls gothic.CompleteUserAuth as usual. As the
+// response is posted cross-site, the session cookie must be sent with such
+// requests, which requires `SameSite=None` and `Secure` on the gothic store.
+//
+// The service provider metadata is published with gothic.MetadataHandler.
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// SAML namespaces, bindings and formats.
+const (
+	nsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+
+	BindingHTTPRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	BindingHTTPPost     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+
+	NameIDFormatUnspecified  = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+	NameIDFormatEmailAddress = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	NameIDFormatPersistent   = "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"
+	NameIDFormatTransient    = "urn:oasis:names:tc:SAML:2.0:nameid-format:transient"
+
+	statusSuccess = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	methodBearer  = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+
+	signatureAlgorithmRSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+
+	// MetadataContentType is the media type of SAML metadata.
+	MetadataContentType = "application/samlmetadata+xml"
+)
+
+// ErrEncryptedAssertion is returned when the identity provider sends an
+// encrypted assertion, which isn't supported. Disable the encryption of
+// assertions for the service provider in the identity provider.
+var ErrEncryptedAssertion = errors.New("saml: encrypted assertions are not supported")
+
+// ErrAssertionReplayed is returned for assertions which have already been used.
+var ErrAssertionReplayed = errors.New("saml: the assertion has already been used")
+
+// AssertionStore remembers the assertions which have been used, until they
+// expire.
+type AssertionStore interface {
+	// UseAssertion records the use of the assertion, or returns
+	// ErrAssertionReplayed.
+	UseAssertion(id string, expiresAt time.Time) error
+}
+
+// NewMemoryAssertionStore returns an AssertionStore which only knows about
+// the assertions used with this process.
+func NewMemoryAssertionStore() AssertionStore {
+	return &memoryAssertionStore{used: map[string]time.Time{}, now: goth.Now}
+}
+
+type memoryAssertionStore struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+	now  func() time.Time
+}
+
+func (m *memoryAssertionStore) UseAssertion(id string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for usedID, expiry := range m.used {
+		if now.After(expiry) {
+			delete(m.used, usedID)
+		}
+	}
+	if _, ok := m.used[id]; ok {
+		return ErrAssertionReplayed
+	}
+	m.used[id] = expiresAt
+	return nil
+}
+
+// DefaultAttributeMap maps the attribute names commonly used by identity
+// providers (as OID, claim URI or plain name) to the fields of goth.User.
+var DefaultAttributeMap = AttributeMap{
+	Email: []string{
+		"urn:oid:0.9.2342.19200300.100.1.3",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress",
+		"email", "mail", "emailAddress",
+	},
+	FirstName: []string{
+		"urn:oid:2.5.4.42",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname",
+		"givenName", "firstName", "first_name",
+	},
+	LastName: []string{
+		"urn:oid:2.5.4.4",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/surname",
+		"sn", "surname", "lastName", "last_name",
+	},
+	Name: []string{
+		"urn:oid:2.16.840.1.113730.3.1.241",
+		"http://schemas.microsoft.com/identity/claims/displayname",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name",
+		"displayName", "cn", "name",
+	},
+	NickName: []string{
+		"urn:oid:0.9.2342.19200300.100.1.1",
+		"uid", "username",
+	},
+	UserID: []string{},
+}
+
+// AttributeMap lists, for the fields of goth.User, the names of the assertion
+// attributes they are read from. The first attribute found is used. The UserID
+// is the NameID of the subject unless one of its attributes is found.
+type AttributeMap struct {
+	Email     []string
+	Name      []string
+	FirstName []string
+	LastName  []string
+	NickName  []string
+	UserID    []string
+}
+
+// IdPMetadata describes the identity provider.
+type IdPMetadata struct {
+	// EntityID is the issuer of the responses of the identity provider. It is
+	// required, the assertions of other issuers are rejected.
+	EntityID string
+	// SSOURL is the single sign-on service using the HTTP-Redirect binding.
+	SSOURL string
+	// Certificates are the certificates the identity provider signs with.
+	Certificates []*x509.Certificate
+}
+
+// ParseIdPMetadata reads the EntityDescriptor published by an identity provider.
+func ParseIdPMetadata(data []byte) (*IdPMetadata, error) {
+	descriptor := struct {
+		EntityID string `xml:"entityID,attr"`
+		IDP      *struct {
+			KeyDescriptors []struct {
+				Use          string   `xml:"use,attr"`
+				Certificates []string `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo>X509Data>X509Certificate"`
+			} `xml:"urn:oasis:names:tc:SAML:2.0:metadata KeyDescriptor"`
+			SingleSignOnServices []struct {
+				Binding  string `xml:"Binding,attr"`
+				Location string `xml:"Location,attr"`
+			} `xml:"urn:oasis:names:tc:SAML:2.0:metadata SingleSignOnService"`
+		} `xml:"urn:oasis:names:tc:SAML:2.0:metadata IDPSSODescriptor"`
+	}{}
+	err := xml.Unmarshal(data, &descriptor)
+	if err != nil {
+		return nil, err
+	}
+	if descriptor.IDP == nil {
+		return nil, errors.New("saml: metadata has no IDPSSODescriptor")
+	}
+
+	idp := &IdPMetadata{EntityID: descriptor.EntityID}
+	for _, service := range descriptor.IDP.SingleSignOnServices {
+		if service.Binding == BindingHTTPRedirect {
+			idp.SSOURL = service.Location
+			break
+		}
+	}
+	if idp.SSOURL == "" {
+		return nil, errors.New("saml: identity provider has no HTTP-Redirect single sign-on service")
+	}
+
+	for _, key := range descriptor.IDP.KeyDescriptors {
+		if key.Use != "" && key.Use != "signing" {
+			continue
+		}
+		for _, encoded := range key.Certificates {
+			der, err := decodeBase64(encoded)
+			if err != nil {
+				return nil, err
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, err
+			}
+			idp.Certificates = append(idp.Certificates, cert)
+		}
+	}
+	if len(idp.Certificates) == 0 {
+		return nil, errors.New("saml: identity provider has no signing certificate")
+	}
+	return idp, nil
+}
+
+// New creates a new SAML provider for the service provider identified by
+// entityID, whose assertion consumer service is acsURL.
+// You should always call `saml.New` to get a new Provider. Never try to create
+// one manually.
+func New(entityID, acsURL string, idp *IdPMetadata) *Provider {
+	return &Provider{
+		EntityID:     entityID,
+		CallbackURL:  acsURL,
+		IdP:          idp,
+		NameIDFormat: NameIDFormatUnspecified,
+		AttributeMap: DefaultAttributeMap,
+		ClockSkew:    3 * time.Minute,
+		Assertions:   NewMemoryAssertionStore(),
+		providerName: "saml",
+		now:          goth.Now,
+	}
+}
+
+// Provider is the implementation of `goth.Provider` for a SAML identity provider.
+type Provider struct {
+	EntityID     string
+	CallbackURL  string
+	IdP          *IdPMetadata
+	NameIDFormat string
+	AttributeMap AttributeMap
+	// ClockSkew is the difference tolerated between the clocks of the
+	// identity provider and the service provider.
+	ClockSkew time.Duration
+	// Assertions rejects replayed assertions. Applications running more than
+	// one instance should share it between them.
+	Assertions   AssertionStore
+	HTTPClient   *http.Client
+	key          *rsa.PrivateKey
+	certificate  *x509.Certificate
+	providerName string
+	now          func() time.Time
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Local reports that the provider is a goth.LocalProvider: the assertions are verified during the login, not the users fetched with a token.
+func (p *Provider) Local() bool {
+	return true
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the saml package.
+func (p *Provider) Debug(debug bool) {}
+
+// SetSigningKey sets the key the authentication requests are signed with.
+// The certificate is published in the metadata of the service provider.
+func (p *Provider) SetSigningKey(key *rsa.PrivateKey, certificate *x509.Certificate) {
+	p.key = key
+	p.certificate = certificate
+}
+
+// BeginAuth creates an authentication request and the URL of the identity
+// provider it is sent to. The state is sent as the RelayState.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	if p.IdP == nil || p.IdP.SSOURL == "" {
+		return nil, errors.New("saml: the identity provider has no single sign-on URL")
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	request := &bytes.Buffer{}
+	request.WriteString(`<samlp:AuthnRequest xmlns:samlp="` + nsProtocol + `" xmlns:saml="` + nsAssertion + `"`)
+	writeAttr(request, "ID", id)
+	writeAttr(request, "Version", "2.0")
+	writeAttr(request, "IssueInstant", p.now().UTC().Format(time.RFC3339))
+	writeAttr(request, "Destination", p.IdP.SSOURL)
+	writeAttr(request, "AssertionConsumerServiceURL", p.CallbackURL)
+	writeAttr(request, "ProtocolBinding", BindingHTTPPost)
+	request.WriteString(`><saml:Issuer>`)
+	escapeText(request, p.EntityID)
+	request.WriteString(`</saml:Issuer><samlp:NameIDPolicy`)
+	writeAttr(request, "Format", p.NameIDFormat)
+	writeAttr(request, "AllowCreate", "true")
+	request.WriteString(`/></samlp:AuthnRequest>`)
+
+	deflated := &bytes.Buffer{}
+	w, err := flate.NewWriter(deflated, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(request.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// the signature of the HTTP-Redirect binding covers the parameters in
+	// this order, exactly as they are encoded in the URL
+	query := "SAMLRequest=" + url.QueryEscape(base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	if state != "" {
+		query += "&RelayState=" + url.QueryEscape(state)
+	}
+	if p.key != nil {
+		query += "&SigAlg=" + url.QueryEscape(signatureAlgorithmRSASHA256)
+		hashed := crypto.SHA256.New()
+		hashed.Write([]byte(query))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, hashed.Sum(nil))
+		if err != nil {
+			return nil, err
+		}
+		query += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(signature))
+	}
+
+	authURL := p.IdP.SSOURL
+	if strings.Contains(authURL, "?") {
+		authURL += "&" + query
+	} else {
+		authURL += "?" + query
+	}
+
+	session := &Session{
+		AuthURL:    authURL,
+		RequestID:  id,
+		RelayState: state,
+	}
+	return session, nil
+}
+
+// FetchUser maps the subject and the attributes of the assertion to a
+// goth.User. The identity provider isn't contacted, everything comes from the
+// assertion received by Authorize.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	s := session.(*Session)
+	user := goth.User{
+		Provider:  p.Name(),
+		UserID:    s.NameID,
+		ExpiresAt: s.ExpiresAt,
+	}
+
+	if s.NameID == "" {
+		// data is not yet retrieved since the assertion hasn't been received
+		return user, fmt.Errorf("%s cannot get user information without a SAML assertion", p.providerName)
+	}
+
+	user.RawData = make(map[string]interface{}, len(s.Attributes)+3)
+	for name, values := range s.Attributes {
+		if len(values) == 1 {
+			user.RawData[name] = values[0]
+		} else {
+			user.RawData[name] = values
+		}
+	}
+	user.RawData["NameID"] = s.NameID
+	user.RawData["NameIDFormat"] = s.NameIDFormat
+	user.RawData["SessionIndex"] = s.SessionIndex
+
+	if v := s.attribute(p.AttributeMap.UserID); v != "" {
+		user.UserID = v
+	}
+	user.Email = s.attribute(p.AttributeMap.Email)
+	if user.Email == "" && s.NameIDFormat == NameIDFormatEmailAddress {
+		user.Email = s.NameID
+	}
+	user.FirstName = s.attribute(p.AttributeMap.FirstName)
+	user.LastName = s.attribute(p.AttributeMap.LastName)
+	user.Name = s.attribute(p.AttributeMap.Name)
+	if user.Name == "" {
+		user.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+	}
+	user.NickName = s.attribute(p.AttributeMap.NickName)
+	return user, nil
+}
+
+// Metadata returns the metadata of the service provider, to be registered
+// with the identity provider. It implements goth.MetadataProvider.
+func (p *Provider) Metadata() (string, []byte, error) {
+	metadata := &bytes.Buffer{}
+	metadata.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	metadata.WriteString(`<md:EntityDescriptor xmlns:md="` + nsMetadata + `"`)
+	writeAttr(metadata, "entityID", p.EntityID)
+	metadata.WriteString(`><md:SPSSODescriptor protocolSupportEnumeration="` + nsProtocol + `"`)
+	writeAttr(metadata, "AuthnRequestsSigned", fmt.Sprint(p.key != nil))
+	writeAttr(metadata, "WantAssertionsSigned", "true")
+	metadata.WriteString(`>`)
+	if p.certificate != nil {
+		metadata.WriteString(`<md:KeyDescriptor use="signing"><ds:KeyInfo xmlns:ds="` + nsDSig + `"><ds:X509Data><ds:X509Certificate>`)
+		metadata.WriteString(base64.StdEncoding.EncodeToString(p.certificate.Raw))
+		metadata.WriteString(`</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>`)
+	}
+	metadata.WriteString(`<md:NameIDFormat>`)
+	escapeText(metadata, p.NameIDFormat)
+	metadata.WriteString(`</md:NameIDFormat><md:AssertionConsumerService`)
+	writeAttr(metadata, "Binding", BindingHTTPPost)
+	writeAttr(metadata, "Location", p.CallbackURL)
+	writeAttr(metadata, "index", "0")
+	writeAttr(metadata, "isDefault", "true")
+	metadata.WriteString(`/></md:SPSSODescriptor></md:EntityDescriptor>` + "\n")
+	return MetadataContentType, metadata.Bytes(), nil
+}
+
+// RefreshToken refresh token is not provided by saml
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by saml")
+}
+
+// RefreshTokenAvailable refresh token is not provided by saml
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
+
+// newID returns a random ID. IDs must not start with a digit.
+func newID() (string, error) {
+	b, err := goth.RandomBytes(20)
+	if err != nil {
+		return "", err
+	}
+	return "id-" + hex.EncodeToString(b), nil
+}
+
+func writeAttr(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(" " + name + `="`)
+	escapeAttr(buf, value)
+	buf.WriteByte('"')
+}
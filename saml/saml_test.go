@@ -0,0 +1,344 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testEntityID = "https://sp.example.com/saml"
+	testACSURL   = "https://sp.example.com/auth/saml/callback"
+	testIssuer   = "https://idp.example.com"
+	testSSOURL   = "https://idp.example.com/sso"
+)
+
+var testNow = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.EntityID, testEntityID)
+	a.Equal(p.CallbackURL, testACSURL)
+	a.Equal(p.Name(), "saml")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+	a.Implements((*goth.MetadataProvider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*Session)
+	a.True(strings.HasPrefix(s.AuthURL, testSSOURL+"?SAMLRequest="))
+	a.Equal("test_state", s.RelayState)
+
+	u, err := url.Parse(s.AuthURL)
+	a.NoError(err)
+	a.Equal("test_state", u.Query().Get("RelayState"))
+	a.Empty(u.Query().Get("Signature"))
+
+	request := inflate(t, u.Query().Get("SAMLRequest"))
+	a.Contains(request, `ID="`+s.RequestID+`"`)
+	a.Contains(request, `AssertionConsumerServiceURL="`+testACSURL+`"`)
+	a.Contains(request, `Destination="`+testSSOURL+`"`)
+	a.Contains(request, `<saml:Issuer>`+testEntityID+`</saml:Issuer>`)
+}
+
+func Test_BeginAuthSigned(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	generateTestKeys()
+	p.SetSigningKey(testKey, testCert)
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	authURL := session.(*Session).AuthURL
+
+	// the signature covers the parameters as they are encoded in the URL
+	query := authURL[strings.Index(authURL, "?")+1:]
+	signed := query[:strings.Index(query, "&Signature=")]
+	u, _ := url.Parse(authURL)
+	a.Equal(signatureAlgorithmRSASHA256, u.Query().Get("SigAlg"))
+	signature, err := base64.StdEncoding.DecodeString(u.Query().Get("Signature"))
+	a.NoError(err)
+	hashed := sha256.Sum256([]byte(signed))
+	a.NoError(rsa.VerifyPKCS1v15(&testKey.PublicKey, crypto.SHA256, hashed[:], signature))
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	s := &Session{RequestID: "id-request", RelayState: "test_state"}
+
+	nameID, err := s.Authorize(p, url.Values{
+		"SAMLResponse": {encode(testResponse(sign(testAssertion(), "</saml:Issuer>")))},
+		"RelayState":   {"test_state"},
+	})
+	a.NoError(err)
+	a.Equal("jdoe@example.com", nameID)
+	a.Equal("idx-1", s.SessionIndex)
+	a.Equal(testNow.Add(8*time.Hour), s.ExpiresAt)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("saml", user.Provider)
+	a.Equal("jdoe@example.com", user.UserID)
+	a.Equal("john.doe@example.com", user.Email)
+	a.Equal("John", user.FirstName)
+	a.Equal("Doe", user.LastName)
+	a.Equal("John Doe", user.Name)
+	a.Equal("jdoe", user.NickName)
+	a.Equal([]string{"admins", "users"}, user.RawData["groups"])
+	a.Equal("John", user.RawData["givenName"])
+}
+
+func Test_AuthorizeRejectsReplay(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	response := encode(testResponse(sign(testAssertion(), "</saml:Issuer>")))
+
+	_, err := (&Session{RequestID: "id-request"}).Authorize(p, url.Values{"SAMLResponse": {response}})
+	a.NoError(err)
+	_, err = (&Session{RequestID: "id-request"}).Authorize(p, url.Values{"SAMLResponse": {response}})
+	a.Equal(ErrAssertionReplayed, err)
+}
+
+func Test_AuthorizeRequiresIssuer(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	p.IdP.EntityID = ""
+
+	_, err := (&Session{RequestID: "id-request"}).Authorize(p, url.Values{"SAMLResponse": {encode(testResponse(sign(testAssertion(), "</saml:Issuer>")))}})
+	a.EqualError(err, "saml: the identity provider has no entity ID")
+}
+
+func Test_AuthorizeSignedResponse(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	s := &Session{RequestID: "id-request"}
+
+	_, err := s.Authorize(p, url.Values{"SAMLResponse": {encode(sign(testResponse(testAssertion()), "</saml:Issuer>"))}})
+	a.NoError(err)
+	a.Equal("jdoe@example.com", s.NameID)
+}
+
+func Test_AuthorizeRejectsInvalidResponses(t *testing.T) {
+	t.Parallel()
+
+	signedAssertion := sign(testAssertion(), "</saml:Issuer>")
+	tests := map[string]struct {
+		response string
+		session  Session
+		err      string
+	}{
+		"unsigned": {
+			response: testResponse(testAssertion()),
+			err:      "saml: element is not signed",
+		},
+		"tampered": {
+			response: testResponse(strings.Replace(signedAssertion, "John", "Jane", 1)),
+			err:      "saml: invalid signature: Signature could not be verified",
+		},
+		"wrapped": {
+			response: testResponse(strings.Replace(testAssertion(), "jdoe@example.com", "admin@example.com", 1) + "<samlp:Extensions>" + signedAssertion + "</samlp:Extensions>"),
+			err:      "saml: element is not signed",
+		},
+		"two assertions": {
+			response: testResponse(signedAssertion + signedAssertion),
+			err:      "saml: response has more than one assertion",
+		},
+		"other request": {
+			response: testResponse(signedAssertion),
+			session:  Session{RequestID: "id-other"},
+			err:      "saml: response to another authentication request",
+		},
+		"wrong audience": {
+			response: testResponse(sign(strings.Replace(testAssertion(), testEntityID, "https://other.example.com", 1), "</saml:Issuer>")),
+			err:      "saml: assertion is meant for another audience",
+		},
+		"wrong recipient": {
+			response: testResponse(sign(strings.Replace(testAssertion(), `Recipient="`+testACSURL, `Recipient="https://other.example.com`, 1), "</saml:Issuer>")),
+			err:      `saml: assertion sent to "https://other.example.com"`,
+		},
+		"expired": {
+			response: testResponse(sign(strings.Replace(testAssertion(), `NotBefore="2024-05-01T11:59:00Z" NotOnOrAfter="2024-05-01T12:05:00Z"`, `NotBefore="2024-05-01T11:50:00Z" NotOnOrAfter="2024-05-01T11:55:00Z"`, 1), "</saml:Issuer>")),
+			err:      "saml: assertion has expired",
+		},
+		"failed": {
+			response: strings.Replace(testResponse(""), "status:Success", "status:Responder", 1),
+			err:      "saml: authentication failed with status urn:oasis:names:tc:SAML:2.0:status:Responder: ",
+		},
+		"encrypted": {
+			response: testResponse(`<saml:EncryptedAssertion></saml:EncryptedAssertion>`),
+			err:      ErrEncryptedAssertion.Error(),
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			a := assert.New(t)
+			s := &test.session
+			if s.RequestID == "" {
+				s.RequestID = "id-request"
+			}
+			_, err := s.Authorize(provider(), url.Values{"SAMLResponse": {encode(test.response)}})
+			a.EqualError(err, test.err)
+			a.Empty(s.NameID)
+		})
+	}
+}
+
+func Test_AuthorizeRejectsRelayStateMismatch(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	s := &Session{RequestID: "id-request", RelayState: "test_state"}
+
+	_, err := s.Authorize(provider(), url.Values{
+		"SAMLResponse": {encode(testResponse(sign(testAssertion(), "</saml:Issuer>")))},
+		"RelayState":   {"other_state"},
+	})
+	a.EqualError(err, "saml: RelayState mismatch")
+}
+
+func Test_FetchUserWithoutAssertion(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	_, err := provider().FetchUser(&Session{})
+	a.Error(err)
+}
+
+func Test_Metadata(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	generateTestKeys()
+	p.SetSigningKey(testKey, testCert)
+
+	contentType, metadata, err := p.Metadata()
+	a.NoError(err)
+	a.Equal(MetadataContentType, contentType)
+	a.Contains(string(metadata), `entityID="`+testEntityID+`"`)
+	a.Contains(string(metadata), `AuthnRequestsSigned="true"`)
+	a.Contains(string(metadata), `Location="`+testACSURL+`"`)
+	a.Contains(string(metadata), base64.StdEncoding.EncodeToString(testCertificate().Raw))
+}
+
+func Test_ParseIdPMetadata(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp, err := ParseIdPMetadata([]byte(`<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="` + testIssuer + `">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:X509Data><ds:X509Certificate>
+        ` + base64.StdEncoding.EncodeToString(testCertificate().Raw) + `
+      </ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp.example.com/sso/post"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="` + testSSOURL + `"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`))
+	a.NoError(err)
+	a.Equal(testIssuer, idp.EntityID)
+	a.Equal(testSSOURL, idp.SSOURL)
+	a.Len(idp.Certificates, 1)
+	a.True(idp.Certificates[0].Equal(testCertificate()))
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://idp.example.com/sso?SAMLRequest=x","RequestID":"id-request","NameID":"jdoe"}`)
+	a.NoError(err)
+
+	s := session.(*Session)
+	a.Equal(s.AuthURL, "https://idp.example.com/sso?SAMLRequest=x")
+	a.Equal(s.RequestID, "id-request")
+	a.Equal(s.NameID, "jdoe")
+}
+
+func provider() *Provider {
+	p := New(testEntityID, testACSURL, &IdPMetadata{
+		EntityID:     testIssuer,
+		SSOURL:       testSSOURL,
+		Certificates: []*x509.Certificate{testCertificate()},
+	})
+	p.now = func() time.Time { return testNow }
+	p.Assertions = &memoryAssertionStore{used: map[string]time.Time{}, now: p.now}
+	return p
+}
+
+func testAssertion() string {
+	return `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="id-assertion" Version="2.0" IssueInstant="2024-05-01T11:59:50Z">` +
+		`<saml:Issuer>` + testIssuer + `</saml:Issuer>` +
+		`<saml:Subject><saml:NameID Format="` + NameIDFormatUnspecified + `">jdoe@example.com</saml:NameID>` +
+		`<saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">` +
+		`<saml:SubjectConfirmationData InResponseTo="id-request" NotOnOrAfter="2024-05-01T12:05:00Z" Recipient="` + testACSURL + `"/>` +
+		`</saml:SubjectConfirmation></saml:Subject>` +
+		`<saml:Conditions NotBefore="2024-05-01T11:59:00Z" NotOnOrAfter="2024-05-01T12:05:00Z">` +
+		`<saml:AudienceRestriction><saml:Audience>` + testEntityID + `</saml:Audience></saml:AudienceRestriction></saml:Conditions>` +
+		`<saml:AuthnStatement AuthnInstant="2024-05-01T11:59:50Z" SessionIndex="idx-1" SessionNotOnOrAfter="2024-05-01T20:00:00Z"/>` +
+		`<saml:AttributeStatement>` +
+		`<saml:Attribute Name="urn:oid:0.9.2342.19200300.100.1.3" FriendlyName="mail"><saml:AttributeValue>john.doe@example.com</saml:AttributeValue></saml:Attribute>` +
+		`<saml:Attribute Name="urn:oid:2.5.4.42" FriendlyName="givenName"><saml:AttributeValue>John</saml:AttributeValue></saml:Attribute>` +
+		`<saml:Attribute Name="urn:oid:2.5.4.4" FriendlyName="sn"><saml:AttributeValue>Doe</saml:AttributeValue></saml:Attribute>` +
+		`<saml:Attribute Name="uid"><saml:AttributeValue>jdoe</saml:AttributeValue></saml:Attribute>` +
+		`<saml:Attribute Name="groups"><saml:AttributeValue>admins</saml:AttributeValue><saml:AttributeValue>users</saml:AttributeValue></saml:Attribute>` +
+		`</saml:AttributeStatement></saml:Assertion>`
+}
+
+func testResponse(assertions string) string {
+	return `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="id-response" Version="2.0" IssueInstant="2024-05-01T11:59:50Z" Destination="` + testACSURL + `" InResponseTo="id-request">` +
+		`<saml:Issuer>` + testIssuer + `</saml:Issuer>` +
+		`<samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>` +
+		assertions + `</samlp:Response>`
+}
+
+func encode(response string) string {
+	return base64.StdEncoding.EncodeToString([]byte(response))
+}
+
+func inflate(t *testing.T, encoded string) string {
+	deflated, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inflated, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(inflated)
+}
@@ -0,0 +1,104 @@
+package saml
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Session stores data during the auth process with a SAML identity provider.
+type Session struct {
+	AuthURL      string
+	RequestID    string // ID of the authentication request, the response must refer to it
+	RelayState   string
+	NameID       string
+	NameIDFormat string
+	SessionIndex string
+	Attributes   map[string][]string
+	ExpiresAt    time.Time
+}
+
+var _ goth.Session = &Session{}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` function on the SAML provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize the session with the SAMLResponse the identity provider posted to
+// the assertion consumer service, and return the NameID of the subject.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	if s.RelayState != "" && params.Get("RelayState") != s.RelayState {
+		return "", errors.New("saml: RelayState mismatch")
+	}
+	if params.Get("SAMLResponse") == "" {
+		return "", errors.New("saml: no SAMLResponse received")
+	}
+
+	a, err := p.parseResponse(params.Get("SAMLResponse"), s)
+	if err != nil {
+		return "", err
+	}
+
+	s.NameID = a.Subject.NameID.Value
+	s.NameIDFormat = a.Subject.NameID.Format
+	s.Attributes = make(map[string][]string)
+	for _, statement := range a.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			values := make([]string, 0, len(attribute.Values))
+			for _, value := range attribute.Values {
+				values = append(values, strings.TrimSpace(value))
+			}
+			s.Attributes[attribute.Name] = append(s.Attributes[attribute.Name], values...)
+			if attribute.FriendlyName != "" && attribute.FriendlyName != attribute.Name {
+				if _, ok := s.Attributes[attribute.FriendlyName]; !ok {
+					s.Attributes[attribute.FriendlyName] = values
+				}
+			}
+		}
+	}
+	for _, statement := range a.AuthnStatements {
+		s.SessionIndex = statement.SessionIndex
+		if statement.SessionNotOnOrAfter != "" {
+			s.ExpiresAt, err = time.Parse(time.RFC3339, statement.SessionNotOnOrAfter)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	return s.NameID, nil
+}
+
+// attribute returns the first value of the first of the attributes found.
+func (s Session) attribute(names []string) string {
+	for _, name := range names {
+		if values := s.Attributes[name]; len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return goth.RedactJSON(s.Marshal())
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	s := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
+	return s, err
+}
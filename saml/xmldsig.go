@@ -0,0 +1,142 @@
+package saml
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+// nsDSig is the namespace of XML signatures.
+const nsDSig = "http://www.w3.org/2000/09/xmldsig#"
+
+// errSignatureMissing is returned by verifySignature when the element isn't signed.
+var errSignatureMissing = errors.New("saml: element is not signed")
+
+// parseXML parses a document into its root element. Documents with a DTD are
+// rejected.
+func parseXML(data []byte) (*etree.Element, error) {
+	doc := etree.NewDocument()
+	err := doc.ReadFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range doc.Child {
+		if _, ok := token.(*etree.Directive); ok {
+			return nil, errors.New("saml: documents with a DTD are not supported")
+		}
+	}
+	root := doc.Root()
+	if root == nil {
+		return nil, errors.New("saml: incomplete XML document")
+	}
+	return root, nil
+}
+
+// is reports whether the element has the namespace and the local name.
+func is(e *etree.Element, namespace, local string) bool {
+	return e.Tag == local && e.NamespaceURI() == namespace
+}
+
+func childElements(e *etree.Element, namespace, local string) []*etree.Element {
+	var children []*etree.Element
+	for _, child := range e.ChildElements() {
+		if is(child, namespace, local) {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// verifySignature verifies the enveloped signature of the element against
+// the certificates with goxmldsig, and returns the canonical form of the
+// signed element. Only the returned bytes are covered by the signature, so
+// everything read from the element has to be read from them.
+func verifySignature(e *etree.Element, certificates []*x509.Certificate, now time.Time) ([]byte, error) {
+	signatures := childElements(e, nsDSig, "Signature")
+	if len(signatures) == 0 {
+		return nil, errSignatureMissing
+	}
+	if len(signatures) > 1 {
+		return nil, errors.New("saml: element has more than one signature")
+	}
+	if e.SelectAttrValue("ID", "") == "" {
+		return nil, errors.New("saml: signed element has no ID")
+	}
+
+	// the element is verified on its own, with the namespaces it inherits
+	ctx, err := etreeutils.NSBuildParentContext(e)
+	if err != nil {
+		return nil, err
+	}
+	detached, err := etreeutils.NSDetatch(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the certificates of the identity provider are trusted, a
+	// certificate in the KeyInfo of the signature must be one of them
+	err = errors.New("saml: the identity provider has no certificate")
+	for _, cert := range certificates {
+		validation := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{Roots: []*x509.Certificate{cert}})
+		validation.Clock = dsig.NewFakeClockAt(now)
+		var signed *etree.Element
+		signed, err = validation.Validate(detached)
+		if err == dsig.ErrMissingSignature {
+			return nil, errors.New("saml: signature doesn't reference the signed element")
+		}
+		if err == nil {
+			return dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("").Canonicalize(signed)
+		}
+	}
+	return nil, fmt.Errorf("saml: invalid signature: %w", err)
+}
+
+func escapeText(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '>':
+			buf.WriteString("&gt;")
+		case '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
+func escapeAttr(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '"':
+			buf.WriteString("&quot;")
+		case '\t':
+			buf.WriteString("&#x9;")
+		case '\n':
+			buf.WriteString("&#xA;")
+		case '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+}
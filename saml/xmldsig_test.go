@@ -0,0 +1,146 @@
+package saml
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseXMLRejectsDTD(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	_, err := parseXML([]byte(`<!DOCTYPE r [<!ENTITY e "x">]><r>&e;</r>`))
+	a.Error(err)
+}
+
+func Test_VerifySignature(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	certificates := []*x509.Certificate{otherCertificate(), testCertificate()}
+
+	signed := sign(`<r:root xmlns:r="urn:r" ID="id-1"><r:issuer>me</r:issuer><r:data>value</r:data></r:root>`, "</r:issuer>")
+	root, err := parseXML([]byte(signed))
+	a.NoError(err)
+
+	canonical, err := verifySignature(root, certificates, testNow)
+	a.NoError(err)
+	a.Equal(`<r:root xmlns:r="urn:r" ID="id-1"><r:issuer>me</r:issuer><r:data>value</r:data></r:root>`, string(canonical))
+
+	root, _ = parseXML([]byte(strings.Replace(signed, "value", "other", 1)))
+	_, err = verifySignature(root, certificates, testNow)
+	a.EqualError(err, "saml: invalid signature: Signature could not be verified")
+
+	root, _ = parseXML([]byte(strings.Replace(signed, `ID="id-1"`, `ID="id-2"`, 1)))
+	_, err = verifySignature(root, certificates, testNow)
+	a.EqualError(err, "saml: signature doesn't reference the signed element")
+
+	root, _ = parseXML([]byte(signed))
+	_, err = verifySignature(root, []*x509.Certificate{otherCertificate()}, testNow)
+	a.Error(err)
+
+	root, _ = parseXML([]byte(signed))
+	_, err = verifySignature(root, certificates, testCertificate().NotAfter.Add(time.Second))
+	a.EqualError(err, "saml: invalid signature: Cert is not valid at this time")
+
+	root, _ = parseXML([]byte(`<r:root xmlns:r="urn:r" ID="id-1"></r:root>`))
+	_, err = verifySignature(root, certificates, testNow)
+	a.Equal(errSignatureMissing, err)
+}
+
+func Test_VerifySignatureInherited(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	// the namespaces of the signed element are declared by its parent
+	signed := sign(`<r:child ID="id-1" xmlns:r="urn:r" xmlns:s="urn:s"><s:data>value</s:data></r:child>`, "</s:data>")
+	signed = strings.Replace(signed, ` xmlns:r="urn:r" xmlns:s="urn:s"`, "", 1)
+	root, err := parseXML([]byte(`<r:root xmlns:r="urn:r" xmlns:s="urn:s" xmlns:t="urn:t">` + signed + `</r:root>`))
+	a.NoError(err)
+
+	canonical, err := verifySignature(root.ChildElements()[0], []*x509.Certificate{testCertificate()}, testNow)
+	a.NoError(err)
+	a.Equal(`<r:child xmlns:r="urn:r" ID="id-1"><s:data xmlns:s="urn:s">value</s:data></r:child>`, string(canonical))
+}
+
+var (
+	testKeysOnce sync.Once
+	testKey      *rsa.PrivateKey
+	testCert     *x509.Certificate
+	otherCert    *x509.Certificate
+)
+
+func generateTestKeys() {
+	testKeysOnce.Do(func() {
+		testKey, testCert = generateCertificate()
+		_, otherCert = generateCertificate()
+	})
+}
+
+func generateCertificate() (*rsa.PrivateKey, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    testNow.AddDate(-1, 0, 0),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+	return key, cert
+}
+
+func testCertificate() *x509.Certificate {
+	generateTestKeys()
+	return testCert
+}
+
+func otherCertificate() *x509.Certificate {
+	generateTestKeys()
+	return otherCert
+}
+
+// sign adds an enveloped signature to the root element of the document, the
+// way identity providers do, right after the given marker.
+func sign(document, after string) string {
+	generateTestKeys()
+	root, err := parseXML([]byte(document))
+	if err != nil {
+		panic(err)
+	}
+	ctx, err := dsig.NewSigningContext(testKey, [][]byte{testCert.Raw})
+	if err != nil {
+		panic(err)
+	}
+	ctx.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+	signature, err := ctx.ConstructSignature(root, true)
+	if err != nil {
+		panic(err)
+	}
+	doc := etree.NewDocument()
+	doc.SetRoot(signature)
+	signatureElement, err := doc.WriteToString()
+	if err != nil {
+		panic(err)
+	}
+	return strings.Replace(document, after, after+signatureElement, 1)
+}
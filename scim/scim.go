@@ -0,0 +1,490 @@
+// Package scim is a client of the SCIM 2.0 protocol (RFC 7643 and RFC 7644),
+// exposed by identity providers like Okta, Microsoft Entra ID (Azure AD) or
+// OneLogin, to pull and push the users and groups of the applications signing
+// users in with goth.
+//
+// Applications can check the users of a provider are still provisioned when
+// their sessions are refreshed, and sign out the ones the identity provider
+// removed or deactivated:
+//
+//	client := scim.NewClient("https://example.okta.com/scim/v2", token)
+//	deprovisioned, err := client.Deprovisioned(user)
+package scim
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// The schemas of the resources and messages of SCIM.
+const (
+	UserSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	GroupSchema        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	EnterpriseSchema   = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	ListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// MediaType is the content type of the SCIM requests and responses.
+const MediaType = "application/scim+json"
+
+// ErrNotFound is returned for resources which don't exist.
+var ErrNotFound = errors.New("scim: resource not found")
+
+// Error is an error response of a SCIM service.
+type Error struct {
+	Status   int    `json:"-"`
+	ScimType string `json:"scimType,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+func (e *Error) Error() string {
+	s := fmt.Sprintf("scim: the service responded with a %d", e.Status)
+	if e.ScimType != "" {
+		s += " " + e.ScimType
+	}
+	if e.Detail != "" {
+		s += ": " + e.Detail
+	}
+	return s
+}
+
+// Is makes errors.Is(err, ErrNotFound) match the 404 responses.
+func (e *Error) Is(target error) bool {
+	return target == ErrNotFound && e.Status == http.StatusNotFound
+}
+
+// Meta holds the metadata of a resource.
+type Meta struct {
+	ResourceType string `json:"resourceType,omitempty"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"`
+	Version      string `json:"version,omitempty"`
+}
+
+// Name is the name of a user.
+type Name struct {
+	Formatted       string `json:"formatted,omitempty"`
+	FamilyName      string `json:"familyName,omitempty"`
+	GivenName       string `json:"givenName,omitempty"`
+	MiddleName      string `json:"middleName,omitempty"`
+	HonorificPrefix string `json:"honorificPrefix,omitempty"`
+	HonorificSuffix string `json:"honorificSuffix,omitempty"`
+}
+
+// MultiValued is a value of a multi-valued attribute, e.g. an email.
+type MultiValued struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// Reference is a reference to another resource, e.g. a member of a group.
+type Reference struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+// User is a user resource. The attributes of the schema extensions, like the
+// enterprise extension, are kept in Extensions by their schema.
+type User struct {
+	Schemas     []string      `json:"schemas"`
+	ID          string        `json:"id,omitempty"`
+	ExternalID  string        `json:"externalId,omitempty"`
+	UserName    string        `json:"userName"`
+	Name        *Name         `json:"name,omitempty"`
+	DisplayName string        `json:"displayName,omitempty"`
+	NickName    string        `json:"nickName,omitempty"`
+	ProfileURL  string        `json:"profileUrl,omitempty"`
+	Title       string        `json:"title,omitempty"`
+	Locale      string        `json:"locale,omitempty"`
+	Timezone    string        `json:"timezone,omitempty"`
+	Active      *bool         `json:"active,omitempty"`
+	Emails      []MultiValued `json:"emails,omitempty"`
+	Photos      []MultiValued `json:"photos,omitempty"`
+	Groups      []Reference   `json:"groups,omitempty"`
+	Meta        *Meta         `json:"meta,omitempty"`
+
+	Extensions map[string]json.RawMessage `json:"-"`
+}
+
+// IsActive reports whether the user is active, which it is unless the
+// service says otherwise.
+func (u *User) IsActive() bool {
+	return u.Active == nil || *u.Active
+}
+
+// PrimaryEmail returns the primary email of the user, or the first one.
+func (u *User) PrimaryEmail() string {
+	for _, e := range u.Emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+type user User
+
+// MarshalJSON adds the Extensions to the attributes of the user.
+func (u User) MarshalJSON() ([]byte, error) {
+	if len(u.Schemas) == 0 {
+		u.Schemas = []string{UserSchema}
+		for schema := range u.Extensions {
+			u.Schemas = append(u.Schemas, schema)
+		}
+	}
+	b, err := json.Marshal(user(u))
+	if err != nil || len(u.Extensions) == 0 {
+		return b, err
+	}
+	var attributes map[string]json.RawMessage
+	err = json.Unmarshal(b, &attributes)
+	if err != nil {
+		return nil, err
+	}
+	for schema, v := range u.Extensions {
+		attributes[schema] = v
+	}
+	return json.Marshal(attributes)
+}
+
+// UnmarshalJSON reads the attributes of the schema extensions in Extensions.
+func (u *User) UnmarshalJSON(data []byte) error {
+	err := json.Unmarshal(data, (*user)(u))
+	if err != nil {
+		return err
+	}
+	var attributes map[string]json.RawMessage
+	err = json.Unmarshal(data, &attributes)
+	if err != nil {
+		return err
+	}
+	u.Extensions = nil
+	for name, v := range attributes {
+		if strings.HasPrefix(name, "urn:") {
+			if u.Extensions == nil {
+				u.Extensions = map[string]json.RawMessage{}
+			}
+			u.Extensions[name] = v
+		}
+	}
+	return nil
+}
+
+// Group is a group resource.
+type Group struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Members     []Reference `json:"members,omitempty"`
+	Meta        *Meta       `json:"meta,omitempty"`
+}
+
+// Operation is an operation of a PATCH request.
+type Operation struct {
+	// Op is "add", "remove" or "replace".
+	Op    string      `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Filter returns the filter comparing the attribute to the value with the
+// operator, e.g. Filter("userName", "eq", "bjensen@example.com").
+func Filter(attribute, operator, value string) string {
+	b, _ := json.Marshal(value)
+	return attribute + " " + operator + " " + string(b)
+}
+
+// Client calls the SCIM service of an identity provider.
+type Client struct {
+	// BaseURL is the base URL of the service, e.g.
+	// "https://example.okta.com/scim/v2".
+	BaseURL string
+	// Token is the bearer token authenticating the client.
+	Token      string
+	HTTPClient *http.Client
+	// PageSize is the number of resources requested at once by the lists.
+	PageSize int
+}
+
+// NewClient creates a client of the service at baseURL, authenticated with
+// the bearer token.
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL:  strings.TrimSuffix(baseURL, "/"),
+		Token:    token,
+		PageSize: 100,
+	}
+}
+
+// Users returns the users matching the filter, all users when it is empty.
+func (c *Client) Users(filter string) ([]User, error) {
+	var users []User
+	err := c.list("/Users", filter, func(resources json.RawMessage) (int, error) {
+		var page []User
+		err := json.Unmarshal(resources, &page)
+		users = append(users, page...)
+		return len(page), err
+	})
+	return users, err
+}
+
+// User returns the user with the ID.
+func (c *Client) User(id string) (*User, error) {
+	u := &User{}
+	err := c.do(http.MethodGet, "/Users/"+url.PathEscape(id), nil, u)
+	return u, err
+}
+
+// CreateUser creates the user, and returns it as created by the service.
+func (c *Client) CreateUser(u *User) (*User, error) {
+	created := &User{}
+	err := c.do(http.MethodPost, "/Users", u, created)
+	return created, err
+}
+
+// ReplaceUser replaces the attributes of the user with the ID of u.
+func (c *Client) ReplaceUser(u *User) (*User, error) {
+	replaced := &User{}
+	err := c.do(http.MethodPut, "/Users/"+url.PathEscape(u.ID), u, replaced)
+	return replaced, err
+}
+
+// PatchUser applies the operations to the user.
+func (c *Client) PatchUser(id string, operations ...Operation) error {
+	return c.patch("/Users/"+url.PathEscape(id), operations)
+}
+
+// DeactivateUser sets the user as inactive, which is how most identity
+// providers deprovision users.
+func (c *Client) DeactivateUser(id string) error {
+	return c.PatchUser(id, Operation{Op: "replace", Path: "active", Value: false})
+}
+
+// DeleteUser deletes the user.
+func (c *Client) DeleteUser(id string) error {
+	return c.do(http.MethodDelete, "/Users/"+url.PathEscape(id), nil, nil)
+}
+
+// Groups returns the groups matching the filter, all groups when it is empty.
+func (c *Client) Groups(filter string) ([]Group, error) {
+	var groups []Group
+	err := c.list("/Groups", filter, func(resources json.RawMessage) (int, error) {
+		var page []Group
+		err := json.Unmarshal(resources, &page)
+		groups = append(groups, page...)
+		return len(page), err
+	})
+	return groups, err
+}
+
+// Group returns the group with the ID.
+func (c *Client) Group(id string) (*Group, error) {
+	g := &Group{}
+	err := c.do(http.MethodGet, "/Groups/"+url.PathEscape(id), nil, g)
+	return g, err
+}
+
+// CreateGroup creates the group, and returns it as created by the service.
+func (c *Client) CreateGroup(g *Group) (*Group, error) {
+	if len(g.Schemas) == 0 {
+		g.Schemas = []string{GroupSchema}
+	}
+	created := &Group{}
+	err := c.do(http.MethodPost, "/Groups", g, created)
+	return created, err
+}
+
+// AddMembers adds the users with the IDs to the group.
+func (c *Client) AddMembers(groupID string, userIDs ...string) error {
+	members := make([]Reference, 0, len(userIDs))
+	for _, id := range userIDs {
+		members = append(members, Reference{Value: id})
+	}
+	return c.patch("/Groups/"+url.PathEscape(groupID), []Operation{{Op: "add", Path: "members", Value: members}})
+}
+
+// RemoveMembers removes the users with the IDs from the group.
+func (c *Client) RemoveMembers(groupID string, userIDs ...string) error {
+	operations := make([]Operation, 0, len(userIDs))
+	for _, id := range userIDs {
+		operations = append(operations, Operation{Op: "remove", Path: "members[" + Filter("value", "eq", id) + "]"})
+	}
+	return c.patch("/Groups/"+url.PathEscape(groupID), operations)
+}
+
+// DeleteGroup deletes the group.
+func (c *Client) DeleteGroup(id string) error {
+	return c.do(http.MethodDelete, "/Groups/"+url.PathEscape(id), nil, nil)
+}
+
+// FindUser returns the SCIM user of the goth user, whose externalId is its
+// UserID, or whose userName is its email. It returns ErrNotFound when there
+// is none.
+func (c *Client) FindUser(gothUser goth.User) (*User, error) {
+	filters := []string{}
+	if gothUser.UserID != "" {
+		filters = append(filters, Filter("externalId", "eq", gothUser.UserID))
+	}
+	if gothUser.Email != "" {
+		filters = append(filters, Filter("userName", "eq", gothUser.Email))
+	}
+	for _, filter := range filters {
+		users, err := c.Users(filter)
+		if err != nil {
+			return nil, err
+		}
+		if len(users) > 0 {
+			return &users[0], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Deprovisioned reports whether the identity provider removed or deactivated
+// the goth user.
+func (c *Client) Deprovisioned(gothUser goth.User) (bool, error) {
+	u, err := c.FindUser(gothUser)
+	if errors.Is(err, ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !u.IsActive(), nil
+}
+
+// FromGothUser returns a SCIM user with the attributes of the goth user, to be
+// pushed with CreateUser.
+func FromGothUser(gothUser goth.User) *User {
+	active := true
+	u := &User{
+		Schemas:     []string{UserSchema},
+		ExternalID:  gothUser.UserID,
+		UserName:    gothUser.Email,
+		DisplayName: gothUser.Name,
+		NickName:    gothUser.NickName,
+		Active:      &active,
+	}
+	if u.UserName == "" {
+		u.UserName = gothUser.NickName
+	}
+	if u.UserName == "" {
+		u.UserName = gothUser.UserID
+	}
+	if gothUser.FirstName != "" || gothUser.LastName != "" {
+		u.Name = &Name{GivenName: gothUser.FirstName, FamilyName: gothUser.LastName, Formatted: gothUser.Name}
+	}
+	if gothUser.Email != "" {
+		u.Emails = []MultiValued{{Value: gothUser.Email, Type: "work", Primary: true}}
+	}
+	if gothUser.AvatarURL != "" {
+		u.Photos = []MultiValued{{Value: gothUser.AvatarURL, Type: "photo"}}
+	}
+	return u
+}
+
+// list requests the pages of the resources matching the filter.
+func (c *Client) list(path, filter string, page func(resources json.RawMessage) (int, error)) error {
+	pageSize := c.PageSize
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+	startIndex := 1
+	for {
+		query := url.Values{
+			"startIndex": {strconv.Itoa(startIndex)},
+			"count":      {strconv.Itoa(pageSize)},
+		}
+		if filter != "" {
+			query.Set("filter", filter)
+		}
+		var response struct {
+			TotalResults int             `json:"totalResults"`
+			Resources    json.RawMessage `json:"Resources"`
+		}
+		err := c.do(http.MethodGet, path+"?"+query.Encode(), nil, &response)
+		if err != nil {
+			return err
+		}
+		if len(response.Resources) == 0 {
+			return nil
+		}
+		n, err := page(response.Resources)
+		if err != nil {
+			return err
+		}
+		startIndex += n
+		if n == 0 || startIndex > response.TotalResults {
+			return nil
+		}
+	}
+}
+
+func (c *Client) patch(path string, operations []Operation) error {
+	body := struct {
+		Schemas    []string    `json:"schemas"`
+		Operations []Operation `json:"Operations"`
+	}{[]string{PatchOpSchema}, operations}
+	return c.do(http.MethodPatch, path, body, nil)
+}
+
+// do sends the request with the JSON of in, and decodes the response in out.
+func (c *Client) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.BaseURL, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", MediaType+", application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", MediaType)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := goth.HTTPClientWithFallBack(c.HTTPClient).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	r := io.LimitReader(resp.Body, 10<<20)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &Error{}
+		_ = json.NewDecoder(r).Decode(e)
+		e.Status = resp.StatusCode
+		return e
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(r).Decode(out)
+}
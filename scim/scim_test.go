@@ -0,0 +1,212 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+// service is a minimal SCIM service, keeping the users in memory and
+// supporting the eq filters of userName and externalId.
+type service struct {
+	mu      sync.Mutex
+	users   map[string]*User
+	patches []json.RawMessage
+	nextID  int
+}
+
+func newService(t *testing.T) (*service, *Client) {
+	s := &service{users: map[string]*User{}}
+	server := httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(server.Close)
+	client := NewClient(server.URL+"/scim/v2/", "secret")
+	client.PageSize = 2
+	return s, client
+}
+
+func (s *service) serveHTTP(res http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res.Header().Set("Content-Type", MediaType)
+	if req.Header.Get("Authorization") != "Bearer secret" {
+		res.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintf(res, `{"schemas":[%q],"status":"401","detail":"invalid token"}`, ErrorSchema)
+		return
+	}
+	path := strings.TrimPrefix(req.URL.Path, "/scim/v2")
+	switch {
+	case path == "/Users" && req.Method == http.MethodGet:
+		var matches []*User
+		for i := 1; i <= s.nextID; i++ {
+			u, ok := s.users[strconv.Itoa(i)]
+			if !ok {
+				continue
+			}
+			filter := req.URL.Query().Get("filter")
+			if filter == "" || filter == Filter("userName", "eq", u.UserName) || filter == Filter("externalId", "eq", u.ExternalID) {
+				matches = append(matches, u)
+			}
+		}
+		start, _ := strconv.Atoi(req.URL.Query().Get("startIndex"))
+		count, _ := strconv.Atoi(req.URL.Query().Get("count"))
+		page := matches[start-1:]
+		if len(page) > count {
+			page = page[:count]
+		}
+		_ = json.NewEncoder(res).Encode(map[string]interface{}{
+			"schemas":      []string{ListResponseSchema},
+			"totalResults": len(matches),
+			"startIndex":   start,
+			"itemsPerPage": len(page),
+			"Resources":    page,
+		})
+	case path == "/Users" && req.Method == http.MethodPost:
+		u := &User{}
+		_ = json.NewDecoder(req.Body).Decode(u)
+		s.nextID++
+		u.ID = strconv.Itoa(s.nextID)
+		u.Meta = &Meta{ResourceType: "User"}
+		s.users[u.ID] = u
+		res.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(res).Encode(u)
+	case strings.HasPrefix(path, "/Users/"):
+		u, ok := s.users[strings.TrimPrefix(path, "/Users/")]
+		if !ok {
+			res.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(res, `{"schemas":[%q],"status":"404","detail":"Resource not found"}`, ErrorSchema)
+			return
+		}
+		switch req.Method {
+		case http.MethodGet:
+			_ = json.NewEncoder(res).Encode(u)
+		case http.MethodPatch:
+			var patch struct {
+				Operations []Operation
+			}
+			body := json.RawMessage{}
+			_ = json.NewDecoder(req.Body).Decode(&body)
+			s.patches = append(s.patches, body)
+			_ = json.Unmarshal(body, &patch)
+			for _, op := range patch.Operations {
+				if op.Path == "active" {
+					active := op.Value.(bool)
+					u.Active = &active
+				}
+			}
+			res.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			delete(s.users, u.ID)
+			res.WriteHeader(http.StatusNoContent)
+		}
+	case strings.HasPrefix(path, "/Groups/") && req.Method == http.MethodPatch:
+		body := json.RawMessage{}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		s.patches = append(s.patches, body)
+		res.WriteHeader(http.StatusNoContent)
+	default:
+		res.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func Test_Users(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	_, client := newService(t)
+	for _, name := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		created, err := client.CreateUser(FromGothUser(goth.User{UserID: "id-" + name, Email: name, FirstName: "A", LastName: "B"}))
+		a.NoError(err)
+		a.NotEmpty(created.ID)
+		a.Equal(name, created.PrimaryEmail())
+		a.True(created.IsActive())
+	}
+
+	users, err := client.Users("")
+	a.NoError(err)
+	a.Len(users, 3)
+	a.Equal("c@example.com", users[2].UserName)
+
+	users, err = client.Users(Filter("userName", "eq", "b@example.com"))
+	a.NoError(err)
+	a.Len(users, 1)
+	a.Equal("id-b@example.com", users[0].ExternalID)
+	a.Equal("B", users[0].Name.FamilyName)
+
+	u, err := client.User(users[0].ID)
+	a.NoError(err)
+	a.Equal("b@example.com", u.UserName)
+
+	a.NoError(client.DeleteUser(u.ID))
+	_, err = client.User(u.ID)
+	a.True(errors.Is(err, ErrNotFound))
+	e, ok := err.(*Error)
+	a.True(ok)
+	a.Equal("Resource not found", e.Detail)
+
+	client.Token = "other"
+	_, err = client.Users("")
+	a.Error(err)
+	a.False(errors.Is(err, ErrNotFound))
+}
+
+func Test_Deprovisioned(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	s, client := newService(t)
+	created, err := client.CreateUser(&User{UserName: "homer@example.com", ExternalID: "00u1"})
+	a.NoError(err)
+
+	deprovisioned, err := client.Deprovisioned(goth.User{UserID: "00u1"})
+	a.NoError(err)
+	a.False(deprovisioned)
+	deprovisioned, err = client.Deprovisioned(goth.User{UserID: "other", Email: "homer@example.com"})
+	a.NoError(err)
+	a.False(deprovisioned)
+
+	a.NoError(client.DeactivateUser(created.ID))
+	a.JSONEq(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"active","value":false}]}`, string(s.patches[0]))
+	deprovisioned, err = client.Deprovisioned(goth.User{UserID: "00u1"})
+	a.NoError(err)
+	a.True(deprovisioned)
+
+	deprovisioned, err = client.Deprovisioned(goth.User{UserID: "unknown", Email: "marge@example.com"})
+	a.NoError(err)
+	a.True(deprovisioned)
+}
+
+func Test_GroupMembers(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	s, client := newService(t)
+	a.NoError(client.AddMembers("g1", "u1", "u2"))
+	a.NoError(client.RemoveMembers("g1", "u3"))
+	a.JSONEq(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"add","path":"members","value":[{"value":"u1"},{"value":"u2"}]}]}`, string(s.patches[0]))
+	a.JSONEq(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"remove","path":"members[value eq \"u3\"]"}]}`, string(s.patches[1]))
+}
+
+func Test_UserExtensions(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	data := `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User","urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"],"id":"1","userName":"bjensen","active":false,"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User":{"employeeNumber":"701984"}}`
+	u := &User{}
+	a.NoError(json.Unmarshal([]byte(data), u))
+	a.False(u.IsActive())
+	a.JSONEq(`{"employeeNumber":"701984"}`, string(u.Extensions[EnterpriseSchema]))
+
+	b, err := json.Marshal(u)
+	a.NoError(err)
+	a.JSONEq(data, string(b))
+}
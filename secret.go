@@ -0,0 +1,218 @@
+package goth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RedactJSON redacts the tokens and the secrets of a JSON document, e.g. a
+// marshaled session, to print it. Documents without secrets are returned as
+// they are.
+func RedactJSON(data string) string {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader([]byte(data)))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return redacted
+	}
+	if !hasSecrets(v) {
+		return data
+	}
+	b, err := json.Marshal(redactJSON(v))
+	if err != nil {
+		return redacted
+	}
+	return string(b)
+}
+
+// RedactBody redacts the tokens and the secrets of a JSON or form body, e.g.
+// of an error response, to print it.
+func RedactBody(contentType string, body []byte) string {
+	return redactBody(contentType, body)
+}
+
+// hasSecrets reports whether the JSON value has a sensitive field which
+// isn't empty.
+func hasSecrets(v interface{}) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if Sensitive(key) && value != nil && value != "" {
+				return true
+			}
+			if hasSecrets(value) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			if hasSecrets(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// String returns the user with its tokens redacted, including the tokens of
+// its RawData, so users can be logged. The user is still marshaled with its
+// tokens, to store it.
+func (u User) String() string {
+	return fmt.Sprintf("%+v", u.redacted())
+}
+
+// GoString returns the user with its tokens redacted.
+func (u User) GoString() string {
+	return fmt.Sprintf("%#v", u.redacted())
+}
+
+// redacted returns a copy of the user, of a type without methods to print it.
+func (u User) redacted() interface{} {
+	type user User
+	r := user(u)
+	r.AccessToken = redactValue(u.AccessToken)
+	r.AccessTokenSecret = redactValue(u.AccessTokenSecret)
+	r.RefreshToken = redactValue(u.RefreshToken)
+	r.IDToken = redactValue(u.IDToken)
+	if u.RawData != nil {
+		r.RawData = make(map[string]interface{}, len(u.RawData))
+		for key, value := range u.RawData {
+			if Sensitive(key) {
+				r.RawData[key] = redacted
+			} else {
+				r.RawData[key] = redactJSON(copyJSON(value))
+			}
+		}
+	}
+	return r
+}
+
+// RedactedString formats v, a provider or another struct or pointer to a
+// struct, like the %+v verb with its secrets redacted, e.g. its client secret,
+// for the String methods of the providers. The secrets of the structs it
+// contains are redacted too, and the pointers it contains are printed as
+// addresses.
+func RedactedString(v interface{}) string {
+	return formatRedacted(v, false)
+}
+
+// RedactedGoString is RedactedString for the %#v verb, for the GoString
+// methods of the providers.
+func RedactedGoString(v interface{}) string {
+	return formatRedacted(v, true)
+}
+
+func formatRedacted(v interface{}, goSyntax bool) string {
+	var b strings.Builder
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Ptr && value.Type().Elem().Kind() == reflect.Struct {
+		if value.IsNil() {
+			writeValue(&b, value, goSyntax)
+			return b.String()
+		}
+		b.WriteByte('&')
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return redacted
+	}
+	writeStruct(&b, value, goSyntax)
+	return b.String()
+}
+
+// writeStruct writes the fields of the struct, with its sensitive strings
+// redacted.
+func writeStruct(b *strings.Builder, v reflect.Value, goSyntax bool) {
+	t := v.Type()
+	if goSyntax {
+		b.WriteString(t.String())
+	}
+	b.WriteByte('{')
+	for i := 0; i < v.NumField(); i++ {
+		if i > 0 && goSyntax {
+			b.WriteString(", ")
+		} else if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(t.Field(i).Name)
+		b.WriteByte(':')
+		field := v.Field(i)
+		switch {
+		case field.Kind() == reflect.String && Sensitive(t.Field(i).Name):
+			if goSyntax {
+				fmt.Fprintf(b, "%q", redactValue(field.String()))
+			} else {
+				b.WriteString(redactValue(field.String()))
+			}
+		case field.Kind() == reflect.Struct && hasSensitiveField(field.Type()):
+			writeStruct(b, field, goSyntax)
+		default:
+			writeValue(b, field, goSyntax)
+		}
+	}
+	b.WriteByte('}')
+}
+
+// writeValue writes a field like fmt, but its pointers as addresses, as fmt
+// only does for the values it doesn't print at the top level.
+func writeValue(b *strings.Builder, v reflect.Value, goSyntax bool) {
+	switch {
+	case v.Kind() == reflect.Ptr && goSyntax && v.IsNil():
+		fmt.Fprintf(b, "(%s)(nil)", v.Type())
+	case v.Kind() == reflect.Ptr && goSyntax:
+		fmt.Fprintf(b, "(%s)(0x%x)", v.Type(), v.Pointer())
+	case v.Kind() == reflect.Ptr && v.IsNil():
+		b.WriteString("<nil>")
+	case v.Kind() == reflect.Ptr:
+		fmt.Fprintf(b, "0x%x", v.Pointer())
+	case goSyntax:
+		fmt.Fprintf(b, "%#v", v)
+	default:
+		fmt.Fprintf(b, "%+v", v)
+	}
+}
+
+// hasSensitiveField reports whether the struct has a sensitive string, or
+// a struct field which has one.
+func hasSensitiveField(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type.Kind() == reflect.String && Sensitive(field.Name) {
+			return true
+		}
+		if field.Type.Kind() == reflect.Struct && hasSensitiveField(field.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactValue returns REDACTED when the token isn't empty.
+func redactValue(token string) string {
+	if token == "" {
+		return ""
+	}
+	return redacted
+}
+
+// copyJSON copies the maps and slices of a JSON value, so it can be redacted.
+func copyJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			c[key] = copyJSON(value)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, value := range v {
+			c[i] = copyJSON(value)
+		}
+		return c
+	}
+	return v
+}
@@ -0,0 +1,86 @@
+package goth_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RedactJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	// sessions without tokens are unchanged
+	a.Equal(`{"AuthURL":"https://example.com","AccessToken":""}`, goth.RedactJSON(`{"AuthURL":"https://example.com","AccessToken":""}`))
+	a.Equal(`{"AccessToken":"REDACTED","AuthURL":"https://example.com","ExpiresIn":3600}`,
+		goth.RedactJSON(`{"AuthURL":"https://example.com","AccessToken":"at-secret","ExpiresIn":3600}`))
+	a.Equal(`{"Nested":[{"refresh_token":"REDACTED"}]}`, goth.RedactJSON(`{"Nested":[{"refresh_token":"rt-secret"}]}`))
+	a.Equal("REDACTED", goth.RedactJSON("not json"))
+
+	a.Equal("error=invalid_grant&refresh_token=REDACTED", goth.RedactBody("application/x-www-form-urlencoded", []byte("error=invalid_grant&refresh_token=rt-secret")))
+}
+
+func Test_User_String(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	user := goth.User{
+		Provider:     "github",
+		Email:        "homer@example.com",
+		AccessToken:  "at-secret",
+		RefreshToken: "rt-secret",
+		IDToken:      "eyJ.secret",
+		ExpiresAt:    time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC),
+		RawData: map[string]interface{}{
+			"login":        "homer",
+			"access_token": "at-secret",
+			"tokens":       map[string]interface{}{"refresh_token": "rt-secret"},
+		},
+	}
+	for _, format := range []string{"%s", "%v", "%+v", "%#v"} {
+		s := fmt.Sprintf(format, user)
+		a.Contains(s, "homer@example.com", format)
+		for _, secret := range []string{"at-secret", "rt-secret", "eyJ.secret"} {
+			a.NotContains(s, secret, format)
+		}
+	}
+	// the user is unchanged, and still marshaled with its tokens to store it
+	a.Equal("rt-secret", user.RawData["tokens"].(map[string]interface{})["refresh_token"])
+	b, _ := json.Marshal(user)
+	a.Contains(string(b), "at-secret")
+}
+
+type redactedConfig struct {
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+type redactedProvider struct {
+	ClientKey string
+	Secret    string
+	config    redactedConfig
+	client    *redactedConfig
+	nothing   *redactedConfig
+}
+
+func (p *redactedProvider) String() string   { return goth.RedactedString(p) }
+func (p *redactedProvider) GoString() string { return goth.RedactedGoString(p) }
+
+func Test_RedactedString(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	config := redactedConfig{ClientID: "client-id", ClientSecret: "cs-secret", Scopes: []string{"email"}}
+	p := &redactedProvider{ClientKey: "client-id", Secret: "cs-secret", config: config, client: &config}
+	a.Equal(fmt.Sprintf("&{ClientKey:client-id Secret:REDACTED config:{ClientID:client-id ClientSecret:REDACTED Scopes:[email]} client:%p nothing:<nil>}", &config), fmt.Sprintf("%+v", p))
+	a.Equal(fmt.Sprintf(`&goth_test.redactedProvider{ClientKey:"client-id", Secret:"REDACTED", config:goth_test.redactedConfig{ClientID:"client-id", ClientSecret:"REDACTED", Scopes:[]string{"email"}}, client:(*goth_test.redactedConfig)(%p), nothing:(*goth_test.redactedConfig)(nil)}`, &config), fmt.Sprintf("%#v", p))
+	for _, format := range []string{"%s", "%v"} {
+		a.NotContains(fmt.Sprintf(format, p), "cs-secret", format)
+	}
+	a.Equal("<nil>", goth.RedactedString((*redactedProvider)(nil)))
+}
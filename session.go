@@ -1,5 +1,7 @@
 package goth
 
+import "context"
+
 // Params is used to pass data to sessions for authorization. An existing
 // implementation, and the one most likely to be used, is `url.Values`.
 type Params interface {
@@ -19,3 +21,20 @@ type Session interface {
 	// that can be stored for later access to the provider.
 	Authorize(Provider, Params) (string, error)
 }
+
+// ChallengeSession can be implemented by sessions of providers whose
+// authentication happens in the browser rather than at an end-point the user
+// is sent to, e.g. WebAuthn. gothic serves the challenge the browser needs with
+// BeginChallengeHandler.
+type ChallengeSession interface {
+	Session
+	Challenge() (contentType string, challenge []byte, err error)
+}
+
+// ContextSession can be implemented by sessions authorizing with a context,
+// e.g. the one of the callback request, so the token exchange can be canceled
+// with it or traced. gothic calls AuthorizeContext instead of Authorize.
+type ContextSession interface {
+	Session
+	AuthorizeContext(ctx context.Context, provider Provider, params Params) (string, error)
+}
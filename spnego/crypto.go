@@ -0,0 +1,331 @@
+package spnego
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Encryption types, see https://www.rfc-editor.org/rfc/rfc3961#section-8
+const (
+	EtypeAES128CTSHMACSHA196 = 17
+	EtypeAES256CTSHMACSHA196 = 18
+	EtypeRC4HMAC             = 23
+)
+
+// Key usages, see https://www.rfc-editor.org/rfc/rfc4120#section-7.5.1
+const (
+	usageTicket        = 2
+	usageAuthenticator = 11
+	usageAPRep         = 12
+)
+
+var errIntegrity = errors.New("spnego: integrity check failed, the keytab may be out of date")
+
+// decrypt decrypts the cipher text encrypted with the key for the usage.
+func decrypt(etype int32, key []byte, usage uint32, ciphertext []byte) ([]byte, error) {
+	switch etype {
+	case EtypeAES128CTSHMACSHA196, EtypeAES256CTSHMACSHA196:
+		return decryptAES(key, usage, ciphertext)
+	case EtypeRC4HMAC:
+		if goth.FIPS() {
+			return nil, &goth.FIPSError{What: "Kerberos encryption type rc4-hmac"}
+		}
+		return decryptRC4(key, usage, ciphertext)
+	default:
+		return nil, fmt.Errorf("spnego: unsupported encryption type %d", etype)
+	}
+}
+
+// encrypt encrypts the plain text with the key for the usage.
+func encrypt(etype int32, key []byte, usage uint32, plaintext []byte) ([]byte, error) {
+	switch etype {
+	case EtypeAES128CTSHMACSHA196, EtypeAES256CTSHMACSHA196:
+		return encryptAES(key, usage, plaintext)
+	case EtypeRC4HMAC:
+		if goth.FIPS() {
+			return nil, &goth.FIPSError{What: "Kerberos encryption type rc4-hmac"}
+		}
+		return encryptRC4(key, usage, plaintext)
+	default:
+		return nil, fmt.Errorf("spnego: unsupported encryption type %d", etype)
+	}
+}
+
+// aes128-cts-hmac-sha1-96 and aes256-cts-hmac-sha1-96, see
+// https://www.rfc-editor.org/rfc/rfc3962
+
+func usageKeys(key []byte, usage uint32) (ke, ki []byte, err error) {
+	constant := make([]byte, 5)
+	binary.BigEndian.PutUint32(constant, usage)
+	constant[4] = 0xaa
+	ke, err = deriveKey(key, constant)
+	if err != nil {
+		return nil, nil, err
+	}
+	constant[4] = 0x55
+	ki, err = deriveKey(key, constant)
+	return ke, ki, err
+}
+
+func decryptAES(key []byte, usage uint32, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < aes.BlockSize+12 {
+		return nil, errors.New("spnego: cipher text is too short")
+	}
+	ke, ki, err := usageKeys(key, usage)
+	if err != nil {
+		return nil, err
+	}
+	data, mac := ciphertext[:len(ciphertext)-12], ciphertext[len(ciphertext)-12:]
+	plaintext, err := decryptCTS(ke, data)
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(sha1.New, ki)
+	h.Write(plaintext)
+	if !hmac.Equal(h.Sum(nil)[:12], mac) {
+		return nil, errIntegrity
+	}
+	return plaintext[aes.BlockSize:], nil
+}
+
+func encryptAES(key []byte, usage uint32, plaintext []byte) ([]byte, error) {
+	ke, ki, err := usageKeys(key, usage)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, aes.BlockSize+len(plaintext))
+	_, err = rand.Read(data[:aes.BlockSize])
+	if err != nil {
+		return nil, err
+	}
+	copy(data[aes.BlockSize:], plaintext)
+	ciphertext, err := encryptCTS(ke, data)
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(sha1.New, ki)
+	h.Write(data)
+	return append(ciphertext, h.Sum(nil)[:12]...), nil
+}
+
+// deriveKey is DK(key, constant), see https://www.rfc-editor.org/rfc/rfc3961#section-5.1
+func deriveKey(key, constant []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	in := nfold(constant, aes.BlockSize)
+	derived := make([]byte, 0, len(key)+aes.BlockSize)
+	for len(derived) < len(key) {
+		out := make([]byte, aes.BlockSize)
+		block.Encrypt(out, in)
+		derived = append(derived, out...)
+		in = out
+	}
+	return derived[:len(key)], nil
+}
+
+// nfold stretches or folds the input to n bytes, see
+// https://www.rfc-editor.org/rfc/rfc3961#section-5.1
+func nfold(in []byte, n int) []byte {
+	k := len(in)
+	l := lcm(n, k)
+	buf := make([]byte, 0, l)
+	for i := 0; i < l/k; i++ {
+		buf = append(buf, rotateRight(in, 13*i)...)
+	}
+
+	// ones' complement addition of the n-byte blocks
+	sum := make([]int, n)
+	for i := 0; i < l; i += n {
+		for j := 0; j < n; j++ {
+			sum[j] += int(buf[i+j])
+		}
+	}
+	for {
+		carry := 0
+		for j := n - 1; j >= 0; j-- {
+			v := sum[j] + carry
+			sum[j] = v & 0xff
+			carry = v >> 8
+		}
+		if carry == 0 {
+			break
+		}
+		sum[n-1] += carry
+	}
+
+	out := make([]byte, n)
+	for j := range out {
+		out[j] = byte(sum[j])
+	}
+	return out
+}
+
+func rotateRight(in []byte, s int) []byte {
+	bits := len(in) * 8
+	s %= bits
+	out := make([]byte, len(in))
+	for i := 0; i < bits; i++ {
+		src := (i - s + bits) % bits
+		if in[src/8]&(0x80>>uint(src%8)) != 0 {
+			out[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return out
+}
+
+func lcm(a, b int) int {
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return a / x * b
+}
+
+// encryptCTS is AES-CBC with ciphertext stealing and a zero IV, where the
+// last two blocks are swapped.
+func encryptCTS(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(plaintext) < aes.BlockSize {
+		return nil, errors.New("spnego: plain text is too short")
+	}
+	if len(plaintext) == aes.BlockSize {
+		out := make([]byte, aes.BlockSize)
+		block.Encrypt(out, plaintext)
+		return out, nil
+	}
+
+	n := (len(plaintext) + aes.BlockSize - 1) / aes.BlockSize
+	lastLen := len(plaintext) - (n-1)*aes.BlockSize
+	padded := make([]byte, n*aes.BlockSize)
+	copy(padded, plaintext)
+	cbc := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(cbc, padded)
+
+	out := make([]byte, 0, len(plaintext))
+	out = append(out, cbc[:(n-2)*aes.BlockSize]...)
+	out = append(out, cbc[(n-1)*aes.BlockSize:]...)
+	out = append(out, cbc[(n-2)*aes.BlockSize:(n-2)*aes.BlockSize+lastLen]...)
+	return out, nil
+}
+
+func decryptCTS(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aes.BlockSize {
+		return nil, errors.New("spnego: cipher text is too short")
+	}
+	if len(ciphertext) == aes.BlockSize {
+		out := make([]byte, aes.BlockSize)
+		block.Decrypt(out, ciphertext)
+		return out, nil
+	}
+
+	n := (len(ciphertext) + aes.BlockSize - 1) / aes.BlockSize
+	lastLen := len(ciphertext) - (n-1)*aes.BlockSize
+	head := ciphertext[:(n-2)*aes.BlockSize]
+	cn := ciphertext[(n-2)*aes.BlockSize : (n-1)*aes.BlockSize]
+	partial := ciphertext[(n-1)*aes.BlockSize:]
+
+	dn := make([]byte, aes.BlockSize)
+	block.Decrypt(dn, cn)
+	// the stolen tail of the previous block is the tail of the decrypted last block
+	cn1 := make([]byte, aes.BlockSize)
+	copy(cn1, partial)
+	copy(cn1[lastLen:], dn[lastLen:])
+	pn := make([]byte, lastLen)
+	for i := range pn {
+		pn[i] = dn[i] ^ partial[i]
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if len(head) > 0 {
+		iv = head[len(head)-aes.BlockSize:]
+	}
+	pn1 := make([]byte, aes.BlockSize)
+	block.Decrypt(pn1, cn1)
+	for i := range pn1 {
+		pn1[i] ^= iv[i]
+	}
+
+	out := make([]byte, len(head), len(ciphertext))
+	if len(head) > 0 {
+		cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out, head)
+	}
+	out = append(out, pn1...)
+	return append(out, pn...), nil
+}
+
+// rc4-hmac, still used by older Active Directory domains, see
+// https://www.rfc-editor.org/rfc/rfc4757
+
+func rc4UsageKey(key []byte, usage uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, usage)
+	h := hmac.New(md5.New, key)
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+func decryptRC4(key []byte, usage uint32, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < md5.Size+8 {
+		return nil, errors.New("spnego: cipher text is too short")
+	}
+	k1 := rc4UsageKey(key, usage)
+	checksum := ciphertext[:md5.Size]
+	h := hmac.New(md5.New, k1)
+	h.Write(checksum)
+	c, err := rc4.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(ciphertext)-md5.Size)
+	c.XORKeyStream(plaintext, ciphertext[md5.Size:])
+
+	h = hmac.New(md5.New, k1)
+	h.Write(plaintext)
+	if !hmac.Equal(h.Sum(nil), checksum) {
+		return nil, errIntegrity
+	}
+	return plaintext[8:], nil
+}
+
+func encryptRC4(key []byte, usage uint32, plaintext []byte) ([]byte, error) {
+	k1 := rc4UsageKey(key, usage)
+	data := make([]byte, 8+len(plaintext))
+	_, err := rand.Read(data[:8])
+	if err != nil {
+		return nil, err
+	}
+	copy(data[8:], plaintext)
+
+	h := hmac.New(md5.New, k1)
+	h.Write(data)
+	checksum := h.Sum(nil)
+	h = hmac.New(md5.New, k1)
+	h.Write(checksum)
+	c, err := rc4.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, md5.Size+len(data))
+	copy(out, checksum)
+	c.XORKeyStream(out[md5.Size:], data)
+	return out, nil
+}
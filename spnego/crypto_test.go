@@ -0,0 +1,118 @@
+package spnego
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func Test_NFold(t *testing.T) {
+	t.Parallel()
+
+	// https://www.rfc-editor.org/rfc/rfc3961#appendix-A.1
+	tests := []struct {
+		in   string
+		bits int
+		out  string
+	}{
+		{"012345", 64, "be072631276b1955"},
+		{"password", 56, "78a07b6caf85fa"},
+		{"Rough Consensus, and Running Code", 64, "bb6ed30870b7f0e0"},
+		{"password", 168, "59e4a8ca7c0385c3c37b3f6d2000247cb6e6bd5b3e"},
+		{"MASSACHVSETTS INSTITVTE OF TECHNOLOGY", 192, "db3b0d8f0b061e603282b308a50841229ad798fab9540c1b"},
+		{"Q", 168, "518a54a215a8452a518a54a215a8452a518a54a215"},
+		{"ba", 168, "fb25d531ae8974499f52fd92ea9857c4ba24cf297e"},
+		{"kerberos", 64, "6b65726265726f73"},
+		{"kerberos", 128, "6b65726265726f737b9b5b2b93132b93"},
+		{"kerberos", 168, "8372c236344e5f1550cd0747e15d62ca7a5a3bcea4"},
+		{"kerberos", 256, "6b65726265726f737b9b5b2b93132b935c9bdcdad95c9899c4cae4dee6d6cae4"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.out, hex.EncodeToString(nfold([]byte(tt.in), tt.bits/8)), tt.in)
+	}
+}
+
+func Test_DeriveKey(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	// the string-to-key vectors of https://www.rfc-editor.org/rfc/rfc3962#appendix-B,
+	// with the PBKDF2 output as the key
+	key, err := deriveKey(unhex("cdedb5281bb2f801565a1122b2563515"), []byte("kerberos"))
+	a.NoError(err)
+	a.Equal("42263c6e89f4fc28b8df68ee09799f15", hex.EncodeToString(key))
+
+	key, err = deriveKey(unhex("cdedb5281bb2f801565a1122b25635150ad1f7a04bb9f3a333ecc0e2e1f70837"), []byte("kerberos"))
+	a.NoError(err)
+	a.Equal("fe697b52bc0d3ce14432ba036a92e65bbb52280990a2fa27883998d72af30161", hex.EncodeToString(key))
+}
+
+func Test_CTS(t *testing.T) {
+	t.Parallel()
+
+	// https://www.rfc-editor.org/rfc/rfc3962#appendix-B
+	key := unhex("636869636b656e207465726979616b69")
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"4920776f756c64206c696b652074686520", "c6353568f2bf8cb4d8a580362da7ff7f97"},
+		{"4920776f756c64206c696b65207468652047656e6572616c20476175277320", "fc00783e0efdb2c1d445d4c8eff7ed2297687268d6ecccc0c07b25e25ecfe5"},
+		{"4920776f756c64206c696b65207468652047656e6572616c2047617527732043", "39312523a78662d5be7fcbcc98ebf5a897687268d6ecccc0c07b25e25ecfe584"},
+		{"4920776f756c64206c696b65207468652047656e6572616c20476175277320436869636b656e2c20706c656173652c", "97687268d6ecccc0c07b25e25ecfe584b3fffd940c16a18c1b5549d2f838029e39312523a78662d5be7fcbcc98ebf5"},
+	}
+	for _, tt := range tests {
+		a := assert.New(t)
+		out, err := encryptCTS(key, unhex(tt.in))
+		a.NoError(err)
+		a.Equal(tt.out, hex.EncodeToString(out))
+
+		in, err := decryptCTS(key, out)
+		a.NoError(err)
+		a.Equal(tt.in, hex.EncodeToString(in))
+	}
+}
+
+func Test_EncryptDecrypt(t *testing.T) {
+	t.Parallel()
+
+	keys := map[int32][]byte{
+		EtypeAES128CTSHMACSHA196: unhex("42263c6e89f4fc28b8df68ee09799f15"),
+		EtypeAES256CTSHMACSHA196: unhex("fe697b52bc0d3ce14432ba036a92e65bbb52280990a2fa27883998d72af30161"),
+		EtypeRC4HMAC:             unhex("8846f7eaee8fb117ad06bdd830b7586c"),
+	}
+	if goth.FIPS() {
+		delete(keys, EtypeRC4HMAC)
+	}
+	for etype, key := range keys {
+		a := assert.New(t)
+		for _, plaintext := range []string{"", "a", "kerberos ticket of exactly 32 b", "a longer message spanning several AES blocks"} {
+			ciphertext, err := encrypt(etype, key, usageTicket, []byte(plaintext))
+			a.NoError(err)
+
+			decrypted, err := decrypt(etype, key, usageTicket, ciphertext)
+			a.NoError(err)
+			a.Equal(plaintext, string(decrypted))
+
+			_, err = decrypt(etype, key, usageAuthenticator, ciphertext)
+			a.Equal(errIntegrity, err)
+
+			ciphertext[len(ciphertext)/2] ^= 1
+			_, err = decrypt(etype, key, usageTicket, ciphertext)
+			a.Error(err)
+		}
+	}
+
+	_, err := decrypt(1, keys[EtypeRC4HMAC], usageTicket, make([]byte, 64))
+	assert.Error(t, err)
+}
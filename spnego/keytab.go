@@ -0,0 +1,165 @@
+package spnego
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Keytab holds the keys of service principals, as exported from the KDC with
+// ktutil, kadmin or ktpass.
+type Keytab struct {
+	Entries []KeytabEntry
+}
+
+// KeytabEntry is the key of a principal, for one key version and
+// encryption type.
+type KeytabEntry struct {
+	// Principal is the name of the principal without realm, e.g.
+	// "HTTP/intranet.example.com".
+	Principal string
+	Realm     string
+	Timestamp time.Time
+	KVNO      uint32
+	EType     int32
+	Key       []byte
+}
+
+// LoadKeytab reads the keytab file.
+func LoadKeytab(path string) (*Keytab, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeytab(data)
+}
+
+// ParseKeytab parses the MIT keytab format, version 2, see
+// https://web.mit.edu/kerberos/krb5-devel/doc/formats/keytab_file_format.html
+func ParseKeytab(data []byte) (*Keytab, error) {
+	if len(data) < 2 || data[0] != 0x05 || data[1] != 0x02 {
+		return nil, errors.New("spnego: not a version 2 keytab")
+	}
+	kt := &Keytab{}
+	r := &keytabReader{data: data[2:]}
+	for len(r.data) > 0 {
+		size := int32(r.uint32())
+		if r.err != nil {
+			break
+		}
+		if size < 0 {
+			// a hole left by a deleted entry
+			r.skip(int(-size))
+			continue
+		}
+		entry := &keytabReader{data: r.bytes(int(size))}
+		if r.err != nil {
+			break
+		}
+
+		e := KeytabEntry{}
+		components := int(entry.uint16())
+		e.Realm = string(entry.counted())
+		names := make([]string, 0, components)
+		for i := 0; i < components; i++ {
+			names = append(names, string(entry.counted()))
+		}
+		e.Principal = strings.Join(names, "/")
+		entry.uint32() // name type
+		e.Timestamp = time.Unix(int64(entry.uint32()), 0)
+		e.KVNO = uint32(entry.uint8())
+		e.EType = int32(entry.uint16())
+		e.Key = entry.counted()
+		// the 32 bit key version of newer keytabs replaces the 8 bit one
+		if len(entry.data) >= 4 {
+			if kvno := entry.uint32(); kvno != 0 {
+				e.KVNO = kvno
+			}
+		}
+		if entry.err != nil {
+			return nil, fmt.Errorf("spnego: invalid keytab entry: %w", entry.err)
+		}
+		kt.Entries = append(kt.Entries, e)
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("spnego: invalid keytab: %w", r.err)
+	}
+	return kt, nil
+}
+
+// key finds the key of the principal for the encryption type, with the key
+// version or the latest one.
+func (kt *Keytab) key(principal, realm string, etype int32, kvno uint32) (*KeytabEntry, error) {
+	var found *KeytabEntry
+	for i := range kt.Entries {
+		e := &kt.Entries[i]
+		if e.Principal != principal || !strings.EqualFold(e.Realm, realm) || e.EType != etype {
+			continue
+		}
+		if kvno != 0 && e.KVNO == kvno {
+			return e, nil
+		}
+		if found == nil || e.KVNO > found.KVNO {
+			found = e
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("spnego: no key for %s@%s with encryption type %d in the keytab", principal, realm, etype)
+	}
+	return found, nil
+}
+
+type keytabReader struct {
+	data []byte
+	err  error
+}
+
+func (r *keytabReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = errors.New("unexpected end of data")
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *keytabReader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *keytabReader) uint8() uint8 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *keytabReader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *keytabReader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+// counted reads a string or key prefixed with its 16 bit length.
+func (r *keytabReader) counted() []byte {
+	n := r.uint16()
+	return r.bytes(int(n))
+}
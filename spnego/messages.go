@@ -0,0 +1,197 @@
+package spnego
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Object identifiers of the mechanisms, see
+// https://www.rfc-editor.org/rfc/rfc4178 and https://www.rfc-editor.org/rfc/rfc4121
+var (
+	oidSPNEGO     = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 2}
+	oidKerberos   = asn1.ObjectIdentifier{1, 2, 840, 113554, 1, 2, 2}
+	oidKerberosMS = asn1.ObjectIdentifier{1, 2, 840, 48018, 1, 2, 2}
+	oidNTLM       = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 2, 10}
+)
+
+// Kerberos messages, see https://www.rfc-editor.org/rfc/rfc4120#section-5
+
+const (
+	tagTicket        = 1
+	tagAuthenticator = 2
+	tagEncTicketPart = 3
+	tagAPReq         = 14
+	tagAPRep         = 15
+	tagEncAPRepPart  = 27
+
+	msgTypeAPReq = 14
+	msgTypeAPRep = 15
+
+	// apOptionMutualRequired is the bit of the AP options asking for an AP-REP
+	apOptionMutualRequired = 2
+)
+
+type principalName struct {
+	NameType   int32    `asn1:"explicit,tag:0"`
+	NameString []string `asn1:"explicit,tag:1"`
+}
+
+func (p principalName) String() string {
+	return strings.Join(p.NameString, "/")
+}
+
+type encryptedData struct {
+	EType  int32  `asn1:"explicit,tag:0"`
+	KVNO   int    `asn1:"explicit,optional,tag:1"`
+	Cipher []byte `asn1:"explicit,tag:2"`
+}
+
+type encryptionKey struct {
+	KeyType  int32  `asn1:"explicit,tag:0"`
+	KeyValue []byte `asn1:"explicit,tag:1"`
+}
+
+type apReq struct {
+	PVNO          int            `asn1:"explicit,tag:0"`
+	MsgType       int            `asn1:"explicit,tag:1"`
+	APOptions     asn1.BitString `asn1:"explicit,tag:2"`
+	Ticket        asn1.RawValue  `asn1:"explicit,tag:3"`
+	Authenticator encryptedData  `asn1:"explicit,tag:4"`
+}
+
+type ticket struct {
+	TktVNO  int           `asn1:"explicit,tag:0"`
+	Realm   string        `asn1:"explicit,tag:1"`
+	SName   principalName `asn1:"explicit,tag:2"`
+	EncPart encryptedData `asn1:"explicit,tag:3"`
+}
+
+type transitedEncoding struct {
+	TRType   int32  `asn1:"explicit,tag:0"`
+	Contents []byte `asn1:"explicit,tag:1"`
+}
+
+type encTicketPart struct {
+	Flags             asn1.BitString    `asn1:"explicit,tag:0"`
+	Key               encryptionKey     `asn1:"explicit,tag:1"`
+	CRealm            string            `asn1:"explicit,tag:2"`
+	CName             principalName     `asn1:"explicit,tag:3"`
+	Transited         transitedEncoding `asn1:"explicit,tag:4"`
+	AuthTime          time.Time         `asn1:"explicit,tag:5,generalized"`
+	StartTime         time.Time         `asn1:"explicit,optional,tag:6,generalized"`
+	EndTime           time.Time         `asn1:"explicit,tag:7,generalized"`
+	RenewTill         time.Time         `asn1:"explicit,optional,tag:8,generalized"`
+	CAddr             asn1.RawValue     `asn1:"explicit,optional,tag:9"`
+	AuthorizationData asn1.RawValue     `asn1:"explicit,optional,tag:10"`
+}
+
+type checksum struct {
+	CksumType int32  `asn1:"explicit,tag:0"`
+	Checksum  []byte `asn1:"explicit,tag:1"`
+}
+
+type authenticator struct {
+	AuthenticatorVNO  int           `asn1:"explicit,tag:0"`
+	CRealm            string        `asn1:"explicit,tag:1"`
+	CName             principalName `asn1:"explicit,tag:2"`
+	Cksum             checksum      `asn1:"explicit,optional,tag:3"`
+	Cusec             int           `asn1:"explicit,tag:4"`
+	CTime             time.Time     `asn1:"explicit,tag:5,generalized"`
+	SubKey            encryptionKey `asn1:"explicit,optional,tag:6"`
+	SeqNumber         int64         `asn1:"explicit,optional,tag:7"`
+	AuthorizationData asn1.RawValue `asn1:"explicit,optional,tag:8"`
+}
+
+type apRep struct {
+	PVNO    int           `asn1:"explicit,tag:0"`
+	MsgType int           `asn1:"explicit,tag:1"`
+	EncPart encryptedData `asn1:"explicit,tag:2"`
+}
+
+type encAPRepPart struct {
+	CTime     time.Time `asn1:"explicit,tag:0,generalized"`
+	Cusec     int       `asn1:"explicit,tag:1"`
+	SeqNumber int64     `asn1:"explicit,optional,tag:3"`
+}
+
+// application returns the params of a message with the application tag.
+func application(tag int) string {
+	return fmt.Sprintf("application,explicit,tag:%d", tag)
+}
+
+// unmarshal decodes a message, and rejects trailing data.
+func unmarshal(b []byte, v interface{}, params string) error {
+	rest, err := asn1.UnmarshalWithParams(b, v, params)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errors.New("spnego: trailing data after the message")
+	}
+	return nil
+}
+
+// SPNEGO messages, see https://www.rfc-editor.org/rfc/rfc4178#section-4.2
+
+type negTokenInit struct {
+	MechTypes   []asn1.ObjectIdentifier `asn1:"explicit,tag:0"`
+	ReqFlags    asn1.BitString          `asn1:"explicit,optional,tag:1"`
+	MechToken   []byte                  `asn1:"explicit,optional,tag:2"`
+	MechListMIC []byte                  `asn1:"explicit,optional,tag:3"`
+}
+
+type negTokenResp struct {
+	NegState      asn1.Enumerated       `asn1:"explicit,tag:0"`
+	SupportedMech asn1.ObjectIdentifier `asn1:"explicit,optional,tag:1"`
+	ResponseToken []byte                `asn1:"explicit,optional,tag:2"`
+	MechListMIC   []byte                `asn1:"explicit,optional,tag:3"`
+}
+
+const negStateAcceptCompleted = 0
+
+// gssToken is the framing of initial context tokens, see
+// https://www.rfc-editor.org/rfc/rfc2743#section-3.1
+type gssToken struct {
+	Mech  asn1.ObjectIdentifier
+	Inner []byte
+}
+
+func parseGSSToken(b []byte) (*gssToken, error) {
+	var raw asn1.RawValue
+	err := unmarshal(b, &raw, "")
+	if err != nil {
+		return nil, err
+	}
+	if raw.Class != asn1.ClassApplication || raw.Tag != 0 || !raw.IsCompound {
+		return nil, errors.New("spnego: not a GSS-API token")
+	}
+	t := &gssToken{}
+	t.Inner, err = asn1.Unmarshal(raw.Bytes, &t.Mech)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *gssToken) marshal() ([]byte, error) {
+	mech, err := asn1.Marshal(t.Mech)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassApplication,
+		Tag:        0,
+		IsCompound: true,
+		Bytes:      append(mech, t.Inner...),
+	})
+}
+
+// Token IDs of the Kerberos GSS-API tokens, see
+// https://www.rfc-editor.org/rfc/rfc4121#section-4.1
+var (
+	tokIDAPReq = []byte{0x01, 0x00}
+	tokIDAPRep = []byte{0x02, 0x00}
+)
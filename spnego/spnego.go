@@ -0,0 +1,369 @@
+// Package spnego authenticates intranet users with the Kerberos ticket of
+// their domain session, with the HTTP Negotiate scheme of
+// https://www.rfc-editor.org/rfc/rfc4559
+//
+// Browsers only send tickets to the sites they are configured to trust, e.g.
+// the "Local intranet" zone of Windows or the network.negotiate-auth.trusted-uris
+// setting of Firefox. The service principal of the site ("HTTP/" followed by
+// its host name) is exported to a keytab by the domain administrator:
+//
+//	kt, err := spnego.LoadKeytab("/etc/intranet.keytab")
+//	a := spnego.New(kt, "HTTP/intranet.example.com")
+//	http.Handle("/auth/spnego", a.Handler(func(res http.ResponseWriter, req *http.Request, user goth.User) {
+//		// sign the user in
+//	}, "/auth/google"))
+//
+// Users whose browser can't negotiate a Kerberos ticket, e.g. outside of the
+// domain, are sent to the fallback URL, such as the gothic begin URL of an
+// OAuth provider.
+//
+// The handler doesn't go through gothic: the users it authenticates have no
+// gothic session, so CompleteUserAuth, the session inventory, the revocation
+// list and the anomaly detectors don't apply to them. The success callback
+// signs them in with the sessions of the application.
+//
+// Tickets encrypted with rc4-hmac are rejected unless AllowRC4 is set.
+package spnego
+
+import (
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+var (
+	// ErrNoToken is returned when the request has no Negotiate authorization.
+	ErrNoToken = errors.New("spnego: no Negotiate token in the request")
+	// ErrNTLM is returned when the client offers NTLM, which isn't supported,
+	// instead of Kerberos.
+	ErrNTLM = errors.New("spnego: the client offered NTLM instead of Kerberos")
+	// ErrReplay is returned when an authenticator is used twice.
+	ErrReplay = errors.New("spnego: the authenticator has already been used")
+	// ErrRC4Disabled is returned for the tickets encrypted with rc4-hmac
+	// when the Authenticator doesn't allow it.
+	ErrRC4Disabled = errors.New("spnego: the rc4-hmac encryption type is disabled")
+)
+
+// New creates a new Authenticator for the service principal, e.g.
+// "HTTP/intranet.example.com", whose keys are in the keytab. Tickets for any
+// principal of the keytab are accepted when the service principal is empty.
+func New(keytab *Keytab, servicePrincipal string) *Authenticator {
+	return &Authenticator{
+		Keytab:           keytab,
+		ServicePrincipal: servicePrincipal,
+		ClockSkew:        5 * time.Minute,
+		providerName:     "spnego",
+		replays:          map[string]time.Time{},
+		now:              time.Now,
+	}
+}
+
+// Authenticator validates the Kerberos tickets browsers send with the
+// Negotiate scheme.
+type Authenticator struct {
+	Keytab           *Keytab
+	ServicePrincipal string
+	// ClockSkew is the difference tolerated between the clocks of the
+	// clients and the server.
+	ClockSkew time.Duration
+	// AllowRC4 accepts the tickets encrypted with rc4-hmac, which older
+	// Active Directory domains still issue. It is weak, and refused in FIPS
+	// mode even when allowed.
+	AllowRC4     bool
+	providerName string
+
+	mu      sync.Mutex
+	replays map[string]time.Time
+	now     func() time.Time
+}
+
+// Name is the name set as the Provider of the users.
+func (a *Authenticator) Name() string {
+	return a.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (a *Authenticator) SetName(name string) {
+	a.providerName = name
+}
+
+// Authenticate validates the Negotiate token of the request. On success, it
+// returns the user, and the WWW-Authenticate header value completing mutual
+// authentication, if the client asked for it.
+func (a *Authenticator) Authenticate(req *http.Request) (goth.User, string, error) {
+	user := goth.User{Provider: a.Name()}
+	header := req.Header.Get("Authorization")
+	if len(header) < len("Negotiate ") || !strings.EqualFold(header[:len("Negotiate ")], "Negotiate ") {
+		return user, "", ErrNoToken
+	}
+	token, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header[len("Negotiate "):]))
+	if err != nil {
+		return user, "", fmt.Errorf("spnego: invalid Negotiate token: %w", err)
+	}
+	if strings.HasPrefix(string(token), "NTLMSSP\x00") {
+		return user, "", ErrNTLM
+	}
+
+	mech, apReqToken, err := kerberosToken(token)
+	if err != nil {
+		return user, "", err
+	}
+	cname, crealm, endTime, apRepToken, err := a.acceptAPReq(apReqToken)
+	if err != nil {
+		return user, "", err
+	}
+
+	user.UserID = cname + "@" + crealm
+	user.NickName = cname
+	user.ExpiresAt = endTime
+	user.RawData = map[string]interface{}{
+		"principal": cname,
+		"realm":     crealm,
+	}
+
+	if apRepToken == nil {
+		return user, "", nil
+	}
+	response, err := asn1.MarshalWithParams(negTokenResp{
+		NegState:      negStateAcceptCompleted,
+		SupportedMech: mech,
+		ResponseToken: apRepToken,
+	}, "explicit,tag:1")
+	if err != nil {
+		return user, "", err
+	}
+	return user, "Negotiate " + base64.StdEncoding.EncodeToString(response), nil
+}
+
+// kerberosToken unwraps the Kerberos AP-REQ of the SPNEGO token, or of the raw
+// Kerberos token some clients send.
+func kerberosToken(token []byte) (asn1.ObjectIdentifier, []byte, error) {
+	gss, err := parseGSSToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mech := gss.Mech
+	inner := gss.Inner
+	if gss.Mech.Equal(oidSPNEGO) {
+		var init negTokenInit
+		err = unmarshal(gss.Inner, &init, "explicit,tag:0")
+		if err != nil {
+			return nil, nil, fmt.Errorf("spnego: invalid NegTokenInit: %w", err)
+		}
+		if len(init.MechTypes) == 0 || len(init.MechToken) == 0 {
+			return nil, nil, errors.New("spnego: the NegTokenInit has no mechanism token")
+		}
+		// the optimistic token is for the preferred mechanism
+		mech = init.MechTypes[0]
+		if mech.Equal(oidNTLM) {
+			return nil, nil, ErrNTLM
+		}
+		gss, err = parseGSSToken(init.MechToken)
+		if err != nil {
+			return nil, nil, err
+		}
+		inner = gss.Inner
+	}
+
+	if !gss.Mech.Equal(oidKerberos) && !gss.Mech.Equal(oidKerberosMS) {
+		return nil, nil, fmt.Errorf("spnego: unsupported mechanism %s", gss.Mech)
+	}
+	if len(inner) < 2 || inner[0] != tokIDAPReq[0] || inner[1] != tokIDAPReq[1] {
+		return nil, nil, errors.New("spnego: the Kerberos token isn't an AP-REQ")
+	}
+	return mech, inner[2:], nil
+}
+
+// acceptAPReq validates the ticket and authenticator, and returns the client
+// principal, and the AP-REP token when mutual authentication is required.
+func (a *Authenticator) acceptAPReq(b []byte) (cname, crealm string, endTime time.Time, apRepToken []byte, err error) {
+	var req apReq
+	err = unmarshal(b, &req, application(tagAPReq))
+	if err != nil {
+		return "", "", endTime, nil, fmt.Errorf("spnego: invalid AP-REQ: %w", err)
+	}
+	if req.PVNO != 5 || req.MsgType != msgTypeAPReq {
+		return "", "", endTime, nil, errors.New("spnego: invalid AP-REQ")
+	}
+
+	var t ticket
+	err = unmarshal(req.Ticket.Bytes, &t, application(tagTicket))
+	if err != nil {
+		return "", "", endTime, nil, fmt.Errorf("spnego: invalid ticket: %w", err)
+	}
+	if a.ServicePrincipal != "" && t.SName.String() != a.ServicePrincipal {
+		return "", "", endTime, nil, fmt.Errorf("spnego: the ticket is for %s", t.SName)
+	}
+	err = a.checkEtype(t.EncPart.EType)
+	if err != nil {
+		return "", "", endTime, nil, err
+	}
+	entry, err := a.Keytab.key(t.SName.String(), t.Realm, t.EncPart.EType, uint32(t.EncPart.KVNO))
+	if err != nil {
+		return "", "", endTime, nil, err
+	}
+
+	plaintext, err := decrypt(t.EncPart.EType, entry.Key, usageTicket, t.EncPart.Cipher)
+	if err != nil {
+		return "", "", endTime, nil, err
+	}
+	var part encTicketPart
+	// the padding of some encryption types is left after the message
+	_, err = asn1.UnmarshalWithParams(plaintext, &part, application(tagEncTicketPart))
+	if err != nil {
+		return "", "", endTime, nil, fmt.Errorf("spnego: invalid ticket: %w", err)
+	}
+
+	now := a.now()
+	startTime := part.StartTime
+	if startTime.IsZero() {
+		startTime = part.AuthTime
+	}
+	if now.Add(a.ClockSkew).Before(startTime) {
+		return "", "", endTime, nil, errors.New("spnego: the ticket isn't valid yet")
+	}
+	if now.Add(-a.ClockSkew).After(part.EndTime) {
+		return "", "", endTime, nil, errors.New("spnego: the ticket has expired")
+	}
+
+	err = a.checkEtype(part.Key.KeyType)
+	if err == nil {
+		err = a.checkEtype(req.Authenticator.EType)
+	}
+	if err != nil {
+		return "", "", endTime, nil, err
+	}
+	plaintext, err = decrypt(req.Authenticator.EType, part.Key.KeyValue, usageAuthenticator, req.Authenticator.Cipher)
+	if err != nil {
+		return "", "", endTime, nil, err
+	}
+	var auth authenticator
+	_, err = asn1.UnmarshalWithParams(plaintext, &auth, application(tagAuthenticator))
+	if err != nil {
+		return "", "", endTime, nil, fmt.Errorf("spnego: invalid authenticator: %w", err)
+	}
+	if auth.CName.String() != part.CName.String() || auth.CRealm != part.CRealm {
+		return "", "", endTime, nil, errors.New("spnego: the authenticator isn't from the client of the ticket")
+	}
+	if auth.CTime.Before(now.Add(-a.ClockSkew)) || auth.CTime.After(now.Add(a.ClockSkew)) {
+		return "", "", endTime, nil, errors.New("spnego: the clock skew of the client is too great")
+	}
+	err = a.checkReplay(auth, now)
+	if err != nil {
+		return "", "", endTime, nil, err
+	}
+
+	if isSet(req.APOptions, apOptionMutualRequired) {
+		apRepToken, err = marshalAPRep(part.Key, auth)
+		if err != nil {
+			return "", "", endTime, nil, err
+		}
+	}
+	return part.CName.String(), part.CRealm, part.EndTime, apRepToken, nil
+}
+
+// checkEtype rejects rc4-hmac unless it is allowed.
+func (a *Authenticator) checkEtype(etype int32) error {
+	if etype == EtypeRC4HMAC && !a.AllowRC4 {
+		return ErrRC4Disabled
+	}
+	return nil
+}
+
+// checkReplay rejects authenticators seen within the clock skew.
+func (a *Authenticator) checkReplay(auth authenticator, now time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for key, expiry := range a.replays {
+		if now.After(expiry) {
+			delete(a.replays, key)
+		}
+	}
+	key := fmt.Sprintf("%s@%s %s %d", auth.CName, auth.CRealm, auth.CTime.UTC().Format(time.RFC3339), auth.Cusec)
+	if _, ok := a.replays[key]; ok {
+		return ErrReplay
+	}
+	a.replays[key] = auth.CTime.Add(a.ClockSkew)
+	return nil
+}
+
+// marshalAPRep encodes the AP-REP proving to the client that the server
+// decrypted its ticket.
+func marshalAPRep(sessionKey encryptionKey, auth authenticator) ([]byte, error) {
+	part, err := asn1.MarshalWithParams(encAPRepPart{
+		CTime:     auth.CTime,
+		Cusec:     auth.Cusec,
+		SeqNumber: auth.SeqNumber,
+	}, application(tagEncAPRepPart))
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := encrypt(sessionKey.KeyType, sessionKey.KeyValue, usageAPRep, part)
+	if err != nil {
+		return nil, err
+	}
+	rep, err := asn1.MarshalWithParams(apRep{
+		PVNO:    5,
+		MsgType: msgTypeAPRep,
+		EncPart: encryptedData{EType: sessionKey.KeyType, Cipher: ciphertext},
+	}, application(tagAPRep))
+	if err != nil {
+		return nil, err
+	}
+	return (&gssToken{Mech: oidKerberos, Inner: append(append([]byte{}, tokIDAPRep...), rep...)}).marshal()
+}
+
+func isSet(flags asn1.BitString, bit int) bool {
+	return bit < flags.BitLength && flags.At(bit) == 1
+}
+
+var fallbackPage = template.Must(template.New("fallback").Parse(`<!DOCTYPE html>
+<html><head><meta http-equiv="refresh" content="0;url={{.}}"></head>
+<body><a href="{{.}}">Sign in</a></body></html>
+`))
+
+// Handler returns a handler asking browsers for a Kerberos ticket, and calling
+// success with the authenticated user. Browsers which can't negotiate a ticket
+// are sent to fallbackURL; without one, they get a 401 Unauthorized response.
+func (a *Authenticator) Handler(success func(res http.ResponseWriter, req *http.Request, user goth.User), fallbackURL string) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		user, challenge, err := a.Authenticate(req)
+		if err == nil {
+			if challenge != "" {
+				res.Header().Set("WWW-Authenticate", challenge)
+			}
+			success(res, req, user)
+			return
+		}
+
+		if err == ErrNoToken {
+			// browsers which don't negotiate show the body of the 401
+			// response, which sends them to the fallback
+			res.Header().Set("WWW-Authenticate", "Negotiate")
+			if fallbackURL == "" {
+				http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			res.Header().Set("Content-Type", "text/html; charset=utf-8")
+			res.WriteHeader(http.StatusUnauthorized)
+			_ = fallbackPage.Execute(res, fallbackURL)
+			return
+		}
+
+		if fallbackURL == "" {
+			http.Error(res, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(res, req, fallbackURL, http.StatusFound)
+	})
+}
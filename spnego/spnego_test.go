@@ -0,0 +1,419 @@
+package spnego
+
+import (
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+type testKey struct {
+	principal string
+	realm     string
+	kvno      uint32
+	etype     int32
+	key       []byte
+}
+
+func randomKey(n int) []byte {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return b
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// marshalKeytab encodes the keys in the keytab format, with a deleted entry.
+func marshalKeytab(keys []testKey) []byte {
+	b := []byte{0x05, 0x02}
+	counted := func(entry []byte, s []byte) []byte {
+		entry = appendUint16(entry, uint16(len(s)))
+		return append(entry, s...)
+	}
+	b = appendUint32(b, uint32(0xfffffff0))
+	b = append(b, make([]byte, 16)...)
+	for _, k := range keys {
+		components := strings.Split(k.principal, "/")
+		var entry []byte
+		entry = appendUint16(entry, uint16(len(components)))
+		entry = counted(entry, []byte(k.realm))
+		for _, c := range components {
+			entry = counted(entry, []byte(c))
+		}
+		entry = appendUint32(entry, 1)
+		entry = appendUint32(entry, 1700000000)
+		entry = append(entry, byte(k.kvno))
+		entry = appendUint16(entry, uint16(k.etype))
+		entry = counted(entry, k.key)
+		entry = appendUint32(entry, k.kvno)
+		b = appendUint32(b, uint32(len(entry)))
+		b = append(b, entry...)
+	}
+	return b
+}
+
+// request describes the AP-REQ a client sends.
+type request struct {
+	service    testKey
+	cname      string
+	crealm     string
+	authName   string
+	sessionKey encryptionKey
+	startTime  time.Time
+	endTime    time.Time
+	ctime      time.Time
+	mutual     bool
+	raw        bool
+}
+
+func newRequest(service testKey) *request {
+	now := time.Now().UTC().Truncate(time.Second)
+	return &request{
+		service:    service,
+		cname:      "jdoe",
+		crealm:     "EXAMPLE.COM",
+		authName:   "jdoe",
+		sessionKey: encryptionKey{KeyType: EtypeAES256CTSHMACSHA196, KeyValue: randomKey(32)},
+		startTime:  now.Add(-time.Minute),
+		endTime:    now.Add(10 * time.Hour),
+		ctime:      now,
+		mutual:     true,
+	}
+}
+
+func mustMarshal(v interface{}, params string) []byte {
+	b, err := asn1.MarshalWithParams(v, params)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// token returns the Negotiate authorization header of the request.
+func (r *request) token() string {
+	cname := principalName{NameType: 1, NameString: strings.Split(r.cname, "/")}
+	part := mustMarshal(encTicketPart{
+		Flags:     asn1.BitString{Bytes: []byte{0, 0, 0, 0}, BitLength: 32},
+		Key:       r.sessionKey,
+		CRealm:    r.crealm,
+		CName:     cname,
+		Transited: transitedEncoding{Contents: []byte{}},
+		AuthTime:  r.startTime,
+		StartTime: r.startTime,
+		EndTime:   r.endTime,
+	}, application(tagEncTicketPart))
+	encPart, err := encrypt(r.service.etype, r.service.key, usageTicket, part)
+	if err != nil {
+		panic(err)
+	}
+	t := mustMarshal(ticket{
+		TktVNO:  5,
+		Realm:   r.service.realm,
+		SName:   principalName{NameType: 2, NameString: strings.Split(r.service.principal, "/")},
+		EncPart: encryptedData{EType: r.service.etype, KVNO: int(r.service.kvno), Cipher: encPart},
+	}, application(tagTicket))
+
+	auth := mustMarshal(authenticator{
+		AuthenticatorVNO: 5,
+		CRealm:           r.crealm,
+		CName:            principalName{NameType: 1, NameString: strings.Split(r.authName, "/")},
+		Cusec:            123,
+		CTime:            r.ctime,
+		SeqNumber:        42,
+	}, application(tagAuthenticator))
+	encAuth, err := encrypt(r.sessionKey.KeyType, r.sessionKey.KeyValue, usageAuthenticator, auth)
+	if err != nil {
+		panic(err)
+	}
+
+	options := asn1.BitString{Bytes: []byte{0, 0, 0, 0}, BitLength: 32}
+	if r.mutual {
+		options.Bytes[0] = 0x20
+	}
+	req := mustMarshal(apReq{
+		PVNO:          5,
+		MsgType:       msgTypeAPReq,
+		APOptions:     options,
+		Ticket:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 3, IsCompound: true, Bytes: t},
+		Authenticator: encryptedData{EType: r.sessionKey.KeyType, Cipher: encAuth},
+	}, application(tagAPReq))
+
+	krb, err := (&gssToken{Mech: oidKerberos, Inner: append([]byte{0x01, 0x00}, req...)}).marshal()
+	if err != nil {
+		panic(err)
+	}
+	if r.raw {
+		return "Negotiate " + base64.StdEncoding.EncodeToString(krb)
+	}
+	init := mustMarshal(negTokenInit{
+		MechTypes: []asn1.ObjectIdentifier{oidKerberosMS, oidKerberos},
+		MechToken: krb,
+	}, "explicit,tag:0")
+	token, err := (&gssToken{Mech: oidSPNEGO, Inner: init}).marshal()
+	if err != nil {
+		panic(err)
+	}
+	return "Negotiate " + base64.StdEncoding.EncodeToString(token)
+}
+
+var (
+	serviceAES = testKey{"HTTP/intranet.example.com", "EXAMPLE.COM", 3, EtypeAES256CTSHMACSHA196, randomKey(32)}
+	serviceOld = testKey{"HTTP/intranet.example.com", "EXAMPLE.COM", 2, EtypeAES256CTSHMACSHA196, randomKey(32)}
+	serviceRC4 = testKey{"HTTP/intranet.example.com", "EXAMPLE.COM", 3, EtypeRC4HMAC, randomKey(16)}
+	otherHost  = testKey{"HTTP/other.example.com", "EXAMPLE.COM", 1, EtypeAES128CTSHMACSHA196, randomKey(16)}
+)
+
+func authenticatorForTest(t *testing.T) *Authenticator {
+	kt, err := ParseKeytab(marshalKeytab([]testKey{serviceOld, serviceAES, serviceRC4, otherHost}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(kt, "HTTP/intranet.example.com")
+}
+
+func authenticate(a *Authenticator, authorization string) (goth.User, string, error) {
+	req := httptest.NewRequest("GET", "/auth/spnego", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return a.Authenticate(req)
+}
+
+func Test_ParseKeytab(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	kt, err := ParseKeytab(marshalKeytab([]testKey{serviceAES, otherHost}))
+	a.NoError(err)
+	a.Len(kt.Entries, 2)
+	a.Equal("HTTP/intranet.example.com", kt.Entries[0].Principal)
+	a.Equal("EXAMPLE.COM", kt.Entries[0].Realm)
+	a.Equal(uint32(3), kt.Entries[0].KVNO)
+	a.Equal(int32(EtypeAES256CTSHMACSHA196), kt.Entries[0].EType)
+	a.Equal(serviceAES.key, kt.Entries[0].Key)
+	a.Equal(int64(1700000000), kt.Entries[0].Timestamp.Unix())
+
+	_, err = ParseKeytab([]byte{0x05, 0x01})
+	a.Error(err)
+	data := marshalKeytab([]testKey{serviceAES})
+	_, err = ParseKeytab(data[:len(data)-10])
+	a.Error(err)
+}
+
+func Test_KeytabKey(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	kt, _ := ParseKeytab(marshalKeytab([]testKey{serviceOld, serviceAES}))
+
+	e, err := kt.key("HTTP/intranet.example.com", "example.com", EtypeAES256CTSHMACSHA196, 2)
+	a.NoError(err)
+	a.Equal(serviceOld.key, e.Key)
+
+	// the latest key is used for unknown key versions
+	e, err = kt.key("HTTP/intranet.example.com", "EXAMPLE.COM", EtypeAES256CTSHMACSHA196, 7)
+	a.NoError(err)
+	a.Equal(serviceAES.key, e.Key)
+
+	_, err = kt.key("HTTP/intranet.example.com", "EXAMPLE.COM", EtypeRC4HMAC, 3)
+	a.Error(err)
+}
+
+func Test_Authenticate(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	auth := authenticatorForTest(t)
+	r := newRequest(serviceAES)
+
+	user, challenge, err := authenticate(auth, r.token())
+	a.NoError(err)
+	a.Equal("spnego", user.Provider)
+	a.Equal("jdoe@EXAMPLE.COM", user.UserID)
+	a.Equal("jdoe", user.NickName)
+	a.Equal("EXAMPLE.COM", user.RawData["realm"])
+	a.Equal(r.endTime, user.ExpiresAt.UTC())
+
+	// the AP-REP proves the server could decrypt the ticket
+	a.True(strings.HasPrefix(challenge, "Negotiate "))
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(challenge, "Negotiate "))
+	a.NoError(err)
+	var resp negTokenResp
+	a.NoError(unmarshal(b, &resp, "explicit,tag:1"))
+	a.Equal(asn1.Enumerated(negStateAcceptCompleted), resp.NegState)
+	a.True(resp.SupportedMech.Equal(oidKerberosMS))
+	gss, err := parseGSSToken(resp.ResponseToken)
+	a.NoError(err)
+	a.Equal(tokIDAPRep, gss.Inner[:2])
+	var rep apRep
+	a.NoError(unmarshal(gss.Inner[2:], &rep, application(tagAPRep)))
+	plaintext, err := decrypt(rep.EncPart.EType, r.sessionKey.KeyValue, usageAPRep, rep.EncPart.Cipher)
+	a.NoError(err)
+	var part encAPRepPart
+	_, err = asn1.UnmarshalWithParams(plaintext, &part, application(tagEncAPRepPart))
+	a.NoError(err)
+	a.Equal(r.ctime, part.CTime.UTC())
+	a.Equal(123, part.Cusec)
+
+	// the authenticator can't be replayed
+	_, _, err = authenticate(auth, r.token())
+	a.Equal(ErrReplay, err)
+}
+
+func Test_AuthenticateRC4(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	if goth.FIPS() {
+		_, err := decrypt(EtypeRC4HMAC, randomKey(16), usageTicket, make([]byte, 64))
+		a.True(errors.Is(err, goth.ErrNotFIPSApproved))
+		return
+	}
+	auth := authenticatorForTest(t)
+	r := newRequest(serviceRC4)
+	r.sessionKey = encryptionKey{KeyType: EtypeRC4HMAC, KeyValue: randomKey(16)}
+	r.mutual = false
+	r.raw = true
+
+	// rc4-hmac is opt-in
+	_, _, err := authenticate(auth, r.token())
+	a.Equal(ErrRC4Disabled, err)
+	// including for the session key of an AES ticket
+	aes := newRequest(serviceAES)
+	aes.sessionKey = r.sessionKey
+	_, _, err = authenticate(auth, aes.token())
+	a.Equal(ErrRC4Disabled, err)
+
+	auth.AllowRC4 = true
+	user, challenge, err := authenticate(auth, r.token())
+	a.NoError(err)
+	a.Equal("jdoe@EXAMPLE.COM", user.UserID)
+	a.Empty(challenge)
+}
+
+func Test_AuthenticateRejected(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		header func() string
+		err    error
+	}{
+		{"no header", func() string { return "" }, ErrNoToken},
+		{"basic", func() string { return "Basic amRvZTpzZWNyZXQ=" }, ErrNoToken},
+		{"not base64", func() string { return "Negotiate !!!" }, nil},
+		{"garbage", func() string { return "Negotiate " + base64.StdEncoding.EncodeToString([]byte("garbage")) }, nil},
+		{"NTLM", func() string {
+			return "Negotiate " + base64.StdEncoding.EncodeToString([]byte("NTLMSSP\x00\x01\x00\x00\x00"))
+		}, ErrNTLM},
+		{"NTLM mechanism", func() string {
+			init := mustMarshal(negTokenInit{MechTypes: []asn1.ObjectIdentifier{oidNTLM}, MechToken: []byte("NTLMSSP\x00")}, "explicit,tag:0")
+			token, _ := (&gssToken{Mech: oidSPNEGO, Inner: init}).marshal()
+			return "Negotiate " + base64.StdEncoding.EncodeToString(token)
+		}, ErrNTLM},
+		{"other service", func() string { return newRequest(otherHost).token() }, nil},
+		{"unknown key", func() string {
+			return newRequest(testKey{"HTTP/intranet.example.com", "EXAMPLE.COM", 3, EtypeAES128CTSHMACSHA196, randomKey(16)}).token()
+		}, nil},
+		{"wrong key", func() string {
+			return newRequest(testKey{"HTTP/intranet.example.com", "EXAMPLE.COM", 3, EtypeAES256CTSHMACSHA196, randomKey(32)}).token()
+		}, errIntegrity},
+		{"expired ticket", func() string {
+			r := newRequest(serviceAES)
+			r.startTime = time.Now().Add(-12 * time.Hour)
+			r.endTime = time.Now().Add(-2 * time.Hour)
+			return r.token()
+		}, nil},
+		{"future ticket", func() string {
+			r := newRequest(serviceAES)
+			r.startTime = time.Now().Add(time.Hour)
+			return r.token()
+		}, nil},
+		{"clock skew", func() string {
+			r := newRequest(serviceAES)
+			r.ctime = time.Now().Add(-10 * time.Minute).UTC().Truncate(time.Second)
+			return r.token()
+		}, nil},
+		{"other client", func() string {
+			r := newRequest(serviceAES)
+			r.authName = "admin"
+			return r.token()
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+			user, _, err := authenticate(authenticatorForTest(t), tt.header())
+			a.Error(err)
+			if tt.err != nil {
+				a.Equal(tt.err, err)
+			}
+			a.Empty(user.UserID)
+		})
+	}
+}
+
+func Test_GeneralString(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	// Kerberos encodes names as GeneralString, which Go never produces
+	var p principalName
+	_, err := asn1.Unmarshal([]byte{
+		0x30, 0x15,
+		0xa0, 0x03, 0x02, 0x01, 0x02,
+		0xa1, 0x0e, 0x30, 0x0c,
+		0x1b, 0x04, 'H', 'T', 'T', 'P',
+		0x1b, 0x04, 'h', 'o', 's', 't',
+	}, &p)
+	a.NoError(err)
+	a.Equal("HTTP/host", p.String())
+}
+
+func Test_Handler(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	auth := authenticatorForTest(t)
+	var signedIn goth.User
+	handler := auth.Handler(func(res http.ResponseWriter, req *http.Request, user goth.User) {
+		signedIn = user
+	}, "/auth/google?next=/")
+
+	// browsers are asked for a ticket, and the others follow the page
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", "/auth/spnego", nil))
+	a.Equal(http.StatusUnauthorized, res.Code)
+	a.Equal("Negotiate", res.Header().Get("WWW-Authenticate"))
+	a.Contains(res.Body.String(), `href="/auth/google?next=/"`)
+	a.Contains(res.Body.String(), `content="0;url=/auth/google?next=/"`)
+
+	res = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/auth/spnego", nil)
+	req.Header.Set("Authorization", "Negotiate "+base64.StdEncoding.EncodeToString([]byte("NTLMSSP\x00\x01")))
+	handler.ServeHTTP(res, req)
+	a.Equal(http.StatusFound, res.Code)
+	a.Equal("/auth/google?next=/", res.Header().Get("Location"))
+
+	res = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/auth/spnego", nil)
+	req.Header.Set("Authorization", newRequest(serviceAES).token())
+	handler.ServeHTTP(res, req)
+	a.Equal("jdoe@EXAMPLE.COM", signedIn.UserID)
+	a.True(strings.HasPrefix(res.Header().Get("WWW-Authenticate"), "Negotiate "))
+
+	res = httptest.NewRecorder()
+	auth.Handler(nil, "").ServeHTTP(res, httptest.NewRequest("GET", "/auth/spnego", nil))
+	a.Equal(http.StatusUnauthorized, res.Code)
+	a.Equal("Negotiate", res.Header().Get("WWW-Authenticate"))
+}
@@ -0,0 +1,95 @@
+package goth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// TLSOptions configures the TLS of the requests of the providers, e.g. to
+// trust the private CA of a self-hosted identity provider like Keycloak or
+// GitLab.
+type TLSOptions struct {
+	// CAFiles are PEM files of certificates trusted in addition to the
+	// roots of the system.
+	CAFiles []string
+	// RootCAs replaces the roots of the system, and the CAFiles are added to
+	// a copy of it.
+	RootCAs *x509.CertPool
+	// MinVersion is the minimum version of TLS, TLS 1.2 when it is zero.
+	MinVersion uint16
+	// KeyLogWriter receives the TLS secrets in the NSS key log format, to
+	// decrypt the traffic with e.g. Wireshark. It defeats the security of
+	// TLS, only set it in development.
+	KeyLogWriter io.Writer
+}
+
+// DefaultClient is the client of the providers without an HTTP client of
+// their own. A client of the DefaultTransport is used when it is nil.
+var DefaultClient *http.Client
+
+// NewTLSConfig returns the TLS configuration of the options.
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	roots := opts.RootCAs
+	if roots == nil {
+		var err error
+		roots, err = x509.SystemCertPool()
+		if err != nil || roots == nil {
+			roots = x509.NewCertPool()
+		}
+	} else if len(opts.CAFiles) > 0 {
+		// the pool of the caller is left as it is
+		var err error
+		roots, err = cloneCertPool(roots)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, file := range opts.CAFiles {
+		pem, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("goth: no certificates found in %s", file)
+		}
+	}
+
+	config := &tls.Config{
+		RootCAs:    roots,
+		MinVersion: opts.MinVersion,
+	}
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	if opts.KeyLogWriter != nil {
+		fmt.Fprintln(os.Stderr, "goth: TLS key logging is enabled, the traffic of the providers can be decrypted. Never enable it in production.")
+		config.KeyLogWriter = opts.KeyLogWriter
+	}
+	return config, nil
+}
+
+// NewHTTPClient returns a client with the TLS configuration of the options,
+// and the pooling of NewTransport.
+func NewHTTPClient(opts TLSOptions) (*http.Client, error) {
+	config, err := NewTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: NewTransport(TransportOptions{TLSClientConfig: config})}, nil
+}
+
+// UseTLS sets the DefaultClient of the providers to a client with the TLS
+// configuration of the options. Providers with an HTTP client of their own
+// keep it.
+func UseTLS(opts TLSOptions) error {
+	client, err := NewHTTPClient(opts)
+	if err != nil {
+		return err
+	}
+	DefaultClient = client
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build go1.19
+// +build go1.19
+
+package goth
+
+import "crypto/x509"
+
+// cloneCertPool returns a copy of the pool, which the CAFiles can be added to.
+func cloneCertPool(pool *x509.CertPool) (*x509.CertPool, error) {
+	return pool.Clone(), nil
+}
@@ -0,0 +1,15 @@
+//go:build !go1.19
+// +build !go1.19
+
+package goth
+
+import (
+	"crypto/x509"
+	"errors"
+)
+
+// cloneCertPool fails before Go 1.19, whose pools can't be copied: adding the
+// CAFiles to the pool would modify the RootCAs of the caller.
+func cloneCertPool(pool *x509.CertPool) (*x509.CertPool, error) {
+	return nil, errors.New("goth: adding CAFiles to RootCAs requires Go 1.19")
+}
@@ -0,0 +1,33 @@
+//go:build go1.19
+// +build go1.19
+
+package goth_test
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewTLSConfigKeepsRootCAs(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	a.NoError(os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	roots := x509.NewCertPool()
+	config, err := goth.NewTLSConfig(goth.TLSOptions{RootCAs: roots, CAFiles: []string{caFile}})
+	a.NoError(err)
+	a.True(roots.Equal(x509.NewCertPool()))
+	a.False(config.RootCAs.Equal(roots))
+}
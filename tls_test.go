@@ -0,0 +1,53 @@
+package goth_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func Test_UseTLS(t *testing.T) {
+	a := assert.New(t)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	a.NoError(os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	// the private CA isn't trusted by default
+	_, err := goth.HTTPClientWithFallBack(nil).Get(server.URL)
+	a.Error(err)
+
+	var keyLog bytes.Buffer
+	a.NoError(goth.UseTLS(goth.TLSOptions{CAFiles: []string{caFile}, KeyLogWriter: &keyLog}))
+	defer func() { goth.DefaultClient = nil }()
+
+	resp, err := goth.HTTPClientWithFallBack(nil).Get(server.URL)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Contains(keyLog.String(), "CLIENT_RANDOM")
+	a.Equal(goth.DefaultClient, goth.ContextForClient(nil).Value(oauth2.HTTPClient))
+
+	// providers with a client of their own keep it
+	own := &http.Client{}
+	a.Equal(own, goth.HTTPClientWithFallBack(own))
+
+	a.NoError(goth.UseTLS(goth.TLSOptions{CAFiles: []string{caFile}, MinVersion: tls.VersionTLS13}))
+	_, err = goth.HTTPClientWithFallBack(nil).Get(server.URL)
+	a.Error(err)
+
+	a.NoError(os.WriteFile(caFile, []byte("not a certificate"), 0600))
+	a.Error(goth.UseTLS(goth.TLSOptions{CAFiles: []string{caFile}}))
+}
@@ -0,0 +1,83 @@
+package totp
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"time"
+)
+
+// Key is a secret to add to an authenticator app, by scanning its QR code or
+// entering the secret.
+type Key struct {
+	Issuer  string
+	Account string
+	Secret  string
+	Period  time.Duration
+	Digits  int
+}
+
+// URI returns the otpauth URI of the key, see
+// https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func (k *Key) URI() string {
+	q := url.Values{}
+	q.Set("secret", k.Secret)
+	if k.Issuer != "" {
+		q.Set("issuer", k.Issuer)
+	}
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(k.Digits))
+	q.Set("period", fmt.Sprint(int64(k.Period/time.Second)))
+
+	label := url.PathEscape(k.Account)
+	if k.Issuer != "" {
+		label = url.PathEscape(k.Issuer) + ":" + label
+	}
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// Image returns the QR code of the URI, with modules of scale pixels and the
+// quiet zone around it.
+func (k *Key) Image(scale int) (image.Image, error) {
+	q, err := encodeQR([]byte(k.URI()))
+	if err != nil {
+		return nil, err
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	const quietZone = 4
+	size := (q.size + 2*quietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+	for row := 0; row < q.size; row++ {
+		for col := 0; col < q.size; col++ {
+			if !q.modules[row][col] {
+				continue
+			}
+			for y := 0; y < scale; y++ {
+				for x := 0; x < scale; x++ {
+					img.SetColorIndex((quietZone+col)*scale+x, (quietZone+row)*scale+y, 1)
+				}
+			}
+		}
+	}
+	return img, nil
+}
+
+// PNG returns the QR code of the URI as a PNG image, with modules of scale
+// pixels.
+func (k *Key) PNG(scale int) ([]byte, error) {
+	img, err := k.Image(scale)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	err = png.Encode(&b, img)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
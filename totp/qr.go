@@ -0,0 +1,468 @@
+package totp
+
+import (
+	"errors"
+)
+
+// A QR code encoder for the enrollment of authenticator apps, see ISO/IEC
+// 18004. Data is encoded in byte mode with the medium error correction level,
+// in versions 1 to 10, which holds up to 213 bytes: more than enough for a key
+// URI.
+
+// errQRTooLong is returned for data which doesn't fit in a version 10 code.
+var errQRTooLong = errors.New("totp: data too long for a QR code")
+
+// qrBlocks is the block structure of a version with the medium error
+// correction level: the error correction codewords of each block, and the
+// data codewords of the blocks in both groups.
+type qrBlocks struct {
+	ecCodewords   int
+	group1Blocks  int
+	group1Data    int
+	group2Blocks  int
+	group2Data    int
+	alignmentBase []int
+}
+
+// qrVersions are the versions 1 to 10 at the medium error correction level.
+var qrVersions = []qrBlocks{
+	{10, 1, 16, 0, 0, nil},
+	{16, 1, 28, 0, 0, []int{6, 18}},
+	{26, 1, 44, 0, 0, []int{6, 22}},
+	{18, 2, 32, 0, 0, []int{6, 26}},
+	{24, 2, 43, 0, 0, []int{6, 30}},
+	{16, 4, 27, 0, 0, []int{6, 34}},
+	{18, 4, 31, 0, 0, []int{6, 22, 38}},
+	{22, 2, 38, 2, 39, []int{6, 24, 42}},
+	{22, 3, 36, 2, 37, []int{6, 26, 46}},
+	{26, 4, 43, 1, 44, []int{6, 28, 50}},
+}
+
+func (b qrBlocks) dataCodewords() int {
+	return b.group1Blocks*b.group1Data + b.group2Blocks*b.group2Data
+}
+
+// qrCode is the matrix of a QR code, true modules are dark.
+type qrCode struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// encodeQR returns the QR code of the data, in the smallest version it fits.
+func encodeQR(data []byte) (*qrCode, error) {
+	version := 0
+	for i, b := range qrVersions {
+		countBits := 8
+		if i+1 >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*b.dataCodewords() {
+			version = i + 1
+			break
+		}
+	}
+	if version == 0 {
+		return nil, errQRTooLong
+	}
+	blocks := qrVersions[version-1]
+
+	codewords := interleave(blocks, qrDataCodewords(data, version, blocks.dataCodewords()))
+
+	q := newQRCode(version)
+	q.drawFunctionPatterns(version)
+	q.drawCodewords(codewords)
+
+	// choose the mask with the lowest penalty
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(mask)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormat(best)
+	return q, nil
+}
+
+// qrDataCodewords encodes the data in byte mode, padded to the capacity.
+func qrDataCodewords(data []byte, version, capacity int) []byte {
+	w := &bitWriter{}
+	w.write(0x4, 4)
+	if version < 10 {
+		w.write(len(data), 8)
+	} else {
+		w.write(len(data), 16)
+	}
+	for _, b := range data {
+		w.write(int(b), 8)
+	}
+	// the terminator, and the padding to a byte
+	for i := 0; i < 4 && w.n < capacity*8; i++ {
+		w.write(0, 1)
+	}
+	for w.n%8 != 0 {
+		w.write(0, 1)
+	}
+	for pad := 0; len(w.bytes) < capacity; pad++ {
+		if pad%2 == 0 {
+			w.write(0xec, 8)
+		} else {
+			w.write(0x11, 8)
+		}
+	}
+	return w.bytes
+}
+
+type bitWriter struct {
+	bytes []byte
+	n     int
+}
+
+func (w *bitWriter) write(v, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.bytes = append(w.bytes, 0)
+		}
+		if v>>uint(i)&1 == 1 {
+			w.bytes[w.n/8] |= 0x80 >> uint(w.n%8)
+		}
+		w.n++
+	}
+}
+
+// interleave splits the data into blocks, adds their error correction
+// codewords, and interleaves them.
+func interleave(b qrBlocks, data []byte) []byte {
+	var dataBlocks, ecBlocks [][]byte
+	for i := 0; i < b.group1Blocks+b.group2Blocks; i++ {
+		n := b.group1Data
+		if i >= b.group1Blocks {
+			n = b.group2Data
+		}
+		dataBlocks = append(dataBlocks, data[:n])
+		ecBlocks = append(ecBlocks, reedSolomon(data[:n], b.ecCodewords))
+		data = data[n:]
+	}
+
+	var out []byte
+	for i := 0; i < b.group1Data || i < b.group2Data; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < b.ecCodewords; i++ {
+		for _, block := range ecBlocks {
+			out = append(out, block[i])
+		}
+	}
+	return out
+}
+
+// gfMul multiplies in GF(256) with the polynomial x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	var z byte
+	for i := 7; i >= 0; i-- {
+		carry := z & 0x80
+		z <<= 1
+		if carry != 0 {
+			z ^= 0x1d
+		}
+		if y>>uint(i)&1 == 1 {
+			z ^= x
+		}
+	}
+	return z
+}
+
+// reedSolomon returns the n error correction codewords of the data.
+func reedSolomon(data []byte, n int) []byte {
+	// the generator polynomial (x - a^0)(x - a^1)...(x - a^(n-1)), without
+	// its leading coefficient
+	generator := make([]byte, n)
+	generator[n-1] = 1
+	root := byte(1)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			generator[j] = gfMul(generator[j], root)
+			if j+1 < n {
+				generator[j] ^= generator[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+
+	remainder := make([]byte, n)
+	for _, b := range data {
+		factor := b ^ remainder[0]
+		copy(remainder, remainder[1:])
+		remainder[n-1] = 0
+		for i := range remainder {
+			remainder[i] ^= gfMul(generator[i], factor)
+		}
+	}
+	return remainder
+}
+
+func newQRCode(version int) *qrCode {
+	size := version*4 + 17
+	q := &qrCode{size: size}
+	q.modules = make([][]bool, size)
+	q.function = make([][]bool, size)
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.function[i] = make([]bool, size)
+	}
+	return q
+}
+
+func (q *qrCode) set(row, col int, dark bool) {
+	q.modules[row][col] = dark
+	q.function[row][col] = true
+}
+
+func (q *qrCode) drawFunctionPatterns(version int) {
+	// timing patterns
+	for i := 0; i < q.size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+
+	// finder patterns with their separators
+	q.drawFinder(3, 3)
+	q.drawFinder(3, q.size-4)
+	q.drawFinder(q.size-4, 3)
+
+	// alignment patterns, except where they overlap the finders
+	positions := qrVersions[version-1].alignmentBase
+	last := len(positions) - 1
+	for i, row := range positions {
+		for j, col := range positions {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dr := -2; dr <= 2; dr++ {
+				for dc := -2; dc <= 2; dc++ {
+					q.set(row+dr, col+dc, abs(dr) == 2 || abs(dc) == 2 || dr == 0 && dc == 0)
+				}
+			}
+		}
+	}
+
+	// reserve the format information, drawn with the mask
+	q.drawFormat(0)
+
+	// version information
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1f25
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := bits>>uint(i)&1 == 1
+			a, b := q.size-11+i%3, i/3
+			q.set(b, a, dark)
+			q.set(a, b, dark)
+		}
+	}
+}
+
+func (q *qrCode) drawFinder(row, col int) {
+	for dr := -4; dr <= 4; dr++ {
+		for dc := -4; dc <= 4; dc++ {
+			r, c := row+dr, col+dc
+			if r < 0 || r >= q.size || c < 0 || c >= q.size {
+				continue
+			}
+			d := abs(dr)
+			if abs(dc) > d {
+				d = abs(dc)
+			}
+			q.set(r, c, d != 2 && d != 4)
+		}
+	}
+}
+
+// formatBits returns the format information of the medium error correction
+// level and the mask.
+func formatBits(mask int) int {
+	data := 0<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (q *qrCode) drawFormat(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool {
+		return bits>>uint(i)&1 == 1
+	}
+
+	// around the top left finder
+	for i := 0; i <= 5; i++ {
+		q.set(i, 8, bit(i))
+	}
+	q.set(7, 8, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(8, 7, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(8, 14-i, bit(i))
+	}
+
+	// split between the other finders
+	for i := 0; i < 8; i++ {
+		q.set(8, q.size-1-i, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(q.size-15+i, 8, bit(i))
+	}
+	// the dark module
+	q.set(q.size-8, 8, true)
+}
+
+// drawCodewords places the codewords in the two module wide columns zigzagging
+// up and down from the bottom right corner, skipping the function patterns.
+func (q *qrCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				col := right - j
+				row := vert
+				if upward {
+					row = q.size - 1 - vert
+				}
+				if q.function[row][col] {
+					continue
+				}
+				if i < len(codewords)*8 {
+					q.modules[row][col] = codewords[i/8]>>uint(7-i%8)&1 == 1
+				}
+				i++
+			}
+		}
+	}
+}
+
+func maskBit(mask, row, col int) bool {
+	switch mask {
+	case 0:
+		return (row+col)%2 == 0
+	case 1:
+		return row%2 == 0
+	case 2:
+		return col%3 == 0
+	case 3:
+		return (row+col)%3 == 0
+	case 4:
+		return (row/2+col/3)%2 == 0
+	case 5:
+		return row*col%2+row*col%3 == 0
+	case 6:
+		return (row*col%2+row*col%3)%2 == 0
+	default:
+		return ((row+col)%2+row*col%3)%2 == 0
+	}
+}
+
+// applyMask inverts the data modules selected by the mask, applying it twice
+// removes it.
+func (q *qrCode) applyMask(mask int) {
+	for row := 0; row < q.size; row++ {
+		for col := 0; col < q.size; col++ {
+			if !q.function[row][col] && maskBit(mask, row, col) {
+				q.modules[row][col] = !q.modules[row][col]
+			}
+		}
+	}
+}
+
+// penalty scores the patterns which make codes harder to scan.
+func (q *qrCode) penalty() int {
+	penalty := 0
+	dark := 0
+	for i := 0; i < q.size; i++ {
+		penalty += linePenalty(q.size, func(j int) bool { return q.modules[i][j] })
+		penalty += linePenalty(q.size, func(j int) bool { return q.modules[j][i] })
+		for j := 0; j < q.size; j++ {
+			if q.modules[i][j] {
+				dark++
+			}
+			if i+1 < q.size && j+1 < q.size {
+				c := q.modules[i][j]
+				if c == q.modules[i+1][j] && c == q.modules[i][j+1] && c == q.modules[i+1][j+1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+	total := q.size * q.size
+	deviation := abs(dark*20 - total*10)
+	penalty += (deviation+total-1)/total*10 - 10
+	return penalty
+}
+
+// linePenalty scores the runs of modules of the same color, and the patterns
+// looking like finders, in a row or a column.
+func linePenalty(size int, module func(int) bool) int {
+	penalty := 0
+	run := 0
+	for j := 0; j < size; j++ {
+		if j > 0 && module(j) == module(j-1) {
+			run++
+		} else {
+			run = 1
+		}
+		if run == 5 {
+			penalty += 3
+		} else if run > 5 {
+			penalty++
+		}
+	}
+
+	finder := []bool{true, false, true, true, true, false, true}
+	for j := 0; j+7 <= size; j++ {
+		match := true
+		for k, dark := range finder {
+			if module(j+k) != dark {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+		// four light modules, or the border, before or after
+		light := func(from, to int) bool {
+			for k := from; k < to; k++ {
+				if k >= 0 && k < size && module(k) {
+					return false
+				}
+			}
+			return true
+		}
+		if light(j-4, j) || light(j+7, j+11) {
+			penalty += 40
+		}
+	}
+	return penalty
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,204 @@
+package totp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ReedSolomon(t *testing.T) {
+	t.Parallel()
+
+	// the "HELLO WORLD" example of ISO/IEC 18004, version 1-M
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	assert.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, reedSolomon(data, 10))
+}
+
+func Test_FormatBits(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	// the format information of the medium level, by mask
+	a.Equal(0x5412, formatBits(0))
+	a.Equal(0x5125, formatBits(1))
+	a.Equal(0x5e7c, formatBits(2))
+	a.Equal(0x4aa0, formatBits(7))
+}
+
+func Test_VersionInformation(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	q := newQRCode(7)
+	q.drawFunctionPatterns(7)
+	// 000111110010010100, the version information of version 7, in the
+	// bottom left block from the least significant bit
+	bits := 0
+	for i := 17; i >= 0; i-- {
+		bits <<= 1
+		if q.modules[q.size-11+i%3][i/3] {
+			bits |= 1
+		}
+	}
+	a.Equal(0x07c94, bits)
+}
+
+func Test_EncodeQR(t *testing.T) {
+	t.Parallel()
+
+	for _, data := range []string{
+		"",
+		"otpauth://totp/Example:homer@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Example",
+		strings.Repeat("a", 14),
+		strings.Repeat("b", 100),
+		strings.Repeat("c", 154),
+		strings.Repeat("d", 213),
+	} {
+		a := assert.New(t)
+		q, err := encodeQR([]byte(data))
+		a.NoError(err)
+		decoded, err := decodeQR(q)
+		a.NoError(err)
+		a.Equal(data, decoded)
+	}
+
+	_, err := encodeQR([]byte(strings.Repeat("e", 214)))
+	assert.Equal(t, errQRTooLong, err)
+}
+
+// errInvalidQR is returned by decodeQR for codes which can't be read.
+var errInvalidQR = errors.New("invalid QR code")
+
+// decodeQR reads the data back from a code, checking its format, finders and
+// error correction.
+func decodeQR(q *qrCode) (string, error) {
+	version := (q.size - 17) / 4
+
+	// the format information of both copies
+	read := func(cells [][2]int) int {
+		bits := 0
+		for i := len(cells) - 1; i >= 0; i-- {
+			bits <<= 1
+			if q.modules[cells[i][0]][cells[i][1]] {
+				bits |= 1
+			}
+		}
+		return bits
+	}
+	var first, second [][2]int
+	for i := 0; i <= 5; i++ {
+		first = append(first, [2]int{i, 8})
+	}
+	first = append(first, [2]int{7, 8}, [2]int{8, 8}, [2]int{8, 7})
+	for i := 9; i < 15; i++ {
+		first = append(first, [2]int{8, 14 - i})
+	}
+	for i := 0; i < 8; i++ {
+		second = append(second, [2]int{8, q.size - 1 - i})
+	}
+	for i := 8; i < 15; i++ {
+		second = append(second, [2]int{q.size - 15 + i, 8})
+	}
+	format := read(first)
+	if format != read(second) {
+		return "", errInvalidQR
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == format {
+			mask = m
+		}
+	}
+	if mask < 0 || !q.modules[q.size-8][8] {
+		return "", errInvalidQR
+	}
+
+	// the finder pattern centers are dark, with a light ring around
+	for _, c := range [][2]int{{3, 3}, {3, q.size - 4}, {q.size - 4, 3}} {
+		if !q.modules[c[0]][c[1]] || q.modules[c[0]-2][c[1]] || !q.modules[c[0]-3][c[1]] {
+			return "", errInvalidQR
+		}
+	}
+
+	// unmask the data modules, and read them in placement order
+	q.applyMask(mask)
+	var bits []bool
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				row := vert
+				if (right+1)&2 == 0 {
+					row = q.size - 1 - vert
+				}
+				if !q.function[row][right-j] {
+					bits = append(bits, q.modules[row][right-j])
+				}
+			}
+		}
+	}
+	q.applyMask(mask)
+
+	var codewords []byte
+	for i := 0; i+8 <= len(bits); i += 8 {
+		var b byte
+		for _, bit := range bits[i : i+8] {
+			b <<= 1
+			if bit {
+				b |= 1
+			}
+		}
+		codewords = append(codewords, b)
+	}
+
+	// deinterleave the blocks and check their error correction
+	blocks := qrVersions[version-1]
+	n := blocks.group1Blocks + blocks.group2Blocks
+	dataBlocks := make([][]byte, n)
+	i := 0
+	for k := 0; k < blocks.group1Data || k < blocks.group2Data; k++ {
+		for b := 0; b < n; b++ {
+			size := blocks.group1Data
+			if b >= blocks.group1Blocks {
+				size = blocks.group2Data
+			}
+			if k < size {
+				dataBlocks[b] = append(dataBlocks[b], codewords[i])
+				i++
+			}
+		}
+	}
+	var data []byte
+	for b := 0; b < n; b++ {
+		var ec []byte
+		for k := 0; k < blocks.ecCodewords; k++ {
+			ec = append(ec, codewords[i+k*n+b])
+		}
+		if string(reedSolomon(dataBlocks[b], blocks.ecCodewords)) != string(ec) {
+			return "", errInvalidQR
+		}
+		data = append(data, dataBlocks[b]...)
+	}
+
+	// byte mode, the character count, and the data
+	if data[0]>>4 != 0x4 {
+		return "", errInvalidQR
+	}
+	var length, offset int
+	if version < 10 {
+		length = int(data[0]&0x0f)<<4 | int(data[1]>>4)
+		offset = 1
+	} else {
+		length = int(data[0]&0x0f)<<12 | int(data[1])<<4 | int(data[2]>>4)
+		offset = 2
+	}
+	out := make([]byte, length)
+	for k := range out {
+		out[k] = data[offset+k]<<4 | data[offset+k+1]>>4
+	}
+	return string(out), nil
+}
@@ -0,0 +1,72 @@
+package totp
+
+import (
+	"sync"
+)
+
+// SecretStore keeps the secrets of the enrolled accounts. Secrets let anyone
+// generate codes, so stores should encrypt them at rest.
+type SecretStore interface {
+	// Secret returns the secret of the account, or ErrNotEnrolled.
+	Secret(account string) (string, error)
+	// SaveSecret stores the secret of the account, an empty secret
+	// unenrolls it.
+	SaveSecret(account, secret string) error
+	// UseStep records the time step of a code accepted for the account, or
+	// returns ErrCodeUsed when the step, or a later one, was already used,
+	// so codes can't be replayed.
+	UseStep(account string, step int64) error
+}
+
+// MemoryStore is a SecretStore keeping secrets in memory, for tests and
+// development.
+type MemoryStore struct {
+	mu      sync.Mutex
+	secrets map[string]string
+	steps   map[string]int64
+}
+
+var _ SecretStore = &MemoryStore{}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		secrets: map[string]string{},
+		steps:   map[string]int64{},
+	}
+}
+
+// Secret returns the secret of the account, or ErrNotEnrolled.
+func (m *MemoryStore) Secret(account string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	secret, ok := m.secrets[account]
+	if !ok {
+		return "", ErrNotEnrolled
+	}
+	return secret, nil
+}
+
+// SaveSecret stores the secret of the account.
+func (m *MemoryStore) SaveSecret(account, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if secret == "" {
+		delete(m.secrets, account)
+	} else {
+		m.secrets[account] = secret
+	}
+	delete(m.steps, account)
+	return nil
+}
+
+// UseStep records the time step of a code accepted for the account.
+func (m *MemoryStore) UseStep(account string, step int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if last, ok := m.steps[account]; ok && step <= last {
+		return ErrCodeUsed
+	}
+	m.steps[account] = step
+	return nil
+}
@@ -0,0 +1,241 @@
+// Package totp implements the time-based one-time passwords of RFC 6238, as a
+// second factor required by gothic after users signed in with a provider.
+//
+// Users enroll by scanning the QR code of a new key with an authenticator app,
+// and confirming it with a code:
+//
+//	key, err := authenticator.NewKey(user)
+//	...
+//	png, err := key.PNG(4)
+//	...
+//	err = authenticator.Enroll(user, key.Secret, req.FormValue("code"))
+//
+// With gothic.StepUp set to the authenticator, gothic.CompleteUserAuth returns
+// gothic.ErrSecondFactorRequired for enrolled users, who then enter a code
+// checked by gothic.VerifySecondFactor:
+//
+//	gothic.StepUp = totp.New("Example", store)
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+)
+
+var (
+	// ErrNotEnrolled is returned for accounts without a secret.
+	ErrNotEnrolled = errors.New("totp: account is not enrolled")
+	// ErrInvalidCode is returned for codes which don't match the secret at
+	// this time.
+	ErrInvalidCode = errors.New("totp: invalid code")
+	// ErrCodeUsed is returned for codes which were already used.
+	ErrCodeUsed = errors.New("totp: code already used")
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Authenticator verifies the codes of authenticator apps, with the secrets of
+// its store.
+type Authenticator struct {
+	// Issuer is the name of the application shown by authenticator apps.
+	Issuer string
+	Store  SecretStore
+	// Account returns the account of the user in the store, the provider
+	// and user ID by default. Applications linking several providers to
+	// their users should return their own user ID.
+	Account func(user goth.User) string
+	// Enforce requires a second factor from all users, instead of the
+	// enrolled ones only. Users who have not enrolled have to, before they
+	// can verify a code.
+	Enforce bool
+	Period  time.Duration
+	Digits  int
+	// Skew is the number of periods before and after the current one which
+	// are accepted, for clocks running apart.
+	Skew int
+
+	now func() time.Time
+}
+
+var _ gothic.SecondFactor = &Authenticator{}
+
+// New creates an Authenticator with the usual parameters of authenticator
+// apps: codes of 6 digits changing every 30 seconds.
+func New(issuer string, store SecretStore) *Authenticator {
+	return &Authenticator{
+		Issuer: issuer,
+		Store:  store,
+		Period: 30 * time.Second,
+		Digits: 6,
+		Skew:   1,
+		now:    goth.Now,
+	}
+}
+
+// GenerateSecret returns a new random secret of 160 bits, base32 encoded.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return secretEncoding.EncodeToString(b), nil
+}
+
+func (a *Authenticator) account(user goth.User) string {
+	if a.Account != nil {
+		return a.Account(user)
+	}
+	return user.Provider + ":" + user.UserID
+}
+
+// Enrolled reports whether the user has a secret.
+func (a *Authenticator) Enrolled(user goth.User) (bool, error) {
+	_, err := a.Store.Secret(a.account(user))
+	if err == ErrNotEnrolled {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Required reports whether the user must verify a code, see Enforce.
+func (a *Authenticator) Required(user goth.User) (bool, error) {
+	if a.Enforce {
+		return true, nil
+	}
+	return a.Enrolled(user)
+}
+
+// Verify checks the code entered by the user. Each code is accepted once.
+func (a *Authenticator) Verify(user goth.User, code string) error {
+	account := a.account(user)
+	secret, err := a.Store.Secret(account)
+	if err != nil {
+		return err
+	}
+	step, err := a.validate(secret, code)
+	if err != nil {
+		return err
+	}
+	return a.Store.UseStep(account, step)
+}
+
+// NewKey generates a key for the user to enroll, see Enroll.
+func (a *Authenticator) NewKey(user goth.User) (*Key, error) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	return &Key{
+		Issuer:  a.Issuer,
+		Account: label(user),
+		Secret:  secret,
+		Period:  a.Period,
+		Digits:  a.Digits,
+	}, nil
+}
+
+// Enroll stores the secret of the user, after checking the user added it to
+// an authenticator app with a code.
+func (a *Authenticator) Enroll(user goth.User, secret, code string) error {
+	_, err := a.validate(secret, code)
+	if err != nil {
+		return err
+	}
+	return a.Store.SaveSecret(a.account(user), secret)
+}
+
+// Unenroll removes the secret of the user.
+func (a *Authenticator) Unenroll(user goth.User) error {
+	return a.Store.SaveSecret(a.account(user), "")
+}
+
+// Code returns the code of the secret at the time.
+func (a *Authenticator) Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return code(key, a.step(t), a.Digits), nil
+}
+
+func (a *Authenticator) step(t time.Time) int64 {
+	return t.Unix() / int64(a.Period/time.Second)
+}
+
+// validate returns the time step of the code.
+func (a *Authenticator) validate(secret, c string) (int64, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, err
+	}
+	c = strings.TrimSpace(c)
+	if len(c) != a.Digits {
+		return 0, ErrInvalidCode
+	}
+	now := a.step(a.clock())
+	for i := -a.Skew; i <= a.Skew; i++ {
+		if subtle.ConstantTimeCompare([]byte(code(key, now+int64(i), a.Digits)), []byte(c)) == 1 {
+			return now + int64(i), nil
+		}
+	}
+	return 0, ErrInvalidCode
+}
+
+func (a *Authenticator) clock() time.Time {
+	if a.now == nil {
+		return goth.Now()
+	}
+	return a.now()
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	key, err := secretEncoding.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("totp: invalid secret")
+	}
+	return key, nil
+}
+
+// code computes the HOTP value of the counter, see
+// https://www.rfc-editor.org/rfc/rfc4226#section-5.3
+func code(key []byte, counter int64, digits int) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// label is the name of the user shown by authenticator apps.
+func label(user goth.User) string {
+	for _, s := range []string{user.Email, user.NickName, user.Name} {
+		if s != "" {
+			return s
+		}
+	}
+	return user.UserID
+}
@@ -0,0 +1,153 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Code(t *testing.T) {
+	t.Parallel()
+
+	// https://www.rfc-editor.org/rfc/rfc6238#appendix-B
+	a := New("Example", NewMemoryStore())
+	a.Digits = 8
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	tests := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for unix, expected := range tests {
+		c, err := a.Code(secret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, c, unix)
+	}
+}
+
+func authenticator(now time.Time) *Authenticator {
+	a := New("Example", NewMemoryStore())
+	a.now = func() time.Time { return now }
+	return a
+}
+
+func Test_EnrollVerify(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	now := time.Unix(1700000000, 0)
+	auth := authenticator(now)
+	user := goth.User{Provider: "github", UserID: "1234", Email: "homer@example.com"}
+
+	required, err := auth.Required(user)
+	a.NoError(err)
+	a.False(required)
+	a.Equal(ErrNotEnrolled, auth.Verify(user, "123456"))
+
+	key, err := auth.NewKey(user)
+	a.NoError(err)
+	a.Len(key.Secret, 32)
+	a.Equal("homer@example.com", key.Account)
+
+	a.Equal(ErrInvalidCode, auth.Enroll(user, key.Secret, "000000"))
+	c, err := auth.Code(key.Secret, now)
+	a.NoError(err)
+	a.NoError(auth.Enroll(user, key.Secret, c))
+
+	required, err = auth.Required(user)
+	a.NoError(err)
+	a.True(required)
+
+	// the code confirming the enrollment can be used once more
+	a.NoError(auth.Verify(user, c))
+	a.Equal(ErrCodeUsed, auth.Verify(user, c))
+
+	// the previous period is accepted for slow users, but was already used
+	previous, err := auth.Code(key.Secret, now.Add(-30*time.Second))
+	a.NoError(err)
+	a.Equal(ErrCodeUsed, auth.Verify(user, previous))
+
+	next, err := auth.Code(key.Secret, now.Add(30*time.Second))
+	a.NoError(err)
+	a.NoError(auth.Verify(user, next))
+
+	late, err := auth.Code(key.Secret, now.Add(2*time.Minute))
+	a.NoError(err)
+	a.Equal(ErrInvalidCode, auth.Verify(user, late))
+	a.Equal(ErrInvalidCode, auth.Verify(user, "12345"))
+
+	// other providers are other accounts
+	a.Equal(ErrNotEnrolled, auth.Verify(goth.User{Provider: "google", UserID: "1234"}, next))
+
+	a.NoError(auth.Unenroll(user))
+	required, err = auth.Required(user)
+	a.NoError(err)
+	a.False(required)
+
+	auth.Enforce = true
+	required, err = auth.Required(user)
+	a.NoError(err)
+	a.True(required)
+}
+
+func Test_Account(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	now := time.Unix(1700000000, 0)
+	auth := authenticator(now)
+	auth.Account = func(user goth.User) string { return user.Email }
+	secret, err := GenerateSecret()
+	a.NoError(err)
+	c, err := auth.Code(secret, now)
+	a.NoError(err)
+	a.NoError(auth.Enroll(goth.User{Provider: "github", Email: "homer@example.com"}, secret, c))
+
+	enrolled, err := auth.Enrolled(goth.User{Provider: "google", Email: "homer@example.com"})
+	a.NoError(err)
+	a.True(enrolled)
+}
+
+func Test_DecodeSecret(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	key, err := decodeSecret("jbsw y3dp ehpk 3pxp")
+	a.NoError(err)
+	a.Equal("Hello!\xde\xad\xbe\xef", string(key))
+
+	_, err = decodeSecret("not base32!")
+	a.Error(err)
+	_, err = decodeSecret("")
+	a.Error(err)
+}
+
+func Test_KeyURI(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	key := &Key{Issuer: "Example Co", Account: "homer@example.com", Secret: "JBSWY3DPEHPK3PXP", Period: 30 * time.Second, Digits: 6}
+	uri := key.URI()
+	a.True(strings.HasPrefix(uri, "otpauth://totp/Example%20Co:homer@example.com?"), uri)
+	a.Contains(uri, "secret=JBSWY3DPEHPK3PXP")
+	a.Contains(uri, "issuer=Example+Co")
+	a.Contains(uri, "digits=6")
+	a.Contains(uri, "period=30")
+
+	png, err := key.PNG(4)
+	a.NoError(err)
+	a.Equal("\x89PNG", string(png[:4]))
+
+	img, err := key.Image(2)
+	a.NoError(err)
+	q, err := encodeQR([]byte(uri))
+	a.NoError(err)
+	a.Equal((q.size+8)*2, img.Bounds().Dx())
+}
@@ -0,0 +1,146 @@
+// Package tracing traces the authentication flows of gothic with
+// OpenTelemetry: the start of the authentication, the completion with the
+// token exchange and the fetch of the user, and the requests of the providers
+// to their services.
+//
+//	gothic.StartSpan = tracing.New(otel.GetTracerProvider())
+//	provider.HTTPClient = &http.Client{Transport: tracing.NewTransport(otel.GetTracerProvider(), nil)}
+//
+// The requests of the providers are children of the span of the callback
+// request for the providers implementing goth.ContextProvider. The spans have
+// the name of the provider, never the values of tokens: the query of the
+// URLs, where some providers send them, is not recorded.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/andreimerlescu/goth/gothic"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+)
+
+// InstrumentationName is the name of the tracers.
+const InstrumentationName = "github.com/andreimerlescu/goth"
+
+// The attributes of the spans.
+const (
+	ProviderKey             = attribute.Key("goth.provider")
+	OperationKey            = attribute.Key("goth.operation")
+	SecondFactorRequiredKey = attribute.Key("goth.second_factor_required")
+	httpMethodKey           = attribute.Key("http.method")
+	httpURLKey              = attribute.Key("http.url")
+	httpStatusCodeKey       = attribute.Key("http.status_code")
+	netPeerNameKey          = attribute.Key("net.peer.name")
+	oauth2ErrorCodeKey      = attribute.Key("oauth2.error")
+	oauth2StatusCodeKey     = attribute.Key("oauth2.status_code")
+)
+
+// New returns the gothic.StartSpan function starting the spans of gothic with
+// the tracer provider.
+func New(tp trace.TracerProvider) func(ctx context.Context, operation, provider string) (context.Context, func(err error)) {
+	tracer := tp.Tracer(InstrumentationName)
+	return func(ctx context.Context, operation, provider string) (context.Context, func(err error)) {
+		ctx, span := tracer.Start(ctx, "gothic."+operation, trace.WithAttributes(
+			OperationKey.String(operation),
+			ProviderKey.String(provider),
+		))
+		return ctx, func(err error) {
+			endSpan(span, err)
+		}
+	}
+}
+
+func endSpan(span trace.Span, err error) {
+	defer span.End()
+	if errors.Is(err, gothic.ErrSecondFactorRequired) {
+		// the user signed in, and verifies the second factor next
+		span.SetAttributes(SecondFactorRequiredKey.Bool(true))
+		return
+	}
+	if err == nil {
+		return
+	}
+
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		// the body of the response may hold anything
+		span.SetAttributes(oauth2ErrorCodeKey.String(retrieveErr.ErrorCode))
+		if retrieveErr.Response != nil {
+			span.SetAttributes(oauth2StatusCodeKey.Int(retrieveErr.Response.StatusCode))
+		}
+		span.SetStatus(codes.Error, "oauth2: token exchange failed")
+		return
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		// the URL may hold tokens
+		span.SetStatus(codes.Error, urlErr.Op+" "+redact(urlErr.URL)+": "+urlErr.Err.Error())
+		return
+	}
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// redact removes the query and credentials of the URL.
+func redact(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	u.User = nil
+	return u.String()
+}
+
+// NewTransport returns a transport tracing the requests made with the base
+// transport, http.DefaultTransport when it is nil, and propagating their
+// trace with the global propagator.
+func NewTransport(tp trace.TracerProvider, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{
+		base:   base,
+		tracer: tp.Tracer(InstrumentationName),
+	}
+}
+
+type transport struct {
+	base   http.RoundTripper
+	tracer trace.Tracer
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			httpMethodKey.String(req.Method),
+			httpURLKey.String(redact(req.URL.String())),
+			netPeerNameKey.String(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+	span.SetAttributes(httpStatusCodeKey.Int(resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, "HTTP "+strconv.Itoa(resp.StatusCode))
+	}
+	return resp, nil
+}
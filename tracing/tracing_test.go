@@ -0,0 +1,142 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	"github.com/andreimerlescu/goth/providers/faux"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/oauth2"
+)
+
+func newTracerProvider() (*sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	return sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	values := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		values[kv.Key] = kv.Value
+	}
+	return values
+}
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	tp, recorder := newTracerProvider()
+	startSpan := New(tp)
+
+	ctx, end := startSpan(context.Background(), gothic.OperationCompleteUserAuth, "github")
+	_, endAuthorize := startSpan(ctx, gothic.OperationAuthorize, "github")
+	endAuthorize(&oauth2.RetrieveError{
+		Response:  &http.Response{StatusCode: http.StatusBadRequest},
+		Body:      []byte(`{"error":"invalid_grant","refresh_token":"secret"}`),
+		ErrorCode: "invalid_grant",
+	})
+	end(&url.Error{Op: "Get", URL: "https://api.example.com/user?access_token=secret", Err: errors.New("EOF")})
+
+	spans := recorder.Ended()
+	a.Len(spans, 2)
+	a.Equal("gothic.authorize", spans[0].Name())
+	a.Equal(spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	a.Equal(codes.Error, spans[0].Status().Code)
+	a.NotContains(spans[0].Status().Description, "secret")
+	a.Equal("invalid_grant", attributes(spans[0])[oauth2ErrorCodeKey].AsString())
+	a.Equal(int64(400), attributes(spans[0])[oauth2StatusCodeKey].AsInt64())
+
+	a.Equal("gothic.complete_user_auth", spans[1].Name())
+	a.Equal("github", attributes(spans[1])[ProviderKey].AsString())
+	a.Equal("Get https://api.example.com/user: EOF", spans[1].Status().Description)
+
+	_, end = startSpan(context.Background(), gothic.OperationCompleteUserAuth, "github")
+	end(gothic.ErrSecondFactorRequired)
+	span := recorder.Ended()[2]
+	a.Equal(codes.Unset, span.Status().Code)
+	a.True(attributes(span)[SecondFactorRequiredKey].AsBool())
+}
+
+func Test_Transport(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		traceparent = req.Header.Get("Traceparent")
+		res.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	tp, recorder := newTracerProvider()
+	client := &http.Client{Transport: &transport{base: http.DefaultTransport, tracer: tp.Tracer(InstrumentationName)}}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "callback")
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/user?access_token=secret", nil)
+	a.NoError(err)
+	resp, err := client.Do(req)
+	a.NoError(err)
+	resp.Body.Close()
+	parent.End()
+
+	span := recorder.Ended()[0]
+	a.Equal("HTTP GET", span.Name())
+	a.Equal(parent.SpanContext().SpanID(), span.Parent().SpanID())
+	a.Equal(server.URL+"/user", attributes(span)[httpURLKey].AsString())
+	a.Equal(int64(401), attributes(span)[httpStatusCodeKey].AsInt64())
+	a.Equal(codes.Error, span.Status().Code)
+	// the global propagator is a no-op unless one is set
+	a.Empty(traceparent)
+
+	propagated := &transport{base: http.DefaultTransport, tracer: tp.Tracer(InstrumentationName)}
+	req, _ = http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err = propagated.RoundTrip(req)
+	a.NoError(err)
+	resp.Body.Close()
+	a.Contains(traceparent, parent.SpanContext().TraceID().String())
+}
+
+func Test_Gothic(t *testing.T) {
+	a := assert.New(t)
+
+	tp, recorder := newTracerProvider()
+	gothic.StartSpan = New(tp)
+	defer func() { gothic.StartSpan = nil }()
+	store := gothic.Store
+	gothic.Store = sessions.NewCookieStore([]byte("secret"))
+	defer func() { gothic.Store = store }()
+	goth.UseProviders(&faux.Provider{})
+
+	res := httptest.NewRecorder()
+	begin := httptest.NewRequest("GET", "/auth?provider=faux", nil)
+	authURL, err := gothic.GetAuthURL(res, begin)
+	a.NoError(err)
+	u, _ := url.Parse(authURL)
+
+	callback := httptest.NewRequest("GET", "/auth/callback?provider=faux&state="+url.QueryEscape(u.Query().Get("state")), nil)
+	for _, cookie := range res.Result().Cookies() {
+		callback.AddCookie(cookie)
+	}
+	_, err = gothic.CompleteUserAuth(httptest.NewRecorder(), callback)
+	a.NoError(err)
+
+	var names []string
+	for _, span := range recorder.Ended() {
+		names = append(names, span.Name())
+	}
+	a.Equal([]string{"gothic.begin_auth", "gothic.authorize", "gothic.fetch_user", "gothic.complete_user_auth"}, names)
+}
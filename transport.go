@@ -0,0 +1,104 @@
+package goth
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// TransportOptions configures the pooling of the connections of a transport
+// created by NewTransport. The zero values are replaced by the defaults.
+type TransportOptions struct {
+	// MaxIdleConns is the number of idle connections kept, 256 by default.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost is the number of idle connections kept per host,
+	// e.g. to the token endpoint of a provider, 64 by default.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the connections per host, unlimited when it is
+	// zero.
+	MaxConnsPerHost int
+	// IdleConnTimeout closes the idle connections, after 90 seconds by
+	// default.
+	IdleConnTimeout time.Duration
+	// DialTimeout is the timeout of the connections, 10 seconds by default.
+	DialTimeout time.Duration
+	// TLSHandshakeTimeout is the timeout of the TLS handshakes, 10 seconds
+	// by default.
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout is the time to wait for the headers of the
+	// responses, 30 seconds by default.
+	ResponseHeaderTimeout time.Duration
+	// TLSClientConfig is the TLS configuration, see NewTLSConfig.
+	TLSClientConfig *tls.Config
+}
+
+// NewTransport returns a transport pooling its connections with the
+// options, and the proxy of the environment.
+func NewTransport(opts TransportOptions) *http.Transport {
+	if opts.MaxIdleConns == 0 {
+		opts.MaxIdleConns = 256
+	}
+	if opts.MaxIdleConnsPerHost == 0 {
+		opts.MaxIdleConnsPerHost = 64
+	}
+	if opts.IdleConnTimeout == 0 {
+		opts.IdleConnTimeout = 90 * time.Second
+	}
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = 10 * time.Second
+	}
+	if opts.TLSHandshakeTimeout == 0 {
+		opts.TLSHandshakeTimeout = 10 * time.Second
+	}
+	if opts.ResponseHeaderTimeout == 0 {
+		opts.ResponseHeaderTimeout = 30 * time.Second
+	}
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   opts.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          opts.MaxIdleConns,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig:       opts.TLSClientConfig,
+	}
+}
+
+// DefaultTransport is the transport shared by the providers without an HTTP
+// client of their own, when DefaultClient is nil. It keeps more idle
+// connections per host than http.DefaultTransport, which keeps 2: under a
+// high throughput of logins, the requests to the token endpoint of a provider
+// would otherwise open new connections, exhausting the ephemeral ports.
+// Replace it before the first request.
+//
+// Applications replacing http.DefaultTransport, e.g. with an instrumented
+// transport or httpmock in their tests, keep sending the requests of the
+// providers with it.
+var DefaultTransport http.RoundTripper = NewTransport(TransportOptions{})
+
+// sharedClient is the client of the providers without an HTTP client of
+// their own, when DefaultClient is nil.
+var sharedClient = &http.Client{Transport: sharedTransport{}}
+
+// stdTransport is the http.DefaultTransport of the standard library, to tell
+// whether the application replaced it.
+var stdTransport = http.DefaultTransport
+
+// sharedTransport sends the requests with the DefaultTransport, or the
+// http.DefaultTransport replaced by the application.
+type sharedTransport struct{}
+
+func (sharedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if http.DefaultTransport != stdTransport {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return DefaultTransport.RoundTrip(req)
+}
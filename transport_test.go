@@ -0,0 +1,125 @@
+package goth_test
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+type countingTransport struct {
+	mu       sync.Mutex
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.requests++
+	c.mu.Unlock()
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func Test_DefaultTransport(t *testing.T) {
+	a := assert.New(t)
+
+	defer func(transport http.RoundTripper) { goth.DefaultTransport = transport }(goth.DefaultTransport)
+	counting := &countingTransport{}
+	goth.DefaultTransport = counting
+
+	resp, err := goth.HTTPClientWithFallBack(nil).Get("https://example.com")
+	a.NoError(err)
+	resp.Body.Close()
+	client, ok := goth.ContextForClient(nil).Value(oauth2.HTTPClient).(*http.Client)
+	a.True(ok)
+	resp, err = client.Get("https://example.com")
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(2, counting.requests)
+
+	// an http.DefaultTransport replaced by the application is kept
+	replaced := &countingTransport{}
+	std := http.DefaultTransport
+	http.DefaultTransport = replaced
+	resp, err = goth.HTTPClientWithFallBack(nil).Get("https://example.com")
+	http.DefaultTransport = std
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(1, replaced.requests)
+	a.Equal(2, counting.requests)
+
+	// the DefaultClient replaces the shared transport
+	goth.DefaultClient = &http.Client{Transport: &countingTransport{}}
+	defer func() { goth.DefaultClient = nil }()
+	resp, err = goth.HTTPClientWithFallBack(nil).Get("https://example.com")
+	a.NoError(err)
+	resp.Body.Close()
+	a.Equal(2, counting.requests)
+}
+
+func Test_DefaultTransport_Pooling(t *testing.T) {
+	a := assert.New(t)
+
+	var mu sync.Mutex
+	conns := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		time.Sleep(time.Millisecond)
+		_, _ = io.WriteString(res, `{"access_token":"token"}`)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	defer func(transport http.RoundTripper) { goth.DefaultTransport = transport }(goth.DefaultTransport)
+	goth.DefaultTransport = goth.NewTransport(goth.TransportOptions{})
+
+	// the connections are reused by the concurrent logins, where
+	// http.DefaultTransport keeps 2 idle connections and opens new ones
+	const concurrency = 16
+	for round := 0; round < 5; round++ {
+		var wg sync.WaitGroup
+		for i := 0; i < concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := goth.HTTPClientWithFallBack(nil).Get(server.URL)
+				if a.NoError(err) {
+					_, _ = io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	a.LessOrEqual(conns, concurrency)
+}
+
+func Test_NewTransport(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	transport := goth.NewTransport(goth.TransportOptions{})
+	a.Equal(256, transport.MaxIdleConns)
+	a.Equal(64, transport.MaxIdleConnsPerHost)
+	a.Equal(90*time.Second, transport.IdleConnTimeout)
+	a.Equal(30*time.Second, transport.ResponseHeaderTimeout)
+	a.NotNil(transport.Proxy)
+
+	transport = goth.NewTransport(goth.TransportOptions{MaxIdleConnsPerHost: 8, MaxConnsPerHost: 32})
+	a.Equal(8, transport.MaxIdleConnsPerHost)
+	a.Equal(32, transport.MaxConnsPerHost)
+}
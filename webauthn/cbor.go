@@ -0,0 +1,138 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// maxCBORDepth bounds the nesting of decoded CBOR items.
+const maxCBORDepth = 16
+
+var errCBORTruncated = errors.New("webauthn: truncated CBOR data")
+
+// decodeCBOR decodes the first CBOR item of data, as used by attestation
+// objects and COSE keys, and returns it with the number of bytes it took.
+// Unsigned and negative integers are decoded as int64, byte strings as
+// []byte, text strings as string, arrays as []interface{} and maps as
+// map[interface{}]interface{}. Floats and tags are not supported.
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (interface{}, int, error) {
+	if depth > maxCBORDepth {
+		return nil, 0, errors.New("webauthn: CBOR data nested too deeply")
+	}
+	if len(data) == 0 {
+		return nil, 0, errCBORTruncated
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	n := 1
+
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24:
+		if len(data) < 2 {
+			return nil, 0, errCBORTruncated
+		}
+		arg = uint64(data[1])
+		n = 2
+	case info == 25:
+		if len(data) < 3 {
+			return nil, 0, errCBORTruncated
+		}
+		arg = uint64(binary.BigEndian.Uint16(data[1:]))
+		n = 3
+	case info == 26:
+		if len(data) < 5 {
+			return nil, 0, errCBORTruncated
+		}
+		arg = uint64(binary.BigEndian.Uint32(data[1:]))
+		n = 5
+	case info == 27:
+		if len(data) < 9 {
+			return nil, 0, errCBORTruncated
+		}
+		arg = binary.BigEndian.Uint64(data[1:])
+		n = 9
+	default:
+		return nil, 0, errors.New("webauthn: indefinite length CBOR items are not supported")
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, 0, errors.New("webauthn: CBOR integer overflow")
+		}
+		return int64(arg), n, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, 0, errors.New("webauthn: CBOR integer overflow")
+		}
+		return -1 - int64(arg), n, nil
+	case 2, 3:
+		if arg > uint64(len(data)-n) {
+			return nil, 0, errCBORTruncated
+		}
+		end := n + int(arg)
+		if major == 2 {
+			b := make([]byte, arg)
+			copy(b, data[n:end])
+			return b, end, nil
+		}
+		return string(data[n:end]), end, nil
+	case 4:
+		if arg > uint64(len(data)-n) {
+			return nil, 0, errCBORTruncated
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, size, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			items = append(items, item)
+			n += size
+		}
+		return items, n, nil
+	case 5:
+		if arg > uint64(len(data)-n) {
+			return nil, 0, errCBORTruncated
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, size, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += size
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, 0, errors.New("webauthn: unsupported CBOR map key")
+			}
+			value, size, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += size
+			m[key] = value
+		}
+		return m, n, nil
+	case 7:
+		switch info {
+		case 20:
+			return false, n, nil
+		case 21:
+			return true, n, nil
+		case 22, 23:
+			return nil, n, nil
+		}
+	}
+	return nil, 0, errors.New("webauthn: unsupported CBOR item")
+}
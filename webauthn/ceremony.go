@@ -0,0 +1,118 @@
+package webauthn
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Flags of the authenticator data.
+const (
+	flagUserPresent         = 0x01
+	flagUserVerified        = 0x04
+	flagAttestedCredentials = 0x40
+)
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// verifyClientData checks the client data was collected by a browser at one
+// of the origins for the ceremony with the challenge.
+func (p *Provider) verifyClientData(raw []byte, ceremony, challenge string) error {
+	cd := clientData{}
+	err := json.Unmarshal(raw, &cd)
+	if err != nil {
+		return err
+	}
+	if cd.Type != ceremony {
+		return fmt.Errorf("webauthn: client data of a %q ceremony", cd.Type)
+	}
+
+	expected, err := decodeBase64URL(challenge)
+	if err != nil {
+		return err
+	}
+	received, err := decodeBase64URL(cd.Challenge)
+	if err != nil || len(expected) == 0 || subtle.ConstantTimeCompare(expected, received) != 1 {
+		return errors.New("webauthn: challenge mismatch")
+	}
+
+	if cd.CrossOrigin {
+		return errors.New("webauthn: cross-origin ceremonies are not allowed")
+	}
+	for _, origin := range p.Origins {
+		if cd.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("webauthn: ceremony at origin %q", cd.Origin)
+}
+
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    *publicKey
+	rawPublicKey []byte
+}
+
+// parseAuthenticatorData decodes the authenticator data, and the credential
+// data attested with it when creating a credential.
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("webauthn: authenticator data is too short")
+	}
+	ad := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if ad.flags&flagAttestedCredentials == 0 {
+		return ad, nil
+	}
+
+	rest := data[37:]
+	// the AAGUID of the authenticator, then the length of the credential ID
+	if len(rest) < 18 {
+		return nil, errors.New("webauthn: attested credential data is too short")
+	}
+	length := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if length == 0 || length > 1023 || len(rest) < length {
+		return nil, errors.New("webauthn: invalid credential ID")
+	}
+	ad.credentialID = rest[:length]
+	rest = rest[length:]
+
+	pk, n, err := parsePublicKey(rest)
+	if err != nil {
+		return nil, err
+	}
+	ad.publicKey = pk
+	ad.rawPublicKey = rest[:n]
+	return ad, nil
+}
+
+// verifyAuthenticatorData checks the authenticator data is scoped to the
+// relying party, and that the user was present and verified when required.
+func (p *Provider) verifyAuthenticatorData(ad *authenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(p.RPID))
+	if subtle.ConstantTimeCompare(rpIDHash[:], ad.rpIDHash) != 1 {
+		return errors.New("webauthn: authenticator data for another relying party")
+	}
+	if ad.flags&flagUserPresent == 0 {
+		return errors.New("webauthn: user was not present")
+	}
+	if p.UserVerification == UserVerificationRequired && ad.flags&flagUserVerified == 0 {
+		return errors.New("webauthn: user was not verified")
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	// register the hashes used by the COSE algorithms
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// COSE algorithms supported for credentials, in the order of preference
+// offered to authenticators.
+// See https://www.iana.org/assignments/cose/cose.xhtml#algorithms
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgES384 int64 = -35
+	AlgES512 int64 = -36
+	AlgRS256 int64 = -257
+)
+
+var supportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgES384, AlgES512, AlgRS256}
+
+const (
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+)
+
+// publicKey is a credential public key decoded from its COSE_Key encoding.
+type publicKey struct {
+	algorithm int64
+	key       crypto.PublicKey
+}
+
+// parsePublicKey decodes a COSE_Key, and returns the number of bytes it took.
+func parsePublicKey(data []byte) (*publicKey, int, error) {
+	item, n, err := decodeCBOR(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	m, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, errors.New("webauthn: credential public key is not a COSE key")
+	}
+
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+	pk := &publicKey{algorithm: alg}
+
+	switch kty {
+	case coseKeyTypeEC2:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		var curve elliptic.Curve
+		switch {
+		case alg == AlgES256 && crv == 1:
+			curve = elliptic.P256()
+		case alg == AlgES384 && crv == 2:
+			curve = elliptic.P384()
+		case alg == AlgES512 && crv == 3:
+			curve = elliptic.P521()
+		default:
+			return nil, 0, fmt.Errorf("webauthn: unsupported EC2 key with algorithm %d and curve %d", alg, crv)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, 0, errors.New("webauthn: credential public key is not on its curve")
+		}
+		pk.key = key
+	case coseKeyTypeOKP:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if alg != AlgEdDSA || crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, fmt.Errorf("webauthn: unsupported OKP key with algorithm %d and curve %d", alg, crv)
+		}
+		pk.key = ed25519.PublicKey(x)
+	case coseKeyTypeRSA:
+		nBytes, _ := m[int64(-1)].([]byte)
+		eBytes, _ := m[int64(-2)].([]byte)
+		if alg != AlgRS256 || len(nBytes) == 0 || len(eBytes) == 0 || len(eBytes) > 4 {
+			return nil, 0, fmt.Errorf("webauthn: unsupported RSA key with algorithm %d", alg)
+		}
+		e := new(big.Int).SetBytes(eBytes)
+		pk.key = &rsa.PublicKey{N: new(big.Int).SetBytes(nBytes), E: int(e.Int64())}
+	default:
+		return nil, 0, fmt.Errorf("webauthn: unsupported key type %d", kty)
+	}
+	return pk, n, nil
+}
+
+// verify checks the signature of the data with the key.
+func (pk *publicKey) verify(data, signature []byte) bool {
+	switch key := pk.key.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, data, signature)
+	case *rsa.PublicKey:
+		hashed := crypto.SHA256.New()
+		hashed.Write(data)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed.Sum(nil), signature) == nil
+	case *ecdsa.PublicKey:
+		hash := crypto.SHA256
+		switch pk.algorithm {
+		case AlgES384:
+			hash = crypto.SHA384
+		case AlgES512:
+			hash = crypto.SHA512
+		}
+		hashed := hash.New()
+		hashed.Write(data)
+		// WebAuthn ECDSA signatures are ASN.1 DER encoded
+		return ecdsa.VerifyASN1(key, hashed.Sum(nil), signature)
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package webauthn
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/andreimerlescu/goth/gothic"
+)
+
+// maxCredentialSize bounds the size of the credentials posted by browsers.
+const maxCredentialSize = 64 << 10
+
+func (p *Provider) registrationKey() string {
+	return p.providerName + "_registration"
+}
+
+// BeginRegistration starts registering a passkey for the user, who must be
+// signed in to the application. The options of navigator.credentials.create
+// are written to the response, and the ceremony is kept in the gothic session
+// until CompleteRegistration.
+func BeginRegistration(res http.ResponseWriter, req *http.Request, p *Provider, user User) error {
+	s, err := p.BeginRegistration(user)
+	if err != nil {
+		return err
+	}
+	contentType, challenge, err := s.Challenge()
+	if err != nil {
+		return err
+	}
+
+	err = gothic.StoreInSession(p.registrationKey(), s.Marshal(), req, res)
+	if err != nil {
+		return err
+	}
+
+	res.Header().Set("Content-Type", contentType)
+	res.Header().Set("Cache-Control", "no-store")
+	_, err = res.Write(challenge)
+	return err
+}
+
+// CompleteRegistration verifies the credential created by the browser for the
+// ceremony started by BeginRegistration, and saves it to the store. The
+// credential is read from the "credential" form value of a form post, or the
+// JSON body of the request.
+func CompleteRegistration(res http.ResponseWriter, req *http.Request, p *Provider) (*Credential, error) {
+	value, err := gothic.GetFromSession(p.registrationKey(), req)
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return nil, errors.New("webauthn: no registration in progress")
+	}
+	s, err := p.UnmarshalRegistrationSession(value)
+	if err != nil {
+		return nil, err
+	}
+	// the ceremony can only be completed once
+	err = gothic.StoreInSession(p.registrationKey(), "", req, res)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = http.MaxBytesReader(res, req.Body, maxCredentialSize)
+	var response []byte
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		response = []byte(req.PostFormValue("credential"))
+	} else {
+		response, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p.FinishRegistration(s, response)
+}
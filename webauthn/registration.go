@@ -0,0 +1,189 @@
+package webauthn
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// RegistrationSession stores data during the registration ceremony of a
+// credential.
+type RegistrationSession struct {
+	User    User
+	Options *CreationOptions
+}
+
+// BeginRegistration creates the challenge to register a passkey for the user.
+// Credentials the user has already registered are excluded, so an
+// authenticator can't register twice.
+func (p *Provider) BeginRegistration(user User) (*RegistrationSession, error) {
+	if user.ID == "" {
+		return nil, errors.New("webauthn: the user has no ID")
+	}
+	challenge, err := newChallenge()
+	if err != nil {
+		return nil, err
+	}
+	existing, err := p.Store.UserCredentials(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	displayName := user.DisplayName
+	if displayName == "" {
+		displayName = user.Name
+	}
+	options := &CreationOptions{
+		RP: RelyingParty{ID: p.RPID, Name: p.RPName},
+		User: UserEntity{
+			ID:          base64.RawURLEncoding.EncodeToString([]byte(user.ID)),
+			Name:        user.Name,
+			DisplayName: displayName,
+		},
+		Challenge: challenge,
+		Timeout:   p.Timeout.Milliseconds(),
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   p.UserVerification,
+		},
+		Attestation: "none",
+	}
+	for _, alg := range supportedAlgorithms {
+		options.PubKeyCredParams = append(options.PubKeyCredParams, CredentialParameters{Type: "public-key", Algorithm: alg})
+	}
+	for _, c := range existing {
+		options.ExcludeCredentials = append(options.ExcludeCredentials, CredentialDescriptor{
+			Type:       "public-key",
+			ID:         base64.RawURLEncoding.EncodeToString(c.ID),
+			Transports: c.Transports,
+		})
+	}
+
+	return &RegistrationSession{User: user, Options: options}, nil
+}
+
+// Challenge returns the options of navigator.credentials.create as JSON.
+func (s RegistrationSession) Challenge() (string, []byte, error) {
+	if s.Options == nil {
+		return "", nil, errors.New("webauthn: the ceremony has no challenge")
+	}
+	b, err := json.Marshal(struct {
+		PublicKey *CreationOptions `json:"publicKey"`
+	}{s.Options})
+	return "application/json", b, err
+}
+
+type attestationCredential struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// FinishRegistration verifies the credential created by the browser, in the
+// JSON form of the PublicKeyCredential, and saves it to the store.
+// The attestation statement isn't verified, as none was requested.
+func (p *Provider) FinishRegistration(s *RegistrationSession, response []byte) (*Credential, error) {
+	if s.Options == nil {
+		return nil, errors.New("webauthn: the ceremony has no challenge")
+	}
+
+	ac := attestationCredential{}
+	err := json.Unmarshal(response, &ac)
+	if err != nil {
+		return nil, err
+	}
+	if ac.Type != "public-key" {
+		return nil, errors.New("webauthn: not a public key credential")
+	}
+	rawClientData, err := decodeBase64URL(ac.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	attestationObject, err := decodeBase64URL(ac.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.verifyClientData(rawClientData, "webauthn.create", s.Options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	item, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, err
+	}
+	attestation, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("webauthn: invalid attestation object")
+	}
+	rawAuthenticatorData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("webauthn: attestation object has no authenticator data")
+	}
+
+	ad, err := parseAuthenticatorData(rawAuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+	err = p.verifyAuthenticatorData(ad)
+	if err != nil {
+		return nil, err
+	}
+	if ad.credentialID == nil {
+		return nil, errors.New("webauthn: authenticator data has no attested credential")
+	}
+	if rawID, err := decodeBase64URL(ac.RawID); err != nil || string(rawID) != string(ad.credentialID) {
+		return nil, errors.New("webauthn: credential ID mismatch")
+	}
+
+	_, err = p.Store.Credential(ad.credentialID)
+	if err == nil {
+		return nil, errors.New("webauthn: credential is already registered")
+	}
+	if !errors.Is(err, ErrCredentialNotFound) {
+		return nil, err
+	}
+
+	now := goth.Now()
+	credential := &Credential{
+		ID:          ad.credentialID,
+		UserID:      s.User.ID,
+		UserName:    s.User.Name,
+		DisplayName: s.Options.User.DisplayName,
+		PublicKey:   ad.rawPublicKey,
+		SignCount:   ad.signCount,
+		Transports:  ac.Response.Transports,
+		CreatedAt:   now,
+		LastUsedAt:  now,
+	}
+	err = p.Store.SaveCredential(credential)
+	if err != nil {
+		return nil, err
+	}
+	// the challenge can only be used once
+	s.Options = nil
+	return credential, nil
+}
+
+// Marshal the session into a string
+func (s RegistrationSession) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// UnmarshalRegistrationSession will unmarshal a JSON string into a registration session.
+func (p *Provider) UnmarshalRegistrationSession(data string) (*RegistrationSession, error) {
+	s := &RegistrationSession{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
+	return s, err
+}
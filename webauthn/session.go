@@ -0,0 +1,191 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+)
+
+// Session stores data during the sign in ceremony with WebAuthn.
+type Session struct {
+	AuthURL      string
+	Options      *RequestOptions
+	UserID       string
+	UserName     string
+	DisplayName  string
+	CredentialID string // base64url encoded
+	UserVerified bool
+	// ExpiresAt is when the challenge expires, after the timeout of the
+	// provider.
+	ExpiresAt time.Time
+}
+
+var (
+	_ goth.Session          = &Session{}
+	_ goth.ChallengeSession = &Session{}
+)
+
+// GetAuthURL will return the URL the credential is posted to.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Challenge returns the options of navigator.credentials.get as JSON.
+func (s Session) Challenge() (string, []byte, error) {
+	if s.Options == nil {
+		return "", nil, errors.New("webauthn: the ceremony has no challenge")
+	}
+	b, err := json.Marshal(struct {
+		PublicKey *RequestOptions `json:"publicKey"`
+	}{s.Options})
+	return "application/json", b, err
+}
+
+type assertionCredential struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// Authorize verifies the credential posted as the "credential" parameter, in
+// the JSON form of the PublicKeyCredential, and returns its base64url encoded ID.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	if s.Options == nil {
+		return "", errors.New("webauthn: the ceremony has no challenge")
+	}
+	if !s.ExpiresAt.IsZero() && goth.Now().After(s.ExpiresAt) {
+		return "", ErrChallengeExpired
+	}
+
+	ac := assertionCredential{}
+	err := json.NewDecoder(strings.NewReader(params.Get("credential"))).Decode(&ac)
+	if err != nil {
+		return "", err
+	}
+	if ac.Type != "public-key" {
+		return "", errors.New("webauthn: not a public key credential")
+	}
+	id, err := decodeBase64URL(ac.RawID)
+	if err != nil {
+		return "", err
+	}
+	if !s.allows(id) {
+		return "", errors.New("webauthn: credential not allowed by the ceremony")
+	}
+	rawClientData, err := decodeBase64URL(ac.Response.ClientDataJSON)
+	if err != nil {
+		return "", err
+	}
+	rawAuthenticatorData, err := decodeBase64URL(ac.Response.AuthenticatorData)
+	if err != nil {
+		return "", err
+	}
+	signature, err := decodeBase64URL(ac.Response.Signature)
+	if err != nil {
+		return "", err
+	}
+	userHandle, err := decodeBase64URL(ac.Response.UserHandle)
+	if err != nil {
+		return "", err
+	}
+
+	err = p.verifyClientData(rawClientData, "webauthn.get", s.Options.Challenge)
+	if err != nil {
+		return "", err
+	}
+	ad, err := parseAuthenticatorData(rawAuthenticatorData)
+	if err != nil {
+		return "", err
+	}
+	err = p.verifyAuthenticatorData(ad)
+	if err != nil {
+		return "", err
+	}
+
+	credential, err := p.Store.Credential(id)
+	if err != nil {
+		return "", err
+	}
+	if len(userHandle) > 0 && string(userHandle) != credential.UserID {
+		return "", errors.New("webauthn: credential of another user")
+	}
+
+	pk, _, err := parsePublicKey(credential.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	clientDataHash := sha256.Sum256(rawClientData)
+	if !pk.verify(append(rawAuthenticatorData, clientDataHash[:]...), signature) {
+		return "", errors.New("webauthn: invalid signature")
+	}
+
+	// authenticators which don't count signatures always return zero
+	if ad.signCount != 0 || credential.SignCount != 0 {
+		if ad.signCount <= credential.SignCount {
+			return "", ErrSignCount
+		}
+	}
+	credential.SignCount = ad.signCount
+	credential.LastUsedAt = goth.Now()
+	err = p.Store.SaveCredential(credential)
+	if err != nil {
+		return "", err
+	}
+
+	s.UserID = credential.UserID
+	s.UserName = credential.UserName
+	s.DisplayName = credential.DisplayName
+	s.CredentialID = base64.RawURLEncoding.EncodeToString(credential.ID)
+	s.UserVerified = ad.flags&flagUserVerified != 0
+	// the challenge can only be used once
+	s.Options = nil
+	return s.CredentialID, nil
+}
+
+// allows reports whether the credential is in the allowCredentials of the
+// challenge, any registered credential is allowed when it is empty.
+func (s Session) allows(id []byte) bool {
+	if len(s.Options.AllowCredentials) == 0 {
+		return true
+	}
+	for _, c := range s.Options.AllowCredentials {
+		allowed, err := decodeBase64URL(c.ID)
+		if err == nil && bytes.Equal(allowed, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return goth.RedactJSON(s.Marshal())
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	s := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
+	return s, err
+}
@@ -0,0 +1,82 @@
+package webauthn
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCredentialNotFound is returned by a CredentialStore for unknown credentials.
+var ErrCredentialNotFound = errors.New("webauthn: credential not found")
+
+// Credential is a public key credential registered for a user.
+type Credential struct {
+	ID          []byte
+	UserID      string
+	UserName    string
+	DisplayName string
+	// PublicKey is the COSE_Key encoded public key of the credential.
+	PublicKey  []byte
+	SignCount  uint32
+	Transports []string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+// CredentialStore keeps the credentials registered with the relying party.
+type CredentialStore interface {
+	// Credential returns the credential with the ID, or ErrCredentialNotFound.
+	Credential(id []byte) (*Credential, error)
+	// UserCredentials returns the credentials registered for the user.
+	UserCredentials(userID string) ([]*Credential, error)
+	// SaveCredential stores a new credential, or updates a known one after
+	// it has been used.
+	SaveCredential(credential *Credential) error
+}
+
+// MemoryStore is a CredentialStore keeping credentials in memory, for tests
+// and development.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	credentials map[string]Credential
+}
+
+var _ CredentialStore = &MemoryStore{}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{credentials: map[string]Credential{}}
+}
+
+// Credential returns the credential with the ID, or ErrCredentialNotFound.
+func (m *MemoryStore) Credential(id []byte) (*Credential, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c, ok := m.credentials[string(id)]
+	if !ok {
+		return nil, ErrCredentialNotFound
+	}
+	return &c, nil
+}
+
+// UserCredentials returns the credentials registered for the user.
+func (m *MemoryStore) UserCredentials(userID string) ([]*Credential, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var credentials []*Credential
+	for _, c := range m.credentials {
+		if c.UserID == userID {
+			c := c
+			credentials = append(credentials, &c)
+		}
+	}
+	return credentials, nil
+}
+
+// SaveCredential stores the credential.
+func (m *MemoryStore) SaveCredential(credential *Credential) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.credentials[string(credential.ID)] = *credential
+	return nil
+}
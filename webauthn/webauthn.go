@@ -0,0 +1,273 @@
+// Package webauthn implements WebAuthn relying party ceremonies, so users can
+// sign in with passkeys alongside the other goth providers.
+//
+// Passkeys are registered by users who are already signed in, with
+// BeginRegistration and CompleteRegistration. The credentials are kept in a
+// CredentialStore provided by the application.
+//
+// Signing in with a passkey is a goth provider: gothic.BeginChallengeHandler
+// returns the options for navigator.credentials.get, and the credential it
+// resolves to is posted as the "credential" form value to the callback, which
+// calls gothic.CompleteUserAuth as usual:
+//
+//	const options = await (await fetch("/auth/webauthn/challenge")).json();
+//	const credential = await navigator.credentials.get({
+//		publicKey: PublicKeyCredential.parseRequestOptionsFromJSON(options.publicKey),
+//	});
+//	await fetch("/auth/webauthn/callback", {
+//		method: "POST",
+//		body: new URLSearchParams({credential: JSON.stringify(credential)}),
+//	});
+//
+// Attestation is not requested, so authenticators are not verified, which is
+// what passkeys are meant for.
+package webauthn
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"golang.org/x/oauth2"
+)
+
+// User verification requirements of the ceremonies.
+const (
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+)
+
+// ErrSignCount is returned when the signature counter of an authenticator
+// didn't increase, which signals the credential may have been cloned.
+var ErrSignCount = errors.New("webauthn: signature counter of the credential didn't increase")
+
+// ErrChallengeExpired is returned for credentials posted after the timeout of
+// the ceremony.
+var ErrChallengeExpired = errors.New("webauthn: the challenge has expired")
+
+// New creates a new WebAuthn provider for the relying party rpID, usually the
+// domain of the application, served at origin. Credentials are looked up in,
+// and saved to the store.
+// You should always call `webauthn.New` to get a new Provider. Never try to
+// create one manually.
+func New(rpID, rpName, origin, callbackURL string, store CredentialStore) *Provider {
+	return &Provider{
+		RPID:             rpID,
+		RPName:           rpName,
+		Origins:          []string{origin},
+		CallbackURL:      callbackURL,
+		Store:            store,
+		UserVerification: UserVerificationPreferred,
+		Timeout:          5 * time.Minute,
+		providerName:     "webauthn",
+	}
+}
+
+// Provider is the implementation of `goth.Provider` for signing in with WebAuthn.
+type Provider struct {
+	RPID   string
+	RPName string
+	// Origins are the origins the ceremonies may happen at.
+	Origins          []string
+	CallbackURL      string
+	Store            CredentialStore
+	UserVerification string
+	Timeout          time.Duration
+	providerName     string
+}
+
+// User is the account a credential is registered for.
+type User struct {
+	// ID identifies the user, it is returned by authenticators as the user
+	// handle and must not contain personal information.
+	ID          string
+	Name        string
+	DisplayName string
+}
+
+// RelyingParty describes the application to authenticators.
+type RelyingParty struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+// UserEntity describes the user to authenticators, its ID is base64url encoded.
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// CredentialParameters is a type of credential the relying party accepts.
+type CredentialParameters struct {
+	Type      string `json:"type"`
+	Algorithm int64  `json:"alg"`
+}
+
+// CredentialDescriptor identifies a credential, its ID is base64url encoded.
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// AuthenticatorSelection lists the requirements on the authenticator
+// creating a credential.
+type AuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey,omitempty"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification,omitempty"`
+}
+
+// CreationOptions are the options of navigator.credentials.create, in their
+// JSON form, see PublicKeyCredential.parseCreationOptionsFromJSON.
+type CreationOptions struct {
+	RP                     RelyingParty           `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              string                 `json:"challenge"`
+	PubKeyCredParams       []CredentialParameters `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials,omitempty"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are the options of navigator.credentials.get, in their JSON
+// form, see PublicKeyCredential.parseRequestOptionsFromJSON.
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout,omitempty"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials,omitempty"`
+	UserVerification string                 `json:"userVerification,omitempty"`
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// String returns the provider with its secrets redacted, so it can be logged.
+func (p *Provider) String() string {
+	return goth.RedactedString(p)
+}
+
+// GoString returns the provider with its secrets redacted.
+func (p *Provider) GoString() string {
+	return goth.RedactedGoString(p)
+}
+
+// Local reports that the provider is a goth.LocalProvider: the credentials are verified during the login, not with a token.
+func (p *Provider) Local() bool {
+	return true
+}
+
+// Debug is a no-op for the webauthn package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth creates the challenge of a sign in ceremony. No credentials are
+// listed, so the user picks any passkey registered for the relying party.
+// The challenge protects the ceremony against CSRF, the state isn't used.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return p.beginAuth(nil)
+}
+
+// BeginAuthForUser creates the challenge of a sign in ceremony with the
+// passkeys registered for the user, e.g. to confirm the identity of a user
+// who is already signed in. Other credentials are rejected.
+func (p *Provider) BeginAuthForUser(userID string) (goth.Session, error) {
+	credentials, err := p.Store.UserCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 {
+		return nil, ErrCredentialNotFound
+	}
+	allowed := make([]CredentialDescriptor, 0, len(credentials))
+	for _, c := range credentials {
+		allowed = append(allowed, CredentialDescriptor{
+			Type:       "public-key",
+			ID:         base64.RawURLEncoding.EncodeToString(c.ID),
+			Transports: c.Transports,
+		})
+	}
+	return p.beginAuth(allowed)
+}
+
+func (p *Provider) beginAuth(allowed []CredentialDescriptor) (goth.Session, error) {
+	challenge, err := newChallenge()
+	if err != nil {
+		return nil, err
+	}
+	session := &Session{
+		AuthURL: p.CallbackURL,
+		Options: &RequestOptions{
+			Challenge:        challenge,
+			Timeout:          p.Timeout.Milliseconds(),
+			RPID:             p.RPID,
+			AllowCredentials: allowed,
+			UserVerification: p.UserVerification,
+		},
+	}
+	if p.Timeout > 0 {
+		session.ExpiresAt = goth.Now().Add(p.Timeout)
+	}
+	return session, nil
+}
+
+// FetchUser returns the user the credential of the session was registered for.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	s := session.(*Session)
+	user := goth.User{
+		Provider: p.Name(),
+		UserID:   s.UserID,
+		NickName: s.UserName,
+		Name:     s.DisplayName,
+	}
+
+	if s.UserID == "" {
+		// the ceremony isn't completed yet
+		return user, fmt.Errorf("%s cannot get user information without a verified credential", p.providerName)
+	}
+
+	user.RawData = map[string]interface{}{
+		"credential_id": s.CredentialID,
+		"user_verified": s.UserVerified,
+	}
+	if strings.Contains(s.UserName, "@") {
+		user.Email = s.UserName
+	}
+	return user, nil
+}
+
+// RefreshToken refresh token is not provided by webauthn
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by webauthn")
+}
+
+// RefreshTokenAvailable refresh token is not provided by webauthn
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
+
+func newChallenge() (string, error) {
+	b, err := goth.RandomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeBase64URL decodes base64url values, with or without padding.
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
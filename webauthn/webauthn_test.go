@@ -0,0 +1,447 @@
+package webauthn_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	"github.com/andreimerlescu/goth/webauthn"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+var testUser = webauthn.User{ID: "user-1", Name: "jdoe@example.com", DisplayName: "John Doe"}
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.RPID, testRPID)
+	a.Equal(p.Origins, []string{testOrigin})
+	a.Equal(p.CallbackURL, "/foo")
+	a.Equal(p.Name(), "webauthn")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*webauthn.Session)
+	a.Equal("/foo", s.AuthURL)
+
+	contentType, challenge, err := s.Challenge()
+	a.NoError(err)
+	a.Equal("application/json", contentType)
+	options := struct {
+		PublicKey webauthn.RequestOptions `json:"publicKey"`
+	}{}
+	a.NoError(json.Unmarshal(challenge, &options))
+	a.Equal(testRPID, options.PublicKey.RPID)
+	a.Len(decode(options.PublicKey.Challenge), 32)
+}
+
+func Test_RegisterAndSignIn(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	auth := newAuthenticator()
+
+	credential := register(t, p, auth)
+	a.Equal(auth.id, credential.ID)
+	a.Equal("user-1", credential.UserID)
+
+	// registering the same authenticator again is prevented
+	rs, err := p.BeginRegistration(testUser)
+	a.NoError(err)
+	a.Len(rs.Options.ExcludeCredentials, 1)
+	_, err = p.FinishRegistration(rs, auth.create(rs.Options.Challenge, testOrigin, testRPID))
+	a.EqualError(err, "webauthn: credential is already registered")
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*webauthn.Session)
+	id, err := s.Authorize(p, url.Values{"credential": {auth.get(s.Options.Challenge, testOrigin, testRPID, "user-1")}})
+	a.NoError(err)
+	a.Equal(base64.RawURLEncoding.EncodeToString(auth.id), id)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("webauthn", user.Provider)
+	a.Equal("user-1", user.UserID)
+	a.Equal("jdoe@example.com", user.NickName)
+	a.Equal("jdoe@example.com", user.Email)
+	a.Equal("John Doe", user.Name)
+	a.Equal(true, user.RawData["user_verified"])
+
+	stored, err := p.Store.Credential(auth.id)
+	a.NoError(err)
+	a.Equal(auth.count, stored.SignCount)
+
+	// the challenge can't be used twice
+	_, err = s.Authorize(p, url.Values{"credential": {auth.get("", testOrigin, testRPID, "user-1")}})
+	a.Error(err)
+}
+
+func Test_AuthorizeRejectsInvalidCredentials(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		credential func(auth *authenticator, challenge string) string
+		err        string
+	}{
+		"other challenge": {
+			credential: func(auth *authenticator, challenge string) string {
+				return auth.get(base64.RawURLEncoding.EncodeToString(make([]byte, 32)), testOrigin, testRPID, "user-1")
+			},
+			err: "webauthn: challenge mismatch",
+		},
+		"other origin": {
+			credential: func(auth *authenticator, challenge string) string {
+				return auth.get(challenge, "https://evil.example.com", testRPID, "user-1")
+			},
+			err: `webauthn: ceremony at origin "https://evil.example.com"`,
+		},
+		"other relying party": {
+			credential: func(auth *authenticator, challenge string) string {
+				return auth.get(challenge, testOrigin, "evil.example.com", "user-1")
+			},
+			err: "webauthn: authenticator data for another relying party",
+		},
+		"other user": {
+			credential: func(auth *authenticator, challenge string) string {
+				return auth.get(challenge, testOrigin, testRPID, "user-2")
+			},
+			err: "webauthn: credential of another user",
+		},
+		"invalid signature": {
+			credential: func(auth *authenticator, challenge string) string {
+				other := newAuthenticator()
+				other.id = auth.id
+				other.count = auth.count
+				return other.get(challenge, testOrigin, testRPID, "user-1")
+			},
+			err: "webauthn: invalid signature",
+		},
+		"cloned": {
+			credential: func(auth *authenticator, challenge string) string {
+				auth.count = 0
+				return auth.get(challenge, testOrigin, testRPID, "user-1")
+			},
+			err: webauthn.ErrSignCount.Error(),
+		},
+		"unknown": {
+			credential: func(auth *authenticator, challenge string) string {
+				auth.id = []byte("unknown")
+				return auth.get(challenge, testOrigin, testRPID, "user-1")
+			},
+			err: webauthn.ErrCredentialNotFound.Error(),
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			a := assert.New(t)
+			p := provider()
+			auth := newAuthenticator()
+			register(t, p, auth)
+
+			session, err := p.BeginAuth("test_state")
+			a.NoError(err)
+			s := session.(*webauthn.Session)
+			_, err = s.Authorize(p, url.Values{"credential": {test.credential(auth, s.Options.Challenge)}})
+			a.EqualError(err, test.err)
+			a.Empty(s.UserID)
+		})
+	}
+}
+
+func Test_BeginAuthForUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	auth := newAuthenticator()
+	register(t, p, auth)
+	other := newAuthenticator()
+	rs, err := p.BeginRegistration(webauthn.User{ID: "user-2", Name: "other@example.com"})
+	a.NoError(err)
+	_, err = p.FinishRegistration(rs, other.create(rs.Options.Challenge, testOrigin, testRPID))
+	a.NoError(err)
+
+	_, err = p.BeginAuthForUser("user-3")
+	a.Equal(webauthn.ErrCredentialNotFound, err)
+
+	session, err := p.BeginAuthForUser("user-1")
+	a.NoError(err)
+	s := session.(*webauthn.Session)
+	a.Equal([]webauthn.CredentialDescriptor{{Type: "public-key", ID: base64.RawURLEncoding.EncodeToString(auth.id), Transports: []string{"internal"}}}, s.Options.AllowCredentials)
+
+	_, err = s.Authorize(p, url.Values{"credential": {other.get(s.Options.Challenge, testOrigin, testRPID, "user-2")}})
+	a.EqualError(err, "webauthn: credential not allowed by the ceremony")
+	a.Empty(s.UserID)
+
+	_, err = s.Authorize(p, url.Values{"credential": {auth.get(s.Options.Challenge, testOrigin, testRPID, "user-1")}})
+	a.NoError(err)
+	a.Equal("user-1", s.UserID)
+}
+
+func Test_AuthorizeRejectsExpiredChallenge(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	auth := newAuthenticator()
+	register(t, p, auth)
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*webauthn.Session)
+	a.WithinDuration(time.Now().Add(p.Timeout), s.ExpiresAt, time.Minute)
+
+	s.ExpiresAt = time.Now().Add(-time.Second)
+	_, err = s.Authorize(p, url.Values{"credential": {auth.get(s.Options.Challenge, testOrigin, testRPID, "user-1")}})
+	a.Equal(webauthn.ErrChallengeExpired, err)
+}
+
+func Test_FinishRegistrationRejectsOtherOrigin(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	rs, err := p.BeginRegistration(testUser)
+	a.NoError(err)
+	_, err = p.FinishRegistration(rs, newAuthenticator().create(rs.Options.Challenge, "https://evil.example.com", testRPID))
+	a.Error(err)
+
+	credentials, err := p.Store.UserCredentials("user-1")
+	a.NoError(err)
+	a.Empty(credentials)
+}
+
+func Test_Handlers(t *testing.T) {
+	a := assert.New(t)
+	gothic.Store = sessions.NewCookieStore([]byte("webauthn-test-key"))
+	p := provider()
+	auth := newAuthenticator()
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/passkeys/register", nil)
+	a.NoError(webauthn.BeginRegistration(res, req, p, testUser))
+	options := struct {
+		PublicKey webauthn.CreationOptions `json:"publicKey"`
+	}{}
+	a.NoError(json.Unmarshal(res.Body.Bytes(), &options))
+	a.Equal(base64.RawURLEncoding.EncodeToString([]byte("user-1")), options.PublicKey.User.ID)
+
+	body := auth.create(options.PublicKey.Challenge, testOrigin, testRPID)
+	req = httptest.NewRequest("POST", "/passkeys/register", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for _, cookie := range res.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	res = httptest.NewRecorder()
+	credential, err := webauthn.CompleteRegistration(res, req, p)
+	a.NoError(err)
+	a.Equal(auth.id, credential.ID)
+
+	// the registration can't be completed twice
+	req = httptest.NewRequest("POST", "/passkeys/register", bytes.NewReader(body))
+	for _, cookie := range res.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	_, err = webauthn.CompleteRegistration(httptest.NewRecorder(), req, p)
+	a.Error(err)
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"/foo","Options":{"challenge":"Y2hhbGxlbmdl","rpId":"example.com"},"UserID":"user-1"}`)
+	a.NoError(err)
+
+	s := session.(*webauthn.Session)
+	a.Equal(s.AuthURL, "/foo")
+	a.Equal(s.Options.Challenge, "Y2hhbGxlbmdl")
+	a.Equal(s.UserID, "user-1")
+}
+
+func provider() *webauthn.Provider {
+	return webauthn.New(testRPID, "Example", testOrigin, "/foo", webauthn.NewMemoryStore())
+}
+
+func register(t *testing.T, p *webauthn.Provider, auth *authenticator) *webauthn.Credential {
+	rs, err := p.BeginRegistration(testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := p.FinishRegistration(rs, auth.create(rs.Options.Challenge, testOrigin, testRPID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return credential
+}
+
+// authenticator is a software authenticator with an ES256 credential.
+type authenticator struct {
+	key   *ecdsa.PrivateKey
+	id    []byte
+	count uint32
+}
+
+func newAuthenticator() *authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return &authenticator{key: key, id: id}
+}
+
+func (a *authenticator) create(challenge, origin, rpID string) []byte {
+	a.count++
+	x := a.key.X.FillBytes(make([]byte, 32))
+	y := a.key.Y.FillBytes(make([]byte, 32))
+	// COSE_Key: kty EC2, alg ES256, crv P-256, x, y
+	publicKey := cborMap(
+		cborInt(1), cborInt(2),
+		cborInt(3), cborInt(-7),
+		cborInt(-1), cborInt(1),
+		cborInt(-2), cborBytes(x),
+		cborInt(-3), cborBytes(y),
+	)
+
+	authData := a.authenticatorData(rpID, 0x45)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = append(authData, byte(len(a.id)>>8), byte(len(a.id)))
+	authData = append(authData, a.id...)
+	authData = append(authData, publicKey...)
+
+	attestationObject := cborMap(
+		cborText("fmt"), cborText("none"),
+		cborText("attStmt"), cborMap(),
+		cborText("authData"), cborBytes(authData),
+	)
+
+	b, _ := json.Marshal(map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(a.id),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.id),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData("webauthn.create", challenge, origin)),
+			"attestationObject": base64.RawURLEncoding.EncodeToString(attestationObject),
+			"transports":        []string{"internal"},
+		},
+	})
+	return b
+}
+
+func (a *authenticator) get(challenge, origin, rpID, userHandle string) string {
+	a.count++
+	authData := a.authenticatorData(rpID, 0x05)
+	cd := clientData("webauthn.get", challenge, origin)
+	cdHash := sha256.Sum256(cd)
+	hashed := sha256.Sum256(append(append([]byte{}, authData...), cdHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, hashed[:])
+	if err != nil {
+		panic(err)
+	}
+
+	b, _ := json.Marshal(map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(a.id),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.id),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(cd),
+			"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+			"signature":         base64.RawURLEncoding.EncodeToString(signature),
+			"userHandle":        base64.RawURLEncoding.EncodeToString([]byte(userHandle)),
+		},
+	})
+	return string(b)
+}
+
+func (a *authenticator) authenticatorData(rpID string, flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append(rpIDHash[:], flags)
+	count := make([]byte, 4)
+	binary.BigEndian.PutUint32(count, a.count)
+	return append(data, count...)
+}
+
+func clientData(ceremony, challenge, origin string) []byte {
+	b, _ := json.Marshal(map[string]interface{}{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    origin,
+	})
+	return b
+}
+
+func cborHeader(major byte, n int) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 256:
+		return []byte{major<<5 | 24, byte(n)}
+	default:
+		return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+	}
+}
+
+func cborInt(n int) []byte {
+	if n < 0 {
+		return cborHeader(1, -1-n)
+	}
+	return cborHeader(0, n)
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHeader(2, len(b)), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHeader(3, len(s)), s...)
+}
+
+func cborMap(items ...[]byte) []byte {
+	m := cborHeader(5, len(items)/2)
+	for _, item := range items {
+		m = append(m, item...)
+	}
+	return m
+}
+
+func decode(s string) []byte {
+	b, _ := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	return b
+}
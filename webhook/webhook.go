@@ -0,0 +1,287 @@
+// Package webhook posts the logins of gothic as JSON to a webhook, e.g. to
+// feed a SIEM. The requests are signed with HMAC-SHA256 and retried when the
+// endpoint is unavailable.
+//
+//	dispatcher := webhook.New("https://siem.example.com/hooks/logins", secret)
+//	dispatcher.Register()
+//	defer dispatcher.Close()
+//
+// Or configured with environment variables, without code changes once the
+// dispatcher is registered:
+//
+//	if dispatcher := webhook.NewFromEnv(); dispatcher != nil {
+//		dispatcher.Register()
+//	}
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	"golang.org/x/oauth2"
+)
+
+// The headers of the requests.
+const (
+	// DeliveryHeader is the ID of the delivery, the same for its retries.
+	DeliveryHeader  = "X-Goth-Delivery"
+	TimestampHeader = "X-Goth-Timestamp"
+	// SignatureHeader is "sha256=" and the hex encoded HMAC-SHA256 of the
+	// timestamp, a dot and the body.
+	SignatureHeader = "X-Goth-Signature"
+)
+
+// The types of payloads.
+const (
+	LoginSucceeded = "login.succeeded"
+	LoginFailed    = "login.failed"
+)
+
+// The environment variables read by NewFromEnv.
+const (
+	EnvURL        = "GOTH_WEBHOOK_URL"
+	EnvSecret     = "GOTH_WEBHOOK_SECRET"
+	EnvMaxRetries = "GOTH_WEBHOOK_MAX_RETRIES"
+)
+
+// Payload is the JSON posted for a login.
+type Payload struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Provider  string    `json:"provider,omitempty"`
+	Subject   string    `json:"subject,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Dispatcher posts the logins to a webhook.
+type Dispatcher struct {
+	URL string
+	// Secret is the key of the signatures. The requests aren't signed when it
+	// is empty.
+	Secret []byte
+	// MaxRetries is the number of retries of a delivery failing with a network
+	// error, a 429 or a 5xx status code.
+	MaxRetries int
+	// Backoff is the delay before the first retry, doubled for every retry.
+	Backoff time.Duration
+	// Timeout is the timeout of a request.
+	Timeout    time.Duration
+	HTTPClient *http.Client
+	// ClientIP returns the IP address of the client of a request. It defaults
+	// to the address of the connection, set it when the application is behind
+	// a proxy.
+	ClientIP func(req *http.Request) string
+	// ErrorHandler is called when a delivery failed after its retries. It
+	// defaults to logging the error.
+	ErrorHandler func(payload Payload, err error)
+
+	wg sync.WaitGroup
+}
+
+// New creates a dispatcher posting to the URL, signing with the secret, with
+// 3 retries.
+func New(url string, secret []byte) *Dispatcher {
+	return &Dispatcher{
+		URL:        url,
+		Secret:     secret,
+		MaxRetries: 3,
+		Backoff:    time.Second,
+		Timeout:    10 * time.Second,
+	}
+}
+
+// NewFromEnv creates a dispatcher from the GOTH_WEBHOOK_URL,
+// GOTH_WEBHOOK_SECRET and GOTH_WEBHOOK_MAX_RETRIES environment variables, or
+// returns nil when GOTH_WEBHOOK_URL isn't set.
+func NewFromEnv() *Dispatcher {
+	url := os.Getenv(EnvURL)
+	if url == "" {
+		return nil
+	}
+	d := New(url, []byte(os.Getenv(EnvSecret)))
+	if retries, err := strconv.Atoi(os.Getenv(EnvMaxRetries)); err == nil && retries >= 0 {
+		d.MaxRetries = retries
+	}
+	return d
+}
+
+// Register subscribes the dispatcher to the completed and failed
+// authentications of gothic, and returns the function unsubscribing it.
+func (d *Dispatcher) Register() (unsubscribe func()) {
+	completed := gothic.Subscribe(gothic.EventAuthCompleted, d.Handle)
+	failed := gothic.Subscribe(gothic.EventAuthFailed, d.Handle)
+	return func() {
+		completed()
+		failed()
+	}
+}
+
+// Handle posts the payload of the event in the background. It is a
+// gothic.EventHandler.
+func (d *Dispatcher) Handle(event gothic.Event) {
+	payload := Payload{
+		ID:       newID(),
+		Type:     LoginSucceeded,
+		Time:     event.Time.UTC(),
+		Provider: event.Provider,
+		Subject:  event.User.UserID,
+		Email:    event.User.Email,
+	}
+	if event.Err != nil {
+		payload.Type = LoginFailed
+		payload.Error = errorMessage(event.Err)
+	}
+	if event.Request != nil {
+		payload.IP = d.clientIP(event.Request)
+		payload.UserAgent = event.Request.UserAgent()
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		if err := d.Send(payload); err != nil {
+			d.handleError(payload, err)
+		}
+	}()
+}
+
+// Close waits for the deliveries in progress, e.g. before the application
+// exits.
+func (d *Dispatcher) Close() {
+	d.wg.Wait()
+}
+
+// Send posts the payload, with the retries.
+func (d *Dispatcher) Send(payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	backoff := d.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := d.post(payload.ID, body)
+		if err == nil || !retry || attempt >= d.MaxRetries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post posts the body, and reports whether it should be retried when it
+// fails.
+func (d *Dispatcher) post(id string, body []byte) (retry bool, err error) {
+	ctx := context.Background()
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", d.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(goth.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, id)
+	req.Header.Set(TimestampHeader, timestamp)
+	if len(d.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(d.Secret, timestamp, body))
+	}
+
+	resp, err := goth.HTTPClientWithFallBack(d.HTTPClient).Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("webhook: %s responded with a %d status code", d.URL, resp.StatusCode)
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+func (d *Dispatcher) handleError(payload Payload, err error) {
+	if d.ErrorHandler != nil {
+		d.ErrorHandler(payload, err)
+		return
+	}
+	log.Printf("webhook: delivering %s %s: %v", payload.Type, payload.ID, err)
+}
+
+func (d *Dispatcher) clientIP(req *http.Request) string {
+	if d.ClientIP != nil {
+		return d.ClientIP(req)
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// Sign returns the signature of the body posted at the timestamp.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature verifies the signature of a request received by a webhook,
+// and that it was sent within the tolerance, to reject replayed requests.
+func VerifySignature(secret []byte, timestamp, signature string, body []byte, tolerance time.Duration) bool {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := time.Since(time.Unix(unix, 0))
+	if age > tolerance || age < -tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
+
+func newID() string {
+	b, err := goth.RandomBytes(16)
+	if err != nil {
+		return strconv.FormatInt(goth.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// errorMessage returns the message of the error, without the body of the
+// responses of token endpoints, which may hold anything.
+func errorMessage(err error) string {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		if retrieveErr.ErrorCode != "" {
+			return "oauth2: token exchange failed: " + retrieveErr.ErrorCode
+		}
+		return "oauth2: token exchange failed"
+	}
+	return err.Error()
+}
@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	"github.com/stretchr/testify/assert"
+)
+
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	payloads []Payload
+	ids      []string
+}
+
+func (r *receiver) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts++
+	body, _ := io.ReadAll(req.Body)
+	if !VerifySignature([]byte("secret"), req.Header.Get(TimestampHeader), req.Header.Get(SignatureHeader), body, time.Minute) {
+		res.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	r.ids = append(r.ids, req.Header.Get(DeliveryHeader))
+	if r.failures > 0 {
+		r.failures--
+		res.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var payload Payload
+	_ = json.Unmarshal(body, &payload)
+	r.payloads = append(r.payloads, payload)
+}
+
+func Test_Handle(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	r := &receiver{failures: 2}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	d := New(server.URL, []byte("secret"))
+	d.Backoff = time.Millisecond
+	req := httptest.NewRequest("GET", "/auth/callback", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("User-Agent", "browser")
+
+	d.Handle(gothic.Event{Type: gothic.EventAuthCompleted, Provider: "github", Request: req, User: goth.User{UserID: "42", Email: "homer@example.com"}, Time: time.Now()})
+	d.Close()
+
+	a.Equal(3, r.attempts)
+	a.Len(r.payloads, 1)
+	a.Equal(LoginSucceeded, r.payloads[0].Type)
+	a.Equal("42", r.payloads[0].Subject)
+	a.Equal("192.0.2.1", r.payloads[0].IP)
+	a.Equal("browser", r.payloads[0].UserAgent)
+	// the retries are the same delivery
+	a.Equal(r.ids[0], r.ids[2])
+	a.Equal(r.payloads[0].ID, r.ids[0])
+}
+
+func Test_HandleFailure(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	r := &receiver{failures: 10}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	var failed []error
+	d := New(server.URL, []byte("secret"))
+	d.Backoff = time.Millisecond
+	d.MaxRetries = 1
+	d.ErrorHandler = func(payload Payload, err error) { failed = append(failed, err) }
+
+	d.Handle(gothic.Event{Type: gothic.EventAuthFailed, Provider: "github", Err: errors.New("state token mismatch")})
+	d.Close()
+	a.Equal(2, r.attempts)
+	a.Len(failed, 1)
+
+	// requests rejected by the endpoint aren't retried
+	d.Secret = []byte("other")
+	d.Handle(gothic.Event{Type: gothic.EventAuthFailed})
+	d.Close()
+	a.Equal(3, r.attempts)
+	a.Len(failed, 2)
+}
+
+func Test_Send(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	r := &receiver{}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	d := New(server.URL, []byte("secret"))
+	a.NoError(d.Send(Payload{ID: "1", Type: LoginFailed, Error: "failure"}))
+	a.Equal("failure", r.payloads[0].Error)
+}
+
+func Test_VerifySignature(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	body := []byte(`{"id":"1"}`)
+	a.True(VerifySignature([]byte("secret"), now, Sign([]byte("secret"), now, body), body, time.Minute))
+	a.False(VerifySignature([]byte("other"), now, Sign([]byte("secret"), now, body), body, time.Minute))
+	a.False(VerifySignature([]byte("secret"), now, Sign([]byte("secret"), now, body), []byte(`{"id":"2"}`), time.Minute))
+	a.False(VerifySignature([]byte("secret"), old, Sign([]byte("secret"), old, body), body, time.Minute))
+	a.False(VerifySignature([]byte("secret"), "now", Sign([]byte("secret"), "now", body), body, time.Minute))
+}
+
+func Test_NewFromEnv(t *testing.T) {
+	a := assert.New(t)
+
+	t.Setenv(EnvURL, "")
+	a.Nil(NewFromEnv())
+
+	t.Setenv(EnvURL, "https://siem.example.com/hooks")
+	t.Setenv(EnvSecret, "secret")
+	t.Setenv(EnvMaxRetries, "5")
+	d := NewFromEnv()
+	a.Equal("https://siem.example.com/hooks", d.URL)
+	a.Equal([]byte("secret"), d.Secret)
+	a.Equal(5, d.MaxRetries)
+}
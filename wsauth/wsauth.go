@@ -0,0 +1,280 @@
+/*
+Package wsauth authenticates the upgrades of WebSockets with the gothic
+session, and returns the goth.User before the handler hijacks the connection,
+while errors can still be written as HTTP responses:
+
+	auth := &wsauth.Authenticator{AllowedOrigins: []string{"https://app.example.com"}}
+	http.Handle("/ws", auth.Handler(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		user, _ := wsauth.UserFromContext(req.Context())
+		conn, err := upgrader.Upgrade(res, req, nil)
+		// ...
+	})))
+
+The browsers send the cookies of the session with the upgrades of the pages of
+the same site, unless it is cross-site: the SameSite=Lax and SameSite=Strict
+cookies, the default of most browsers, aren't sent with the upgrades of the
+pages of other sites, and the SameSite=None cookies must be Secure and are
+blocked by the browsers blocking the third-party cookies. As the browsers
+don't apply the same-origin policy to WebSockets, the origin of the upgrades
+authenticated with the cookies is checked, against cross-site WebSocket
+hijacking.
+
+The sockets of other origins authenticate with tickets instead: the page
+requests a short-lived ticket from the TicketHandler, authenticated with the
+session, and passes it in the TicketParam of the URL of the socket. Each
+ticket authenticates a single upgrade.
+*/
+package wsauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+)
+
+// TicketParam is the query parameter of the tickets of the upgrades.
+const TicketParam = "ticket"
+
+// DefaultTicketTTL is how long the tickets are valid when
+// Authenticator.TicketTTL isn't set.
+const DefaultTicketTTL = 30 * time.Second
+
+var (
+	// ErrNotUpgrade is returned for the requests which aren't WebSocket
+	// upgrades.
+	ErrNotUpgrade = errors.New("wsauth: not a websocket upgrade")
+	// ErrOriginNotAllowed is returned for the upgrades authenticated with the
+	// session from an origin which isn't allowed.
+	ErrOriginNotAllowed = errors.New("wsauth: origin not allowed")
+	// ErrInvalidTicket is returned for the tickets which are unknown,
+	// expired, or were already used.
+	ErrInvalidTicket = errors.New("wsauth: invalid ticket")
+)
+
+// TicketStore keeps the tickets of the upgrades, e.g. in a store shared by
+// the instances of the application.
+type TicketStore interface {
+	// Save saves the ticket of the user until it expires.
+	Save(ctx context.Context, ticket string, user goth.User, expires time.Time) error
+	// Redeem returns the user of the ticket and deletes it, or
+	// ErrInvalidTicket when it is unknown or expired.
+	Redeem(ctx context.Context, ticket string) (goth.User, error)
+}
+
+// Authenticator authenticates the upgrades of WebSockets.
+type Authenticator struct {
+	// AllowedOrigins are the origins, e.g. https://app.example.com, whose
+	// upgrades may be authenticated with the session, besides the origin of
+	// the host of the request.
+	AllowedOrigins []string
+	// Tickets keeps the tickets, which are disabled when it is nil.
+	Tickets TicketStore
+	// TicketTTL is how long the tickets are valid, DefaultTicketTTL when it
+	// is zero.
+	TicketTTL time.Duration
+}
+
+/*
+Authenticate returns the user of the WebSocket upgrade:
+
+  - the user of its ticket, when it has one;
+  - or the user of its gothic session, see gothic.SessionUser, when its
+    Origin is the one of its host or is allowed. The upgrades without an
+    Origin, which browsers always send, are the ones of other clients.
+*/
+func (a *Authenticator) Authenticate(req *http.Request) (goth.User, error) {
+	if !IsUpgrade(req) {
+		return goth.User{}, ErrNotUpgrade
+	}
+	if ticket := req.URL.Query().Get(TicketParam); ticket != "" {
+		if a.Tickets == nil {
+			return goth.User{}, ErrInvalidTicket
+		}
+		return a.Tickets.Redeem(req.Context(), ticket)
+	}
+	if !a.originAllowed(req) {
+		return goth.User{}, ErrOriginNotAllowed
+	}
+	return gothic.SessionUser(req.Context(), req)
+}
+
+// IsUpgrade reports whether the request is a WebSocket upgrade.
+func IsUpgrade(req *http.Request) bool {
+	if !strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range req.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a *Authenticator) originAllowed(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, req.Host) {
+		return true
+	}
+	for _, allowed := range a.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// IssueTicket returns a new ticket of the user, valid for TicketTTL.
+func (a *Authenticator) IssueTicket(ctx context.Context, user goth.User) (string, error) {
+	if a.Tickets == nil {
+		return "", errors.New("wsauth: no ticket store is configured")
+	}
+	b, err := goth.RandomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(b)
+	// the tickets don't carry the tokens of the user
+	user.AccessToken, user.AccessTokenSecret, user.RefreshToken, user.IDToken, user.RawData = "", "", "", "", nil
+	if err := a.Tickets.Save(ctx, ticket, user, goth.Now().Add(a.ticketTTL())); err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+func (a *Authenticator) ticketTTL() time.Duration {
+	if a.TicketTTL <= 0 {
+		return DefaultTicketTTL
+	}
+	return a.TicketTTL
+}
+
+// Ticket is the response of the TicketHandler.
+type Ticket struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// TicketHandler issues a ticket to the user of the gothic session of the
+// request, e.g. {"ticket":"…","expires_in":30}. The application exposes it
+// to the other origins of its sockets with CORS and credentials.
+func (a *Authenticator) TicketHandler(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		res.Header().Set("Allow", http.MethodPost)
+		gothic.ErrorHandler(res, req, http.StatusMethodNotAllowed, errors.New("wsauth: tickets are issued to POST requests"))
+		return
+	}
+	user, err := gothic.SessionUser(req.Context(), req)
+	if err != nil {
+		gothic.ErrorHandler(res, req, http.StatusUnauthorized, err)
+		return
+	}
+	ticket, err := a.IssueTicket(req.Context(), user)
+	if err != nil {
+		gothic.ErrorHandler(res, req, http.StatusInternalServerError, err)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(res).Encode(Ticket{Ticket: ticket, ExpiresIn: int(a.ticketTTL() / time.Second)})
+}
+
+// Handler authenticates the upgrades before the handler, which reads the user
+// with UserFromContext. The upgrades which aren't authenticated are refused
+// with gothic.ErrorHandler: 400 for the requests which aren't upgrades, 403
+// for the origins which aren't allowed, and 401 otherwise.
+func (a *Authenticator) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		user, err := a.Authenticate(req)
+		if err != nil {
+			status := http.StatusUnauthorized
+			switch {
+			case errors.Is(err, ErrNotUpgrade):
+				status = http.StatusBadRequest
+			case errors.Is(err, ErrOriginNotAllowed):
+				status = http.StatusForbidden
+			}
+			gothic.ErrorHandler(res, req, status, err)
+			return
+		}
+		handler.ServeHTTP(res, req.WithContext(NewContext(req.Context(), user)))
+	})
+}
+
+type userKey struct{}
+
+// NewContext returns a copy of ctx with the user, e.g. for the tests of the
+// handlers.
+func NewContext(ctx context.Context, user goth.User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext returns the user authenticated by the Handler.
+func UserFromContext(ctx context.Context) (goth.User, bool) {
+	user, ok := ctx.Value(userKey{}).(goth.User)
+	return user, ok
+}
+
+// MemoryTickets is a TicketStore in memory, for a single instance of the
+// application.
+type MemoryTickets struct {
+	mu      sync.Mutex
+	tickets map[string]memoryTicket
+}
+
+type memoryTicket struct {
+	user    goth.User
+	expires time.Time
+}
+
+// NewMemoryTickets returns an empty MemoryTickets.
+func NewMemoryTickets() *MemoryTickets {
+	return &MemoryTickets{tickets: map[string]memoryTicket{}}
+}
+
+// Save saves the ticket, and forgets the expired ones.
+func (t *MemoryTickets) Save(ctx context.Context, ticket string, user goth.User, expires time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := goth.Now()
+	for id, mt := range t.tickets {
+		if !now.Before(mt.expires) {
+			delete(t.tickets, id)
+		}
+	}
+	t.tickets[ticket] = memoryTicket{user: user, expires: expires}
+	return nil
+}
+
+// Redeem returns the user of the ticket and deletes it.
+func (t *MemoryTickets) Redeem(ctx context.Context, ticket string) (goth.User, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	mt, ok := t.tickets[ticket]
+	if !ok {
+		return goth.User{}, ErrInvalidTicket
+	}
+	delete(t.tickets, ticket)
+	if !goth.Now().Before(mt.expires) {
+		return goth.User{}, ErrInvalidTicket
+	}
+	return mt.user, nil
+}
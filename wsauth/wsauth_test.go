@@ -0,0 +1,126 @@
+package wsauth_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andreimerlescu/goth"
+	"github.com/andreimerlescu/goth/gothic"
+	"github.com/andreimerlescu/goth/providers/mock"
+	"github.com/andreimerlescu/goth/wsauth"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+// login returns the cookie of a gothic session of a mock provider.
+func login(t *testing.T) *http.Cookie {
+	store := gothic.Store
+	gothic.Store = sessions.NewCookieStore([]byte("test-session-key"))
+	p := mock.New(goth.User{UserID: "7", Name: "Marge", AccessToken: "secret"})
+	p.SetName("ws-mock")
+	goth.UseProviders(p)
+	t.Cleanup(func() {
+		gothic.Store = store
+		goth.UnregisterProviders("ws-mock")
+	})
+
+	res := httptest.NewRecorder()
+	sess := &mock.Session{UserID: "7", AccessToken: mock.AccessToken("7")}
+	if err := gothic.StoreInSession("ws-mock", sess.Marshal(), httptest.NewRequest("GET", "/", nil), res); err != nil {
+		t.Fatal(err)
+	}
+	return res.Result().Cookies()[0]
+}
+
+func upgrade(target, origin string, cookie *http.Cookie) *http.Request {
+	req := httptest.NewRequest("GET", target, nil)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+func Test_Handler(t *testing.T) {
+	a := assert.New(t)
+	cookie := login(t)
+	auth := &wsauth.Authenticator{AllowedOrigins: []string{"https://app.example.com/"}}
+	var user goth.User
+	handler := auth.Handler(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		user, _ = wsauth.UserFromContext(req.Context())
+	}))
+	serve := func(req *http.Request) int {
+		user = goth.User{}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	a.Equal(http.StatusOK, serve(upgrade("/ws", "http://example.com", cookie)))
+	a.Equal("7", user.UserID)
+	a.Equal("ws-mock", user.Provider)
+	a.Equal(http.StatusOK, serve(upgrade("/ws", "https://app.example.com", cookie)))
+	a.Equal("7", user.UserID)
+	a.Equal(http.StatusOK, serve(upgrade("/ws", "", cookie)))
+
+	// cross-site WebSocket hijacking
+	a.Equal(http.StatusForbidden, serve(upgrade("/ws", "https://evil.example", cookie)))
+	a.Empty(user.UserID)
+	a.Equal(http.StatusUnauthorized, serve(upgrade("/ws", "http://example.com", nil)))
+
+	notUpgrade := httptest.NewRequest("GET", "/ws", nil)
+	notUpgrade.AddCookie(cookie)
+	a.Equal(http.StatusBadRequest, serve(notUpgrade))
+	a.Equal(http.StatusUnauthorized, serve(upgrade("/ws?ticket=forged", "https://evil.example", nil)))
+}
+
+func Test_Tickets(t *testing.T) {
+	a := assert.New(t)
+	cookie := login(t)
+	auth := &wsauth.Authenticator{Tickets: wsauth.NewMemoryTickets(), TicketTTL: time.Minute}
+
+	res := httptest.NewRecorder()
+	auth.TicketHandler(res, httptest.NewRequest("GET", "/ws/ticket", nil))
+	a.Equal(http.StatusMethodNotAllowed, res.Code)
+	res = httptest.NewRecorder()
+	auth.TicketHandler(res, httptest.NewRequest("POST", "/ws/ticket", nil))
+	a.Equal(http.StatusUnauthorized, res.Code)
+
+	issue := func() string {
+		req := httptest.NewRequest("POST", "/ws/ticket", nil)
+		req.AddCookie(cookie)
+		res := httptest.NewRecorder()
+		auth.TicketHandler(res, req)
+		a.Equal(http.StatusOK, res.Code)
+		a.Equal("no-store", res.Header().Get("Cache-Control"))
+		var ticket wsauth.Ticket
+		a.NoError(json.NewDecoder(res.Body).Decode(&ticket))
+		a.Equal(60, ticket.ExpiresIn)
+		a.NotEmpty(ticket.Ticket)
+		return ticket.Ticket
+	}
+
+	// the sockets of other origins authenticate with the tickets, once
+	ticket := issue()
+	user, err := auth.Authenticate(upgrade("/ws?ticket="+ticket, "https://other.example", nil))
+	a.NoError(err)
+	a.Equal("7", user.UserID)
+	a.Empty(user.AccessToken)
+	_, err = auth.Authenticate(upgrade("/ws?ticket="+ticket, "https://other.example", nil))
+	a.ErrorIs(err, wsauth.ErrInvalidTicket)
+
+	// the tickets expire
+	ticket = issue()
+	now := time.Now()
+	goth.DefaultClock = goth.ClockFunc(func() time.Time { return now.Add(2 * time.Minute) })
+	defer func() { goth.DefaultClock = goth.ClockFunc(time.Now) }()
+	_, err = auth.Authenticate(upgrade("/ws?ticket="+ticket, "https://other.example", nil))
+	a.ErrorIs(err, wsauth.ErrInvalidTicket)
+}